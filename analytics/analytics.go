@@ -0,0 +1,270 @@
+/*
+	 Package analytics summarizes served jokes per UTC day and uploads
+	 the summaries to an S3-compatible bucket for the data team, and
+	 streams individual events to an EventSink.
+
+		Each instance uploads its own summaries, named after the day
+		and the instance, so replicas and restarts never overwrite
+		one another: the data team sums the files of a day.
+*/
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+// Layout of the day a summary covers
+const dayLayout = "2006-01-02"
+
+// Most jokes listed in a summary, and most distinct jokes and users counted per day
+const (
+	topJokes    = 10
+	maxDayJokes = 10000
+	maxDayUsers = 100000
+)
+
+// Uploader stores an object under key, e.g. an s3.Client
+type Uploader interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// Summary is the usage of one instance on one UTC day
+type Summary struct {
+	Date string `json:"date"`
+	// Instance names the process the summary came from
+	Instance string `json:"instance"`
+	// Partial summaries were uploaded before the day ended, on shutdown
+	Partial bool `json:"partial"`
+	Serves  int  `json:"serves"`
+	// Anonymous counts serves to visitors who weren't signed in
+	Anonymous  int            `json:"anonymous"`
+	Users      int            `json:"users"`
+	Categories map[string]int `json:"categories"`
+	// Tenants counts serves per tenant, "" for those without one
+	Tenants  map[string]int `json:"tenants"`
+	TopJokes []JokeCount    `json:"top_jokes"`
+}
+
+// JokeCount is how often a joke was served on a day
+type JokeCount struct {
+	Joke   string `json:"joke"`
+	Serves int    `json:"serves"`
+}
+
+// struct to hold the counts of one day as they are added
+type day struct {
+	serves     int
+	anonymous  int
+	users      map[string]bool
+	categories map[string]int
+	tenants    map[string]int
+	jokes      map[string]int
+}
+
+/*
+	 Aggregator counts served jokes per UTC day and uploads a Summary
+	 of each day once it ends
+
+		Safe for concurrent use. Build one with New and feed it with
+		history.Store.OnAdd.
+*/
+type Aggregator struct {
+	uploader Uploader
+	prefix   string
+	instance string
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu   sync.Mutex
+	days map[string]*day
+}
+
+/*
+	 New returns an Aggregator uploading to uploader under prefix
+
+		instance tells this process's summaries apart from other
+		replicas', e.g. the hostname and start time.
+*/
+func New(uploader Uploader, prefix, instance string, logger *slog.Logger) *Aggregator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Aggregator{
+		uploader: uploader,
+		prefix:   strings.Trim(prefix, "/"),
+		instance: instance,
+		logger:   logger,
+		now:      time.Now,
+		days:     make(map[string]*day),
+	}
+}
+
+// Add counts a served joke on the UTC day it was served
+func (a *Aggregator) Add(e history.Entry) {
+	date := e.ServedAt.UTC().Format(dayLayout)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	d, ok := a.days[date]
+	if !ok {
+		d = &day{
+			users:      make(map[string]bool),
+			categories: make(map[string]int),
+			tenants:    make(map[string]int),
+			jokes:      make(map[string]int),
+		}
+		a.days[date] = d
+	}
+	d.serves++
+	d.categories[e.Category]++
+	d.tenants[e.Tenant]++
+	if e.User == "" {
+		d.anonymous++
+	} else if len(d.users) < maxDayUsers {
+		d.users[e.User] = true
+	}
+	// Past the limit only jokes already counted are, so memory stays bounded
+	if _, ok := d.jokes[e.Joke]; ok || len(d.jokes) < maxDayJokes {
+		d.jokes[e.Joke]++
+	}
+}
+
+/*
+	 Export uploads a Summary of every day that has ended
+
+		With final set, e.g. on shutdown, the current day is uploaded
+		too, marked Partial. Days that fail to upload are kept and
+		retried on the next export.
+*/
+func (a *Aggregator) Export(ctx context.Context, final bool) error {
+	today := a.now().UTC().Format(dayLayout)
+	a.mu.Lock()
+	ready := make(map[string]*day)
+	for date, d := range a.days {
+		if date < today || final {
+			ready[date] = d
+			delete(a.days, date)
+		}
+	}
+	a.mu.Unlock()
+
+	dates := make([]string, 0, len(ready))
+	for date := range ready {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	var failed error
+	for _, date := range dates {
+		err := a.upload(ctx, a.summarize(date, ready[date], date >= today))
+		// Handle errors while uploading; keep the day for the next export
+		if err != nil {
+			failed = err
+			a.mu.Lock()
+			a.restoreLocked(date, ready[date])
+			a.mu.Unlock()
+		}
+	}
+	return failed
+}
+
+// Run exports ended days every interval until ctx is done, then exports everything left
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Upload what is left; ctx is already canceled
+			if err := a.Export(context.WithoutCancel(ctx), true); err != nil {
+				a.logger.Error("could not export analytics", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := a.Export(ctx, false); err != nil {
+				a.logger.Error("could not export analytics", "error", err)
+			}
+		}
+	}
+}
+
+// Function to return the Summary of the counts d of date
+func (a *Aggregator) summarize(date string, d *day, partial bool) Summary {
+	top := make([]JokeCount, 0, len(d.jokes))
+	for joke, n := range d.jokes {
+		top = append(top, JokeCount{Joke: joke, Serves: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Serves != top[j].Serves {
+			return top[i].Serves > top[j].Serves
+		}
+		return top[i].Joke < top[j].Joke
+	})
+	if len(top) > topJokes {
+		top = top[:topJokes]
+	}
+	return Summary{
+		Date:       date,
+		Instance:   a.instance,
+		Partial:    partial,
+		Serves:     d.serves,
+		Anonymous:  d.anonymous,
+		Users:      len(d.users),
+		Categories: d.categories,
+		Tenants:    d.tenants,
+		TopJokes:   top,
+	}
+}
+
+/*
+	 Function to upload s as a JSON object
+
+		Objects are named <prefix>/date=<yyyy-mm-dd>/<instance>.json,
+		a layout most query engines read as a partitioned table.
+*/
+func (a *Aggregator) upload(ctx context.Context, s Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("analytics: could not encode summary: %w", err)
+	}
+	key := "date=" + s.Date + "/" + a.instance + ".json"
+	if a.prefix != "" {
+		key = a.prefix + "/" + key
+	}
+	if err := a.uploader.Put(ctx, key, "application/json", body); err != nil {
+		return fmt.Errorf("analytics: could not upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Function to add the counts d of date back, merging with any added since
+func (a *Aggregator) restoreLocked(date string, d *day) {
+	cur, ok := a.days[date]
+	if !ok {
+		a.days[date] = d
+		return
+	}
+	cur.serves += d.serves
+	cur.anonymous += d.anonymous
+	for user := range d.users {
+		cur.users[user] = true
+	}
+	for k, n := range d.categories {
+		cur.categories[k] += n
+	}
+	for k, n := range d.tenants {
+		cur.tenants[k] += n
+	}
+	for k, n := range d.jokes {
+		cur.jokes[k] += n
+	}
+}
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+// struct to hold the objects uploaded to it by key
+type fakeUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    error
+}
+
+func (u *fakeUploader) Put(ctx context.Context, key, contentType string, body []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.fail != nil {
+		return u.fail
+	}
+	if contentType != "application/json" {
+		return errors.New("unexpected content type " + contentType)
+	}
+	u.objects[key] = body
+	return nil
+}
+
+// Function to return the summary uploaded under key
+func (u *fakeUploader) summary(t *testing.T, key string) Summary {
+	t.Helper()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	body, ok := u.objects[key]
+	if !ok {
+		t.Fatalf("Expected an object at %s; got keys %v", key, u.keys())
+	}
+	var s Summary
+	if err := json.Unmarshal(body, &s); err != nil {
+		t.Fatalf("Expected a JSON summary; got %v", err)
+	}
+	return s
+}
+
+// Function to return the uploaded keys
+func (u *fakeUploader) keys() []string {
+	var keys []string
+	for k := range u.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestAggregator(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2024, time.January, 1, 23, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(2 * time.Hour)
+
+	// Function to return an aggregator at tuesday uploading to a fake
+	newAggregator := func() (*Aggregator, *fakeUploader) {
+		u := &fakeUploader{objects: make(map[string][]byte)}
+		a := New(u, "/usage/", "web-1", nil)
+		a.now = func() time.Time { return tuesday }
+		return a, u
+	}
+
+	t.Run("Uploads a summary of each ended day", func(t *testing.T) {
+		a, u := newAggregator()
+		for _, e := range []history.Entry{
+			{Joke: "a", Category: "nerdy", User: "alice", ServedAt: monday},
+			{Joke: "a", Category: "nerdy", User: "alice", ServedAt: monday},
+			{Joke: "b", Category: "explicit", User: "bob", Tenant: "acme", ServedAt: monday},
+			{Joke: "c", ServedAt: monday},
+			{Joke: "d", ServedAt: tuesday},
+		} {
+			a.Add(e)
+		}
+		if err := a.Export(context.Background(), false); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		s := u.summary(t, "usage/date=2024-01-01/web-1.json")
+		if s.Date != "2024-01-01" || s.Instance != "web-1" || s.Partial {
+			t.Errorf("Expected a complete summary of 2024-01-01 from web-1; got %+v", s)
+		}
+		if s.Serves != 4 || s.Anonymous != 1 || s.Users != 2 {
+			t.Errorf("Expected 4 serves, 1 anonymous and 2 users; got %+v", s)
+		}
+		if s.Categories["nerdy"] != 2 || s.Tenants["acme"] != 1 || s.Tenants[""] != 3 {
+			t.Errorf("Expected counts by category and tenant; got %+v", s)
+		}
+		if len(s.TopJokes) != 3 || s.TopJokes[0] != (JokeCount{Joke: "a", Serves: 2}) {
+			t.Errorf("Expected the most served joke first; got %+v", s.TopJokes)
+		}
+		if len(u.keys()) != 1 {
+			t.Errorf("Expected today to wait until it ends; got keys %v", u.keys())
+		}
+	})
+
+	t.Run("Uploads today as partial when final", func(t *testing.T) {
+		a, u := newAggregator()
+		a.Add(history.Entry{Joke: "d", ServedAt: tuesday})
+		if err := a.Export(context.Background(), true); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if s := u.summary(t, "usage/date=2024-01-02/web-1.json"); !s.Partial || s.Serves != 1 {
+			t.Errorf("Expected a partial summary of one serve; got %+v", s)
+		}
+	})
+
+	t.Run("Keeps days that fail to upload", func(t *testing.T) {
+		a, u := newAggregator()
+		u.fail = errors.New("bucket down")
+		a.Add(history.Entry{Joke: "a", ServedAt: monday})
+		if err := a.Export(context.Background(), false); err == nil {
+			t.Fatal("Expected an error from the uploader; got nil")
+		}
+
+		a.Add(history.Entry{Joke: "a", ServedAt: monday})
+		u.fail = nil
+		if err := a.Export(context.Background(), false); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if s := u.summary(t, "usage/date=2024-01-01/web-1.json"); s.Serves != 2 {
+			t.Errorf("Expected the retried day to hold both serves; got %+v", s)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Events buffered before more are dropped, and most sent to a sink at once
+const (
+	eventBuffer = 1000
+	maxBatch    = 500
+)
+
+// Type of an analytics event
+type EventType string
+
+// Types of event emitted. Jokes are not rated, so there are no rating events.
+const (
+	// EventJokeServed is a joke served to a visitor
+	EventJokeServed EventType = "joke_served"
+	// EventError is a failed call to an upstream service
+	EventError EventType = "error"
+)
+
+// Event is something that happened, for the data team to analyze
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Joke     string    `json:"joke,omitempty"`
+	Category string    `json:"category,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	// Upstream and Error describe EventError
+	Upstream string `json:"upstream,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EventSink receives batches of events
+type EventSink interface {
+	Write(ctx context.Context, events []Event) error
+}
+
+// EventSinkFunc adapts a function to the EventSink interface
+type EventSinkFunc func(ctx context.Context, events []Event) error
+
+// Write calls f
+func (f EventSinkFunc) Write(ctx context.Context, events []Event) error {
+	return f(ctx, events)
+}
+
+/*
+	 Events buffers emitted events and hands them to a sink in batches
+
+		Emitting never blocks: when the sink falls behind and the
+		buffer fills, events are dropped. Build one with NewEvents and
+		start it with Run.
+*/
+type Events struct {
+	sink   EventSink
+	logger *slog.Logger
+	ch     chan Event
+}
+
+// NewEvents returns Events writing to sink
+func NewEvents(sink EventSink, logger *slog.Logger) *Events {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Events{sink: sink, logger: logger, ch: make(chan Event, eventBuffer)}
+}
+
+// Emit queues ev for the sink, timestamping it when it has no Time
+func (e *Events) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	select {
+	case e.ch <- ev:
+	default:
+		e.logger.Warn("dropped analytics event, the sink is falling behind", "type", ev.Type)
+	}
+}
+
+// Served emits EventJokeServed for a history entry, to register with history.Store.OnAdd
+func (e *Events) Served(h history.Entry) {
+	e.Emit(Event{
+		Type:     EventJokeServed,
+		Time:     h.ServedAt,
+		Joke:     h.Joke,
+		Category: h.Category,
+		User:     h.User,
+		Tenant:   h.Tenant,
+	})
+}
+
+// Names returns p wrapped so every failed call emits EventError under upstream
+func (e *Events) Names(upstream string, p joke.NameProvider) joke.NameProvider {
+	return joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		n, err := p.Name(ctx)
+		e.failed(upstream, err)
+		return n, err
+	})
+}
+
+// Jokes returns p wrapped so every failed call emits EventError under upstream
+func (e *Events) Jokes(upstream string, p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		text, err := p.Joke(ctx, firstName, lastName)
+		e.failed(upstream, err)
+		return text, err
+	})
+}
+
+// Function to emit EventError for err, unless it is nil
+func (e *Events) failed(upstream string, err error) {
+	if err != nil {
+		e.Emit(Event{Type: EventError, Upstream: upstream, Error: err.Error()})
+	}
+}
+
+/*
+	 Run writes buffered events to the sink every interval, or sooner
+	 once a batch is full, until ctx is done, then writes what is left
+
+		Events are best effort: a batch the sink fails to take is
+		logged and dropped rather than retried.
+*/
+func (e *Events) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, maxBatch)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.sink.Write(ctx, batch); err != nil {
+			e.logger.Error("could not write analytics events", "events", len(batch), "error", err)
+		}
+		// Start a new batch; the sink may keep the old one
+		batch = make([]Event, 0, maxBatch)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// Write what is left; ctx is already canceled
+			for {
+				select {
+				case ev := <-e.ch:
+					batch = append(batch, ev)
+					if len(batch) == maxBatch {
+						flush(context.WithoutCancel(ctx))
+					}
+					continue
+				default:
+				}
+				break
+			}
+			flush(context.WithoutCancel(ctx))
+			return
+		case ev := <-e.ch:
+			batch = append(batch, ev)
+			if len(batch) == maxBatch {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	// Function to return events whose batches are captured in got, guarded by mu
+	newEvents := func() (*Events, *[]Event, *sync.Mutex) {
+		var (
+			got []Event
+			mu  sync.Mutex
+		)
+		e := NewEvents(EventSinkFunc(func(ctx context.Context, events []Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, events...)
+			return nil
+		}), nil)
+		return e, &got, &mu
+	}
+
+	t.Run("Writes served jokes and upstream errors on shutdown", func(t *testing.T) {
+		e, got, mu := newEvents()
+		servedAt := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+		e.Served(history.Entry{Joke: "a", Category: "nerdy", User: "alice", ServedAt: servedAt})
+		jokes := e.Jokes("joke", joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			return "", errors.New("upstream down")
+		}))
+		jokes.Joke(context.Background(), "Chuck", "Norris")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		e.Run(ctx, time.Hour)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(*got) != 2 {
+			t.Fatalf("Expected 2 events; got %+v", *got)
+		}
+		if ev := (*got)[0]; ev.Type != EventJokeServed || ev.Joke != "a" || ev.User != "alice" || !ev.Time.Equal(servedAt) {
+			t.Errorf("Expected the served joke; got %+v", ev)
+		}
+		if ev := (*got)[1]; ev.Type != EventError || ev.Upstream != "joke" || ev.Error != "upstream down" || ev.Time.IsZero() {
+			t.Errorf("Expected the upstream error; got %+v", ev)
+		}
+	})
+
+	t.Run("Emits nothing for successful calls", func(t *testing.T) {
+		e, got, mu := newEvents()
+		names := e.Names("name", joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+			return joke.Names{}, nil
+		}))
+		names.Name(context.Background())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		e.Run(ctx, time.Hour)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(*got) != 0 {
+			t.Errorf("Expected no events; got %+v", *got)
+		}
+	})
+
+	t.Run("Drops events once the buffer is full", func(t *testing.T) {
+		e, _, _ := newEvents()
+		for i := 0; i < eventBuffer+10; i++ {
+			e.Emit(Event{Type: EventJokeServed})
+		}
+		if len(e.ch) != eventBuffer {
+			t.Errorf("Expected %d buffered events; got %d", eventBuffer, len(e.ch))
+		}
+	})
+}
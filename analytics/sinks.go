@@ -0,0 +1,243 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Base URL of the BigQuery API, and where tokens come from on Google Cloud
+const (
+	defaultBigQueryEndpoint = "https://bigquery.googleapis.com/bigquery/v2"
+	defaultMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+/*
+	 NewEventSink returns the EventSink described by dest
+
+		"stdout" writes JSON lines to standard output, http:// and
+		https:// URLs receive a POST of a JSON array, and
+		bigquery://project/dataset/table streams rows into a table.
+*/
+func NewEventSink(dest string) (EventSink, error) {
+	if dest == "stdout" {
+		return &WriterSink{W: os.Stdout}, nil
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: invalid event sink %q: %w", dest, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &WebhookSink{URL: dest}, nil
+	case "bigquery":
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if u.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("analytics: invalid event sink %q: want bigquery://project/dataset/table", dest)
+		}
+		return &BigQuerySink{Project: u.Host, Dataset: parts[0], Table: parts[1]}, nil
+	}
+	return nil, fmt.Errorf("analytics: invalid event sink %q: want stdout or an http, https or bigquery URL", dest)
+}
+
+// WriterSink writes events to W as JSON lines
+type WriterSink struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Write writes one line per event
+func (s *WriterSink) Write(ctx context.Context, events []Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range events {
+		// Events always encode; they hold only strings and times
+		enc.Encode(ev)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.W.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("analytics: could not write events: %w", err)
+	}
+	return nil
+}
+
+// WebhookSink POSTs events to URL as a JSON array
+type WebhookSink struct {
+	URL string
+	// Client sends the requests, defaulting to a client with a 30s timeout
+	Client *http.Client
+}
+
+// Write POSTs events, failing unless the endpoint answers 2xx
+func (s *WebhookSink) Write(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("analytics: could not encode events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("analytics: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client(s.Client).Do(req)
+	if err != nil {
+		return fmt.Errorf("analytics: could not post events: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("analytics: could not post events: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+/*
+	 BigQuerySink streams events into a BigQuery table with insertAll
+
+		The table needs a column for each field of Event, with type and
+		time as STRING and TIMESTAMP. Access tokens come from the
+		Google Cloud metadata server, so the sink works on GCE, GKE and
+		Cloud Run with the service account attached to the workload.
+*/
+type BigQuerySink struct {
+	Project string
+	Dataset string
+	Table   string
+	// Client sends the requests, defaulting to a client with a 30s timeout
+	Client *http.Client
+
+	// Overridden in tests
+	endpoint string
+	tokenURL string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// struct to hold the parts of an insertAll response used
+type insertAllResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// Write inserts one row per event, failing if any row is refused
+func (s *BigQuerySink) Write(ctx context.Context, events []Event) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		JSON Event `json:"json"`
+	}
+	rows := make([]row, len(events))
+	for i, ev := range events {
+		rows[i] = row{ev}
+	}
+	body, err := json.Marshal(map[string]any{"rows": rows})
+	if err != nil {
+		return fmt.Errorf("analytics: could not encode events: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", orDefault(s.endpoint, defaultBigQueryEndpoint),
+		url.PathEscape(s.Project), url.PathEscape(s.Dataset), url.PathEscape(s.Table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("analytics: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	var res insertAllResponse
+	if err := s.do(req, &res); err != nil {
+		return err
+	}
+	// Rows are inserted or refused one by one; report the first refusal
+	if n := len(res.InsertErrors); n > 0 {
+		first := res.InsertErrors[0]
+		reason := "unknown"
+		if len(first.Errors) > 0 {
+			reason = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("analytics: BigQuery refused %d of %d rows, row %d: %s", n, len(events), first.Index, reason)
+	}
+	return nil
+}
+
+// Function to return an access token from the metadata server, fetching a new one when it is about to expire
+func (s *BigQuerySink) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, orDefault(s.tokenURL, defaultMetadataTokenURL), nil)
+	if err != nil {
+		return "", fmt.Errorf("analytics: could not build request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := s.do(req, &res); err != nil {
+		return "", err
+	}
+	s.token = res.AccessToken
+	// Renew a minute early, so a token doesn't expire mid-request
+	s.expires = time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+// Function to send req and decode its JSON response into v
+func (s *BigQuerySink) do(req *http.Request, v any) error {
+	res, err := client(s.Client).Do(req)
+	if err != nil {
+		return fmt.Errorf("analytics: could not call %s: %w", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("analytics: could not read response from %s: %w", req.URL.Host, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("analytics: %s %s: status %d: %s", req.Method, req.URL.Path, res.StatusCode, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("analytics: could not parse response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+// Client used by sinks without one, timing out so a hung endpoint can't stall writes
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Function to return c, or a client with a 30s timeout when c is nil
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return defaultClient
+	}
+	return c
+}
+
+// Function to return s, or def when s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Events written by the sink tests
+var testEvents = []Event{
+	{Type: EventJokeServed, Time: time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC), Joke: "a", Category: "nerdy"},
+	{Type: EventError, Time: time.Date(2024, time.January, 31, 12, 0, 1, 0, time.UTC), Upstream: "joke", Error: "timeout"},
+}
+
+func TestNewEventSink(t *testing.T) {
+	t.Parallel()
+
+	for dest, want := range map[string]string{
+		"stdout":                       "*analytics.WriterSink",
+		"https://events.example":       "*analytics.WebhookSink",
+		"bigquery://proj/jokes/events": "*analytics.BigQuerySink",
+	} {
+		sink, err := NewEventSink(dest)
+		if err != nil {
+			t.Errorf("%s: expected no error; got %v", dest, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", sink); got != want {
+			t.Errorf("%s: expected %s; got %s", dest, want, got)
+		}
+	}
+	for _, dest := range []string{"ftp://example", "bigquery://proj/jokes", "bigquery:///jokes/events"} {
+		if _, err := NewEventSink(dest); err == nil {
+			t.Errorf("%s: expected an error; got nil", dest)
+		}
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := (&WriterSink{W: &buf}).Write(context.Background(), testEvents); err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines; got %q", buf.String())
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil || ev.Type != EventError || ev.Upstream != "joke" {
+		t.Errorf("Expected the error event; got %+v (%v)", ev, err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Posts a JSON array", func(t *testing.T) {
+		var got []Event
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+		}))
+		defer ts.Close()
+
+		if err := (&WebhookSink{URL: ts.URL}).Write(context.Background(), testEvents); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if len(got) != 2 || got[0].Joke != "a" {
+			t.Errorf("Expected both events; got %+v", got)
+		}
+	})
+
+	t.Run("Fails on a non-2xx status", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer ts.Close()
+
+		if err := (&WebhookSink{URL: ts.URL}).Write(context.Background(), testEvents); err == nil {
+			t.Error("Expected an error; got nil")
+		}
+	})
+}
+
+func TestBigQuerySink(t *testing.T) {
+	t.Parallel()
+
+	// Function to return a sink against a fake metadata server and BigQuery answering insertAll with reply
+	newSink := func(t *testing.T, reply string, tokens *int, rows *int) *BigQuerySink {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			*tokens++
+			fmt.Fprint(w, `{"access_token":"tok","expires_in":3600}`)
+		})
+		mux.HandleFunc("POST /projects/proj/datasets/jokes/tables/events/insertAll", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var body struct {
+				Rows []struct {
+					JSON Event `json:"json"`
+				} `json:"rows"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			*rows += len(body.Rows)
+			fmt.Fprint(w, reply)
+		})
+		ts := httptest.NewServer(mux)
+		t.Cleanup(ts.Close)
+		return &BigQuerySink{Project: "proj", Dataset: "jokes", Table: "events", endpoint: ts.URL, tokenURL: ts.URL + "/token"}
+	}
+
+	t.Run("Inserts a row per event, reusing the token", func(t *testing.T) {
+		var tokens, rows int
+		sink := newSink(t, `{"kind":"bigquery#tableDataInsertAllResponse"}`, &tokens, &rows)
+		for i := 0; i < 2; i++ {
+			if err := sink.Write(context.Background(), testEvents); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+		}
+		if rows != 4 || tokens != 1 {
+			t.Errorf("Expected 4 rows with 1 token; got %d rows with %d tokens", rows, tokens)
+		}
+	})
+
+	t.Run("Fails when rows are refused", func(t *testing.T) {
+		var tokens, rows int
+		sink := newSink(t, `{"insertErrors":[{"index":1,"errors":[{"reason":"invalid","message":"no such field"}]}]}`, &tokens, &rows)
+		err := sink.Write(context.Background(), testEvents)
+		if err == nil || !strings.Contains(err.Error(), "no such field") {
+			t.Errorf("Expected the refusal reason; got %v", err)
+		}
+	})
+}
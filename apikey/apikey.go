@@ -0,0 +1,310 @@
+/*
+	 Package apikey manages API keys with scopes, expirations and
+	 usage counts.
+
+		Keys are held in memory for fast checks and, when the Store
+		has a path, saved to a JSON file. Only a SHA-256 hash of each
+		secret is kept; the secret itself is shown once, on creation.
+*/
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/atomicfile"
+	"github.com/jswanson806/joke-generator/token"
+)
+
+// Scopes a key can be granted
+const (
+	// ScopeJokes allows fetching jokes and history
+	ScopeJokes = "jokes"
+	// ScopeAdmin allows calling the /admin routes
+	ScopeAdmin = "admin"
+)
+
+// Every scope a key may hold
+var Scopes = []string{ScopeJokes, ScopeAdmin}
+
+// Prefix of every secret, so leaked keys are easy to recognize
+const secretPrefix = "jk_"
+
+// Errors returned by Store
+var (
+	ErrNotFound     = errors.New("apikey: key not found")
+	ErrUnknownScope = errors.New("apikey: unknown scope")
+)
+
+// Key describes an API key; it never holds the secret
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is nil for keys that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Uses      uint64     `json:"uses"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	Quota     Quota      `json:"quota"`
+	Usage     Usage      `json:"usage"`
+}
+
+// Active reports whether k can be used at now
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// struct to hold a key and its secret's hash as saved to the file
+type record struct {
+	Key
+	Hash    string   `json:"hash"`
+	Rollups []Rollup `json:"rollups,omitempty"`
+}
+
+/*
+	 Store holds API keys
+
+		Safe for concurrent use. Build one with Open.
+*/
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu     sync.Mutex
+	byHash map[string]*record
+	byID   map[string]*record
+	dirty  bool
+}
+
+/*
+	 Open returns a Store saved to the JSON file at path, loading the
+	 keys already in it
+
+		A missing file is an empty store. An empty path keeps keys in
+		memory only.
+*/
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		now:    time.Now,
+		byHash: make(map[string]*record),
+		byID:   make(map[string]*record),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apikey: could not read %s: %w", path, err)
+	}
+	var records []*record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("apikey: could not parse %s: %w", path, err)
+	}
+	for _, r := range records {
+		s.byHash[r.Hash] = r
+		s.byID[r.ID] = r
+	}
+	return s, nil
+}
+
+/*
+	 Create adds a key named name with scopes and quota, expiring at
+	 expiresAt unless it is zero, and returns it with its secret
+
+		Without scopes the key gets ScopeJokes. The key is saved
+		with its quota, so a failed save never leaves it unlimited.
+*/
+func (s *Store) Create(name string, scopes []string, expiresAt time.Time, quota Quota) (Key, string, error) {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeJokes}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return Key{}, "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+
+	secret := secretPrefix + token.String(32)
+	r := &record{
+		Key: Key{
+			ID:        randomHex(8),
+			Name:      name,
+			Scopes:    slices.Clone(scopes),
+			CreatedAt: s.now().UTC(),
+			Quota:     quota,
+			Usage:     Usage{Day: startOfDay(s.now()), Month: startOfMonth(s.now())},
+		},
+		Hash: hash(secret),
+	}
+	if !expiresAt.IsZero() {
+		r.ExpiresAt = ptr(expiresAt.UTC())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[r.Hash] = r
+	s.byID[r.ID] = r
+	if err := s.saveLocked(); err != nil {
+		delete(s.byHash, r.Hash)
+		delete(s.byID, r.ID)
+		return Key{}, "", err
+	}
+	return r.Key, secret, nil
+}
+
+// List returns every key, newest first
+func (s *Store) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]Key, 0, len(s.byID))
+	for _, r := range s.byID {
+		k := r.Key
+		k.Scopes = slices.Clone(r.Scopes)
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Revoke stops the key with id from being used; it stays listed
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byID[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	if r.RevokedAt == nil {
+		r.RevokedAt = ptr(s.now().UTC())
+		if err := s.saveLocked(); err != nil {
+			r.RevokedAt = nil
+			return Key{}, err
+		}
+	}
+	return r.Key, nil
+}
+
+/*
+	 Authenticate returns the active key with secret, if it holds
+	 scope
+
+		An empty scope accepts any active key. Usage is not recorded;
+		see Allow.
+*/
+func (s *Store) Authenticate(secret, scope string) (Key, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.lookupLocked(secret, scope)
+	if !ok {
+		return Key{}, false
+	}
+	return r.Key, true
+}
+
+/*
+	 Validator returns a function, for middleware.APIKey, accepting
+	 active keys that hold scope
+
+		Use is recorded by Allow, once the request is within quota.
+*/
+func (s *Store) Validator(scope string) func(secret string) bool {
+	return func(secret string) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, ok := s.lookupLocked(secret, scope)
+		return ok
+	}
+}
+
+// Flush saves usage recorded since the last save
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Function to find the active key with secret holding scope
+func (s *Store) lookupLocked(secret, scope string) (*record, bool) {
+	r, ok := s.byHash[hash(secret)]
+	if !ok || !r.Active(s.now()) {
+		return nil, false
+	}
+	if scope != "" && !slices.Contains(r.Scopes, scope) {
+		return nil, false
+	}
+	return r, true
+}
+
+// Function to write every key to the file, when the store has one
+func (s *Store) saveLocked() error {
+	s.dirty = false
+	if s.path == "" {
+		return nil
+	}
+	records := make([]*record, 0, len(s.byID))
+	for _, r := range s.byID {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("apikey: could not save keys: %w", err)
+	}
+	return nil
+}
+
+// Function to return a pointer to t
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+/*
+	 Fingerprint returns a short identifier for secret that is safe to
+	 log or export
+
+		Useful for keys held outside the Store, which have no ID.
+*/
+func Fingerprint(secret string) string {
+	return "sha256:" + hash(secret)[:12]
+}
+
+// Function to hash a secret for lookup and storage
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Function to return n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
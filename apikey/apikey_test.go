@@ -0,0 +1,158 @@
+package apikey
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Creates and authenticates keys", func(t *testing.T) {
+		s, _ := Open("")
+		key, secret, err := s.Create("ci", nil, time.Time{}, Quota{})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if !strings.HasPrefix(secret, "jk_") || key.ID == "" || key.ExpiresAt != nil {
+			t.Errorf("Unexpected key %+v with secret %q", key, secret)
+		}
+
+		if got, ok := s.Authenticate(secret, ScopeJokes); !ok || got.ID != key.ID {
+			t.Errorf("Expected key to authenticate with default scope; got %+v, %v", got, ok)
+		}
+		if _, ok := s.Authenticate(secret, ScopeAdmin); ok {
+			t.Error("Expected key without admin scope to be refused admin")
+		}
+		if _, ok := s.Authenticate(secret+"x", ""); ok {
+			t.Error("Expected wrong secret to be refused")
+		}
+	})
+
+	t.Run("Rejects unknown scopes", func(t *testing.T) {
+		s, _ := Open("")
+		if _, _, err := s.Create("bad", []string{"root"}, time.Time{}, Quota{}); !errors.Is(err, ErrUnknownScope) {
+			t.Errorf("Expected ErrUnknownScope; got %v", err)
+		}
+	})
+
+	t.Run("Expires keys", func(t *testing.T) {
+		s, _ := Open("")
+		now := time.Now()
+		s.now = func() time.Time { return now }
+		_, secret, _ := s.Create("temp", nil, now.Add(time.Hour), Quota{})
+
+		if _, ok := s.Authenticate(secret, ""); !ok {
+			t.Error("Expected key to work before it expires")
+		}
+		now = now.Add(2 * time.Hour)
+		if _, ok := s.Authenticate(secret, ""); ok {
+			t.Error("Expected key to be refused once expired")
+		}
+	})
+
+	t.Run("Revokes keys", func(t *testing.T) {
+		s, _ := Open("")
+		key, secret, _ := s.Create("leaked", nil, time.Time{}, Quota{})
+
+		revoked, err := s.Revoke(key.ID)
+		if err != nil || revoked.RevokedAt == nil {
+			t.Fatalf("Expected key to be revoked; got %+v, %v", revoked, err)
+		}
+		if _, ok := s.Authenticate(secret, ""); ok {
+			t.Error("Expected revoked key to be refused")
+		}
+		if _, err := s.Revoke("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound; got %v", err)
+		}
+		if keys := s.List(); len(keys) != 1 || keys[0].RevokedAt == nil {
+			t.Errorf("Expected revoked key to stay listed; got %+v", keys)
+		}
+	})
+
+	t.Run("Allow records usage", func(t *testing.T) {
+		s, _ := Open("")
+		_, secret, _ := s.Create("app", []string{ScopeJokes, ScopeAdmin}, time.Time{}, Quota{})
+
+		s.Allow(secret)
+		s.Allow(secret)
+		s.Allow("jk_unknown")
+
+		keys := s.List()
+		if keys[0].Uses != 2 || keys[0].LastUsed == nil {
+			t.Errorf("Expected 2 recorded uses; got %+v", keys[0])
+		}
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening missing file; got %v", err)
+	}
+	key, secret, _ := s.Create("ci", []string{ScopeAdmin}, time.Time{}, Quota{Daily: 5})
+	s.Allow(secret)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	// The file never holds the secret
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), secret) {
+		t.Error("Expected secret not to be saved")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	got, ok := reopened.Authenticate(secret, ScopeAdmin)
+	if !ok || got.ID != key.ID || got.Uses != 1 || got.Quota.Daily != 5 {
+		t.Errorf("Expected saved key with 1 use and its quota; got %+v, %v", got, ok)
+	}
+
+	// A corrupt file is an error, not an empty store
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := Open(path); err == nil {
+		t.Error("Expected error for corrupt file; got nil")
+	}
+}
+
+func TestCreateSaveFailure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "keys", "keys.json"))
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	// The file's directory doesn't exist, so saving fails
+	if _, _, err := s.Create("ci", nil, time.Time{}, Quota{Daily: 5}); err == nil {
+		t.Fatal("Expected an error saving the key")
+	}
+	if keys := s.List(); len(keys) != 0 {
+		t.Errorf("Expected no key left behind; got %+v", keys)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	a, b := Fingerprint("static-key"), Fingerprint("other-key")
+	if !strings.HasPrefix(a, "sha256:") || len(a) != len("sha256:")+12 {
+		t.Errorf("Unexpected fingerprint %q", a)
+	}
+	if a == b || a != Fingerprint("static-key") {
+		t.Errorf("Expected stable, distinct fingerprints; got %q and %q", a, b)
+	}
+	if strings.Contains(a, "static-key") {
+		t.Error("Expected fingerprint to hide the secret")
+	}
+}
@@ -0,0 +1,189 @@
+package apikey
+
+import (
+	"slices"
+	"time"
+)
+
+// Periods usage is rolled up into
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Number of rollups kept per key and period; older ones are dropped
+const (
+	keepDays   = 90
+	keepMonths = 24
+)
+
+// Quota caps how many requests a key may make; zero is unlimited
+type Quota struct {
+	Daily   uint64 `json:"daily,omitempty"`
+	Monthly uint64 `json:"monthly,omitempty"`
+}
+
+/*
+	 Usage counts requests made in the current day and month
+
+		Periods are UTC calendar days and months. Counts reset when a
+		period ends and are kept as Rollups.
+*/
+type Usage struct {
+	Day       time.Time `json:"day"`
+	DayUses   uint64    `json:"day_uses"`
+	Month     time.Time `json:"month"`
+	MonthUses uint64    `json:"month_uses"`
+}
+
+// Rollup is the number of requests a key made in one finished period
+type Rollup struct {
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	Uses   uint64    `json:"uses"`
+}
+
+// Stats is a key's current usage and its finished periods, oldest first
+type Stats struct {
+	Key     Key      `json:"key"`
+	Rollups []Rollup `json:"rollups"`
+}
+
+// SetQuota sets the quota of the key with id
+func (s *Store) SetQuota(id string, q Quota) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byID[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	old := r.Quota
+	r.Quota = q
+	if err := s.saveLocked(); err != nil {
+		r.Quota = old
+		return Key{}, err
+	}
+	return r.Key, nil
+}
+
+/*
+	 Allow reports whether the key with secret is within its quota,
+	 and when its usage next resets, recording the use when it is
+
+		Requests refused for quota aren't counted, so a key's usage
+		never passes its quota. Usage is kept in memory and saved by
+		Flush. Secrets the store does not hold are always allowed, so
+		static keys are unaffected.
+*/
+func (s *Store) Allow(secret string) (bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byHash[hash(secret)]
+	if !ok {
+		return true, time.Time{}
+	}
+	now := s.now()
+	s.rollLocked(r, now)
+
+	if r.Quota.Daily > 0 && r.Usage.DayUses >= r.Quota.Daily {
+		return false, r.Usage.Day.AddDate(0, 0, 1)
+	}
+	if r.Quota.Monthly > 0 && r.Usage.MonthUses >= r.Quota.Monthly {
+		return false, r.Usage.Month.AddDate(0, 1, 0)
+	}
+	r.Uses++
+	r.Usage.DayUses++
+	r.Usage.MonthUses++
+	r.LastUsed = ptr(now.UTC())
+	s.dirty = true
+	return true, time.Time{}
+}
+
+// Stats returns the usage of the key with id, optionally only for period
+func (s *Store) Stats(id, period string) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byID[id]
+	if !ok {
+		return Stats{}, ErrNotFound
+	}
+	s.rollLocked(r, s.now())
+
+	stats := Stats{Key: r.Key, Rollups: []Rollup{}}
+	stats.Key.Scopes = slices.Clone(r.Scopes)
+	for _, ru := range r.Rollups {
+		if period == "" || ru.Period == period {
+			stats.Rollups = append(stats.Rollups, ru)
+		}
+	}
+	return stats, nil
+}
+
+/*
+	 Roll closes the periods that have ended for every key, storing
+	 their rollups and resetting quotas
+
+		Keys in use are also rolled as requests arrive; Roll catches
+		up idle keys so their rollups appear on schedule. Changes are
+		saved by the next Flush.
+*/
+func (s *Store) Roll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, r := range s.byID {
+		s.rollLocked(r, now)
+	}
+}
+
+// Function to roll r's usage into rollups when its day or month has ended
+func (s *Store) rollLocked(r *record, now time.Time) {
+	day, month := startOfDay(now), startOfMonth(now)
+
+	if !r.Usage.Day.Equal(day) {
+		if r.Usage.DayUses > 0 {
+			r.Rollups = appendRollup(r.Rollups, Rollup{Period: PeriodDay, Start: r.Usage.Day, Uses: r.Usage.DayUses}, keepDays)
+		}
+		r.Usage.Day, r.Usage.DayUses = day, 0
+		s.dirty = true
+	}
+	if !r.Usage.Month.Equal(month) {
+		if r.Usage.MonthUses > 0 {
+			r.Rollups = appendRollup(r.Rollups, Rollup{Period: PeriodMonth, Start: r.Usage.Month, Uses: r.Usage.MonthUses}, keepMonths)
+		}
+		r.Usage.Month, r.Usage.MonthUses = month, 0
+		s.dirty = true
+	}
+}
+
+// Function to append ru, dropping the oldest rollups of its period past keep
+func appendRollup(rollups []Rollup, ru Rollup, keep int) []Rollup {
+	rollups = append(rollups, ru)
+	n := 0
+	for _, r := range rollups {
+		if r.Period == ru.Period {
+			n++
+		}
+	}
+	for i := 0; n > keep && i < len(rollups); {
+		if rollups[i].Period == ru.Period {
+			rollups = slices.Delete(rollups, i, i+1)
+			n--
+			continue
+		}
+		i++
+	}
+	return rollups
+}
+
+// Function to return the start of t's UTC day
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Function to return the start of t's UTC month
+func startOfMonth(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
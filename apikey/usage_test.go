@@ -0,0 +1,129 @@
+package apikey
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+
+	// Function to return a store at a fixed time that tests can move
+	newStore := func(t *testing.T) (*Store, *time.Time) {
+		t.Helper()
+		s, _ := Open("")
+		now := time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)
+		s.now = func() time.Time { return now }
+		return s, &now
+	}
+
+	t.Run("Enforces daily quota until the day ends", func(t *testing.T) {
+		s, now := newStore(t)
+		key, secret, _ := s.Create("ci", nil, time.Time{}, Quota{})
+		s.SetQuota(key.ID, Quota{Daily: 2})
+
+		for i, want := range []bool{true, true, false, false} {
+			if ok, _ := s.Allow(secret); ok != want {
+				t.Errorf("Request %d: expected allowed %v; got %v", i+1, want, ok)
+			}
+		}
+		if _, reset := s.Allow(secret); !reset.Equal(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected quota to reset at midnight; got %v", reset)
+		}
+		// Refused requests aren't counted
+		if stats, _ := s.Stats(key.ID, ""); stats.Key.Usage.DayUses != 2 || stats.Key.Uses != 2 {
+			t.Errorf("Expected only the 2 allowed requests counted; got %+v", stats.Key)
+		}
+
+		*now = now.Add(2 * time.Hour)
+		if ok, _ := s.Allow(secret); !ok {
+			t.Error("Expected quota to reset the next day")
+		}
+	})
+
+	t.Run("Enforces monthly quota", func(t *testing.T) {
+		s, _ := newStore(t)
+		key, secret, _ := s.Create("ci", nil, time.Time{}, Quota{})
+		s.SetQuota(key.ID, Quota{Monthly: 1})
+
+		s.Allow(secret)
+		if ok, reset := s.Allow(secret); ok || !reset.Equal(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected key over quota until February; got %v, %v", ok, reset)
+		}
+	})
+
+	t.Run("Allows unknown keys", func(t *testing.T) {
+		s, _ := newStore(t)
+		if ok, _ := s.Allow("static"); !ok {
+			t.Error("Expected keys outside the store to be allowed")
+		}
+	})
+
+	t.Run("Rolls up finished periods", func(t *testing.T) {
+		s, now := newStore(t)
+		key, secret, _ := s.Create("ci", nil, time.Time{}, Quota{})
+		s.Allow(secret)
+		s.Allow(secret)
+
+		// Crossing midnight into February closes a day and a month
+		*now = now.Add(2 * time.Hour)
+		s.Roll()
+
+		stats, err := s.Stats(key.ID, "")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		jan31 := time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)
+		jan := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		if len(stats.Rollups) != 2 ||
+			stats.Rollups[0] != (Rollup{Period: PeriodDay, Start: jan31, Uses: 2}) ||
+			stats.Rollups[1] != (Rollup{Period: PeriodMonth, Start: jan, Uses: 2}) {
+			t.Errorf("Unexpected rollups: %+v", stats.Rollups)
+		}
+		if stats.Key.Usage.DayUses != 0 || stats.Key.Usage.MonthUses != 0 || stats.Key.Uses != 2 {
+			t.Errorf("Expected current usage to reset and total to stay; got %+v", stats.Key)
+		}
+
+		if days, _ := s.Stats(key.ID, PeriodDay); len(days.Rollups) != 1 {
+			t.Errorf("Expected one daily rollup; got %+v", days.Rollups)
+		}
+	})
+
+	t.Run("Keeps a limited number of rollups", func(t *testing.T) {
+		s, now := newStore(t)
+		key, secret, _ := s.Create("ci", nil, time.Time{}, Quota{})
+		for i := 0; i < keepDays+5; i++ {
+			s.Allow(secret)
+			*now = now.AddDate(0, 0, 1)
+		}
+		s.Roll()
+
+		if days, _ := s.Stats(key.ID, PeriodDay); len(days.Rollups) != keepDays {
+			t.Errorf("Expected %d daily rollups; got %d", keepDays, len(days.Rollups))
+		}
+	})
+
+	t.Run("Persists rollups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		s, _ := Open(path)
+		now := time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)
+		s.now = func() time.Time { return now }
+		key, secret, _ := s.Create("ci", nil, time.Time{}, Quota{})
+		s.Allow(secret)
+		now = now.Add(2 * time.Hour)
+		s.Roll()
+		if err := s.Flush(); err != nil {
+			t.Fatalf("Expected no error flushing; got %v", err)
+		}
+
+		reopened, err := Open(path)
+		if err != nil {
+			t.Fatalf("Expected no error reopening; got %v", err)
+		}
+		reopened.now = s.now
+		if stats, _ := reopened.Stats(key.ID, ""); len(stats.Rollups) != 2 {
+			t.Errorf("Expected rollups to survive a restart; got %+v", stats.Rollups)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jswanson806/joke-generator/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+	 Function to return the gRPC interceptors checking every call's
+	 API key, as requireKey and middleware.Quota do for HTTP
+
+		Calls need a key valid accepts, and within allow's quota when
+		allow isn't nil.
+*/
+func grpcKeys(valid func(string) bool, allow func(string) (bool, time.Time)) []grpc.ServerOption {
+	check := func(ctx context.Context) error {
+		key := server.GRPCKey(ctx)
+		if key == "" || !valid(key) {
+			return status.Error(codes.Unauthenticated, "missing or invalid API key")
+		}
+		if allow != nil {
+			if ok, reset := allow(key); !ok {
+				return status.Errorf(codes.ResourceExhausted, "API key quota exceeded until %s", reset.UTC().Format(time.RFC3339))
+			}
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// Function to stop g once its calls finish, ending those still running after timeout
+func stopGRPC(g *grpc.Server, timeout time.Duration, logger *slog.Logger) {
+	stopped := make(chan struct{})
+	go func() {
+		g.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		logger.Error("could not drain gRPC calls")
+		g.Stop()
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCKeys(t *testing.T) {
+	t.Parallel()
+
+	allow := func(key string) (bool, time.Time) { return key != "spent", time.Now().Add(time.Hour) }
+	app := server.NewServer(server.WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}))
+	g := app.GRPCServer(grpcKeys(middleware.StaticKeys("static", "spent"), allow)...)
+	lis := bufconn.Listen(1 << 20)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Could not dial: %v", err)
+	}
+	defer conn.Close()
+	client := jokev1.NewJokeServiceClient(conn)
+
+	tests := []struct {
+		name string
+		key  string
+		want codes.Code
+	}{
+		{"Valid key", "static", codes.OK},
+		{"No key", "", codes.Unauthenticated},
+		{"Unknown key", "forged", codes.Unauthenticated},
+		{"Key over quota", "spent", codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.key)
+		}
+		if _, err := client.GetName(ctx, &jokev1.GetNameRequest{}); status.Code(err) != tt.want {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.want, err)
+		}
+	}
+}
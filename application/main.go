@@ -1,261 +1,1282 @@
 package main
 
 import (
-	"encoding/json"
+	"cmp"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/jswanson806/joke-generator/analytics"
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/digest"
+	"github.com/jswanson806/joke-generator/directory"
+	"github.com/jswanson806/joke-generator/experiment"
+	"github.com/jswanson806/joke-generator/feature"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/metering"
+	"github.com/jswanson806/joke-generator/metrics"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/payloadlog"
+	"github.com/jswanson806/joke-generator/redis"
+	"github.com/jswanson806/joke-generator/s3"
+	"github.com/jswanson806/joke-generator/safety"
+	"github.com/jswanson806/joke-generator/search"
+	"github.com/jswanson806/joke-generator/sentry"
+	"github.com/jswanson806/joke-generator/server"
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/sla"
+	"github.com/jswanson806/joke-generator/statsd"
+	"github.com/jswanson806/joke-generator/submission"
+	"github.com/jswanson806/joke-generator/teams"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/tracing"
+	"github.com/jswanson806/joke-generator/translate"
+	"github.com/jswanson806/joke-generator/trending"
+	"github.com/jswanson806/joke-generator/ui"
+	"github.com/jswanson806/joke-generator/vcr"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 )
 
 const serverPort = 3000
 
-// Endpoint for getting a random first and last name
-const randNameEndpoint = "https://names.mcquay.me/api/v0/"
+// Number of names kept ready ahead of incoming requests
+const namePrefetchSize = 16
 
-// Base endpoint for generating a random joke.
-// Use query string values 'firstName' and 'lastName' to personalize
-const randJokeBaseEndpoint = "http://joke.loc8u.com:8888/joke?limitTo=nerdy"
+// How often the feature flag and IP rules files are checked for changes
+const reloadInterval = 5 * time.Second
 
-// struct to hold expected output of Names
-type Names struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-}
+// Joke categories never served in -safe-mode
+var safeModeExcluded = []string{"explicit"}
 
-// struct to hold expected output of Joke
-type Joke struct {
-	Value struct {
-		Joke string `json:"joke"`
-	} `json:"value"`
-}
+// Longest a published joke's post to Teams may take
+const teamsPostTimeout = 10 * time.Second
 
-func getRoot(w http.ResponseWriter, r *http.Request) {
-	var wg sync.WaitGroup
-	var name Names
-	var joke string
-	var err error
+// Jokes -llm-model may generate at once over -llm-rate
+const llmBurst = 5
 
-	// Add to WaitGroup
-	wg.Add(1)
-	// goroutine to get random first and last name
-	go func() {
-		defer wg.Done()
-		name, err = getRandomName()
-		// Set error while getting name
+// How often API key usage and -cache-file are saved
+const flushInterval = 30 * time.Second
+
+// Most served jokes kept in history, and how often entries older than
+// -history-retention are purged from it
+const (
+	historySize   = 1000
+	purgeInterval = time.Hour
+)
+
+// Distinct served jokes kept in the search index and tracked for trending
+const (
+	searchIndexSize   = 10000
+	trendingTrackSize = 10000
+)
+
+// How long until a serve counts half as much toward the weekly digest,
+// so the week's jokes outrank older ones
+const digestHalfLife = 48 * time.Hour
+
+// How often days that have ended are uploaded to -analytics-s3, and
+// buffered events are written to -event-sink
+const (
+	analyticsInterval = 10 * time.Minute
+	eventInterval     = 5 * time.Second
+)
+
+// How often finished spans are exported to -otlp-endpoint
+const traceInterval = 5 * time.Second
+
+// Most sessions kept in memory; the least recently used end to make room
+const maxSessions = 100000
+
+// How long a replica's lease on a scheduled job outlives it, should it die
+const leaseTTL = 30 * time.Second
+
+// Calls to each upstream in flight at once without -name-concurrency and -joke-concurrency
+const defaultConcurrency = 32
+
+func main() {
+	// Command line configuration
+	addr := flag.String("addr", fmt.Sprintf("127.0.0.1:%d", serverPort), "address to listen on")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline for each request, including upstream calls")
+	nameConnectTimeout := flag.Duration("name-connect-timeout", joke.DefaultConnectTimeout, "longest wait to connect to a name source")
+	nameReadTimeout := flag.Duration("name-read-timeout", joke.DefaultReadTimeout, "longest wait for a name source to respond once connected")
+	jokeConnectTimeout := flag.Duration("joke-connect-timeout", joke.DefaultConnectTimeout, "longest wait to connect to a joke source")
+	jokeReadTimeout := flag.Duration("joke-read-timeout", joke.DefaultReadTimeout, "longest wait for a joke source to respond once connected; raise it for -llm-model")
+	rps := flag.Float64("rate", 0, "global requests per second allowed, 0 disables rate limiting")
+	burst := flag.Int("burst", 10, "requests allowed in a burst over -rate")
+	ipRules := flag.String("ip-rules", "", "JSON file of CIDR allow and deny lists, reloaded when it changes; empty serves every client")
+	ipRate := flag.Float64("ip-rate", 0, "requests per second allowed for each client IP, 0 disables per-IP rate limiting")
+	ipBurst := flag.Int("ip-burst", 20, "requests each client IP may send in a burst over -ip-rate")
+	ipAllow := flag.String("ip-rate-allow", "", "comma-separated CIDRs or IPs exempt from -ip-rate, e.g. internal networks")
+	redisURL := flag.String("redis-url", "", "redis:// or rediss:// URL of a Redis shared by every replica to enforce -rate and -ip-rate cluster-wide and run scheduled jobs on one replica, empty limits each instance")
+	apiKeys := flag.String("api-keys", "", "comma-separated API keys required on every request, empty disables auth")
+	adminKeys := flag.String("admin-keys", "", "comma-separated API keys allowed to call /admin routes, empty disables the admin routes")
+	keysFile := flag.String("keys-file", "", "JSON file holding API keys managed through /admin/keys, empty disables managed keys")
+	cacheFile := flag.String("cache-file", "", "bbolt database file the fallback joke cache is saved to so it survives restarts, empty disables the cache")
+	tenantsPath := flag.String("tenants", "", "JSON file of tenants selected by /t/{id}/ prefix or API key, each with its own categories, rate limit and branding")
+	meterSink := flag.String("metering-sink", "", "where per-key usage is exported: file:///path, http(s)://url or s3://bucket/prefix, empty disables metering")
+	meterInterval := flag.Duration("metering-interval", metering.DefaultInterval, "how often usage is exported to -metering-sink")
+	logLevel := flag.String("log-level", "info", "initial log level: debug, info, warn or error; admins can change it at runtime")
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL for user login, empty disables login")
+	submissionsFile := flag.String("submissions-file", "", "JSON file holding jokes submitted by signed-in users at POST /jokes/submit, empty disables submissions; needs -oidc-issuer")
+	submissionsShare := flag.Float64("submissions-share", 0.2, "fraction (0-1) of jokes served from approved -submissions-file submissions")
+	submissionsWebhook := flag.String("submissions-webhook", "", "URL every submission, edit, approval and rejection is posted to as JSON, e.g. to notify moderators; empty disables")
+	oidcClientID := flag.String("oidc-client-id", "", "client ID registered with -oidc-issuer; the secret is read from OIDC_CLIENT_SECRET")
+	oidcRedirect := flag.String("oidc-redirect-url", "http://localhost:3000/auth/callback", "absolute URL of the /auth/callback route registered with -oidc-issuer")
+	vcrMode := flag.String("vcr-mode", "off", "upstream record/replay mode: off, record or replay")
+	vcrDir := flag.String("vcr-dir", "fixtures", "directory holding recorded upstream responses")
+	payloadRate := flag.Float64("log-payloads", 0, "fraction of upstream requests and responses to log, 0 disables payload logging")
+	payloadMax := flag.Int("log-payloads-max", payloadlog.DefaultMaxBytes, "bytes of each upstream response body logged by -log-payloads")
+	featuresPath := flag.String("features", "", "JSON file of feature flags, reloaded when it changes; FEATURE_* environment variables override it")
+	chaosRate := flag.Float64("chaos-rate", 0, "fraction of upstream calls to fault with delays, errors or malformed payloads, 0 disables chaos")
+	upstreamConcurrency := flag.Int("upstream-concurrency", 0, "most upstream calls in flight at once across the name and joke services, 0 leaves only the per-service limits")
+	nameConcurrency := flag.Int("name-concurrency", defaultConcurrency, "most name service calls in flight at once; more wait for a slot")
+	jokeConcurrency := flag.Int("joke-concurrency", defaultConcurrency, "most joke service calls in flight at once; more wait for a slot")
+	chaosDelay := flag.Duration("chaos-delay", joke.DefaultChaosDelay, "delay injected into upstream calls faulted by -chaos-rate")
+	roster := flag.String("roster", "", "JSON or CSV file of teammates to pick names from, optionally weighted, instead of the name service; empty disables")
+	googleCredentials := flag.String("google-directory-credentials", "", "service account key file used to pick names from the Google Workspace directory instead of the name service; empty disables")
+	googleAdmin := flag.String("google-directory-admin", "", "Google Workspace admin the -google-directory-credentials service account acts as")
+	googleQuery := flag.String("google-directory-query", "", "Directory API query filtering the users, e.g. orgUnitPath='/Engineering'")
+	googleGroup := flag.String("google-directory-group", "", "email of a group whose direct members are the only users picked")
+	slackChannel := flag.String("slack-channel", "", "ID of a Slack channel whose members are picked as names instead of the name service, with a bot token in SLACK_TOKEN; empty disables")
+	githubRepo := flag.String("github-repo", "", "GitHub repository, as owner/name, whose contributors are picked as names instead of the name service, with an optional token in GITHUB_TOKEN; empty disables")
+	directoryRefresh := flag.Duration("directory-refresh", directory.DefaultRefresh, "how often directory name sources are listed again")
+	madLibs := flag.String("madlibs", "", "JSON file of joke templates and word lists to fill them from, instead of the joke service; empty disables")
+	llmModel := flag.String("llm-model", "", "model generating jokes through an OpenAI-compatible chat completions API instead of the joke service, empty disables; the key is read from LLM_API_KEY")
+	llmURL := flag.String("llm-url", joke.DefaultLLMEndpoint, "chat completions endpoint used by -llm-model")
+	llmPrompt := flag.String("llm-prompt", "", "file holding the prompt template for -llm-model, given {{.FirstName}} and {{.LastName}}; empty uses the built-in prompt")
+	llmMaxTokens := flag.Int("llm-max-tokens", joke.DefaultLLMMaxTokens, "tokens each generated joke may use")
+	llmRate := flag.Float64("llm-rate", 1, "jokes generated per second at most, in bursts of up to 5; others get the fallback joke, 0 disables the limit")
+	llmDailyTokens := flag.Int("llm-daily-tokens", 0, "tokens -llm-model may use per UTC day before serving the fallback joke, 0 disables the budget")
+	warmNames := flag.Int("warm-names", 0, "names to prefetch before /readyz reports ready, up to the prefetch buffer's size")
+	warmJokes := flag.Int("warm-jokes", 0, "jokes to fetch before /readyz reports ready, opening upstream connections and caching the fallback joke")
+	warmTimeout := flag.Duration("warm-timeout", 30*time.Second, "longest warm-up before the server reports ready anyway")
+	shutdownDelay := flag.Duration("shutdown-delay", 0, "how long /readyz reports not ready before connections are drained on shutdown, so load balancers stop routing here first")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "longest wait for in-flight requests to finish on shutdown")
+	publishInterval := flag.Duration("publish-interval", 0, "how often a joke is published to clients long-polling GET /joke/next, 0 disables the route")
+	historyRetention := flag.Duration("history-retention", 0, "how long served jokes are kept in history before being purged, e.g. 2160h for 90 days; 0 keeps them until the newest 1000 push them out")
+	analyticsS3 := flag.String("analytics-s3", "", "s3://bucket/prefix a JSON summary of each UTC day's served jokes is uploaded to, with credentials from the AWS environment variables; empty disables")
+	statsdAddr := flag.String("statsd-addr", "", "host:port of a StatsD or DogStatsD agent upstream metrics are also pushed to, e.g. 127.0.0.1:8125; empty disables")
+	statsdPrefix := flag.String("statsd-prefix", "joke.", "prefix of every metric pushed to -statsd-addr")
+	statsdTags := flag.String("statsd-tags", "", "comma-separated key:value DogStatsD tags added to every metric pushed to -statsd-addr, e.g. env:prod,service:jokes")
+	statsdPlain := flag.Bool("statsd-plain", false, "push plain StatsD to -statsd-addr, folding tag values into metric names, for agents without DogStatsD tags")
+	sentrySample := flag.Float64("sentry-sample-rate", 1, "fraction (0-1) of errors sent to Sentry when SENTRY_DSN is set")
+	sentryRelease := flag.String("sentry-release", "", "release errors sent to Sentry are tagged with, empty uses the VCS revision the binary was built from")
+	sentryEnv := flag.String("sentry-environment", "production", "environment errors sent to Sentry are tagged with")
+	sentryBurst := flag.Int("sentry-burst", 20, "errors from one upstream within a minute reported to Sentry as a burst")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "base URL of an OpenTelemetry collector traces are exported to over OTLP/HTTP, e.g. http://localhost:4318, with headers from OTEL_EXPORTER_OTLP_HEADERS; empty disables tracing")
+	otlpService := flag.String("otlp-service-name", "joke-generator", "service.name resource attribute of exported traces")
+	otlpEnv := flag.String("otlp-environment", "", "deployment.environment.name resource attribute of exported traces, e.g. production")
+	otlpResource := flag.String("otlp-resource-attributes", "", "extra comma-separated key=value resource attributes of exported traces, e.g. service.version=1.4,team=fun")
+	traceSampler := flag.String("trace-sampler", "parentbased_always_on", "traces kept for -otlp-endpoint: always_on, traceidratio, parentbased_always_on or parentbased_traceidratio, the last two following the caller's traceparent decision")
+	traceRatio := flag.Float64("trace-sample-ratio", 1, "fraction (0-1) of traces kept by the traceidratio samplers")
+	traceErrors := flag.Bool("trace-keep-errors", true, "keep every trace of a failed request or upstream call, whatever -trace-sampler decided")
+	selfCheck := flag.Duration("selfcheck-interval", 0, "how often a synthetic joke is built through the live name and joke services, recording success and latency in the joke_selfcheck metrics; 0 disables")
+	safeMode := flag.Bool("safe-mode", false, "kid-safe deployment: excludes explicit jokes, filters profanity and refuses joke sources that can't be vetted, such as -llm-model")
+	blocklistPath := flag.String("blocklist", "", "file of words, phrases and /regexes/, one per line, blocking every joke containing one; reloaded when it changes, empty disables")
+	safetyRules := flag.String("safety-rules", "", "JSON file of keyword and regex rules scoring jokes for toxicity before they are served, empty disables")
+	safetyModeration := flag.String("safety-moderation-url", "", "OpenAI-compatible moderation endpoint scoring jokes for toxicity, e.g. https://api.openai.com/v1/moderations; the key is read from MODERATION_API_KEY, empty disables")
+	safetyThreshold := flag.Float64("safety-threshold", 0.5, "toxicity score (0-1) at or above which a joke is blocked by -safety-rules or -safety-moderation-url")
+	safetyRetries := flag.Int("safety-retries", 2, "times a blocked joke is re-fetched before the fallback joke is served")
+	experimentFile := flag.String("experiment-file", "", "JSON file of an A/B experiment splitting clients into buckets served by different joke sources, empty disables")
+	shadowURL := flag.String("shadow-joke-url", "", "candidate joke service a share of joke calls are mirrored to in the background, to vet it without serving its jokes; empty disables")
+	shadowShare := flag.Float64("shadow-share", 0.1, "fraction (0-1) of joke calls mirrored to -shadow-joke-url")
+	digestSubscribers := flag.String("digest-subscribers", "", "file of addresses, one per line, emailed the week's top jokes every Monday; unsubscribes are kept in the same path plus .unsubscribed, empty disables")
+	digestSMTP := flag.String("digest-smtp", "", "host:port of the mail server sending the -digest-subscribers emails, logging in with SMTP_USERNAME and SMTP_PASSWORD when set")
+	digestFrom := flag.String("digest-from", "", "sender address of the -digest-subscribers emails")
+	digestURL := flag.String("digest-url", "", "public URL of this server for the unsubscribe links in -digest-subscribers emails, empty uses -public-url")
+	grpcAddr := flag.String("grpc-addr", "", "address the gRPC JokeService listens on, e.g. 127.0.0.1:9090, requiring the same API keys as HTTP; empty disables gRPC")
+	publicURL := flag.String("public-url", "", "URL clients reach this server at, e.g. https://jokes.example.com, which permalinks, oEmbed and sitemap URLs start with; empty uses http:// and -addr")
+	translateProvider := flag.String("translate", "", "translation provider serving translated jokes at /es/joke, /fr/joke and so on: deepl, google, libretranslate or noop, keyed with TRANSLATE_API_KEY; empty disables")
+	translateURL := flag.String("translate-url", "", "URL of the -translate libretranslate server, which it needs, or of the deepl or google API in place of their own")
+	twilioURL := flag.String("twilio-url", "", "public URL of POST /integrations/twilio/voice, set as a Twilio number's voice webhook, to speak jokes to callers; needs TWILIO_AUTH_TOKEN, empty disables")
+	eventSink := flag.String("event-sink", "", "where joke_served and upstream error events are streamed: stdout, http(s)://url or bigquery://project/dataset/table; empty disables")
+	trendingHalfLife := flag.Duration("trending-half-life", trending.DefaultHalfLife, "how long until a serve counts half as much toward a joke trending at /jokes/trending")
+	service := flag.String("service", "", "Windows only: install or uninstall the server as a service with the other flags given, or run as one (used by the installed service)")
+	flag.Parse()
+
+	// Install or uninstall the Windows service and exit
+	if handled, err := serviceCommand(*service); handled {
 		if err != nil {
-			err = fmt.Errorf("failed to get name: %w", err)
-			return
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-	}()
-
-	wg.Wait()
-	//Handle name retrieval error
-	if err != nil {
-		http.Error(w, "failed to get name", http.StatusInternalServerError)
 		return
 	}
+	// Run under the Windows service control manager, logging to the event log
+	logOutput := io.Writer(os.Stderr)
+	var serviceStop <-chan struct{}
+	serviceStopped := func() {}
+	switch *service {
+	case "":
+	case "run":
+		var err error
+		logOutput, serviceStop, serviceStopped, err = runService()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer serviceStopped()
+	default:
+		fmt.Fprintf(os.Stderr, "-service: unknown mode %q, want install, uninstall or run\n", *service)
+		os.Exit(2)
+	}
 
-	// Add to WaitGroup
-	wg.Add(1)
+	// Log level shared with /admin/loglevel so it can change at runtime
+	level := new(slog.LevelVar)
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var logHandler slog.Handler = slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: level})
+	// Tag log lines with the trace and span they were logged in
+	if *otlpEndpoint != "" {
+		logHandler = tracing.LogHandler(logHandler)
+	}
+	logger := slog.New(logHandler)
 
-	// goroutine to get random joke
-	//	Pass first and last name returned from getRandomName()
-	go func() {
-		defer wg.Done()
-		joke, err = getRandomJoke(name.FirstName, name.LastName)
-		// Handle error while getting joke
+	// Root context of the background work, canceled once the server has
+	// drained so nothing more is counted
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	// Function to run work that flushes what it holds when ctx ends,
+	// which is waited for before exiting
+	var flushing sync.WaitGroup
+	flusher := func(run func()) {
+		flushing.Add(1)
+		go func() {
+			defer flushing.Done()
+			run()
+		}()
+	}
+
+	// Feature flags, reloaded in the background when the file changes
+	features, err := feature.New(*featuresPath, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	go features.Watch(ctx, reloadInterval)
+
+	// Trace requests and joke calls when -otlp-endpoint is set
+	var tracer *tracing.Tracer
+	if *otlpEndpoint != "" {
+		resource, err := tracing.ParsePairs(*otlpResource)
 		if err != nil {
-			err = fmt.Errorf("error getting joke: %w", err)
-			return
+			fmt.Fprintln(os.Stderr, "-otlp-resource-attributes:", err)
+			os.Exit(2)
 		}
-	}()
+		headers, err := tracing.ParsePairs(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "OTEL_EXPORTER_OTLP_HEADERS:", err)
+			os.Exit(2)
+		}
+		resource["service.name"] = *otlpService
+		if *otlpEnv != "" {
+			resource["deployment.environment.name"] = *otlpEnv
+		}
+		sampler, err := tracing.ParseSampler(*traceSampler, *traceRatio)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-trace-sampler:", err)
+			os.Exit(2)
+		}
+		tracer = tracing.New(&tracing.OTLP{Endpoint: *otlpEndpoint, Headers: headers}, resource, logger)
+		tracer.Sampler, tracer.KeepErrors = sampler, *traceErrors
+		flusher(func() { tracer.Run(ctx, traceInterval) })
+	}
 
-	wg.Wait()
-	// Handle joke retrieval error
+	// Client for upstream calls, optionally recording or replaying responses
+	mode, err := vcr.ParseMode(*vcrMode)
 	if err != nil {
-		http.Error(w, "failed to get joke", http.StatusInternalServerError)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
+	// Function to return a client for one upstream with its own timeouts
+	newClient := func(timeouts joke.Timeouts) *http.Client {
+		client := joke.NewClient(timeouts)
+		if tracer != nil {
+			client.Transport = tracing.Transport(client.Transport)
+		}
+		if mode != vcr.Off {
+			client.Transport = vcr.New(mode, *vcrDir, client.Transport)
+		}
+		// Log a sample of raw upstream payloads, redacted and capped
+		if *payloadRate > 0 {
+			payloads := payloadlog.New(*payloadRate, client.Transport, logger)
+			payloads.MaxBytes = *payloadMax
+			client.Transport = payloads
+		}
+		return client
+	}
+	// Name sources and joke sources each get their own client
+	nameClient := newClient(joke.Timeouts{Connect: *nameConnectTimeout, Read: *nameReadTimeout})
+	jokeClient := newClient(joke.Timeouts{Connect: *jokeConnectTimeout, Read: *jokeReadTimeout})
 
-	// Call function to return completed joke
-	returnCompleteJoke(joke, w)
-}
+	// Report panics and upstream error bursts to Sentry when SENTRY_DSN is set
+	var tracker *sentry.Client
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		c, err := sentry.New(dsn, sentry.Options{SampleRate: *sentrySample, Release: *sentryRelease, Environment: *sentryEnv, Logger: logger})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "SENTRY_DSN:", err)
+			os.Exit(2)
+		}
+		tracker = c
+	}
+	var reportPanic middleware.PanicReporter
+	if tracker != nil {
+		reportPanic = tracker.ReportPanic
+	}
 
-func main() {
+	// Middleware applied to every route, outermost first
+	chain := []middleware.Middleware{
+		middleware.RecoverReporting(logger, reportPanic),
+		middleware.Logging(logger),
+		middleware.JSONP(),
+	}
+	// Trace outside recovery, so panics are recorded as failed requests
+	if tracer != nil {
+		chain = append([]middleware.Middleware{tracer.Middleware()}, chain...)
+	}
+	// Block disallowed clients before any other work is done for them
+	if *ipRules != "" {
+		rules, err := middleware.LoadIPRules(*ipRules)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		go rules.Watch(ctx, *ipRules, reloadInterval, logger)
+		chain = append(chain, middleware.IPFilter(rules))
+	}
+	// Limits counted in Redis hold across every replica, and leases in
+	// it elect the one replica running each scheduled job
+	var shared *redis.Client
+	if *redisURL != "" {
+		shared, err = redis.Open(*redisURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-redis-url:", err)
+			os.Exit(2)
+		}
+		defer shared.Close()
+	}
+	// Per-IP limits run first so one client can't use up the global limit
+	if *ipRate > 0 {
+		allow, err := middleware.ParsePrefixes(*ipAllow)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-ip-rate-allow:", err)
+			os.Exit(2)
+		}
+		if shared != nil {
+			l := redis.NewLimiter(shared, "ratelimit:ip", *ipRate, *ipBurst)
+			chain = append(chain, middleware.RateLimitSharedPerIP(l, logger, allow...))
+		} else {
+			chain = append(chain, middleware.RateLimitPerIP(*ipRate, *ipBurst, allow...))
+		}
+	}
+	if *rps > 0 {
+		if shared != nil {
+			l := redis.NewLimiter(shared, "ratelimit:global", *rps, *burst)
+			chain = append(chain, middleware.RateLimitShared(l, middleware.GlobalKey, logger))
+		} else {
+			chain = append(chain, middleware.RateLimit(*rps, *burst))
+		}
+	}
+	// Keys created through /admin/keys, cached in memory for auth checks
+	var keys *apikey.Store
+	if *keysFile != "" {
+		keys, err = apikey.Open(*keysFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-keys-file:", err)
+			os.Exit(2)
+		}
+		go maintainKeys(ctx, keys, logger)
+	}
+	var adminValid func(string) bool
+	if *adminKeys != "" {
+		adminValid = middleware.StaticKeys(strings.Split(*adminKeys, ",")...)
+	}
+	if keys != nil {
+		staticAdmin := adminValid
+		adminValid = func(key string) bool {
+			if staticAdmin != nil && staticAdmin(key) {
+				return true
+			}
+			_, ok := keys.Authenticate(key, apikey.ScopeAdmin)
+			return ok
+		}
+	}
+	// gRPC calls need the same keys as HTTP requests
+	var grpcOpts []grpc.ServerOption
+	if *apiKeys != "" || keys != nil {
+		var staticValid func(string) bool
+		if *apiKeys != "" {
+			staticValid = middleware.StaticKeys(strings.Split(*apiKeys, ",")...)
+		}
+		chain = append(chain, requireKey(staticValid, adminValid, keys))
+		var allow func(string) (bool, time.Time)
+		if keys != nil {
+			allow = keys.Allow
+			chain = append(chain, middleware.Quota(allow))
+		}
+		grpcOpts = grpcKeys(jokeKeys(staticValid, keys), allow)
+	}
+	if *meterSink != "" {
+		sink, err := metering.NewSink(*meterSink)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-metering-sink:", err)
+			os.Exit(2)
+		}
+		meter := metering.New(sink, logger)
+		flusher(func() { meter.Run(ctx, *meterInterval) })
+		chain = append(chain, meter.Middleware(func(r *http.Request) string {
+			return keyID(keys, middleware.RequestKey(r))
+		}))
+	}
+	chain = append(chain, middleware.Timeout(*timeout))
 
-	// Use http.ServeMux struct instead of default multiplexer
-	mux := http.NewServeMux()
+	// Kid-safe mode never serves jokes of the excluded categories, and only
+	// serves sources whose jokes are vetted before they're served
+	var excluded []string
+	if *safeMode {
+		excluded = safeModeExcluded
+		if *llmModel != "" {
+			fmt.Fprintln(os.Stderr, "-safe-mode: -llm-model generates jokes no one vetted, unset it")
+			os.Exit(2)
+		}
+		logger.Info("safe mode enabled", "excluded_categories", excluded)
+	}
 
-	// Handlers for routes are defined below
-	mux.HandleFunc("/", getRoot)
+	// Upstream providers, optionally with injected faults
+	var (
+		upstreamNames joke.NameProvider = &joke.HTTPNameProvider{Client: nameClient, Logger: logger}
+		upstreamJokes joke.JokeProvider = &joke.HTTPJokeProvider{Client: jokeClient, Logger: logger, Exclude: excluded}
+	)
+	// Only one name source may replace the name service
+	nameSources := 0
+	for _, set := range []bool{*roster != "", *googleCredentials != "", *slackChannel != "", *githubRepo != ""} {
+		if set {
+			nameSources++
+		}
+	}
+	if nameSources > 1 {
+		fmt.Fprintln(os.Stderr, "-roster, -google-directory-credentials, -slack-channel and -github-repo are all name sources, set one")
+		os.Exit(2)
+	}
+	if *roster != "" {
+		r, err := joke.LoadRoster(*roster)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-roster:", err)
+			os.Exit(2)
+		}
+		upstreamNames = r
+	}
+	if *googleCredentials != "" {
+		g, err := directory.LoadGoogle(*googleCredentials, *googleAdmin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-google-directory-credentials:", err)
+			os.Exit(2)
+		}
+		g.Query, g.Group, g.Client = *googleQuery, *googleGroup, nameClient
+		upstreamNames = startDirectory(ctx, g, *directoryRefresh, logger)
+	}
+	if *slackChannel != "" {
+		token := os.Getenv("SLACK_TOKEN")
+		if token == "" {
+			fmt.Fprintln(os.Stderr, "-slack-channel: SLACK_TOKEN is not set")
+			os.Exit(2)
+		}
+		upstreamNames = startDirectory(ctx, &directory.Slack{Token: token, Channel: *slackChannel, Client: nameClient}, *directoryRefresh, logger)
+	}
+	if *githubRepo != "" {
+		upstreamNames = startDirectory(ctx, &directory.GitHub{Repo: *githubRepo, Token: os.Getenv("GITHUB_TOKEN"), Client: nameClient}, *directoryRefresh, logger)
+	}
+	if *madLibs != "" && *llmModel != "" {
+		fmt.Fprintln(os.Stderr, "-madlibs and -llm-model are both joke sources, set one")
+		os.Exit(2)
+	}
+	if *madLibs != "" {
+		m, err := joke.LoadMadLibs(*madLibs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-madlibs:", err)
+			os.Exit(2)
+		}
+		upstreamJokes = m
+	}
+	if *llmModel != "" {
+		llm := &joke.LLMJokeProvider{
+			Endpoint:    *llmURL,
+			APIKey:      os.Getenv("LLM_API_KEY"),
+			Model:       *llmModel,
+			MaxTokens:   *llmMaxTokens,
+			DailyTokens: *llmDailyTokens,
+			Client:      jokeClient,
+			Logger:      logger,
+		}
+		if *llmPrompt != "" {
+			prompt, err := os.ReadFile(*llmPrompt)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "-llm-prompt:", err)
+				os.Exit(2)
+			}
+			llm.Prompt = string(prompt)
+		}
+		if *llmRate > 0 {
+			llm.Limiter = rate.NewLimiter(rate.Limit(*llmRate), llmBurst)
+		}
+		upstreamJokes = llm
+	}
+	// Serve each experiment bucket from its own joke source, behind the
+	// same chaos, metrics and bulkheads as the joke service
+	var exp *experiment.Experiment
+	if *experimentFile != "" {
+		exp, err = experiment.Load(*experimentFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-experiment-file:", err)
+			os.Exit(2)
+		}
+		for _, b := range exp.Buckets {
+			switch {
+			case b.JokeURL != "" && *safeMode:
+				fmt.Fprintf(os.Stderr, "-safe-mode: experiment bucket %s serves an unvetted joke_url\n", b.Name)
+				os.Exit(2)
+			case b.JokeURL != "":
+				b.Jokes = &joke.HTTPJokeProvider{Endpoint: b.JokeURL, Client: jokeClient, Logger: logger}
+			case b.MadLibs != "":
+				m, err := joke.LoadMadLibs(b.MadLibs)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "-experiment-file:", err)
+					os.Exit(2)
+				}
+				b.Jokes = m
+			}
+		}
+		upstreamJokes = exp.Jokes(upstreamJokes)
+	}
+
+	// Track each upstream's uptime for /providers/sla, before injected
+	// faults so they don't count against the vendor
+	slas := sla.New()
+	upstreamNames = slas.Names("name", upstreamNames)
+	upstreamJokes = slas.Jokes("joke", upstreamJokes)
+
+	if *chaosRate > 0 {
+		logger.Warn("chaos mode enabled", "rate", *chaosRate, "delay", *chaosDelay)
+		chaos := &joke.Chaos{Rate: *chaosRate, Delay: *chaosDelay, Logger: logger}
+		upstreamNames = chaos.Names(upstreamNames)
+		upstreamJokes = chaos.Jokes(upstreamJokes)
+	}
+
+	// Record latency and errors per upstream, counting injected faults too
+	registry := metrics.NewRegistry()
+	if *statsdAddr != "" {
+		client, err := statsd.Dial(*statsdAddr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-statsd-addr:", err)
+			os.Exit(2)
+		}
+		client.Prefix = *statsdPrefix
+		client.Plain = *statsdPlain
+		if *statsdTags != "" {
+			client.Tags = strings.Split(*statsdTags, ",")
+		}
+		registry.Forward(client)
+	}
+	if exp != nil {
+		exp.ObserveCall = registry.ObserveExperiment
+		exp.ObserveRating = registry.ObserveRating
+	}
+	upstreamNames = registry.Names("name", upstreamNames)
+	upstreamJokes = registry.Jokes("joke", upstreamJokes)
+
+	if tracker != nil {
+		bursts := sentry.NewBursts(tracker, *sentryBurst, time.Minute)
+		upstreamNames = bursts.Names("name", upstreamNames)
+		upstreamJokes = bursts.Jokes("joke", upstreamJokes)
+	}
+
+	// Stream served jokes and upstream errors to -event-sink
+	var events *analytics.Events
+	if *eventSink != "" {
+		sink, err := analytics.NewEventSink(*eventSink)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-event-sink:", err)
+			os.Exit(2)
+		}
+		events = analytics.NewEvents(sink, logger)
+		flusher(func() { events.Run(ctx, eventInterval) })
+		upstreamNames = events.Names("name", upstreamNames)
+		upstreamJokes = events.Jokes("joke", upstreamJokes)
+	}
+
+	// Cap the calls in flight to each upstream, and all of them when asked,
+	// so a slow service can't starve the other of goroutines. Each call takes
+	// its own service's slot before a global one, or calls queued behind a
+	// slow service would hold global slots.
+	if *nameConcurrency < 1 || *jokeConcurrency < 1 {
+		fmt.Fprintln(os.Stderr, "-name-concurrency and -joke-concurrency must be at least 1")
+		os.Exit(2)
+	}
+	if *upstreamConcurrency > 0 {
+		global := joke.NewBulkhead("upstream", *upstreamConcurrency, registry.ObserveQueue)
+		upstreamNames = global.Names(upstreamNames)
+		upstreamJokes = global.Jokes(upstreamJokes)
+	}
+	upstreamNames = joke.NewBulkhead("name", *nameConcurrency, registry.ObserveQueue).Names(upstreamNames)
+	upstreamJokes = joke.NewBulkhead("joke", *jokeConcurrency, registry.ObserveQueue).Jokes(upstreamJokes)
+	// Joke spans include the wait for a slot. Names are prefetched outside
+	// of requests, so they aren't traced.
+	if tracer != nil {
+		upstreamJokes = tracer.Jokes("joke", upstreamJokes)
+	}
+
+	// Probe the live upstreams, bypassing prefetched names and submissions
+	if *selfCheck > 0 {
+		go selfCheckLoop(ctx, upstreamNames, upstreamJokes, *selfCheck, *timeout, registry, logger)
+	}
+
+	// Mirror a share of joke calls to a candidate replacement, tracking it
+	// under the "shadow" upstream in /metrics and /providers/sla
+	if *shadowURL != "" {
+		if *shadowShare < 0 || *shadowShare > 1 {
+			fmt.Fprintln(os.Stderr, "-shadow-share must be between 0 and 1")
+			os.Exit(2)
+		}
+		candidate := &joke.HTTPJokeProvider{
+			Endpoint: *shadowURL,
+			Client:   newClient(joke.Timeouts{Connect: *jokeConnectTimeout, Read: *jokeReadTimeout}),
+			Logger:   logger,
+		}
+		observe := func(d time.Duration, err error) {
+			registry.ObserveUpstream("shadow", d, err)
+			slas.Observe("shadow", d, err)
+		}
+		upstreamJokes = joke.NewShadow(candidate, *shadowShare, *jokeConnectTimeout+*jokeReadTimeout, 0, observe, logger).Jokes(upstreamJokes)
+	}
+
+	// Serve approved submissions for a share of jokes
+	var submissions *submission.Store
+	if *submissionsFile != "" {
+		if *oidcIssuer == "" {
+			fmt.Fprintln(os.Stderr, "-submissions-file needs -oidc-issuer, only signed-in users may submit jokes")
+			os.Exit(2)
+		}
+		if *submissionsShare < 0 || *submissionsShare > 1 {
+			fmt.Fprintln(os.Stderr, "-submissions-share must be between 0 and 1")
+			os.Exit(2)
+		}
+		submissions, err = submission.Open(*submissionsFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-submissions-file:", err)
+			os.Exit(2)
+		}
+		if *submissionsWebhook != "" {
+			submissions.OnChange(submission.Webhook(*submissionsWebhook, nil, logger))
+		}
+		upstreamJokes = submissions.Jokes(upstreamJokes, *submissionsShare, excluded...)
+	}
+
+	// Score every joke for toxicity before it is served, re-fetching those
+	// at or above -safety-threshold
+	var scorers []safety.Scorer
+	if *safeMode {
+		scorers = append(scorers, safety.Profanity())
+	}
+	var blocklist *safety.Blocklist
+	if *blocklistPath != "" {
+		blocklist, err = safety.LoadBlocklist(*blocklistPath, registry.ObserveBlocked, logger)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-blocklist:", err)
+			os.Exit(2)
+		}
+		scorers = append(scorers, blocklist)
+	}
+	if *safetyRules != "" {
+		k, err := safety.LoadKeywords(*safetyRules)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-safety-rules:", err)
+			os.Exit(2)
+		}
+		scorers = append(scorers, k)
+	}
+	if *safetyModeration != "" {
+		scorers = append(scorers, &safety.Moderation{
+			Endpoint: *safetyModeration,
+			APIKey:   os.Getenv("MODERATION_API_KEY"),
+			Client:   newClient(joke.Timeouts{Connect: *jokeConnectTimeout, Read: *jokeReadTimeout}),
+		})
+	}
+	if len(scorers) > 0 {
+		if *safetyThreshold <= 0 || *safetyThreshold > 1 {
+			fmt.Fprintln(os.Stderr, "-safety-threshold must be above 0 and at most 1")
+			os.Exit(2)
+		}
+		upstreamJokes = safety.NewFilter(safety.Max(scorers...), *safetyThreshold, *safetyRetries, registry.ObserveSafety, logger).Jokes(upstreamJokes)
+	}
+
+	// Keep random names ready ahead of incoming requests
+	names := joke.NewNamePrefetcher(upstreamNames, max(namePrefetchSize, *warmNames), logger)
+	go names.Run(ctx)
+
+	// Keep served jokes, purging them after -history-retention
+	if *historyRetention < 0 {
+		fmt.Fprintln(os.Stderr, "-history-retention must not be negative")
+		os.Exit(2)
+	}
+	served := history.New(historySize)
+	if *historyRetention > 0 {
+		go purgeHistory(ctx, served, *historyRetention, registry, logger)
+	}
+	if events != nil {
+		served.OnAdd(events.Served)
+	}
+	var weekly *digest.Digest
+	if *digestSubscribers != "" {
+		secret := os.Getenv("DIGEST_SECRET")
+		switch {
+		case *digestURL == "" && *publicURL == "":
+			fmt.Fprintln(os.Stderr, "-digest-subscribers needs -public-url or -digest-url for its unsubscribe links")
+			os.Exit(2)
+		case *digestSMTP == "" || *digestFrom == "":
+			fmt.Fprintln(os.Stderr, "-digest-subscribers needs -digest-smtp and -digest-from")
+			os.Exit(2)
+		case secret == "":
+			fmt.Fprintln(os.Stderr, "-digest-subscribers: DIGEST_SECRET is not set")
+			os.Exit(2)
+		}
+		// Rank every tenant's jokes together over about a week
+		top := trending.New(digestHalfLife, trendingTrackSize)
+		served.OnAdd(func(e history.Entry) {
+			top.Record("", e.Joke, e.Category, e.ServedAt)
+		})
+		weekly = digest.New(digest.Config{
+			Subscribers: digest.NewSubscribers(*digestSubscribers, *digestSubscribers+".unsubscribed"),
+			Top:         func(n int) []trending.Joke { return top.Top("", "", n) },
+			Sender:      &digest.SMTP{Addr: *digestSMTP, From: *digestFrom, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD")},
+			Secret:      []byte(secret),
+			BaseURL:     strings.TrimSuffix(cmp.Or(*digestURL, *publicURL), "/"),
+			Logger:      logger,
+		})
+		// Only the replica holding the lease sends, so nobody gets it twice
+		flusher(func() { lead(ctx, shared, "digest", weekly.Run, logger) })
+	}
+	if *analyticsS3 != "" {
+		u, err := url.Parse(*analyticsS3)
+		if err != nil || u.Scheme != "s3" || u.Host == "" {
+			fmt.Fprintln(os.Stderr, "-analytics-s3 must be an s3://bucket/prefix URL")
+			os.Exit(2)
+		}
+		host, _ := os.Hostname()
+		instance := fmt.Sprintf("%s-%d", host, time.Now().Unix())
+		agg := analytics.New(s3.New(s3.FromEnv(u.Host)), u.Path, instance, logger)
+		served.OnAdd(agg.Add)
+		flusher(func() { agg.Run(ctx, analyticsInterval) })
+	}
 
 	// Set up the server
-	server := http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", serverPort),
-		Handler: mux,
+	opts := []server.Option{
+		server.WithAddr(*addr),
+		server.WithPublicURL(*publicURL),
+		server.WithProviders(names, upstreamJokes),
+		server.WithFeatures(features),
+		server.WithMetrics(registry),
+		server.WithLogger(logger),
+		server.WithLogLevel(level),
+		server.WithMiddleware(chain...),
+		server.WithHistory(served),
+		server.WithSearch(search.New(searchIndexSize)),
+		server.WithTrending(trending.New(*trendingHalfLife, trendingTrackSize)),
+		server.WithSLA(slas),
+	}
+	if exp != nil {
+		opts = append(opts, server.WithExperiment(exp))
+	}
+	if weekly != nil {
+		opts = append(opts, server.WithDigest(weekly))
+	}
+	if *twilioURL != "" {
+		token := os.Getenv("TWILIO_AUTH_TOKEN")
+		if token == "" {
+			fmt.Fprintln(os.Stderr, "-twilio-url: TWILIO_AUTH_TOKEN is not set")
+			os.Exit(2)
+		}
+		opts = append(opts, server.WithTwilio(token, *twilioURL))
+	}
+	// Serve the browser page and its embedded assets
+	page, err := ui.New()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	opts = append(opts, server.WithUI(page))
+	if *publishInterval < 0 {
+		fmt.Fprintln(os.Stderr, "-publish-interval must not be negative")
+		os.Exit(2)
+	}
+	// Answer Mattermost slash commands and outgoing webhooks carrying
+	// one of the comma-separated MATTERMOST_TOKENS
+	if tokens := os.Getenv("MATTERMOST_TOKENS"); tokens != "" {
+		opts = append(opts, server.WithMattermost(strings.Split(tokens, ",")...))
+	}
+	if *translateProvider != "" {
+		translator, err := translate.New(translate.Config{
+			Provider: *translateProvider,
+			APIKey:   os.Getenv("TRANSLATE_API_KEY"),
+			URL:      *translateURL,
+			Client:   newClient(joke.Timeouts{Connect: *jokeConnectTimeout, Read: *jokeReadTimeout}),
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-translate:", err)
+			os.Exit(2)
+		}
+		opts = append(opts, server.WithTranslator(translator))
+	}
+	// Post jokes to Teams when TEAMS_WEBHOOK_URL is set: each published
+	// joke, and any an admin asks for
+	var teamsHook *teams.Webhook
+	if u := os.Getenv("TEAMS_WEBHOOK_URL"); u != "" {
+		teamsHook = &teams.Webhook{URL: u, Client: newClient(joke.Timeouts{Connect: *jokeConnectTimeout, Read: *jokeReadTimeout})}
+		opts = append(opts, server.WithTeams(teamsHook))
 	}
+	if *publishInterval > 0 {
+		publisher := joke.NewPublisher(names, upstreamJokes, *publishInterval, logger)
+		// Every replica publishes for its own clients, but only one posts to Teams
+		if teamsHook != nil {
+			flusher(func() {
+				lead(ctx, shared, "teams", func(ctx context.Context) { postPublished(ctx, publisher, teamsHook, logger) }, logger)
+			})
+		}
+		go publisher.Run(ctx)
+		opts = append(opts, server.WithPublisher(publisher))
+	}
+	if adminValid != nil {
+		opts = append(opts, server.WithAdminAuth(adminValid))
+	}
+	if keys != nil {
+		opts = append(opts, server.WithKeyStore(keys))
+	}
+	var jokeCache cache.Cache
+	var diskCache *cache.Disk
+	if *cacheFile != "" {
+		diskCache, err = cache.OpenDisk(*cacheFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-cache-file:", err)
+			os.Exit(2)
+		}
+		// Waited for so the last periodic save is done before the cache is closed
+		flusher(func() { flushCache(ctx, diskCache, logger) })
+		jokeCache = diskCache
+		opts = append(opts, server.WithCache(diskCache))
+	}
+	// Don't fall back to a cached joke the blocklist blocks, at startup or
+	// once new entries are loaded
+	if blocklist != nil {
+		dropBlocked := func() {
+			if jokeCache == nil {
+				return
+			}
+			if cached, ok := jokeCache.Get(joke.FallbackKey); ok && blocklist.Blocked(string(cached)) {
+				jokeCache.Delete(joke.FallbackKey)
+			}
+		}
+		dropBlocked()
+		blocklist.OnReload(dropBlocked)
+		go blocklist.Watch(ctx, reloadInterval)
+	}
+	if *tenantsPath != "" {
+		tenants, err := tenant.Load(*tenantsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-tenants:", err)
+			os.Exit(2)
+		}
+		opts = append(opts, server.WithTenants(tenants, func(secret string) string { return keyID(keys, secret) }))
+	}
+	if *oidcIssuer != "" {
+		opts = append(opts, server.WithLogin(newLogin(*oidcIssuer, *oidcClientID, *oidcRedirect, logger)))
+	}
+	if submissions != nil {
+		opts = append(opts, server.WithSubmissions(submissions))
+	}
+	// Share the reveal key between replicas and restarts
+	if secret := os.Getenv("REVEAL_SECRET"); secret != "" {
+		opts = append(opts, server.WithRevealSecret([]byte(secret)))
+	}
+	// Report ready once names and jokes are warmed up, serving meanwhile,
+	// and not ready again once draining
+	var ready, draining atomic.Bool
+	opts = append(opts, server.WithReadiness(func() bool { return ready.Load() && !draining.Load() }))
+	// Drain on SIGTERM or SIGINT, when an admin asks to or when the service is stopped
+	quit := make(chan struct{})
+	var quitOnce sync.Once
+	closeQuit := func() { quitOnce.Do(func() { close(quit) }) }
+	opts = append(opts, server.WithQuit(closeQuit))
+	if serviceStop != nil {
+		go func() {
+			<-serviceStop
+			closeQuit()
+		}()
+	}
+	app := server.NewServer(opts...)
+	srv := app.HTTPServer()
+	var grpcSrv *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-grpc-addr:", err)
+			os.Exit(2)
+		}
+		grpcSrv = app.GRPCServer(grpcOpts...)
+		logger.Info("listening for gRPC", "addr", lis.Addr().String())
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				logger.Error("gRPC server stopped", "error", err)
+			}
+		}()
+	}
+	go func() {
+		warmUp(ctx, names, upstreamJokes, jokeCache, *warmNames, *warmJokes, *warmTimeout, logger)
+		ready.Store(true)
+	}()
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	drained := make(chan struct{})
+	go func() {
+		drain(signals, quit, srv, grpcSrv, &draining, *shutdownDelay, *shutdownTimeout, logger)
+		close(drained)
+	}()
 
 	// Start server with parameters configured above for server
-	err := server.ListenAndServe()
+	logger.Info("listening", "addr", *addr)
+	err = srv.ListenAndServe()
 
 	// Handle ErrServerClosed error
 	if !errors.Is(err, http.ErrServerClosed) {
 		fmt.Printf("error running http server: %s\n", err)
+		return
+	}
+	// ListenAndServe returns as the drain starts; wait for it to finish
+	<-drained
+	// Stop the background work, waiting for it to flush
+	stop()
+	flushing.Wait()
+
+	// Save what changed since the last periodic flush
+	if diskCache != nil {
+		if err := diskCache.Close(); err != nil {
+			logger.Error("could not save cache", "error", err)
+		}
+	}
+	if keys != nil {
+		if err := keys.Flush(); err != nil {
+			logger.Error("could not save API key usage", "error", err)
+		}
 	}
+	logger.Info("shut down")
 }
 
 /*
-	 Function to return random first and last name.
+	 Function to shut srv down gracefully once ctx is done or quit is
+	 closed
 
-		Calls external web service:
-			"https://names.mcquay.me/api/v0/"
-
-		Returns Names struct
+		Readiness turns false first and stays so for delay, giving
+		load balancers time to stop routing here, e.g. Kubernetes
+		removing the pod from its endpoints. Then srv, and g unless
+		it's nil, stop accepting connections and wait up to timeout
+		for in-flight requests and calls.
 */
-var getRandomName = func() (Names, error) {
-	// Parse randNameEndpoint into a URL structure
-	base, err := url.Parse(randNameEndpoint)
-	// Handle errors while parsing
-	if err != nil {
-		return Names{}, fmt.Errorf("client could not parse url: %s", err)
-	}
-	// Create the GET request
-	req, err := http.NewRequest(http.MethodGet, base.String(), nil)
-	// Handle errors creating request
-	if err != nil {
-		return Names{}, fmt.Errorf("client could not create request: %s", err)
+func drain(ctx context.Context, quit <-chan struct{}, srv *http.Server, g *grpc.Server, draining *atomic.Bool, delay, timeout time.Duration, logger *slog.Logger) {
+	select {
+	case <-ctx.Done():
+	case <-quit:
 	}
-	// Timeout if request takes longer than 30 seconds
-	client := http.Client{
-		Timeout: 30 * time.Second,
-	}
-	// Make the request
-	res, err := client.Do(req)
-	// Handle errors while making request
-	if err != nil {
-		return Names{}, fmt.Errorf("client: error making http request: %s", err)
-	}
-	// Print client message and status code for debugging
-	fmt.Printf("client: got response!\n")
-	fmt.Printf("client: status code: %d\n", res.StatusCode)
-	// Read the response body
-	resBody, err := io.ReadAll(res.Body)
-	// Handle errors while reading response body
-	if err != nil {
-		return Names{}, fmt.Errorf("client: could not read response body: %s", err)
+	draining.Store(true)
+	logger.Info("draining", "delay", delay, "timeout", timeout)
+	time.Sleep(delay)
+
+	// Drain gRPC calls alongside the HTTP requests
+	var grpcDrained sync.WaitGroup
+	if g != nil {
+		grpcDrained.Add(1)
+		go func() {
+			defer grpcDrained.Done()
+			stopGRPC(g, timeout, logger)
+		}()
 	}
-	// Initialize struct to hold return values
-	var n Names
-	// Verify response body is valid JSON
-	if json.Valid(resBody) {
-		// Unmarshal JSON in resBody and initialize struct Names with data
 
-		// Handle errors while unmarshalling resBody JSON and exit program
-		if err := json.Unmarshal(resBody, &n); err != nil {
-			return Names{}, fmt.Errorf("error unmarshalling JSON: %s", err)
-		}
-		// If not valid JSON, handle error and print body
-		//	does not cause failure state
-	} else {
-		return Names{}, fmt.Errorf("non-JSON response received: %s", string(resBody))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Handle requests still running at the timeout; their connections are closed
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("could not drain connections", "error", err)
+		srv.Close()
 	}
-	// Return Names struct
-	return n, err
+	grpcDrained.Wait()
+}
+
+// Function to build the login handler for an OIDC issuer, keeping at most maxSessions sessions in memory
+func newLogin(issuer, clientID, redirectURL string, logger *slog.Logger) *auth.Handler {
+	sessions := session.NewManager(cache.NewLRU(maxSessions))
+	sessions.Secure = strings.HasPrefix(redirectURL, "https://")
+	o := auth.NewOIDC(auth.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+	})
+	return auth.NewHandler(o, sessions, logger)
 }
 
 /*
-	 Function to return random Chuck Norris joke
+	 Function to return the middleware checking every request's API key
 
-		Accepts firstName and lastName as arguments
-		and calls external web service:
-			"http://joke.loc8u.com:8888/joke?limitTo=nerdy"
+		Routes need a static key or a managed key with the jokes scope.
+		/admin routes, under a tenant's /t/{id} prefix or not, also let
+		admin keys through, to be checked again by WithAdminAuth.
+*/
+func requireKey(staticValid, adminValid func(string) bool, keys *apikey.Store) middleware.Middleware {
+	valid := jokeKeys(staticValid, keys)
+	validAdmin := func(key string) bool {
+		return valid(key) || adminValid != nil && adminValid(key)
+	}
 
-		Passes firstName and lastName in the query string to
-		personalize the joke being returned.
+	return func(next http.Handler) http.Handler {
+		routes, admin := middleware.APIKey(valid)(next), middleware.APIKey(validAdmin)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAdminPath(r.URL.Path) {
+				admin.ServeHTTP(w, r)
+				return
+			}
+			routes.ServeHTTP(w, r)
+		})
+	}
+}
 
-		Returns Joke struct
-*/
-var getRandomJoke = func(firstName, lastName string) (string, error) {
-	// Parse randJokeBaseEndpoint into a URL structure
-	base, err := url.Parse(randJokeBaseEndpoint)
+// Function to return a validator accepting static keys and managed keys with the jokes scope
+func jokeKeys(staticValid func(string) bool, keys *apikey.Store) func(string) bool {
+	var managedValid func(string) bool
+	if keys != nil {
+		managedValid = keys.Validator(apikey.ScopeJokes)
+	}
+	return func(key string) bool {
+		if staticValid != nil && staticValid(key) {
+			return true
+		}
+		return managedValid != nil && managedValid(key)
+	}
+}
 
-	// Handle errors while parsing url
-	if err != nil {
-		return "", fmt.Errorf("client could not parse url: %s", err)
+// Function to report whether path is of an /admin route, with or without a tenant prefix
+func isAdminPath(path string) bool {
+	if rest, ok := strings.CutPrefix(path, tenant.PathPrefix); ok {
+		_, rest, _ = strings.Cut(rest, "/")
+		path = "/" + rest
 	}
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
 
-	// Initialize Values map 'params'
-	params := url.Values{}
+// Function to return the ID of the key secret, for metering and tenants; "" without a key
+func keyID(keys *apikey.Store, secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if keys != nil {
+		if k, ok := keys.Authenticate(secret, ""); ok {
+			return k.ID
+		}
+	}
+	return apikey.Fingerprint(secret)
+}
 
-	// Add the firstName and lastName to params
-	params.Add("firstName", firstName)
-	params.Add("lastName", lastName)
+/*
+	 Function to roll up and save API key usage every flushInterval
+	 until ctx is done
 
-	// Encode and add query string values to base URL
-	base.RawQuery = params.Encode()
+		Rolling closes finished days and months, resetting quotas.
+*/
+func maintainKeys(ctx context.Context, keys *apikey.Store, logger *slog.Logger) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keys.Roll()
+			// Handle errors while saving usage; it is retried on the next tick
+			if err := keys.Flush(); err != nil {
+				logger.Error("could not save API key usage", "error", err)
+			}
+		}
+	}
+}
 
-	// Create the GET request
-	req, err := http.NewRequest(http.MethodGet, base.String(), nil)
+/*
+	 Function to post the jokes p publishes to Teams until ctx ends
 
-	// Handle errors while creating the request and exit program
-	if err != nil {
-		return "", fmt.Errorf("client could not create request: %s", err)
+		Starts with the latest joke, if any, then posts each new one;
+		jokes published while a post is in flight are skipped for the
+		latest.
+*/
+func postPublished(ctx context.Context, p *joke.Publisher, w *teams.Webhook, logger *slog.Logger) {
+	var after int64
+	for {
+		j, err := p.Next(ctx, after)
+		if err != nil {
+			return
+		}
+		after = j.ID
+		postToTeams(w, j, logger)
 	}
+}
 
-	// Timeout if request takes longer than 30 seconds
-	client := http.Client{
-		Timeout: 30 * time.Second,
+// Function to post a published joke to Teams, logging failures
+func postToTeams(w *teams.Webhook, j joke.Published, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), teamsPostTimeout)
+	defer cancel()
+	if err := w.Post(ctx, j); err != nil {
+		logger.ErrorContext(ctx, "failed to post joke to Teams", "id", j.ID, "error", err)
 	}
+}
 
-	// Make the request
-	res, err := client.Do(req)
+/*
+	 Function to run job until ctx ends, on one replica at a time when
+	 shared is not nil
 
-	// Handle errors while making request and exit program
-	if err != nil {
-		return "", fmt.Errorf("client: error making http request: %s", err)
+		Replicas sharing Redis take turns holding the lease on name;
+		job runs while this one holds it and is cancelled if it loses
+		it, so a scheduled job publishes once per cluster rather than
+		once per replica. Without Redis job simply runs.
+*/
+func lead(ctx context.Context, shared *redis.Client, name string, job func(ctx context.Context), logger *slog.Logger) {
+	if shared == nil {
+		job(ctx)
+		return
 	}
+	redis.NewLease(shared, "lease:"+name, leaseTTL).Lead(ctx, job, func(err error) {
+		logger.Error("could not renew lease", "job", name, "error", err)
+	})
+}
 
-	// Print client message and status code for debugging
-	fmt.Printf("client: got response!\n")
-	fmt.Printf("client: status code: %d\n", res.StatusCode)
-
-	// Read the response body
-	resBody, err := io.ReadAll(res.Body)
+/*
+	 Function to purge entries older than retention from h every
+	 purgeInterval, and once at the start, until ctx ends
 
-	// Handle errors while reading response body and exit program
-	if err != nil {
-		return "", fmt.Errorf("client: could not read response body: %s", err)
+		Purged entries are counted in reg.
+*/
+func purgeHistory(ctx context.Context, h *history.Store, retention time.Duration, reg *metrics.Registry, logger *slog.Logger) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		n := h.Purge(time.Now().Add(-retention))
+		reg.ObservePurge("history", n)
+		if n > 0 {
+			logger.Info("purged history", "entries", n, "retention", retention)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	// Initialize new Joke struct
-	var j Joke
+/*
+	 Function to probe names and jokes every interval, each probe
+	 limited to timeout, until ctx ends
 
-	// Unmarshal JSON in resBody and initialize struct Names with data
-	if err := json.Unmarshal(resBody, &j); err != nil {
-		// Handle errors while unmarshalling resBody JSON and exit program
-		return "", fmt.Errorf("error unmarshalling JSON: %s", err)
+		Results are recorded in reg; failures are logged too.
+*/
+func selfCheckLoop(ctx context.Context, names joke.NameProvider, jokes joke.JokeProvider, interval, timeout time.Duration, reg *metrics.Registry, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			start := time.Now()
+			err := joke.Probe(probeCtx, names, jokes)
+			cancel()
+			reg.ObserveProbe(time.Since(start), err)
+			// Handle a failed probe; users may be seeing the same failure
+			if err != nil {
+				logger.Warn("self-check failed", "error", err, "duration", time.Since(start))
+			}
+		}
+	}
+}
+
+// Function to save c to its file every flushInterval until ctx ends
+func flushCache(ctx context.Context, c *cache.Disk, logger *slog.Logger) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Handle errors while saving; it is retried on the next tick
+			if err := c.Flush(); err != nil {
+				logger.Error("could not save cache", "error", err)
+			}
+		}
 	}
+}
 
-	// Return joke string from Joke struct
-	return j.Value.Joke, nil
+/*
+	 Function to list the members of a directory name source and keep
+	 them fresh in the background until ctx ends
+
+		A failed first listing is logged and retried at the next
+		refresh; names fail until one succeeds.
+*/
+func startDirectory(ctx context.Context, src directory.Source, refresh time.Duration, logger *slog.Logger) *directory.Names {
+	names := directory.New(src, refresh, logger)
+	if err := names.Refresh(ctx); err != nil {
+		logger.Error("directory: could not list members", "error", err)
+	}
+	go names.Run(ctx)
+	return names
 }
 
 /*
-	 Function writes the joke string to http.ResponseWriter
+	 Function to buffer names and fetch jokes ahead of the first
+	 requests, giving up after timeout
 
-		Accepts a string and Writer
+		Failures are logged; the server is ready regardless, as it can
+		serve without warming up, only slower.
 */
-var returnCompleteJoke = func(joke string, w http.ResponseWriter) {
-	// Write joke string
-	_, err := io.WriteString(w, joke)
+func warmUp(ctx context.Context, names *joke.NamePrefetcher, jokes joke.JokeProvider, c cache.Cache, nameCount, jokeCount int, timeout time.Duration, logger *slog.Logger) {
+	if nameCount <= 0 && jokeCount <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
 
-	// Handle errors while writing response
+	// Fetch the jokes first, so the names they take are refilled while waiting
+	fetched, err := joke.Warm(ctx, names, jokes, c, jokeCount)
 	if err != nil {
-		http.Error(w, "failed to write response", http.StatusInternalServerError)
-		return
+		logger.Warn("warm-up: failed to fetch jokes", "fetched", fetched, "wanted", jokeCount, "error", err)
+	}
+	// Handle a warm-up that ran out of time
+	if err := names.Wait(ctx, nameCount); err != nil {
+		logger.Warn("warm-up: names not buffered in time", "error", err)
 	}
+	logger.Info("warm-up done", "jokes", fetched, "duration", time.Since(start))
 }
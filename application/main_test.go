@@ -1,227 +1,126 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"strings"
-	"sync"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/teams"
 )
 
-func TestGetRoot(t *testing.T) {
-	// Save and restore original function implementations
-	originalGetRandomName := getRandomName
-	originalGetRandomJoke := getRandomJoke
-	defer func() {
-		getRandomName = originalGetRandomName
-		getRandomJoke = originalGetRandomJoke
-	}()
-	// Mock getRandomName to return a predefined value
-	getRandomName = func() (Names, error) {
-		return Names{FirstName: "John", LastName: "Doe"}, nil
+func TestRequireKey(t *testing.T) {
+	t.Parallel()
+
+	keys, err := apikey.Open(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
 	}
-	// Mock getRandomJoke to return predefined value
-	getRandomJoke = func(firstName, lastName string) (string, error) {
-		return "Mocked joke about John Doe", nil
+	_, jokesKey, err := keys.Create("app", []string{apikey.ScopeJokes}, time.Time{}, apikey.Quota{})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
 	}
-
-	t.Run("Returns 200 status code", func(t *testing.T) {
-
-		// Create a request to pass to the handler
-		req, err := http.NewRequest(http.MethodGet, "/", nil)
-		// Handle error while creating response
-		if err != nil {
-			t.Fatalf("Could not create request: %v", err)
-		}
-
-		// Record response
-		rec := httptest.NewRecorder()
-
-		// Initialize handler
-		handler := http.HandlerFunc(getRoot)
-
-		// Call the handler
-		handler.ServeHTTP(rec, req)
-
-		// Check the status code for 200
-		if rec.Code != http.StatusOK {
-			t.Errorf("Expected status OK; got %v", rec.Code)
-		}
-	})
-
-	t.Run("Returns expected string", func(t *testing.T) {
-
-		// Create a request to pass to the handler
-		req, err := http.NewRequest(http.MethodGet, "/", nil)
-
-		// Handle error while creating the request
-		if err != nil {
-			t.Fatalf("Could not create request: %v", err)
-		}
-
-		// Record response
+	_, adminKey, err := keys.Create("ops", []string{apikey.ScopeAdmin}, time.Time{}, apikey.Quota{})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	adminValid := func(key string) bool {
+		_, ok := keys.Authenticate(key, apikey.ScopeAdmin)
+		return ok
+	}
+	handler := requireKey(middleware.StaticKeys("static"), adminValid, keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name string
+		path string
+		key  string
+		want int
+	}{
+		{"Static key", "/jokes", "static", http.StatusOK},
+		{"Jokes key", "/jokes", jokesKey, http.StatusOK},
+		{"Admin key without jokes scope", "/jokes", adminKey, http.StatusUnauthorized},
+		{"Admin key under a tenant", "/t/acme/history", adminKey, http.StatusUnauthorized},
+		{"Admin key on admin route", "/admin/keys", adminKey, http.StatusOK},
+		{"Admin key on tenant admin route", "/t/acme/admin/keys", adminKey, http.StatusOK},
+		{"Unknown key", "/jokes", "forged", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-API-Key", tt.key)
 		rec := httptest.NewRecorder()
-
-		// Initialize handler
-		handler := http.HandlerFunc(getRoot)
-
-		// Call the handler
 		handler.ServeHTTP(rec, req)
-
-		// String expected from the response
-		expected := "Mocked joke about John Doe"
-
-		// Verify expected string is in the response body
-		if !strings.Contains(rec.Body.String(), expected) {
-			t.Errorf("Expected response body to contain %q; got %q", expected, rec.Body.String())
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d; got %d", tt.name, tt.want, rec.Code)
 		}
-	})
+	}
 }
 
-func TestGetRootFailures(t *testing.T) {
-	// Save and restore original function implementations
-	originalGetRandomName := getRandomName
-	originalGetRandomJoke := getRandomJoke
-	defer func() {
-		getRandomName = originalGetRandomName
-		getRandomJoke = originalGetRandomJoke
-	}()
-
-	t.Run("getRandomName failure", func(t *testing.T) {
-		// Mock getRandomName to return an error
-		getRandomName = func() (Names, error) {
-			return Names{}, fmt.Errorf("failed to fetch name")
+func TestPostPublished(t *testing.T) {
+	t.Parallel()
+
+	posts := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Attachments []struct {
+				Content struct {
+					Body []struct {
+						Text string `json:"text"`
+					} `json:"body"`
+				} `json:"content"`
+			} `json:"attachments"`
 		}
-
-		// Create a request to pass to the handler
-		req, err := http.NewRequest(http.MethodGet, "/", nil)
-		if err != nil {
-			t.Fatalf("Could not create request: %v", err)
+		if err := json.Unmarshal(body, &msg); err != nil || len(msg.Attachments) == 0 || len(msg.Attachments[0].Content.Body) < 2 {
+			t.Errorf("Expected an Adaptive Card; got %s", body)
+			return
 		}
+		posts <- msg.Attachments[0].Content.Body[1].Text
+	}))
+	t.Cleanup(srv.Close)
+
+	p := joke.NewPublisher(nil, nil, time.Hour, nil)
+	p.Publish(joke.Published{Joke: "First joke"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		postPublished(ctx, p, &teams.Webhook{URL: srv.URL, Client: srv.Client()}, slog.Default())
+	}()
 
-		// Record response
-		rec := httptest.NewRecorder()
-
-		// Initialize the handler
-		handler := http.HandlerFunc(getRoot)
-
-		// Call the handler
-		handler.ServeHTTP(rec, req)
-
-		// Verify status code is 500
-		if rec.Code != http.StatusInternalServerError {
-			t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
-		}
-	})
-
-	t.Run("getRandomJoke failure", func(t *testing.T) {
-		// Mock getRandomName
-		getRandomName = func() (Names, error) {
-			return Names{FirstName: "John", LastName: "Doe"}, nil
-		}
-
-		// Mock and simulate a failed call to getRandomJoke
-		getRandomJoke = func(firstName, lastName string) (string, error) {
-			return "", fmt.Errorf("failed to fetch joke")
-		}
-
-		// Create a request to pass to the handler
-		req, err := http.NewRequest(http.MethodGet, "/", nil)
-		if err != nil {
-			t.Fatalf("Could not create request: %v", err)
-		}
-
-		// Record response
-		rec := httptest.NewRecorder()
-
-		// Initialize the handler
-		handler := http.HandlerFunc(getRoot)
-
-		// Call the handler
-		handler.ServeHTTP(rec, req)
-
-		// Verify status code is 500
-		if rec.Code != http.StatusInternalServerError {
-			t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
-		}
-	})
-}
-
-func TestServerLoad(t *testing.T) {
-
-	const (
-		concurrentRequests = 100  // Number of concurrent requests
-		totalRequests      = 1000 // Total requests to send
-	)
-
-	// WaitGroup to wait for all requests to completed
-	var wg sync.WaitGroup
-
-	// Handler for testing
-	handler := http.HandlerFunc(getRoot)
-
-	// Channel to collect responses
-	responses := make(chan int, totalRequests)
-
-	// Channel to collect any errors
-	errors := make(chan error, totalRequests)
-
-	// Semaphore to control the number of concurrent requests
-	semaphore := make(chan struct{}, concurrentRequests)
-
-	// make a request to the server for
-	for i := 0; i < totalRequests; i++ {
-		// Add to the wait group
-		wg.Add(1)
-
-		// Acquire a slot in the semaphore
-		semaphore <- struct{}{}
-
-		go func() {
-			defer wg.Done()
-			defer func() { <-semaphore }() // Release slot in semaphore
-			// Create a request
-			req, err := http.NewRequest(http.MethodGet, "/", nil)
-			if err != nil {
-				errors <- err
-				return
-			}
-			// Record the response
-			rec := httptest.NewRecorder()
-
-			// Server the request
-			handler.ServeHTTP(rec, req)
-
-			// Send status code to the responses channel
-			responses <- rec.Code
-
-			// Check for a 200 OK response
-			if rec.Code != http.StatusOK {
-				errors <- fmt.Errorf("expected status 200, got %d", rec.Code)
+	wait := func(want string) {
+		t.Helper()
+		select {
+		case got := <-posts:
+			if got != want {
+				t.Errorf("Expected %q posted; got %q", want, got)
 			}
-		}()
-	}
-	// Wait for all requests to complete
-	wg.Wait()
-
-	// Close responses and errors channels
-	close(responses)
-	close(errors)
-
-	// Ensure semaphore is empty
-	for i := 0; i < concurrentRequests; i++ {
-		semaphore <- struct{}{}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %q posted; got nothing", want)
+		}
 	}
-	// Close semaphore channel
-	close(semaphore)
-
-	// Check the length of responses to ensure no failures
-	if len(responses) < totalRequests {
-		t.Errorf("Some requests were unsuccesssful: %d requests made of %v", len(responses), totalRequests)
+	wait("First joke")
+	p.Publish(joke.Published{Joke: "Second joke"})
+	wait("Second joke")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected postPublished to return once cancelled")
 	}
-	// Collect errors
-	if len(errors) > 0 {
-		t.Errorf("Some requests failed: %d errors", len(errors))
+	select {
+	case got := <-posts:
+		t.Errorf("Expected no more posts; got %q", got)
+	default:
 	}
 }
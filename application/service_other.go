@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// Returned for -service on platforms without Windows services
+var errNoService = errors.New("service: -service is only supported on Windows")
+
+// Function to run -service install or uninstall, only supported on Windows
+func serviceCommand(mode string) (bool, error) {
+	if mode == "install" || mode == "uninstall" {
+		return true, errNoService
+	}
+	return false, nil
+}
+
+// Function to run under the Windows service control manager, only supported on Windows
+func runService() (io.Writer, <-chan struct{}, func(), error) {
+	return nil, nil, nil, errNoService
+}
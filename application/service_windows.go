@@ -0,0 +1,345 @@
+//go:build windows
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Name the service and its event log source are registered under
+const (
+	serviceName        = "joke-generator"
+	serviceDisplayName = "Joke Generator"
+)
+
+// Message file whose single %1 message renders any text, so events need no message DLL of our own
+const eventMessageFile = `%SystemRoot%\System32\EventCreate.exe`
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+	procOpenSCManagerW               = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW               = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                 = advapi32.NewProc("OpenServiceW")
+	procDeleteService                = advapi32.NewProc("DeleteService")
+	procCloseServiceHandle           = advapi32.NewProc("CloseServiceHandle")
+	procRegisterEventSourceW         = advapi32.NewProc("RegisterEventSourceW")
+	procReportEventW                 = advapi32.NewProc("ReportEventW")
+	procRegCreateKeyExW              = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW               = advapi32.NewProc("RegSetValueExW")
+	procRegDeleteKeyW                = advapi32.NewProc("RegDeleteKeyW")
+)
+
+// Windows API constants used below
+const (
+	scManagerAllAccess     = 0xF003F
+	serviceAllAccess       = 0xF01FF
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+	deleteAccess           = 0x10000
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop     = 1
+	serviceAcceptShutdown = 4
+
+	controlStop        = 1
+	controlInterrogate = 4
+	controlShutdown    = 5
+
+	errorCallNotImplemented = 120
+
+	eventError       = 1
+	eventWarning     = 2
+	eventInformation = 4
+
+	keyWrite     = 0x20006
+	regExpandSz  = 2
+	regDword     = 4
+	eventLogKey  = `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + serviceName
+	hklm         = uintptr(syscall.HKEY_LOCAL_MACHINE)
+	maxEventSize = 31839
+)
+
+// struct to hold a SERVICE_STATUS
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// struct to hold a SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	ServiceName *uint16
+	ServiceProc uintptr
+}
+
+/*
+	 Function to run -service install or uninstall, reporting whether
+	 mode was one of them
+
+		install registers the service to start automatically with the
+		flags given alongside -service, plus its event log source;
+		both need an elevated prompt.
+*/
+func serviceCommand(mode string) (bool, error) {
+	switch mode {
+	case "install":
+		return true, installService()
+	case "uninstall":
+		return true, uninstallService()
+	}
+	return false, nil
+}
+
+// Function to register the service to run this executable with the current flags
+func installService() error {
+	exe, err := os.Executable()
+	// Handle errors while finding the executable
+	if err != nil {
+		return fmt.Errorf("service: could not find executable: %w", err)
+	}
+	args := []string{syscall.EscapeArg(exe), "-service=run"}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "service" {
+			args = append(args, syscall.EscapeArg("-"+f.Name+"="+f.Value.String()))
+		}
+	})
+
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(scm)
+
+	h, _, err := procCreateServiceW.Call(scm,
+		utf16Ptr(serviceName), utf16Ptr(serviceDisplayName),
+		serviceAllAccess, serviceWin32OwnProcess, serviceAutoStart, serviceErrorNormal,
+		utf16Ptr(strings.Join(args, " ")), 0, 0, 0, 0, 0)
+	// Handle errors while creating the service, e.g. it already exists
+	if h == 0 {
+		return fmt.Errorf("service: could not install %s: %w", serviceName, err)
+	}
+	procCloseServiceHandle.Call(h)
+
+	// Register the event log source, so events render without a missing-message warning
+	if err := installEventSource(); err != nil {
+		return err
+	}
+	fmt.Printf("installed service %s: %s\n", serviceName, strings.Join(args, " "))
+	return nil
+}
+
+// Function to remove the service and its event log source
+func uninstallService() error {
+	scm, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(scm)
+
+	h, _, err := procOpenServiceW.Call(scm, utf16Ptr(serviceName), deleteAccess)
+	// Handle errors while opening the service, e.g. it isn't installed
+	if h == 0 {
+		return fmt.Errorf("service: could not open %s: %w", serviceName, err)
+	}
+	defer procCloseServiceHandle.Call(h)
+	if ok, _, err := procDeleteService.Call(h); ok == 0 {
+		return fmt.Errorf("service: could not uninstall %s: %w", serviceName, err)
+	}
+	// A missing source isn't worth failing the uninstall for
+	procRegDeleteKeyW.Call(hklm, utf16Ptr(eventLogKey))
+	fmt.Printf("uninstalled service %s\n", serviceName)
+	return nil
+}
+
+// Function to connect to the service control manager
+func openSCManager() (uintptr, error) {
+	scm, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if scm == 0 {
+		return 0, fmt.Errorf("service: could not connect to the service control manager: %w", err)
+	}
+	return scm, nil
+}
+
+// Function to register serviceName as an Application event log source
+func installEventSource() error {
+	var key syscall.Handle
+	if rc, _, _ := procRegCreateKeyExW.Call(hklm, utf16Ptr(eventLogKey), 0, 0, 0, keyWrite, 0, uintptr(unsafe.Pointer(&key)), 0); rc != 0 {
+		return fmt.Errorf("service: could not register event source: %w", syscall.Errno(rc))
+	}
+	defer syscall.RegCloseKey(key)
+
+	file, _ := syscall.UTF16FromString(eventMessageFile)
+	if rc, _, _ := procRegSetValueExW.Call(uintptr(key), utf16Ptr("EventMessageFile"), 0, regExpandSz,
+		uintptr(unsafe.Pointer(&file[0])), uintptr(len(file)*2)); rc != 0 {
+		return fmt.Errorf("service: could not register event source: %w", syscall.Errno(rc))
+	}
+	types := uint32(eventError | eventWarning | eventInformation)
+	if rc, _, _ := procRegSetValueExW.Call(uintptr(key), utf16Ptr("TypesSupported"), 0, regDword,
+		uintptr(unsafe.Pointer(&types)), 4); rc != 0 {
+		return fmt.Errorf("service: could not register event source: %w", syscall.Errno(rc))
+	}
+	return nil
+}
+
+/*
+windowsService is the state shared with the callbacks the service
+control manager calls on its own threads
+*/
+type windowsService struct {
+	handle uintptr
+	// Closed when the service control manager asks the service to stop
+	stop     chan struct{}
+	stopOnce sync.Once
+	// Closed by the stopped func runService returns, letting serviceMain return
+	exited  chan struct{}
+	started chan struct{}
+}
+
+// The running service; callbacks can't carry Go state
+var current *windowsService
+
+/*
+	 Function to run under the service control manager, for
+	 -service run
+
+		Returns a writer logging to the event log, a channel closed
+		when the service is asked to stop, and a func to call once the
+		server has shut down. Fails when not started as a service,
+		e.g. from a console.
+*/
+func runService() (io.Writer, <-chan struct{}, func(), error) {
+	logs, err := openEventLog()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	current = &windowsService{stop: make(chan struct{}), exited: make(chan struct{}), started: make(chan struct{})}
+
+	failed := make(chan error, 1)
+	go func() {
+		table := []serviceTableEntry{
+			{ServiceName: utf16PtrOf(serviceName), ServiceProc: syscall.NewCallback(serviceMain)},
+			{},
+		}
+		// Blocks until the service has stopped
+		if ok, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); ok == 0 {
+			failed <- fmt.Errorf("service: could not start dispatcher, -service run is for the service control manager: %w", err)
+		}
+	}()
+	select {
+	case err := <-failed:
+		return nil, nil, nil, err
+	case <-current.started:
+	}
+
+	var once sync.Once
+	stopped := func() {
+		once.Do(func() {
+			setServiceStatus(serviceStopped, 0)
+			close(current.exited)
+		})
+	}
+	return logs, current.stop, stopped, nil
+}
+
+// Function called by the service control manager when the service starts
+func serviceMain(argc, argv uintptr) uintptr {
+	h, _, _ := procRegisterServiceCtrlHandlerEx.Call(utf16Ptr(serviceName), syscall.NewCallback(serviceControl), 0)
+	current.handle = h
+	setServiceStatus(serviceStartPending, 0)
+	setServiceStatus(serviceRunning, serviceAcceptStop|serviceAcceptShutdown)
+	close(current.started)
+
+	<-current.exited
+	return 0
+}
+
+// Function called by the service control manager for each control request
+func serviceControl(control, eventType, eventData, context uintptr) uintptr {
+	switch control {
+	case controlStop, controlShutdown:
+		setServiceStatus(serviceStopPending, 0)
+		current.stopOnce.Do(func() { close(current.stop) })
+		return 0
+	case controlInterrogate:
+		return 0
+	}
+	return errorCallNotImplemented
+}
+
+// Function to report the service's state to the service control manager
+func setServiceStatus(state, accepts uint32) {
+	status := serviceStatus{ServiceType: serviceWin32OwnProcess, CurrentState: state, ControlsAccepted: accepts}
+	if state == serviceStopPending || state == serviceStartPending {
+		// Time the service control manager waits before assuming we hung, in ms
+		status.WaitHint = 60000
+	}
+	procSetServiceStatus.Call(current.handle, uintptr(unsafe.Pointer(&status)))
+}
+
+// eventLog writes each line logged to the Application event log
+type eventLog struct {
+	handle uintptr
+}
+
+// Function to open the event log under serviceName
+func openEventLog() (*eventLog, error) {
+	h, _, err := procRegisterEventSourceW.Call(0, utf16Ptr(serviceName))
+	if h == 0 {
+		return nil, fmt.Errorf("service: could not open event log: %w", err)
+	}
+	return &eventLog{handle: h}, nil
+}
+
+// Write reports p as one event, typed by the slog level in it
+func (l *eventLog) Write(p []byte) (int, error) {
+	line := strings.TrimSpace(string(p))
+	kind := uint16(eventInformation)
+	switch {
+	case strings.Contains(line, "level=ERROR"):
+		kind = eventError
+	case strings.Contains(line, "level=WARN"):
+		kind = eventWarning
+	}
+	if len(line) > maxEventSize {
+		line = line[:maxEventSize]
+	}
+	msg, err := syscall.UTF16PtrFromString(strings.ReplaceAll(line, "\x00", ""))
+	if err != nil {
+		return 0, err
+	}
+	// Event ID 1 renders its single string as-is with EventCreate.exe
+	if ok, _, err := procReportEventW.Call(l.handle, uintptr(kind), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&msg)), 0); ok == 0 {
+		return 0, fmt.Errorf("service: could not write event: %w", err)
+	}
+	return len(p), nil
+}
+
+// Function to convert s, known to hold no NUL, to a UTF-16 pointer for a call
+func utf16Ptr(s string) uintptr {
+	return uintptr(unsafe.Pointer(utf16PtrOf(s)))
+}
+
+// Function to convert s, known to hold no NUL, to a UTF-16 pointer
+func utf16PtrOf(s string) *uint16 {
+	p, _ := syscall.UTF16PtrFromString(s)
+	return p
+}
@@ -0,0 +1,41 @@
+/*
+	 Package atomicfile saves files so readers, and the process after
+	 a crash, see either the old contents or the new, never a mix
+
+		Used for the JSON files stores keep their state in, e.g. API
+		keys and submissions.
+*/
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+	 WriteFile replaces the file at path with data
+
+		data is written to a temporary file beside path, synced to
+		disk and renamed over path, so a crash never leaves a
+		half-written file. The file is readable only by its owner.
+*/
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	// Remove the temporary file unless it was renamed
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
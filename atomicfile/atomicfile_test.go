@@ -0,0 +1,34 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Replaces the file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "state.json")
+		os.WriteFile(path, []byte("old"), 0o600)
+
+		if err := WriteFile(path, []byte("new")); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "new" {
+			t.Errorf("Expected %q; got %q", "new", data)
+		}
+		// The temporary file is renamed, leaving nothing else behind
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("Expected only the file; got %v", entries)
+		}
+	})
+
+	t.Run("Leaves the file when saving fails", func(t *testing.T) {
+		if err := WriteFile(filepath.Join(t.TempDir(), "missing", "state.json"), []byte("new")); err == nil {
+			t.Error("Expected an error writing to a missing directory")
+		}
+	})
+}
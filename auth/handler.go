@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/token"
+)
+
+// How long a login may take before it must be restarted
+const loginTTL = 10 * time.Minute
+
+// Cookie holding a login from Login until its Callback
+const loginCookie = "joke_login"
+
+// Session values set by Callback
+const (
+	valueSubject = "sub"
+	valueEmail   = "email"
+	valueName    = "name"
+)
+
+/*
+	 Handler serves the login routes
+
+		Signed-in users are kept in sessions from the session
+		Manager, whose middleware must run before the login routes
+		and any handler calling FromContext.
+*/
+type Handler struct {
+	oidc     *OIDC
+	sessions *session.Manager
+	logger   *slog.Logger
+	// seals login cookies, with a key made for this process
+	login *token.Sealer
+}
+
+// struct to hold a login started by Login, sealed into its cookie
+type loginClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+	Expires  int64  `json:"exp"`
+}
+
+// NewHandler returns a Handler signing users in with o and keeping them signed in with sessions
+func NewHandler(o *OIDC, sessions *session.Manager, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Handler{oidc: o, sessions: sessions, logger: logger, login: token.NewSealer(nil)}
+}
+
+// Sessions returns the Manager holding the handler's sessions
+func (h *Handler) Sessions() *session.Manager {
+	return h.sessions
+}
+
+/*
+	 Login redirects the user to the issuer to sign in
+
+		The optional next query value is the local path the user
+		returns to once signed in. The login is sealed into a
+		short-lived cookie rather than stored, so anonymous clients
+		can't fill the session store by starting logins.
+*/
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	c := loginClaims{
+		State:    token.String(32),
+		Nonce:    token.String(32),
+		Verifier: token.String(32),
+		Next:     localPath(r.URL.Query().Get("next")),
+		Expires:  time.Now().Add(loginTTL).Unix(),
+	}
+	target, err := h.oidc.AuthCodeURL(r.Context(), c.State, c.Nonce, c.Verifier)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "login failed", "error", err)
+		http.Error(w, "login is unavailable", http.StatusBadGateway)
+		return
+	}
+	sealed, err := h.login.Seal(c)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "login failed", "error", err)
+		http.Error(w, "login is unavailable", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    sealed,
+		Path:     "/auth/",
+		MaxAge:   int(loginTTL.Seconds()),
+		Secure:   h.sessions.Secure,
+		HttpOnly: true,
+		// Lax so the cookie comes back on the issuer's redirect to the callback
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// Callback completes a login started by Login and starts the user's session
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	// Handle errors returned by the issuer, e.g. the user declined
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	// The state must match the one sealed by Login in this browser's cookie
+	login, ok := h.openLogin(r, time.Now())
+	if !ok || login.State != q.Get("state") {
+		http.Error(w, "login expired or was started elsewhere, try again", http.StatusBadRequest)
+		return
+	}
+	// A login cookie is only good for one attempt
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/auth/", MaxAge: -1, Secure: h.sessions.Secure, HttpOnly: true})
+
+	id, err := h.oidc.Exchange(r.Context(), q.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "login failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	// Start a fresh session so an ID set before login is never promoted
+	_, err = h.sessions.Start(w, 0, map[string]string{
+		valueSubject: id.Subject,
+		valueEmail:   id.Email,
+		valueName:    id.Name,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "login failed", "error", err)
+		http.Error(w, "login failed", http.StatusInternalServerError)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "user signed in", "sub", id.Subject)
+	http.Redirect(w, r, login.Next, http.StatusFound)
+}
+
+// Logout ends the session and redirects to /
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	h.sessions.Destroy(w, r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// FromContext returns the signed-in Identity of the request's session
+func FromContext(ctx context.Context) (Identity, bool) {
+	s, ok := session.FromContext(ctx)
+	if !ok || s.Values[valueSubject] == "" {
+		return Identity{}, false
+	}
+	return Identity{
+		Subject: s.Values[valueSubject],
+		Email:   s.Values[valueEmail],
+		Name:    s.Values[valueName],
+	}, true
+}
+
+// Subject returns the signed-in user's subject, or "" when anonymous
+func Subject(ctx context.Context) string {
+	id, _ := FromContext(ctx)
+	return id.Subject
+}
+
+/*
+	 Function to open the request's login cookie, false when missing,
+	 forged or expired
+
+		Logins in progress when the server restarts must be started
+		again, as the key sealing them is made for this process.
+*/
+func (h *Handler) openLogin(r *http.Request, now time.Time) (loginClaims, bool) {
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		return loginClaims{}, false
+	}
+	var c loginClaims
+	if err := h.login.Open(cookie.Value, &c); err != nil || c.State == "" || !now.Before(time.Unix(c.Expires, 0)) {
+		return loginClaims{}, false
+	}
+	return c, true
+}
+
+// Function to return p if it is a local path, or / otherwise, so login can't redirect off-site
+func localPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
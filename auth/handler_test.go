@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/session"
+)
+
+// Function to build a Handler signing users in with f
+func newTestHandler(f *fakeIssuer) *Handler {
+	return NewHandler(NewOIDC(f.config()), session.NewManager(cache.NewMemory()), nil)
+}
+
+// Function to serve req through the session middleware and handler h
+func serve(h *Handler, handler http.HandlerFunc, req *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.Sessions().Middleware()(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+// Function to run Login and return the redirect URL and login cookie
+func startLogin(t *testing.T, h *Handler, next string) (*url.URL, *http.Cookie) {
+	t.Helper()
+	rec := serve(h, h.Login, httptest.NewRequest(http.MethodGet, "/auth/login?next="+url.QueryEscape(next), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected redirect from login; got %d %q", rec.Code, rec.Body.String())
+	}
+	target, _ := url.Parse(rec.Header().Get("Location"))
+	return target, rec.Result().Cookies()[0]
+}
+
+// Function to call Callback with the given state and cookies
+func callback(h *Handler, state string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=good-code&state="+url.QueryEscape(state), nil)
+	return serve(h, h.Callback, req, cookies...)
+}
+
+// Function to return the identity seen by a handler for a request carrying cookies
+func identity(h *Handler, cookies ...*http.Cookie) (Identity, bool) {
+	var id Identity
+	var ok bool
+	serve(h, func(w http.ResponseWriter, r *http.Request) {
+		id, ok = FromContext(r.Context())
+	}, httptest.NewRequest(http.MethodGet, "/", nil), cookies...)
+	return id, ok
+}
+
+func TestLoginFlow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Signs user in", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		h := newTestHandler(f)
+
+		target, login := startLogin(t, h, "/history")
+		// The login cookie alone is not signed in
+		if _, ok := identity(h, login); ok {
+			t.Error("Expected login cookie to be anonymous")
+		}
+
+		// The issuer echoes the nonce from the authorization request
+		f.claims["nonce"] = target.Query().Get("nonce")
+		rec := callback(h, target.Query().Get("state"), login)
+
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/history" {
+			t.Fatalf("Expected redirect to /history; got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 2 || cookies[0].Name != loginCookie || cookies[0].MaxAge >= 0 || cookies[1].Name != session.DefaultCookieName {
+			t.Fatalf("Expected the login cookie cleared and a session cookie; got %+v", cookies)
+		}
+
+		id, ok := identity(h, cookies[1])
+		if !ok || id.Subject != "user-1" || id.Email != "grace@example.com" {
+			t.Errorf("Expected identity of user-1; got %+v", id)
+		}
+	})
+
+	t.Run("Stores nothing until signed in", func(t *testing.T) {
+		store := cache.NewMemory()
+		h := NewHandler(NewOIDC(newFakeIssuer(t).config()), session.NewManager(store), nil)
+		for range 3 {
+			startLogin(t, h, "/")
+		}
+		if entries := store.Entries(); len(entries) != 0 {
+			t.Errorf("Expected no sessions; got %+v", entries)
+		}
+	})
+
+	t.Run("Signs user out", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		h := newTestHandler(f)
+		target, login := startLogin(t, h, "/")
+		f.claims["nonce"] = target.Query().Get("nonce")
+		signedIn := callback(h, target.Query().Get("state"), login).Result().Cookies()[1]
+
+		rec := serve(h, h.Logout, httptest.NewRequest(http.MethodPost, "/auth/logout", nil), signedIn)
+
+		if rec.Code != http.StatusSeeOther {
+			t.Errorf("Expected redirect after logout; got %d", rec.Code)
+		}
+		if _, ok := identity(h, signedIn); ok {
+			t.Error("Expected session to end on logout")
+		}
+	})
+
+	t.Run("Rejects mismatched state", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		h := newTestHandler(f)
+
+		_, login := startLogin(t, h, "/")
+		if rec := callback(h, "forged", login); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %d", rec.Code)
+		}
+		if rec := callback(h, "forged"); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request without login cookie; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Rejects forged and expired login cookies", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		h := newTestHandler(f)
+
+		target, login := startLogin(t, h, "/")
+		state := target.Query().Get("state")
+		// Another process seals with its own key
+		if rec := callback(newTestHandler(f), state, login); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request for a cookie sealed elsewhere; got %d", rec.Code)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/auth/callback", nil)
+		req.AddCookie(login)
+		if _, ok := h.openLogin(req, time.Now().Add(loginTTL)); ok {
+			t.Error("Expected the login cookie to expire")
+		}
+	})
+
+	t.Run("Rejects wrong nonce", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		h := newTestHandler(f)
+
+		target, login := startLogin(t, h, "/")
+		f.claims["nonce"] = "replayed"
+		if rec := callback(h, target.Query().Get("state"), login); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Keeps redirects local", func(t *testing.T) {
+		for _, next := range []string{"https://evil.test", "//evil.test", "/\\evil.test", ""} {
+			if got := localPath(next); got != "/" {
+				t.Errorf("Expected / for %q; got %q", next, got)
+			}
+		}
+		if got := localPath("/history?page=2"); got != "/history?page=2" {
+			t.Errorf("Expected local path to be kept; got %q", got)
+		}
+	})
+}
@@ -0,0 +1,252 @@
+/*
+	 Package auth signs users in with an OpenID Connect provider and
+	 keeps them signed in with a server-side session.
+
+		Mount Handler's Login, Callback and Logout routes behind the
+		session Manager's middleware; handlers read the signed-in
+		user with FromContext.
+*/
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes requested when Config.Scopes is empty
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// ErrInvalidToken is returned when the provider's ID token fails validation
+var ErrInvalidToken = errors.New("auth: invalid ID token")
+
+// Cap on response bodies read from the provider
+const maxProviderResponseBytes = 1 << 20
+
+// struct to hold the OIDC client registration
+type Config struct {
+	// Issuer URL, e.g. https://accounts.google.com
+	Issuer string
+	// ClientID and ClientSecret registered with the issuer
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback route
+	RedirectURL string
+	// Scopes requested, defaults to DefaultScopes
+	Scopes []string
+	// Client used to call the issuer, defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+// Identity is the signed-in user
+type Identity struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// struct to hold the fields of the issuer's discovery document that are used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+/*
+	 OIDC runs the authorization code flow with PKCE against an issuer
+
+		The discovery document is fetched on first use and cached.
+*/
+type OIDC struct {
+	cfg Config
+
+	mu   sync.Mutex
+	meta *metadata
+}
+
+// NewOIDC returns an OIDC client for cfg
+func NewOIDC(cfg Config) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDC{cfg: cfg}
+}
+
+// Function to fetch and cache the issuer's discovery document
+func (o *OIDC) discover(ctx context.Context) (*metadata, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.meta != nil {
+		return o.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("auth: could not create discovery request: %w", err)
+	}
+	var meta metadata
+	if err := o.do(req, &meta); err != nil {
+		return nil, fmt.Errorf("auth: discovery failed: %w", err)
+	}
+	// The document must describe the configured issuer
+	if strings.TrimSuffix(meta.Issuer, "/") != o.cfg.Issuer {
+		return nil, fmt.Errorf("auth: discovery issuer %q does not match %q", meta.Issuer, o.cfg.Issuer)
+	}
+	o.meta = &meta
+	return o.meta, nil
+}
+
+/*
+	 AuthCodeURL returns the issuer URL the user is sent to for login
+
+		state and nonce are echoed back through the callback and ID
+		token; verifier is the PKCE code verifier later passed to
+		Exchange.
+*/
+func (o *OIDC) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("auth: could not parse authorization endpoint: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", o.cfg.ClientID)
+	q.Set("redirect_uri", o.cfg.RedirectURL)
+	q.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+/*
+	 Exchange trades an authorization code for the user's identity
+
+		The ID token is received directly from the token endpoint
+		over TLS, so per OpenID Connect Core 3.1.3.7 its issuer,
+		audience, expiry and nonce are validated in place of its
+		signature.
+*/
+func (o *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	meta, err := o.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("auth: could not create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := o.do(req, &token); err != nil {
+		return Identity{}, fmt.Errorf("auth: token exchange failed: %w", err)
+	}
+	return o.validate(token.IDToken, nonce, time.Now())
+}
+
+// struct to hold the ID token claims that are checked
+type claims struct {
+	Identity
+	Issuer   string   `json:"iss"`
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+	Nonce    string   `json:"nonce"`
+}
+
+// audience is the aud claim, which may be a string or a list of strings
+type audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Function to decode and check the claims of an ID token
+func (o *OIDC) validate(idToken, nonce string, now time.Time) (Identity, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	switch {
+	case strings.TrimSuffix(c.Issuer, "/") != o.cfg.Issuer:
+		return Identity{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, c.Issuer)
+	case !slices.Contains(c.Audience, o.cfg.ClientID):
+		return Identity{}, fmt.Errorf("%w: token not issued to this client", ErrInvalidToken)
+	case now.Unix() >= c.Expiry:
+		return Identity{}, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	case c.Nonce != nonce:
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	case c.Subject == "":
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return c.Identity, nil
+}
+
+// Function to send req and decode a successful JSON response into v
+func (o *OIDC) do(req *http.Request, v any) error {
+	res, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxProviderResponseBytes))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	 fakeIssuer is an OIDC provider serving discovery and a token
+	 endpoint
+
+		The token endpoint returns an ID token for claims, filling in
+		nonce from the authorization request when claims has none.
+*/
+type fakeIssuer struct {
+	*httptest.Server
+	claims map[string]any
+	// Code verifier received by the token endpoint
+	verifier string
+}
+
+// Function to start a fake issuer that is closed when the test ends
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	f := &fakeIssuer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 f.URL,
+				"authorization_endpoint": f.URL + "/authorize",
+				"token_endpoint":         f.URL + "/token",
+			})
+		case "/token":
+			if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" {
+				http.Error(w, "bad client", http.StatusUnauthorized)
+				return
+			}
+			if r.PostFormValue("code") != "good-code" {
+				http.Error(w, "bad code", http.StatusBadRequest)
+				return
+			}
+			f.verifier = r.PostFormValue("code_verifier")
+			json.NewEncoder(w).Encode(map[string]string{"id_token": idToken(f.claims)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	f.claims = map[string]any{
+		"iss":   f.URL,
+		"aud":   "client",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"sub":   "user-1",
+		"email": "grace@example.com",
+		"nonce": "nonce",
+	}
+	return f
+}
+
+// Function to return the OIDC config of a client registered with f
+func (f *fakeIssuer) config() Config {
+	return Config{Issuer: f.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "http://app.test/auth/callback"}
+}
+
+// Function to build an unsigned ID token holding claims
+func idToken(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	t.Parallel()
+	f := newFakeIssuer(t)
+	o := NewOIDC(f.config())
+
+	target, err := o.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	u, _ := url.Parse(target)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != "client" || q.Get("state") != "state" || q.Get("nonce") != "nonce" {
+		t.Errorf("Unexpected authorization URL: %s", target)
+	}
+	if q.Get("scope") != "openid email profile" {
+		t.Errorf("Expected default scopes; got %q", q.Get("scope"))
+	}
+	// S256 challenge of "verifier"
+	if q.Get("code_challenge") != "iMnq5o6zALKXGivsnlom_0F5_WYda32GHkxlV7mq7hQ" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("Unexpected PKCE challenge: %s", target)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Returns identity", func(t *testing.T) {
+		f := newFakeIssuer(t)
+
+		id, err := NewOIDC(f.config()).Exchange(context.Background(), "good-code", "verifier", "nonce")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if id.Subject != "user-1" || id.Email != "grace@example.com" {
+			t.Errorf("Unexpected identity: %+v", id)
+		}
+		if f.verifier != "verifier" {
+			t.Errorf("Expected code verifier to be sent; got %q", f.verifier)
+		}
+	})
+
+	t.Run("Rejects bad code", func(t *testing.T) {
+		f := newFakeIssuer(t)
+
+		if _, err := NewOIDC(f.config()).Exchange(context.Background(), "bad-code", "verifier", "nonce"); err == nil {
+			t.Error("Expected error for bad code; got nil")
+		}
+	})
+
+	t.Run("Rejects discovery for another issuer", func(t *testing.T) {
+		f := newFakeIssuer(t)
+		cfg := f.config()
+		cfg.Issuer = strings.Replace(f.URL, "127.0.0.1", "localhost", 1)
+
+		if _, err := NewOIDC(cfg).Exchange(context.Background(), "good-code", "verifier", "nonce"); err == nil {
+			t.Error("Expected error for mismatched issuer; got nil")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	o := NewOIDC(Config{Issuer: "https://issuer.test", ClientID: "client"})
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{"iss": "https://issuer.test", "aud": []string{"other", "client"}, "exp": now.Add(time.Minute).Unix(), "sub": "user-1", "nonce": "n"}
+	}
+
+	// A token with every claim correct, aud given as a list
+	if _, err := o.validate(idToken(valid()), "n", now); err != nil {
+		t.Errorf("Expected valid token; got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		claim string
+		value any
+	}{
+		{"Wrong issuer", "iss", "https://evil.test"},
+		{"Wrong audience", "aud", "other"},
+		{"Expired", "exp", now.Add(-time.Minute).Unix()},
+		{"Wrong nonce", "nonce", "replayed"},
+		{"Missing subject", "sub", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			c[tt.claim] = tt.value
+			if _, err := o.validate(idToken(c), "n", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken; got %v", err)
+			}
+		})
+	}
+
+	t.Run("Malformed token", func(t *testing.T) {
+		for _, tok := range []string{"", "a.b", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte("[]")) + ".c"} {
+			if _, err := o.validate(tok, "n", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken for %q; got %v", tok, err)
+			}
+		}
+	})
+}
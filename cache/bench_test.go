@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func BenchmarkMemory(b *testing.B) {
+	value := []byte("Grace Hopper can compile HTML.")
+
+	b.Run("Get", func(b *testing.B) {
+		m := NewMemory()
+		m.Set("key", value, time.Hour)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m.Get("key")
+			}
+		})
+	})
+
+	b.Run("Set", func(b *testing.B) {
+		m := NewMemory()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				m.Set(strconv.Itoa(i%1024), value, time.Hour)
+				i++
+			}
+		})
+	})
+}
@@ -0,0 +1,172 @@
+// Package cache provides the key/value cache used by the server.
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores byte values under string keys with an optional expiry
+type Cache interface {
+	// Get returns the value stored under key and whether it was found
+	Get(key string) ([]byte, bool)
+	// Set stores value under key; a ttl of zero never expires
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete removes key
+	Delete(key string)
+}
+
+/*
+	 Inspector is a Cache whose contents and counters admins can see,
+	 and which they can clear
+
+		Inspecting a key doesn't count as a hit or miss.
+*/
+type Inspector interface {
+	Cache
+	// Entries describes every unexpired value, sorted by key
+	Entries() []Entry
+	// Inspect returns the value stored under key and its description
+	Inspect(key string) (Entry, []byte, bool)
+	// Stats returns the counters since the cache was created
+	Stats() Stats
+	// Clear removes every value
+	Clear()
+}
+
+// Entry describes a cached value without its contents
+type Entry struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+	// ExpiresAt is nil for values that never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Stats counts cache lookups and values dropped on expiry
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// struct to hold a cached value and its expiry
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+// Memory is an in-process Cache. The zero value is not usable, use NewMemory.
+type Memory struct {
+	mu    sync.RWMutex
+	items map[string]item
+	now   func() time.Time
+
+	hits, misses, evictions atomic.Uint64
+}
+
+// NewMemory returns an empty in-memory cache
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]item), now: time.Now}
+}
+
+// Get returns the value stored under key, treating expired values as missing
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.RLock()
+	it, ok := m.items[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		m.misses.Add(1)
+		return nil, false
+	}
+	// Drop expired values lazily
+	if m.expired(it) {
+		m.mu.Lock()
+		// Re-check in case the key was set again since it was read
+		if it, ok := m.items[key]; ok && m.expired(it) {
+			delete(m.items, key)
+			m.evictions.Add(1)
+		}
+		m.mu.Unlock()
+		m.misses.Add(1)
+		return nil, false
+	}
+	m.hits.Add(1)
+	return it.value, true
+}
+
+// expired reports whether it has passed its expiry
+func (m *Memory) expired(it item) bool {
+	return !it.expires.IsZero() && !m.now().Before(it.expires)
+}
+
+// Set stores value under key for ttl
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) {
+	it := item{value: value}
+	if ttl > 0 {
+		it.expires = m.now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = it
+}
+
+// Delete removes key
+func (m *Memory) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+}
+
+// Entries describes every unexpired value, sorted by key
+func (m *Memory) Entries() []Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []Entry{}
+	for key, it := range m.items {
+		if m.expired(it) {
+			continue
+		}
+		entries = append(entries, entry(key, it))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// Inspect returns the unexpired value stored under key without counting a hit or miss
+func (m *Memory) Inspect(key string) (Entry, []byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	it, ok := m.items[key]
+	if !ok || m.expired(it) {
+		return Entry{}, nil, false
+	}
+	return entry(key, it), it.value, true
+}
+
+// Stats returns the hits, misses and evictions since the cache was created
+func (m *Memory) Stats() Stats {
+	return Stats{Hits: m.hits.Load(), Misses: m.misses.Load(), Evictions: m.evictions.Load()}
+}
+
+// Clear removes every value
+func (m *Memory) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.items)
+}
+
+// Function to describe the value it stored under key
+func entry(key string, it item) Entry {
+	e := Entry{Key: key, Size: len(it.value)}
+	if !it.expires.IsZero() {
+		expires := it.expires
+		e.ExpiresAt = &expires
+	}
+	return e
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	t.Run("Returns stored values", func(t *testing.T) {
+		m.Set("key", []byte("value"), 0)
+		got, ok := m.Get("key")
+		if !ok || string(got) != "value" {
+			t.Errorf("Expected %q; got %q (found %v)", "value", got, ok)
+		}
+	})
+
+	t.Run("Misses unknown and deleted keys", func(t *testing.T) {
+		if _, ok := m.Get("unknown"); ok {
+			t.Error("Expected miss for unknown key")
+		}
+		m.Set("deleted", []byte("value"), 0)
+		m.Delete("deleted")
+		if _, ok := m.Get("deleted"); ok {
+			t.Error("Expected miss for deleted key")
+		}
+	})
+
+	t.Run("Expires values after ttl", func(t *testing.T) {
+		m.Set("expiring", []byte("value"), time.Minute)
+		if _, ok := m.Get("expiring"); !ok {
+			t.Error("Expected hit before expiry")
+		}
+		now = now.Add(time.Minute)
+		if _, ok := m.Get("expiring"); ok {
+			t.Error("Expected miss after expiry")
+		}
+	})
+}
+
+func TestMemoryInspector(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	m.Set("b", []byte("value"), 0)
+	m.Set("a", []byte("xy"), time.Minute)
+
+	t.Run("Counts hits, misses and evictions", func(t *testing.T) {
+		m.Get("b")
+		m.Get("missing")
+		m.Set("expiring", []byte("value"), time.Second)
+		now = now.Add(time.Second)
+		m.Get("expiring")
+		if got := m.Stats(); got != (Stats{Hits: 1, Misses: 2, Evictions: 1}) {
+			t.Errorf("Expected 1 hit, 2 misses and 1 eviction; got %+v", got)
+		}
+	})
+
+	t.Run("Lists entries by key", func(t *testing.T) {
+		entries := m.Entries()
+		if len(entries) != 2 || entries[0].Key != "a" || entries[0].Size != 2 || entries[1].Key != "b" {
+			t.Fatalf("Unexpected entries: %+v", entries)
+		}
+		if entries[0].ExpiresAt == nil || entries[1].ExpiresAt != nil {
+			t.Errorf("Expected only a to expire; got %+v", entries)
+		}
+	})
+
+	t.Run("Inspects without counting", func(t *testing.T) {
+		before := m.Stats()
+		if _, value, ok := m.Inspect("b"); !ok || string(value) != "value" {
+			t.Errorf("Expected %q; got %q (found %v)", "value", value, ok)
+		}
+		if _, _, ok := m.Inspect("missing"); ok {
+			t.Error("Expected missing key not to be found")
+		}
+		if m.Stats() != before {
+			t.Errorf("Expected stats to be unchanged; got %+v", m.Stats())
+		}
+	})
+
+	t.Run("Clears every value", func(t *testing.T) {
+		m.Clear()
+		if len(m.Entries()) != 0 {
+			t.Errorf("Expected no entries; got %+v", m.Entries())
+		}
+	})
+}
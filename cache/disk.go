@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket of the database holding the cached values
+var diskBucket = []byte("cache")
+
+// Longest OpenDisk waits for another process to close the database
+const diskLockTimeout = time.Second
+
+/*
+	 Disk is a Memory cache saved to a bbolt database, so values
+	 survive restarts of a single node without a cache server
+
+		Values are read from memory and written to the database by
+		Flush, which should be called periodically and on shutdown;
+		changes since the last Flush are lost on a crash. The file is
+		locked while open, so one process uses it at a time. Build
+		one with OpenDisk and Close it when done.
+*/
+type Disk struct {
+	*Memory
+	db   *bolt.DB
+	path string
+
+	// Serializes Flush, and guards changed and cleared
+	mu      sync.Mutex
+	changed map[string]struct{}
+	cleared bool
+}
+
+// OpenDisk returns a cache saved to the database at path, loading the values already in it
+func OpenDisk(path string) (*Disk, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: diskLockTimeout})
+	// Handle errors while opening the file, e.g. it isn't a database
+	if err != nil {
+		return nil, fmt.Errorf("cache: could not open %s: %w", path, err)
+	}
+	d := &Disk{Memory: NewMemory(), db: db, path: path, changed: make(map[string]struct{})}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(diskBucket)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			loaded, ok := decodeDiskItem(v)
+			if !ok {
+				return fmt.Errorf("corrupt value under %q", k)
+			}
+			// Drop values that expired while the server was down
+			if d.expired(loaded) {
+				expired = append(expired, k)
+				return nil
+			}
+			d.items[string(k)] = loaded
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// Handle errors while loading the values
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cache: could not load %s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Set stores value under key for ttl, to be saved by the next Flush
+func (d *Disk) Set(key string, value []byte, ttl time.Duration) {
+	d.Memory.Set(key, value, ttl)
+	d.markChanged(key)
+}
+
+// Delete removes key, to be saved by the next Flush
+func (d *Disk) Delete(key string) {
+	d.Memory.Delete(key)
+	d.markChanged(key)
+}
+
+// Clear removes every value, to be saved by the next Flush
+func (d *Disk) Clear() {
+	d.Memory.Clear()
+	d.mu.Lock()
+	d.cleared = true
+	clear(d.changed)
+	d.mu.Unlock()
+}
+
+/*
+	 Flush writes the values changed since the last Flush to the
+	 database
+
+		Changes are written in one transaction, so a crash keeps
+		either all of them or none. Values that expired are deleted.
+*/
+func (d *Disk) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.changed) == 0 && !d.cleared {
+		return nil
+	}
+
+	// Copy the changed values out so Gets aren't blocked while writing
+	saved := make(map[string][]byte, len(d.changed))
+	d.Memory.mu.RLock()
+	for key := range d.changed {
+		if it, ok := d.items[key]; ok && !d.expired(it) {
+			saved[key] = encodeDiskItem(it)
+		} else {
+			saved[key] = nil
+		}
+	}
+	d.Memory.mu.RUnlock()
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		if d.cleared {
+			if err := tx.DeleteBucket(diskBucket); err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucketIfNotExists(diskBucket)
+		if err != nil {
+			return err
+		}
+		for key, v := range saved {
+			if v == nil {
+				err = b.Delete([]byte(key))
+			} else {
+				err = b.Put([]byte(key), v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// Keep the changes to retry with the next Flush
+	if err != nil {
+		return fmt.Errorf("cache: could not save %s: %w", d.path, err)
+	}
+	clear(d.changed)
+	d.cleared = false
+	return nil
+}
+
+// Close flushes the values changed since the last Flush and closes the database
+func (d *Disk) Close() error {
+	flushErr := d.Flush()
+	if err := d.db.Close(); err != nil {
+		return fmt.Errorf("cache: could not close %s: %w", d.path, err)
+	}
+	return flushErr
+}
+
+// Function to record that key changed since the last Flush
+func (d *Disk) markChanged(key string) {
+	d.mu.Lock()
+	d.changed[key] = struct{}{}
+	d.mu.Unlock()
+}
+
+// Function to encode it as its expiry in Unix nanoseconds, zero for never, then its value
+func encodeDiskItem(it item) []byte {
+	b := make([]byte, 8, 8+len(it.value))
+	if !it.expires.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(it.expires.UnixNano()))
+	}
+	return append(b, it.value...)
+}
+
+// Function to decode a value encoded by encodeDiskItem, false when too short
+func decodeDiskItem(v []byte) (item, bool) {
+	if len(v) < 8 {
+		return item{}, false
+	}
+	// bbolt's slices are only valid during the transaction
+	it := item{value: append([]byte(nil), v[8:]...)}
+	if ns := binary.BigEndian.Uint64(v); ns != 0 {
+		it.expires = time.Unix(0, int64(ns))
+	}
+	return it, true
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Function to open a Disk cache at path, closing it when the test ends
+func openTestDisk(t *testing.T, path string) *Disk {
+	t.Helper()
+	d, err := OpenDisk(path)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDisk(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Values survive reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+		d := openTestDisk(t, path)
+		d.Set("kept", []byte("value"), 0)
+		d.Set("expiring", []byte("value"), time.Hour)
+		d.Set("deleted", []byte("value"), 0)
+		d.Delete("deleted")
+		if err := d.Close(); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		reopened := openTestDisk(t, path)
+		for _, key := range []string{"kept", "expiring"} {
+			if got, ok := reopened.Get(key); !ok || string(got) != "value" {
+				t.Errorf("Expected %s to be %q; got %q (found %v)", key, "value", got, ok)
+			}
+		}
+		if _, ok := reopened.Get("deleted"); ok {
+			t.Error("Expected deleted key to stay deleted")
+		}
+	})
+
+	t.Run("Drops values that expired while closed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+		db, err := bolt.Open(path, 0o600, nil)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		db.Update(func(tx *bolt.Tx) error {
+			b, _ := tx.CreateBucket(diskBucket)
+			return b.Put([]byte("old"), encodeDiskItem(item{value: []byte("value"), expires: time.Now().Add(-time.Minute)}))
+		})
+		db.Close()
+
+		d := openTestDisk(t, path)
+		if len(d.items) != 0 {
+			t.Errorf("Expected expired values to be dropped; got %d", len(d.items))
+		}
+		d.db.View(func(tx *bolt.Tx) error {
+			if tx.Bucket(diskBucket).Get([]byte("old")) != nil {
+				t.Error("Expected expired values to be deleted from the file")
+			}
+			return nil
+		})
+	})
+
+	t.Run("Clear is saved", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+		d := openTestDisk(t, path)
+		d.Set("key", []byte("value"), 0)
+		d.Flush()
+		d.Clear()
+		d.Set("after", []byte("value"), 0)
+		d.Close()
+
+		reopened := openTestDisk(t, path)
+		if _, ok := reopened.Get("key"); ok {
+			t.Error("Expected the cleared key to stay cleared")
+		}
+		if _, ok := reopened.Get("after"); !ok {
+			t.Error("Expected the key set after clearing to be saved")
+		}
+	})
+
+	t.Run("Flush skips unchanged caches", func(t *testing.T) {
+		d := openTestDisk(t, filepath.Join(t.TempDir(), "cache.db"))
+		before := d.db.Stats()
+		if err := d.Flush(); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		after := d.db.Stats()
+		if after.TxStats.GetWrite() != before.TxStats.GetWrite() {
+			t.Error("Expected no writes before any change")
+		}
+	})
+
+	t.Run("Flush failures are retried", func(t *testing.T) {
+		d := openTestDisk(t, filepath.Join(t.TempDir(), "cache.db"))
+		d.Set("key", []byte("value"), 0)
+		d.db.Close()
+		if err := d.Flush(); err == nil {
+			t.Fatal("Expected an error writing to a closed database")
+		}
+		if _, ok := d.changed["key"]; !ok {
+			t.Error("Expected the change to be kept for the next Flush")
+		}
+	})
+
+	t.Run("Rejects files that aren't databases", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+		os.WriteFile(path, []byte(`{"key": {"value": "dmFsdWU="}}`), 0o600)
+		if d, err := OpenDisk(path); err == nil {
+			d.Close()
+			t.Error("Expected an error for a file that isn't a database")
+		}
+	})
+}
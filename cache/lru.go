@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+/*
+	 LRU is an in-process Cache holding at most a fixed number of values,
+	 dropping the least recently used to make room
+
+		Use it for values that rarely repeat, which a Memory cache
+		would keep until they're read again. Build one with NewLRU.
+*/
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+// struct to hold a value in the LRU's order
+type lruItem struct {
+	key string
+	item
+}
+
+// NewLRU returns an empty cache holding at most size values
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  max(size, 1),
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Get returns the value stored under key, treating expired values as missing
+func (l *LRU) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*lruItem)
+	if !it.expires.IsZero() && !l.now().Before(it.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return it.value, true
+}
+
+// Set stores value under key for ttl, dropping the least recently used value when full
+func (l *LRU) Set(key string, value []byte, ttl time.Duration) {
+	it := item{value: value}
+	if ttl > 0 {
+		it.expires = l.now().Add(ttl)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		el.Value.(*lruItem).item = it
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruItem{key: key, item: it})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+// Delete removes key
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+// Len returns the number of values held, including expired ones not yet dropped
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
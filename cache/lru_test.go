@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Drops the least recently used value when full", func(t *testing.T) {
+		l := NewLRU(2)
+		l.Set("a", []byte("1"), 0)
+		l.Set("b", []byte("2"), 0)
+		// Reading a makes b the least recently used
+		l.Get("a")
+		l.Set("c", []byte("3"), 0)
+
+		if _, ok := l.Get("b"); ok {
+			t.Error("Expected b to be dropped")
+		}
+		for _, key := range []string{"a", "c"} {
+			if _, ok := l.Get(key); !ok {
+				t.Errorf("Expected %s to be kept", key)
+			}
+		}
+		if n := l.Len(); n != 2 {
+			t.Errorf("Expected 2 values; got %d", n)
+		}
+	})
+
+	t.Run("Replacing a value doesn't grow the cache", func(t *testing.T) {
+		l := NewLRU(2)
+		l.Set("a", []byte("1"), 0)
+		l.Set("a", []byte("2"), 0)
+		if got, ok := l.Get("a"); !ok || string(got) != "2" {
+			t.Errorf("Expected %q; got %q (found %v)", "2", got, ok)
+		}
+		if n := l.Len(); n != 1 {
+			t.Errorf("Expected 1 value; got %d", n)
+		}
+	})
+
+	t.Run("Expired values are missing", func(t *testing.T) {
+		now := time.Now()
+		l := NewLRU(2)
+		l.now = func() time.Time { return now }
+		l.Set("a", []byte("1"), time.Minute)
+		now = now.Add(time.Minute)
+		if _, ok := l.Get("a"); ok {
+			t.Error("Expected a to have expired")
+		}
+		if n := l.Len(); n != 0 {
+			t.Errorf("Expected the expired value dropped; got %d values", n)
+		}
+	})
+
+	t.Run("Delete removes a value", func(t *testing.T) {
+		l := NewLRU(2)
+		l.Set("a", []byte("1"), 0)
+		l.Delete("a")
+		if _, ok := l.Get("a"); ok {
+			t.Error("Expected a to be deleted")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// Package calendar writes iCalendar (RFC 5545) feeds of all-day events,
+// which calendar apps such as Google Calendar and Outlook can subscribe to.
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType of iCalendar feeds
+const ContentType = "text/calendar; charset=utf-8"
+
+// Longest content line in octets, excluding the line break; longer lines are folded
+const maxLineOctets = 75
+
+// Layouts of DATE and UTC DATE-TIME values
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// Event is an all-day event
+type Event struct {
+	// UID identifies the event across feeds, e.g. 2026-10-16@jokes.example.com
+	UID string
+	// Day is the date of the event; its time and location are ignored
+	Day         time.Time
+	Summary     string
+	Description string
+}
+
+// Feed is a calendar of events
+type Feed struct {
+	// Name is shown by calendar apps for the subscribed calendar
+	Name string
+	// Refresh is how often apps are asked to fetch the feed again, when not zero
+	Refresh time.Duration
+	Events  []Event
+}
+
+/*
+	 Write writes f to w as an iCalendar object, stamped with now
+
+		Text is escaped and lines folded as RFC 5545 requires.
+*/
+func (f Feed) Write(w io.Writer, now time.Time) error {
+	b := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeLine(b, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//joke-generator//calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if f.Name != "" {
+		line("X-WR-CALNAME", escape(f.Name))
+	}
+	if f.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", duration(f.Refresh))
+		line("X-PUBLISHED-TTL", duration(f.Refresh))
+	}
+	stamp := now.UTC().Format(dateTimeLayout)
+	for _, e := range f.Events {
+		day := time.Date(e.Day.Year(), e.Day.Month(), e.Day.Day(), 0, 0, 0, 0, time.UTC)
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", stamp)
+		line("DTSTART;VALUE=DATE", day.Format(dateLayout))
+		line("DTEND;VALUE=DATE", day.AddDate(0, 0, 1).Format(dateLayout))
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("calendar: could not write feed: %w", err)
+	}
+	return nil
+}
+
+// Function to escape a TEXT value
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// Function to format d as a DURATION value in whole seconds, e.g. PT12H
+func duration(d time.Duration) string {
+	s := int64(d / time.Second)
+	out := "PT"
+	if h := s / 3600; h > 0 {
+		out += fmt.Sprintf("%dH", h)
+	}
+	if m := s % 3600 / 60; m > 0 {
+		out += fmt.Sprintf("%dM", m)
+	}
+	if sec := s % 60; sec > 0 || out == "PT" {
+		out += fmt.Sprintf("%dS", sec)
+	}
+	return out
+}
+
+// Function to write a content line, folded at 75 octets without splitting a character
+func writeLine(b *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts toward their length
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,68 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("é", 60) + " and then some"
+	f := Feed{
+		Name:    "Joke of the Day",
+		Refresh: 12 * time.Hour,
+		Events: []Event{{
+			UID:         "2026-10-16@jokes.example.com",
+			Day:         time.Date(2026, 10, 16, 15, 0, 0, 0, time.FixedZone("PDT", -7*3600)),
+			Summary:     "Why, Ada; why?",
+			Description: "Why, Ada; why?\nBecause\\" + long,
+		}},
+	}
+	var b strings.Builder
+	if err := f.Write(&b, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:Joke of the Day\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT12H\r\n",
+		"DTSTAMP:20261016T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20261016\r\nDTEND;VALUE=DATE:20261017\r\n",
+		`SUMMARY:Why\, Ada\; why?` + "\r\n",
+		`DESCRIPTION:Why\, Ada\; why?\nBecause\\`,
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected the feed to contain %q; got %s", want, out)
+		}
+	}
+
+	// Every line fits in 75 octets and unfolds to the original text
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("Expected lines of at most %d octets; got %d: %q", maxLineOctets, len(line), line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(out, "\r\n ", ""); !strings.Contains(unfolded, long+"\r\n") {
+		t.Errorf("Expected the description to unfold intact; got %s", unfolded)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	for d, want := range map[time.Duration]string{
+		12 * time.Hour:            "PT12H",
+		90 * time.Minute:          "PT1H30M",
+		0:                         "PT0S",
+		time.Hour + 5*time.Second: "PT1H5S",
+	} {
+		if got := duration(d); got != want {
+			t.Errorf("%v: expected %s; got %s", d, want, got)
+		}
+	}
+}
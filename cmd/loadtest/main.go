@@ -0,0 +1,256 @@
+// Command loadtest sends HTTP traffic to a running joke generator and
+// reports throughput, status codes and latency percentiles.
+//
+// Usage:
+//
+//	go run ./cmd/loadtest -url http://localhost:3000/ -rps 50 -concurrency 20 -duration 30s
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// struct to hold the load test configuration
+type config struct {
+	url         string
+	rps         float64
+	concurrency int
+	duration    time.Duration
+	apiKey      string
+}
+
+// struct to hold the outcome of one request
+type sample struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// struct to hold the aggregated results of a run
+type result struct {
+	elapsed   time.Duration
+	total     int
+	dropped   int
+	statuses  map[int]int
+	errors    map[string]int
+	latencies []time.Duration
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.url, "url", "http://localhost:3000/", "URL to send GET requests to")
+	flag.Float64Var(&cfg.rps, "rps", 0, "target requests per second, 0 sends as fast as the workers allow")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "number of concurrent workers")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to send traffic")
+	flag.StringVar(&cfg.apiKey, "api-key", "", "API key sent in the X-API-Key header")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-request timeout")
+	flag.Parse()
+
+	if cfg.concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "loadtest: -concurrency must be at least 1")
+		os.Exit(2)
+	}
+
+	// Stop early on Ctrl-C and still print results
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: *timeout,
+		// Keep enough idle connections for every worker
+		Transport: &http.Transport{MaxIdleConnsPerHost: cfg.concurrency},
+	}
+
+	res := run(ctx, cfg, client)
+	report(os.Stdout, res)
+
+	// Exit non-zero when any request failed so CI can gate on it
+	if res.failures() > 0 {
+		os.Exit(1)
+	}
+}
+
+/*
+	 run sends traffic described by cfg until its duration passes or ctx
+	 is cancelled
+
+		With rps set, a ticker schedules requests at that rate; ticks
+		arriving while every worker is busy are counted as dropped
+		rather than queued, so a slow server shows up as lost
+		throughput instead of ever-growing latency.
+*/
+func run(ctx context.Context, cfg config, client *http.Client) result {
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	jobs := make(chan struct{}, cfg.concurrency)
+	samples := make(chan sample, cfg.concurrency)
+	res := result{statuses: make(map[int]int), errors: make(map[string]int)}
+
+	// Workers sending requests
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				samples <- send(ctx, cfg, client)
+			}
+		}()
+	}
+
+	// Scheduler feeding the workers
+	var dropped int
+	go func() {
+		defer close(jobs)
+		if cfg.rps <= 0 {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- struct{}{}:
+				}
+			}
+		}
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rps))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case jobs <- struct{}{}:
+				default:
+					dropped++
+				}
+			}
+		}
+	}()
+
+	// Close samples once every worker has finished
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	start := time.Now()
+	for s := range samples {
+		// Requests cut off by the end of the run are not failures
+		if s.err != nil && ctx.Err() != nil {
+			continue
+		}
+		res.total++
+		res.latencies = append(res.latencies, s.latency)
+		if s.err != nil {
+			res.errors[errorKind(s.err)]++
+			continue
+		}
+		res.statuses[s.status]++
+	}
+	res.elapsed = time.Since(start)
+	// The scheduler has exited once jobs is drained and samples closed
+	res.dropped = dropped
+	return res
+}
+
+// Function to send one request and time it
+func send(ctx context.Context, cfg config, client *http.Client) sample {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.url, nil)
+	if err != nil {
+		return sample{err: err}
+	}
+	if cfg.apiKey != "" {
+		req.Header.Set("X-API-Key", cfg.apiKey)
+	}
+
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return sample{latency: time.Since(start), err: err}
+	}
+	// Drain the body so the connection is reused
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return sample{status: res.StatusCode, latency: time.Since(start)}
+}
+
+// Function to group transport errors into short, countable kinds
+func errorKind(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Client.Timeout"), strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(msg, "connection refused"):
+		return "connection refused"
+	case strings.Contains(msg, "connection reset"):
+		return "connection reset"
+	default:
+		return "other"
+	}
+}
+
+// failures returns the number of requests without a 2xx response
+func (r result) failures() int {
+	n := 0
+	for status, count := range r.statuses {
+		if status < 200 || status > 299 {
+			n += count
+		}
+	}
+	for _, count := range r.errors {
+		n += count
+	}
+	return n
+}
+
+// percentile returns the p-th percentile (0-100) of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// Nearest-rank method
+	rank := int(float64(len(sorted))*p/100+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
+
+// Function to print a summary of res to w
+func report(w io.Writer, res result) {
+	sort.Slice(res.latencies, func(i, j int) bool { return res.latencies[i] < res.latencies[j] })
+
+	fmt.Fprintf(w, "requests:   %d in %s (%.1f req/s)\n", res.total, res.elapsed.Round(time.Millisecond), float64(res.total)/res.elapsed.Seconds())
+	fmt.Fprintf(w, "dropped:    %d (all workers busy)\n", res.dropped)
+	fmt.Fprintf(w, "failures:   %d\n", res.failures())
+
+	// Status codes in ascending order
+	codes := make([]int, 0, len(res.statuses))
+	for code := range res.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  status %d: %d\n", code, res.statuses[code])
+	}
+	for kind, count := range res.errors {
+		fmt.Fprintf(w, "  error %s: %d\n", kind, count)
+	}
+
+	fmt.Fprintln(w, "latency:")
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Fprintf(w, "  p%-3v %s\n", p, percentile(res.latencies, p))
+	}
+	if n := len(res.latencies); n > 0 {
+		fmt.Fprintf(w, "  max  %s\n", res.latencies[n-1])
+	}
+}
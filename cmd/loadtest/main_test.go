@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, 1 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("Expected p%v %s; got %s", tt.p, tt.want, got)
+		}
+	}
+
+	// No samples
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("Expected 0 for no samples; got %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Counts statuses", func(t *testing.T) {
+		var n atomic.Int64
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Fail every fourth request
+			if n.Add(1)%4 == 0 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+		defer target.Close()
+
+		cfg := config{url: target.URL, concurrency: 4, duration: 100 * time.Millisecond}
+		res := run(context.Background(), cfg, target.Client())
+
+		if res.total == 0 {
+			t.Fatal("Expected requests to be sent; got none")
+		}
+		if res.statuses[http.StatusOK] == 0 || res.statuses[http.StatusBadGateway] == 0 {
+			t.Errorf("Expected 200 and 502 responses; got %v", res.statuses)
+		}
+		if res.failures() != res.statuses[http.StatusBadGateway] {
+			t.Errorf("Expected %d failures; got %d", res.statuses[http.StatusBadGateway], res.failures())
+		}
+		if len(res.latencies) != res.total {
+			t.Errorf("Expected %d latencies; got %d", res.total, len(res.latencies))
+		}
+	})
+
+	t.Run("Paces requests", func(t *testing.T) {
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer target.Close()
+
+		cfg := config{url: target.URL, rps: 50, concurrency: 2, duration: 200 * time.Millisecond}
+		res := run(context.Background(), cfg, target.Client())
+
+		// 50 req/s for 200ms is about 10 requests
+		if res.total < 5 || res.total > 15 {
+			t.Errorf("Expected about 10 requests; got %d", res.total)
+		}
+	})
+
+	t.Run("Sends API key", func(t *testing.T) {
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer target.Close()
+
+		cfg := config{url: target.URL, concurrency: 1, duration: 50 * time.Millisecond, apiKey: "secret"}
+		res := run(context.Background(), cfg, target.Client())
+
+		if res.statuses[http.StatusUnauthorized] != 0 {
+			t.Errorf("Expected no 401 responses; got %d", res.statuses[http.StatusUnauthorized])
+		}
+	})
+
+	t.Run("Unreachable target", func(t *testing.T) {
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		target.Close()
+
+		cfg := config{url: target.URL, concurrency: 1, rps: 20, duration: 100 * time.Millisecond}
+		res := run(context.Background(), cfg, http.DefaultClient)
+
+		if res.errors["connection refused"] == 0 {
+			t.Errorf("Expected connection refused errors; got %v", res.errors)
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	res := result{
+		elapsed:   time.Second,
+		total:     3,
+		statuses:  map[int]int{200: 2, 504: 1},
+		errors:    map[string]int{},
+		latencies: []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond},
+	}
+
+	var buf bytes.Buffer
+	report(&buf, res)
+	out := buf.String()
+
+	for _, want := range []string{"requests:   3", "failures:   1", "status 504: 1", "p50  20ms", "max  30ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q; got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+/*
+	 Package digest emails subscribers a weekly digest of the jokes
+	 served most that week.
+
+		Jokes aren't rated, so they are ranked by how often they were
+		served, decayed over days by a trending.Tracker. Each email
+		links to the server's unsubscribe route with a signature only
+		the server can make, so nobody can unsubscribe someone else.
+*/
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/trending"
+)
+
+// Jokes in each digest
+const Size = 10
+
+// When the digest is sent each week, in UTC
+const (
+	sendWeekday = time.Monday
+	sendHour    = 9
+)
+
+// Subject of the digest emails
+const subject = "This week's top jokes"
+
+// Path of the server's unsubscribe route
+const UnsubscribePath = "/digest/unsubscribe"
+
+// Message is an email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	HTML    []byte
+	// Unsubscribe is the recipient's unsubscribe link
+	Unsubscribe string
+}
+
+// Sender sends emails
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config configures a Digest
+type Config struct {
+	Subscribers *Subscribers
+	// Top returns the n jokes to send, highest ranked first
+	Top    func(n int) []trending.Joke
+	Sender Sender
+	// Secret signs unsubscribe links
+	Secret []byte
+	// BaseURL is the server's public URL, e.g. https://jokes.example.com
+	BaseURL string
+	Logger  *slog.Logger
+}
+
+// Digest sends the weekly digest. Build one with New.
+type Digest struct {
+	cfg  Config
+	page *template.Template
+}
+
+// struct to hold a joke shown in the digest
+type digestJoke struct {
+	Setup    string
+	Delivery string
+	Serves   int
+}
+
+// struct to hold the data of a digest email
+type digestPage struct {
+	Jokes       []digestJoke
+	Unsubscribe string
+}
+
+// Template of the digest emails, styled inline since mail clients drop style sheets
+const pageTemplate = `<!DOCTYPE html>
+<html><body style="font-family: sans-serif; max-width: 600px; margin: 0 auto;">
+<h1 style="font-size: 20px;">This week's top jokes</h1>
+<ol>
+{{- range .Jokes}}
+<li style="margin-bottom: 16px;">{{.Setup}}{{if .Delivery}}<br><strong>{{.Delivery}}</strong>{{end}}
+<br><small style="color: #666;">Served {{.Serves}} {{if eq .Serves 1}}time{{else}}times{{end}}</small></li>
+{{- end}}
+</ol>
+<p style="color: #666; font-size: 12px;">You get this email because you subscribed to the joke digest.
+<a href="{{.Unsubscribe}}">Unsubscribe</a></p>
+</body></html>
+`
+
+// New returns a Digest configured by cfg
+func New(cfg Config) *Digest {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Digest{cfg: cfg, page: template.Must(template.New("digest").Parse(pageTemplate))}
+}
+
+/*
+	 Send emails the digest to every subscriber, returning how many it
+	 was sent to
+
+		Nothing is sent when no joke was served. Failed recipients
+		don't stop the others; their errors are joined.
+*/
+func (d *Digest) Send(ctx context.Context) (int, error) {
+	top := d.cfg.Top(Size)
+	if len(top) == 0 {
+		d.cfg.Logger.InfoContext(ctx, "digest: no jokes served, skipping")
+		return 0, nil
+	}
+	jokes := make([]digestJoke, len(top))
+	for i, j := range top {
+		jokes[i] = digestJoke{Setup: j.Joke, Serves: j.Serves}
+		if setup, delivery, ok := joke.SplitJoke(j.Joke); ok {
+			jokes[i].Setup, jokes[i].Delivery = setup, delivery
+		}
+	}
+
+	recipients, err := d.cfg.Subscribers.Recipients()
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, to := range recipients {
+		if err := ctx.Err(); err != nil {
+			return sent, errors.Join(append(errs, err)...)
+		}
+		unsubscribe := d.UnsubscribeURL(to)
+		var html bytes.Buffer
+		if err := d.page.Execute(&html, digestPage{Jokes: jokes, Unsubscribe: unsubscribe}); err != nil {
+			return sent, fmt.Errorf("digest: could not render email: %w", err)
+		}
+		if err := d.cfg.Sender.Send(ctx, Message{To: to, Subject: subject, HTML: html.Bytes(), Unsubscribe: unsubscribe}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// UnsubscribeURL returns the link unsubscribing address
+func (d *Digest) UnsubscribeURL(address string) string {
+	address = normalizeAddress(address)
+	q := url.Values{"email": {address}, "sig": {d.sign(address)}}
+	return d.cfg.BaseURL + UnsubscribePath + "?" + q.Encode()
+}
+
+// Valid reports whether sig is the signature of the unsubscribe link for address
+func (d *Digest) Valid(address, sig string) bool {
+	return hmac.Equal([]byte(d.sign(normalizeAddress(address))), []byte(sig))
+}
+
+// Unsubscribe stops sending the digest to address
+func (d *Digest) Unsubscribe(address string) error {
+	return d.cfg.Subscribers.Unsubscribe(address)
+}
+
+// Function to sign an unsubscribe link for address
+func (d *Digest) sign(address string) string {
+	mac := hmac.New(sha256.New, d.cfg.Secret)
+	mac.Write([]byte(address))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends the digest every Monday at 09:00 UTC until ctx is cancelled
+func (d *Digest) Run(ctx context.Context) {
+	for {
+		next := nextSend(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		sent, err := d.Send(ctx)
+		if err != nil {
+			d.cfg.Logger.ErrorContext(ctx, "digest: failed to send to some subscribers", "sent", sent, "error", err)
+			continue
+		}
+		d.cfg.Logger.InfoContext(ctx, "digest: sent", "recipients", sent)
+	}
+}
+
+// Function to return the first send time after now
+func nextSend(now time.Time) time.Time {
+	now = now.UTC()
+	days := (int(sendWeekday) - int(now.Weekday()) + 7) % 7
+	next := time.Date(now.Year(), now.Month(), now.Day()+days, sendHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
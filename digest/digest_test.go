@@ -0,0 +1,135 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/trending"
+)
+
+// struct to hold a Sender recording the messages it is given
+type fakeSender struct {
+	sent []Message
+	fail string
+}
+
+// Send records msg, failing for the fail address
+func (f *fakeSender) Send(ctx context.Context, msg Message) error {
+	if msg.To == f.fail {
+		return errors.New("mailbox full")
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// Function to return a Digest of top sent by sender to the addresses in list
+func newTestDigest(t *testing.T, list string, top []trending.Joke, sender Sender) *Digest {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscribers.txt")
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{
+		Subscribers: NewSubscribers(path, filepath.Join(dir, "unsubscribed.txt")),
+		Top:         func(n int) []trending.Joke { return top },
+		Sender:      sender,
+		Secret:      []byte("secret"),
+		BaseURL:     "https://jokes.example.com",
+	})
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	top := []trending.Joke{{Joke: "Why did Ada cross the road?\nTo debug <it>.", Serves: 3}, {Joke: "Ada can divide by zero.", Serves: 1}}
+
+	t.Run("Emails every subscriber with their own unsubscribe link", func(t *testing.T) {
+		t.Parallel()
+		sender := &fakeSender{fail: "bob@example.com"}
+		d := newTestDigest(t, "ada@example.com\nbob@example.com\n", top, sender)
+
+		sent, err := d.Send(context.Background())
+		if sent != 1 || err == nil {
+			t.Errorf("Expected one email sent and bob's failure; got %d, %v", sent, err)
+		}
+		if len(sender.sent) != 1 {
+			t.Fatalf("Expected one message; got %d", len(sender.sent))
+		}
+		msg := sender.sent[0]
+		html := string(msg.HTML)
+		if msg.To != "ada@example.com" || msg.Unsubscribe != d.UnsubscribeURL("ada@example.com") {
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+		for _, want := range []string{"Why did Ada cross the road?<br><strong>To debug &lt;it&gt;.</strong>", "Served 3 times", "Served 1 time<", "Ada can divide by zero."} {
+			if !strings.Contains(html, want) {
+				t.Errorf("Expected the email to contain %q; got %s", want, html)
+			}
+		}
+	})
+
+	t.Run("Sends nothing without jokes", func(t *testing.T) {
+		t.Parallel()
+		sender := &fakeSender{}
+		d := newTestDigest(t, "ada@example.com\n", nil, sender)
+		if sent, err := d.Send(context.Background()); sent != 0 || err != nil || len(sender.sent) != 0 {
+			t.Errorf("Expected nothing sent; got %d, %v", sent, err)
+		}
+	})
+
+	t.Run("Skips unsubscribed addresses", func(t *testing.T) {
+		t.Parallel()
+		sender := &fakeSender{}
+		d := newTestDigest(t, "ada@example.com\nbob@example.com\n", top, sender)
+		if err := d.Unsubscribe("Bob@Example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if sent, _ := d.Send(context.Background()); sent != 1 || sender.sent[0].To != "ada@example.com" {
+			t.Errorf("Expected only ada to get the digest; got %+v", sender.sent)
+		}
+	})
+}
+
+func TestUnsubscribeURL(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDigest(t, "", nil, &fakeSender{})
+	u, err := url.Parse(d.UnsubscribeURL("Ada@Example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "jokes.example.com" || u.Path != UnsubscribePath {
+		t.Errorf("Unexpected link: %s", u)
+	}
+	q := u.Query()
+	if q.Get("email") != "ada@example.com" || !d.Valid(q.Get("email"), q.Get("sig")) {
+		t.Errorf("Expected a valid signature; got %s", u)
+	}
+	if d.Valid("bob@example.com", q.Get("sig")) {
+		t.Error("Expected the signature not to unsubscribe someone else")
+	}
+}
+
+func TestNextSend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		now  string
+		want string
+	}{
+		{"2026-10-16T12:00:00Z", "2026-10-19T09:00:00Z"}, // Friday
+		{"2026-10-19T08:59:00Z", "2026-10-19T09:00:00Z"}, // Monday before
+		{"2026-10-19T09:00:00Z", "2026-10-26T09:00:00Z"}, // Monday at the time
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.now)
+		if got := nextSend(now).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s: expected %s; got %s", tt.now, tt.want, got)
+		}
+	}
+}
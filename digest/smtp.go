@@ -0,0 +1,78 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Length of the lines a base64 body is wrapped at, as RFC 2045 requires
+const base64LineLength = 76
+
+/*
+	 SMTP sends messages through a mail server, with STARTTLS when the
+	 server offers it
+
+		Username and Password, when set, authenticate with PLAIN,
+		which net/smtp only allows over TLS or to localhost.
+*/
+type SMTP struct {
+	// Addr is the server's host:port, e.g. smtp.example.com:587
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send sends msg; ctx is only checked before sending, as net/smtp can't be canceled
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("digest: invalid SMTP address %q: %w", s.Addr, err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, s.encode(msg, time.Now())); err != nil {
+		return fmt.Errorf("digest: could not send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+/*
+	 Function to encode msg as an HTML email dated now
+
+		The List-Unsubscribe headers let mail clients offer one-click
+		unsubscribing, per RFC 8058.
+*/
+func (s *SMTP) encode(msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	if msg.Unsubscribe != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", msg.Unsubscribe)
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString(msg.HTML)
+	for len(encoded) > base64LineLength {
+		b.WriteString(encoded[:base64LineLength] + "\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,43 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	s := &SMTP{From: "jokes@example.com"}
+	html := bytes.Repeat([]byte("<p>Ada can divide by zero.</p>"), 10)
+	data := s.encode(Message{To: "ada@example.com", Subject: "Top jokes ✨", HTML: html, Unsubscribe: "https://jokes.example.com/digest/unsubscribe?sig=x"}, time.Unix(0, 0))
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not parse message: %v", err)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://jokes.example.com/digest/unsubscribe?sig=x>" {
+		t.Errorf("Unexpected List-Unsubscribe: %q", got)
+	}
+	if got, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); got != "Top jokes ✨" {
+		t.Errorf("Unexpected subject: %q", got)
+	}
+	for _, line := range bytes.Split(data, []byte("\r\n")) {
+		if len(line) > 78 {
+			t.Errorf("Expected lines of at most 78 characters; got %d", len(line))
+		}
+	}
+	encoded, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatalf("Could not read body: %v", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(string(bytes.ReplaceAll(encoded, []byte("\r\n"), nil)))
+	if err != nil || !bytes.Equal(body, html) {
+		t.Errorf("Expected the HTML body; got %q, %v", body, err)
+	}
+}
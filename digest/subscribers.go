@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+	 Subscribers is the list of addresses the digest is sent to
+
+		The list is a file of one address per line, kept by operators;
+		blank lines and lines starting with # are skipped. Addresses
+		that unsubscribe are appended to a second file rather than
+		removed, so the list can be edited and redeployed without
+		subscribing them again. Both files are read on every send.
+*/
+type Subscribers struct {
+	path      string
+	unsubPath string
+
+	mu sync.Mutex
+}
+
+// NewSubscribers returns the subscribers listed in path, minus those listed in unsubPath
+func NewSubscribers(path, unsubPath string) *Subscribers {
+	return &Subscribers{path: path, unsubPath: unsubPath}
+}
+
+// Recipients returns every subscribed address that hasn't unsubscribed
+func (s *Subscribers) Recipients() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := readAddresses(s.path, false)
+	if err != nil {
+		return nil, err
+	}
+	unsubscribed, err := readAddresses(s.unsubPath, true)
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[string]bool, len(unsubscribed))
+	for _, a := range unsubscribed {
+		skip[a] = true
+	}
+
+	var recipients []string
+	for _, a := range list {
+		if !skip[a] {
+			skip[a] = true
+			recipients = append(recipients, a)
+		}
+	}
+	return recipients, nil
+}
+
+// Unsubscribe stops sending the digest to address, doing nothing if it already unsubscribed
+func (s *Subscribers) Unsubscribe(address string) error {
+	address = normalizeAddress(address)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unsubscribed, err := readAddresses(s.unsubPath, true)
+	if err != nil {
+		return err
+	}
+	for _, a := range unsubscribed {
+		if a == address {
+			return nil
+		}
+	}
+	f, err := os.OpenFile(s.unsubPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("digest: could not open %s: %w", s.unsubPath, err)
+	}
+	if _, err := fmt.Fprintln(f, address); err != nil {
+		f.Close()
+		return fmt.Errorf("digest: could not write %s: %w", s.unsubPath, err)
+	}
+	return f.Close()
+}
+
+// Function to read the addresses in the file at path, an empty list when missing is true and it doesn't exist
+func readAddresses(path string, missing bool) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if missing && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("digest: could not read %s: %w", path, err)
+	}
+	var addresses []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addresses = append(addresses, normalizeAddress(line))
+	}
+	return addresses, sc.Err()
+}
+
+// Function to put an address in the form it is listed and signed in
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,42 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSubscribers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscribers.txt")
+	list := "# team\nAda@Example.com\n\nbob@example.com\nada@example.com\n"
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSubscribers(path, filepath.Join(dir, "unsubscribed.txt"))
+
+	got, err := s.Recipients()
+	if err != nil || !reflect.DeepEqual(got, []string{"ada@example.com", "bob@example.com"}) {
+		t.Errorf("Expected each address once; got %v, %v", got, err)
+	}
+
+	// Unsubscribing twice lists the address once
+	for range 2 {
+		if err := s.Unsubscribe("ADA@example.com"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if got, _ := s.Recipients(); !reflect.DeepEqual(got, []string{"bob@example.com"}) {
+		t.Errorf("Expected only bob; got %v", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "unsubscribed.txt")); string(data) != "ada@example.com\n" {
+		t.Errorf("Expected ada unsubscribed once; got %q", data)
+	}
+
+	if _, err := NewSubscribers(filepath.Join(dir, "missing.txt"), "").Recipients(); err == nil {
+		t.Error("Expected an error for a missing list")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+	 Package directory provides names of real people, e.g. the
+	 company's Google Workspace users, for jokes about the team
+
+		A Source lists the people; Names caches the list, refreshes it
+		periodically and picks a random person per joke.
+*/
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// How often the members are listed again by default
+const DefaultRefresh = time.Hour
+
+// Longest response body read from a directory's API
+const maxBody = 10 << 20
+
+// Returned by Names.Name before the members were ever listed
+var errNotLoaded = errors.New("directory: members not listed yet")
+
+// Source lists the people in a directory
+type Source interface {
+	Members(ctx context.Context) ([]joke.Member, error)
+}
+
+/*
+	 Names is a joke.NameProvider picking a random member of a
+	 Source
+
+		The members are listed by Refresh, and by Run periodically;
+		a failed refresh keeps the members from the last one. Build
+		one with New.
+*/
+type Names struct {
+	source  Source
+	refresh time.Duration
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	roster *joke.Roster
+}
+
+// New returns Names for source, refreshed every refresh by Run (DefaultRefresh when 0)
+func New(source Source, refresh time.Duration, logger *slog.Logger) *Names {
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Names{source: source, refresh: refresh, logger: logger}
+}
+
+// Refresh lists the members again, keeping the previous ones on failure
+func (n *Names) Refresh(ctx context.Context) error {
+	members, err := n.source.Members(ctx)
+	// Handle errors while listing
+	if err != nil {
+		return err
+	}
+	roster, err := joke.NewRoster(members)
+	// Handle an empty directory, e.g. a filter matching nobody
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.roster = roster
+	n.mu.Unlock()
+	n.logger.Info("directory: listed members", "members", len(members))
+	return nil
+}
+
+// Run refreshes the members every refresh interval until ctx is done, logging failures
+func (n *Names) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.Refresh(ctx); err != nil {
+				n.logger.Error("directory: could not list members, keeping the last list", "error", err)
+			}
+		}
+	}
+}
+
+// Name returns a random member, failing with joke.ErrNameUpstream before the first successful Refresh
+func (n *Names) Name(ctx context.Context) (joke.Names, error) {
+	n.mu.RLock()
+	roster := n.roster
+	n.mu.RUnlock()
+	if roster == nil {
+		return joke.Names{}, fmt.Errorf("%w: %w", joke.ErrNameUpstream, errNotLoaded)
+	}
+	return roster.Name(ctx)
+}
+
+/*
+	 Function to split a display name into first and last names
+
+		The last name is the last word along with the lower-case
+		particles before it, so "Ludwig van Beethoven" is Ludwig and
+		van Beethoven; hyphenated surnames are one word already.
+*/
+func splitName(display string) (first, last string) {
+	words := strings.Fields(display)
+	if len(words) < 2 {
+		return strings.Join(words, " "), ""
+	}
+	i := len(words) - 1
+	for i > 1 && surnameParticles[words[i-1]] {
+		i--
+	}
+	return strings.Join(words[:i], " "), strings.Join(words[i:], " ")
+}
+
+// Lower-case words that belong to the surname following them
+var surnameParticles = map[string]bool{
+	"al": true, "bin": true, "da": true, "das": true, "de": true, "del": true, "della": true, "der": true,
+	"di": true, "dos": true, "du": true, "la": true, "le": true, "ten": true, "ter": true, "van": true, "von": true,
+}
+
+// Function to send req and decode its JSON response into v, failing on unsuccessful statuses
+func doJSON(c *http.Client, req *http.Request, v any) error {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("directory: could not call %s: %w", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBody))
+	if err != nil {
+		return fmt.Errorf("directory: could not read response from %s: %w", req.URL.Host, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("directory: %s %s: status %d: %s", req.Method, req.URL.Path, res.StatusCode, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("directory: could not parse response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+// Function to return s, or def when s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// Function to decode a response body into v
+func unmarshal(data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("directory: could not parse response: %w", err)
+	}
+	return nil
+}
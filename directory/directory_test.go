@@ -0,0 +1,68 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// sourceFunc adapts a function to the Source interface in tests
+type sourceFunc func(ctx context.Context) ([]joke.Member, error)
+
+func (f sourceFunc) Members(ctx context.Context) ([]joke.Member, error) { return f(ctx) }
+
+func TestNames(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Fails before the first refresh", func(t *testing.T) {
+		n := New(sourceFunc(func(ctx context.Context) ([]joke.Member, error) { return nil, nil }), 0, nil)
+		if _, err := n.Name(context.Background()); !errors.Is(err, joke.ErrNameUpstream) {
+			t.Errorf("Expected ErrNameUpstream; got %v", err)
+		}
+	})
+
+	t.Run("Keeps the last members when a refresh fails", func(t *testing.T) {
+		members := []joke.Member{{FirstName: "Ada", LastName: "Lovelace"}}
+		var fail error
+		n := New(sourceFunc(func(ctx context.Context) ([]joke.Member, error) { return members, fail }), 0, nil)
+
+		if err := n.Refresh(context.Background()); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		fail = errors.New("directory down")
+		if err := n.Refresh(context.Background()); err == nil {
+			t.Error("Expected the refresh to fail")
+		}
+		if name, err := n.Name(context.Background()); err != nil || name.FirstName != "Ada" {
+			t.Errorf("Expected Ada; got %v, %v", name, err)
+		}
+
+		// An empty directory isn't swapped in either
+		fail, members = nil, nil
+		if err := n.Refresh(context.Background()); err == nil {
+			t.Error("Expected an empty directory to fail")
+		}
+	})
+}
+
+func TestSplitName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][2]string{
+		"Ada Lovelace":            {"Ada", "Lovelace"},
+		"  Mary  Jane   Watson  ": {"Mary Jane", "Watson"},
+		"Ludwig van Beethoven":    {"Ludwig", "van Beethoven"},
+		"Vincent van der Berg":    {"Vincent", "van der Berg"},
+		"Mary Smith-Jones":        {"Mary", "Smith-Jones"},
+		"de Gaulle":               {"de", "Gaulle"},
+		"Cher":                    {"Cher", ""},
+		"":                        {"", ""},
+	}
+	for display, want := range tests {
+		if first, last := splitName(display); first != want[0] || last != want[1] {
+			t.Errorf("%q: expected %q %q; got %q %q", display, want[0], want[1], first, last)
+		}
+	}
+}
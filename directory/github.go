@@ -0,0 +1,124 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Base URL of the GitHub REST API and how many contributors it lists per page
+const (
+	defaultGitHubEndpoint = "https://api.github.com"
+	githubPageSize        = 100
+)
+
+/*
+	 GitHub lists the contributors of a GitHub repository
+
+		Contributors are listed with their logins only, so each one's
+		profile is fetched once for their name and remembered across
+		refreshes; contributors without a name on their profile are
+		picked by login. Bots are left out. Without a Token, GitHub
+		allows 60 requests an hour, enough for about as many new
+		contributors.
+*/
+type GitHub struct {
+	// Repo is the repository as owner/name
+	Repo string
+	// Token is an optional personal access token raising the rate limit
+	Token string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+
+	// Base URL of the API, replaced in tests
+	endpoint string
+
+	mu    sync.Mutex
+	names map[string]joke.Member
+}
+
+// struct to hold a contributor as GitHub lists them
+type githubContributor struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// Members lists the people who contributed to Repo
+func (g *GitHub) Members(ctx context.Context) ([]joke.Member, error) {
+	owner, repo, ok := strings.Cut(g.Repo, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("directory: GitHub repository %q is not owner/name", g.Repo)
+	}
+
+	var members []joke.Member
+	for page := 1; ; page++ {
+		var contributors []githubContributor
+		path := "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo) + "/contributors"
+		params := url.Values{"per_page": {strconv.Itoa(githubPageSize)}, "page": {strconv.Itoa(page)}}
+		if err := g.get(ctx, path+"?"+params.Encode(), &contributors); err != nil {
+			return nil, err
+		}
+		for _, c := range contributors {
+			if c.Type == "Bot" {
+				continue
+			}
+			m, err := g.member(ctx, c.Login)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, m)
+		}
+		// A short page is the last one
+		if len(contributors) < githubPageSize {
+			return members, nil
+		}
+	}
+}
+
+// Function to return the name of the contributor with login, fetching their profile the first time
+func (g *GitHub) member(ctx context.Context, login string) (joke.Member, error) {
+	g.mu.Lock()
+	m, ok := g.names[login]
+	g.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	if err := g.get(ctx, "/users/"+url.PathEscape(login), &user); err != nil {
+		return joke.Member{}, err
+	}
+	m.FirstName, m.LastName = splitName(user.Name)
+	if m.FirstName == "" {
+		m.FirstName = login
+	}
+
+	g.mu.Lock()
+	if g.names == nil {
+		g.names = make(map[string]joke.Member)
+	}
+	g.names[login] = m
+	g.mu.Unlock()
+	return m, nil
+}
+
+// Function to GET path from the API and decode the response into v
+func (g *GitHub) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, orDefault(g.endpoint, defaultGitHubEndpoint)+path, nil)
+	if err != nil {
+		return fmt.Errorf("directory: could not create request: %w", err)
+	}
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	return doJSON(g.Client, req, v)
+}
@@ -0,0 +1,108 @@
+package directory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Function to start a fake GitHub API, returning a GitHub using it and counting profile lookups
+func newFakeGitHub(t *testing.T, lookups *atomic.Int32) *GitHub {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/jokes/contributors", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp-token" {
+			http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		// A full first page of one contributor, then a short one
+		if r.URL.Query().Get("page") == "1" {
+			page := []byte(`[`)
+			for i := range githubPageSize {
+				if i > 0 {
+					page = append(page, ',')
+				}
+				page = append(page, `{"login": "ada", "type": "User"}`...)
+			}
+			w.Write(append(page, ']'))
+			return
+		}
+		w.Write([]byte(`[{"login": "grace", "type": "User"}, {"login": "dependabot[bot]", "type": "Bot"}]`))
+	})
+	mux.HandleFunc("GET /users/{login}", func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		switch r.PathValue("login") {
+		case "ada":
+			w.Write([]byte(`{"login": "ada", "name": "Ada Lovelace"}`))
+		case "grace":
+			w.Write([]byte(`{"login": "grace", "name": null}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &GitHub{Repo: "acme/jokes", Token: "ghp-token", endpoint: srv.URL}
+}
+
+func TestGitHub(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists the repository's contributors", func(t *testing.T) {
+		t.Parallel()
+		var lookups atomic.Int32
+		g := newFakeGitHub(t, &lookups)
+		members, err := g.Members(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if len(members) != githubPageSize+1 {
+			t.Fatalf("Expected %d members; got %d", githubPageSize+1, len(members))
+		}
+		if members[0] != (joke.Member{FirstName: "Ada", LastName: "Lovelace"}) {
+			t.Errorf("Expected Ada Lovelace; got %v", members[0])
+		}
+		// grace has no name, so is picked by login
+		if last := members[len(members)-1]; last != (joke.Member{FirstName: "grace"}) {
+			t.Errorf("Expected grace; got %v", last)
+		}
+	})
+
+	t.Run("looks each profile up once", func(t *testing.T) {
+		t.Parallel()
+		var lookups atomic.Int32
+		g := newFakeGitHub(t, &lookups)
+		for range 2 {
+			if _, err := g.Members(context.Background()); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+		}
+		if n := lookups.Load(); n != 2 {
+			t.Errorf("Expected 2 lookups; got %d", n)
+		}
+	})
+
+	t.Run("reports API errors", func(t *testing.T) {
+		t.Parallel()
+		var lookups atomic.Int32
+		g := newFakeGitHub(t, &lookups)
+		g.Token = ""
+		if _, err := g.Members(context.Background()); err == nil {
+			t.Error("Expected an error; got nil")
+		}
+	})
+
+	t.Run("rejects repositories not named owner/name", func(t *testing.T) {
+		t.Parallel()
+		for _, repo := range []string{"", "acme", "acme/", "/jokes", "acme/jokes/extra"} {
+			g := &GitHub{Repo: repo}
+			if _, err := g.Members(context.Background()); err == nil {
+				t.Errorf("Expected an error for %q; got nil", repo)
+			}
+		}
+	})
+}
@@ -0,0 +1,260 @@
+package directory
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Base URL of the Admin SDK Directory API
+const defaultGoogleEndpoint = "https://admin.googleapis.com/admin/directory/v1"
+
+// Read-only scopes the service account is granted
+const googleScopes = "https://www.googleapis.com/auth/admin.directory.user.readonly https://www.googleapis.com/auth/admin.directory.group.member.readonly"
+
+/*
+	 Google lists the users of a Google Workspace directory
+
+		It authenticates as a service account with domain-wide
+		delegation, acting as Subject, an admin of the workspace.
+		Users are listed with Query, e.g. "orgUnitPath='/Engineering'",
+		or only the members of Group when set. Suspended users are
+		left out. Build one with LoadGoogle.
+*/
+type Google struct {
+	// Subject is the admin the service account acts as
+	Subject string
+	// Query filters the users, in the Directory API's search syntax
+	Query string
+	// Group, an email or ID, limits the users to its direct members
+	Group string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+
+	email    string
+	key      *rsa.PrivateKey
+	tokenURL string
+	endpoint string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// struct to hold the fields of a service account key file used
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadGoogle returns a Google authenticating with the service account key file at path
+func LoadGoogle(path, subject string) (*Google, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("directory: could not read %s: %w", path, err)
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("directory: could not parse %s: %w", path, err)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("directory: no private key in %s", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("directory: could not parse the private key in %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("directory: the private key in %s is not RSA", path)
+	}
+	if subject == "" {
+		return nil, errors.New("directory: a Google Workspace admin to act as is required")
+	}
+	return &Google{
+		Subject:  subject,
+		email:    sa.ClientEmail,
+		key:      key,
+		tokenURL: orDefault(sa.TokenURI, "https://oauth2.googleapis.com/token"),
+		endpoint: defaultGoogleEndpoint,
+	}, nil
+}
+
+// struct to hold the parts of a Directory API user used
+type googleUser struct {
+	PrimaryEmail string `json:"primaryEmail"`
+	Suspended    bool   `json:"suspended"`
+	Name         struct {
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	} `json:"name"`
+}
+
+// Members lists the users matching Query, or the members of Group
+func (g *Google) Members(ctx context.Context) ([]joke.Member, error) {
+	var users []googleUser
+	var err error
+	if g.Group != "" {
+		users, err = g.groupUsers(ctx)
+	} else {
+		users, err = g.listUsers(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var members []joke.Member
+	for _, u := range users {
+		if u.Suspended || u.Name.GivenName == "" {
+			continue
+		}
+		members = append(members, joke.Member{FirstName: u.Name.GivenName, LastName: u.Name.FamilyName})
+	}
+	return members, nil
+}
+
+// Function to list the users matching Query, page by page
+func (g *Google) listUsers(ctx context.Context) ([]googleUser, error) {
+	var users []googleUser
+	q := url.Values{"customer": {"my_customer"}, "maxResults": {"500"}}
+	if g.Query != "" {
+		q.Set("query", g.Query)
+	}
+	for {
+		var page struct {
+			Users         []googleUser `json:"users"`
+			NextPageToken string       `json:"nextPageToken"`
+		}
+		if err := g.get(ctx, "/users?"+q.Encode(), &page); err != nil {
+			return nil, err
+		}
+		users = append(users, page.Users...)
+		if page.NextPageToken == "" {
+			return users, nil
+		}
+		q.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Function to list the users directly in Group, which lists only their IDs
+func (g *Google) groupUsers(ctx context.Context) ([]googleUser, error) {
+	var users []googleUser
+	q := url.Values{"maxResults": {"200"}}
+	for {
+		var page struct {
+			Members []struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			} `json:"members"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := g.get(ctx, "/groups/"+url.PathEscape(g.Group)+"/members?"+q.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Members {
+			// Nested groups and external members have no user to look up
+			if m.Type != "USER" {
+				continue
+			}
+			var u googleUser
+			if err := g.get(ctx, "/users/"+url.PathEscape(m.ID), &u); err != nil {
+				return nil, err
+			}
+			users = append(users, u)
+		}
+		if page.NextPageToken == "" {
+			return users, nil
+		}
+		q.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Function to GET path of the Directory API into v
+func (g *Google) get(ctx context.Context, path string, v any) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+path, nil)
+	if err != nil {
+		return fmt.Errorf("directory: could not create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doJSON(g.Client, req, v)
+}
+
+/*
+	 Function to return an access token for Subject, exchanging a
+	 signed assertion for a new one when it is about to expire
+
+		This is the OAuth 2.0 JWT bearer flow Google uses for service
+		accounts (RFC 7523).
+*/
+func (g *Google) accessToken(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token != "" && time.Now().Before(g.expires) {
+		return g.token, nil
+	}
+
+	assertion, err := g.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("directory: could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doJSON(g.Client, req, &res); err != nil {
+		return "", err
+	}
+	g.token = res.AccessToken
+	// Renew a minute early, so a token doesn't expire mid-refresh
+	g.expires = time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
+
+// Function to return a JWT signed with the service account key, asking for a token for Subject
+func (g *Google) assertion(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   g.email,
+		"sub":   g.Subject,
+		"scope": googleScopes,
+		"aud":   g.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(nil, g.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("directory: could not sign assertion: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
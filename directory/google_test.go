@@ -0,0 +1,135 @@
+package directory
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Function to start a fake Google token endpoint and Directory API, returning a Google using them
+func newFakeGoogle(t *testing.T, tokens *int) *Google {
+	t.Helper()
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	users := map[string]string{
+		"1": `{"primaryEmail": "ada@example.com", "name": {"givenName": "Ada", "familyName": "Lovelace"}}`,
+		"2": `{"primaryEmail": "grace@example.com", "name": {"givenName": "Grace", "familyName": "Hopper"}}`,
+		"3": `{"primaryEmail": "gone@example.com", "suspended": true, "name": {"givenName": "Gone", "familyName": "User"}}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		*tokens++
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			http.Error(w, "bad assertion", http.StatusBadRequest)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+	})
+	// Function to wrap h, refusing requests without the token
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("GET /users", authed(func(w http.ResponseWriter, r *http.Request) {
+		// Two pages: Ada then Grace and the suspended user
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"users": [` + users["1"] + `], "nextPageToken": "next"}`))
+			return
+		}
+		w.Write([]byte(`{"users": [` + users["2"] + `,` + users["3"] + `]}`))
+	}))
+	mux.HandleFunc("GET /groups/{group}/members", authed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"members": [{"id": "2", "type": "USER"}, {"id": "9", "type": "GROUP"}]}`))
+	}))
+	mux.HandleFunc("GET /users/{id}", authed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(users[r.PathValue("id")]))
+	}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "key.json")
+	sa, _ := json.Marshal(serviceAccount{
+		ClientEmail: "jokes@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    srv.URL + "/token",
+	})
+	os.WriteFile(path, sa, 0o600)
+	g, err := LoadGoogle(path, "admin@example.com")
+	if err != nil {
+		t.Fatalf("Could not load service account: %v", err)
+	}
+	g.endpoint = srv.URL
+	return g
+}
+
+func TestGoogle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Lists active users across pages", func(t *testing.T) {
+		var tokens int
+		g := newFakeGoogle(t, &tokens)
+
+		members, err := g.Members(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if len(members) != 2 || members[0].FirstName != "Ada" || members[1].LastName != "Hopper" {
+			t.Errorf("Unexpected members: %+v", members)
+		}
+		// The token is reused until it expires
+		g.Members(context.Background())
+		if tokens != 1 {
+			t.Errorf("Expected one token request; got %d", tokens)
+		}
+	})
+
+	t.Run("Lists the members of a group", func(t *testing.T) {
+		var tokens int
+		g := newFakeGoogle(t, &tokens)
+		g.Group = "eng@example.com"
+
+		members, err := g.Members(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if len(members) != 1 || members[0].FirstName != "Grace" {
+			t.Errorf("Expected only Grace; got %+v", members)
+		}
+	})
+
+	t.Run("Rejects invalid key files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.json")
+		os.WriteFile(path, []byte(`{"private_key": "not pem"}`), 0o600)
+		if _, err := LoadGoogle(path, "admin@example.com"); err == nil {
+			t.Error("Expected an error for a key file without a key")
+		}
+		if _, err := LoadGoogle(filepath.Join(t.TempDir(), "missing.json"), "admin@example.com"); err == nil {
+			t.Error("Expected an error for a missing key file")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Base URL of the Slack Web API
+const defaultSlackEndpoint = "https://slack.com/api"
+
+/*
+	 Slack lists the members of a Slack channel
+
+		Token is a bot token with the channels:read (groups:read for
+		private channels) and users:read scopes, and the bot must be
+		in Channel. Bots and deactivated users are left out.
+*/
+type Slack struct {
+	// Token is sent as a bearer token
+	Token string
+	// Channel is the channel's ID, e.g. C0123456789
+	Channel string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+
+	// Base URL of the API, replaced in tests
+	endpoint string
+}
+
+// struct to hold the envelope every Slack API response shares
+type slackResponse struct {
+	OK               bool   `json:"ok"`
+	Error            string `json:"error"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// struct to hold the parts of a Slack user used
+type slackUser struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+	IsBot   bool   `json:"is_bot"`
+	Profile struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		RealName  string `json:"real_name"`
+	} `json:"profile"`
+}
+
+/*
+	 Members lists the people in Channel
+
+		The channel lists only member IDs, so the workspace's users are
+		listed too for their names; two paged calls rather than one
+		per member, which Slack rate limits.
+*/
+func (s *Slack) Members(ctx context.Context) ([]joke.Member, error) {
+	if s.Channel == "" {
+		return nil, errors.New("directory: a Slack channel is required")
+	}
+	inChannel := map[string]bool{}
+	err := s.pages(ctx, "conversations.members", url.Values{"channel": {s.Channel}, "limit": {"1000"}}, func(page []byte) error {
+		var res struct {
+			Members []string `json:"members"`
+		}
+		if err := unmarshal(page, &res); err != nil {
+			return err
+		}
+		for _, id := range res.Members {
+			inChannel[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var members []joke.Member
+	err = s.pages(ctx, "users.list", url.Values{"limit": {"200"}}, func(page []byte) error {
+		var res struct {
+			Members []slackUser `json:"members"`
+		}
+		if err := unmarshal(page, &res); err != nil {
+			return err
+		}
+		for _, u := range res.Members {
+			if !inChannel[u.ID] || u.Deleted || u.IsBot || u.ID == "USLACKBOT" {
+				continue
+			}
+			first, last := u.Profile.FirstName, u.Profile.LastName
+			if first == "" {
+				first, last = splitName(u.Profile.RealName)
+			}
+			if first != "" {
+				members = append(members, joke.Member{FirstName: first, LastName: last})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Function to call method with params, passing each page to fn until the cursor runs out
+func (s *Slack) pages(ctx context.Context, method string, params url.Values, fn func(page []byte) error) error {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, orDefault(s.endpoint, defaultSlackEndpoint)+"/"+method+"?"+params.Encode(), nil)
+		if err != nil {
+			return fmt.Errorf("directory: could not create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+		var page json.RawMessage
+		if err := doJSON(s.Client, req, &page); err != nil {
+			return err
+		}
+		var res slackResponse
+		if err := unmarshal(page, &res); err != nil {
+			return err
+		}
+		// Slack reports failures in the body with a 200
+		if !res.OK {
+			return fmt.Errorf("directory: Slack %s: %s", method, res.Error)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if res.ResponseMetadata.NextCursor == "" {
+			return nil
+		}
+		params.Set("cursor", res.ResponseMetadata.NextCursor)
+	}
+}
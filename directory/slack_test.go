@@ -0,0 +1,92 @@
+package directory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Function to start a fake Slack Web API, returning a Slack using it
+func newFakeSlack(t *testing.T) *Slack {
+	t.Helper()
+	mux := http.NewServeMux()
+	// Function to wrap h, answering requests without the token as Slack does, with a 200
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer xoxb-token" {
+				w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("GET /conversations.members", authed(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("channel") != "C1" {
+			w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+			return
+		}
+		// Two pages of member IDs
+		if r.URL.Query().Get("cursor") == "" {
+			w.Write([]byte(`{"ok": true, "members": ["U1", "U2"], "response_metadata": {"next_cursor": "next"}}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true, "members": ["U3", "U4", "B1"], "response_metadata": {"next_cursor": ""}}`))
+	}))
+	mux.HandleFunc("GET /users.list", authed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true, "members": [
+			{"id": "U1", "profile": {"first_name": "Ada", "last_name": "Lovelace", "real_name": "Ada L"}},
+			{"id": "U2", "profile": {"real_name": "Grace Brewster Hopper"}},
+			{"id": "U3", "deleted": true, "profile": {"first_name": "Gone"}},
+			{"id": "U4", "profile": {"real_name": ""}},
+			{"id": "U5", "profile": {"first_name": "Not", "last_name": "Here"}},
+			{"id": "B1", "is_bot": true, "profile": {"first_name": "Bot"}}
+		]}`))
+	}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &Slack{Token: "xoxb-token", Channel: "C1", endpoint: srv.URL}
+}
+
+func TestSlack(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists the channel's people", func(t *testing.T) {
+		t.Parallel()
+		s := newFakeSlack(t)
+		members, err := s.Members(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		want := []joke.Member{{FirstName: "Ada", LastName: "Lovelace"}, {FirstName: "Grace Brewster", LastName: "Hopper"}}
+		if len(members) != len(want) {
+			t.Fatalf("Expected %v; got %v", want, members)
+		}
+		for i := range want {
+			if members[i] != want[i] {
+				t.Errorf("Expected %v; got %v", want[i], members[i])
+			}
+		}
+	})
+
+	t.Run("reports Slack errors", func(t *testing.T) {
+		t.Parallel()
+		s := newFakeSlack(t)
+		s.Token = "wrong"
+		_, err := s.Members(context.Background())
+		if err == nil || err.Error() != "directory: Slack conversations.members: invalid_auth" {
+			t.Errorf("Expected an invalid_auth error; got %v", err)
+		}
+	})
+
+	t.Run("requires a channel", func(t *testing.T) {
+		t.Parallel()
+		s := newFakeSlack(t)
+		s.Channel = ""
+		if _, err := s.Members(context.Background()); err == nil {
+			t.Error("Expected an error; got nil")
+		}
+	})
+}
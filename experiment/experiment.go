@@ -0,0 +1,237 @@
+/*
+	 Package experiment splits requests into A/B buckets, each served
+	 by its own joke source, so sources can be compared on live traffic.
+
+		A client is assigned a bucket by hashing its API key, or without
+		one a random ID kept in a cookie, so it stays in the same bucket
+		across requests and replicas. Clients rate the jokes they're
+		served, and calls and ratings are reported per bucket.
+*/
+package experiment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// Cookie holding the ID of clients without an API key
+const CookieName = "joke_experiment"
+
+// Header naming the bucket a response was served from
+const BucketHeader = "X-Experiment-Bucket"
+
+// Lowest and highest rating a client may give a joke
+const (
+	MinScore = 1
+	MaxScore = 5
+)
+
+// Errors returned by Rate
+var (
+	ErrNoBucket = errors.New("experiment: request has no bucket")
+	ErrScore    = fmt.Errorf("experiment: score must be %d-%d", MinScore, MaxScore)
+)
+
+/*
+	 Bucket is one arm of an experiment
+
+		A bucket serves jokes from JokeURL or the Mad Libs templates
+		file MadLibs when set, and from the server's joke source
+		otherwise, so one bucket is usually left as the control.
+*/
+type Bucket struct {
+	Name string `json:"name"`
+	// Weight is the bucket's share of clients relative to the others
+	Weight  int    `json:"weight"`
+	JokeURL string `json:"joke_url,omitempty"`
+	MadLibs string `json:"madlibs,omitempty"`
+
+	// Jokes serves the bucket, set by the caller from JokeURL or
+	// MadLibs; nil serves the jokes of the wrapped provider
+	Jokes joke.JokeProvider `json:"-"`
+}
+
+/*
+	 Experiment assigns requests to buckets
+
+		Build one with New or Load, and set the observers before use.
+*/
+type Experiment struct {
+	Name    string    `json:"name"`
+	Buckets []*Bucket `json:"buckets"`
+
+	// ObserveCall, when not nil, is called with each joke call made
+	// for a bucket, its latency and error
+	ObserveCall func(bucket string, d time.Duration, err error)
+	// ObserveRating, when not nil, is called with each rating
+	ObserveRating func(bucket string, score int)
+
+	total int
+}
+
+/*
+	 Load reads an experiment from a JSON file such as:
+
+		{"name": "source-2024-06", "buckets": [
+		  {"name": "control", "weight": 50},
+		  {"name": "madlibs", "weight": 50, "madlibs": "templates.json"}]}
+*/
+func Load(path string) (*Experiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("experiment: could not read %s: %w", path, err)
+	}
+	var e Experiment
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("experiment: could not parse %s: %w", path, err)
+	}
+	return New(e.Name, e.Buckets...)
+}
+
+/*
+	 New returns an Experiment splitting clients across buckets by weight
+
+		The name seeds the assignment, so a new experiment reshuffles
+		clients rather than keeping the buckets of the last one.
+*/
+func New(name string, buckets ...*Bucket) (*Experiment, error) {
+	if name == "" {
+		return nil, errors.New("experiment: missing name")
+	}
+	if len(buckets) < 2 {
+		return nil, errors.New("experiment: needs at least 2 buckets")
+	}
+	e := &Experiment{Name: name, Buckets: buckets}
+	seen := make(map[string]bool)
+	for _, b := range buckets {
+		if b.Name == "" || seen[b.Name] {
+			return nil, fmt.Errorf("experiment: invalid or duplicate bucket name %q", b.Name)
+		}
+		if b.Weight < 1 {
+			return nil, fmt.Errorf("experiment: bucket %s needs a positive weight", b.Name)
+		}
+		if b.JokeURL != "" && b.MadLibs != "" {
+			return nil, fmt.Errorf("experiment: bucket %s sets both joke_url and madlibs", b.Name)
+		}
+		seen[b.Name] = true
+		e.total += b.Weight
+	}
+	return e, nil
+}
+
+// Assign returns the bucket of the client identified by id
+func (e *Experiment) Assign(id string) *Bucket {
+	h := fnv.New32a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	n := int(h.Sum32() % uint32(e.total))
+	for _, b := range e.Buckets {
+		if n < b.Weight {
+			return b
+		}
+		n -= b.Weight
+	}
+	return e.Buckets[len(e.Buckets)-1]
+}
+
+// Key type for the request's bucket in a context
+type contextKey struct{}
+
+// FromContext returns the bucket of the request, nil outside an experiment
+func FromContext(ctx context.Context) *Bucket {
+	b, _ := ctx.Value(contextKey{}).(*Bucket)
+	return b
+}
+
+/*
+	 Middleware assigns each request a bucket, available through
+	 FromContext and named in the X-Experiment-Bucket header
+
+		Clients are identified by their API key, or by the ID in the
+		joke_experiment cookie, which is set on their first request.
+*/
+func (e *Experiment) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := middleware.RequestKey(r)
+			if id == "" {
+				id = clientID(w, r)
+			}
+			b := e.Assign(id)
+			w.Header().Set(BucketHeader, b.Name)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, b)))
+		})
+	}
+}
+
+// Function to return the ID in r's cookie, setting a new one on w without it
+func clientID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(CookieName); err == nil && c.Value != "" {
+		return c.Value
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+/*
+	 Jokes returns p wrapped to serve each request from its bucket's
+	 provider, recording the call under the bucket
+
+		Calls outside a request, such as self-checks, go to p and are
+		not recorded.
+*/
+func (e *Experiment) Jokes(p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		b := FromContext(ctx)
+		if b == nil {
+			return p.Joke(ctx, firstName, lastName)
+		}
+		provider := p
+		if b.Jokes != nil {
+			provider = b.Jokes
+		}
+		start := time.Now()
+		text, err := provider.Joke(ctx, firstName, lastName)
+		if e.ObserveCall != nil {
+			e.ObserveCall(b.Name, time.Since(start), err)
+		}
+		return text, err
+	})
+}
+
+// Rate records a rating of score by the client of ctx, counted under its bucket
+func (e *Experiment) Rate(ctx context.Context, score int) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return ErrNoBucket
+	}
+	if score < MinScore || score > MaxScore {
+		return ErrScore
+	}
+	if e.ObserveRating != nil {
+		e.ObserveRating(b.Name, score)
+	}
+	return nil
+}
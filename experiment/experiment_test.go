@@ -0,0 +1,166 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Function to return an experiment with a control and a treatment bucket of equal weight
+func newTestExperiment(t *testing.T) *Experiment {
+	t.Helper()
+	e, err := New("test", &Bucket{Name: "control", Weight: 1}, &Bucket{Name: "treatment", Weight: 1})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	return e
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "experiment.json")
+	os.WriteFile(path, []byte(`{"name": "source", "buckets": [
+		{"name": "control", "weight": 1},
+		{"name": "madlibs", "weight": 3, "madlibs": "templates.json"}]}`), 0o600)
+	e, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if e.Name != "source" || len(e.Buckets) != 2 || e.Buckets[1].MadLibs != "templates.json" || e.total != 4 {
+		t.Errorf("Unexpected experiment: %+v", e)
+	}
+
+	for name, buckets := range map[string][]*Bucket{
+		"one bucket":  {{Name: "a", Weight: 1}},
+		"duplicate":   {{Name: "a", Weight: 1}, {Name: "a", Weight: 1}},
+		"zero weight": {{Name: "a", Weight: 1}, {Name: "b"}},
+		"two sources": {{Name: "a", Weight: 1}, {Name: "b", Weight: 1, JokeURL: "http://x", MadLibs: "m.json"}},
+		"unnamed arm": {{Name: "a", Weight: 1}, {Weight: 1}},
+	} {
+		if _, err := New("x", buckets...); err == nil {
+			t.Errorf("%s: expected an error; got nil", name)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	t.Parallel()
+
+	e := newTestExperiment(t)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprint("client-", i)
+		b := e.Assign(id)
+		if e.Assign(id) != b {
+			t.Fatalf("Expected %s to keep its bucket", id)
+		}
+		counts[b.Name]++
+	}
+	// Equal weights split clients roughly in half
+	if counts["control"] < 400 || counts["treatment"] < 400 {
+		t.Errorf("Expected an even split; got %v", counts)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	e := newTestExperiment(t)
+	var got *Bucket
+	handler := e.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	t.Run("Sticks to the API key", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if got != e.Assign("secret") || rec.Header().Get(BucketHeader) != got.Name {
+			t.Errorf("Expected the key's bucket; got %+v", got)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Error("Expected no cookie for a client with a key")
+		}
+	})
+
+	t.Run("Sticks to the cookie", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != CookieName || got != e.Assign(cookies[0].Value) {
+			t.Fatalf("Expected a cookie naming the client; got %v", cookies)
+		}
+		first := got
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(cookies[0])
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if got != first || len(rec.Result().Cookies()) != 0 {
+			t.Errorf("Expected the same bucket without a new cookie; got %s", got.Name)
+		}
+	})
+}
+
+func TestJokes(t *testing.T) {
+	t.Parallel()
+
+	e := newTestExperiment(t)
+	e.Buckets[1].Jokes = joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		return "", errors.New("treatment down")
+	})
+	var calls []string
+	e.ObserveCall = func(bucket string, d time.Duration, err error) {
+		calls = append(calls, fmt.Sprint(bucket, " ", err))
+	}
+	jokes := e.Jokes(joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		return "control joke", nil
+	}))
+
+	if text, err := jokes.Joke(context.WithValue(context.Background(), contextKey{}, e.Buckets[0]), "A", "B"); text != "control joke" || err != nil {
+		t.Errorf("Expected the control joke; got %q, %v", text, err)
+	}
+	if _, err := jokes.Joke(context.WithValue(context.Background(), contextKey{}, e.Buckets[1]), "A", "B"); err == nil {
+		t.Error("Expected the treatment's error")
+	}
+	if _, err := jokes.Joke(context.Background(), "A", "B"); err != nil {
+		t.Errorf("Expected the wrapped provider outside a request; got %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "control <nil>" || calls[1] != "treatment treatment down" {
+		t.Errorf("Expected one call per bucket observed; got %v", calls)
+	}
+}
+
+func TestRate(t *testing.T) {
+	t.Parallel()
+
+	e := newTestExperiment(t)
+	var ratings []string
+	e.ObserveRating = func(bucket string, score int) {
+		ratings = append(ratings, fmt.Sprint(bucket, score))
+	}
+	ctx := context.WithValue(context.Background(), contextKey{}, e.Buckets[1])
+
+	if err := e.Rate(ctx, 4); err != nil {
+		t.Errorf("Expected no error; got %v", err)
+	}
+	if err := e.Rate(ctx, 6); !errors.Is(err, ErrScore) {
+		t.Errorf("Expected ErrScore; got %v", err)
+	}
+	if err := e.Rate(context.Background(), 3); !errors.Is(err, ErrNoBucket) {
+		t.Errorf("Expected ErrNoBucket; got %v", err)
+	}
+	if len(ratings) != 1 || ratings[0] != "treatment4" {
+		t.Errorf("Expected one rating for treatment; got %v", ratings)
+	}
+}
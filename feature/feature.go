@@ -0,0 +1,184 @@
+/*
+	 Package feature gates new behaviors behind flags loaded from a
+	 JSON file and the environment.
+
+		The file maps flag names to booleans:
+
+			{"json_default": true}
+
+		Environment variables named FEATURE_ followed by the upper-case
+		flag name, e.g. FEATURE_JSON_DEFAULT=true, override the file.
+		Watch reloads the file when it changes, so flags can be flipped
+		per environment without a rebuild or restart.
+*/
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the flags understood by the server
+const (
+	// JSONDefault serves jokes as JSON instead of plain text
+	JSONDefault = "json_default"
+)
+
+// Prefix of environment variables overriding flags
+const EnvPrefix = "FEATURE_"
+
+/*
+	 Flags holds the current value of every feature flag
+
+		A nil *Flags reports every flag as disabled, so callers
+		can leave it unset.
+*/
+type Flags struct {
+	path    string
+	environ func() []string
+	logger  *slog.Logger
+
+	mu      sync.RWMutex
+	values  map[string]bool
+	modTime time.Time
+}
+
+/*
+	 New returns flags loaded from the JSON file at path and the
+	 environment
+
+		An empty path loads flags from the environment only.
+*/
+func New(path string, logger *slog.Logger) (*Flags, error) {
+	return newFlags(path, os.Environ, logger)
+}
+
+// Function to build flags reading the environment from environ
+func newFlags(path string, environ func() []string, logger *slog.Logger) (*Flags, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	f := &Flags{path: path, environ: environ, logger: logger}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Enabled reports whether the flag name is enabled
+func (f *Flags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.values[name]
+}
+
+// All returns a copy of every flag that has been set
+func (f *Flags) All() map[string]bool {
+	all := make(map[string]bool)
+	if f == nil {
+		return all
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for name, on := range f.values {
+		all[name] = on
+	}
+	return all
+}
+
+/*
+	 Reload reads the flag file and environment again
+
+		On error the current values are kept.
+*/
+func (f *Flags) Reload() error {
+	values := make(map[string]bool)
+	var modTime time.Time
+
+	// Read the flag file
+	if f.path != "" {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return fmt.Errorf("feature: could not stat %s: %w", f.path, err)
+		}
+		modTime = info.ModTime()
+
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("feature: could not read %s: %w", f.path, err)
+		}
+		if err := json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("feature: could not parse %s: %w", f.path, err)
+		}
+	}
+
+	// Environment variables override the file
+	for _, kv := range f.environ() {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("feature: invalid value for %s: %q", key, value)
+		}
+		values[strings.ToLower(strings.TrimPrefix(key, EnvPrefix))] = on
+	}
+
+	f.mu.Lock()
+	f.values = values
+	f.modTime = modTime
+	f.mu.Unlock()
+	return nil
+}
+
+/*
+	 Watch reloads the flag file every interval when its
+	 modification time changes, until ctx is cancelled
+
+		Reload errors are logged and the previous values kept, so a
+		half-written file never disables every flag.
+*/
+func (f *Flags) Watch(ctx context.Context, interval time.Duration) {
+	if f.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Skip the reload when the file is unchanged
+		info, err := os.Stat(f.path)
+		if err != nil {
+			f.logger.WarnContext(ctx, "feature: could not stat flag file", "path", f.path, "error", err)
+			continue
+		}
+		f.mu.RLock()
+		unchanged := info.ModTime().Equal(f.modTime)
+		f.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+
+		if err := f.Reload(); err != nil {
+			f.logger.WarnContext(ctx, "feature: keeping previous flags", "error", err)
+			continue
+		}
+		f.logger.InfoContext(ctx, "feature: reloaded flags", "path", f.path, "flags", f.All())
+	}
+}
@@ -0,0 +1,137 @@
+package feature
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Function to write a flag file in a temporary directory and return its path
+func writeFlagFile(t *testing.T, path, contents string) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "features.json")
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Could not write flag file: %v", err)
+	}
+	return path
+}
+
+// Function to return an environment holding only vars
+func env(vars ...string) func() []string {
+	return func() []string { return vars }
+}
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Loads file", func(t *testing.T) {
+		path := writeFlagFile(t, "", `{"json_default": true, "new_ui": false}`)
+
+		f, err := newFlags(path, env(), nil)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if !f.Enabled(JSONDefault) {
+			t.Error("Expected json_default to be enabled")
+		}
+		if f.Enabled("new_ui") || f.Enabled("unknown") {
+			t.Error("Expected new_ui and unknown flags to be disabled")
+		}
+	})
+
+	t.Run("Environment overrides file", func(t *testing.T) {
+		path := writeFlagFile(t, "", `{"json_default": true}`)
+
+		f, err := newFlags(path, env("FEATURE_JSON_DEFAULT=false", "FEATURE_NEW_UI=1", "HOME=/root"), nil)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if f.Enabled(JSONDefault) {
+			t.Error("Expected json_default to be disabled by the environment")
+		}
+		if !f.Enabled("new_ui") {
+			t.Error("Expected new_ui to be enabled by the environment")
+		}
+	})
+
+	t.Run("Environment only", func(t *testing.T) {
+		f, err := newFlags("", env("FEATURE_JSON_DEFAULT=true"), nil)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if !f.Enabled(JSONDefault) {
+			t.Error("Expected json_default to be enabled")
+		}
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		if _, err := newFlags(filepath.Join(t.TempDir(), "missing.json"), env(), nil); err == nil {
+			t.Error("Expected error for missing file; got nil")
+		}
+		if _, err := newFlags(writeFlagFile(t, "", `{"json_default": "yes"}`), env(), nil); err == nil {
+			t.Error("Expected error for non-boolean flag; got nil")
+		}
+		if _, err := newFlags("", env("FEATURE_JSON_DEFAULT=maybe"), nil); err == nil {
+			t.Error("Expected error for invalid environment value; got nil")
+		}
+	})
+
+	t.Run("Failed reload keeps values", func(t *testing.T) {
+		path := writeFlagFile(t, "", `{"json_default": true}`)
+		f, err := newFlags(path, env(), nil)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		writeFlagFile(t, path, `{"json_default": tr`)
+		if err := f.Reload(); err == nil {
+			t.Error("Expected error for half-written file; got nil")
+		}
+		if !f.Enabled(JSONDefault) {
+			t.Error("Expected json_default to stay enabled")
+		}
+	})
+
+	t.Run("Nil flags", func(t *testing.T) {
+		var f *Flags
+		if f.Enabled(JSONDefault) {
+			t.Error("Expected nil flags to disable everything")
+		}
+		if len(f.All()) != 0 {
+			t.Errorf("Expected no flags; got %v", f.All())
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	path := writeFlagFile(t, "", `{"json_default": false}`)
+	f, err := newFlags(path, env(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Watch(ctx, 5*time.Millisecond)
+
+	// Flip the flag, moving the modification time so the change is noticed
+	writeFlagFile(t, path, `{"json_default": true}`)
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Could not touch flag file: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !f.Enabled(JSONDefault) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected json_default to be enabled after reload")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,257 @@
+// JokeService is the single definition of the joke API. The gRPC server and,
+// through grpc-gateway, the HTTP/JSON routes are both generated from it so the
+// two surfaces cannot drift apart.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: joke/v1/joke.proto
+
+package jokev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	JokeService_GetJoke_FullMethodName     = "/joke.v1.JokeService/GetJoke"
+	JokeService_GetName_FullMethodName     = "/joke.v1.JokeService/GetName"
+	JokeService_ListHistory_FullMethodName = "/joke.v1.JokeService/ListHistory"
+	JokeService_StreamJokes_FullMethodName = "/joke.v1.JokeService/StreamJokes"
+)
+
+// JokeServiceClient is the client API for JokeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type JokeServiceClient interface {
+	// GetJoke returns a random joke personalized with a random name.
+	GetJoke(ctx context.Context, in *GetJokeRequest, opts ...grpc.CallOption) (*Joke, error)
+	// GetName returns a random first and last name.
+	GetName(ctx context.Context, in *GetNameRequest, opts ...grpc.CallOption) (*Name, error)
+	// ListHistory pages through served jokes, newest first.
+	ListHistory(ctx context.Context, in *ListHistoryRequest, opts ...grpc.CallOption) (*ListHistoryResponse, error)
+	// StreamJokes sends a joke every interval until the client cancels, for
+	// display services. The next joke is only fetched once the previous one has
+	// been sent, so a slow client holds the stream back instead of queueing
+	// jokes; a joke that fails is skipped, with the stream carrying on.
+	StreamJokes(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Joke], error)
+}
+
+type jokeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJokeServiceClient(cc grpc.ClientConnInterface) JokeServiceClient {
+	return &jokeServiceClient{cc}
+}
+
+func (c *jokeServiceClient) GetJoke(ctx context.Context, in *GetJokeRequest, opts ...grpc.CallOption) (*Joke, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Joke)
+	err := c.cc.Invoke(ctx, JokeService_GetJoke_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jokeServiceClient) GetName(ctx context.Context, in *GetNameRequest, opts ...grpc.CallOption) (*Name, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Name)
+	err := c.cc.Invoke(ctx, JokeService_GetName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jokeServiceClient) ListHistory(ctx context.Context, in *ListHistoryRequest, opts ...grpc.CallOption) (*ListHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListHistoryResponse)
+	err := c.cc.Invoke(ctx, JokeService_ListHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jokeServiceClient) StreamJokes(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Joke], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JokeService_ServiceDesc.Streams[0], JokeService_StreamJokes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, Joke]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JokeService_StreamJokesClient = grpc.ServerStreamingClient[Joke]
+
+// JokeServiceServer is the server API for JokeService service.
+// All implementations must embed UnimplementedJokeServiceServer
+// for forward compatibility.
+type JokeServiceServer interface {
+	// GetJoke returns a random joke personalized with a random name.
+	GetJoke(context.Context, *GetJokeRequest) (*Joke, error)
+	// GetName returns a random first and last name.
+	GetName(context.Context, *GetNameRequest) (*Name, error)
+	// ListHistory pages through served jokes, newest first.
+	ListHistory(context.Context, *ListHistoryRequest) (*ListHistoryResponse, error)
+	// StreamJokes sends a joke every interval until the client cancels, for
+	// display services. The next joke is only fetched once the previous one has
+	// been sent, so a slow client holds the stream back instead of queueing
+	// jokes; a joke that fails is skipped, with the stream carrying on.
+	StreamJokes(*StreamRequest, grpc.ServerStreamingServer[Joke]) error
+	mustEmbedUnimplementedJokeServiceServer()
+}
+
+// UnimplementedJokeServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedJokeServiceServer struct{}
+
+func (UnimplementedJokeServiceServer) GetJoke(context.Context, *GetJokeRequest) (*Joke, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJoke not implemented")
+}
+func (UnimplementedJokeServiceServer) GetName(context.Context, *GetNameRequest) (*Name, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetName not implemented")
+}
+func (UnimplementedJokeServiceServer) ListHistory(context.Context, *ListHistoryRequest) (*ListHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListHistory not implemented")
+}
+func (UnimplementedJokeServiceServer) StreamJokes(*StreamRequest, grpc.ServerStreamingServer[Joke]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamJokes not implemented")
+}
+func (UnimplementedJokeServiceServer) mustEmbedUnimplementedJokeServiceServer() {}
+func (UnimplementedJokeServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeJokeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JokeServiceServer will
+// result in compilation errors.
+type UnsafeJokeServiceServer interface {
+	mustEmbedUnimplementedJokeServiceServer()
+}
+
+func RegisterJokeServiceServer(s grpc.ServiceRegistrar, srv JokeServiceServer) {
+	// If the following call pancis, it indicates UnimplementedJokeServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&JokeService_ServiceDesc, srv)
+}
+
+func _JokeService_GetJoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JokeServiceServer).GetJoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JokeService_GetJoke_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JokeServiceServer).GetJoke(ctx, req.(*GetJokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JokeService_GetName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JokeServiceServer).GetName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JokeService_GetName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JokeServiceServer).GetName(ctx, req.(*GetNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JokeService_ListHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JokeServiceServer).ListHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JokeService_ListHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JokeServiceServer).ListHistory(ctx, req.(*ListHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JokeService_StreamJokes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JokeServiceServer).StreamJokes(m, &grpc.GenericServerStream[StreamRequest, Joke]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JokeService_StreamJokesServer = grpc.ServerStreamingServer[Joke]
+
+// JokeService_ServiceDesc is the grpc.ServiceDesc for JokeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JokeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "joke.v1.JokeService",
+	HandlerType: (*JokeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetJoke",
+			Handler:    _JokeService_GetJoke_Handler,
+		},
+		{
+			MethodName: "GetName",
+			Handler:    _JokeService_GetName_Handler,
+		},
+		{
+			MethodName: "ListHistory",
+			Handler:    _JokeService_ListHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamJokes",
+			Handler:       _JokeService_StreamJokes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "joke/v1/joke.proto",
+}
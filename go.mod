@@ -1,3 +1,23 @@
 module github.com/jswanson806/joke-generator
 
 go 1.23.5
+
+require golang.org/x/sync v0.11.0
+
+require golang.org/x/time v0.9.0
+
+require (
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.26.3
+	github.com/jung-kurt/gofpdf v1.16.2
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/text v0.22.0
+	google.golang.org/genproto/googleapis/api v0.0.0-20250303144028-a0af3efb3deb
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250303144028-a0af3efb3deb
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.36.5
+)
+
+require (
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
@@ -0,0 +1,187 @@
+// Package history keeps a bounded log of served jokes.
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// struct to hold a single served joke
+type Entry struct {
+	ID        int       `json:"id"`
+	Joke      string    `json:"joke"`
+	Category  string    `json:"category"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	ServedAt  time.Time `json:"served_at"`
+	// User is the signed-in user the joke was served to, empty when anonymous
+	User string `json:"user,omitempty"`
+	// Tenant is the tenant the joke was served to, empty without one
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// struct to hold the filters applied when listing history
+type Filter struct {
+	Page     int
+	PerPage  int
+	Since    time.Time
+	Category string
+	// User limits entries to those served to this user when set
+	User string
+	// Tenant limits entries to those served to this tenant. Unlike the
+	// other filters it always applies, so tenants never see each
+	// other's history.
+	Tenant string
+}
+
+/*
+	 Store is an in-memory, size-bounded log of served jokes.
+
+		Safe for concurrent use. Once the limit is reached the
+		oldest entries are dropped.
+*/
+type Store struct {
+	mu      sync.RWMutex
+	entries []Entry
+	nextID  int
+	limit   int
+	hooks   []func(Entry)
+}
+
+// New returns an empty Store holding at most limit entries
+func New(limit int) *Store {
+	return &Store{limit: limit, nextID: 1}
+}
+
+/*
+	 OnAdd calls fn with every entry added from now on, e.g. to index it
+
+		fn is called synchronously and should return quickly. Register
+		hooks before the Store is used.
+*/
+func (s *Store) OnAdd(fn func(Entry)) {
+	s.hooks = append(s.hooks, fn)
+}
+
+// Add assigns an ID to the entry, stores it and returns the stored entry
+func (s *Store) Add(e Entry) Entry {
+	e = s.add(e)
+	for _, fn := range s.hooks {
+		fn(e)
+	}
+	return e
+}
+
+// Function to store e with the next ID
+func (s *Store) add(e Entry) Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = s.nextID
+	s.nextID++
+	s.entries = append(s.entries, e)
+
+	// Drop the oldest entries once the limit is exceeded
+	if over := len(s.entries) - s.limit; over > 0 {
+		s.entries = append([]Entry(nil), s.entries[over:]...)
+	}
+	return e
+}
+
+// Purge removes the entries served before before and returns how many it removed
+func (s *Store) Purge(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, e := range s.entries {
+		if !e.ServedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	purged := len(s.entries) - len(kept)
+	// Clear the tail so purged entries can be collected
+	clear(s.entries[len(kept):])
+	s.entries = kept
+	return purged
+}
+
+/*
+	 Get returns the entry with id served to tenant, false if there is
+	 none or it was dropped
+
+		Entries served to other tenants are never returned, as with
+		List.
+*/
+func (s *Store) Get(id int, tenant string) (Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Entries are kept in ID order
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].ID >= id })
+	if i == len(s.entries) || s.entries[i].ID != id || s.entries[i].Tenant != tenant {
+		return Entry{}, false
+	}
+	return s.entries[i], true
+}
+
+/*
+	 After returns up to n entries served to tenant with IDs above id,
+	 oldest first
+
+		IDs don't change as entries are added, so pages of entries
+		by ID, unlike List's, stay put.
+*/
+func (s *Store) After(id, n int, tenant string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []Entry
+	// Entries are kept in ID order
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].ID > id })
+	for ; i < len(s.entries) && len(entries) < n; i++ {
+		if s.entries[i].Tenant == tenant {
+			entries = append(entries, s.entries[i])
+		}
+	}
+	return entries
+}
+
+// List returns the requested page of entries matching the filter, newest
+// first, along with the total number of matching entries
+func (s *Store) List(f Filter) ([]Entry, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Collect matching entries, newest first
+	var matched []Entry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		if !f.Since.IsZero() && e.ServedAt.Before(f.Since) {
+			continue
+		}
+		if f.Category != "" && e.Category != f.Category {
+			continue
+		}
+		if f.User != "" && e.User != f.User {
+			continue
+		}
+		if e.Tenant != f.Tenant {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	// Slice out the requested page, checking the page is in range
+	// before multiplying so a huge page can't overflow start
+	if f.Page-1 > len(matched)/f.PerPage {
+		return []Entry{}, len(matched)
+	}
+	start := (f.Page - 1) * f.PerPage
+	if start >= len(matched) {
+		return []Entry{}, len(matched)
+	}
+	end := min(start+f.PerPage, len(matched))
+	return matched[start:end], len(matched)
+}
@@ -0,0 +1,196 @@
+package history
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStoreList(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Populate history with five entries, one minute apart
+	h := New(10)
+	for i := 0; i < 5; i++ {
+		category := "nerdy"
+		if i%2 == 1 {
+			category = "explicit"
+		}
+		h.Add(Entry{
+			Joke:     "joke",
+			Category: category,
+			ServedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	t.Run("Returns newest first", func(t *testing.T) {
+		entries, total := h.List(Filter{Page: 1, PerPage: 2})
+		if total != 5 {
+			t.Errorf("Expected total 5; got %d", total)
+		}
+		if len(entries) != 2 || entries[0].ID != 5 || entries[1].ID != 4 {
+			t.Errorf("Expected entries 5 and 4; got %+v", entries)
+		}
+	})
+
+	t.Run("Returns later pages", func(t *testing.T) {
+		entries, _ := h.List(Filter{Page: 3, PerPage: 2})
+		if len(entries) != 1 || entries[0].ID != 1 {
+			t.Errorf("Expected entry 1; got %+v", entries)
+		}
+	})
+
+	t.Run("Returns empty page past the end", func(t *testing.T) {
+		entries, total := h.List(Filter{Page: 4, PerPage: 2})
+		if len(entries) != 0 || total != 5 {
+			t.Errorf("Expected no entries and total 5; got %d entries and total %d", len(entries), total)
+		}
+	})
+
+	t.Run("Returns empty page for pages that overflow", func(t *testing.T) {
+		for _, page := range []int{2305843009213693953, math.MaxInt} {
+			if entries, total := h.List(Filter{Page: page, PerPage: 5}); len(entries) != 0 || total != 5 {
+				t.Errorf("Page %d: expected no entries and total 5; got %d entries and total %d", page, len(entries), total)
+			}
+		}
+	})
+
+	t.Run("Filters by since and category", func(t *testing.T) {
+		entries, total := h.List(Filter{
+			Page:     1,
+			PerPage:  10,
+			Since:    base.Add(2 * time.Minute),
+			Category: "nerdy",
+		})
+		if total != 2 || entries[0].ID != 5 || entries[1].ID != 3 {
+			t.Errorf("Expected entries 5 and 3; got %+v", entries)
+		}
+	})
+
+	t.Run("Filters by user", func(t *testing.T) {
+		users := New(10)
+		users.Add(Entry{Joke: "joke", User: "user-1"})
+		users.Add(Entry{Joke: "joke"})
+		users.Add(Entry{Joke: "joke", User: "user-2"})
+
+		entries, total := users.List(Filter{Page: 1, PerPage: 10, User: "user-1"})
+		if total != 1 || entries[0].ID != 1 {
+			t.Errorf("Expected entry 1; got %+v", entries)
+		}
+	})
+
+	t.Run("Isolates tenants", func(t *testing.T) {
+		tenants := New(10)
+		tenants.Add(Entry{Joke: "joke", Tenant: "acme"})
+		tenants.Add(Entry{Joke: "joke"})
+		tenants.Add(Entry{Joke: "joke", Tenant: "globex"})
+
+		if entries, total := tenants.List(Filter{Page: 1, PerPage: 10, Tenant: "acme"}); total != 1 || entries[0].ID != 1 {
+			t.Errorf("Expected only acme's entry; got %+v", entries)
+		}
+		if entries, total := tenants.List(Filter{Page: 1, PerPage: 10}); total != 1 || entries[0].ID != 2 {
+			t.Errorf("Expected only the entry without a tenant; got %+v", entries)
+		}
+	})
+
+	t.Run("Drops oldest entries over the limit", func(t *testing.T) {
+		small := New(2)
+		for i := 0; i < 3; i++ {
+			small.Add(Entry{Joke: "joke"})
+		}
+		entries, total := small.List(Filter{Page: 1, PerPage: 10})
+		if total != 2 || entries[1].ID != 2 {
+			t.Errorf("Expected entries 3 and 2; got %+v", entries)
+		}
+	})
+}
+
+func TestStoreOnAdd(t *testing.T) {
+	t.Parallel()
+
+	h := New(10)
+	var added []Entry
+	h.OnAdd(func(e Entry) { added = append(added, e) })
+	h.Add(Entry{Joke: "joke"})
+
+	if len(added) != 1 || added[0].ID != 1 {
+		t.Errorf("Expected the hook to get entry 1; got %+v", added)
+	}
+}
+
+func TestStorePurge(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := New(10)
+	for i := 0; i < 4; i++ {
+		h.Add(Entry{Joke: "joke", ServedAt: base.Add(time.Duration(i) * 24 * time.Hour)})
+	}
+
+	if n := h.Purge(base.Add(48 * time.Hour)); n != 2 {
+		t.Errorf("Expected 2 entries purged; got %d", n)
+	}
+	entries, total := h.List(Filter{Page: 1, PerPage: 10})
+	if total != 2 || entries[1].ID != 3 {
+		t.Errorf("Expected entries 4 and 3 to remain; got %+v", entries)
+	}
+
+	// New entries keep counting from the last ID
+	if e := h.Add(Entry{Joke: "joke", ServedAt: base.Add(96 * time.Hour)}); e.ID != 5 {
+		t.Errorf("Expected ID 5; got %d", e.ID)
+	}
+}
+
+func TestStoreGet(t *testing.T) {
+	t.Parallel()
+
+	h := New(2)
+	h.Add(Entry{Joke: "dropped"})
+	h.Add(Entry{Joke: "mine", Tenant: "acme"})
+	h.Add(Entry{Joke: "shared"})
+
+	if e, ok := h.Get(2, "acme"); !ok || e.Joke != "mine" {
+		t.Errorf("Expected entry 2; got %+v, %v", e, ok)
+	}
+	if _, ok := h.Get(2, ""); ok {
+		t.Error("Expected another tenant's entry to be hidden")
+	}
+	for _, id := range []int{0, 1, 4} {
+		if _, ok := h.Get(id, ""); ok {
+			t.Errorf("%d: expected no entry", id)
+		}
+	}
+}
+
+func TestStoreAfter(t *testing.T) {
+	t.Parallel()
+
+	h := New(4)
+	h.Add(Entry{Joke: "dropped"})
+	for _, tenant := range []string{"", "acme", "", ""} {
+		h.Add(Entry{Joke: "joke", Tenant: tenant})
+	}
+
+	for _, tc := range []struct {
+		id, n   int
+		tenant  string
+		wantIDs []int
+	}{
+		{0, 10, "", []int{2, 4, 5}},
+		{2, 1, "", []int{4}},
+		{4, 10, "", []int{5}},
+		{5, 10, "", nil},
+		{0, 10, "acme", []int{3}},
+	} {
+		var got []int
+		for _, e := range h.After(tc.id, tc.n, tc.tenant) {
+			got = append(got, e.ID)
+		}
+		if !slices.Equal(got, tc.wantIDs) {
+			t.Errorf("After(%d, %d, %q): expected IDs %v; got %v", tc.id, tc.n, tc.tenant, tc.wantIDs, got)
+		}
+	}
+}
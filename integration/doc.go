@@ -0,0 +1,3 @@
+// Package integration holds end-to-end tests that run the full server over
+// real HTTP against mock upstream services. It contains no production code.
+package integration
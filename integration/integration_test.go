@@ -0,0 +1,280 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/server"
+)
+
+// Behaviors a mock upstream can be switched between
+const (
+	behaviorOK      = "ok"
+	behaviorSlow    = "slow"
+	behaviorError   = "error"
+	behaviorGarbage = "garbage"
+)
+
+// Timeout of the upstream client; slow upstreams take longer than this
+const upstreamTimeout = 50 * time.Millisecond
+
+/*
+	 mockUpstream is an httptest.Server whose behavior can be switched
+	 while tests run
+
+		ok writes the body built by respond, slow sleeps past the
+		client timeout, error returns a 500 and garbage writes a
+		body that is not JSON.
+*/
+type mockUpstream struct {
+	*httptest.Server
+	behavior atomic.Value
+	calls    atomic.Int32
+}
+
+// Function to start a mock upstream that is closed when the test ends
+func newMockUpstream(t *testing.T, respond func(r *http.Request) string) *mockUpstream {
+	t.Helper()
+	m := &mockUpstream{}
+	m.behavior.Store(behaviorOK)
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.calls.Add(1)
+		switch m.behavior.Load() {
+		case behaviorSlow:
+			select {
+			case <-r.Context().Done():
+			case <-time.After(4 * upstreamTimeout):
+			}
+		case behaviorError:
+			http.Error(w, "upstream exploded", http.StatusInternalServerError)
+		case behaviorGarbage:
+			io.WriteString(w, "}{ not json")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, respond(r))
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// Function to switch the upstream's behavior
+func (m *mockUpstream) set(behavior string) {
+	m.behavior.Store(behavior)
+}
+
+// struct to hold the full server and its mock upstreams
+type harness struct {
+	names *mockUpstream
+	jokes *mockUpstream
+	app   *httptest.Server
+}
+
+// Function to start the full server wired to fresh mock upstreams
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	names := newMockUpstream(t, func(r *http.Request) string {
+		return `{"first_name": "Grace", "last_name": "Hopper"}`
+	})
+	jokes := newMockUpstream(t, func(r *http.Request) string {
+		q := r.URL.Query()
+		return fmt.Sprintf(`{"value": {"joke": "%s %s can compile HTML."}}`, q.Get("firstName"), q.Get("lastName"))
+	})
+
+	client := &http.Client{Timeout: upstreamTimeout}
+	srv := server.New(server.WithProviders(
+		&joke.HTTPNameProvider{Endpoint: names.URL, Client: client},
+		&joke.HTTPJokeProvider{Endpoint: jokes.URL, Client: client},
+	))
+	app := httptest.NewServer(srv.Handler)
+	t.Cleanup(app.Close)
+
+	return &harness{names: names, jokes: jokes, app: app}
+}
+
+// Function to GET path from the server and return the status and body
+func (h *harness) get(t *testing.T, path string) (int, string) {
+	t.Helper()
+	res, err := http.Get(h.app.URL + path)
+	if err != nil {
+		t.Fatalf("Could not GET %s: %v", path, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Could not read body: %v", err)
+	}
+	return res.StatusCode, string(body)
+}
+
+// Function to decode the error code from an error response body
+func errorCode(t *testing.T, body string) string {
+	t.Helper()
+	var e struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatalf("Could not decode error body %q: %v", body, err)
+	}
+	return e.Code
+}
+
+func TestServesPersonalizedJoke(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	status, body := h.get(t, "/")
+
+	if status != http.StatusOK {
+		t.Fatalf("Expected status OK; got %d %q", status, body)
+	}
+	if body != "Grace Hopper can compile HTML." {
+		t.Errorf("Unexpected joke: %q", body)
+	}
+}
+
+func TestUpstreamFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		upstream func(h *harness) *mockUpstream
+		behavior string
+		status   int
+		code     string
+	}{
+		{"Name upstream error", func(h *harness) *mockUpstream { return h.names }, behaviorError, http.StatusBadGateway, "name_upstream_error"},
+		{"Name upstream garbage", func(h *harness) *mockUpstream { return h.names }, behaviorGarbage, http.StatusBadGateway, "upstream_decode_error"},
+		{"Name upstream slow", func(h *harness) *mockUpstream { return h.names }, behaviorSlow, http.StatusGatewayTimeout, "upstream_timeout"},
+		{"Joke upstream error", func(h *harness) *mockUpstream { return h.jokes }, behaviorError, http.StatusBadGateway, "joke_upstream_error"},
+		{"Joke upstream garbage", func(h *harness) *mockUpstream { return h.jokes }, behaviorGarbage, http.StatusBadGateway, "upstream_decode_error"},
+		{"Joke upstream slow", func(h *harness) *mockUpstream { return h.jokes }, behaviorSlow, http.StatusGatewayTimeout, "upstream_timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := newHarness(t)
+			tt.upstream(h).set(tt.behavior)
+
+			status, body := h.get(t, "/")
+
+			if status != tt.status {
+				t.Errorf("Expected status %d; got %d %q", tt.status, status, body)
+			}
+			if code := errorCode(t, body); code != tt.code {
+				t.Errorf("Expected code %q; got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestSkipsJokeWhenNameFails(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.names.set(behaviorError)
+
+	h.get(t, "/")
+
+	if calls := h.jokes.calls.Load(); calls != 0 {
+		t.Errorf("Expected no joke upstream calls; got %d", calls)
+	}
+}
+
+func TestRecoversAfterUpstreamFailure(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.jokes.set(behaviorError)
+	if status, _ := h.get(t, "/"); status != http.StatusBadGateway {
+		t.Errorf("Expected status Bad Gateway while upstream fails; got %d", status)
+	}
+
+	h.jokes.set(behaviorOK)
+	if status, _ := h.get(t, "/"); status != http.StatusOK {
+		t.Errorf("Expected status OK once upstream recovers; got %d", status)
+	}
+}
+
+func TestHistoryRecordsServedJokes(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.get(t, "/")
+	h.jokes.set(behaviorError)
+	h.get(t, "/")
+
+	status, body := h.get(t, "/history")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status OK; got %d %q", status, body)
+	}
+
+	// Only the successful request is recorded
+	var page struct {
+		Total   int `json:"total"`
+		Entries []struct {
+			Joke string `json:"joke"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatalf("Could not decode history: %v", err)
+	}
+	if page.Total != 1 || !strings.Contains(page.Entries[0].Joke, "Grace Hopper") {
+		t.Errorf("Expected one recorded joke about Grace Hopper; got %+v", page)
+	}
+}
+
+func TestChaosFallsBackToCachedJoke(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	// Fault half of all upstream calls, with delays past the request deadline
+	chaos := &joke.Chaos{Rate: 0.5, Delay: 4 * upstreamTimeout}
+	client := &http.Client{Timeout: upstreamTimeout}
+	c := cache.NewMemory()
+	srv := server.New(
+		server.WithProviders(
+			chaos.Names(&joke.HTTPNameProvider{Endpoint: h.names.URL, Client: client}),
+			chaos.Jokes(&joke.HTTPJokeProvider{Endpoint: h.jokes.URL, Client: client}),
+		),
+		server.WithCache(c),
+		server.WithMiddleware(middleware.Timeout(2*upstreamTimeout)),
+	)
+	app := httptest.NewServer(srv.Handler)
+	t.Cleanup(app.Close)
+
+	// Seed the fallback so every faulted request can still be answered
+	c.Set(joke.FallbackKey, []byte("Grace Hopper can compile HTML."), 0)
+
+	var fallbacks int
+	for i := 0; i < 40; i++ {
+		res, err := http.Get(app.URL)
+		if err != nil {
+			t.Fatalf("Could not GET /: %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || string(body) != "Grace Hopper can compile HTML." {
+			t.Fatalf("Expected joke with status OK; got %d %q", res.StatusCode, body)
+		}
+		if res.Header.Get("X-Joke-Fallback") == "true" {
+			fallbacks++
+		}
+	}
+
+	// Three in four requests hit at least one fault
+	if fallbacks == 0 {
+		t.Error("Expected some requests to be served the fallback; got none")
+	}
+}
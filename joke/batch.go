@@ -0,0 +1,164 @@
+package joke
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Number of jokes served by a batch without ?count=, the most one may
+// ask for, and how many are fetched at once
+const (
+	defaultBatch = 10
+	maxBatch     = 50
+	batchWorkers = 4
+)
+
+// struct to hold the joke handler serving batches
+type batchHandler struct {
+	handler
+}
+
+/*
+	 NewBatchHandler returns an http.Handler serving ?count= jokes,
+	 1 to 50 and 10 by default, as a list
+
+		Jokes are written as they arrive, in the format the request
+		accepts. Jokes that fail are left out of the list; the request
+		fails only when every joke does. The fallback joke isn't
+		served, as a batch of it would repeat one joke.
+*/
+func NewBatchHandler(deps Deps) http.Handler {
+	return &batchHandler{handler{deps: deps, logger: loggerOrDefault(deps.Logger)}}
+}
+
+// ServeHTTP fetches the jokes concurrently and streams each as it is ready
+func (h *batchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	count := defaultBatch
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Handle counts that aren't a number in range
+		if err != nil || n < 1 || n > maxBatch {
+			http.Error(w, "count must be between 1 and "+strconv.Itoa(maxBatch), http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	ctx, names, err := h.personalize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t := tenant.FromContext(r.Context())
+	if t != nil && !t.Allows(DefaultCategory) {
+		writeError(w, h.logger, ErrCategoryNotAllowed, "category "+DefaultCategory+" is not allowed for this tenant")
+		return
+	}
+
+	// Answer HEAD with the headers alone, without fetching jokes
+	if r.Method == http.MethodHead {
+		h.writeHead(w, r, h.batchFormats())
+		return
+	}
+
+	// struct to hold the outcome of fetching one joke
+	type result struct {
+		name Names
+		text string
+		err  error
+	}
+	results := make(chan result)
+
+	// Fetch batchWorkers jokes at a time, each a name then a joke as in ServeHTTP
+	var g errgroup.Group
+	g.SetLimit(batchWorkers)
+	go func() {
+		for range count {
+			// Stop starting fetches once the client goes away
+			if ctx.Err() != nil {
+				break
+			}
+			g.Go(func() error {
+				// The client may have gone while this fetch waited for a worker
+				if ctx.Err() != nil {
+					return nil
+				}
+				name, text, err := Fetch(ctx, names, h.deps.Jokes)
+				results <- result{name: name, text: text, err: err}
+				return nil
+			})
+		}
+		g.Wait()
+		close(results)
+	}()
+
+	formats := h.batchFormats()
+	f, ok := render.Negotiate(r, formats...)
+	if !ok {
+		f = formats[0]
+	}
+	stream := render.NewStream(w, f)
+
+	var lastErr error
+	for res := range results {
+		// Handle a failed joke; leave it out of the batch
+		if res.err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to build joke in batch", "error", res.err)
+			lastErr = res.err
+			continue
+		}
+		if !personal(ctx) {
+			h.record(r, res.name, res.text)
+		}
+		j := h.brand(r, jokeResponse{Joke: res.text, Category: DefaultCategory, FirstName: res.name.FirstName, LastName: res.name.LastName}).split()
+		// Handle errors while writing; keep draining so the fetches finish
+		if err := stream.Write(j); err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to write joke", "error", err)
+		}
+	}
+
+	// Nobody is left to finish the response for
+	if ClientGone(r.Context()) {
+		h.logger.InfoContext(r.Context(), "client went away before the batch was ready")
+		if !stream.Started() {
+			w.WriteHeader(StatusClientClosedRequest)
+		}
+		return
+	}
+
+	// Every joke failed
+	if !stream.Started() && lastErr != nil {
+		var se *stageError
+		if errors.As(lastErr, &se) {
+			writeError(w, h.logger, se.err, se.msg)
+			return
+		}
+		writeError(w, h.logger, lastErr, "failed to get jokes")
+		return
+	}
+
+	// Handle errors while ending the response
+	if err := stream.Close(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// Function to return the formats batches are offered in; joke.v1 has no
+// message for a list of jokes, so protobuf isn't one, pages show a single
+// joke, and CSV has rows only for lists
+func (h *batchHandler) batchFormats() []render.Format {
+	var formats []render.Format
+	for _, f := range h.formats() {
+		if f.Name != render.Protobuf.Name && f.Name != pageFormat.Name {
+			formats = append(formats, f)
+		}
+	}
+	return append(formats, render.CSV)
+}
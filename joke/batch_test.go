@@ -0,0 +1,146 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestBatchHandler(t *testing.T) {
+	t.Parallel()
+
+	getRandomName := func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	}
+	getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "Mocked joke about " + firstName, nil
+	}
+
+	// Function to serve a batch request to a handler around the mocks
+	serve := func(deps Deps, target string, header http.Header) *httptest.ResponseRecorder {
+		if deps.Names == nil {
+			deps.Names = NameProviderFunc(getRandomName)
+		}
+		if deps.Jokes == nil {
+			deps.Jokes = JokeProviderFunc(getRandomJoke)
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		NewBatchHandler(deps).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Serves count jokes as text lines", func(t *testing.T) {
+		rec := serve(Deps{}, "/jokes?count=3", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		if len(lines) != 3 || lines[0] != "Mocked joke about John" {
+			t.Errorf("Expected 3 jokes; got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Serves a JSON array", func(t *testing.T) {
+		rec := serve(Deps{}, "/jokes?count=2", http.Header{"Accept": {"application/json"}})
+		var jokes []struct {
+			Joke string `json:"joke"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &jokes); err != nil {
+			t.Fatalf("Expected a JSON array; got %q: %v", rec.Body.String(), err)
+		}
+		if len(jokes) != 2 || jokes[1].Joke != "Mocked joke about John" {
+			t.Errorf("Expected 2 jokes; got %v", jokes)
+		}
+	})
+
+	t.Run("Serves a YAML sequence", func(t *testing.T) {
+		rec := serve(Deps{}, "/jokes?count=2&format=yaml", nil)
+		want := "- joke: Mocked joke about John\n- joke: Mocked joke about John\n"
+		if rec.Body.String() != want {
+			t.Errorf("Expected %q; got %q", want, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+			t.Errorf("Expected Content-Type application/yaml; got %s", ct)
+		}
+	})
+
+	t.Run("Serves CSV with a header line", func(t *testing.T) {
+		rec := serve(Deps{}, "/jokes?count=2", http.Header{"Accept": {"text/csv"}})
+		want := "joke\nMocked joke about John\nMocked joke about John\n"
+		if rec.Body.String() != want {
+			t.Errorf("Expected %q; got %q", want, rec.Body.String())
+		}
+	})
+
+	t.Run("Defaults to 10 jokes and records them", func(t *testing.T) {
+		store := history.New(100)
+		serve(Deps{History: store}, "/jokes", nil)
+		if _, total := store.List(history.Filter{Page: 1, PerPage: 100}); total != defaultBatch {
+			t.Errorf("Expected %d jokes in history; got %d", defaultBatch, total)
+		}
+	})
+
+	t.Run("Rejects counts out of range", func(t *testing.T) {
+		for _, count := range []string{"0", "51", "ten"} {
+			if rec := serve(Deps{}, "/jokes?count="+count, nil); rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for count=%s; got %v", count, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Leaves out failed jokes", func(t *testing.T) {
+		var calls atomic.Int32
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			if calls.Add(1)%2 == 0 {
+				return "", ErrJokeUpstream
+			}
+			return "joke", nil
+		}
+		rec := serve(Deps{Jokes: JokeProviderFunc(jokes)}, "/jokes?count=4", nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != "joke\njoke\n" {
+			t.Errorf("Expected 2 jokes; got %v %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Fails when every joke fails", func(t *testing.T) {
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", errors.Join(ErrJokeUpstream, ErrTimeout)
+		}
+		rec := serve(Deps{Jokes: JokeProviderFunc(jokes)}, "/jokes?count=3", nil)
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected status 504; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Stops fetching when the client goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls atomic.Int32
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			calls.Add(1)
+			cancel()
+			<-ctx.Done()
+			return "", upstreamError(ErrJokeUpstream, ctx.Err())
+		}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/jokes?count=50", nil)
+		NewBatchHandler(Deps{Names: NameProviderFunc(getRandomName), Jokes: JokeProviderFunc(jokes)}).ServeHTTP(rec, req)
+
+		if rec.Code != StatusClientClosedRequest {
+			t.Errorf("Expected status 499; got %v", rec.Code)
+		}
+		if n := calls.Load(); n > batchWorkers {
+			t.Errorf("Expected at most %d fetches; got %d", batchWorkers, n)
+		}
+	})
+}
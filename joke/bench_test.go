@@ -0,0 +1,95 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/cache"
+)
+
+// Providers returning immediately so benchmarks measure only the handler
+var (
+	benchNames = NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "Grace", LastName: "Hopper"}, nil
+	})
+	benchJokes = JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "Grace Hopper can compile HTML.", nil
+	})
+)
+
+// Function to serve b.N requests to h, in parallel when parallel is set
+func benchmarkHandler(b *testing.B, h http.Handler, parallel bool) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	if !parallel {
+		for i := 0; i < b.N; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+		return
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+	})
+}
+
+func BenchmarkHandler(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	b.Run("Serial", func(b *testing.B) {
+		benchmarkHandler(b, NewHandler(Deps{Names: benchNames, Jokes: benchJokes, Logger: logger}), false)
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		benchmarkHandler(b, NewHandler(Deps{Names: benchNames, Jokes: benchJokes, Logger: logger}), true)
+	})
+	b.Run("WithCache", func(b *testing.B) {
+		h := NewHandler(Deps{Names: benchNames, Jokes: benchJokes, Cache: cache.NewMemory(), Logger: logger})
+		benchmarkHandler(b, h, true)
+	})
+	b.Run("Fallback", func(b *testing.B) {
+		failing := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", ErrJokeUpstream
+		})
+		c := cache.NewMemory()
+		c.Set(FallbackKey, []byte("Grace Hopper can compile HTML."), 0)
+		benchmarkHandler(b, NewHandler(Deps{Names: benchNames, Jokes: failing, Cache: c, Logger: logger}), true)
+	})
+}
+
+func BenchmarkWriteError(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	err := errors.Join(ErrJokeUpstream, ErrTimeout)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writeError(httptest.NewRecorder(), logger, err, "failed to get joke")
+	}
+}
+
+func BenchmarkDecodeName(b *testing.B) {
+	body := []byte(`{"first_name": "Grace", "last_name": "Hopper"}`)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeName(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeJoke(b *testing.B) {
+	body := []byte(`{"type": "success", "value": {"id": 1, "joke": "Grace Hopper can compile HTML.", "categories": ["nerdy"]}}`)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeJoke(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
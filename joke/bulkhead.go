@@ -0,0 +1,79 @@
+package joke
+
+import (
+	"context"
+	"time"
+)
+
+/*
+	 Bulkhead caps the calls in flight to the providers it wraps
+
+		Calls beyond the limit wait for a slot until their context
+		ends, so a slow joke service fills its own bulkhead rather
+		than every goroutine, and name fetches keep their slots. One
+		bulkhead can wrap several providers to cap them together,
+		e.g. every outbound call, around the bulkheads of each.
+*/
+type Bulkhead struct {
+	name    string
+	slots   chan struct{}
+	observe func(bulkhead string, wait time.Duration, err error)
+}
+
+/*
+	 NewBulkhead returns a Bulkhead allowing limit calls at once
+
+		observe, when not nil, is called with how long each call
+		waited for a slot, and the error when its context ended
+		first; name labels the bulkhead in it.
+*/
+func NewBulkhead(name string, limit int, observe func(bulkhead string, wait time.Duration, err error)) *Bulkhead {
+	return &Bulkhead{name: name, slots: make(chan struct{}, max(limit, 1)), observe: observe}
+}
+
+// Names returns p wrapped so its calls take a slot of b
+func (b *Bulkhead) Names(p NameProvider) NameProvider {
+	return NameProviderFunc(func(ctx context.Context) (Names, error) {
+		if err := b.acquire(ctx); err != nil {
+			return Names{}, upstreamError(ErrNameUpstream, err)
+		}
+		defer b.release()
+		return p.Name(ctx)
+	})
+}
+
+// Jokes returns p wrapped so its calls take a slot of b
+func (b *Bulkhead) Jokes(p JokeProvider) JokeProvider {
+	return JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		if err := b.acquire(ctx); err != nil {
+			return "", upstreamError(ErrJokeUpstream, err)
+		}
+		defer b.release()
+		return p.Joke(ctx, firstName, lastName)
+	})
+}
+
+// Function to wait for a free slot, failing with ctx's error if it ends first
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	start := time.Now()
+	var err error
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		// Every slot is taken; wait for one
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if b.observe != nil {
+		b.observe(b.name, time.Since(start), err)
+	}
+	return err
+}
+
+// Function to free the slot taken by acquire
+func (b *Bulkhead) release() {
+	<-b.slots
+}
@@ -0,0 +1,103 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Caps calls in flight", func(t *testing.T) {
+		t.Parallel()
+		b := NewBulkhead("joke", 2, nil)
+
+		var mu sync.Mutex
+		inFlight, most := 0, 0
+		jokes := b.Jokes(JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			mu.Lock()
+			inFlight++
+			most = max(most, inFlight)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return "joke", nil
+		}))
+
+		var wg sync.WaitGroup
+		for range 6 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := jokes.Joke(context.Background(), "John", "Doe"); err != nil {
+					t.Errorf("Expected no error; got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if most != 2 {
+			t.Errorf("Expected at most 2 calls in flight; got %d", most)
+		}
+	})
+
+	t.Run("Isolates providers in separate bulkheads", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		defer close(release)
+		jokes := NewBulkhead("joke", 1, nil).Jokes(JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			<-release
+			return "joke", nil
+		}))
+		names := NewBulkhead("name", 1, nil).Names(NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John"}, nil
+		}))
+
+		// A stuck joke call fills the joke bulkhead only
+		go jokes.Joke(context.Background(), "John", "Doe")
+		time.Sleep(5 * time.Millisecond)
+		if n, err := names.Name(context.Background()); err != nil || n.FirstName != "John" {
+			t.Errorf("Expected a name; got %v, %v", n, err)
+		}
+	})
+
+	t.Run("Gives up when the context ends while waiting", func(t *testing.T) {
+		t.Parallel()
+		var waited []error
+		b := NewBulkhead("name", 1, func(bulkhead string, wait time.Duration, err error) {
+			if bulkhead != "name" {
+				t.Errorf("Expected bulkhead name; got %q", bulkhead)
+			}
+			waited = append(waited, err)
+		})
+		release := make(chan struct{})
+		started := make(chan struct{})
+		names := b.Names(NameProviderFunc(func(ctx context.Context) (Names, error) {
+			close(started)
+			<-release
+			return Names{}, nil
+		}))
+		done := make(chan struct{})
+		go func() {
+			names.Name(context.Background())
+			close(done)
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := names.Name(ctx)
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrNameUpstream and ErrTimeout; got %v", err)
+		}
+		close(release)
+		<-done
+		if len(waited) != 2 || waited[0] != nil || !errors.Is(waited[1], context.DeadlineExceeded) {
+			t.Errorf("Expected a slot then a deadline; got %v", waited)
+		}
+	})
+}
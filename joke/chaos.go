@@ -0,0 +1,137 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Delay injected by Chaos when none is configured
+const DefaultChaosDelay = 2 * time.Second
+
+// Error returned for injected upstream failures
+var errInjected = errors.New("chaos: injected failure")
+
+// Payloads returned for injected malformed responses, decoded like a real body
+const (
+	malformedNamePayload = `{"first_name": 42, "last_name": `
+	malformedJokePayload = `<html><body>502 Bad Gateway</body></html>`
+)
+
+// Kinds of fault Chaos can inject into a call
+type fault int
+
+const (
+	faultNone fault = iota
+	faultDelay
+	faultError
+	faultMalformed
+)
+
+// String returns the fault name used in logs
+func (f fault) String() string {
+	switch f {
+	case faultDelay:
+		return "delay"
+	case faultError:
+		return "error"
+	case faultMalformed:
+		return "malformed"
+	default:
+		return "none"
+	}
+}
+
+/*
+	 Chaos wraps providers to randomly inject upstream faults
+
+		Each call is faulted with probability Rate. A faulted call
+		is equally likely to be delayed by Delay before reaching the
+		provider, fail with the provider's upstream error, or fail
+		decoding a malformed payload, so the fallback and retry
+		paths can be exercised without a misbehaving upstream.
+*/
+type Chaos struct {
+	// Probability between 0 and 1 that a call is faulted
+	Rate float64
+	// Delay injected before a delayed call, defaults to DefaultChaosDelay
+	Delay time.Duration
+	// Logger for injected faults, defaults to slog.Default()
+	Logger *slog.Logger
+
+	// Source of random numbers in [0, 1), replaced in tests
+	random func() float64
+}
+
+// Names returns p wrapped so its calls are faulted at c.Rate
+func (c *Chaos) Names(p NameProvider) NameProvider {
+	return NameProviderFunc(func(ctx context.Context) (Names, error) {
+		switch f := c.fault(ctx, "name"); f {
+		case faultDelay:
+			if err := c.wait(ctx); err != nil {
+				return Names{}, upstreamError(ErrNameUpstream, err)
+			}
+		case faultError:
+			return Names{}, fmt.Errorf("%w: %w", ErrNameUpstream, errInjected)
+		case faultMalformed:
+			_, err := DecodeName([]byte(malformedNamePayload))
+			return Names{}, fmt.Errorf("%w: %w", ErrNameUpstream, err)
+		}
+		return p.Name(ctx)
+	})
+}
+
+// Jokes returns p wrapped so its calls are faulted at c.Rate
+func (c *Chaos) Jokes(p JokeProvider) JokeProvider {
+	return JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		switch f := c.fault(ctx, "joke"); f {
+		case faultDelay:
+			if err := c.wait(ctx); err != nil {
+				return "", upstreamError(ErrJokeUpstream, err)
+			}
+		case faultError:
+			return "", fmt.Errorf("%w: %w", ErrJokeUpstream, errInjected)
+		case faultMalformed:
+			_, err := DecodeJoke([]byte(malformedJokePayload))
+			return "", fmt.Errorf("%w: %w", ErrJokeUpstream, err)
+		}
+		return p.Joke(ctx, firstName, lastName)
+	})
+}
+
+// Function to pick the fault, if any, to inject into the next call
+func (c *Chaos) fault(ctx context.Context, upstream string) fault {
+	random := c.random
+	if random == nil {
+		random = rand.Float64
+	}
+
+	// One draw decides both whether and how to fault
+	r := random()
+	if r >= c.Rate {
+		return faultNone
+	}
+	f := faultDelay + fault(r/c.Rate*3)
+
+	loggerOrDefault(c.Logger).DebugContext(ctx, "chaos: injecting fault", "upstream", upstream, "fault", f)
+	return f
+}
+
+// Function to sleep for the configured delay, returning early when ctx is done
+func (c *Chaos) wait(ctx context.Context) error {
+	d := c.Delay
+	if d <= 0 {
+		d = DefaultChaosDelay
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
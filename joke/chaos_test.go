@@ -0,0 +1,123 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Function to return a Chaos whose every random draw is r
+func newTestChaos(rate, r float64) *Chaos {
+	return &Chaos{Rate: rate, Delay: 50 * time.Millisecond, random: func() float64 { return r }}
+}
+
+func TestChaosNames(t *testing.T) {
+	t.Parallel()
+
+	// Provider wrapped by every case
+	names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c := &Chaos{}
+
+		n, err := c.Names(names).Name(context.Background())
+		if err != nil || n.FirstName != "John" {
+			t.Errorf("Expected John with no error; got %v, %v", n, err)
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		c := newTestChaos(0.3, 0.05)
+
+		start := time.Now()
+		n, err := c.Names(names).Name(context.Background())
+		if err != nil || n.FirstName != "John" {
+			t.Errorf("Expected John with no error; got %v, %v", n, err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("Expected call to be delayed 50ms; took %s", elapsed)
+		}
+	})
+
+	t.Run("Delay past deadline", func(t *testing.T) {
+		c := newTestChaos(0.3, 0.05)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := c.Names(names).Name(ctx)
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrNameUpstream and ErrTimeout; got %v", err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		c := newTestChaos(0.3, 0.15)
+
+		_, err := c.Names(names).Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) || errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrNameUpstream only; got %v", err)
+		}
+	})
+
+	t.Run("Malformed payload", func(t *testing.T) {
+		c := newTestChaos(0.3, 0.25)
+
+		_, err := c.Names(names).Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrNameUpstream and ErrDecode; got %v", err)
+		}
+	})
+}
+
+func TestChaosJokes(t *testing.T) {
+	t.Parallel()
+
+	// Provider wrapped by every case
+	jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return firstName + " " + lastName + " can divide by zero.", nil
+	})
+
+	t.Run("Below rate", func(t *testing.T) {
+		c := newTestChaos(0.3, 0.5)
+
+		joke, err := c.Jokes(jokes).Joke(context.Background(), "John", "Doe")
+		if err != nil || joke != "John Doe can divide by zero." {
+			t.Errorf("Expected joke with no error; got %q, %v", joke, err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		c := newTestChaos(1, 0.5)
+
+		_, err := c.Jokes(jokes).Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrJokeUpstream only; got %v", err)
+		}
+	})
+
+	t.Run("Malformed payload", func(t *testing.T) {
+		c := newTestChaos(1, 0.9)
+
+		_, err := c.Jokes(jokes).Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrJokeUpstream and ErrDecode; got %v", err)
+		}
+	})
+
+	t.Run("Always faulted", func(t *testing.T) {
+		// With the real random source and a rate of 1 no call gets through
+		c := &Chaos{Rate: 1, Delay: time.Millisecond}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+
+		for i := 0; i < 20; i++ {
+			if _, err := c.Jokes(jokes).Joke(ctx, "John", "Doe"); err == nil {
+				t.Fatal("Expected every call to fail; got nil error")
+			}
+		}
+	})
+}
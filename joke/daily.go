@@ -0,0 +1,111 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+)
+
+// Prefix of the cache keys of jokes of the day, followed by the date
+const dailyKeyPrefix = "joke:daily:"
+
+// Layout of the dates jokes of the day are kept under
+const dailyLayout = "2006-01-02"
+
+// How long a joke of the day is kept, enough for a month of past days
+const dailyTTL = 32 * 24 * time.Hour
+
+// Daily is the joke of one day
+type Daily struct {
+	// Day is midnight UTC of the day
+	Day       time.Time `json:"day"`
+	Joke      string    `json:"joke"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+}
+
+/*
+	 DailyJokes picks one joke for each day, in UTC, the same for every
+	 client
+
+		Today's joke is fetched the first time it is asked for and
+		kept in the cache, so a persistent cache keeps it across
+		restarts. Build one with NewDailyJokes.
+*/
+type DailyJokes struct {
+	names NameProvider
+	jokes JokeProvider
+	cache cache.Cache
+
+	// Held while today's joke is fetched, so it is fetched once
+	mu sync.Mutex
+}
+
+// NewDailyJokes returns DailyJokes fetched from names and jokes, kept in c
+func NewDailyJokes(names NameProvider, jokes JokeProvider, c cache.Cache) *DailyJokes {
+	return &DailyJokes{names: names, jokes: jokes, cache: c}
+}
+
+// Today returns the joke of the day of now, fetching it if it hasn't been yet
+func (d *DailyJokes) Today(ctx context.Context, now time.Time) (Daily, error) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if j, ok := d.get(day); ok {
+		return j, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Another request may have fetched it while this one waited
+	if j, ok := d.get(day); ok {
+		return j, nil
+	}
+	name, text, err := Fetch(ctx, d.names, d.jokes)
+	if err != nil {
+		return Daily{}, err
+	}
+	j := Daily{Day: day, Joke: text, FirstName: name.FirstName, LastName: name.LastName}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return Daily{}, fmt.Errorf("joke: could not encode joke of the day: %w", err)
+	}
+	d.cache.Set(dailyKeyPrefix+day.Format(dailyLayout), data, dailyTTL)
+	return j, nil
+}
+
+/*
+	 Recent returns the jokes of the days days up to and including the
+	 day of now, newest first
+
+		Today's joke is fetched if needed; past days without a joke,
+		e.g. before the server first ran, are skipped.
+*/
+func (d *DailyJokes) Recent(ctx context.Context, now time.Time, days int) ([]Daily, error) {
+	today, err := d.Today(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	recent := []Daily{today}
+	for i := 1; i < days; i++ {
+		if j, ok := d.get(today.Day.AddDate(0, 0, -i)); ok {
+			recent = append(recent, j)
+		}
+	}
+	return recent, nil
+}
+
+// Function to return the kept joke of day
+func (d *DailyJokes) get(day time.Time) (Daily, bool) {
+	data, ok := d.cache.Get(dailyKeyPrefix + day.Format(dailyLayout))
+	if !ok {
+		return Daily{}, false
+	}
+	var j Daily
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Daily{}, false
+	}
+	return j, true
+}
@@ -0,0 +1,51 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+)
+
+func TestDailyJokes(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+	})
+	jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		if calls.Add(1) > 2 {
+			return "", ErrJokeUpstream
+		}
+		return "joke " + string(rune('0'+calls.Load())), nil
+	})
+	d := NewDailyJokes(names, jokes, cache.NewMemory())
+	day1 := time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)
+
+	j, err := d.Today(context.Background(), day1)
+	if err != nil || j.Joke != "joke 1" || !j.Day.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected the first joke on day 1; got %+v, %v", j, err)
+	}
+	// The same day keeps its joke
+	if j, _ := d.Today(context.Background(), day1.Add(-time.Hour)); j.Joke != "joke 1" {
+		t.Errorf("Expected the same joke all day; got %q", j.Joke)
+	}
+
+	recent, err := d.Recent(context.Background(), day2, 30)
+	if err != nil || len(recent) != 2 || recent[0].Joke != "joke 2" || recent[1].Joke != "joke 1" {
+		t.Errorf("Expected today's joke then yesterday's; got %+v, %v", recent, err)
+	}
+
+	// A day whose joke can't be fetched fails
+	if _, err := d.Today(context.Background(), day2.AddDate(0, 0, 1)); !errors.Is(err, ErrJokeUpstream) {
+		t.Errorf("Expected ErrJokeUpstream; got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected one fetch a day; got %d", calls.Load())
+	}
+}
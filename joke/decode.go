@@ -0,0 +1,63 @@
+package joke
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Maximum upstream response body read before decoding
+const maxResponseBytes = 1 << 20
+
+// Maximum length of an upstream body quoted in an error message
+const maxQuotedBody = 200
+
+/*
+	 DecodeName decodes a name service response body
+
+		Returns an error wrapping ErrDecode when body is not JSON or
+		does not match Names.
+*/
+func DecodeName(body []byte) (Names, error) {
+	// Verify response body is valid JSON
+	if !json.Valid(body) {
+		return Names{}, fmt.Errorf("%w: non-JSON response received: %q", ErrDecode, quoteBody(body))
+	}
+	// Initialize struct to hold return values
+	var n Names
+	// Unmarshal JSON in body and initialize struct Names with data
+	if err := json.Unmarshal(body, &n); err != nil {
+		return Names{}, fmt.Errorf("%w: error unmarshalling JSON: %w", ErrDecode, err)
+	}
+	return n, nil
+}
+
+/*
+	 DecodeJoke decodes a joke service response body and returns the joke
+
+		A joke sent in two parts is returned as its setup and delivery
+		joined by PunchlineSeparator. Returns an error wrapping
+		ErrDecode when body does not match Joke.
+*/
+func DecodeJoke(body []byte) (string, error) {
+	// Initialize new Joke struct
+	var j Joke
+	// Unmarshal JSON in body and initialize struct Joke with data
+	if err := json.Unmarshal(body, &j); err != nil {
+		return "", fmt.Errorf("%w: error unmarshalling JSON: %w", ErrDecode, err)
+	}
+	switch {
+	case j.Value.Setup != "" && j.Value.Delivery != "":
+		return j.Value.Setup + PunchlineSeparator + j.Value.Delivery, nil
+	case j.Type == "twopart" && j.Setup != "" && j.Delivery != "":
+		return j.Setup + PunchlineSeparator + j.Delivery, nil
+	}
+	return j.Value.Joke, nil
+}
+
+// Function to shorten an upstream body for inclusion in an error message
+func quoteBody(body []byte) string {
+	if len(body) > maxQuotedBody {
+		return string(body[:maxQuotedBody]) + "..."
+	}
+	return string(body)
+}
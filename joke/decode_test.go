@@ -0,0 +1,112 @@
+package joke
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func FuzzDecodeName(f *testing.F) {
+	// Seed corpus of well-formed and malformed name payloads
+	for _, seed := range []string{
+		`{"first_name": "Grace", "last_name": "Hopper"}`,
+		`{"first_name": "Zoë", "last_name": "O'Brien-Smith"}`,
+		`{"first_name": 42}`,
+		`[]`,
+		`null`,
+		`<html>bad gateway</html>`,
+		``,
+		`{"first_name": "\u0000"}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		n, err := DecodeName(body)
+		if err != nil {
+			// Every failure must be classified as a decode error
+			if !errors.Is(err, ErrDecode) {
+				t.Fatalf("Expected ErrDecode; got %v", err)
+			}
+			return
+		}
+
+		// A decoded name must survive a round trip unchanged
+		encoded, err := json.Marshal(n)
+		if err != nil {
+			t.Fatalf("Could not re-encode %+v: %v", n, err)
+		}
+		again, err := DecodeName(encoded)
+		if err != nil || again != n {
+			t.Fatalf("Round trip changed %+v into %+v (%v)", n, again, err)
+		}
+	})
+}
+
+func TestDecodeJoke(t *testing.T) {
+	t.Parallel()
+
+	for body, want := range map[string]string{
+		`{"value": {"joke": "One part."}}`:                                    "One part.",
+		`{"value": {"setup": "Why?", "delivery": "Because."}}`:                "Why?\nBecause.",
+		`{"type": "twopart", "setup": "Why?", "delivery": "Because."}`:        "Why?\nBecause.",
+		`{"type": "single", "setup": "Why?", "value": {"joke": "One part."}}`: "One part.",
+	} {
+		if got, err := DecodeJoke([]byte(body)); err != nil || got != want {
+			t.Errorf("%s: expected %q; got %q, %v", body, want, got, err)
+		}
+	}
+}
+
+func TestSplitJoke(t *testing.T) {
+	t.Parallel()
+
+	if setup, delivery, ok := SplitJoke("Why?\n Because.\nReally."); !ok || setup != "Why?" || delivery != "Because.\nReally." {
+		t.Errorf("Expected a split at the first line break; got %q, %q, %v", setup, delivery, ok)
+	}
+	for _, text := range []string{"One part.", "Why?\n", "\nBecause."} {
+		if _, _, ok := SplitJoke(text); ok {
+			t.Errorf("%q: expected a one-part joke", text)
+		}
+	}
+}
+
+func FuzzDecodeJoke(f *testing.F) {
+	// Seed corpus of well-formed and malformed joke payloads
+	for _, seed := range []string{
+		`{"type": "success", "value": {"id": 1, "joke": "Grace Hopper can compile HTML.", "categories": ["nerdy"]}}`,
+		`{"value": {"joke": ""}}`,
+		`{"value": "not an object"}`,
+		`{"value": {"joke": `,
+		`{"value": {"joke": "&quot;escaped&quot;"}}`,
+		`{"value": {"setup": "Why?", "delivery": "Because."}}`,
+		`{"type": "twopart", "setup": "Why?", "delivery": "Because."}`,
+		`garbage`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		text, err := DecodeJoke(body)
+		if err != nil {
+			// Every failure must be classified as a decode error
+			if !errors.Is(err, ErrDecode) {
+				t.Fatalf("Expected ErrDecode; got %v", err)
+			}
+			return
+		}
+
+		// A decoded joke must survive a round trip unchanged
+		var j Joke
+		j.Value.Joke = text
+		encoded, err := json.Marshal(j)
+		if err != nil {
+			t.Fatalf("Could not re-encode %q: %v", text, err)
+		}
+		again, err := DecodeJoke(encoded)
+		if err != nil || again != text {
+			t.Fatalf("Round trip changed %q into %q (%v)", text, again, err)
+		}
+	})
+}
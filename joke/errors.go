@@ -0,0 +1,41 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Sentinel errors returned by the providers.
+//
+// An error may match more than one sentinel, e.g. a timed out name request
+// matches both ErrNameUpstream and ErrTimeout.
+var (
+	// ErrNameUpstream reports a failure calling the name service
+	ErrNameUpstream = errors.New("name upstream error")
+	// ErrJokeUpstream reports a failure calling the joke service
+	ErrJokeUpstream = errors.New("joke upstream error")
+	// ErrDecode reports an upstream response that could not be decoded
+	ErrDecode = errors.New("upstream response could not be decoded")
+	// ErrTimeout reports an upstream request that timed out
+	ErrTimeout = errors.New("upstream request timed out")
+	// ErrStatus reports an upstream response with an unsuccessful status code
+	ErrStatus = errors.New("unexpected status code")
+	// ErrCategoryNotAllowed reports a category the request's tenant may not be served
+	ErrCategoryNotAllowed = errors.New("category not allowed")
+)
+
+/*
+	 Function to wrap an error returned while calling an upstream service
+
+		Wraps err with the provider's sentinel, adding ErrTimeout
+		when the request timed out.
+*/
+func upstreamError(sentinel error, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w: %w", sentinel, ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
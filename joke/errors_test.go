@@ -0,0 +1,29 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUpstreamError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Wraps with provider sentinel", func(t *testing.T) {
+		cause := errors.New("connection refused")
+		err := upstreamError(ErrNameUpstream, cause)
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, cause) {
+			t.Errorf("Expected ErrNameUpstream wrapping cause; got %v", err)
+		}
+		if errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected no ErrTimeout; got %v", err)
+		}
+	})
+
+	t.Run("Adds ErrTimeout for deadlines", func(t *testing.T) {
+		err := upstreamError(ErrJokeUpstream, context.DeadlineExceeded)
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrJokeUpstream and ErrTimeout; got %v", err)
+		}
+	})
+}
@@ -0,0 +1,396 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/feature"
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Cache key and lifetime of the joke served when a provider fails
+const (
+	FallbackKey = "joke:fallback"
+	fallbackTTL = 24 * time.Hour
+)
+
+/*
+	 Deps holds the dependencies of the handler returned by NewHandler
+
+		Names and Jokes are required. Cache, History, Features, User
+		and Logger are optional.
+*/
+type Deps struct {
+	// Names provides the name inserted into each joke
+	Names NameProvider
+	// Jokes provides the personalized joke
+	Jokes JokeProvider
+	// Cache holds the fallback joke served when a provider fails.
+	// Without a cache, provider failures are returned as errors.
+	Cache cache.Cache
+	// History records every served joke when set
+	History *history.Store
+	// Features gates optional behaviors, every flag is off when nil
+	Features *feature.Flags
+	// User returns the signed-in user recorded in history, e.g. auth.Subject
+	User func(ctx context.Context) string
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+/*
+	 stageError records which stage of the handler pipeline failed
+
+		msg is the message returned to the client, err the cause.
+*/
+type stageError struct {
+	msg string
+	err error
+}
+
+func (e *stageError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *stageError) Unwrap() error { return e.err }
+
+// struct to hold the dependencies of the joke handler
+type handler struct {
+	deps   Deps
+	logger *slog.Logger
+}
+
+/*
+	 NewHandler returns an http.Handler serving a random personalized joke
+
+		The handler ignores the request path, so it can be mounted at
+		any pattern of another mux, e.g.:
+
+			mux.Handle("/fun/joke", joke.NewHandler(deps))
+*/
+func NewHandler(deps Deps) http.Handler {
+	return &handler{deps: deps, logger: loggerOrDefault(deps.Logger)}
+}
+
+/*
+	 ServeHTTP fetches a name, then a joke personalized with it, and writes the joke
+
+		Requests with a tenant (see tenant.FromContext) are refused
+		categories the tenant doesn't allow and get jokes branded with
+		its template. ?firstName and ?lastName replace the random name,
+		?firstName2 and ?lastName2 add a second person, and ?nickname,
+		?title and ?pronouns are passed to the providers as a Persona.
+		HEAD requests get the headers without a joke being fetched.
+*/
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := tenant.FromContext(r.Context())
+	if t != nil && !t.Allows(DefaultCategory) {
+		writeError(w, h.logger, ErrCategoryNotAllowed, "category "+DefaultCategory+" is not allowed for this tenant")
+		return
+	}
+
+	// Read the people named in the request and how to personalize their joke
+	ctx, names, err := h.personalize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Answer HEAD with the headers alone, without fetching a joke
+	if r.Method == http.MethodHead {
+		h.writeHead(w, r, h.formats())
+		return
+	}
+
+	// Stream pages, so the browser shows one while the joke is fetched
+	if f, ok := render.Negotiate(r, h.formats()...); ok && f.Name == pageFormat.Name {
+		h.servePage(ctx, w, r, names)
+		return
+	}
+
+	res, err := h.fetch(ctx, r, names)
+	if err != nil {
+		h.writeFailure(w, r, err)
+		return
+	}
+	if res.Fallback {
+		w.Header().Set("X-Joke-Fallback", "true")
+	}
+
+	// Call function to return completed joke
+	h.writeJoke(w, r, res)
+}
+
+/*
+	 Function to fetch the request's joke, recording it in history and
+	 keeping it as the fallback
+
+		When a provider fails the cached fallback joke is returned
+		instead, if there is one and the client is still there. Jokes
+		personalized by the request are served without being kept.
+*/
+func (h *handler) fetch(ctx context.Context, r *http.Request, names NameProvider) (jokeResponse, error) {
+	name, text, err := Fetch(ctx, names, h.deps.Jokes)
+
+	// Handle name or joke retrieval error
+	if err != nil {
+		// Serve the cached fallback joke if there is one
+		if h.deps.Cache != nil && !ClientGone(r.Context()) {
+			if cached, ok := h.deps.Cache.Get(FallbackKey); ok {
+				h.logger.WarnContext(r.Context(), "serving fallback joke", "error", err)
+				return jokeResponse{Joke: string(cached), Category: DefaultCategory, Fallback: true}, nil
+			}
+		}
+		return jokeResponse{}, err
+	}
+
+	// Keep the latest joke as the fallback and record it in history,
+	// unless the caller supplied its names
+	if !personal(ctx) {
+		if h.deps.Cache != nil {
+			h.deps.Cache.Set(FallbackKey, []byte(text), fallbackTTL)
+		}
+		h.record(r, name, text)
+	}
+	return jokeResponse{Joke: text, Category: DefaultCategory, FirstName: name.FirstName, LastName: name.LastName}, nil
+}
+
+// Function to write the error response for a joke that couldn't be fetched
+func (h *handler) writeFailure(w http.ResponseWriter, r *http.Request, err error) {
+	// Nobody is left to serve; the upstream calls were already canceled
+	if ClientGone(r.Context()) {
+		h.logger.InfoContext(r.Context(), "client went away before the joke was ready")
+		w.WriteHeader(StatusClientClosedRequest)
+		return
+	}
+
+	h.logger.ErrorContext(r.Context(), "failed to build joke", "error", err)
+	var se *stageError
+	if errors.As(err, &se) {
+		writeError(w, h.logger, se.err, se.msg)
+		return
+	}
+	writeError(w, h.logger, err, "failed to get joke")
+}
+
+/*
+	 Function to return the context and name provider a request's
+	 jokes are fetched with
+
+		Errors describe invalid parameters, to be returned as a 400.
+*/
+func (h *handler) personalize(r *http.Request) (context.Context, NameProvider, error) {
+	q := r.URL.Query()
+	persona, err := ParsePersona(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	first, second, err := ParseNames(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, names := withPeople(WithPersona(r.Context(), persona), h.deps.Names, first, second)
+	if first != nil || second != nil || persona != (Persona{}) {
+		ctx = context.WithValue(ctx, personalKey{}, true)
+	}
+	return ctx, names, nil
+}
+
+// Context key marking jokes personalized with what the request supplied
+type personalKey struct{}
+
+/*
+	 Function to report whether ctx's jokes are personalized with names
+	 or a persona the request supplied
+
+		Such jokes are served only to the caller: they are neither kept
+		as the fallback nor recorded in history, which feeds search,
+		trending, the sitemap and shortlinks.
+*/
+func personal(ctx context.Context) bool {
+	marked, _ := ctx.Value(personalKey{}).(bool)
+	return marked
+}
+
+/*
+	 Fetch gets a random name from names, then a joke personalized with
+	 it from jokes
+
+		The joke is only requested once the name arrives, and both
+		calls are canceled if ctx ends or either fails. Names and the
+		joke are put in Unicode NFC, however the providers encode
+		them. Errors match the providers' sentinels, see ErrorCode.
+*/
+func Fetch(ctx context.Context, names NameProvider, jokes JokeProvider) (Names, string, error) {
+	var name Names
+	var text string
+
+	// Cancel both stages if the client goes away or either stage fails
+	g, ctx := errgroup.WithContext(ctx)
+
+	// Channel handing the name from the first stage to the second
+	ch := make(chan Names, 1)
+
+	// Stage 1: get a random first and last name
+	g.Go(func() error {
+		defer close(ch)
+		n, err := names.Name(ctx)
+		// Handle error while getting name
+		if err != nil {
+			return &stageError{msg: "failed to get name", err: err}
+		}
+		ch <- n.normalize()
+		return nil
+	})
+
+	// Stage 2: get a random joke personalized with the name from stage 1
+	g.Go(func() error {
+		n, ok := <-ch
+		// Stage 1 failed, its error is reported by the group
+		if !ok {
+			return nil
+		}
+		j, err := jokes.Joke(ctx, n.FirstName, n.LastName)
+		// Handle error while getting joke
+		if err != nil {
+			return &stageError{msg: "failed to get joke", err: err}
+		}
+		name, text = n, norm.NFC.String(j)
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return Names{}, "", err
+	}
+	return name, text, nil
+}
+
+/*
+	 struct to hold a served joke in every response format
+
+		JSON carries only the joke, as it did before formats were
+		negotiated, plus the setup and delivery of a two-part joke;
+		the protobuf message is joke.v1.Joke from
+		proto/joke/v1/joke.proto.
+*/
+type jokeResponse struct {
+	Joke      string `json:"joke"`
+	Setup     string `json:"setup,omitempty"`
+	Delivery  string `json:"delivery,omitempty"`
+	Category  string `json:"-"`
+	FirstName string `json:"-"`
+	LastName  string `json:"-"`
+	Fallback  bool   `json:"-"`
+}
+
+// String returns the joke, for the plain text format
+func (j jokeResponse) String() string {
+	return j.Joke
+}
+
+// Proto returns j as a joke.v1.Joke message
+func (j jokeResponse) Proto() proto.Message {
+	return &jokev1.Joke{
+		Joke:      j.Joke,
+		Category:  j.Category,
+		FirstName: j.FirstName,
+		LastName:  j.LastName,
+		Fallback:  j.Fallback,
+		Setup:     j.Setup,
+		Delivery:  j.Delivery,
+	}
+}
+
+// Function to return j with the setup and delivery of its joke, when told in two parts
+func (j jokeResponse) split() jokeResponse {
+	j.Setup, j.Delivery, _ = SplitJoke(j.Joke)
+	return j
+}
+
+/*
+	 Function to return the formats jokes are offered in, the default first
+
+		Plain text is the default unless feature.JSONDefault is on.
+		HTML pages come last, served to browsers asking for them.
+*/
+func (h *handler) formats() []render.Format {
+	if h.deps.Features.Enabled(feature.JSONDefault) {
+		return []render.Format{render.JSON, render.Text, render.Protobuf, render.MsgPack, render.CBOR, render.YAML, pageFormat}
+	}
+	return []render.Format{render.Text, render.JSON, render.Protobuf, render.MsgPack, render.CBOR, render.YAML, pageFormat}
+}
+
+/*
+	 Function writes the joke, branded for the request's tenant, to
+	 http.ResponseWriter in the format the request accepts
+
+		Requests accepting none of the formats get the default rather
+		than a 406, as they did before formats were negotiated.
+*/
+func (h *handler) writeJoke(w http.ResponseWriter, r *http.Request, res jokeResponse) {
+	res = h.brand(r, res).split()
+
+	formats := h.formats()
+	f, ok := render.Negotiate(r, formats...)
+	if !ok {
+		f = formats[0]
+	}
+
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, f, res); err != nil {
+		h.logger.Error("failed to write response", "error", err)
+	}
+}
+
+// Function to record a served joke in history, if the handler keeps one
+func (h *handler) record(r *http.Request, name Names, text string) {
+	if h.deps.History == nil {
+		return
+	}
+	e := history.Entry{
+		Joke:      text,
+		Category:  DefaultCategory,
+		FirstName: name.FirstName,
+		LastName:  name.LastName,
+		ServedAt:  time.Now(),
+		Tenant:    tenant.ID(r.Context()),
+	}
+	if h.deps.User != nil {
+		e.User = h.deps.User(r.Context())
+	}
+	h.deps.History.Add(e)
+}
+
+// Function to brand res for the request's tenant, if it has one
+func (h *handler) brand(r *http.Request, res jokeResponse) jokeResponse {
+	t := tenant.FromContext(r.Context())
+	if t == nil {
+		return res
+	}
+	branded, err := t.Brand(tenant.Branding{Joke: res.Joke, FirstName: res.FirstName, LastName: res.LastName})
+	// Handle errors while branding; serve the joke unbranded
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to brand joke", "error", err)
+		return res
+	}
+	res.Joke = branded
+	return res
+}
+
+// Function to send the headers a GET would get in the format the request
+// accepts, for HEAD requests
+func (h *handler) writeHead(w http.ResponseWriter, r *http.Request, formats []render.Format) {
+	f, ok := render.Negotiate(r, formats...)
+	if !ok {
+		f = formats[0]
+	}
+	render.WriteHeader(w, http.StatusOK, f)
+}
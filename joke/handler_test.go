@@ -0,0 +1,501 @@
+package joke
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/feature"
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+/*
+	 Function to build the joke handler around mocked providers
+
+		A nil joke mock may be passed when the joke provider is
+		never expected to be called.
+*/
+func newTestHandler(
+	getRandomName func(ctx context.Context) (Names, error),
+	getRandomJoke func(ctx context.Context, firstName, lastName string) (string, error),
+) http.Handler {
+	return NewHandler(Deps{
+		Names: NameProviderFunc(getRandomName),
+		Jokes: JokeProviderFunc(getRandomJoke),
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	// Mock getRandomName to return a predefined value
+	getRandomName := func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	}
+	// Mock getRandomJoke to return predefined value
+	getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "Mocked joke about John Doe", nil
+	}
+
+	t.Run("Returns 200 status code", func(t *testing.T) {
+
+		// Create a request to pass to the handler
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		// Handle error while creating response
+		if err != nil {
+			t.Fatalf("Could not create request: %v", err)
+		}
+
+		// Record response
+		rec := httptest.NewRecorder()
+
+		// Initialize handler
+		handler := newTestHandler(getRandomName, getRandomJoke)
+
+		// Call the handler
+		handler.ServeHTTP(rec, req)
+
+		// Check the status code for 200
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Returns expected string", func(t *testing.T) {
+
+		// Create a request to pass to the handler
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+
+		// Handle error while creating the request
+		if err != nil {
+			t.Fatalf("Could not create request: %v", err)
+		}
+
+		// Record response
+		rec := httptest.NewRecorder()
+
+		// Initialize handler
+		handler := newTestHandler(getRandomName, getRandomJoke)
+
+		// Call the handler
+		handler.ServeHTTP(rec, req)
+
+		// String expected from the response
+		expected := "Mocked joke about John Doe"
+
+		// Verify expected string is in the response body
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("Expected response body to contain %q; got %q", expected, rec.Body.String())
+		}
+	})
+}
+
+func TestHandlerFailures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("getRandomName failure", func(t *testing.T) {
+		// Mock getRandomName to return an error
+		getRandomName := func(ctx context.Context) (Names, error) {
+			return Names{}, fmt.Errorf("failed to fetch name")
+		}
+
+		// Create a request to pass to the handler
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatalf("Could not create request: %v", err)
+		}
+
+		// Record response
+		rec := httptest.NewRecorder()
+
+		// Initialize the handler
+		handler := newTestHandler(getRandomName, nil)
+
+		// Call the handler
+		handler.ServeHTTP(rec, req)
+
+		// Verify status code is 500
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
+		}
+	})
+
+	t.Run("getRandomJoke failure", func(t *testing.T) {
+		// Mock getRandomName
+		getRandomName := func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		}
+
+		// Mock and simulate a failed call to getRandomJoke
+		getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", fmt.Errorf("failed to fetch joke")
+		}
+
+		// Create a request to pass to the handler
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatalf("Could not create request: %v", err)
+		}
+
+		// Record response
+		rec := httptest.NewRecorder()
+
+		// Initialize the handler
+		handler := newTestHandler(getRandomName, getRandomJoke)
+
+		// Call the handler
+		handler.ServeHTTP(rec, req)
+
+		// Verify status code is 500
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
+		}
+	})
+}
+
+func TestHandlerPipeline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Skips joke when name fails", func(t *testing.T) {
+		getRandomName := func(ctx context.Context) (Names, error) {
+			return Names{}, fmt.Errorf("failed to fetch name")
+		}
+		jokeCalled := false
+		getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+			jokeCalled = true
+			return "", nil
+		}
+
+		rec := httptest.NewRecorder()
+		newTestHandler(getRandomName, getRandomJoke).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if jokeCalled {
+			t.Error("Expected getRandomJoke not to be called")
+		}
+		if !strings.Contains(rec.Body.String(), "failed to get name") {
+			t.Errorf("Expected name failure message; got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Cancels upstream calls when client goes away", func(t *testing.T) {
+		getRandomName := func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		}
+		// Block until the request context is cancelled
+		getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			newTestHandler(getRandomName, getRandomJoke).ServeHTTP(httptest.NewRecorder(), req)
+			close(done)
+		}()
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Expected handler to return after cancellation")
+		}
+	})
+}
+
+func TestNewHandler(t *testing.T) {
+	t.Parallel()
+
+	deps := Deps{
+		Names: NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		}),
+		Jokes: JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return firstName + " " + lastName + " writes bug-free code", nil
+		}),
+		History: history.New(10),
+	}
+
+	t.Run("Mounts under another mux", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("GET /fun/joke", NewHandler(deps))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fun/joke", nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "John Doe writes bug-free code" {
+			t.Errorf("Expected joke with status OK; got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Passes the persona to the providers", func(t *testing.T) {
+		var got Persona
+		d := deps
+		d.Jokes = JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			got = PersonaFrom(ctx)
+			return "joke", nil
+		})
+		rec := httptest.NewRecorder()
+		NewHandler(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?nickname=JD&title=Dr.&pronouns=he/him", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %d", rec.Code)
+		}
+		if want := (Persona{Nickname: "JD", Title: "Dr.", Pronouns: He}); got != want {
+			t.Errorf("Expected persona %+v; got %+v", want, got)
+		}
+
+		rec = httptest.NewRecorder()
+		NewHandler(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?pronouns=x/y", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for invalid pronouns; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Names the people from the query", func(t *testing.T) {
+		d := deps
+		d.Jokes = JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			second, _ := SecondFrom(ctx)
+			return firstName + " and " + second.FirstName, nil
+		})
+		rec := httptest.NewRecorder()
+		NewHandler(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?firstName=Ada&firstName2=Grace", nil))
+
+		if rec.Body.String() != "Ada and Grace" {
+			t.Errorf("Expected a joke about Ada and Grace; got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Normalizes names and jokes to NFC", func(t *testing.T) {
+		d := deps
+		d.Names = NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{"Zoe\u0308", " van  der Berg "}, nil
+		})
+		d.Jokes = JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return firstName + " " + lastName + " rene\u0301e", nil
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		NewHandler(d).ServeHTTP(rec, req)
+
+		if want := "{\"joke\":\"Zo\u00eb van der Berg ren\u00e9e\"}"; strings.TrimSpace(rec.Body.String()) != want {
+			t.Errorf("Expected %s; got %s", want, rec.Body.String())
+		}
+	})
+
+	t.Run("Records served jokes in history", func(t *testing.T) {
+		NewHandler(deps).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		entries, _ := deps.History.List(history.Filter{Page: 1, PerPage: 1})
+		if len(entries) != 1 || entries[0].FirstName != "John" || entries[0].Category != DefaultCategory {
+			t.Errorf("Expected history entry for John; got %+v", entries)
+		}
+	})
+
+	t.Run("Records signed-in user in history", func(t *testing.T) {
+		userDeps := deps
+		userDeps.History = history.New(10)
+		userDeps.User = func(ctx context.Context) string { return "user-1" }
+
+		NewHandler(userDeps).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		entries, _ := userDeps.History.List(history.Filter{Page: 1, PerPage: 1, User: "user-1"})
+		if len(entries) != 1 {
+			t.Errorf("Expected history entry for user-1; got %+v", entries)
+		}
+	})
+
+	t.Run("Keeps jokes personalized by the request private", func(t *testing.T) {
+		for _, query := range []string{"firstName=Ada", "firstName2=Grace", "nickname=JD"} {
+			d := deps
+			d.History = history.New(10)
+			d.Cache = cache.NewMemory()
+			rec := httptest.NewRecorder()
+			NewHandler(d).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: expected status OK; got %d", query, rec.Code)
+			}
+			if _, total := d.History.List(history.Filter{Page: 1, PerPage: 1}); total != 0 {
+				t.Errorf("%s: expected no history entry; got %d", query, total)
+			}
+			if _, ok := d.Cache.Get(FallbackKey); ok {
+				t.Errorf("%s: expected no fallback joke", query)
+			}
+		}
+	})
+
+	t.Run("Serves JSON when json_default is enabled", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "features.json")
+		if err := os.WriteFile(path, []byte(`{"json_default": true}`), 0o644); err != nil {
+			t.Fatalf("Could not write flag file: %v", err)
+		}
+		flags, err := feature.New(path, nil)
+		if err != nil {
+			t.Fatalf("Could not load flags: %v", err)
+		}
+		jsonDeps := deps
+		jsonDeps.Features = flags
+
+		rec := httptest.NewRecorder()
+		NewHandler(jsonDeps).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected Content-Type application/json; got %q", ct)
+		}
+		if body := rec.Body.String(); body != `{"joke":"John Doe writes bug-free code"}`+"\n" {
+			t.Errorf("Unexpected body: %q", body)
+		}
+	})
+
+	t.Run("Splits two-part jokes into setup and delivery", func(t *testing.T) {
+		twoPart := deps
+		twoPart.Jokes = JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "Why does " + firstName + " never debug?\nBugs confess.", nil
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		NewHandler(twoPart).ServeHTTP(rec, r)
+
+		want := `{"joke":"Why does John never debug?\nBugs confess.","setup":"Why does John never debug?","delivery":"Bugs confess."}` + "\n"
+		if body := rec.Body.String(); body != want {
+			t.Errorf("Unexpected body: %q", body)
+		}
+	})
+
+	t.Run("Brands jokes and records the tenant", func(t *testing.T) {
+		reg, err := tenant.New(&tenant.Tenant{ID: "acme", Name: "Acme", Template: "{{.Joke}}, says {{.Tenant}}"})
+		if err != nil {
+			t.Fatalf("Could not build tenants: %v", err)
+		}
+		acme, _ := reg.Get("acme")
+		tenantDeps := deps
+		tenantDeps.History = history.New(10)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		NewHandler(tenantDeps).ServeHTTP(rec, req.WithContext(tenant.NewContext(req.Context(), acme)))
+
+		if body := rec.Body.String(); body != "John Doe writes bug-free code, says Acme" {
+			t.Errorf("Unexpected body: %q", body)
+		}
+		if _, total := tenantDeps.History.List(history.Filter{Page: 1, PerPage: 1, Tenant: "acme"}); total != 1 {
+			t.Errorf("Expected one history entry for acme; got %d", total)
+		}
+	})
+
+	t.Run("Refuses categories the tenant does not allow", func(t *testing.T) {
+		reg, _ := tenant.New(&tenant.Tenant{ID: "kids", Categories: []string{"animals"}})
+		kids, _ := reg.Get("kids")
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, req.WithContext(tenant.NewContext(req.Context(), kids)))
+
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "category_not_allowed") {
+			t.Errorf("Expected category_not_allowed with status Forbidden; got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Serves protobuf when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, req)
+
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("Expected Content-Type application/x-protobuf; got %q", ct)
+		}
+		var j jokev1.Joke
+		if err := proto.Unmarshal(rec.Body.Bytes(), &j); err != nil {
+			t.Fatalf("Could not decode joke.v1.Joke: %v", err)
+		}
+		if j.Joke != "John Doe writes bug-free code" || j.Category != "nerdy" || j.FirstName != "John" || j.LastName != "Doe" {
+			t.Errorf("Unexpected joke %v", &j)
+		}
+	})
+
+	t.Run("Serves msgpack when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/msgpack")
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, req)
+
+		// A one-entry map of "joke" to the 29 byte joke
+		want := "\x81\xa4joke\xbdJohn Doe writes bug-free code"
+		if body := rec.Body.String(); body != want {
+			t.Errorf("Expected body %q; got %q", want, body)
+		}
+	})
+
+	t.Run("Serves CBOR when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?format=cbor", nil)
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, req)
+
+		// A one-entry map of "joke" to the 29 byte joke
+		want := "\xa1\x64joke\x78\x1dJohn Doe writes bug-free code"
+		if ct := rec.Header().Get("Content-Type"); ct != "application/cbor" {
+			t.Errorf("Expected Content-Type application/cbor; got %q", ct)
+		}
+		if body := rec.Body.String(); body != want {
+			t.Errorf("Expected body %q; got %q", want, body)
+		}
+	})
+
+	t.Run("Serves the default to unacceptable requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "image/png")
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "John Doe writes bug-free code" {
+			t.Errorf("Expected plain joke with status OK; got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("client goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan struct{})
+		getRandomName := func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		}
+		// The upstream call hangs until the client goes away
+		getRandomJoke := func(ctx context.Context, firstName, lastName string) (string, error) {
+			cancel()
+			<-ctx.Done()
+			close(canceled)
+			return "", upstreamError(ErrJokeUpstream, ctx.Err())
+		}
+		deps := Deps{Names: NameProviderFunc(getRandomName), Jokes: JokeProviderFunc(getRandomJoke), Cache: cache.NewMemory()}
+		deps.Cache.Set(FallbackKey, []byte("fallback"), time.Minute)
+
+		rec := httptest.NewRecorder()
+		NewHandler(deps).ServeHTTP(rec, httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil))
+
+		select {
+		case <-canceled:
+		default:
+			t.Error("Expected the upstream call to be canceled")
+		}
+		// The fallback joke isn't served to nobody
+		if rec.Code != StatusClientClosedRequest || rec.Body.Len() != 0 {
+			t.Errorf("Expected an empty 499; got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
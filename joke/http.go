@@ -0,0 +1,247 @@
+package joke
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Endpoint for getting a random first and last name
+const DefaultNameEndpoint = "https://names.mcquay.me/api/v0/"
+
+// Base endpoint for generating a random joke.
+// Use query string values 'firstName' and 'lastName' to personalize
+const DefaultJokeEndpoint = "http://joke.loc8u.com:8888/joke?limitTo=nerdy"
+
+// Category of every joke returned by DefaultJokeEndpoint (limitTo=nerdy)
+const DefaultCategory = "nerdy"
+
+// Timeouts of an upstream call when none are configured, short enough
+// for a joke to be served interactively
+const (
+	DefaultConnectTimeout = 2 * time.Second
+	DefaultReadTimeout    = 5 * time.Second
+)
+
+// Client used when a provider has no Client configured
+var defaultClient = NewClient(Timeouts{})
+
+// Timeouts bounds each call to an upstream service
+type Timeouts struct {
+	// Connect bounds dialing and the TLS handshake, defaults to DefaultConnectTimeout
+	Connect time.Duration
+	// Read bounds the wait for the response once connected, defaults to DefaultReadTimeout
+	Read time.Duration
+}
+
+/*
+	 NewClient returns an http.Client for an upstream service with
+	 its own connect and read timeouts
+
+		Connect bounds establishing the connection; Read bounds the
+		wait for the response headers. The whole call, body included,
+		is bounded by the two together. Each client has its own
+		connection pool, so upstreams don't share idle connections.
+*/
+func NewClient(t Timeouts) *http.Client {
+	connect := cmp.Or(t.Connect, DefaultConnectTimeout)
+	read := cmp.Or(t.Read, DefaultReadTimeout)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connect
+	transport.ResponseHeaderTimeout = read
+	return &http.Client{Transport: transport, Timeout: connect + read}
+}
+
+/*
+	 HTTPNameProvider returns random names from an upstream web service.
+
+		The zero value calls DefaultNameEndpoint with a client using
+		the default Timeouts.
+*/
+type HTTPNameProvider struct {
+	// Endpoint of the name service, defaults to DefaultNameEndpoint
+	Endpoint string
+	// Client used for requests, defaults to a client with the default Timeouts
+	Client *http.Client
+	// Logger for debug output, defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+/*
+	 HTTPJokeProvider returns personalized jokes from an upstream web service.
+
+		The zero value calls DefaultJokeEndpoint with a client using
+		the default Timeouts.
+*/
+type HTTPJokeProvider struct {
+	// Endpoint of the joke service, defaults to DefaultJokeEndpoint
+	Endpoint string
+	// Client used for requests, defaults to a client with the default Timeouts
+	Client *http.Client
+	// Logger for debug output, defaults to slog.Default()
+	Logger *slog.Logger
+	// Exclude lists categories the service must not return, e.g. "explicit",
+	// sent as exclude=[a,b]
+	Exclude []string
+}
+
+/*
+	 Function to return random first and last name.
+
+		Calls external web service:
+			"https://names.mcquay.me/api/v0/"
+
+		Returns Names struct
+*/
+func (p *HTTPNameProvider) Name(ctx context.Context) (Names, error) {
+	// Parse endpoint into a URL structure
+	base, err := url.Parse(orDefault(p.Endpoint, DefaultNameEndpoint))
+	// Handle errors while parsing
+	if err != nil {
+		return Names{}, fmt.Errorf("client could not parse url: %w", err)
+	}
+	// Create the GET request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	// Handle errors creating request
+	if err != nil {
+		return Names{}, fmt.Errorf("client could not create request: %w", err)
+	}
+	// Make the request
+	res, err := clientOrDefault(p.Client).Do(req)
+	// Handle errors while making request
+	if err != nil {
+		return Names{}, upstreamError(ErrNameUpstream, fmt.Errorf("client: error making http request: %w", err))
+	}
+	// Close the response body once it has been read
+	defer res.Body.Close()
+	// Log status code for debugging
+	loggerOrDefault(p.Logger).DebugContext(ctx, "client: got response", "upstream", "name", "status", res.StatusCode)
+	// Handle unsuccessful status codes
+	if res.StatusCode != http.StatusOK {
+		return Names{}, fmt.Errorf("%w: %w: %d", ErrNameUpstream, ErrStatus, res.StatusCode)
+	}
+	// Read the response body, capped so a hostile upstream can't exhaust memory
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	// Handle errors while reading response body
+	if err != nil {
+		return Names{}, upstreamError(ErrNameUpstream, fmt.Errorf("client: could not read response body: %w", err))
+	}
+	// Decode the response body
+	n, err := DecodeName(resBody)
+	if err != nil {
+		return Names{}, fmt.Errorf("%w: %w", ErrNameUpstream, err)
+	}
+	// Return Names struct
+	return n, nil
+}
+
+/*
+	 Function to return random Chuck Norris joke
+
+		Accepts a request context, firstName and lastName as arguments
+		and calls external web service:
+			"http://joke.loc8u.com:8888/joke?limitTo=nerdy"
+
+		Passes firstName and lastName in the query string to
+		personalize the joke being returned.
+
+		Returns the joke string
+*/
+func (p *HTTPJokeProvider) Joke(ctx context.Context, firstName, lastName string) (string, error) {
+	// Parse endpoint into a URL structure
+	base, err := url.Parse(orDefault(p.Endpoint, DefaultJokeEndpoint))
+
+	// Handle errors while parsing url
+	if err != nil {
+		return "", fmt.Errorf("client could not parse url: %w", err)
+	}
+
+	// Keep query string values already on the endpoint (e.g. limitTo)
+	params := base.Query()
+
+	// Add the firstName and lastName to params
+	params.Set("firstName", firstName)
+	params.Set("lastName", lastName)
+	if len(p.Exclude) > 0 {
+		params.Set("exclude", "["+strings.Join(p.Exclude, ",")+"]")
+	}
+
+	// Encode and add query string values to base URL
+	base.RawQuery = params.Encode()
+
+	// Create the GET request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+
+	// Handle errors while creating the request
+	if err != nil {
+		return "", fmt.Errorf("client could not create request: %w", err)
+	}
+
+	// Make the request
+	res, err := clientOrDefault(p.Client).Do(req)
+
+	// Handle errors while making request
+	if err != nil {
+		return "", upstreamError(ErrJokeUpstream, fmt.Errorf("client: error making http request: %w", err))
+	}
+
+	// Close the response body once it has been read
+	defer res.Body.Close()
+	// Log status code for debugging
+	loggerOrDefault(p.Logger).DebugContext(ctx, "client: got response", "upstream", "joke", "status", res.StatusCode)
+
+	// Handle unsuccessful status codes
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %w: %d", ErrJokeUpstream, ErrStatus, res.StatusCode)
+	}
+
+	// Read the response body, capped so a hostile upstream can't exhaust memory
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+
+	// Handle errors while reading response body
+	if err != nil {
+		return "", upstreamError(ErrJokeUpstream, fmt.Errorf("client: could not read response body: %w", err))
+	}
+
+	// Decode the joke from the response body
+	text, err := DecodeJoke(resBody)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrJokeUpstream, err)
+	}
+
+	// Return joke string
+	return text, nil
+}
+
+// Function to return s, or def when s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// Function to return c, or the default client when c is nil
+func clientOrDefault(c *http.Client) *http.Client {
+	if c == nil {
+		return defaultClient
+	}
+	return c
+}
+
+// Function to return l, or slog.Default() when l is nil
+func loggerOrDefault(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
@@ -0,0 +1,244 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Function to start a mock upstream that is closed when the test ends
+func mockUpstream(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestHTTPNameProviderFailures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Invalid endpoint", func(t *testing.T) {
+		p := &HTTPNameProvider{Endpoint: "://missing-scheme"}
+
+		if _, err := p.Name(context.Background()); err == nil {
+			t.Error("Expected error for invalid endpoint; got nil")
+		}
+	})
+
+	t.Run("Unreachable upstream", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+		upstream.Close()
+		p := &HTTPNameProvider{Endpoint: upstream.URL}
+
+		_, err := p.Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) {
+			t.Errorf("Expected ErrNameUpstream; got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		})
+		p := &HTTPNameProvider{
+			Endpoint: upstream.URL,
+			Client:   &http.Client{Timeout: 10 * time.Millisecond},
+		}
+
+		_, err := p.Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrNameUpstream and ErrTimeout; got %v", err)
+		}
+	})
+
+	t.Run("Unsuccessful status code", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		p := &HTTPNameProvider{Endpoint: upstream.URL}
+
+		_, err := p.Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected ErrNameUpstream and ErrStatus; got %v", err)
+		}
+	})
+
+	t.Run("Non-JSON response", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "<html>not json</html>")
+		})
+		p := &HTTPNameProvider{Endpoint: upstream.URL}
+
+		_, err := p.Name(context.Background())
+		if !errors.Is(err, ErrNameUpstream) || !errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrNameUpstream and ErrDecode; got %v", err)
+		}
+	})
+
+	t.Run("Mismatched JSON", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"first_name": 42}`)
+		})
+		p := &HTTPNameProvider{Endpoint: upstream.URL}
+
+		_, err := p.Name(context.Background())
+		if !errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrDecode; got %v", err)
+		}
+	})
+}
+
+func TestHTTPJokeProviderFailures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Invalid endpoint", func(t *testing.T) {
+		p := &HTTPJokeProvider{Endpoint: "://missing-scheme"}
+
+		if _, err := p.Joke(context.Background(), "John", "Doe"); err == nil {
+			t.Error("Expected error for invalid endpoint; got nil")
+		}
+	})
+
+	t.Run("Unreachable upstream", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+		upstream.Close()
+		p := &HTTPJokeProvider{Endpoint: upstream.URL}
+
+		_, err := p.Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) {
+			t.Errorf("Expected ErrJokeUpstream; got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		})
+		p := &HTTPJokeProvider{
+			Endpoint: upstream.URL,
+			Client:   &http.Client{Timeout: 10 * time.Millisecond},
+		}
+
+		_, err := p.Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrJokeUpstream and ErrTimeout; got %v", err)
+		}
+	})
+
+	t.Run("Unsuccessful status code", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL}
+
+		_, err := p.Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected ErrJokeUpstream and ErrStatus; got %v", err)
+		}
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"value": {"joke": `)
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL}
+
+		_, err := p.Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrDecode) {
+			t.Errorf("Expected ErrJokeUpstream and ErrDecode; got %v", err)
+		}
+	})
+
+	t.Run("Sends name in query string", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			// Verify the endpoint's own query string values are kept
+			if got := r.URL.Query().Get("limitTo"); got != DefaultCategory {
+				t.Errorf("Expected limitTo %q; got %q", DefaultCategory, got)
+			}
+			fmt.Fprintf(w, `{"value": {"joke": "%s %s writes bug-free code"}}`,
+				r.URL.Query().Get("firstName"), r.URL.Query().Get("lastName"))
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL + "?limitTo=" + DefaultCategory}
+
+		joke, err := p.Joke(context.Background(), "John", "Doe")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if joke != "John Doe writes bug-free code" {
+			t.Errorf("Unexpected joke: %q", joke)
+		}
+	})
+
+	t.Run("Sends excluded categories", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			if got := r.URL.Query().Get("exclude"); got != "[explicit,political]" {
+				t.Errorf("Expected exclude [explicit,political]; got %q", got)
+			}
+			io.WriteString(w, `{"value": {"joke": "a clean joke"}}`)
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL, Exclude: []string{"explicit", "political"}}
+
+		if _, err := p.Joke(context.Background(), "John", "Doe"); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+	})
+
+	t.Run("Sends non-ASCII and multi-word names intact", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"value": {"joke": "%s %s writes bug-free code"}}`,
+				r.URL.Query().Get("firstName"), r.URL.Query().Get("lastName"))
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL}
+
+		joke, err := p.Joke(context.Background(), "Zoë & José", "van der Berg-Ñúñez")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if joke != "Zoë & José van der Berg-Ñúñez writes bug-free code" {
+			t.Errorf("Unexpected joke: %q", joke)
+		}
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Read timeout", func(t *testing.T) {
+		upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		})
+		p := &HTTPJokeProvider{Endpoint: upstream.URL, Client: NewClient(Timeouts{Read: 10 * time.Millisecond})}
+
+		_, err := p.Joke(context.Background(), "John", "Doe")
+		if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected ErrJokeUpstream and ErrTimeout; got %v", err)
+		}
+	})
+
+	t.Run("Connect timeout", func(t *testing.T) {
+		// A dial can't be made to hang without a network, so the transport is checked
+		c := NewClient(Timeouts{Connect: 50 * time.Millisecond})
+		transport := c.Transport.(*http.Transport)
+		if transport.TLSHandshakeTimeout != 50*time.Millisecond {
+			t.Errorf("Expected a 50ms TLS handshake timeout; got %v", transport.TLSHandshakeTimeout)
+		}
+		if c.Timeout != 50*time.Millisecond+DefaultReadTimeout {
+			t.Errorf("Expected the call bounded by both timeouts; got %v", c.Timeout)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		c := NewClient(Timeouts{})
+		if transport := c.Transport.(*http.Transport); transport.ResponseHeaderTimeout != DefaultReadTimeout {
+			t.Errorf("Expected a %v read timeout; got %v", DefaultReadTimeout, transport.ResponseHeaderTimeout)
+		}
+		if c.Timeout != DefaultConnectTimeout+DefaultReadTimeout {
+			t.Errorf("Expected the call bounded by both timeouts; got %v", c.Timeout)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Package joke fetches random names and personalized jokes from upstream
+// services and serves them over HTTP.
+package joke
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// struct to hold expected output of Names
+type Names struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+/*
+	 struct to hold expected output of Joke
+
+		Services telling jokes in two parts send the setup and
+		delivery instead of the joke, either in the value or, as
+		JokeAPI does, at the top level with type "twopart".
+*/
+type Joke struct {
+	Value struct {
+		Joke     string `json:"joke"`
+		Setup    string `json:"setup"`
+		Delivery string `json:"delivery"`
+	} `json:"value"`
+	Type     string `json:"type"`
+	Setup    string `json:"setup"`
+	Delivery string `json:"delivery"`
+}
+
+// Separator between the setup and delivery of a two-part joke's text
+const PunchlineSeparator = "\n"
+
+/*
+	 SplitJoke returns the setup and delivery of a two-part joke, the
+	 text before and after its first line break
+
+		ok is false for a joke told in one part, with no line break or
+		nothing on one side of it.
+*/
+func SplitJoke(text string) (setup, delivery string, ok bool) {
+	setup, delivery, ok = strings.Cut(text, PunchlineSeparator)
+	setup, delivery = strings.TrimSpace(setup), strings.TrimSpace(delivery)
+	if !ok || setup == "" || delivery == "" {
+		return "", "", false
+	}
+	return setup, delivery, true
+}
+
+/*
+	 Function to return n in the form jokes are built with
+
+		Names are put in Unicode NFC, so "Zoë" is the same whether its
+		ë arrived as one rune or two, and runs of spaces are collapsed,
+		leaving multi-word surnames such as "van der Berg" one space
+		apart.
+*/
+func (n Names) normalize() Names {
+	return Names{FirstName: normalizeName(n.FirstName), LastName: normalizeName(n.LastName)}
+}
+
+// Function to put one name in NFC with single spaces between its words
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// NameProvider returns a random first and last name
+type NameProvider interface {
+	Name(ctx context.Context) (Names, error)
+}
+
+// JokeProvider returns a joke personalized with firstName and lastName
+type JokeProvider interface {
+	Joke(ctx context.Context, firstName, lastName string) (string, error)
+}
+
+// NameProviderFunc adapts an ordinary function to a NameProvider
+type NameProviderFunc func(ctx context.Context) (Names, error)
+
+// Name calls f(ctx)
+func (f NameProviderFunc) Name(ctx context.Context) (Names, error) {
+	return f(ctx)
+}
+
+// JokeProviderFunc adapts an ordinary function to a JokeProvider
+type JokeProviderFunc func(ctx context.Context, firstName, lastName string) (string, error)
+
+// Joke calls f(ctx, firstName, lastName)
+func (f JokeProviderFunc) Joke(ctx context.Context, firstName, lastName string) (string, error) {
+	return f(ctx, firstName, lastName)
+}
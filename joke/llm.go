@@ -0,0 +1,245 @@
+package joke
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Chat completions endpoint of the OpenAI API; other compatible APIs serve the same path
+const DefaultLLMEndpoint = "https://api.openai.com/v1/chat/completions"
+
+// Model asked for jokes when none is configured
+const DefaultLLMModel = "gpt-4o-mini"
+
+// Prompt rendered with the person when none is configured
+const DefaultLLMPrompt = `Write one short, original, family-friendly nerdy joke in the style of a Chuck Norris fact, starring {{.Name}}{{if .Name2}} and {{.Name2}} together{{end}} instead of Chuck Norris.{{if ne .Nickname .FirstName}} Call {{.FirstName}} "{{.Nickname}}" at least once.{{end}} {{.FirstName}}'s pronouns are {{.Pronouns}}. Reply with the joke only.`
+
+// Tokens each completion may use when MaxTokens is not configured
+const DefaultLLMMaxTokens = 120
+
+// ErrBudget reports a joke not generated because a cost limit was reached
+var ErrBudget = errors.New("generation budget exhausted")
+
+/*
+	 LLMJokeProvider generates personalized jokes with an
+	 OpenAI-compatible chat completions API
+
+		Every joke costs tokens, so calls can be capped by Limiter and
+		DailyTokens; calls over a cap fail with ErrBudget without
+		reaching the API, and the server serves its fallback joke.
+		The zero value calls DefaultLLMEndpoint with DefaultLLMModel,
+		DefaultLLMPrompt and no caps.
+*/
+type LLMJokeProvider struct {
+	// Endpoint of the chat completions API, defaults to DefaultLLMEndpoint
+	Endpoint string
+	// APIKey sent as a bearer token
+	APIKey string
+	// Model asked for jokes, defaults to DefaultLLMModel
+	Model string
+	// Prompt is a text/template given the fields MadLibs templates are, without word lists; defaults to DefaultLLMPrompt
+	Prompt string
+	// MaxTokens caps each completion, defaults to DefaultLLMMaxTokens
+	MaxTokens int
+	// Limiter caps how often the API is called; nil doesn't limit
+	Limiter *rate.Limiter
+	// DailyTokens caps the tokens used per UTC day, as reported by the API; 0 doesn't limit
+	DailyTokens int
+	// Client used for requests, defaults to a client with the default Timeouts
+	Client *http.Client
+	// Logger for debug output, defaults to slog.Default()
+	Logger *slog.Logger
+
+	// Prompt parsed once, on first use
+	once   sync.Once
+	prompt *template.Template
+	err    error
+
+	// Tokens used on day, reset when the UTC day changes
+	mu   sync.Mutex
+	day  string
+	used int
+
+	// Current time, replaced in tests
+	now func() time.Time
+}
+
+// struct to hold a chat completions request
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens"`
+}
+
+// struct to hold one message of a chat
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// struct to hold the parts of a chat completions response used
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+/*
+	 Function to return a joke about firstName lastName generated by
+	 the model
+
+		Fails with ErrBudget, without calling the API, when Limiter
+		or DailyTokens is exhausted. Errors wrap ErrJokeUpstream.
+*/
+func (p *LLMJokeProvider) Joke(ctx context.Context, firstName, lastName string) (string, error) {
+	// Render the prompt for these names
+	prompt, err := p.render(ctx, firstName, lastName)
+	if err != nil {
+		return "", err
+	}
+	// Check the cost limits before spending anything
+	if err := p.reserve(); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrJokeUpstream, err)
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model:     orDefault(p.Model, DefaultLLMModel),
+		Messages:  []chatMessage{{Role: "user", Content: prompt}},
+		MaxTokens: p.maxTokens(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("client could not encode request: %w", err)
+	}
+	// Create the POST request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, orDefault(p.Endpoint, DefaultLLMEndpoint), bytes.NewReader(body))
+	// Handle errors while creating the request
+	if err != nil {
+		return "", fmt.Errorf("client could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	// Make the request
+	res, err := clientOrDefault(p.Client).Do(req)
+	// Handle errors while making request
+	if err != nil {
+		return "", upstreamError(ErrJokeUpstream, fmt.Errorf("client: error making http request: %w", err))
+	}
+	// Close the response body once it has been read
+	defer res.Body.Close()
+	// Log status code for debugging
+	loggerOrDefault(p.Logger).DebugContext(ctx, "client: got response", "upstream", "llm", "status", res.StatusCode)
+	// Handle unsuccessful status codes, e.g. 429 when the API's own quota is used up
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %w: %d", ErrJokeUpstream, ErrStatus, res.StatusCode)
+	}
+	// Read the response body, capped so a hostile upstream can't exhaust memory
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	// Handle errors while reading response body
+	if err != nil {
+		return "", upstreamError(ErrJokeUpstream, fmt.Errorf("client: could not read response body: %w", err))
+	}
+
+	var chat chatResponse
+	// Decode the completion
+	if err := json.Unmarshal(resBody, &chat); err != nil {
+		return "", fmt.Errorf("%w: %w: error unmarshalling JSON: %w", ErrJokeUpstream, ErrDecode, err)
+	}
+	p.spend(chat.Usage.TotalTokens)
+	if len(chat.Choices) == 0 {
+		return "", fmt.Errorf("%w: %w: no choices in response: %q", ErrJokeUpstream, ErrDecode, quoteBody(resBody))
+	}
+	text := cleanCompletion(chat.Choices[0].Message.Content)
+	if text == "" {
+		return "", fmt.Errorf("%w: %w: empty completion", ErrJokeUpstream, ErrDecode)
+	}
+	return text, nil
+}
+
+// Function to render the prompt template with the name and the Persona in ctx
+func (p *LLMJokeProvider) render(ctx context.Context, firstName, lastName string) (string, error) {
+	p.once.Do(func() {
+		p.prompt, p.err = template.New("prompt").Funcs(templateFuncs).Option("missingkey=error").Parse(orDefault(p.Prompt, DefaultLLMPrompt))
+	})
+	// Handle errors while parsing the template
+	if p.err != nil {
+		return "", fmt.Errorf("llm: could not parse prompt: %w", p.err)
+	}
+	var b strings.Builder
+	// Handle errors while rendering the template, e.g. an unknown field
+	if err := p.prompt.Execute(&b, personaFields(ctx, firstName, lastName)); err != nil {
+		return "", fmt.Errorf("llm: could not render prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Function to check the rate and daily token caps before a call
+func (p *LLMJokeProvider) reserve() error {
+	if p.DailyTokens > 0 {
+		p.mu.Lock()
+		p.rollDay()
+		spent := p.used >= p.DailyTokens
+		p.mu.Unlock()
+		if spent {
+			return fmt.Errorf("%w: %d daily tokens used", ErrBudget, p.DailyTokens)
+		}
+	}
+	if p.Limiter != nil && !p.Limiter.Allow() {
+		return fmt.Errorf("%w: rate limited", ErrBudget)
+	}
+	return nil
+}
+
+// Function to count tokens used against today's budget
+func (p *LLMJokeProvider) spend(tokens int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rollDay()
+	p.used += tokens
+}
+
+// Function to reset the tokens used when the UTC day changes; p.mu must be held
+func (p *LLMJokeProvider) rollDay() {
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+	if day := now().UTC().Format(time.DateOnly); day != p.day {
+		p.day = day
+		p.used = 0
+	}
+}
+
+// Function to return the tokens each completion may use
+func (p *LLMJokeProvider) maxTokens() int {
+	if p.MaxTokens > 0 {
+		return p.MaxTokens
+	}
+	return DefaultLLMMaxTokens
+}
+
+// Function to trim whitespace and the quotes models like to wrap answers in
+func cleanCompletion(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
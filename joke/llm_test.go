@@ -0,0 +1,156 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Function to return a mock chat completions API answering content, using tokens per call
+func mockLLM(t *testing.T, content string, tokens int, requests *[]chatRequest) string {
+	t.Helper()
+	upstream := mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if requests != nil {
+			*requests = append(*requests, req)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": content}}},
+			"usage":   map[string]any{"total_tokens": tokens},
+		})
+	})
+	return upstream.URL
+}
+
+func TestLLMJokeProvider(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Generates a joke from the prompt", func(t *testing.T) {
+		var requests []chatRequest
+		p := &LLMJokeProvider{
+			Endpoint:  mockLLM(t, ` "Ada Lovelace can divide by zero." `, 30, &requests),
+			APIKey:    "key",
+			Model:     "tiny",
+			Prompt:    "A joke about {{.FirstName}} {{.LastName}}",
+			MaxTokens: 50,
+		}
+
+		got, err := p.Joke(context.Background(), "Ada", "Lovelace")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if got != "Ada Lovelace can divide by zero." {
+			t.Errorf("Expected the trimmed, unquoted completion; got %q", got)
+		}
+		if len(requests) != 1 {
+			t.Fatalf("Expected one request; got %d", len(requests))
+		}
+		req := requests[0]
+		if req.Model != "tiny" || req.MaxTokens != 50 || req.Messages[0].Content != "A joke about Ada Lovelace" {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+	})
+
+	t.Run("Uses defaults", func(t *testing.T) {
+		var requests []chatRequest
+		p := &LLMJokeProvider{Endpoint: mockLLM(t, "joke", 1, &requests), APIKey: "key"}
+		p.Joke(context.Background(), "Ada", "Lovelace")
+
+		req := requests[0]
+		if req.Model != DefaultLLMModel || req.MaxTokens != DefaultLLMMaxTokens {
+			t.Errorf("Expected the default model and token cap; got %+v", req)
+		}
+		if !strings.Contains(req.Messages[0].Content, "Ada Lovelace") {
+			t.Errorf("Expected the default prompt to name Ada Lovelace; got %q", req.Messages[0].Content)
+		}
+	})
+
+	t.Run("Prompts with the persona", func(t *testing.T) {
+		var requests []chatRequest
+		p := &LLMJokeProvider{Endpoint: mockLLM(t, "joke", 1, &requests), APIKey: "key"}
+		ctx := WithPersona(context.Background(), Persona{Nickname: "Countess", Title: "Dr.", Pronouns: She})
+		p.Joke(ctx, "Ada", "Lovelace")
+
+		prompt := requests[0].Messages[0].Content
+		for _, want := range []string{"Dr. Ada Lovelace", `"Countess"`, "she/her"} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("Expected the prompt to contain %q; got %q", want, prompt)
+			}
+		}
+	})
+
+	t.Run("Stops at the daily token budget", func(t *testing.T) {
+		var requests []chatRequest
+		now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		p := &LLMJokeProvider{Endpoint: mockLLM(t, "joke", 60, &requests), APIKey: "key", DailyTokens: 100}
+		p.now = func() time.Time { return now }
+
+		for i := 0; i < 2; i++ {
+			if _, err := p.Joke(context.Background(), "Ada", "Lovelace"); err != nil {
+				t.Fatalf("Call %d: expected no error; got %v", i+1, err)
+			}
+		}
+		_, err := p.Joke(context.Background(), "Ada", "Lovelace")
+		if !errors.Is(err, ErrBudget) || !errors.Is(err, ErrJokeUpstream) {
+			t.Errorf("Expected ErrBudget and ErrJokeUpstream; got %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected the API not to be called over budget; got %d calls", len(requests))
+		}
+
+		// The budget resets with the UTC day
+		now = now.Add(12 * time.Hour)
+		if _, err := p.Joke(context.Background(), "Ada", "Lovelace"); err != nil {
+			t.Errorf("Expected a new day's budget; got %v", err)
+		}
+	})
+
+	t.Run("Stops at the rate limit", func(t *testing.T) {
+		p := &LLMJokeProvider{Endpoint: mockLLM(t, "joke", 1, nil), APIKey: "key", Limiter: rate.NewLimiter(rate.Every(time.Hour), 1)}
+
+		if _, err := p.Joke(context.Background(), "Ada", "Lovelace"); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if _, err := p.Joke(context.Background(), "Ada", "Lovelace"); !errors.Is(err, ErrBudget) {
+			t.Errorf("Expected ErrBudget; got %v", err)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tests := []struct {
+			name string
+			p    *LLMJokeProvider
+			want error
+		}{
+			{"Unsuccessful status code", &LLMJokeProvider{Endpoint: mockLLM(t, "joke", 1, nil), APIKey: "wrong"}, ErrStatus},
+			{"Empty completion", &LLMJokeProvider{Endpoint: mockLLM(t, "  ", 1, nil), APIKey: "key"}, ErrDecode},
+			{"Not a completion", &LLMJokeProvider{Endpoint: mockUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"choices": []}`))
+			}).URL}, ErrDecode},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := tt.p.Joke(context.Background(), "Ada", "Lovelace")
+				if !errors.Is(err, ErrJokeUpstream) || !errors.Is(err, tt.want) {
+					t.Errorf("Expected ErrJokeUpstream and %v; got %v", tt.want, err)
+				}
+			})
+		}
+
+		p := &LLMJokeProvider{Prompt: "{{.Nope}}"}
+		if _, err := p.Joke(context.Background(), "Ada", "Lovelace"); err == nil || !strings.Contains(err.Error(), "prompt") {
+			t.Errorf("Expected a prompt error; got %v", err)
+		}
+	})
+}
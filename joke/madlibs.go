@@ -0,0 +1,260 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+/*
+	 MadLibsConfig holds joke templates and the word lists their
+	 placeholders are filled from
+
+		Templates are text/templates; {{.Name}}, {{.FirstName}},
+		{{.LastName}} and the Persona fields {{.Nickname}}, {{.Title}},
+		{{.They}}, {{.Them}} and {{.Their}} describe the person, and
+		any other placeholder, e.g. {{.Language}}, is a random word
+		from the list of that name. {{capitalize .They}} starts a
+		sentence.
+*/
+type MadLibsConfig struct {
+	Templates []string `json:"templates"`
+	// Pairs are templates for jokes about two people, used when a
+	// second person is given; {{.Name2}}, {{.FirstName2}} and
+	// {{.LastName2}} are the second person
+	Pairs []string            `json:"pairs,omitempty"`
+	Words map[string][]string `json:"words"`
+}
+
+/*
+	 MadLibs is a JokeProvider filling a random template with the name
+	 and random words, for far more variety than name substitution
+
+		Build one with NewMadLibs or LoadMadLibs. Safe for concurrent
+		use.
+*/
+type MadLibs struct {
+	templates []*template.Template
+	pairs     []*template.Template
+	words     map[string][]string
+
+	// Returns a random int in [0, n), replaced in tests
+	random func(n int) int
+}
+
+/*
+	 LoadMadLibs reads a MadLibs from a JSON file such as:
+
+		{"templates": ["{{.Name}} rewrote {{.Tool}} in {{.Language}} over lunch."],
+		 "words": {"Language": ["Go", "Rust"], "Tool": ["vim", "make"]}}
+*/
+func LoadMadLibs(path string) (*MadLibs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("madlibs: could not read %s: %w", path, err)
+	}
+	var cfg MadLibsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("madlibs: could not parse %s: %w", path, err)
+	}
+	return NewMadLibs(cfg)
+}
+
+/*
+	 NewMadLibs returns a MadLibs for cfg
+
+		Checks every template parses and uses only the people's fields
+		and the word lists, so a typo fails at startup rather than per
+		joke.
+*/
+func NewMadLibs(cfg MadLibsConfig) (*MadLibs, error) {
+	if len(cfg.Templates) == 0 {
+		return nil, fmt.Errorf("madlibs: no templates")
+	}
+	m := &MadLibs{words: make(map[string][]string), random: rand.IntN}
+	reserved := personaFields(context.Background(), "", "")
+	for list, words := range cfg.Words {
+		if _, ok := reserved[list]; ok {
+			return nil, fmt.Errorf("madlibs: word list %q is reserved for the person", list)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("madlibs: word list %q is empty", list)
+		}
+		m.words[list] = words
+	}
+
+	// Sample data holding every placeholder, to catch unknown ones
+	sample := m.fill(WithSecond(context.Background(), Names{FirstName: "First2", LastName: "Last2"}), "First", "Last")
+	var err error
+	if m.templates, err = m.parse("template", cfg.Templates, sample); err != nil {
+		return nil, err
+	}
+	if m.pairs, err = m.parse("pair", cfg.Pairs, sample); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Function to parse texts as templates named kind, checking each fills with sample
+func (m *MadLibs) parse(kind string, texts []string, sample map[string]string) ([]*template.Template, error) {
+	var parsed []*template.Template
+	for i, text := range texts {
+		tmpl, err := template.New(fmt.Sprintf("%s %d", kind, i+1)).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("madlibs: invalid %s %d: %w", kind, i+1, err)
+		}
+		if err := tmpl.Execute(new(strings.Builder), sample); err != nil {
+			return nil, fmt.Errorf("madlibs: %s %d uses a placeholder not in %s: %w", kind, i+1, strings.Join(m.placeholders(), ", "), err)
+		}
+		parsed = append(parsed, tmpl)
+	}
+	return parsed, nil
+}
+
+// Longest a filled submitted template may be, in bytes
+const maxFilledTemplate = 4096
+
+/*
+	 ValidateTemplate checks text is a joke template using only the
+	 people's placeholders, e.g. {{.FirstName}} or {{.They}}
+
+		Templates are submitted by users, so only text and plain
+		{{.Field}} actions are allowed: no functions, pipelines,
+		variables or control structures such as range or if. Templates
+		that don't mention the person at all are refused, as they can't
+		be personalized.
+*/
+func ValidateTemplate(text string) error {
+	_, err := parseSubmitted(text)
+	return err
+}
+
+// Function to parse text as a submitted template, checking it holds only text and plain person fields
+func parseSubmitted(text string) (*template.Template, error) {
+	tmpl, err := template.New("template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("madlibs: invalid template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("madlibs: template may not define templates")
+	}
+	fields := personaFields(context.Background(), "", "")
+	placeholders := 0
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			field, ok := plainField(node)
+			if !ok {
+				return nil, fmt.Errorf("madlibs: template may only use placeholders such as {{.FirstName}}, not %s", node)
+			}
+			if _, ok := fields[field]; !ok {
+				return nil, fmt.Errorf("madlibs: template uses {{.%s}}, which is not a placeholder for the person", field)
+			}
+			placeholders++
+		default:
+			return nil, fmt.Errorf("madlibs: template may only use placeholders such as {{.FirstName}}, not %s", node)
+		}
+	}
+	if placeholders == 0 {
+		return nil, fmt.Errorf("madlibs: template has no placeholder for the person, e.g. {{.FirstName}}")
+	}
+	return tmpl, nil
+}
+
+// Function to return the field an action prints when it is a plain {{.Field}}
+func plainField(node *parse.ActionNode) (string, bool) {
+	pipe := node.Pipe
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return "", false
+	}
+	return field.Ident[0], true
+}
+
+/*
+	 FillTemplate fills text, a template passing ValidateTemplate, with
+	 the name and the Persona in ctx
+
+		text is checked again, as it may have been stored before the
+		checks tightened, and the result is capped in size.
+*/
+func FillTemplate(ctx context.Context, text, firstName, lastName string) (string, error) {
+	tmpl, err := parseSubmitted(text)
+	if err != nil {
+		return "", err
+	}
+	b := &limitedBuilder{max: maxFilledTemplate}
+	if err := tmpl.Execute(b, personaFields(ctx, firstName, lastName)); err != nil {
+		return "", fmt.Errorf("madlibs: could not fill template: %w", err)
+	}
+	return b.String(), nil
+}
+
+/*
+	 Joke returns a random template filled with the name, the Persona
+	 in ctx and random words
+
+		With a second person in ctx a pair template is used, if there
+		are any.
+*/
+func (m *MadLibs) Joke(ctx context.Context, firstName, lastName string) (string, error) {
+	templates := m.templates
+	if _, ok := SecondFrom(ctx); ok && len(m.pairs) > 0 {
+		templates = m.pairs
+	}
+	tmpl := templates[m.random(len(templates))]
+	var b strings.Builder
+	if err := tmpl.Execute(&b, m.fill(ctx, firstName, lastName)); err != nil {
+		return "", fmt.Errorf("madlibs: could not fill %s: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// Function to return the template data: the person, and a random word from each list
+func (m *MadLibs) fill(ctx context.Context, firstName, lastName string) map[string]string {
+	data := personaFields(ctx, firstName, lastName)
+	for list, words := range m.words {
+		data[list] = words[m.random(len(words))]
+	}
+	return data
+}
+
+// Function to return the placeholders templates may use, sorted, for error messages
+func (m *MadLibs) placeholders() []string {
+	var names []string
+	for field := range personaFields(context.Background(), "", "") {
+		names = append(names, field)
+	}
+	for list := range m.words {
+		names = append(names, list)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// limitedBuilder builds a string, failing writes past max bytes
+type limitedBuilder struct {
+	b   strings.Builder
+	max int
+}
+
+func (l *limitedBuilder) Write(p []byte) (int, error) {
+	if l.b.Len()+len(p) > l.max {
+		return 0, fmt.Errorf("filled template is longer than %d bytes", l.max)
+	}
+	return l.b.Write(p)
+}
+
+func (l *limitedBuilder) String() string {
+	return l.b.String()
+}
@@ -0,0 +1,156 @@
+package joke
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMadLibs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Fills placeholders from the name and word lists", func(t *testing.T) {
+		m, err := NewMadLibs(MadLibsConfig{
+			Templates: []string{"{{.Name}} rewrote {{.Tool}} in {{.Language}}.", "unused"},
+			Words:     map[string][]string{"Language": {"Go", "Rust"}, "Tool": {"vim", "make"}},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		// Always pick the last option
+		m.random = func(n int) int { return n - 1 }
+		m.templates = m.templates[:1]
+
+		got, err := m.Joke(context.Background(), "Ada", "Lovelace")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if want := "Ada Lovelace rewrote make in Rust."; got != want {
+			t.Errorf("Expected %q; got %q", want, got)
+		}
+	})
+
+	t.Run("Varies between calls", func(t *testing.T) {
+		m, _ := LoadMadLibs(filepath.Join("testdata", "madlibs.json"))
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			j, _ := m.Joke(context.Background(), "Ada", "Lovelace")
+			seen[j] = true
+		}
+		if len(seen) < 3 {
+			t.Errorf("Expected varied jokes; got %d distinct", len(seen))
+		}
+	})
+
+	t.Run("Rejects invalid configs", func(t *testing.T) {
+		tests := []struct {
+			name string
+			cfg  MadLibsConfig
+			want string
+		}{
+			{"No templates", MadLibsConfig{}, "no templates"},
+			{"Unknown placeholder", MadLibsConfig{Templates: []string{"{{.Editor}}"}, Words: map[string][]string{"Tool": {"vim"}}}, "FirstName, FirstName2, LastName, LastName2, Name, Name2, Nickname, Pronouns, Their, Them, They, Title, Tool"},
+			{"Invalid template", MadLibsConfig{Templates: []string{"{{.Name"}}, "invalid template 1"},
+			{"Empty list", MadLibsConfig{Templates: []string{"x"}, Words: map[string][]string{"Tool": nil}}, "empty"},
+			{"Reserved list", MadLibsConfig{Templates: []string{"x"}, Words: map[string][]string{"Name": {"x"}}}, "reserved"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := NewMadLibs(tt.cfg)
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Expected an error containing %q; got %v", tt.want, err)
+				}
+			})
+		}
+	})
+
+	t.Run("Fills the persona", func(t *testing.T) {
+		m, _ := NewMadLibs(MadLibsConfig{Templates: []string{"{{.Name}} aka {{.Nickname}}. {{capitalize .They}} fixed {{.Their}} build."}})
+		ctx := WithPersona(context.Background(), Persona{Nickname: "Countess", Title: "Dr.", Pronouns: She})
+
+		if got, _ := m.Joke(ctx, "Ada", "Lovelace"); got != "Dr. Ada Lovelace aka Countess. She fixed her build." {
+			t.Errorf("Unexpected joke: %q", got)
+		}
+		// Without a persona the fields fall back
+		if got, _ := m.Joke(context.Background(), "Ada", "Lovelace"); got != "Ada Lovelace aka Ada. They fixed their build." {
+			t.Errorf("Unexpected joke: %q", got)
+		}
+	})
+
+	t.Run("Uses pair templates for two people", func(t *testing.T) {
+		m, err := NewMadLibs(MadLibsConfig{
+			Templates: []string{"{{.Name}} alone."},
+			Pairs:     []string{"{{.Name}} and {{.FirstName2}} paired."},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		ctx := WithSecond(context.Background(), Names{FirstName: "Grace", LastName: "Hopper"})
+		if got, _ := m.Joke(ctx, "Ada", "Lovelace"); got != "Ada Lovelace and Grace paired." {
+			t.Errorf("Unexpected joke: %q", got)
+		}
+		if got, _ := m.Joke(context.Background(), "Ada", "Lovelace"); got != "Ada Lovelace alone." {
+			t.Errorf("Unexpected joke: %q", got)
+		}
+
+		// Without pair templates the second person is left out
+		single, _ := NewMadLibs(MadLibsConfig{Templates: []string{"{{.Name}} alone."}})
+		if got, _ := single.Joke(ctx, "Ada", "Lovelace"); got != "Ada Lovelace alone." {
+			t.Errorf("Unexpected joke: %q", got)
+		}
+
+		if _, err := NewMadLibs(MadLibsConfig{Templates: []string{"x"}, Pairs: []string{"{{.Name3}}"}}); err == nil || !strings.Contains(err.Error(), "pair 1") {
+			t.Errorf("Expected an error for pair 1; got %v", err)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		if _, err := LoadMadLibs(filepath.Join("testdata", "missing.json")); err == nil {
+			t.Error("Expected an error for a missing file")
+		}
+	})
+}
+
+func TestValidateTemplate(t *testing.T) {
+	t.Parallel()
+
+	for text, valid := range map[string]bool{
+		"{{.FirstName}} compiles in {{.Their}} head.": true,
+		"{{.They}} did it again, {{.Name2}}":          true,
+		"Nobody is in this joke.":                     false,
+		"{{.Language}} isn't a person's placeholder.": false,
+		"{{.FirstName":                               false,
+		"{{capitalize .They}} did it again.":         false,
+		"{{range 2000000000}}{{$.FirstName}}{{end}}": false,
+		"{{if .Name}}{{.FirstName}}{{end}}":          false,
+		"{{with .Name}}{{.}}{{end}}":                 false,
+		`{{define "x"}}{{.Name}}{{end}}{{.Name}}`:    false,
+		"{{.FirstName | printf \"%999999d\"}}":       false,
+		"{{$x := .Name}}{{$x}}":                      false,
+		"{{.FirstName.Len}}":                         false,
+	} {
+		if err := ValidateTemplate(text); (err == nil) != valid {
+			t.Errorf("%q: expected valid %v; got %v", text, valid, err)
+		}
+	}
+}
+
+func TestFillTemplate(t *testing.T) {
+	t.Parallel()
+
+	ctx := WithPersona(context.Background(), Persona{Title: "Dr."})
+	got, err := FillTemplate(ctx, "{{.Name}} checked {{.Their}} tests.", "Ada", "Lovelace")
+	if err != nil || got != "Dr. Ada Lovelace checked their tests." {
+		t.Errorf("Expected a filled template; got %q, %v", got, err)
+	}
+	if _, err := FillTemplate(ctx, "{{.Shoe}}", "Ada", "Lovelace"); err == nil {
+		t.Error("Expected an unknown placeholder to fail")
+	}
+	if _, err := FillTemplate(ctx, "{{range 2000000000}}{{$.FirstName}}{{end}}", "Ada", "Lovelace"); err == nil {
+		t.Error("Expected a range to fail")
+	}
+	if _, err := FillTemplate(ctx, strings.Repeat("{{.Name}}", 1000), "Ada", "Lovelace"); err == nil {
+		t.Error("Expected an oversized joke to fail")
+	}
+}
@@ -0,0 +1,105 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/jswanson806/joke-generator/render"
+)
+
+/*
+	 pageFormat offers a joke as an HTML page
+
+		The handler streams it: the shell, with a loading message, is
+		flushed before the joke is fetched, and the joke follows with
+		a style hiding the message, so slow upstreams show a page at
+		once and no script is needed.
+*/
+var pageFormat = render.Format{
+	Name:        "html",
+	ContentType: "text/html; charset=utf-8",
+	Encode: func(w io.Writer, v any) error {
+		res, ok := v.(jokeResponse)
+		if !ok {
+			return fmt.Errorf("joke: cannot write %T as a page", v)
+		}
+		if _, err := io.WriteString(w, pageShell); err != nil {
+			return err
+		}
+		return pageJoke.Execute(w, res)
+	},
+}
+
+// Start of the page, sent before the joke is ready
+const pageShell = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Joke Generator</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40rem;margin:4rem auto;padding:0 1rem;line-height:1.5}.error{color:#b00020}</style>
+</head>
+<body>
+<main>
+<p id="loading">Fetching a joke…</p>
+`
+
+// Rest of the page, once the joke or an error is ready
+var (
+	pageJoke = template.Must(template.New("joke").Parse(`<style>#loading{display:none}</style>
+<p id="joke">{{.Joke}}</p>
+</main>
+</body>
+</html>
+`))
+	pageError = template.Must(template.New("error").Parse(`<style>#loading{display:none}</style>
+<p id="joke" class="error">Could not get a joke: {{.}}</p>
+</main>
+</body>
+</html>
+`))
+)
+
+/*
+	 Function to stream the joke page
+
+		The status is sent with the shell, before the joke is fetched,
+		so failures are shown in the page rather than as an error
+		status.
+*/
+func (h *handler) servePage(ctx context.Context, w http.ResponseWriter, r *http.Request, names NameProvider) {
+	render.WriteHeader(w, http.StatusOK, pageFormat)
+	// Handle errors while writing; the client is gone
+	if _, err := io.WriteString(w, pageShell); err != nil {
+		return
+	}
+	// Writers that can't flush send the shell with the joke
+	http.NewResponseController(w).Flush()
+
+	res, err := h.fetch(ctx, r, names)
+	if err != nil {
+		if ClientGone(r.Context()) {
+			h.logger.InfoContext(r.Context(), "client went away before the joke was ready")
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "failed to build joke", "error", err)
+		msg := "failed to get joke"
+		var se *stageError
+		if errors.As(err, &se) {
+			msg = se.msg
+		}
+		if err := pageError.Execute(w, msg); err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+		}
+		return
+	}
+
+	// Handle errors while writing the joke
+	if err := pageJoke.Execute(w, h.brand(r, res)); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package joke
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	t.Parallel()
+
+	getRandomName := func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	}
+
+	// Function to request the page from a handler around the mocks
+	serve := func(rec *httptest.ResponseRecorder, jokes JokeProviderFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		NewHandler(Deps{Names: NameProviderFunc(getRandomName), Jokes: jokes}).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Flushes the shell before the joke", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		var flushedFirst bool
+		serve(rec, func(ctx context.Context, firstName, lastName string) (string, error) {
+			flushedFirst = rec.Flushed && strings.Contains(rec.Body.String(), `id="loading"`)
+			return "<b>" + firstName + "</b> & friends", nil
+		})
+		if !flushedFirst {
+			t.Error("Expected the shell to be flushed before the joke was fetched")
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("Expected an HTML page; got %q", ct)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, `<p id="joke">&lt;b&gt;John&lt;/b&gt; &amp; friends</p>`) {
+			t.Errorf("Expected the escaped joke; got %s", body)
+		}
+		if !strings.HasSuffix(body, "</html>\n") {
+			t.Errorf("Expected a complete page; got %s", body)
+		}
+	})
+
+	t.Run("Shows failures in the page", func(t *testing.T) {
+		rec := serve(httptest.NewRecorder(), func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", ErrJokeUpstream
+		})
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<p id="joke" class="error">Could not get a joke: failed to get joke</p>`) {
+			t.Errorf("Expected an error in the page; got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package joke
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Context key the second person is stored under
+type secondKey struct{}
+
+// WithSecond returns ctx carrying a second person for the joke, for the providers that support pairs
+func WithSecond(ctx context.Context, n Names) context.Context {
+	return context.WithValue(ctx, secondKey{}, n)
+}
+
+// SecondFrom returns the second person in ctx, if any
+func SecondFrom(ctx context.Context) (Names, bool) {
+	n, ok := ctx.Value(secondKey{}).(Names)
+	return n, ok
+}
+
+/*
+	 ParseNames reads the people a request names, nil when not given
+
+		?firstName and ?lastName name the person in the joke instead
+		of a random one; ?firstName2 and ?lastName2 add a second person
+		for a joke about the pair. Each person needs at least a first
+		name.
+*/
+func ParseNames(q url.Values) (first, second *Names, err error) {
+	if first, err = parseName(q, ""); err != nil {
+		return nil, nil, err
+	}
+	if second, err = parseName(q, "2"); err != nil {
+		return nil, nil, err
+	}
+	return first, second, nil
+}
+
+// Function to read ?firstName and ?lastName, each followed by suffix
+func parseName(q url.Values, suffix string) (*Names, error) {
+	n := Names{FirstName: q.Get("firstName" + suffix), LastName: q.Get("lastName" + suffix)}
+	if err := validateName("firstName"+suffix, n.FirstName); err != nil {
+		return nil, err
+	}
+	if err := validateName("lastName"+suffix, n.LastName); err != nil {
+		return nil, err
+	}
+	n = n.normalize()
+	if n.FirstName == "" && n.LastName == "" {
+		return nil, nil
+	}
+	if n.FirstName == "" {
+		return nil, fmt.Errorf("lastName%s needs firstName%s", suffix, suffix)
+	}
+	return &n, nil
+}
+
+/*
+	 Function to check that the value of the name parameter param is
+	 fit to send upstream
+
+		Names are at most maxPersonaLen characters of letters, with
+		the spaces, hyphens, apostrophes and periods names such as
+		"Mary-Jane O'Neil Jr." use. Anything else, from control
+		characters to the & and = of a query string, is refused.
+*/
+func validateName(param, v string) error {
+	if !utf8.ValidString(v) {
+		return fmt.Errorf("%s must be valid UTF-8", param)
+	}
+	// Count characters of the normalized name, so a combining accent isn't one
+	if utf8.RuneCountInString(normalizeName(v)) > maxPersonaLen {
+		return fmt.Errorf("%s must be at most %d characters", param, maxPersonaLen)
+	}
+	letters := 0
+	for _, r := range v {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsMark(r), r == ' ', r == '-', r == '\'', r == '’', r == '.':
+		case unicode.IsControl(r):
+			return fmt.Errorf("%s must not contain control characters", param)
+		default:
+			return fmt.Errorf("%s must not contain %q, only letters, spaces, hyphens, apostrophes and periods", param, r)
+		}
+	}
+	if strings.TrimSpace(v) != "" && letters == 0 {
+		return fmt.Errorf("%s must contain a letter", param)
+	}
+	return nil
+}
+
+/*
+	 Function to return the names and context a request's jokes are
+	 fetched with
+
+		A named first person replaces names; a second person is added
+		to ctx.
+*/
+func withPeople(ctx context.Context, names NameProvider, first, second *Names) (context.Context, NameProvider) {
+	if second != nil {
+		ctx = WithSecond(ctx, *second)
+	}
+	if first != nil {
+		n := *first
+		names = NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return n, nil
+		})
+	}
+	return ctx, names
+}
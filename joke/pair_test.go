@@ -0,0 +1,125 @@
+package joke
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query         string
+		first, second *Names
+		wantErr       bool
+	}{
+		{"", nil, nil, false},
+		{"firstName=Ada&lastName=Lovelace", &Names{"Ada", "Lovelace"}, nil, false},
+		{"firstName2=Grace", nil, &Names{FirstName: "Grace"}, false},
+		{"firstName=Ada&firstName2=+Grace+&lastName2=Hopper", &Names{FirstName: "Ada"}, &Names{"Grace", "Hopper"}, false},
+		{"lastName=Lovelace", nil, nil, true},
+		{"lastName2=Hopper", nil, nil, true},
+		{"firstName2=" + strings.Repeat("x", maxPersonaLen+1), nil, nil, true},
+		{"firstName=Mary-Jane&lastName=O%27Neil+Jr.", &Names{"Mary-Jane", "O'Neil Jr."}, nil, false},
+		{"firstName=Zoe%CC%88&lastName=van++der+Berg", &Names{"Zo\u00eb", "van der Berg"}, nil, false},
+		{"firstName=Ada%26limitTo%3Dnerdy", nil, nil, true},
+		{"firstName=Ada&lastName=Love%0Alace", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			first, second, err := ParseNames(q)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if !equalNames(first, tt.first) || !equalNames(second, tt.second) {
+				t.Errorf("Expected %v, %v; got %v, %v", tt.first, tt.second, first, second)
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"Ada", ""},
+		{"Zoë", ""},
+		{"Zoe\u0308", ""},
+		{"José María", ""},
+		{"Владимир", ""},
+		{"D’Angelo", ""},
+		{"St. John-Smith", ""},
+		{strings.Repeat("x", maxPersonaLen+1), "firstName must be at most 40 characters"},
+		{"Ada\x00", "firstName must not contain control characters"},
+		{"Ada\tLovelace", "firstName must not contain control characters"},
+		{"Ada&limitTo=[nerdy]", `firstName must not contain '&', only letters, spaces, hyphens, apostrophes and periods`},
+		{"<script>", `firstName must not contain '<', only letters, spaces, hyphens, apostrophes and periods`},
+		{"R2D2", `firstName must not contain '2', only letters, spaces, hyphens, apostrophes and periods`},
+		{"--", "firstName must contain a letter"},
+		{"Ada\xff", "firstName must be valid UTF-8"},
+		// Combining marks count as part of the letter they compose with
+		{strings.Repeat("e\u0301", maxPersonaLen), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := validateName("firstName", tt.value)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithPeople(t *testing.T) {
+	t.Parallel()
+
+	random := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{"Random", "Person"}, nil
+	})
+
+	ctx, names := withPeople(context.Background(), random, &Names{"Ada", "Lovelace"}, &Names{"Grace", "Hopper"})
+	if n, _ := names.Name(ctx); n.FirstName != "Ada" {
+		t.Errorf("Expected the named first person; got %v", n)
+	}
+	if n, ok := SecondFrom(ctx); !ok || n.FirstName != "Grace" {
+		t.Errorf("Expected the second person in ctx; got %v, %v", n, ok)
+	}
+	if f := personaFields(ctx, "Ada", "Lovelace"); f["Name2"] != "Grace Hopper" {
+		t.Errorf("Expected Name2 Grace Hopper; got %q", f["Name2"])
+	}
+
+	ctx, names = withPeople(context.Background(), random, nil, nil)
+	if n, _ := names.Name(ctx); n.FirstName != "Random" {
+		t.Errorf("Expected a random name; got %v", n)
+	}
+	if _, ok := SecondFrom(ctx); ok {
+		t.Error("Expected no second person")
+	}
+}
+
+// Function to compare optional names
+func equalNames(a, b *Names) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
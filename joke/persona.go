@@ -0,0 +1,154 @@
+package joke
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest nickname, title or pronoun accepted
+const maxPersonaLen = 40
+
+// Pronouns refer to the person in a joke, e.g. she, her, her
+type Pronouns struct {
+	Subject    string
+	Object     string
+	Possessive string
+}
+
+// String returns the pronouns as written, e.g. "she/her"
+func (p Pronouns) String() string {
+	return p.Subject + "/" + p.Object
+}
+
+// Pronouns recognized from their subject form; others must be given in full, e.g. xe/xem/xyr
+var (
+	They = Pronouns{"they", "them", "their"}
+	She  = Pronouns{"she", "her", "her"}
+	He   = Pronouns{"he", "him", "his"}
+)
+
+/*
+	 Persona personalizes a joke beyond the name, for the providers
+	 that support it
+
+		Every field is optional: without a nickname the first name is
+		used, without a title none is, and without pronouns They is.
+*/
+type Persona struct {
+	Nickname string
+	Title    string
+	Pronouns Pronouns
+}
+
+// Context key the Persona is stored under
+type personaKey struct{}
+
+// WithPersona returns ctx carrying p, for the providers serving a request
+func WithPersona(ctx context.Context, p Persona) context.Context {
+	return context.WithValue(ctx, personaKey{}, p)
+}
+
+// PersonaFrom returns the Persona in ctx, or the zero Persona
+func PersonaFrom(ctx context.Context) Persona {
+	p, _ := ctx.Value(personaKey{}).(Persona)
+	return p
+}
+
+/*
+	 ParsePersona reads the optional ?nickname, ?title and ?pronouns
+	 parameters of a request
+
+		Pronouns are she/her, he/him and they/them, or any set given in
+		full as subject/object/possessive, e.g. xe/xem/xyr.
+*/
+func ParsePersona(q url.Values) (Persona, error) {
+	p := Persona{
+		Nickname: strings.TrimSpace(q.Get("nickname")),
+		Title:    strings.TrimSpace(q.Get("title")),
+	}
+	if utf8.RuneCountInString(p.Nickname) > maxPersonaLen {
+		return Persona{}, fmt.Errorf("nickname must be at most %d characters", maxPersonaLen)
+	}
+	if utf8.RuneCountInString(p.Title) > maxPersonaLen {
+		return Persona{}, fmt.Errorf("title must be at most %d characters", maxPersonaLen)
+	}
+	if v := strings.TrimSpace(q.Get("pronouns")); v != "" {
+		pronouns, err := parsePronouns(v)
+		if err != nil {
+			return Persona{}, err
+		}
+		p.Pronouns = pronouns
+	}
+	return p, nil
+}
+
+// Function to parse pronouns given as subject/object or subject/object/possessive
+func parsePronouns(v string) (Pronouns, error) {
+	parts := strings.Split(strings.ToLower(v), "/")
+	for _, part := range parts {
+		if part == "" || utf8.RuneCountInString(part) > maxPersonaLen || strings.ContainsAny(part, " \t") {
+			return Pronouns{}, fmt.Errorf("invalid pronouns %q, want e.g. she/her or xe/xem/xyr", v)
+		}
+	}
+	if len(parts) == 3 {
+		return Pronouns{parts[0], parts[1], parts[2]}, nil
+	}
+	if len(parts) <= 2 {
+		for _, known := range []Pronouns{They, She, He} {
+			if parts[0] == known.Subject && (len(parts) == 1 || parts[1] == known.Object) {
+				return known, nil
+			}
+		}
+	}
+	return Pronouns{}, fmt.Errorf("invalid pronouns %q, give unfamiliar ones in full, e.g. xe/xem/xyr", v)
+}
+
+/*
+	 Function to return the fields templates are filled with for a
+	 name and the Persona in ctx, falling back where it is unset
+
+		Name includes the title, e.g. "Dr. Ada Lovelace"; Nickname
+		falls back to the first name; They, Them and Their to they,
+		them and their. Name2, FirstName2 and LastName2 are the second
+		person in ctx, empty without one.
+*/
+func personaFields(ctx context.Context, firstName, lastName string) map[string]string {
+	p := PersonaFrom(ctx)
+	pronouns := p.Pronouns
+	if pronouns.Subject == "" {
+		pronouns = They
+	}
+	fields := map[string]string{
+		"Name":      strings.TrimSpace(strings.Join([]string{p.Title, firstName, lastName}, " ")),
+		"FirstName": firstName,
+		"LastName":  lastName,
+		"Nickname":  orDefault(p.Nickname, firstName),
+		"Title":     p.Title,
+		"They":      pronouns.Subject,
+		"Them":      pronouns.Object,
+		"Their":     pronouns.Possessive,
+		"Pronouns":  pronouns.String(),
+	}
+	second, _ := SecondFrom(ctx)
+	fields["Name2"] = strings.TrimSpace(second.FirstName + " " + second.LastName)
+	fields["FirstName2"] = second.FirstName
+	fields["LastName2"] = second.LastName
+	return fields
+}
+
+// Functions available to joke templates, e.g. {{capitalize .They}} at the start of a sentence
+var templateFuncs = template.FuncMap{"capitalize": capitalize}
+
+// Function to upper-case the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,70 @@
+package joke
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParsePersona(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query   string
+		want    Persona
+		wantErr bool
+	}{
+		{"", Persona{}, false},
+		{"nickname=+Ace+&title=Dr.", Persona{Nickname: "Ace", Title: "Dr."}, false},
+		{"pronouns=she/her", Persona{Pronouns: She}, false},
+		{"pronouns=He", Persona{Pronouns: He}, false},
+		{"pronouns=they/them", Persona{Pronouns: They}, false},
+		{"pronouns=xe/xem/xyr", Persona{Pronouns: Pronouns{"xe", "xem", "xyr"}}, false},
+		{"pronouns=she/him", Persona{}, true},
+		{"pronouns=ze/zir", Persona{}, true},
+		{"pronouns=a//b", Persona{}, true},
+		{"pronouns=a/b/c/d", Persona{}, true},
+		{"nickname=" + strings.Repeat("x", maxPersonaLen+1), Persona{}, true},
+		{"title=" + strings.Repeat("x", maxPersonaLen+1), Persona{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			got, err := ParsePersona(q)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error; got %+v", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %+v; got %+v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestPersonaFields(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Falls back without a persona", func(t *testing.T) {
+		f := personaFields(context.Background(), "Ada", "Lovelace")
+		if f["Name"] != "Ada Lovelace" || f["Nickname"] != "Ada" || f["Title"] != "" || f["They"] != "they" || f["Pronouns"] != "they/them" {
+			t.Errorf("Unexpected fields: %v", f)
+		}
+	})
+
+	t.Run("Uses the persona", func(t *testing.T) {
+		ctx := WithPersona(context.Background(), Persona{Nickname: "Countess", Title: "Dr.", Pronouns: She})
+		f := personaFields(ctx, "Ada", "Lovelace")
+		if f["Name"] != "Dr. Ada Lovelace" || f["Nickname"] != "Countess" || f["Their"] != "her" {
+			t.Errorf("Unexpected fields: %v", f)
+		}
+	})
+
+	if got := capitalize("élan"); got != "Élan" {
+		t.Errorf("Expected Élan; got %q", got)
+	}
+}
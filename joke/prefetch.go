@@ -0,0 +1,91 @@
+package joke
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Delay before the prefetcher retries after a failed fetch, and how often
+// Wait checks the buffer
+const (
+	prefetchRetryDelay   = time.Second
+	prefetchPollInterval = 10 * time.Millisecond
+)
+
+/*
+	 NamePrefetcher is a NameProvider that keeps a buffer of names
+	 fetched ahead of time from another NameProvider.
+
+		Requests take a ready name from the buffer so only the joke
+		call sits on the request path, while Run refills the buffer
+		in the background. Without Run every name is fetched directly.
+*/
+type NamePrefetcher struct {
+	source NameProvider
+	names  chan Names
+	logger *slog.Logger
+}
+
+// NewNamePrefetcher returns a prefetcher buffering up to size names from source
+func NewNamePrefetcher(source NameProvider, size int, logger *slog.Logger) *NamePrefetcher {
+	return &NamePrefetcher{
+		source: source,
+		names:  make(chan Names, size),
+		logger: loggerOrDefault(logger),
+	}
+}
+
+// Run fills the buffer until ctx is cancelled
+func (p *NamePrefetcher) Run(ctx context.Context) {
+	for {
+		name, err := p.source.Name(ctx)
+		if err != nil {
+			p.logger.WarnContext(ctx, "prefetch: failed to get name", "error", err)
+			// Back off before retrying so a failing upstream isn't hammered
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(prefetchRetryDelay):
+			}
+			continue
+		}
+
+		// Block until there is room in the buffer
+		select {
+		case <-ctx.Done():
+			return
+		case p.names <- name:
+		}
+	}
+}
+
+// Name returns a buffered name, fetching one directly when the buffer is empty
+func (p *NamePrefetcher) Name(ctx context.Context) (Names, error) {
+	select {
+	case name := <-p.names:
+		return name, nil
+	default:
+		return p.source.Name(ctx)
+	}
+}
+
+/*
+	 Wait blocks until n names are buffered, or the buffer is full if
+	 it holds fewer, so Run must be running
+
+		It returns ctx's error if ctx ends first.
+*/
+func (p *NamePrefetcher) Wait(ctx context.Context, n int) error {
+	n = min(n, cap(p.names))
+	ticker := time.NewTicker(prefetchPollInterval)
+	defer ticker.Stop()
+	for len(p.names) < n {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
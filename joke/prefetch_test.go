@@ -0,0 +1,109 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNamePrefetcher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Run fills the buffer", func(t *testing.T) {
+		var calls atomic.Int32
+		source := NameProviderFunc(func(ctx context.Context) (Names, error) {
+			calls.Add(1)
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		p := NewNamePrefetcher(source, 2, nil)
+		done := make(chan struct{})
+		go func() {
+			p.Run(ctx)
+			close(done)
+		}()
+
+		// Wait for the buffer to fill
+		deadline := time.Now().Add(time.Second)
+		for len(p.names) < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if len(p.names) != 2 {
+			t.Fatalf("Expected 2 buffered names; got %d", len(p.names))
+		}
+
+		// Taking a buffered name must not call the upstream on the request path
+		before := calls.Load()
+		if _, err := p.Name(context.Background()); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		cancel()
+		<-done
+		if calls.Load() > before+1 {
+			t.Errorf("Expected at most one refill call; got %d", calls.Load()-before)
+		}
+	})
+
+	t.Run("Wait returns once names are buffered", func(t *testing.T) {
+		source := NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "John", LastName: "Doe"}, nil
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		p := NewNamePrefetcher(source, 3, nil)
+		go p.Run(ctx)
+
+		// Asking for more than the buffer holds waits for a full buffer
+		if err := p.Wait(ctx, 10); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if len(p.names) != 3 {
+			t.Errorf("Expected 3 buffered names; got %d", len(p.names))
+		}
+	})
+
+	t.Run("Wait gives up when ctx ends", func(t *testing.T) {
+		p := NewNamePrefetcher(NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{}, errors.New("down")
+		}), 2, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := p.Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded; got %v", err)
+		}
+	})
+
+	t.Run("Name fetches directly when empty", func(t *testing.T) {
+		source := NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{}, errors.New("upstream down")
+		})
+
+		p := NewNamePrefetcher(source, 2, nil)
+		if _, err := p.Name(context.Background()); err == nil {
+			t.Error("Expected error from direct fetch; got nil")
+		}
+	})
+
+	t.Run("Run stops on cancellation while retrying", func(t *testing.T) {
+		source := NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{}, errors.New("upstream down")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			NewNamePrefetcher(source, 2, nil).Run(ctx)
+			close(done)
+		}()
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Expected Run to return after cancellation")
+		}
+	})
+}
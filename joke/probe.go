@@ -0,0 +1,31 @@
+package joke
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jswanson806/joke-generator/render"
+)
+
+/*
+	 Probe builds one joke through names and jokes and renders it as
+	 JSON and as a page, the full path a request takes, returning the
+	 first error
+
+		Nothing is cached or recorded in history, so probes never
+		change what visitors are served.
+*/
+func Probe(ctx context.Context, names NameProvider, jokes JokeProvider) error {
+	name, text, err := Fetch(ctx, names, jokes)
+	if err != nil {
+		return err
+	}
+	res := jokeResponse{Joke: text, Category: DefaultCategory, FirstName: name.FirstName, LastName: name.LastName}
+	for _, f := range []render.Format{render.JSON, pageFormat} {
+		if err := f.Encode(io.Discard, res); err != nil {
+			return fmt.Errorf("joke: could not render %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	t.Parallel()
+
+	names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	})
+
+	t.Run("Succeeds through the full path", func(t *testing.T) {
+		var got string
+		jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			got = firstName + " " + lastName
+			return "probe joke <b>", nil
+		})
+		if err := Probe(context.Background(), names, jokes); err != nil {
+			t.Errorf("Expected no error; got %v", err)
+		}
+		if got != "John Doe" {
+			t.Errorf("Expected the joke to be personalized with the name; got %q", got)
+		}
+	})
+
+	t.Run("Reports the failing stage", func(t *testing.T) {
+		jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", errors.New("upstream down")
+		})
+		if err := Probe(context.Background(), names, jokes); err == nil {
+			t.Error("Expected an error; got nil")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package joke
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Longest the publisher waits for one joke
+const publishTimeout = 30 * time.Second
+
+// Published is a joke published by a Publisher
+type Published struct {
+	// ID counts the publisher's jokes from 1
+	ID          int64     `json:"id"`
+	Joke        string    `json:"joke"`
+	Category    string    `json:"category"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+/*
+	 Publisher publishes a joke on a schedule for every client to share
+
+		Run fetches a joke at once and then every interval; Next lets
+		clients wait for the next one. Failed fetches are retried at
+		the next tick, keeping the last joke.
+*/
+type Publisher struct {
+	names    NameProvider
+	jokes    JokeProvider
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	latest Published
+	// Closed, and replaced, when a joke is published
+	next  chan struct{}
+	hooks []func(Published)
+}
+
+// NewPublisher returns a Publisher of jokes from names and jokes every interval
+func NewPublisher(names NameProvider, jokes JokeProvider, interval time.Duration, logger *slog.Logger) *Publisher {
+	return &Publisher{
+		names:    names,
+		jokes:    jokes,
+		interval: interval,
+		logger:   loggerOrDefault(logger),
+		next:     make(chan struct{}),
+	}
+}
+
+// Run publishes jokes until ctx is cancelled
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.publishNext(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Function to fetch and publish a joke, logging failures
+func (p *Publisher) publishNext(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	name, text, err := Fetch(ctx, p.names, p.jokes)
+	// Handle a failed joke; keep the last one until the next tick
+	if err != nil {
+		p.logger.ErrorContext(ctx, "publish: failed to get joke", "error", err)
+		return
+	}
+	p.Publish(Published{Joke: text, Category: DefaultCategory, FirstName: name.FirstName, LastName: name.LastName})
+}
+
+/*
+	 OnPublish calls fn with every joke published from now on, e.g. to
+	 post it to a chat channel
+
+		fn is called after waiting clients are woken, and should not
+		block for long since the next joke waits for it.
+*/
+func (p *Publisher) OnPublish(fn func(Published)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks = append(p.hooks, fn)
+}
+
+// Publish publishes j, numbering and timestamping it, and wakes every waiting client
+func (p *Publisher) Publish(j Published) Published {
+	p.mu.Lock()
+	j.ID = p.latest.ID + 1
+	j.PublishedAt = time.Now()
+	p.latest = j
+	close(p.next)
+	p.next = make(chan struct{})
+	hooks := p.hooks
+	p.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(j)
+	}
+	return j
+}
+
+// Latest returns the last joke published, false before the first
+func (p *Publisher) Latest() (Published, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latest, p.latest.ID > 0
+}
+
+/*
+	 Next returns the latest joke if its ID is above after, otherwise
+	 waits for the next one
+
+		Clients pass the ID of the last joke they got, so none
+		published between their calls is missed; when several were,
+		they get the latest. IDs start again from 1 when the process
+		restarts, so an after past the latest ID is stale and gets the
+		latest joke at once. It returns ctx's error if ctx ends first.
+*/
+func (p *Publisher) Next(ctx context.Context, after int64) (Published, error) {
+	for {
+		p.mu.Lock()
+		latest, next := p.latest, p.next
+		p.mu.Unlock()
+		// Handle IDs from before a restart as well as new jokes
+		if latest.ID > after || (latest.ID > 0 && after > latest.ID) {
+			return latest, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Published{}, ctx.Err()
+		case <-next:
+		}
+	}
+}
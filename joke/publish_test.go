@@ -0,0 +1,104 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Publishes at once and on each tick", func(t *testing.T) {
+		names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+			return Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+		})
+		jokes := JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			return first + " " + last + " walks into a bar", nil
+		})
+		p := NewPublisher(names, jokes, 10*time.Millisecond, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.Run(ctx)
+
+		wait, done := context.WithTimeout(context.Background(), time.Second)
+		defer done()
+		j, err := p.Next(wait, 1)
+		if err != nil {
+			t.Fatalf("Expected a second joke; got %v", err)
+		}
+		if j.ID < 2 || j.Joke != "Ada Lovelace walks into a bar" || j.FirstName != "Ada" {
+			t.Errorf("Unexpected joke: %+v", j)
+		}
+	})
+
+	t.Run("Returns the latest joke past after at once", func(t *testing.T) {
+		p := NewPublisher(nil, nil, time.Hour, nil)
+		if _, ok := p.Latest(); ok {
+			t.Error("Expected no joke before the first is published")
+		}
+		p.Publish(Published{Joke: "first"})
+		p.Publish(Published{Joke: "second"})
+
+		j, err := p.Next(context.Background(), 0)
+		if err != nil || j.ID != 2 || j.Joke != "second" {
+			t.Errorf("Expected joke 2; got %+v, %v", j, err)
+		}
+	})
+
+	t.Run("Waits for the next joke", func(t *testing.T) {
+		p := NewPublisher(nil, nil, time.Hour, nil)
+		p.Publish(Published{Joke: "first"})
+
+		got := make(chan Published, 1)
+		go func() {
+			j, _ := p.Next(context.Background(), 1)
+			got <- j
+		}()
+		time.Sleep(10 * time.Millisecond)
+		p.Publish(Published{Joke: "second"})
+
+		select {
+		case j := <-got:
+			if j.ID != 2 {
+				t.Errorf("Expected joke 2; got %+v", j)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the waiting client to get the joke")
+		}
+	})
+
+	t.Run("Returns the latest joke for an ID from before a restart", func(t *testing.T) {
+		p := NewPublisher(nil, nil, time.Hour, nil)
+		p.Publish(Published{Joke: "first"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		j, err := p.Next(ctx, 41)
+		if err != nil || j.ID != 1 || j.Joke != "first" {
+			t.Errorf("Expected joke 1; got %+v, %v", j, err)
+		}
+	})
+
+	t.Run("Gives up when ctx ends", func(t *testing.T) {
+		p := NewPublisher(nil, nil, time.Hour, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := p.Next(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected DeadlineExceeded; got %v", err)
+		}
+	})
+
+	t.Run("Calls hooks with each published joke", func(t *testing.T) {
+		p := NewPublisher(nil, nil, time.Hour, nil)
+		var got []Published
+		p.OnPublish(func(j Published) { got = append(got, j) })
+		p.Publish(Published{Joke: "first"})
+		p.Publish(Published{Joke: "second"})
+
+		if len(got) != 2 || got[1].ID != 2 || got[1].Joke != "second" {
+			t.Errorf("Expected both jokes numbered; got %+v", got)
+		}
+	})
+}
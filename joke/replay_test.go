@@ -0,0 +1,37 @@
+package joke
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/vcr"
+)
+
+// Fixtures recorded from the default upstream endpoints
+const fixtureDir = "testdata/vcr"
+
+func TestHTTPProvidersReplay(t *testing.T) {
+	t.Parallel()
+
+	// Replay recorded upstream responses instead of calling the live services
+	client := &http.Client{Transport: vcr.New(vcr.Replay, fixtureDir, nil)}
+	names := &HTTPNameProvider{Client: client}
+	jokes := &HTTPJokeProvider{Client: client}
+
+	n, err := names.Name(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error getting name; got %v", err)
+	}
+	if n.FirstName != "Grace" || n.LastName != "Hopper" {
+		t.Errorf("Unexpected name: %+v", n)
+	}
+
+	text, err := jokes.Joke(context.Background(), n.FirstName, n.LastName)
+	if err != nil {
+		t.Fatalf("Expected no error getting joke; got %v", err)
+	}
+	if want := "Grace Hopper can write infinite recursion functions and have them return."; text != want {
+		t.Errorf("Expected %q; got %q", want, text)
+	}
+}
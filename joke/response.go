@@ -0,0 +1,92 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Machine-readable error codes included in error responses
+const (
+	codeTimeout       = "upstream_timeout"
+	codeDecode        = "upstream_decode_error"
+	codeNameUpstream  = "name_upstream_error"
+	codeJokeUpstream  = "joke_upstream_error"
+	codeInternalError = "internal_error"
+	codeCategory      = "category_not_allowed"
+	codeClientClosed  = "client_closed_request"
+)
+
+// StatusClientClosedRequest is the status, borrowed from nginx, recorded
+// for requests whose client went away before the joke was ready
+const StatusClientClosedRequest = 499
+
+// ClientGone reports whether the client of the request with ctx went away,
+// canceling it, rather than the request timing out
+func ClientGone(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// struct to hold the JSON body of an error response
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+/*
+	 Function to map an error to an HTTP status and error code
+
+		Cancellation, which only a client going away causes, takes
+		precedence over timeouts, which take precedence over decode
+		errors, which take precedence over the provider that failed.
+*/
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, codeClientClosed
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout, codeTimeout
+	case errors.Is(err, ErrDecode):
+		return http.StatusBadGateway, codeDecode
+	case errors.Is(err, ErrNameUpstream):
+		return http.StatusBadGateway, codeNameUpstream
+	case errors.Is(err, ErrJokeUpstream):
+		return http.StatusBadGateway, codeJokeUpstream
+	case errors.Is(err, ErrCategoryNotAllowed):
+		return http.StatusForbidden, codeCategory
+	default:
+		return http.StatusInternalServerError, codeInternalError
+	}
+}
+
+/*
+	 ErrorCode returns the machine-readable code served for err, e.g.
+	 "upstream_timeout"
+
+		For transports that report errors in their own format.
+*/
+func ErrorCode(err error) string {
+	_, code := errorStatus(err)
+	return code
+}
+
+// Function to write err as a JSON error response with the given message
+func writeError(w http.ResponseWriter, logger *slog.Logger, err error, message string) {
+	status, code := errorStatus(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	// Write the error body
+	if err := json.NewEncoder(w).Encode(errorResponse{Code: code, Message: message}); err != nil {
+		logger.Error("error writing error response", "error", err)
+	}
+}
+
+// WriteError writes err as the JSON error response the joke handlers serve, for routes built outside this package
+func WriteError(w http.ResponseWriter, logger *slog.Logger, err error, message string) {
+	writeError(w, logger, err, message)
+}
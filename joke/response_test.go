@@ -0,0 +1,66 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"name upstream", fmt.Errorf("%w: refused", ErrNameUpstream), http.StatusBadGateway, codeNameUpstream},
+		{"joke upstream", fmt.Errorf("%w: refused", ErrJokeUpstream), http.StatusBadGateway, codeJokeUpstream},
+		{"category not allowed", ErrCategoryNotAllowed, http.StatusForbidden, codeCategory},
+		{"timeout", fmt.Errorf("%w: %w", ErrJokeUpstream, ErrTimeout), http.StatusGatewayTimeout, codeTimeout},
+		{"decode", fmt.Errorf("%w: %w: bad json", ErrNameUpstream, ErrDecode), http.StatusBadGateway, codeDecode},
+		{"client gone", fmt.Errorf("%w: %w", ErrJokeUpstream, context.Canceled), StatusClientClosedRequest, codeClientClosed},
+		{"unclassified", errors.New("boom"), http.StatusInternalServerError, codeInternalError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := errorStatus(tt.err)
+			if status != tt.status || code != tt.code {
+				t.Errorf("Expected %d %q; got %d %q", tt.status, tt.code, status, code)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	// Mock the name provider to time out
+	names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{}, fmt.Errorf("%w: %w", ErrNameUpstream, ErrTimeout)
+	})
+	handler := NewHandler(Deps{Names: names})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Verify status code is 504
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status Gateway Timeout; got %v", rec.Code)
+	}
+
+	// Verify the body carries the machine-readable code
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if body.Code != codeTimeout {
+		t.Errorf("Expected code %q; got %q", codeTimeout, body.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package joke
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Member is a teammate on a Roster, picked Weight times as often as a member of weight 1
+type Member struct {
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	Weight    float64 `json:"weight,omitempty"`
+}
+
+/*
+	 Roster is a NameProvider picking members of a team at random, so
+	 jokes feature real people, e.g. on an office display
+
+		Build one with NewRoster or LoadRoster. Safe for concurrent
+		use.
+*/
+type Roster struct {
+	members []Member
+	// Running total of the weights, for picking by weight
+	cumulative []float64
+
+	// Returns a random float64 in [0, 1), replaced in tests
+	random func() float64
+}
+
+/*
+	 NewRoster returns a Roster of members
+
+		Members without a weight have weight 1; a weight of 0 is not
+		allowed, leave the member out instead.
+*/
+func NewRoster(members []Member) (*Roster, error) {
+	if len(members) == 0 {
+		return nil, errors.New("roster: no members")
+	}
+	r := &Roster{random: rand.Float64}
+	total := 0.0
+	for i, m := range members {
+		m.FirstName = strings.TrimSpace(m.FirstName)
+		m.LastName = strings.TrimSpace(m.LastName)
+		if m.FirstName == "" {
+			return nil, fmt.Errorf("roster: member %d has no first name", i+1)
+		}
+		if m.Weight == 0 {
+			m.Weight = 1
+		}
+		if m.Weight < 0 {
+			return nil, fmt.Errorf("roster: member %d (%s) has a negative weight", i+1, m.FirstName)
+		}
+		total += m.Weight
+		r.members = append(r.members, m)
+		r.cumulative = append(r.cumulative, total)
+	}
+	return r, nil
+}
+
+/*
+	 LoadRoster reads a Roster from a JSON or CSV file, by extension
+
+		JSON files hold a list of members:
+
+			[{"first_name": "Ada", "last_name": "Lovelace", "weight": 2}]
+
+		CSV files have a header of first_name, last_name and an
+		optional weight column.
+*/
+func LoadRoster(path string) (*Roster, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("roster: could not read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var members []Member
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&members)
+	case ".csv":
+		members, err = readRosterCSV(f)
+	default:
+		return nil, fmt.Errorf("roster: %s is not a .json or .csv file", path)
+	}
+	// Handle errors while parsing the file
+	if err != nil {
+		return nil, fmt.Errorf("roster: could not parse %s: %w", path, err)
+	}
+	return NewRoster(members)
+}
+
+// Function to read members from CSV with a first_name, last_name[, weight] header
+func readRosterCSV(r io.Reader) ([]Member, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	first, ok := cols["first_name"]
+	if !ok {
+		return nil, errors.New("header has no first_name column")
+	}
+	// Function to return the named column of record, "" when missing
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var members []Member
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return members, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		m := Member{FirstName: record[first], LastName: field(record, "last_name")}
+		if w := strings.TrimSpace(field(record, "weight")); w != "" {
+			if m.Weight, err = strconv.ParseFloat(w, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid weight %q", line, w)
+			}
+		}
+		members = append(members, m)
+	}
+}
+
+// Name returns a member picked at random by weight
+func (r *Roster) Name(ctx context.Context) (Names, error) {
+	total := r.cumulative[len(r.cumulative)-1]
+	target := r.random() * total
+	// First member whose running total passes the target
+	i := sort.Search(len(r.cumulative), func(i int) bool { return r.cumulative[i] > target })
+	if i == len(r.members) {
+		i--
+	}
+	m := r.members[i]
+	return Names{FirstName: m.FirstName, LastName: m.LastName}, nil
+}
@@ -0,0 +1,60 @@
+package joke
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoster(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Picks members by weight", func(t *testing.T) {
+		r, err := NewRoster([]Member{{FirstName: "Ada", LastName: "Lovelace", Weight: 3}, {FirstName: "Grace", LastName: "Hopper"}})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		// Ada covers [0, 0.75) of the range, Grace [0.75, 1)
+		for random, want := range map[float64]string{0: "Ada", 0.74: "Ada", 0.75: "Grace", 0.999: "Grace"} {
+			r.random = func() float64 { return random }
+			if n, _ := r.Name(context.Background()); n.FirstName != want {
+				t.Errorf("Expected %s at %v; got %s", want, random, n.FirstName)
+			}
+		}
+	})
+
+	t.Run("Loads JSON and CSV", func(t *testing.T) {
+		for _, file := range []string{"roster.json", "roster.csv"} {
+			r, err := LoadRoster(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("%s: expected no error; got %v", file, err)
+			}
+			if len(r.members) != 2 || r.members[0] != (Member{"Ada", "Lovelace", 3}) || r.members[1] != (Member{"Grace", "Hopper", 1}) {
+				t.Errorf("%s: unexpected members %+v", file, r.members)
+			}
+		}
+	})
+
+	t.Run("Rejects invalid rosters", func(t *testing.T) {
+		dir := t.TempDir()
+		tests := []struct {
+			file, content, want string
+		}{
+			{"empty.json", `[]`, "no members"},
+			{"nameless.json", `[{"last_name": "Hopper"}]`, "no first name"},
+			{"negative.json", `[{"first_name": "Ada", "weight": -1}]`, "negative weight"},
+			{"header.csv", "name\nAda\n", "no first_name column"},
+			{"weight.csv", "first_name,weight\nAda,lots\n", `line 2: invalid weight "lots"`},
+			{"roster.txt", "Ada", "not a .json or .csv"},
+		}
+		for _, tt := range tests {
+			path := filepath.Join(dir, tt.file)
+			os.WriteFile(path, []byte(tt.content), 0o644)
+			if _, err := LoadRoster(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: expected an error containing %q; got %v", tt.file, tt.want, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package joke
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Shadow calls allowed in flight at once when NewShadow is given no limit
+const DefaultShadowLimit = 16
+
+/*
+	 Shadow mirrors a share of joke calls to a candidate provider,
+	 to vet it on live traffic before it replaces the current one
+
+		A mirrored call is made in the background once the primary
+		call returns, with the same names, so its latency and errors
+		never reach the user. Only the primary's joke is served.
+*/
+type Shadow struct {
+	candidate JokeProvider
+	rate      float64
+	timeout   time.Duration
+	slots     chan struct{}
+	observe   func(d time.Duration, err error)
+	logger    *slog.Logger
+
+	// Source of random numbers in [0, 1), replaced in tests
+	random func() float64
+	// Called when a mirrored call is done, set in tests
+	done func()
+}
+
+/*
+	 NewShadow returns a Shadow mirroring a rate share of calls, between
+	 0 and 1, to candidate
+
+		Each mirrored call is bounded by timeout, defaulting to the
+		default connect and read timeouts together, and at most limit
+		run at once; calls beyond that aren't mirrored. observe, when
+		not nil, is called with each mirrored call's latency and error.
+		Both jokes are logged at info level for comparison.
+*/
+func NewShadow(candidate JokeProvider, rate float64, timeout time.Duration, limit int, observe func(d time.Duration, err error), logger *slog.Logger) *Shadow {
+	return &Shadow{
+		candidate: candidate,
+		rate:      rate,
+		timeout:   cmp.Or(timeout, DefaultConnectTimeout+DefaultReadTimeout),
+		slots:     make(chan struct{}, cmp.Or(limit, DefaultShadowLimit)),
+		observe:   observe,
+		logger:    loggerOrDefault(logger),
+		random:    rand.Float64,
+	}
+}
+
+// Jokes returns p wrapped so a share of its calls are mirrored to the candidate
+func (s *Shadow) Jokes(p JokeProvider) JokeProvider {
+	return JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		text, err := p.Joke(ctx, firstName, lastName)
+		if s.random() < s.rate {
+			s.mirror(ctx, firstName, lastName, text, err)
+		}
+		return text, err
+	})
+}
+
+// Function to call the candidate in the background, unless every slot is taken
+func (s *Shadow) mirror(ctx context.Context, firstName, lastName, primary string, primaryErr error) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.logger.DebugContext(ctx, "shadow: skipping call, limit reached")
+		return
+	}
+
+	// Outlive the request, which is answered without waiting
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+	go func() {
+		defer func() {
+			cancel()
+			<-s.slots
+			if s.done != nil {
+				s.done()
+			}
+		}()
+
+		start := time.Now()
+		text, err := s.candidate.Joke(ctx, firstName, lastName)
+		d := time.Since(start)
+		if s.observe != nil {
+			s.observe(d, err)
+		}
+
+		attrs := []any{"latency", d, "primary", primary, "candidate", text}
+		if primaryErr != nil {
+			attrs = append(attrs, "primary_error", primaryErr)
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		s.logger.InfoContext(ctx, "shadow: candidate joke", attrs...)
+	}()
+}
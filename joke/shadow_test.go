@@ -0,0 +1,97 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShadow(t *testing.T) {
+	t.Parallel()
+
+	primary := JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		return "primary joke about " + first, nil
+	})
+
+	t.Run("Mirrors sampled calls without changing the result", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			names    string
+			observed error
+		)
+		candidate := JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			mu.Lock()
+			names = first + " " + last
+			mu.Unlock()
+			return "", errors.New("candidate down")
+		})
+		done := make(chan struct{}, 1)
+		s := NewShadow(candidate, 0.5, time.Second, 1, func(d time.Duration, err error) {
+			mu.Lock()
+			observed = err
+			mu.Unlock()
+		}, nil)
+		s.random = func() float64 { return 0.2 }
+		s.done = func() { done <- struct{}{} }
+
+		// The request's context ends as soon as it is answered
+		ctx, cancel := context.WithCancel(context.Background())
+		text, err := s.Jokes(primary).Joke(ctx, "Chuck", "Norris")
+		cancel()
+		if err != nil || text != "primary joke about Chuck" {
+			t.Errorf("Expected the primary joke; got %q, %v", text, err)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the candidate to be called")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if names != "Chuck Norris" || observed == nil {
+			t.Errorf("Expected the candidate's error observed for the same names; got %q, %v", names, observed)
+		}
+	})
+
+	t.Run("Skips calls outside the rate", func(t *testing.T) {
+		candidate := JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			t.Error("Expected the candidate not to be called")
+			return "", nil
+		})
+		s := NewShadow(candidate, 0.5, time.Second, 1, nil, nil)
+		s.random = func() float64 { return 0.7 }
+
+		if _, err := s.Jokes(primary).Joke(context.Background(), "Chuck", "Norris"); err != nil {
+			t.Errorf("Expected no error; got %v", err)
+		}
+	})
+
+	t.Run("Skips calls over the limit", func(t *testing.T) {
+		release := make(chan struct{})
+		calls := make(chan struct{}, 2)
+		candidate := JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			calls <- struct{}{}
+			<-release
+			return "", nil
+		})
+		done := make(chan struct{}, 2)
+		s := NewShadow(candidate, 1, time.Second, 1, nil, nil)
+		s.done = func() { done <- struct{}{} }
+
+		jokes := s.Jokes(primary)
+		jokes.Joke(context.Background(), "Chuck", "Norris")
+		<-calls
+		jokes.Joke(context.Background(), "Chuck", "Norris")
+		close(release)
+		<-done
+
+		select {
+		case <-calls:
+			t.Error("Expected the second call not to be mirrored")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package joke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Seconds between jokes on a stream without ?interval=, and the range allowed
+const (
+	defaultStreamInterval = 30
+	minStreamInterval     = 5
+	maxStreamInterval     = 3600
+)
+
+// Longest a stream waits for one joke, and how often it proves it's
+// alive to proxies in between
+const (
+	streamFetchTimeout = 10 * time.Second
+	streamHeartbeat    = 15 * time.Second
+)
+
+// struct to hold a joke sent on a stream
+type streamJoke struct {
+	Joke      string `json:"joke"`
+	Setup     string `json:"setup,omitempty"`
+	Delivery  string `json:"delivery,omitempty"`
+	Category  string `json:"category"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Fallback  bool   `json:"fallback,omitempty"`
+}
+
+// struct to hold the joke handler serving event streams
+type streamHandler struct {
+	handler
+}
+
+/*
+	 NewStreamHandler returns an http.Handler serving a joke every
+	 ?interval= seconds, 5 to 3600 and 30 by default, as Server-Sent
+	 Events
+
+		The headers and a "loading" event are flushed before each joke
+		is fetched, then the joke is sent as a "joke" event, or an
+		"error" event when it fails and the stream carries on. The
+		query parameters of NewHandler choose the people and persona,
+		and ?category= only sends the jokes in that category, matched
+		case-insensitively on the server; jokes in others are dropped.
+		Categories the server never produces are refused with a 400.
+*/
+func NewStreamHandler(deps Deps) http.Handler {
+	return &streamHandler{handler{deps: deps, logger: loggerOrDefault(deps.Logger)}}
+}
+
+// ServeHTTP streams jokes until the client goes away
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	interval := defaultStreamInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Handle intervals that aren't a number in range
+		if err != nil || n < minStreamInterval || n > maxStreamInterval {
+			http.Error(w, fmt.Sprintf("interval must be between %d and %d seconds", minStreamInterval, maxStreamInterval), http.StatusBadRequest)
+			return
+		}
+		interval = n
+	}
+	category := r.URL.Query().Get("category")
+	// Every joke is in DefaultCategory, so a stream of any other would never send one
+	if category != "" && !strings.EqualFold(category, DefaultCategory) {
+		http.Error(w, "category "+category+" is not supported, jokes are all "+DefaultCategory, http.StatusBadRequest)
+		return
+	}
+	// Jokes carry no rating to filter on
+	if r.URL.Query().Has("minRating") {
+		http.Error(w, "minRating is not supported, jokes are not rated", http.StatusBadRequest)
+		return
+	}
+
+	t := tenant.FromContext(r.Context())
+	if t != nil && !t.Allows(DefaultCategory) {
+		writeError(w, h.logger, ErrCategoryNotAllowed, "category "+DefaultCategory+" is not allowed for this tenant")
+		return
+	}
+
+	ctx, names, err := h.personalize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Ask nginx not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for id := 1; ; id++ {
+		if err := h.send(ctx, w, r, names, category, id); err != nil {
+			return
+		}
+
+		next := time.NewTimer(time.Duration(interval) * time.Second)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				next.Stop()
+				return
+			case <-heartbeat.C:
+				// Handle errors while writing; the client is gone
+				if err := writeEvent(w, "", 0, nil); err != nil {
+					next.Stop()
+					return
+				}
+			case <-next.C:
+				break wait
+			}
+		}
+	}
+}
+
+/*
+	 Function to send a loading event, then the next joke or an error
+	 event, returning write errors
+
+		Jokes outside category, unless it's empty, aren't sent.
+*/
+func (h *streamHandler) send(ctx context.Context, w http.ResponseWriter, r *http.Request, names NameProvider, category string, id int) error {
+	if err := writeEvent(w, "loading", 0, struct{}{}); err != nil {
+		return err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, streamFetchTimeout)
+	defer cancel()
+	res, err := h.fetch(fetchCtx, r, names)
+	if err != nil {
+		if ClientGone(r.Context()) {
+			h.logger.InfoContext(r.Context(), "client went away before the joke was ready")
+			return err
+		}
+		h.logger.ErrorContext(r.Context(), "failed to build joke", "error", err)
+		msg := "failed to get joke"
+		var se *stageError
+		if errors.As(err, &se) {
+			msg = se.msg
+		}
+		_, code := errorStatus(err)
+		return writeEvent(w, "error", id, errorResponse{Code: code, Message: msg})
+	}
+
+	// Drop jokes the client didn't subscribe to
+	if category != "" && !strings.EqualFold(res.Category, category) {
+		h.logger.DebugContext(r.Context(), "dropped joke outside the stream's category", "category", res.Category)
+		return nil
+	}
+
+	res = h.brand(r, res).split()
+	return writeEvent(w, "joke", id, streamJoke{
+		Joke:      res.Joke,
+		Setup:     res.Setup,
+		Delivery:  res.Delivery,
+		Category:  res.Category,
+		FirstName: res.FirstName,
+		LastName:  res.LastName,
+		Fallback:  res.Fallback,
+	})
+}
+
+/*
+	 Function to write and flush one event
+
+		An id of 0 is left out, and a nil data writes a comment
+		keeping the connection alive instead of an event.
+*/
+func writeEvent(w http.ResponseWriter, event string, id int, data any) error {
+	var b []byte
+	if data == nil {
+		b = []byte(": keep-alive\n\n")
+	} else {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if id > 0 {
+			b = append(b, "id: "+strconv.Itoa(id)+"\n"...)
+		}
+		b = append(b, "event: "+event+"\ndata: "...)
+		b = append(append(b, payload...), "\n\n"...)
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	// Writers that can't flush send the events when the stream ends
+	http.NewResponseController(w).Flush()
+	return nil
+}
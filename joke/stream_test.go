@@ -0,0 +1,133 @@
+package joke
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Function to read the next event of a stream, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected an event; got %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	t.Parallel()
+
+	getRandomName := func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	}
+
+	// Function to open a stream from a handler around the mocks
+	open := func(t *testing.T, jokes JokeProviderFunc, query string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		srv := httptest.NewServer(NewStreamHandler(Deps{Names: NameProviderFunc(getRandomName), Jokes: jokes}))
+		t.Cleanup(srv.Close)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream"+query, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res, bufio.NewReader(res.Body)
+	}
+
+	t.Run("Sends loading then the joke", func(t *testing.T) {
+		release := make(chan struct{})
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			<-release
+			return "Joke about " + firstName, nil
+		}
+		res, body := open(t, jokes, "")
+		if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Expected an event stream; got %q", ct)
+		}
+
+		// The loading event arrives while the joke is still being fetched
+		if event, _ := readEvent(t, body); event != "loading" {
+			t.Errorf("Expected a loading event; got %q", event)
+		}
+		close(release)
+		event, data := readEvent(t, body)
+		if event != "joke" || data != `{"joke":"Joke about John","category":"nerdy","first_name":"John","last_name":"Doe"}` {
+			t.Errorf("Expected a joke event; got %q %s", event, data)
+		}
+	})
+
+	t.Run("Sends failures as error events", func(t *testing.T) {
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", errors.Join(ErrJokeUpstream, ErrTimeout)
+		}
+		_, body := open(t, jokes, "?interval=5")
+		readEvent(t, body)
+		event, data := readEvent(t, body)
+		if event != "error" || data != `{"code":"upstream_timeout","message":"failed to get joke"}` {
+			t.Errorf("Expected an error event; got %q %s", event, data)
+		}
+	})
+
+	t.Run("Rejects intervals out of range", func(t *testing.T) {
+		for _, interval := range []string{"1", "abc", "3601"} {
+			rec := httptest.NewRecorder()
+			NewStreamHandler(Deps{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream?interval="+interval, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s; got %d", interval, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Only sends jokes in the subscribed category", func(t *testing.T) {
+		jokes := func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "Joke about " + firstName, nil
+		}
+		h := &streamHandler{handler{deps: Deps{Jokes: JokeProviderFunc(jokes)}, logger: loggerOrDefault(nil)}}
+		for category, want := range map[string]bool{"": true, "NERDY": true, "puns": false} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+			if err := h.send(req.Context(), rec, req, NameProviderFunc(getRandomName), category, 1); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := strings.Contains(rec.Body.String(), "event: joke"); got != want {
+				t.Errorf("%q: expected joke sent %v; got %v", category, want, got)
+			}
+		}
+	})
+
+	t.Run("Rejects categories it never sends", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		NewStreamHandler(Deps{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream?category=dad", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Rejects rating filters", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		NewStreamHandler(Deps{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream?minRating=4", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400; got %d", rec.Code)
+		}
+	})
+}
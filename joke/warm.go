@@ -0,0 +1,52 @@
+package joke
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/jswanson806/joke-generator/cache"
+)
+
+// Most jokes fetched at once while warming up
+const warmWorkers = 4
+
+/*
+	 Warm fetches count jokes through names and jokes, as requests do,
+	 so the first requests find upstream connections already open
+
+		The last joke fetched is cached as the fallback when c is set.
+		It returns how many jokes were fetched and the last error, if
+		any failed.
+*/
+func Warm(ctx context.Context, names NameProvider, jokes JokeProvider, c cache.Cache, count int) (int, error) {
+	var (
+		mu      sync.Mutex
+		fetched int
+		lastErr error
+	)
+
+	var g errgroup.Group
+	g.SetLimit(warmWorkers)
+	for range count {
+		g.Go(func() error {
+			_, text, err := Fetch(ctx, names, jokes)
+
+			mu.Lock()
+			defer mu.Unlock()
+			// Handle a failed joke; keep warming with the rest
+			if err != nil {
+				lastErr = err
+				return nil
+			}
+			fetched++
+			if c != nil {
+				c.Set(FallbackKey, []byte(text), fallbackTTL)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+	return fetched, lastErr
+}
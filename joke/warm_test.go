@@ -0,0 +1,49 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/cache"
+)
+
+func TestWarm(t *testing.T) {
+	t.Parallel()
+
+	names := NameProviderFunc(func(ctx context.Context) (Names, error) {
+		return Names{FirstName: "John", LastName: "Doe"}, nil
+	})
+
+	t.Run("Fetches count jokes and caches the fallback", func(t *testing.T) {
+		var calls atomic.Int32
+		jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			calls.Add(1)
+			return "warm joke", nil
+		})
+		c := cache.NewMemory()
+
+		fetched, err := Warm(context.Background(), names, jokes, c, 5)
+		if err != nil || fetched != 5 {
+			t.Errorf("Expected 5 jokes and no error; got %d, %v", fetched, err)
+		}
+		if calls.Load() != 5 {
+			t.Errorf("Expected 5 joke calls; got %d", calls.Load())
+		}
+		if got, ok := c.Get(FallbackKey); !ok || string(got) != "warm joke" {
+			t.Errorf("Expected the fallback to be cached; got %q", got)
+		}
+	})
+
+	t.Run("Reports failures", func(t *testing.T) {
+		jokes := JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", ErrJokeUpstream
+		})
+
+		fetched, err := Warm(context.Background(), names, jokes, nil, 3)
+		if fetched != 0 || !errors.Is(err, ErrJokeUpstream) {
+			t.Errorf("Expected no jokes and ErrJokeUpstream; got %d, %v", fetched, err)
+		}
+	})
+}
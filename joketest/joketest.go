@@ -0,0 +1,168 @@
+// Package joketest provides fake providers for testing code that uses the
+// joke package without making real HTTP calls.
+package joketest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Name returned by FakeNameProvider when no responses are scripted
+var DefaultNames = joke.Names{FirstName: "John", LastName: "Doe"}
+
+// struct to hold one scripted name response
+type nameResponse struct {
+	names joke.Names
+	err   error
+}
+
+/*
+	 FakeNameProvider is a joke.NameProvider returning scripted responses
+
+		Responses queued with Return and Fail are returned in order;
+		the last one repeats once the queue is exhausted. Without any
+		scripted response DefaultNames is returned. The zero value is
+		ready to use and safe for concurrent use.
+*/
+type FakeNameProvider struct {
+	// Delay before each response; a cancelled context ends the wait early
+	Delay time.Duration
+
+	mu        sync.Mutex
+	responses []nameResponse
+	calls     int
+}
+
+// Return queues a successful response
+func (f *FakeNameProvider) Return(names joke.Names) *FakeNameProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, nameResponse{names: names})
+	return f
+}
+
+// Fail queues a response failing with err
+func (f *FakeNameProvider) Fail(err error) *FakeNameProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, nameResponse{err: err})
+	return f
+}
+
+// Name records the call and returns the next scripted response
+func (f *FakeNameProvider) Name(ctx context.Context) (joke.Names, error) {
+	f.mu.Lock()
+	i := f.calls
+	f.calls++
+	f.mu.Unlock()
+
+	if err := wait(ctx, f.Delay); err != nil {
+		return joke.Names{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return DefaultNames, nil
+	}
+	res := f.responses[min(i, len(f.responses)-1)]
+	return res.names, res.err
+}
+
+// Calls returns the number of times Name was called
+func (f *FakeNameProvider) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// JokeCall records the arguments of one FakeJokeProvider.Joke call
+type JokeCall struct {
+	FirstName string
+	LastName  string
+}
+
+// struct to hold one scripted joke response
+type jokeResponse struct {
+	joke string
+	err  error
+}
+
+/*
+	 FakeJokeProvider is a joke.JokeProvider returning scripted responses
+
+		Responses queued with Return and Fail are returned in order;
+		the last one repeats once the queue is exhausted. Without any
+		scripted response a joke about the requested name is returned.
+		The zero value is ready to use and safe for concurrent use.
+*/
+type FakeJokeProvider struct {
+	// Delay before each response; a cancelled context ends the wait early
+	Delay time.Duration
+
+	mu        sync.Mutex
+	responses []jokeResponse
+	calls     []JokeCall
+}
+
+// Return queues a successful response
+func (f *FakeJokeProvider) Return(joke string) *FakeJokeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, jokeResponse{joke: joke})
+	return f
+}
+
+// Fail queues a response failing with err
+func (f *FakeJokeProvider) Fail(err error) *FakeJokeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, jokeResponse{err: err})
+	return f
+}
+
+// Joke records the call and returns the next scripted response
+func (f *FakeJokeProvider) Joke(ctx context.Context, firstName, lastName string) (string, error) {
+	f.mu.Lock()
+	i := len(f.calls)
+	f.calls = append(f.calls, JokeCall{FirstName: firstName, LastName: lastName})
+	f.mu.Unlock()
+
+	if err := wait(ctx, f.Delay); err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return fmt.Sprintf("%s %s can divide by zero.", firstName, lastName), nil
+	}
+	res := f.responses[min(i, len(f.responses)-1)]
+	return res.joke, res.err
+}
+
+// Calls returns the arguments of every Joke call, in order
+func (f *FakeJokeProvider) Calls() []JokeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]JokeCall(nil), f.calls...)
+}
+
+// Function to wait for d, returning early with ctx's error if it is cancelled
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,84 @@
+package joketest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Compile-time checks that the fakes satisfy the provider interfaces
+var (
+	_ joke.NameProvider = (*FakeNameProvider)(nil)
+	_ joke.JokeProvider = (*FakeJokeProvider)(nil)
+)
+
+func TestFakeNameProvider(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Returns default without script", func(t *testing.T) {
+		f := &FakeNameProvider{}
+		names, err := f.Name(context.Background())
+		if err != nil || names != DefaultNames {
+			t.Errorf("Expected %+v; got %+v, %v", DefaultNames, names, err)
+		}
+	})
+
+	t.Run("Returns scripted responses in order then repeats the last", func(t *testing.T) {
+		errDown := errors.New("down")
+		f := (&FakeNameProvider{}).Fail(errDown).Return(joke.Names{FirstName: "Ada"})
+
+		if _, err := f.Name(context.Background()); !errors.Is(err, errDown) {
+			t.Errorf("Expected scripted error; got %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if names, _ := f.Name(context.Background()); names.FirstName != "Ada" {
+				t.Errorf("Expected Ada; got %+v", names)
+			}
+		}
+		if f.Calls() != 3 {
+			t.Errorf("Expected 3 calls; got %d", f.Calls())
+		}
+	})
+
+	t.Run("Delay honors cancellation", func(t *testing.T) {
+		f := &FakeNameProvider{Delay: time.Minute}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := f.Name(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled; got %v", err)
+		}
+	})
+}
+
+func TestFakeJokeProvider(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Personalizes default joke", func(t *testing.T) {
+		f := &FakeJokeProvider{}
+		text, err := f.Joke(context.Background(), "Ada", "Lovelace")
+		if err != nil || text != "Ada Lovelace can divide by zero." {
+			t.Errorf("Unexpected joke %q, %v", text, err)
+		}
+	})
+
+	t.Run("Records calls", func(t *testing.T) {
+		f := (&FakeJokeProvider{}).Return("scripted")
+		f.Joke(context.Background(), "Ada", "Lovelace")
+		f.Joke(context.Background(), "Alan", "Turing")
+
+		calls := f.Calls()
+		if len(calls) != 2 || calls[1] != (JokeCall{FirstName: "Alan", LastName: "Turing"}) {
+			t.Errorf("Unexpected calls: %+v", calls)
+		}
+	})
+
+	t.Run("Injects errors", func(t *testing.T) {
+		f := (&FakeJokeProvider{}).Fail(joke.ErrJokeUpstream)
+		if _, err := f.Joke(context.Background(), "Ada", "Lovelace"); !errors.Is(err, joke.ErrJokeUpstream) {
+			t.Errorf("Expected ErrJokeUpstream; got %v", err)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+/*
+	 Package mattermost answers Mattermost slash commands and outgoing
+	 webhooks
+
+		Both post the same fields, form-encoded or, for outgoing
+		webhooks, as JSON, and carry the token Mattermost generated
+		for the command or webhook. Both are answered with a Response.
+*/
+package mattermost
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Largest request body read, well above the fields Mattermost sends
+const maxBody = 64 << 10
+
+// Response types of a slash command's response
+const (
+	// InChannel responses are posted for the whole channel to see
+	InChannel = "in_channel"
+	// Ephemeral responses are only shown to the user who ran the command
+	Ephemeral = "ephemeral"
+)
+
+// ErrToken reports a request without a token of a configured command or webhook
+var ErrToken = errors.New("mattermost: invalid token")
+
+// Request is a slash command or outgoing webhook request
+type Request struct {
+	Token       string `json:"token"`
+	TeamDomain  string `json:"team_domain"`
+	ChannelName string `json:"channel_name"`
+	UserName    string `json:"user_name"`
+	// Command is the slash command run, e.g. /joke, empty for webhooks
+	Command string `json:"command"`
+	// TriggerWord is the word that fired an outgoing webhook, empty for commands
+	TriggerWord string `json:"trigger_word"`
+	// Text follows the command, or is the whole message for webhooks
+	Text string `json:"text"`
+}
+
+// Args returns the words after the command or trigger word
+func (r Request) Args() []string {
+	args := strings.Fields(r.Text)
+	if r.TriggerWord != "" && len(args) > 0 && strings.EqualFold(args[0], r.TriggerWord) {
+		args = args[1:]
+	}
+	return args
+}
+
+// Response answers a slash command or outgoing webhook
+type Response struct {
+	// ResponseType is InChannel or Ephemeral, ignored for webhooks
+	ResponseType string `json:"response_type,omitempty"`
+	Text         string `json:"text"`
+}
+
+/*
+	 Parse reads the Mattermost request r, checking its token is one of
+	 tokens
+
+		Errors other than ErrToken describe a malformed request.
+*/
+func Parse(w http.ResponseWriter, r *http.Request, tokens []string) (Request, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	var req Request
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return Request{}, fmt.Errorf("mattermost: could not decode request: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return Request{}, fmt.Errorf("mattermost: could not parse form: %w", err)
+		}
+		f := r.PostForm
+		req = Request{
+			Token:       f.Get("token"),
+			TeamDomain:  f.Get("team_domain"),
+			ChannelName: f.Get("channel_name"),
+			UserName:    f.Get("user_name"),
+			Command:     f.Get("command"),
+			TriggerWord: f.Get("trigger_word"),
+			Text:        f.Get("text"),
+		}
+	}
+	if !validToken(tokens, req.Token) {
+		return Request{}, ErrToken
+	}
+	return req, nil
+}
+
+// Function to report whether token is one of tokens, comparing in constant time
+func validToken(tokens []string, token string) bool {
+	ok := false
+	for _, t := range tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
@@ -0,0 +1,68 @@
+package mattermost
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tokens := []string{"command-token", "webhook-token"}
+
+	t.Run("Parses a slash command", func(t *testing.T) {
+		t.Parallel()
+		form := url.Values{"token": {"command-token"}, "command": {"/joke"}, "text": {"Ada Lovelace"}, "user_name": {"ada"}}
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		req, err := Parse(httptest.NewRecorder(), r, tokens)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if req.Command != "/joke" || req.UserName != "ada" || !reflect.DeepEqual(req.Args(), []string{"Ada", "Lovelace"}) {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+	})
+
+	t.Run("Parses a JSON outgoing webhook", func(t *testing.T) {
+		t.Parallel()
+		body := `{"token": "webhook-token", "trigger_word": "joke", "text": "JOKE  about Ada Lovelace"}`
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		req, err := Parse(httptest.NewRecorder(), r, tokens)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(req.Args(), []string{"about", "Ada", "Lovelace"}) {
+			t.Errorf("Expected the words after the trigger; got %q", req.Args())
+		}
+	})
+
+	t.Run("Rejects unknown tokens", func(t *testing.T) {
+		t.Parallel()
+		for _, token := range []string{"", "other"} {
+			form := url.Values{"token": {token}}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if _, err := Parse(httptest.NewRecorder(), r, tokens); !errors.Is(err, ErrToken) {
+				t.Errorf("%q: expected ErrToken; got %v", token, err)
+			}
+		}
+	})
+
+	t.Run("Rejects malformed JSON", func(t *testing.T) {
+		t.Parallel()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{"))
+		r.Header.Set("Content-Type", "application/json")
+		if _, err := Parse(httptest.NewRecorder(), r, tokens); err == nil || errors.Is(err, ErrToken) {
+			t.Errorf("Expected a decode error; got %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+/*
+	 Package meme draws jokes as PNG images sized for link previews,
+	 such as the og:image of a shared joke
+
+		Text is drawn in a built-in 5 by 7 pixel font scaled up, so no
+		font files are needed. It covers ASCII; common typographic
+		quotes and dashes are drawn as their ASCII look-alikes and
+		other characters as "?".
+*/
+package meme
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Size of images in pixels, the 1.91:1 link previews are cropped to
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// ContentType of images
+const ContentType = "image/png"
+
+// Margin around the joke, in pixels
+const margin = 80
+
+// Scales of the font tried for the joke, largest first, until it fits
+var scales = []int{10, 8, 7, 6, 5, 4, 3}
+
+// Colors of the image
+var (
+	brand  = color.RGBA{R: 41, G: 97, B: 189, A: 255}
+	yellow = color.RGBA{R: 255, G: 204, B: 51, A: 255}
+	white  = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	black  = color.RGBA{A: 255}
+)
+
+/*
+	 Render draws joke centred on a card with the logo, and caption,
+	 e.g. who the joke stars, along the bottom
+
+		The joke is drawn in the largest scale at which it fits,
+		breaking lines at spaces and at the line breaks in joke.
+*/
+func Render(joke, caption string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(brand), image.Point{}, draw.Src)
+
+	// Logo: a smiling face and the name
+	ellipse(img, margin+40, 84, 40, 40, yellow)
+	ellipse(img, margin+26, 70, 6, 6, black)
+	ellipse(img, margin+54, 70, 6, 6, black)
+	ellipse(img, margin+40, 98, 22, 14, black)
+	ellipse(img, margin+40, 91, 24, 13, yellow)
+	text(img, margin+104, 70, 5, white, "Joke Generator")
+
+	// Joke, in the largest scale whose lines fit between logo and caption
+	top, bottom, width := 160, Height-110, Width-2*margin
+	var lines []string
+	var scale int
+	for _, scale = range scales {
+		lines = wrap(joke, width/(6*scale))
+		if len(lines)*10*scale <= bottom-top {
+			break
+		}
+	}
+	y := top + (bottom-top-len(lines)*10*scale)/2
+	for _, line := range lines {
+		x := (Width - textWidth(line, scale)) / 2
+		// A drop shadow keeps the text legible, as memes do
+		text(img, x+max(scale/3, 1), y+max(scale/3, 1), scale, black, line)
+		text(img, x, y, scale, white, line)
+		y += 10 * scale
+	}
+
+	if caption != "" {
+		text(img, (Width-textWidth(caption, 4))/2, Height-70, 4, yellow, caption)
+	}
+	return img
+}
+
+// Write renders joke and caption, see Render, and writes them to w as a PNG
+func Write(w io.Writer, joke, caption string) error {
+	if err := png.Encode(w, Render(joke, caption)); err != nil {
+		return fmt.Errorf("meme: could not write image: %w", err)
+	}
+	return nil
+}
+
+// Function to draw s with its top left corner at x, y, each font pixel scale pixels square
+func text(img *image.RGBA, x, y, scale int, c color.RGBA, s string) {
+	src := image.NewUniform(c)
+	for _, r := range s {
+		g := glyph(r)
+		for col, bits := range g {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				px := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+				draw.Draw(img, px, src, image.Point{}, draw.Src)
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+// Function to return the width of s drawn at scale, without the spacing after its last character
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return (6*n - 1) * scale
+}
+
+// Function to fill the ellipse centred at cx, cy with radii rx and ry
+func ellipse(img *image.RGBA, cx, cy, rx, ry int, c color.RGBA) {
+	for y := cy - ry; y <= cy+ry; y++ {
+		for x := cx - rx; x <= cx+rx; x++ {
+			dx, dy := float64(x-cx)/float64(rx), float64(y-cy)/float64(ry)
+			if dx*dx+dy*dy <= 1 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+/*
+	 Function to break s into lines of at most n characters
+
+		Lines break at spaces and at the line breaks in s; a word
+		longer than n is split.
+*/
+func wrap(s string, n int) []string {
+	n = max(n, 1)
+	var lines []string
+	for _, para := range strings.Split(strings.TrimSpace(s), "\n") {
+		line := []rune{}
+		for _, word := range strings.Fields(para) {
+			w := []rune(word)
+			if len(line) > 0 && len(line)+1+len(w) > n {
+				lines = append(lines, string(line))
+				line = line[:0:0]
+			}
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+			line = append(line, w...)
+			for len(line) > n {
+				lines = append(lines, string(line[:n]))
+				line = line[n:]
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
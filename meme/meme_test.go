@@ -0,0 +1,84 @@
+package meme
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	if err := Write(&b, "Why did Ada cross the road?\nTo debug it.", "Starring Ada Lovelace"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("Expected a PNG; got %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != Width || size.Y != Height {
+		t.Errorf("Expected %dx%d; got %v", Width, Height, size)
+	}
+	if got := img.At(0, 0); got != brand {
+		t.Errorf("Expected the background to be %v; got %v", brand, got)
+	}
+
+	// The joke is drawn in white between the logo and the caption
+	drawn := 0
+	for y := 160; y < Height-110; y++ {
+		for x := 0; x < Width; x++ {
+			if img.At(x, y) == white {
+				drawn++
+			}
+		}
+	}
+	if drawn == 0 {
+		t.Errorf("Expected the joke to be drawn")
+	}
+}
+
+func TestRenderShrinksLongJokes(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("Ada can divide by zero. ", 40)
+	img := Render(long, "")
+	// The smallest scale still fits, leaving the bottom edge clear
+	for x := 0; x < Width; x++ {
+		if img.RGBAAt(x, Height-1) != brand {
+			t.Fatalf("Expected the bottom edge clear; got %v at %d", img.RGBAAt(x, Height-1), x)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		s    string
+		n    int
+		want []string
+	}{
+		{"Why did the chicken", 10, []string{"Why did", "the", "chicken"}},
+		{"One\nTwo three", 20, []string{"One", "Two three"}},
+		{"Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"Olé olé", 4, []string{"Olé", "olé"}},
+	} {
+		if got := wrap(tc.s, tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: expected %q; got %q", tc.s, tc.want, got)
+		}
+	}
+}
+
+func TestGlyph(t *testing.T) {
+	t.Parallel()
+
+	if glyph('’') != glyph('\'') || glyph('—') != glyph('-') {
+		t.Errorf("Expected typographic characters drawn as ASCII")
+	}
+	if glyph('é') != glyph('?') {
+		t.Errorf("Expected missing characters drawn as ?")
+	}
+}
@@ -0,0 +1,244 @@
+/*
+	 Package metering counts requests per API key and endpoint and
+	 exports them periodically for billing and chargeback
+
+		Meter.Middleware counts requests; Meter.Run hands the counts to
+		a Sink every interval as Records covering that window.
+*/
+package metering
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// DefaultInterval is how often usage is exported by default
+const DefaultInterval = time.Minute
+
+// Longest an export may take, so a hung sink can't hold up later exports or shutdown
+const exportTimeout = 30 * time.Second
+
+// Record is the usage of one key on one endpoint during a window
+type Record struct {
+	Key      string    `json:"key"`
+	Endpoint string    `json:"endpoint"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Requests uint64    `json:"requests"`
+	// Errors counts requests answered with a 5xx status
+	Errors uint64 `json:"errors"`
+}
+
+// Sink receives exported usage records
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+}
+
+// SinkFunc adapts a function to the Sink interface
+type SinkFunc func(ctx context.Context, records []Record) error
+
+// Write calls f
+func (f SinkFunc) Write(ctx context.Context, records []Record) error {
+	return f(ctx, records)
+}
+
+// struct to hold the key and endpoint usage is counted under
+type usageKey struct {
+	key      string
+	endpoint string
+}
+
+// struct to hold the counts for one usageKey
+type usage struct {
+	requests uint64
+	errors   uint64
+}
+
+/*
+	 Meter counts requests per key and endpoint
+
+		Safe for concurrent use. Build one with New.
+*/
+type Meter struct {
+	sink    Sink
+	logger  *slog.Logger
+	now     func() time.Time
+	timeout time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[usageKey]*usage
+}
+
+// New returns a Meter exporting to sink
+func New(sink Sink, logger *slog.Logger) *Meter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &Meter{sink: sink, logger: logger, now: time.Now, timeout: exportTimeout}
+	m.start = m.now().UTC()
+	m.counts = make(map[usageKey]*usage)
+	return m
+}
+
+/*
+	 Middleware counts every request carrying a key
+
+		key identifies the caller, returning "" for requests that are
+		not metered. Endpoints are the method and first path segment,
+		e.g. "GET /history", so IDs in paths don't multiply records.
+*/
+func (m *Meter) Middleware(key func(r *http.Request) string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if k == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			m.Add(k, Endpoint(r), rec.status >= 500)
+		})
+	}
+}
+
+// Add counts one request by key to endpoint
+func (m *Meter) Add(key, endpoint string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.counts[usageKey{key, endpoint}]
+	if !ok {
+		u = &usage{}
+		m.counts[usageKey{key, endpoint}] = u
+	}
+	u.requests++
+	if failed {
+		u.errors++
+	}
+}
+
+/*
+	 Export hands the usage counted since the last export to the sink
+
+		Counts are cleared first. If the sink fails they are added
+		back, so the next export retries them over a wider window.
+*/
+func (m *Meter) Export(ctx context.Context) error {
+	m.mu.Lock()
+	start, end, counts := m.start, m.now().UTC(), m.counts
+	m.start, m.counts = end, make(map[usageKey]*usage)
+	m.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+	records := make([]Record, 0, len(counts))
+	for k, u := range counts {
+		records = append(records, Record{
+			Key:      k.key,
+			Endpoint: k.endpoint,
+			Start:    start,
+			End:      end,
+			Requests: u.requests,
+			Errors:   u.errors,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Key != records[j].Key {
+			return records[i].Key < records[j].Key
+		}
+		return records[i].Endpoint < records[j].Endpoint
+	})
+
+	err := m.sink.Write(ctx, records)
+	// Handle errors while exporting; keep the counts for the next try
+	if err != nil {
+		m.mu.Lock()
+		m.start = start
+		for k, u := range counts {
+			if cur, ok := m.counts[k]; ok {
+				cur.requests += u.requests
+				cur.errors += u.errors
+			} else {
+				m.counts[k] = u
+			}
+		}
+		m.mu.Unlock()
+	}
+	return err
+}
+
+/*
+	 Run exports usage every interval until ctx is done, then exports
+	 once more
+
+		Each export is given up after 30 seconds; its usage is kept
+		for the next one.
+*/
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Export what is left; ctx is already canceled
+			m.export(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			m.export(ctx)
+		}
+	}
+}
+
+// Function to export under the export timeout, logging failures
+func (m *Meter) export(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	if err := m.Export(ctx); err != nil {
+		m.logger.Error("could not export usage", "error", err)
+	}
+}
+
+/*
+	 Endpoint returns the method and first path segment of r, e.g.
+	 "GET /history"
+
+		A tenant's /t/{id} prefix is skipped, so /t/acme/history is
+		also "GET /history".
+*/
+func Endpoint(r *http.Request) string {
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, tenant.PathPrefix); ok {
+		_, rest, _ = strings.Cut(rest, "/")
+		path = "/" + rest
+	}
+	if i := strings.IndexByte(strings.TrimPrefix(path, "/"), '/'); i >= 0 {
+		path = path[:i+1]
+	}
+	return r.Method + " " + path
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,133 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	t.Parallel()
+
+	// Function to return a meter whose exports are captured in got
+	newMeter := func(fail error) (*Meter, *[][]Record) {
+		var got [][]Record
+		m := New(SinkFunc(func(ctx context.Context, records []Record) error {
+			if fail != nil {
+				return fail
+			}
+			got = append(got, records)
+			return nil
+		}), nil)
+		return m, &got
+	}
+
+	t.Run("Counts requests per key and endpoint", func(t *testing.T) {
+		m, got := newMeter(nil)
+		handler := m.Middleware(func(r *http.Request) string { return r.Header.Get("X-API-Key") })(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/broken" {
+					w.WriteHeader(http.StatusBadGateway)
+				}
+			}))
+
+		for _, req := range []struct{ key, path string }{
+			{"a", "/"}, {"a", "/t/acme/"}, {"a", "/t/acme/admin/keys/123"}, {"b", "/broken"}, {"", "/"},
+		} {
+			r := httptest.NewRequest(http.MethodGet, req.path, nil)
+			r.Header.Set("X-API-Key", req.key)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		if err := m.Export(context.Background()); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		want := []Record{
+			{Key: "a", Endpoint: "GET /", Requests: 2},
+			{Key: "a", Endpoint: "GET /admin", Requests: 1},
+			{Key: "b", Endpoint: "GET /broken", Requests: 1, Errors: 1},
+		}
+		if len(*got) != 1 || len((*got)[0]) != len(want) {
+			t.Fatalf("Expected one export of %d records; got %+v", len(want), *got)
+		}
+		for i, r := range (*got)[0] {
+			if r.Key != want[i].Key || r.Endpoint != want[i].Endpoint || r.Requests != want[i].Requests || r.Errors != want[i].Errors {
+				t.Errorf("Record %d: expected %+v; got %+v", i, want[i], r)
+			}
+			if !r.End.After(r.Start) && !r.End.Equal(r.Start) {
+				t.Errorf("Record %d: window ends before it starts: %+v", i, r)
+			}
+		}
+	})
+
+	t.Run("Skips empty exports", func(t *testing.T) {
+		m, got := newMeter(nil)
+		m.Export(context.Background())
+		if len(*got) != 0 {
+			t.Errorf("Expected no export without usage; got %+v", *got)
+		}
+	})
+
+	t.Run("Keeps usage when the sink fails", func(t *testing.T) {
+		m, _ := newMeter(errors.New("sink down"))
+		start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		m.start = start
+		m.Add("a", "GET /", false)
+
+		if err := m.Export(context.Background()); err == nil {
+			t.Fatal("Expected sink error")
+		}
+		m.Add("a", "GET /", false)
+
+		// Retry into a working sink
+		var got []Record
+		m.sink = SinkFunc(func(ctx context.Context, records []Record) error {
+			got = records
+			return nil
+		})
+		m.Export(context.Background())
+		if len(got) != 1 || got[0].Requests != 2 || !got[0].Start.Equal(start) {
+			t.Errorf("Expected both requests since %v; got %+v", start, got)
+		}
+	})
+
+	t.Run("Run exports what is left when stopped", func(t *testing.T) {
+		m, got := newMeter(nil)
+		m.Add("a", "GET /", false)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.Run(ctx, time.Hour)
+			close(done)
+		}()
+		cancel()
+		<-done
+		if len(*got) != 1 || (*got)[0][0].Requests != 1 {
+			t.Errorf("Expected the request exported on stop; got %+v", *got)
+		}
+	})
+
+	t.Run("Run gives up on a hung sink", func(t *testing.T) {
+		m := New(SinkFunc(func(ctx context.Context, records []Record) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}), nil)
+		m.timeout = 10 * time.Millisecond
+		m.Add("a", "GET /", false)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.Run(ctx, time.Hour)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Run to return despite the hung sink")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jswanson806/joke-generator/s3"
+)
+
+/*
+	 NewSink returns the Sink described by dest
+
+		file:///var/log/usage.jsonl appends JSON lines to a file,
+		http:// and https:// URLs receive a POST of a JSON array, and
+		s3://bucket/prefix uploads one JSON lines object per export,
+		with credentials from the AWS environment variables.
+*/
+func NewSink(dest string) (Sink, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("metering: invalid sink %q: %w", dest, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return &FileSink{Path: u.Path}, nil
+	case "http", "https":
+		return &HTTPSink{URL: dest}, nil
+	case "s3":
+		return &S3Sink{Client: s3.New(s3.FromEnv(u.Host)), Prefix: strings.Trim(u.Path, "/")}, nil
+	}
+	return nil, fmt.Errorf("metering: invalid sink %q: scheme must be file, http, https or s3", dest)
+}
+
+// FileSink appends records to the file at Path as JSON lines
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Write appends records to the file, creating it if needed
+func (s *FileSink) Write(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("metering: could not open %s: %w", s.Path, err)
+	}
+	if _, err := f.Write(jsonLines(records)); err != nil {
+		f.Close()
+		return fmt.Errorf("metering: could not write %s: %w", s.Path, err)
+	}
+	return f.Close()
+}
+
+// HTTPSink POSTs records to URL as a JSON array
+type HTTPSink struct {
+	URL string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// Write POSTs records, failing unless the endpoint answers 2xx
+func (s *HTTPSink) Write(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("metering: could not encode records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("metering: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metering: could not post records: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("metering: could not post records: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+/*
+	 S3Sink uploads each export as a JSON lines object
+
+		Objects are named <Prefix>/<yyyy>/<mm>/<dd>/<end>.jsonl after
+		the end of the window they cover.
+*/
+type S3Sink struct {
+	Client *s3.Client
+	Prefix string
+}
+
+// Write uploads records as one object
+func (s *S3Sink) Write(ctx context.Context, records []Record) error {
+	end := records[0].End
+	key := end.Format("2006/01/02/20060102T150405Z") + ".jsonl"
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	return s.Client.Put(ctx, key, "application/x-ndjson", jsonLines(records))
+}
+
+// Function to encode records one JSON object per line
+func jsonLines(records []Record) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		// Records always encode; they hold only strings, times and numbers
+		enc.Encode(r)
+	}
+	return buf.Bytes()
+}
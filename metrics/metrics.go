@@ -0,0 +1,465 @@
+/*
+	 Package metrics records upstream latency and errors and exposes
+	 them in the Prometheus text format.
+
+		Wrap each provider with Registry.Names or Registry.Jokes, giving
+		it the upstream label shown on dashboards, and mount
+		Registry.Handler at /metrics.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Upper bounds in seconds of the upstream latency histogram buckets
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Results an upstream call is classified as
+const (
+	ResultOK       = "ok"
+	ResultTimeout  = "timeout"
+	ResultCanceled = "canceled"
+	ResultStatus   = "bad_status"
+	ResultDecode   = "decode_error"
+	ResultError    = "error"
+)
+
+/*
+	 Classify returns the result label for an error returned by a
+	 provider
+
+		Timeouts are checked before decode and status errors since an
+		error can match more than one sentinel.
+*/
+func Classify(err error) string {
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, joke.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ResultTimeout
+	case errors.Is(err, context.Canceled):
+		return ResultCanceled
+	case errors.Is(err, joke.ErrDecode):
+		return ResultDecode
+	case errors.Is(err, joke.ErrStatus):
+		return ResultStatus
+	default:
+		return ResultError
+	}
+}
+
+// struct to hold one upstream's latency histogram
+type histogram struct {
+	// counts[i] is the number of observations <= buckets[i], not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// struct to hold the ratings of one experiment bucket
+type rating struct {
+	count uint64
+	sum   uint64
+}
+
+// struct to hold the label pair of a request counter
+type requestKey struct {
+	upstream string
+	result   string
+}
+
+/*
+	 Forwarder receives every observation as the Registry records it,
+	 e.g. to push it to StatsD
+
+		Names are dotted, such as "upstream.requests", and tags are
+		key:value pairs.
+*/
+type Forwarder interface {
+	Count(name string, n int64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+}
+
+/*
+	 Registry holds upstream metrics
+
+		It is safe for concurrent use. Build one with NewRegistry.
+*/
+type Registry struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	latency   map[string]*histogram
+	queueWait map[string]*histogram
+	rejected  map[string]uint64
+	purged    map[string]uint64
+	// Self-check probe results, latency and last success
+	probes       map[string]uint64
+	probeLatency map[string]*histogram
+	probeSuccess time.Time
+	// Experiment calls by bucket and result, and ratings by bucket
+	experiments map[requestKey]uint64
+	ratings     map[string]*rating
+	// Content-safety verdicts, and jokes blocked by the blocklist
+	safety  map[string]uint64
+	blocked uint64
+
+	forwarders []Forwarder
+}
+
+// NewRegistry returns an empty Registry using DefaultBuckets
+func NewRegistry() *Registry {
+	return &Registry{
+		buckets:   DefaultBuckets,
+		requests:  make(map[requestKey]uint64),
+		latency:   make(map[string]*histogram),
+		queueWait: make(map[string]*histogram),
+		rejected:  make(map[string]uint64),
+		purged:    make(map[string]uint64),
+
+		probes:       make(map[string]uint64),
+		probeLatency: make(map[string]*histogram),
+
+		experiments: make(map[requestKey]uint64),
+		ratings:     make(map[string]*rating),
+		safety:      make(map[string]uint64),
+	}
+}
+
+/*
+	 Forward sends every observation recorded from now on to f as well
+
+		Register forwarders before the Registry is used.
+*/
+func (r *Registry) Forward(f Forwarder) {
+	r.forwarders = append(r.forwarders, f)
+}
+
+// ObserveUpstream records a call to upstream that took d and returned err
+func (r *Registry) ObserveUpstream(upstream string, d time.Duration, err error) {
+	seconds := d.Seconds()
+	result := Classify(err)
+	for _, f := range r.forwarders {
+		f.Count("upstream.requests", 1, "upstream:"+upstream, "result:"+result)
+		f.Timing("upstream.request_duration", d, "upstream:"+upstream)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[requestKey{upstream, result}]++
+	r.observe(r.latency, upstream, seconds)
+}
+
+/*
+	 ObserveQueue records a call that waited d for a slot of bulkhead,
+	 counting it as rejected when err is not nil
+
+		Its signature matches the observer of joke.NewBulkhead.
+*/
+func (r *Registry) ObserveQueue(bulkhead string, d time.Duration, err error) {
+	for _, f := range r.forwarders {
+		f.Timing("bulkhead.queue_wait", d, "bulkhead:"+bulkhead)
+		if err != nil {
+			f.Count("bulkhead.rejected", 1, "bulkhead:"+bulkhead)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.observe(r.queueWait, bulkhead, d.Seconds())
+	if err != nil {
+		r.rejected[bulkhead]++
+	} else if _, ok := r.rejected[bulkhead]; !ok {
+		// Keep the counter at zero from the first call, so rates start at 0
+		r.rejected[bulkhead] = 0
+	}
+}
+
+// ObservePurge records n records deleted from store by a retention purge
+func (r *Registry) ObservePurge(store string, n int) {
+	for _, f := range r.forwarders {
+		f.Count("retention.purged", int64(n), "store:"+store)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purged[store] += uint64(n)
+}
+
+// ObserveProbe records a self-check probe that took d and returned err
+func (r *Registry) ObserveProbe(d time.Duration, err error) {
+	result := Classify(err)
+	for _, f := range r.forwarders {
+		f.Count("selfcheck.probes", 1, "result:"+result)
+		f.Timing("selfcheck.duration", d)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probes[result]++
+	r.observe(r.probeLatency, "selfcheck", d.Seconds())
+	if err == nil {
+		r.probeSuccess = time.Now()
+	}
+}
+
+/*
+	 ObserveExperiment records a joke call for an experiment bucket
+	 that took d and returned err
+
+		Its signature matches experiment.Experiment's ObserveCall.
+*/
+func (r *Registry) ObserveExperiment(bucket string, d time.Duration, err error) {
+	result := Classify(err)
+	for _, f := range r.forwarders {
+		f.Count("experiment.requests", 1, "bucket:"+bucket, "result:"+result)
+		f.Timing("experiment.request_duration", d, "bucket:"+bucket)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.experiments[requestKey{bucket, result}]++
+}
+
+// ObserveRating records a rating of score given to a joke served from an experiment bucket
+func (r *Registry) ObserveRating(bucket string, score int) {
+	for _, f := range r.forwarders {
+		f.Count("experiment.ratings", 1, "bucket:"+bucket, "score:"+strconv.Itoa(score))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt, ok := r.ratings[bucket]
+	if !ok {
+		rt = &rating{}
+		r.ratings[bucket] = rt
+	}
+	rt.count++
+	rt.sum += uint64(score)
+}
+
+/*
+	 ObserveSafety records the verdict of a content-safety check
+
+		Its signature matches the observer of safety.NewFilter. Scores
+		are logged by the filter, not recorded.
+*/
+func (r *Registry) ObserveSafety(verdict string, score float64) {
+	for _, f := range r.forwarders {
+		f.Count("safety.checks", 1, "verdict:"+verdict)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.safety[verdict]++
+}
+
+// ObserveBlocked records a joke blocked by the blocklist
+func (r *Registry) ObserveBlocked() {
+	for _, f := range r.forwarders {
+		f.Count("blocklist.blocked", 1)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blocked++
+}
+
+// Function to add an observation of seconds to the histogram of label in hs, r.mu held
+func (r *Registry) observe(hs map[string]*histogram, label string, seconds float64) {
+	h, ok := hs[label]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		hs[label] = h
+	}
+	// Observations above the last bucket only count towards +Inf
+	if i := sort.SearchFloat64s(r.buckets, seconds); i < len(r.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Names returns p wrapped so every call is recorded under upstream
+func (r *Registry) Names(upstream string, p joke.NameProvider) joke.NameProvider {
+	return joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		start := time.Now()
+		n, err := p.Name(ctx)
+		r.ObserveUpstream(upstream, time.Since(start), err)
+		return n, err
+	})
+}
+
+// Jokes returns p wrapped so every call is recorded under upstream
+func (r *Registry) Jokes(upstream string, p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		start := time.Now()
+		text, err := p.Joke(ctx, firstName, lastName)
+		r.ObserveUpstream(upstream, time.Since(start), err)
+		return text, err
+	})
+}
+
+/*
+	 WriteText writes every metric to w in the Prometheus text format
+
+		Series are sorted by label so the output is stable.
+*/
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	// Request counters by upstream and result
+	keys := make([]requestKey, 0, len(r.requests))
+	for k := range r.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].upstream != keys[j].upstream {
+			return keys[i].upstream < keys[j].upstream
+		}
+		return keys[i].result < keys[j].result
+	})
+	b.WriteString("# HELP joke_upstream_requests_total Upstream calls by upstream and result.\n")
+	b.WriteString("# TYPE joke_upstream_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "joke_upstream_requests_total{upstream=%q,result=%q} %d\n", k.upstream, k.result, r.requests[k])
+	}
+
+	// Latency histograms by upstream
+	b.WriteString("# HELP joke_upstream_request_duration_seconds Latency of upstream calls.\n")
+	b.WriteString("# TYPE joke_upstream_request_duration_seconds histogram\n")
+	r.writeHistograms(&b, "joke_upstream_request_duration_seconds", "upstream", r.latency)
+
+	// Bulkhead queue waits and rejections by bulkhead
+	if len(r.queueWait) > 0 {
+		b.WriteString("# HELP joke_bulkhead_queue_wait_seconds Time upstream calls waited for a bulkhead slot.\n")
+		b.WriteString("# TYPE joke_bulkhead_queue_wait_seconds histogram\n")
+		r.writeHistograms(&b, "joke_bulkhead_queue_wait_seconds", "bulkhead", r.queueWait)
+
+		b.WriteString("# HELP joke_bulkhead_rejected_total Upstream calls whose context ended waiting for a bulkhead slot.\n")
+		b.WriteString("# TYPE joke_bulkhead_rejected_total counter\n")
+		for _, k := range sortedKeys(r.rejected) {
+			fmt.Fprintf(&b, "joke_bulkhead_rejected_total{bulkhead=%q} %d\n", k, r.rejected[k])
+		}
+	}
+
+	// Records deleted by retention purges by store
+	if len(r.purged) > 0 {
+		b.WriteString("# HELP joke_retention_purged_total Records deleted by retention purges.\n")
+		b.WriteString("# TYPE joke_retention_purged_total counter\n")
+		for _, k := range sortedKeys(r.purged) {
+			fmt.Fprintf(&b, "joke_retention_purged_total{store=%q} %d\n", k, r.purged[k])
+		}
+	}
+
+	// Self-check probes
+	if len(r.probes) > 0 {
+		b.WriteString("# HELP joke_selfcheck_probes_total Self-check probes through the name and joke upstreams by result.\n")
+		b.WriteString("# TYPE joke_selfcheck_probes_total counter\n")
+		for _, k := range sortedKeys(r.probes) {
+			fmt.Fprintf(&b, "joke_selfcheck_probes_total{result=%q} %d\n", k, r.probes[k])
+		}
+		b.WriteString("# HELP joke_selfcheck_duration_seconds Latency of self-check probes.\n")
+		b.WriteString("# TYPE joke_selfcheck_duration_seconds histogram\n")
+		r.writeHistograms(&b, "joke_selfcheck_duration_seconds", "probe", r.probeLatency)
+		b.WriteString("# HELP joke_selfcheck_last_success_timestamp_seconds Unix time of the last successful self-check probe, 0 before one.\n")
+		b.WriteString("# TYPE joke_selfcheck_last_success_timestamp_seconds gauge\n")
+		last := int64(0)
+		if !r.probeSuccess.IsZero() {
+			last = r.probeSuccess.Unix()
+		}
+		fmt.Fprintf(&b, "joke_selfcheck_last_success_timestamp_seconds %d\n", last)
+	}
+
+	// Experiment calls and ratings by bucket
+	if len(r.experiments) > 0 || len(r.ratings) > 0 {
+		keys := make([]requestKey, 0, len(r.experiments))
+		for k := range r.experiments {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].upstream != keys[j].upstream {
+				return keys[i].upstream < keys[j].upstream
+			}
+			return keys[i].result < keys[j].result
+		})
+		b.WriteString("# HELP joke_experiment_requests_total Joke calls by experiment bucket and result.\n")
+		b.WriteString("# TYPE joke_experiment_requests_total counter\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "joke_experiment_requests_total{bucket=%q,result=%q} %d\n", k.upstream, k.result, r.experiments[k])
+		}
+		b.WriteString("# HELP joke_experiment_rating Ratings of jokes by experiment bucket; the mean is sum over count.\n")
+		b.WriteString("# TYPE joke_experiment_rating summary\n")
+		for _, k := range sortedKeys(r.ratings) {
+			fmt.Fprintf(&b, "joke_experiment_rating_sum{bucket=%q} %d\n", k, r.ratings[k].sum)
+			fmt.Fprintf(&b, "joke_experiment_rating_count{bucket=%q} %d\n", k, r.ratings[k].count)
+		}
+	}
+
+	// Content-safety verdicts
+	if len(r.safety) > 0 {
+		b.WriteString("# HELP joke_safety_checks_total Jokes checked for content safety by verdict.\n")
+		b.WriteString("# TYPE joke_safety_checks_total counter\n")
+		for _, k := range sortedKeys(r.safety) {
+			fmt.Fprintf(&b, "joke_safety_checks_total{verdict=%q} %d\n", k, r.safety[k])
+		}
+		b.WriteString("# HELP joke_blocklist_blocked_total Jokes blocked for containing a blocklist entry.\n")
+		b.WriteString("# TYPE joke_blocklist_blocked_total counter\n")
+		fmt.Fprintf(&b, "joke_blocklist_blocked_total %d\n", r.blocked)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler returns an http.Handler serving the metrics for a Prometheus scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		// A failed write means the scraper went away, nothing to report
+		_ = r.WriteText(w)
+	})
+}
+
+// Function to write the histograms in hs, one per value of label, r.mu held
+func (r *Registry) writeHistograms(b *strings.Builder, name, label string, hs map[string]*histogram) {
+	for _, v := range sortedKeys(hs) {
+		h := hs[v]
+		var cumulative uint64
+		for i, le := range r.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "%s_bucket{%s=%q,le=%q} %d\n", name, label, v, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, v, h.count)
+		fmt.Fprintf(b, "%s_sum{%s=%q} %s\n", name, label, v, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{%s=%q} %d\n", name, label, v, h.count)
+	}
+}
+
+// Function to return the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
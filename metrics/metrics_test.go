@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ResultOK},
+		{fmt.Errorf("%w: %w", joke.ErrNameUpstream, joke.ErrTimeout), ResultTimeout},
+		{context.DeadlineExceeded, ResultTimeout},
+		{fmt.Errorf("%w: %w", joke.ErrJokeUpstream, context.Canceled), ResultCanceled},
+		{fmt.Errorf("%w: %w", joke.ErrJokeUpstream, joke.ErrDecode), ResultDecode},
+		{fmt.Errorf("%w: %w: %d", joke.ErrNameUpstream, joke.ErrStatus, 503), ResultStatus},
+		{errors.New("connection refused"), ResultError},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Expected %q for %v; got %q", tt.want, tt.err, got)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Records calls by upstream", func(t *testing.T) {
+		r := NewRegistry()
+		names := r.Names("name", joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+			return joke.Names{FirstName: "John", LastName: "Doe"}, nil
+		}))
+		jokes := r.Jokes("joke", joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", fmt.Errorf("%w: %w", joke.ErrJokeUpstream, joke.ErrDecode)
+		}))
+
+		names.Name(context.Background())
+		names.Name(context.Background())
+		jokes.Joke(context.Background(), "John", "Doe")
+
+		var b strings.Builder
+		if err := r.WriteText(&b); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		out := b.String()
+		for _, want := range []string{
+			`joke_upstream_requests_total{upstream="name",result="ok"} 2`,
+			`joke_upstream_requests_total{upstream="joke",result="decode_error"} 1`,
+			`joke_upstream_request_duration_seconds_count{upstream="name"} 2`,
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="+Inf"} 1`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("Buckets are cumulative", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObserveUpstream("joke", 3*time.Millisecond, nil)
+		r.ObserveUpstream("joke", 300*time.Millisecond, nil)
+		r.ObserveUpstream("joke", time.Minute, nil)
+
+		var b strings.Builder
+		r.WriteText(&b)
+		out := b.String()
+		for _, want := range []string{
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="0.005"} 1`,
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="0.25"} 1`,
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="0.5"} 2`,
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="10"} 2`,
+			`joke_upstream_request_duration_seconds_bucket{upstream="joke",le="+Inf"} 3`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("Records bulkhead queue waits", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObserveQueue("joke", 3*time.Millisecond, nil)
+		r.ObserveQueue("joke", time.Second, context.DeadlineExceeded)
+		r.ObserveQueue("name", 0, nil)
+
+		var b strings.Builder
+		r.WriteText(&b)
+		out := b.String()
+		for _, want := range []string{
+			`joke_bulkhead_queue_wait_seconds_bucket{bulkhead="joke",le="0.005"} 1`,
+			`joke_bulkhead_queue_wait_seconds_count{bulkhead="joke"} 2`,
+			`joke_bulkhead_queue_wait_seconds_count{bulkhead="name"} 1`,
+			`joke_bulkhead_rejected_total{bulkhead="joke"} 1`,
+			`joke_bulkhead_rejected_total{bulkhead="name"} 0`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("Records retention purges", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObservePurge("history", 3)
+		r.ObservePurge("history", 0)
+
+		var b strings.Builder
+		r.WriteText(&b)
+		if want := `joke_retention_purged_total{store="history"} 3`; !strings.Contains(b.String(), want) {
+			t.Errorf("Expected output to contain %q; got:\n%s", want, b.String())
+		}
+	})
+
+	t.Run("Records self-check probes", func(t *testing.T) {
+		r := NewRegistry()
+		var b strings.Builder
+		r.WriteText(&b)
+		if strings.Contains(b.String(), "joke_selfcheck") {
+			t.Errorf("Expected no self-check metrics before a probe; got:\n%s", b.String())
+		}
+
+		r.ObserveProbe(time.Millisecond, joke.ErrTimeout)
+		b.Reset()
+		r.WriteText(&b)
+		for _, want := range []string{
+			`joke_selfcheck_probes_total{result="timeout"} 1`,
+			`joke_selfcheck_duration_seconds_count{probe="selfcheck"} 1`,
+			`joke_selfcheck_last_success_timestamp_seconds 0`,
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, b.String())
+			}
+		}
+
+		r.ObserveProbe(time.Millisecond, nil)
+		b.Reset()
+		r.WriteText(&b)
+		if strings.Contains(b.String(), "joke_selfcheck_last_success_timestamp_seconds 0") {
+			t.Errorf("Expected the last success to be set; got:\n%s", b.String())
+		}
+	})
+
+	t.Run("Records experiment calls and ratings", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObserveExperiment("control", time.Millisecond, nil)
+		r.ObserveExperiment("madlibs", time.Millisecond, joke.ErrTimeout)
+		r.ObserveRating("control", 4)
+		r.ObserveRating("control", 2)
+
+		var b strings.Builder
+		r.WriteText(&b)
+		for _, want := range []string{
+			`joke_experiment_requests_total{bucket="control",result="ok"} 1`,
+			`joke_experiment_requests_total{bucket="madlibs",result="timeout"} 1`,
+			`joke_experiment_rating_sum{bucket="control"} 6`,
+			`joke_experiment_rating_count{bucket="control"} 2`,
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, b.String())
+			}
+		}
+	})
+
+	t.Run("Records safety verdicts", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObserveSafety("pass", 0.1)
+		r.ObserveSafety("blocked", 0.9)
+		r.ObserveSafety("blocked", 0.8)
+		r.ObserveBlocked()
+
+		var b strings.Builder
+		r.WriteText(&b)
+		for _, want := range []string{
+			`joke_safety_checks_total{verdict="blocked"} 2`,
+			`joke_blocklist_blocked_total 1`,
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Expected output to contain %q; got:\n%s", want, b.String())
+			}
+		}
+	})
+
+	t.Run("Forwards observations", func(t *testing.T) {
+		r := NewRegistry()
+		f := &recordingForwarder{}
+		r.Forward(f)
+		r.ObserveUpstream("joke", time.Millisecond, joke.ErrTimeout)
+		r.ObserveQueue("name", time.Millisecond, context.Canceled)
+		r.ObservePurge("history", 2)
+
+		want := []string{
+			"count upstream.requests 1 [upstream:joke result:timeout]",
+			"timing upstream.request_duration 1ms [upstream:joke]",
+			"timing bulkhead.queue_wait 1ms [bulkhead:name]",
+			"count bulkhead.rejected 1 [bulkhead:name]",
+			"count retention.purged 2 [store:history]",
+		}
+		if fmt.Sprint(f.got) != fmt.Sprint(want) {
+			t.Errorf("Expected %q; got %q", want, f.got)
+		}
+	})
+
+	t.Run("Serves Prometheus text", func(t *testing.T) {
+		r := NewRegistry()
+		r.ObserveUpstream("name", time.Millisecond, nil)
+
+		rec := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("Expected text/plain content type; got %q", ct)
+		}
+		if !strings.Contains(rec.Body.String(), "# TYPE joke_upstream_requests_total counter") {
+			t.Errorf("Expected counter TYPE line; got:\n%s", rec.Body.String())
+		}
+	})
+}
+
+// struct to hold the observations forwarded to it, formatted
+type recordingForwarder struct {
+	got []string
+}
+
+func (f *recordingForwarder) Count(name string, n int64, tags ...string) {
+	f.got = append(f.got, fmt.Sprintf("count %s %d %v", name, n, tags))
+}
+
+func (f *recordingForwarder) Timing(name string, d time.Duration, tags ...string) {
+	f.got = append(f.got, fmt.Sprintf("timing %s %v %v", name, d, tags))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+
+	handler := APIKey(StaticKeys("secret", "other"))(okHandler)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"Missing key", "", "", http.StatusUnauthorized},
+		{"Invalid key", "X-API-Key", "wrong", http.StatusUnauthorized},
+		{"X-API-Key header", "X-API-Key", "secret", http.StatusOK},
+		{"Bearer token", "Authorization", "Bearer other", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d; got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSelfAuthenticating(t *testing.T) {
+	t.Parallel()
+
+	handler := SelfAuthenticating(APIKey(StaticKeys("secret"))(okHandler))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/integrations/twilio/voice", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a marked request through without a key; got %d", rec.Code)
+	}
+}
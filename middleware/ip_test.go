@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remote string
+		want   string
+		ok     bool
+	}{
+		{"192.0.2.1:1234", "192.0.2.1", true},
+		{"[2001:db8::1]:1234", "2001:db8::1", true},
+		{"[::ffff:192.0.2.1]:1234", "192.0.2.1", true},
+		{"192.0.2.1", "192.0.2.1", true},
+		{"@", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		// Forwarding headers are ignored
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+
+		addr, ok := ClientIP(req)
+		if ok != tt.ok || (ok && addr.String() != tt.want) {
+			t.Errorf("%s: expected %q, %v; got %q, %v", tt.remote, tt.want, tt.ok, addr, ok)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	t.Parallel()
+
+	prefixes, err := ParsePrefixes(" 10.0.0.0/8, 192.0.2.7 ,2001:db8::/32,,10.1.2.3/16")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.7/32", "2001:db8::/32", "10.1.0.0/16"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("Expected %v; got %v", want, prefixes)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("Expected %s; got %s", want[i], p)
+		}
+	}
+
+	if !containsAddr(prefixes, netip.MustParseAddr("10.200.0.1")) || containsAddr(prefixes, netip.MustParseAddr("192.0.2.8")) {
+		t.Error("Unexpected containment result")
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "not-an-ip"} {
+		if _, err := ParsePrefixes(bad); err == nil {
+			t.Errorf("Expected error for %q; got nil", bad)
+		}
+	}
+}
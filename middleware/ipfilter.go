@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	 IPRules holds the CIDR allow and deny lists enforced by IPFilter
+
+		A client in deny is always blocked. When allow is not empty,
+		only clients in it are served. Rules can be replaced while
+		serving with Set or Watch.
+*/
+type IPRules struct {
+	mu    sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// struct to hold the JSON rules file read by LoadIPRules
+type ipRulesFile struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// NewIPRules returns rules with the given allow and deny lists
+func NewIPRules(allow, deny []netip.Prefix) *IPRules {
+	return &IPRules{allow: allow, deny: deny}
+}
+
+/*
+	 LoadIPRules reads rules from a JSON file of CIDRs or IPs:
+
+		{"allow": ["10.0.0.0/8"], "deny": ["10.6.6.0/24", "192.0.2.1"]}
+*/
+func LoadIPRules(path string) (*IPRules, error) {
+	allow, deny, err := readIPRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewIPRules(allow, deny), nil
+}
+
+// Set replaces the allow and deny lists
+func (r *IPRules) Set(allow, deny []netip.Prefix) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allow, r.deny = allow, deny
+}
+
+// Allowed reports whether addr may be served
+func (r *IPRules) Allowed(addr netip.Addr) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if containsAddr(r.deny, addr) {
+		return false
+	}
+	return len(r.allow) == 0 || containsAddr(r.allow, addr)
+}
+
+/*
+	 Watch reloads the rules from path every interval when the file
+	 changes, until ctx is cancelled
+
+		Errors are logged and the current rules kept, so a broken
+		edit never opens or closes the server to everyone.
+*/
+func (r *IPRules) Watch(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Skip the reload when the file is unchanged
+		info, err := os.Stat(path)
+		if err != nil {
+			logger.WarnContext(ctx, "ip rules: could not stat file", "path", path, "error", err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+
+		allow, deny, err := readIPRules(path)
+		if err != nil {
+			logger.WarnContext(ctx, "ip rules: keeping previous rules", "error", err)
+			continue
+		}
+		modTime = info.ModTime()
+		r.Set(allow, deny)
+		logger.InfoContext(ctx, "ip rules: reloaded", "path", path, "allow", len(allow), "deny", len(deny))
+	}
+}
+
+// Function to read and parse a rules file
+func readIPRules(path string) (allow, deny []netip.Prefix, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ip rules: could not read %s: %w", path, err)
+	}
+	var f ipRulesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("ip rules: could not parse %s: %w", path, err)
+	}
+	if allow, err = ParsePrefixes(strings.Join(f.Allow, ",")); err != nil {
+		return nil, nil, fmt.Errorf("ip rules: allow: %w", err)
+	}
+	if deny, err = ParsePrefixes(strings.Join(f.Deny, ",")); err != nil {
+		return nil, nil, fmt.Errorf("ip rules: deny: %w", err)
+	}
+	return allow, deny, nil
+}
+
+// IPFilter rejects requests from clients not allowed by rules with a 403
+func IPFilter(rules *IPRules) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// An unknown address matches no prefix, so only an allow list blocks it
+			addr, _ := ClientIP(r)
+			if !rules.Allowed(addr) {
+				writeError(w, http.StatusForbidden, "forbidden", "client address is not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Function to write a rules file in a temporary directory and return its path
+func writeIPRules(t *testing.T, path, contents string) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "ip-rules.json")
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Could not write rules file: %v", err)
+	}
+	return path
+}
+
+// Function to send a request from remote through handler and return the status
+func statusFrom(handler http.Handler, remote string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remote
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestIPFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Deny list", func(t *testing.T) {
+		deny, _ := ParsePrefixes("192.0.2.0/24")
+		handler := IPFilter(NewIPRules(nil, deny))(okHandler)
+
+		if got := statusFrom(handler, "192.0.2.9:1000"); got != http.StatusForbidden {
+			t.Errorf("Expected denied client to get Forbidden; got %d", got)
+		}
+		if got := statusFrom(handler, "198.51.100.1:1000"); got != http.StatusOK {
+			t.Errorf("Expected other clients to get OK; got %d", got)
+		}
+		if got := statusFrom(handler, "@"); got != http.StatusOK {
+			t.Errorf("Expected unknown address to get OK without an allow list; got %d", got)
+		}
+	})
+
+	t.Run("Allow list with deny override", func(t *testing.T) {
+		allow, _ := ParsePrefixes("10.0.0.0/8")
+		deny, _ := ParsePrefixes("10.6.6.6")
+		handler := IPFilter(NewIPRules(allow, deny))(okHandler)
+
+		tests := map[string]int{
+			"10.1.2.3:1000":     http.StatusOK,
+			"10.6.6.6:1000":     http.StatusForbidden,
+			"198.51.100.1:1000": http.StatusForbidden,
+			"@":                 http.StatusForbidden,
+		}
+		for remote, want := range tests {
+			if got := statusFrom(handler, remote); got != want {
+				t.Errorf("%s: expected status %d; got %d", remote, want, got)
+			}
+		}
+	})
+}
+
+func TestLoadIPRules(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Parses file", func(t *testing.T) {
+		rules, err := LoadIPRules(writeIPRules(t, "", `{"allow": ["10.0.0.0/8"], "deny": ["10.6.6.6"]}`))
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		handler := IPFilter(rules)(okHandler)
+		if statusFrom(handler, "10.1.1.1:1") != http.StatusOK || statusFrom(handler, "10.6.6.6:1") != http.StatusForbidden {
+			t.Error("Unexpected rules loaded")
+		}
+	})
+
+	t.Run("Rejects invalid files", func(t *testing.T) {
+		for _, contents := range []string{`not json`, `{"deny": ["10.0.0.0/99"]}`, `{"allow": ["nope"]}`} {
+			if _, err := LoadIPRules(writeIPRules(t, "", contents)); err == nil {
+				t.Errorf("Expected error for %q; got nil", contents)
+			}
+		}
+	})
+
+	t.Run("Reloads when the file changes", func(t *testing.T) {
+		path := writeIPRules(t, "", `{"deny": []}`)
+		rules, err := LoadIPRules(path)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		handler := IPFilter(rules)(okHandler)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go rules.Watch(ctx, path, 5*time.Millisecond, slog.Default())
+
+		// A broken edit keeps the current rules
+		writeIPRules(t, path, `{"deny": [`)
+		touch(t, path, time.Now().Add(time.Second))
+		time.Sleep(20 * time.Millisecond)
+		if got := statusFrom(handler, "192.0.2.1:1"); got != http.StatusOK {
+			t.Fatalf("Expected previous rules to be kept; got %d", got)
+		}
+
+		// Block the client, moving the modification time so the change is noticed
+		writeIPRules(t, path, `{"deny": ["192.0.2.1"]}`)
+		touch(t, path, time.Now().Add(2*time.Second))
+		deadline := time.Now().Add(time.Second)
+		for statusFrom(handler, "192.0.2.1:1") != http.StatusForbidden {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected client to be blocked after reload")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
+
+// Function to set the modification time of path
+func touch(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Could not touch %s: %v", path, err)
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// How long a client's limiter is kept after its last request
+const ipLimiterIdle = 10 * time.Minute
+
+// struct to hold a client's limiter and when it was last used
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+/*
+	 RateLimitPerIP limits each client IP to rps requests per second
+	 with bursts of up to burst requests
+
+		Clients in allow are not limited. IPv6 clients are limited
+		per /64, the block a single host is usually given. Every
+		limited response carries X-RateLimit-Limit,
+		X-RateLimit-Remaining and X-RateLimit-Reset, the seconds
+		until the client's bucket is full again; rejected requests
+		get a 429 with a Retry-After header.
+*/
+func RateLimitPerIP(rps float64, burst int, allow ...netip.Prefix) Middleware {
+	var (
+		mu        sync.Mutex
+		clients   = make(map[netip.Prefix]*ipLimiter)
+		lastSweep = time.Now()
+	)
+
+	// Function to return the limiter for key, dropping idle limiters now and then
+	limiterFor := func(key netip.Prefix, now time.Time) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if now.Sub(lastSweep) > ipLimiterIdle {
+			for k, c := range clients {
+				if now.Sub(c.lastSeen) > ipLimiterIdle {
+					delete(clients, k)
+				}
+			}
+			lastSweep = now
+		}
+
+		c, ok := clients[key]
+		if !ok {
+			c = &ipLimiter{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+			clients[key] = c
+		}
+		c.lastSeen = now
+		return c.limiter
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, ok := ClientIP(r)
+			// Requests without a parseable address, e.g. over a unix socket, are not limited
+			if !ok || containsAddr(allow, addr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+			limiter := limiterFor(clientPrefix(addr), now)
+
+			res := limiter.ReserveN(now, 1)
+			delay := res.DelayFrom(now)
+			if delay > 0 {
+				// Give the token back, the request is not served
+				res.CancelAt(now)
+			}
+
+			// Report the bucket after this request
+			tokens := limiter.TokensAt(now)
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(burst))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(max(0, int(math.Floor(tokens)))))
+			if rps > 0 {
+				h.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(burst)-tokens)/rps))))
+			}
+
+			if delay > 0 {
+				// Whole seconds until a token is available, at least one
+				h.Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
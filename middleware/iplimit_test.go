@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitPerIP(t *testing.T) {
+	t.Parallel()
+
+	// Function to send a request from remote and return the response
+	send := func(handler http.Handler, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Limits each IP separately", func(t *testing.T) {
+		// Allow a burst of two, refilling one token a minute
+		handler := RateLimitPerIP(1.0/60, 2)(okHandler)
+
+		for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+			rec := send(handler, "192.0.2.1:1000")
+			if rec.Code != want {
+				t.Errorf("Request %d: expected status %d; got %d", i+1, want, rec.Code)
+			}
+		}
+		// Another client still has its full burst
+		if rec := send(handler, "192.0.2.2:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK for another IP; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Sets X-RateLimit headers", func(t *testing.T) {
+		handler := RateLimitPerIP(1.0/60, 2)(okHandler)
+
+		first := send(handler, "192.0.2.1:1000")
+		if first.Header().Get("X-RateLimit-Limit") != "2" || first.Header().Get("X-RateLimit-Remaining") != "1" {
+			t.Errorf("Unexpected headers after first request: %v", first.Header())
+		}
+		if reset := first.Header().Get("X-RateLimit-Reset"); reset != "60" {
+			t.Errorf("Expected reset in 60 seconds; got %q", reset)
+		}
+
+		send(handler, "192.0.2.1:1000")
+		rejected := send(handler, "192.0.2.1:1000")
+		if rejected.Header().Get("X-RateLimit-Remaining") != "0" || rejected.Header().Get("Retry-After") == "" {
+			t.Errorf("Unexpected headers on rejected request: %v", rejected.Header())
+		}
+	})
+
+	t.Run("Skips allowlisted clients", func(t *testing.T) {
+		allow, _ := ParsePrefixes("10.0.0.0/8")
+		handler := RateLimitPerIP(1.0/60, 1, allow...)(okHandler)
+
+		for i := 0; i < 3; i++ {
+			rec := send(handler, "10.1.2.3:1000")
+			if rec.Code != http.StatusOK {
+				t.Errorf("Request %d: expected status OK; got %d", i+1, rec.Code)
+			}
+			if rec.Header().Get("X-RateLimit-Limit") != "" {
+				t.Error("Expected no rate limit headers for allowlisted client")
+			}
+		}
+	})
+
+	t.Run("Groups IPv6 clients by /64", func(t *testing.T) {
+		handler := RateLimitPerIP(1.0/60, 1)(okHandler)
+
+		send(handler, "[2001:db8:0:1::1]:1000")
+		if rec := send(handler, "[2001:db8:0:1::2]:1000"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected the same /64 to share a limit; got %d", rec.Code)
+		}
+		if rec := send(handler, "[2001:db8:0:2::1]:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected another /64 to have its own limit; got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+)
+
+// Callback names JSONP accepts: a JavaScript identifier or dotted path to
+// one, so the callback can't inject script
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// Longest callback name JSONP accepts
+const maxCallbackLen = 128
+
+/*
+	 JSONP wraps the JSON responses of GET requests with ?callback=fn
+	 as a call to fn, served as JavaScript
+
+		The callback implies JSON unless ?format= says otherwise, and
+		responses in other formats are served unwrapped. Callbacks that
+		aren't a JavaScript identifier, or dotted path of them, are
+		rejected with a 400. The empty comment before the call stops
+		the response being sniffed as anything but script.
+*/
+func JSONP() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			callback := r.URL.Query().Get("callback")
+			if callback == "" || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(callback) > maxCallbackLen || !jsonpCallback.MatchString(callback) {
+				writeError(w, http.StatusBadRequest, "invalid_callback", "callback must be a JavaScript identifier")
+				return
+			}
+
+			// Ask for JSON, leaving an explicit ?format= to win
+			r = r.Clone(r.Context())
+			r.Header.Set("Accept", "application/json")
+
+			jw := &jsonpWriter{ResponseWriter: w, callback: callback}
+			next.ServeHTTP(jw, r)
+			jw.close()
+		})
+	}
+}
+
+// jsonpWriter wraps a JSON response body in a call to callback
+type jsonpWriter struct {
+	http.ResponseWriter
+	callback    string
+	wroteHeader bool
+	wrap        bool
+}
+
+func (w *jsonpWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType == "application/json" {
+		w.wrap = true
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(status)
+	if w.wrap {
+		io.WriteString(w.ResponseWriter, "/**/"+w.callback+"(")
+	}
+}
+
+func (w *jsonpWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *jsonpWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Function to end the call once the handler has written the JSON
+func (w *jsonpWriter) close() {
+	if w.wrap {
+		io.WriteString(w.ResponseWriter, ");")
+	}
+}
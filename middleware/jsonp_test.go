@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONP(t *testing.T) {
+	t.Parallel()
+
+	// Serve JSON when it is accepted and plain text otherwise
+	handler := JSONP()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/json" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"joke":"hi"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "hi")
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		ct     string
+		body   string
+	}{
+		{"No callback", http.MethodGet, "/", http.StatusOK, "text/plain; charset=utf-8", "hi"},
+		{"Wraps JSON", http.MethodGet, "/?callback=showJoke", http.StatusOK, "application/javascript; charset=utf-8", `/**/showJoke({"joke":"hi"});`},
+		{"Dotted callback", http.MethodGet, "/?callback=app.jokes.show", http.StatusOK, "application/javascript; charset=utf-8", `/**/app.jokes.show({"joke":"hi"});`},
+		{"Only GET is wrapped", http.MethodPost, "/?callback=showJoke", http.StatusOK, "text/plain; charset=utf-8", "hi"},
+		{"Rejects script", http.MethodGet, "/?callback=alert(1)//", http.StatusBadRequest, "application/json", ""},
+		{"Rejects leading digit", http.MethodGet, "/?callback=1fn", http.StatusBadRequest, "application/json", ""},
+		{"Rejects empty path segment", http.MethodGet, "/?callback=a..b", http.StatusBadRequest, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d; got %d", tt.status, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.ct {
+				t.Errorf("Expected Content-Type %q; got %q", tt.ct, ct)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("Expected body %q; got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("Rejects long callbacks", func(t *testing.T) {
+		long := make([]byte, maxCallbackLen+1)
+		for i := range long {
+			long[i] = 'a'
+		}
+		req := httptest.NewRequest(http.MethodGet, "/?callback="+string(long), nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400; got %d", rec.Code)
+		}
+	})
+}
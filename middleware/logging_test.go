@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	handler := Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/history", nil))
+
+	// Verify the request line carries method, path and status
+	for _, want := range []string{"method=GET", "path=/history", "status=418", "duration="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected log to contain %q; got %q", want, buf.String())
+		}
+	}
+}
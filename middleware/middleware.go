@@ -0,0 +1,41 @@
+// Package middleware provides the cross-cutting HTTP behavior shared by
+// every route: logging, recovery, timeouts, rate limiting and auth.
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Middleware wraps an http.Handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+/*
+	 Chain composes mws into a single Middleware
+
+		The first middleware is the outermost, so
+		Chain(a, b)(h) handles a request as a(b(h)).
+*/
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		// Wrap in reverse so the first middleware is the outermost
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// struct to hold the JSON body of an error response
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Function to write a JSON error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Handler returning 200 OK used as the end of test chains
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	// Middleware appending its name to the response body before and after next
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(name + ">"))
+				next.ServeHTTP(w, r)
+				w.Write([]byte("<" + name))
+			})
+		}
+	}
+
+	t.Run("First middleware is outermost", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Chain(tag("a"), tag("b"))(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if got := rec.Body.String(); got != "a>b>ok<b<a" {
+			t.Errorf("Expected %q; got %q", "a>b>ok<b<a", got)
+		}
+	})
+
+	t.Run("Empty chain returns handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Chain()(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if !strings.Contains(rec.Body.String(), "ok") {
+			t.Errorf("Expected handler response; got %q", rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+	 Quota rejects requests whose API key has used up its quota
+
+		allow reports whether a key may make the request and, if not,
+		when its quota resets. Rejected requests get a 429 with a
+		Retry-After header. Requests without a key are passed on, so
+		Quota belongs after APIKey.
+*/
+func Quota(allow func(key string) (bool, time.Time)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := RequestKey(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, reset := allow(key); !ok {
+				// Whole seconds until the quota resets, at least one
+				wait := math.Max(1, math.Ceil(time.Until(reset).Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait)))
+				writeError(w, http.StatusTooManyRequests, "quota_exceeded", "API key quota exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
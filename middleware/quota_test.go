@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+
+	reset := time.Now().Add(90 * time.Second)
+	handler := Quota(func(key string) (bool, time.Time) {
+		if key == "spent" {
+			return false, reset
+		}
+		return true, time.Time{}
+	})(okHandler)
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"Missing key", "", http.StatusOK},
+		{"Within quota", "fresh", http.StatusOK},
+		{"Over quota", "spent", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d; got %d", tt.status, rec.Code)
+			}
+			if tt.status == http.StatusTooManyRequests {
+				if got := rec.Header().Get("Retry-After"); got != "90" {
+					t.Errorf("Expected Retry-After %q; got %q", "90", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/time/rate"
+)
+
+/*
+	 RateLimit rejects requests over a global limit of rps requests per
+	 second with bursts of up to burst requests
+
+		Rejected requests get a 429 with a Retry-After header.
+*/
+func RateLimit(rps float64, burst int) Middleware {
+	limiter := rate.NewLimiter(rate.Limit(rps), burst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Reserve()
+			if delay := res.Delay(); delay > 0 {
+				// Give the token back, the request is not served
+				res.Cancel()
+				// Whole seconds until a token is available, at least one
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	// Allow a burst of two, refilling one token a minute
+	handler := RateLimit(1.0/60, 2)(okHandler)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != want {
+			t.Errorf("Request %d: expected status %d; got %d", i+1, want, rec.Code)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header on rejected request")
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicReporter is told about every recovered panic, e.g. to send it to an error tracker
+type PanicReporter func(r *http.Request, p any, stack []byte)
+
+// Recover turns a panicking handler into a 500 response instead of a
+// dropped connection, logging the panic and stack trace
+func Recover(logger *slog.Logger) Middleware {
+	return RecoverReporting(logger, nil)
+}
+
+// RecoverReporting is Recover, also passing each panic to report unless it is nil
+func RecoverReporting(logger *slog.Logger, report PanicReporter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// Let the server abort the response as it normally would
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				stack := debug.Stack()
+				logger.ErrorContext(r.Context(), "handler panic", "panic", p, "stack", string(stack))
+				if report != nil {
+					report(r, p, stack)
+				}
+				writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
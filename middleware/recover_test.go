@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Verify status code is 500
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
+	}
+}
+
+func TestRecoverReporting(t *testing.T) {
+	t.Parallel()
+
+	var got any
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := RecoverReporting(logger, func(r *http.Request, p any, stack []byte) {
+		if len(stack) == 0 {
+			t.Error("Expected a stack trace")
+		}
+		got = p
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status Internal Server Error; got %v", rec.Code)
+	}
+	if got != "boom" {
+		t.Errorf("Expected the panic to be reported; got %v", got)
+	}
+}
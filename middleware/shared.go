@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+/*
+	 SharedLimiter counts requests in a store shared by every replica,
+	 e.g. a *redis.Limiter
+
+		Allow reports whether a request for key is allowed and, if not,
+		how long until one would be; remaining is how many more the
+		key could make now, and reset how long until it could make
+		Limit again.
+*/
+type SharedLimiter interface {
+	Allow(ctx context.Context, key string) (allowed bool, remaining int64, retryAfter, reset time.Duration, err error)
+	Limit() int64
+}
+
+/*
+	 RateLimitShared limits requests with l, so the limit holds across
+	 replicas rather than per instance
+
+		key names the bucket a request counts against; requests it
+		returns false for aren't limited. Rejected requests get a 429
+		with a Retry-After header. If l fails the request is served,
+		so an outage of the shared store doesn't take the server down
+		with it, and the error is logged.
+*/
+func RateLimitShared(l SharedLimiter, key func(r *http.Request) (string, bool), logger *slog.Logger) Middleware {
+	return rateLimitShared(l, key, false, logger)
+}
+
+/*
+	 RateLimitSharedPerIP limits each client IP with l, as
+	 RateLimitPerIP does in memory
+
+		Clients in allow are not limited, and IPv6 clients are
+		limited per /64. Every limited response carries
+		X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset,
+		the seconds until the client could use the whole limit
+		again. Failures of l are handled as by RateLimitShared.
+*/
+func RateLimitSharedPerIP(l SharedLimiter, logger *slog.Logger, allow ...netip.Prefix) Middleware {
+	return rateLimitShared(l, IPKey(allow...), true, logger)
+}
+
+// Function to build RateLimitShared, setting the X-RateLimit headers when headers is true
+func rateLimitShared(l SharedLimiter, key func(r *http.Request) (string, bool), headers bool, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, ok := key(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, remaining, retryAfter, reset, err := l.Allow(r.Context(), k)
+			// Handle errors from the shared store; fail open
+			if err != nil {
+				logger.ErrorContext(r.Context(), "shared rate limit unavailable", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			if headers {
+				h.Set("X-RateLimit-Limit", strconv.FormatInt(l.Limit(), 10))
+				h.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+				h.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+			}
+			if !allowed {
+				// Whole seconds until a request fits, at least one
+				h.Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+				writeError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GlobalKey counts every request against one bucket, for RateLimitShared
+func GlobalKey(r *http.Request) (string, bool) {
+	return "global", true
+}
+
+/*
+	 IPKey counts requests against a bucket per client IP, for
+	 RateLimitShared
+
+		Clients in allow, and requests without a parseable address,
+		are not limited. IPv6 clients share a bucket per /64, as in
+		RateLimitPerIP.
+*/
+func IPKey(allow ...netip.Prefix) func(r *http.Request) (string, bool) {
+	return func(r *http.Request) (string, bool) {
+		addr, ok := ClientIP(r)
+		if !ok || containsAddr(allow, addr) {
+			return "", false
+		}
+		return clientPrefix(addr).String(), true
+	}
+}
+
+// Function to return the block a client is limited by: its address, or its /64 for IPv6
+func clientPrefix(addr netip.Addr) netip.Prefix {
+	if addr.Is6() {
+		p, _ := addr.Prefix(64)
+		return p
+	}
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
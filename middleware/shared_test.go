@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// struct to hold a SharedLimiter allowing a fixed number of requests per key
+type fakeSharedLimiter struct {
+	limit int
+	seen  map[string]int
+	err   error
+}
+
+func (l *fakeSharedLimiter) Allow(ctx context.Context, key string) (bool, int64, time.Duration, time.Duration, error) {
+	if l.err != nil {
+		return false, 0, 0, 0, l.err
+	}
+	l.seen[key]++
+	if l.seen[key] > l.limit {
+		return false, 0, 1500 * time.Millisecond, 2500 * time.Millisecond, nil
+	}
+	return true, int64(l.limit - l.seen[key]), 0, 1200 * time.Millisecond, nil
+}
+
+func (l *fakeSharedLimiter) Limit() int64 {
+	return int64(l.limit)
+}
+
+func TestRateLimitShared(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Function to send a request from remote and return the response
+	send := func(handler http.Handler, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Rejects requests over the limit", func(t *testing.T) {
+		l := &fakeSharedLimiter{limit: 1, seen: map[string]int{}}
+		handler := RateLimitShared(l, GlobalKey, logger)(okHandler)
+
+		if rec := send(handler, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %d", rec.Code)
+		}
+		rec := send(handler, "192.0.2.2:1000")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429; got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("Expected Retry-After rounded up to 2; got %q", got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "" {
+			t.Errorf("Expected no X-RateLimit headers; got limit %q", got)
+		}
+	})
+
+	t.Run("Fails open when the store is down", func(t *testing.T) {
+		l := &fakeSharedLimiter{err: errors.New("connection refused")}
+		handler := RateLimitShared(l, GlobalKey, logger)(okHandler)
+
+		if rec := send(handler, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Keys by client IP", func(t *testing.T) {
+		allow, _ := ParsePrefixes("10.0.0.0/8")
+		l := &fakeSharedLimiter{limit: 1, seen: map[string]int{}}
+		handler := RateLimitSharedPerIP(l, logger, allow...)(okHandler)
+
+		send(handler, "[2001:db8:0:1::1]:1000")
+		if rec := send(handler, "[2001:db8:0:1::2]:1000"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected the same /64 to share a limit; got %d", rec.Code)
+		}
+		if rec := send(handler, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected another IP to have its own limit; got %d", rec.Code)
+		}
+		send(handler, "10.1.2.3:1000")
+		if rec := send(handler, "10.1.2.3:1000"); rec.Code != http.StatusOK {
+			t.Errorf("Expected allowlisted clients not to be limited; got %d", rec.Code)
+		}
+		if _, ok := l.seen["192.0.2.1/32"]; !ok {
+			t.Errorf("Expected IPv4 clients keyed by address; got %v", l.seen)
+		}
+	})
+
+	t.Run("Reports the client's limit in headers", func(t *testing.T) {
+		l := &fakeSharedLimiter{limit: 2, seen: map[string]int{}}
+		handler := RateLimitSharedPerIP(l, logger)(okHandler)
+
+		// Function to check the X-RateLimit headers of rec
+		check := func(rec *httptest.ResponseRecorder, remaining, reset string) {
+			t.Helper()
+			h := rec.Header()
+			if h.Get("X-RateLimit-Limit") != "2" || h.Get("X-RateLimit-Remaining") != remaining || h.Get("X-RateLimit-Reset") != reset {
+				t.Errorf("Expected limit 2, remaining %s and reset %s; got %v", remaining, reset, h)
+			}
+		}
+		check(send(handler, "192.0.2.1:1000"), "1", "2")
+		check(send(handler, "192.0.2.1:1000"), "0", "2")
+		rec := send(handler, "192.0.2.1:1000")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429; got %d", rec.Code)
+		}
+		check(rec, "0", "3")
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+/*
+	 Timeout sets a deadline of d on every request's context, cancelling
+	 in-flight upstream calls once it passes
+
+		Requests marked by Streaming stay open by design and get no
+		deadline; their handler bounds each event's work instead.
+*/
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsStreaming(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Context key marking requests for a long-lived stream
+type streamingKey struct{}
+
+/*
+	 Streaming marks requests to next as opening a long-lived stream,
+	 e.g. Server-Sent Events, which Timeout leaves without a deadline
+
+		Wrap the middleware chain with it for the streaming routes
+		only; a request's Accept header alone doesn't lift the deadline.
+*/
+func Streaming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), streamingKey{}, true)))
+	})
+}
+
+// IsStreaming reports whether the request of ctx was marked by Streaming
+func IsStreaming(ctx context.Context) bool {
+	marked, _ := ctx.Value(streamingKey{}).(bool)
+	return marked
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Block until the deadline cancels the request context
+		select {
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Verify the context was cancelled by the deadline
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status Gateway Timeout; got %v", rec.Code)
+	}
+}
+
+func TestTimeoutEventStream(t *testing.T) {
+	t.Parallel()
+
+	var deadline bool
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = r.Context().Deadline()
+	}))
+
+	t.Run("Streaming routes get no deadline", func(t *testing.T) {
+		Streaming(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+		if deadline {
+			t.Error("Expected no deadline on a stream")
+		}
+	})
+
+	t.Run("Other routes keep theirs whatever they accept", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/joke", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if !deadline {
+			t.Error("Expected a deadline on /joke asking for an event stream")
+		}
+	})
+}
@@ -0,0 +1,227 @@
+/*
+	 Package payloadlog logs a sampled fraction of raw upstream HTTP
+	 payloads, to debug intermittent decode failures without logging
+	 every response.
+
+		Logged bodies are capped at MaxBytes, and credentials in
+		headers, query strings and JSON bodies are redacted.
+*/
+package payloadlog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Bytes of each body logged when MaxBytes is not set
+const DefaultMaxBytes = 4096
+
+// Replacement for redacted values
+const redacted = "[REDACTED]"
+
+// Headers, query parameters and JSON fields redacted when none are configured
+var (
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+	DefaultRedactParams  = []string{"api_key", "apikey", "key", "token", "access_token", "password"}
+	DefaultRedactFields  = []string{"api_key", "token", "access_token", "refresh_token", "password", "secret"}
+)
+
+/*
+	 Transport is an http.RoundTripper logging a sample of the
+	 requests it sends and the responses it receives
+
+		Each request is logged with probability Rate. The response
+		body is logged when the caller closes it, with whatever the
+		caller read, so a body is never read twice.
+*/
+type Transport struct {
+	// Probability between 0 and 1 that a request is logged
+	Rate float64
+	// Bytes of the response body logged, defaults to DefaultMaxBytes
+	MaxBytes int
+	// Headers whose values are redacted, defaults to DefaultRedactHeaders
+	RedactHeaders []string
+	// Query parameters whose values are redacted, defaults to DefaultRedactParams
+	RedactParams []string
+	// JSON body fields whose string values are redacted, defaults to DefaultRedactFields
+	RedactFields []string
+	// Next performs requests, defaults to http.DefaultTransport
+	Next http.RoundTripper
+	// Logger for payloads, defaults to slog.Default()
+	Logger *slog.Logger
+
+	// Source of random numbers in [0, 1), replaced in tests
+	random func() float64
+
+	once    sync.Once
+	fieldRE *regexp.Regexp
+}
+
+// New returns a Transport logging a fraction rate of requests sent through next
+func New(rate float64, next http.RoundTripper, logger *slog.Logger) *Transport {
+	return &Transport{Rate: rate, Next: next, Logger: logger}
+}
+
+// RoundTrip sends req through Next, logging it when sampled
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !t.sampled() {
+		return next.RoundTrip(req)
+	}
+
+	attrs := []any{
+		"method", req.Method,
+		"url", t.redactURL(req.URL),
+		"request_header", t.redactHeader(req.Header),
+	}
+	res, err := next.RoundTrip(req)
+	// Handle errors while making request
+	if err != nil {
+		t.logger().WarnContext(req.Context(), "upstream payload", append(attrs, "error", err)...)
+		return nil, err
+	}
+
+	// Log once the caller has read and closed the body
+	res.Body = &capture{
+		ReadCloser: res.Body,
+		max:        t.maxBytes(),
+		done: func(body []byte, n int64) {
+			t.logger().InfoContext(req.Context(), "upstream payload", append(attrs,
+				"status", res.StatusCode,
+				"response_header", t.redactHeader(res.Header),
+				"body", t.redactBody(body),
+				"body_bytes", n,
+				"truncated", n > int64(len(body)),
+			)...)
+		},
+	}
+	return res, nil
+}
+
+// Function to decide whether the next request is logged
+func (t *Transport) sampled() bool {
+	if t.Rate <= 0 {
+		return false
+	}
+	random := t.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < t.Rate
+}
+
+// Function to return a copy of u with sensitive query values redacted
+func (t *Transport) redactURL(u *url.URL) string {
+	params := orDefault(t.RedactParams, DefaultRedactParams)
+	q := u.Query()
+	for key := range q {
+		for _, p := range params {
+			if strings.EqualFold(key, p) {
+				q[key] = []string{redacted}
+			}
+		}
+	}
+	c := *u
+	c.User = nil
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+// Function to return a copy of h with sensitive values redacted
+func (t *Transport) redactHeader(h http.Header) http.Header {
+	c := h.Clone()
+	if c == nil {
+		return http.Header{}
+	}
+	for _, name := range orDefault(t.RedactHeaders, DefaultRedactHeaders) {
+		if c.Get(name) != "" {
+			c.Set(name, redacted)
+		}
+	}
+	return c
+}
+
+/*
+	 Function to redact sensitive JSON string fields in body
+
+		Matches fields textually rather than decoding the body, so
+		truncated and malformed payloads, the ones worth logging,
+		are redacted too.
+*/
+func (t *Transport) redactBody(body []byte) string {
+	t.once.Do(func() {
+		fields := orDefault(t.RedactFields, DefaultRedactFields)
+		quoted := make([]string, len(fields))
+		for i, f := range fields {
+			quoted[i] = regexp.QuoteMeta(f)
+		}
+		t.fieldRE = regexp.MustCompile(`("(?i:` + strings.Join(quoted, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	})
+	return t.fieldRE.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+}
+
+// Function to return the configured body cap
+func (t *Transport) maxBytes() int {
+	if t.MaxBytes <= 0 {
+		return DefaultMaxBytes
+	}
+	return t.MaxBytes
+}
+
+// Function to return the configured logger
+func (t *Transport) logger() *slog.Logger {
+	if t.Logger == nil {
+		return slog.Default()
+	}
+	return t.Logger
+}
+
+// Function to return s, or def when s is empty
+func orDefault(s, def []string) []string {
+	if len(s) == 0 {
+		return def
+	}
+	return s
+}
+
+/*
+	 capture is a response body keeping the first max bytes read
+
+		done is called once, on Close, with the kept bytes and the
+		total number of bytes read.
+*/
+type capture struct {
+	io.ReadCloser
+	max  int
+	buf  bytes.Buffer
+	n    int64
+	done func(body []byte, n int64)
+	once sync.Once
+}
+
+// Read reads from the body, keeping bytes up to the cap
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	if room := c.max - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+// Close closes the body and logs what was read
+func (c *capture) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(func() { c.done(c.buf.Bytes(), c.n) })
+	return err
+}
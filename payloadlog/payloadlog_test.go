@@ -0,0 +1,143 @@
+package payloadlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Function to return a transport logging every request to buf as JSON
+func newTestTransport(buf *bytes.Buffer) *Transport {
+	return &Transport{
+		Rate:   1,
+		Logger: slog.New(slog.NewJSONHandler(buf, nil)),
+		random: func() float64 { return 0 },
+	}
+}
+
+// Function to GET url through t, read the whole body and close it
+func get(t *testing.T, rt http.RoundTripper, url string, header http.Header) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	req.Header = header
+	res, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		t.Fatalf("Could not GET %s: %v", url, err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	return string(body)
+}
+
+// Function to decode the single log record written to buf
+func record(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("Could not decode log record %q: %v", buf.String(), err)
+	}
+	return rec
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		io.WriteString(w, `{"first_name": "John", "token": "s3cr\"et", "last_name": "Doe"}`)
+	}))
+	defer upstream.Close()
+
+	t.Run("Logs sampled payload", func(t *testing.T) {
+		var buf bytes.Buffer
+		body := get(t, newTestTransport(&buf), upstream.URL+"/?api_key=hunter2&limitTo=nerdy", http.Header{"X-Api-Key": {"hunter2"}})
+
+		// The caller still gets the full, unredacted body
+		if !strings.Contains(body, `s3cr\"et`) {
+			t.Errorf("Expected caller to receive the raw body; got %q", body)
+		}
+
+		rec := record(t, &buf)
+		if rec["msg"] != "upstream payload" || rec["status"] != float64(200) {
+			t.Errorf("Unexpected record: %v", rec)
+		}
+		logged := buf.String()
+		for _, secret := range []string{"hunter2", "s3cr", "session=abc"} {
+			if strings.Contains(logged, secret) {
+				t.Errorf("Expected %q to be redacted; got %s", secret, logged)
+			}
+		}
+		if !strings.Contains(logged, "limitTo=nerdy") || !strings.Contains(logged, "John") {
+			t.Errorf("Expected non-sensitive values to be logged; got %s", logged)
+		}
+	})
+
+	t.Run("Caps logged body", func(t *testing.T) {
+		var buf bytes.Buffer
+		rt := newTestTransport(&buf)
+		rt.MaxBytes = 10
+		body := get(t, rt, upstream.URL, nil)
+
+		rec := record(t, &buf)
+		if rec["body"] != body[:10] {
+			t.Errorf("Expected body %q; got %q", body[:10], rec["body"])
+		}
+		if rec["truncated"] != true || rec["body_bytes"] != float64(len(body)) {
+			t.Errorf("Expected truncated body of %d bytes; got %v", len(body), rec)
+		}
+	})
+
+	t.Run("Skips unsampled requests", func(t *testing.T) {
+		var buf bytes.Buffer
+		rt := newTestTransport(&buf)
+		rt.Rate = 0.1
+		rt.random = func() float64 { return 0.5 }
+		get(t, rt, upstream.URL, nil)
+
+		if buf.Len() != 0 {
+			t.Errorf("Expected nothing logged; got %s", buf.String())
+		}
+	})
+
+	t.Run("Logs transport errors", func(t *testing.T) {
+		closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		closed.Close()
+		var buf bytes.Buffer
+
+		if _, err := (&http.Client{Transport: newTestTransport(&buf)}).Get(closed.URL); err == nil {
+			t.Fatal("Expected error from closed upstream; got nil")
+		}
+		if rec := record(t, &buf); rec["error"] == nil {
+			t.Errorf("Expected error to be logged; got %v", rec)
+		}
+	})
+}
+
+func TestRedactBody(t *testing.T) {
+	t.Parallel()
+
+	rt := &Transport{}
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"password":"pw"}`, `{"password":"[REDACTED]"}`},
+		{`{"Token" : "a\"b", "joke": "ok"}`, `{"Token" : "[REDACTED]", "joke": "ok"}`},
+		// Truncated payloads are redacted too
+		{`{"secret": "abc`, `{"secret": "[REDACTED]"`},
+		{`{"first_name": "John"}`, `{"first_name": "John"}`},
+	}
+	for _, tt := range tests {
+		if got := rt.redactBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("Expected %q; got %q", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+	 fakeServer is an in-memory Redis speaking RESP2, supporting the
+	 commands the client and Limiter use
+
+		Values never expire; PEXPIRE only records the ttl.
+*/
+type fakeServer struct {
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]string
+	password string
+	commands []string
+	addr     string
+}
+
+// Function to start a fakeServer, stopped when t ends
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeServer{values: map[string]string{}, ttls: map[string]string{}, addr: ln.Addr().String()}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+// Function to answer the commands sent over c until it closes
+func (f *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := false
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		list, _ := reply.([]any)
+		args := make([]string, len(list))
+		for i, a := range list {
+			args[i], _ = a.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		var out string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if args[1] == f.password {
+				authed = true
+				out = "+OK\r\n"
+			} else {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case f.password != "" && !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			out = "+PONG\r\n"
+		case cmd == "SELECT":
+			out = "+OK\r\n"
+		case cmd == "INCR" || cmd == "DECR":
+			n, _ := strconv.ParseInt(f.values[args[1]], 10, 64)
+			if cmd == "INCR" {
+				n++
+			} else {
+				n--
+			}
+			f.values[args[1]] = strconv.FormatInt(n, 10)
+			out = ":" + f.values[args[1]] + "\r\n"
+		case cmd == "PEXPIRE":
+			f.ttls[args[1]] = args[2]
+			out = ":1\r\n"
+		case cmd == "SET":
+			// Only SET key value NX PX ttl, as Lease uses it
+			if _, ok := f.values[args[1]]; ok {
+				out = "$-1\r\n"
+			} else {
+				f.values[args[1]] = args[2]
+				f.ttls[args[1]] = args[5]
+				out = "+OK\r\n"
+			}
+		case cmd == "EVAL":
+			// Only the lease scripts, run natively
+			key, token := args[3], args[4]
+			out = ":0\r\n"
+			if f.values[key] == token {
+				switch args[1] {
+				case renewScript:
+					f.ttls[key] = args[5]
+				case releaseScript:
+					delete(f.values, key)
+				}
+				out = ":1\r\n"
+			}
+		case cmd == "GET":
+			if v, ok := f.values[args[1]]; ok {
+				out = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			} else {
+				out = "$-1\r\n"
+			}
+		default:
+			out = "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := c.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+// Function to return the value stored under key
+func (f *fakeServer) get(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[key]
+}
+
+// Function to remove key, as if it expired
+func (f *fakeServer) expire(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.values, key)
+}
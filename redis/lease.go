@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/token"
+)
+
+// Scripts renewing and releasing a lease only while the caller still holds it
+const (
+	renewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+/*
+	 Lease elects one holder of key among every replica, e.g. to run a
+	 scheduled job once per cluster rather than once per instance
+
+		The holder stores a random token under key for TTL and renews
+		it before it expires; if the holder dies the key expires and
+		another replica takes over. Build one with NewLease.
+*/
+type Lease struct {
+	client *Client
+	key    string
+	token  string
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	held bool
+}
+
+// NewLease returns a Lease on key that lapses ttl after it was last renewed
+func NewLease(c *Client, key string, ttl time.Duration) *Lease {
+	return &Lease{client: c, key: key, token: token.String(16), ttl: ttl}
+}
+
+/*
+	 Acquire takes the lease if it is free, or renews it if held,
+	 reporting whether this Lease holds it
+
+		Errors leave the lease as not held, so a replica cut off from
+		Redis stops acting as the leader.
+*/
+func (l *Lease) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	var reply any
+	var err error
+	if l.held {
+		reply, err = l.client.Do(ctx, "EVAL", renewScript, "1", l.key, l.token, ttl)
+	} else {
+		reply, err = l.client.Do(ctx, "SET", l.key, l.token, "NX", "PX", ttl)
+	}
+	if err != nil {
+		l.held = false
+		return false, err
+	}
+	// SET replies OK when taken, the script 1 when renewed
+	l.held = reply == "OK" || reply == int64(1)
+	return l.held, nil
+}
+
+// Release gives up the lease if held, so another replica can take it without waiting for it to lapse
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held {
+		return nil
+	}
+	l.held = false
+	_, err := l.client.Do(ctx, "EVAL", releaseScript, "1", l.key, l.token)
+	return err
+}
+
+/*
+	 Lead runs job whenever this replica holds the lease, until ctx is
+	 done
+
+		The lease is acquired or renewed every third of its TTL. job's
+		context is cancelled when the lease is lost, and the lease is
+		released when ctx is done. errs, if not nil, receives errors
+		talking to Redis.
+*/
+func (l *Lease) Lead(ctx context.Context, job func(ctx context.Context), errs func(error)) {
+	ticker := time.NewTicker(max(l.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	// The running job, nil while not leading
+	var running *leaderJob
+	// Function to cancel the running job and wait for it to return
+	stop := func() {
+		if running != nil {
+			running.cancel()
+			<-running.done
+			running = nil
+		}
+	}
+	defer func() {
+		stop()
+		// Release with a fresh context, ctx is already done
+		release, cancelRelease := context.WithTimeout(context.Background(), l.client.timeout)
+		defer cancelRelease()
+		if err := l.Release(release); err != nil && errs != nil {
+			errs(err)
+		}
+	}()
+
+	for {
+		held, err := l.Acquire(ctx)
+		if err != nil && errs != nil && ctx.Err() == nil {
+			errs(err)
+		}
+		if held && running == nil {
+			running = startJob(ctx, job)
+		} else if !held {
+			stop()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// struct to hold a job run by Lead and how to stop it
+type leaderJob struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Function to run job in a goroutine with a context cancelled by the returned leaderJob
+func startJob(ctx context.Context, job func(ctx context.Context)) *leaderJob {
+	ctx, cancel := context.WithCancel(ctx)
+	j := &leaderJob{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(j.done)
+		job(ctx)
+	}()
+	return j
+}
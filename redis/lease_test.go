@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	t.Parallel()
+
+	// Function to return two leases on the same key, as two replicas would hold
+	newLeases := func(t *testing.T, ttl time.Duration) (*Lease, *Lease, *fakeServer) {
+		f := newFakeServer(t)
+		c, _ := Open("redis://" + f.addr)
+		t.Cleanup(func() { c.Close() })
+		a := NewLease(c, "leader", ttl)
+		b := NewLease(c, "leader", ttl)
+		return a, b, f
+	}
+
+	t.Run("Only one replica holds the lease", func(t *testing.T) {
+		a, b, f := newLeases(t, time.Minute)
+		ctx := context.Background()
+
+		if held, err := a.Acquire(ctx); !held || err != nil {
+			t.Fatalf("Expected the first replica to acquire; got %v, %v", held, err)
+		}
+		if held, _ := b.Acquire(ctx); held {
+			t.Error("Expected the second replica not to acquire")
+		}
+		// Renewing keeps it
+		if held, _ := a.Acquire(ctx); !held {
+			t.Error("Expected the holder to renew")
+		}
+		if got := f.ttls["leader"]; got != "60000" {
+			t.Errorf("Expected a ttl of 60000ms; got %q", got)
+		}
+	})
+
+	t.Run("Released or lapsed leases are taken over", func(t *testing.T) {
+		a, b, f := newLeases(t, time.Minute)
+		ctx := context.Background()
+
+		a.Acquire(ctx)
+		if err := a.Release(ctx); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if held, _ := b.Acquire(ctx); !held {
+			t.Fatal("Expected a released lease to be taken")
+		}
+
+		// b lapses and a takes over; b can't renew or release a's lease
+		f.expire("leader")
+		a.Acquire(ctx)
+		if held, _ := b.Acquire(ctx); held {
+			t.Error("Expected a lapsed holder not to renew")
+		}
+		b.Release(ctx)
+		if f.get("leader") != a.token {
+			t.Error("Expected the new holder to keep the lease")
+		}
+	})
+
+	t.Run("Lead runs the job only while leading", func(t *testing.T) {
+		a, b, f := newLeases(t, 30*time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var runningA, runningB atomic.Int32
+		// Function to return a job counting itself as running until cancelled
+		job := func(running *atomic.Int32) func(ctx context.Context) {
+			return func(ctx context.Context) {
+				running.Add(1)
+				<-ctx.Done()
+				running.Add(-1)
+			}
+		}
+		done := make(chan struct{}, 2)
+		go func() { a.Lead(ctx, job(&runningA), nil); done <- struct{}{} }()
+		waitFor(t, func() bool { return runningA.Load() == 1 })
+		go func() { b.Lead(ctx, job(&runningB), nil); done <- struct{}{} }()
+
+		time.Sleep(50 * time.Millisecond)
+		if runningB.Load() != 0 {
+			t.Error("Expected only the leader to run the job")
+		}
+
+		// a loses the lease to b
+		f.mu.Lock()
+		f.values["leader"] = b.token
+		f.mu.Unlock()
+		b.mu.Lock()
+		b.held = true
+		b.mu.Unlock()
+		waitFor(t, func() bool { return runningA.Load() == 0 && runningB.Load() == 1 })
+
+		cancel()
+		<-done
+		<-done
+		if runningB.Load() != 0 {
+			t.Error("Expected the job to stop with Lead")
+		}
+		if f.get("leader") != "" {
+			t.Error("Expected the lease to be released")
+		}
+	})
+}
+
+// Function to wait up to a second for cond
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
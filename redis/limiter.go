@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+/*
+	 Limiter allows up to Limit requests per key in any Window, counted
+	 in Redis so every replica shares the limit
+
+		It uses a sliding window counter: requests are counted in fixed
+		windows, and the previous window's count is weighted by how
+		much of it still overlaps the sliding window. Rejected requests
+		aren't counted.
+*/
+type Limiter struct {
+	client *Client
+	prefix string
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+}
+
+/*
+	 NewLimiter returns a Limiter allowing rps requests per second on
+	 average with bursts of up to burst, under keys starting with prefix
+
+		The window is burst/rps long, at least a second, so a full
+		burst is allowed once per window. The limit is the larger of
+		burst and the requests rps allows over the window, so rps
+		above burst is not cut down to burst per second.
+*/
+func NewLimiter(c *Client, prefix string, rps float64, burst int) *Limiter {
+	window := time.Second
+	limit := int64(max(1, burst))
+	if rps > 0 {
+		window = max(time.Second, time.Duration(float64(burst)/rps*float64(time.Second)))
+		limit = max(limit, int64(math.Ceil(rps*window.Seconds())))
+	}
+	return &Limiter{client: c, prefix: prefix, limit: limit, window: window, now: time.Now}
+}
+
+// Limit returns the most requests allowed for a key in any window
+func (l *Limiter) Limit() int64 {
+	return l.limit
+}
+
+/*
+	 Allow counts a request for key, returning false and how long to
+	 wait when over the limit
+
+		remaining is how many more requests the key could make now,
+		and reset how long until the requests counted so far have all
+		slid out of the window.
+*/
+func (l *Limiter) Allow(ctx context.Context, key string) (allowed bool, remaining int64, retryAfter, reset time.Duration, err error) {
+	now := l.now().UnixMilli()
+	window := l.window.Milliseconds()
+	index := now / window
+	elapsed := now % window
+
+	cur := l.prefix + ":" + key + ":" + strconv.FormatInt(index, 10)
+	prev := l.prefix + ":" + key + ":" + strconv.FormatInt(index-1, 10)
+	replies, err := l.client.Pipeline(ctx,
+		[]string{"INCR", cur},
+		// Kept until it stops being the previous window
+		[]string{"PEXPIRE", cur, strconv.FormatInt(2*window, 10)},
+		[]string{"GET", prev},
+	)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	count, ok := replies[0].(int64)
+	if !ok {
+		return false, 0, 0, 0, fmt.Errorf("redis: unexpected INCR reply %v", replies[0])
+	}
+	var prevCount int64
+	if s, ok := replies[2].(string); ok {
+		prevCount, _ = strconv.ParseInt(s, 10, 64)
+	}
+
+	// Weight the previous window by how much of it the sliding window covers
+	weight := float64(window-elapsed) / float64(window)
+	estimate := float64(prevCount)*weight + float64(count)
+	if estimate <= float64(l.limit) {
+		return true, l.remaining(estimate), 0, resetAfter(prevCount, count, elapsed, window), nil
+	}
+
+	// Give the request back, it is not served
+	if _, err := l.client.Do(ctx, "DECR", cur); err != nil {
+		return false, 0, 0, 0, err
+	}
+	count--
+	return false, l.remaining(estimate - 1), l.retryAfter(prevCount, count, elapsed, window), resetAfter(prevCount, count, elapsed, window), nil
+}
+
+// Function to return how many more requests fit under the limit with estimate counted
+func (l *Limiter) remaining(estimate float64) int64 {
+	return max(0, int64(math.Floor(float64(l.limit)-estimate)))
+}
+
+/*
+	 Function to return how long until no counted request is left in
+	 the sliding window
+
+		Requests in the current window slide out once the next one
+		ends, and those in the previous window once the current one
+		ends.
+*/
+func resetAfter(prevCount, count, elapsed, window int64) time.Duration {
+	switch {
+	case count > 0:
+		return time.Duration(2*window-elapsed) * time.Millisecond
+	case prevCount > 0:
+		return time.Duration(window-elapsed) * time.Millisecond
+	}
+	return 0
+}
+
+/*
+	 Function to return how long until one more request fits
+
+		The previous window's weight falls as time passes, so with
+		previous requests the wait is until enough of them slide out;
+		otherwise it is until the current window ends.
+*/
+func (l *Limiter) retryAfter(prevCount, count, elapsed, window int64) time.Duration {
+	remaining := window - elapsed
+	if prevCount == 0 || count+1 > l.limit {
+		return time.Duration(remaining) * time.Millisecond
+	}
+	// Solve prev*(remaining-t)/window + count+1 <= limit for t
+	excess := float64(prevCount)*float64(remaining)/float64(window) + float64(count+1) - float64(l.limit)
+	wait := int64(math.Ceil(excess * float64(window) / float64(prevCount)))
+	return time.Duration(min(wait, remaining)) * time.Millisecond
+}
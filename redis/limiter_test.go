@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	// Function to return a limiter of burst requests per second at now
+	newLimiter := func(t *testing.T, burst int, now *time.Time) (*Limiter, *fakeServer) {
+		f := newFakeServer(t)
+		c, _ := Open("redis://" + f.addr)
+		t.Cleanup(func() { c.Close() })
+		l := NewLimiter(c, "rl", float64(burst), burst)
+		l.now = func() time.Time { return *now }
+		return l, f
+	}
+
+	t.Run("Window is burst over rps", func(t *testing.T) {
+		if l := NewLimiter(nil, "rl", 1, 60); l.window != time.Minute || l.limit != 60 {
+			t.Errorf("Expected 60 per minute; got %d per %s", l.limit, l.window)
+		}
+		if l := NewLimiter(nil, "rl", 100, 10); l.window != time.Second || l.limit != 100 {
+			t.Errorf("Expected 100 per second when rps is above burst; got %d per %s", l.limit, l.window)
+		}
+		if l := NewLimiter(nil, "rl", 2.5, 1); l.window != time.Second || l.limit != 3 {
+			t.Errorf("Expected a fractional rate rounded up; got %d per %s", l.limit, l.window)
+		}
+	})
+
+	t.Run("Allows up to the limit per window", func(t *testing.T) {
+		now := time.UnixMilli(10_000)
+		l, f := newLimiter(t, 3, &now)
+		for i := 0; i < 3; i++ {
+			if ok, _, _, _, err := l.Allow(context.Background(), "k"); !ok || err != nil {
+				t.Fatalf("Expected request %d to be allowed; got %v, %v", i+1, ok, err)
+			}
+		}
+		ok, remaining, wait, reset, err := l.Allow(context.Background(), "k")
+		if ok || err != nil {
+			t.Fatalf("Expected the 4th request to be rejected; got %v, %v", ok, err)
+		}
+		if wait <= 0 || wait > time.Second {
+			t.Errorf("Expected a wait within the window; got %s", wait)
+		}
+		// The 3 requests counted slide out at the end of the next window
+		if remaining != 0 || reset != 2*time.Second {
+			t.Errorf("Expected 0 remaining and a reset of 2s; got %d and %s", remaining, reset)
+		}
+		// Rejected requests are given back
+		if got := f.get("rl:k:10"); got != "3" {
+			t.Errorf("Expected a count of 3; got %q", got)
+		}
+		// Other keys have their own buckets
+		if ok, _, _, _, _ := l.Allow(context.Background(), "other"); !ok {
+			t.Error("Expected another key to be allowed")
+		}
+	})
+
+	t.Run("Allows rps per second when above burst", func(t *testing.T) {
+		now := time.UnixMilli(10_000)
+		f := newFakeServer(t)
+		c, _ := Open("redis://" + f.addr)
+		t.Cleanup(func() { c.Close() })
+		l := NewLimiter(c, "rl", 5, 2)
+		l.now = func() time.Time { return now }
+		for i := 0; i < 5; i++ {
+			if ok, _, _, _, err := l.Allow(context.Background(), "k"); !ok || err != nil {
+				t.Fatalf("Expected request %d to be allowed; got %v, %v", i+1, ok, err)
+			}
+		}
+		if ok, _, _, _, _ := l.Allow(context.Background(), "k"); ok {
+			t.Error("Expected the 6th request to be rejected")
+		}
+	})
+
+	t.Run("Weights the previous window", func(t *testing.T) {
+		now := time.UnixMilli(10_000)
+		l, _ := newLimiter(t, 4, &now)
+		for i := 0; i < 4; i++ {
+			l.Allow(context.Background(), "k")
+		}
+
+		// A quarter into the next window, 3 of the previous 4 still count
+		now = time.UnixMilli(11_250)
+		if ok, _, _, _, _ := l.Allow(context.Background(), "k"); !ok {
+			t.Fatal("Expected one more request to fit")
+		}
+		ok, _, wait, _, _ := l.Allow(context.Background(), "k")
+		if ok {
+			t.Fatal("Expected the sliding window to be full")
+		}
+		// One previous request slides out every 250ms
+		if wait != 250*time.Millisecond {
+			t.Errorf("Expected a wait of 250ms; got %s", wait)
+		}
+	})
+
+	t.Run("Reports the requests remaining", func(t *testing.T) {
+		now := time.UnixMilli(10_500)
+		l, _ := newLimiter(t, 3, &now)
+		ok, remaining, _, reset, _ := l.Allow(context.Background(), "k")
+		if !ok || remaining != 2 || reset != 1500*time.Millisecond {
+			t.Errorf("Expected 2 remaining and a reset of 1.5s; got %v, %d, %s", ok, remaining, reset)
+		}
+		if l.Limit() != 3 {
+			t.Errorf("Expected a limit of 3; got %d", l.Limit())
+		}
+	})
+
+	t.Run("Sets an expiry on counters", func(t *testing.T) {
+		now := time.UnixMilli(10_000)
+		l, f := newLimiter(t, 1, &now)
+		l.Allow(context.Background(), "k")
+		if got := f.ttls["rl:k:10"]; got != "2000" {
+			t.Errorf("Expected a ttl of two windows; got %q", got)
+		}
+	})
+}
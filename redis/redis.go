@@ -0,0 +1,292 @@
+/*
+	 Package redis is a minimal Redis client for the state replicas
+	 share, e.g. rate limits and leases electing a leader
+
+		It speaks RESP2 over a small pool of connections and supports
+		only what the server needs: commands and pipelines of them.
+*/
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of a Client opened from a URL
+const (
+	defaultTimeout  = 2 * time.Second
+	defaultPoolSize = 8
+)
+
+// Error is an error reply from the server, e.g. "WRONGTYPE ..."
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Returned for replies the client can't parse
+var errProtocol = errors.New("redis: protocol error")
+
+/*
+	 Client sends commands to one Redis server
+
+		Safe for concurrent use. Up to PoolSize idle connections are
+		kept for reuse; connections that fail are closed.
+*/
+type Client struct {
+	addr     string
+	password string
+	db       int
+	tls      bool
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+// struct to hold a connection and its buffered reader
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+/*
+	 Open returns a Client for a redis:// or rediss:// (TLS) URL
+
+		The URL may carry a password and database, e.g.
+		redis://:secret@localhost:6379/2. Connections are made on
+		first use.
+*/
+func Open(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	// Handle errors while parsing the URL
+	if err != nil {
+		return nil, fmt.Errorf("redis: could not parse %q: %w", rawURL, err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis: unsupported scheme %q, want redis or rediss", u.Scheme)
+	}
+	c := &Client{
+		addr:    u.Host,
+		tls:     u.Scheme == "rediss",
+		timeout: defaultTimeout,
+		pool:    make(chan *conn, defaultPoolSize),
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply, or the Error the server replied with
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(Error); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+/*
+	 Pipeline sends cmds in one round trip and returns their replies
+	 in order
+
+		Replies are string, int64, nil, []any or Error; an Error reply
+		fails only its own command, so it is returned among the
+		replies rather than as the error.
+*/
+func (c *Client) Pipeline(ctx context.Context, cmds ...[]string) ([]any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := cn.roundTrip(ctx, c.timeout, cmds)
+	if err != nil {
+		// The connection may hold half a reply, never reuse it
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Function to take an idle connection or dial a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	d := &net.Dialer{Timeout: c.timeout}
+	var nc net.Conn
+	var err error
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.addr)
+		nc, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	// Handle errors while connecting
+	if err != nil {
+		return nil, fmt.Errorf("redis: could not connect to %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	// Authenticate and select the database before first use
+	var setup [][]string
+	if c.password != "" {
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		replies, err := cn.roundTrip(ctx, c.timeout, setup)
+		if err == nil {
+			for _, reply := range replies {
+				if e, ok := reply.(Error); ok {
+					err = e
+				}
+			}
+		}
+		// Handle errors while setting up the connection
+		if err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// Function to return a healthy connection to the pool, closing it if full
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// Function to write cmds and read a reply for each, within ctx and timeout
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, cmds [][]string) ([]any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cn.SetDeadline(deadline)
+
+	var b []byte
+	for _, args := range cmds {
+		b = appendCommand(b, args)
+	}
+	if _, err := cn.Write(b); err != nil {
+		return nil, fmt.Errorf("redis: could not send command: %w", err)
+	}
+	replies := make([]any, len(cmds))
+	for i := range cmds {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, fmt.Errorf("redis: could not read reply: %w", err)
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// Function to append args as a RESP array of bulk strings
+func appendCommand(b []byte, args []string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, arg := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, arg...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// Function to read one RESP reply
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errProtocol
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return Error(body), nil
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		list := make([]any, n)
+		for i := range list {
+			if list[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, errProtocol
+}
@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url      string
+		addr     string
+		password string
+		db       int
+		tls      bool
+		wantErr  bool
+	}{
+		{"redis://localhost", "localhost:6379", "", 0, false, false},
+		{"redis://:secret@cache:6380/2", "cache:6380", "secret", 2, false, false},
+		{"rediss://cache", "cache:6379", "", 0, true, false},
+		{"http://cache", "", "", 0, false, true},
+		{"redis://cache/db", "", "", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			c, err := Open(tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if c.addr != tt.addr || c.password != tt.password || c.db != tt.db || c.tls != tt.tls {
+				t.Errorf("Unexpected client: %+v", c)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Sends commands and pipelines", func(t *testing.T) {
+		f := newFakeServer(t)
+		c, _ := Open("redis://" + f.addr)
+		defer c.Close()
+
+		if reply, err := c.Do(context.Background(), "PING"); err != nil || reply != "PONG" {
+			t.Errorf("Expected PONG; got %v, %v", reply, err)
+		}
+		replies, err := c.Pipeline(context.Background(),
+			[]string{"INCR", "n"},
+			[]string{"INCR", "n"},
+			[]string{"GET", "n"},
+			[]string{"GET", "missing"},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if replies[0] != int64(1) || replies[1] != int64(2) || replies[2] != "2" || replies[3] != nil {
+			t.Errorf("Unexpected replies: %#v", replies)
+		}
+	})
+
+	t.Run("Returns error replies", func(t *testing.T) {
+		f := newFakeServer(t)
+		c, _ := Open("redis://" + f.addr)
+		defer c.Close()
+
+		_, err := c.Do(context.Background(), "NOPE")
+		var e Error
+		if !errors.As(err, &e) || !strings.HasPrefix(string(e), "ERR unknown command") {
+			t.Errorf("Expected an ERR reply; got %v", err)
+		}
+	})
+
+	t.Run("Authenticates and selects the database", func(t *testing.T) {
+		f := newFakeServer(t)
+		f.password = "secret"
+		c, _ := Open("redis://:secret@" + f.addr + "/3")
+		defer c.Close()
+
+		if _, err := c.Do(context.Background(), "PING"); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if got := strings.Join(f.commands, ", "); got != "AUTH secret, SELECT 3, PING" {
+			t.Errorf("Expected AUTH and SELECT before PING; got %s", got)
+		}
+
+		bad, _ := Open("redis://:wrong@" + f.addr)
+		if _, err := bad.Do(context.Background(), "PING"); err == nil {
+			t.Error("Expected an error for a wrong password")
+		}
+	})
+
+	t.Run("Reuses connections", func(t *testing.T) {
+		f := newFakeServer(t)
+		f.password = "secret"
+		c, _ := Open("redis://:secret@" + f.addr)
+		defer c.Close()
+
+		c.Do(context.Background(), "PING")
+		c.Do(context.Background(), "PING")
+		if got := strings.Count(strings.Join(f.commands, ","), "AUTH"); got != 1 {
+			t.Errorf("Expected one connection; got %d", got)
+		}
+	})
+
+	t.Run("Fails without a server", func(t *testing.T) {
+		c, _ := Open("redis://127.0.0.1:1")
+		if _, err := c.Do(context.Background(), "PING"); err == nil {
+			t.Error("Expected a connection error")
+		}
+	})
+}
+
+func TestReadReply(t *testing.T) {
+	t.Parallel()
+
+	reply, err := readReply(bufio.NewReader(strings.NewReader("*3\r\n:1\r\n$3\r\nfoo\r\n*-1\r\n")))
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	list, ok := reply.([]any)
+	if !ok || len(list) != 3 || list[0] != int64(1) || list[1] != "foo" || list[2] != nil {
+		t.Errorf("Unexpected reply: %#v", reply)
+	}
+
+	for _, bad := range []string{"?x\r\n", ":x\r\n", "+OK\n"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(bad))); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+/*
+	 CBOR encodes values in CBOR (RFC 8949), with the field names and
+	 order of their JSON encoding
+
+		Integers and lengths use the shortest header; floats are always
+		64 bit. Times are strings, as in JSON. Streams are written as
+		an indefinite-length array.
+*/
+var CBOR = streaming(valueFormat("cbor", "application/cbor", nil, func(w io.Writer, v any) error {
+	b, err := appendCBOR(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}), func(w io.Writer) StreamEncoder {
+	return &cborStream{w: w}
+})
+
+// struct to hold an indefinite-length CBOR array being written an item at a time
+type cborStream struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *cborStream) Item(v any) error {
+	value, err := toValue(v)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	if !s.started {
+		s.started = true
+		b = append(b, 0x9f)
+	}
+	if b, err = appendCBOR(b, value); err != nil {
+		return err
+	}
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *cborStream) Close() error {
+	end := []byte{0xff}
+	if !s.started {
+		end = []byte{0x9f, 0xff}
+	}
+	_, err := s.w.Write(end)
+	return err
+}
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+)
+
+// Function to append v, a value from toValue, as CBOR
+func appendCBOR(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xf6), nil
+	case bool:
+		if v {
+			return append(b, 0xf5), nil
+		}
+		return append(b, 0xf4), nil
+	case int64:
+		if v < 0 {
+			return appendCBORHeader(b, cborNegInt, uint64(-1-v)), nil
+		}
+		return appendCBORHeader(b, cborUint, uint64(v)), nil
+	case uint64:
+		return appendCBORHeader(b, cborUint, v), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xfb), math.Float64bits(v)), nil
+	case string:
+		b = appendCBORHeader(b, cborText, uint64(len(v)))
+		return append(b, v...), nil
+	case []any:
+		b = appendCBORHeader(b, cborArray, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if b, err = appendCBOR(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case object:
+		b = appendCBORHeader(b, cborMap, uint64(len(v)))
+		for _, m := range v {
+			var err error
+			if b, err = appendCBOR(b, m.key); err != nil {
+				return nil, err
+			}
+			if b, err = appendCBOR(b, m.value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("render: cannot encode %T as CBOR", v)
+}
+
+// Function to append the header of major type major with argument n
+func appendCBORHeader(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(b, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, m|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, m|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, m|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, m|27), n)
+	}
+}
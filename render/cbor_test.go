@@ -0,0 +1,52 @@
+package render
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCBOR(t *testing.T) {
+	t.Parallel()
+
+	// Expected encodings from Appendix A of RFC 8949
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"0", 0, "00"},
+		{"23", 23, "17"},
+		{"24", 24, "1818"},
+		{"1000", 1000, "1903e8"},
+		{"1000000", 1000000, "1a000f4240"},
+		{"1000000000000", 1000000000000, "1b000000e8d4a51000"},
+		{"Max uint64", uint64(18446744073709551615), "1bffffffffffffffff"},
+		{"-1", -1, "20"},
+		{"-1000", -1000, "3903e7"},
+		{"1.1", 1.1, "fb3ff199999999999a"},
+		{"false", false, "f4"},
+		{"true", true, "f5"},
+		{"null", nil, "f6"},
+		{"Empty string", "", "60"},
+		{"\"IETF\"", "IETF", "6449455446"},
+		{"Array", []int{1, 2, 3}, "83010203"},
+		{"Nested", []any{1, []int{2, 3}, []int{4, 5}}, "8301820203820405"},
+		{"Map", struct {
+			A int   `json:"a"`
+			B []int `json:"b"`
+		}{1, []int{2, 3}}, "a26161016162820203"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := CBOR.Encode(&buf, tt.v); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+				t.Errorf("Expected %s; got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+/*
+	 CSV writes lists of objects as CSV, a header line of the first
+	 item's field names then a row per item
+
+		Later items are written in the header's columns, so fields the
+		first item lacks are left out. Nested values are written as
+		JSON, and a list that isn't of objects as one column with no
+		header. Nothing is written for an empty list, as there are no
+		fields to name.
+*/
+var CSV = streaming(Format{
+	Name:        "csv",
+	ContentType: "text/csv; charset=utf-8",
+	Encode: func(w io.Writer, v any) error {
+		value, err := toValue(v)
+		if err != nil {
+			return err
+		}
+		s := &csvStream{w: csv.NewWriter(w)}
+		list, ok := value.([]any)
+		if !ok {
+			list = []any{value}
+		}
+		for _, item := range list {
+			if err := s.row(item); err != nil {
+				return err
+			}
+		}
+		return s.Close()
+	},
+}, func(w io.Writer) StreamEncoder {
+	return &csvStream{w: csv.NewWriter(w)}
+})
+
+// struct to hold a CSV stream and the columns named by its header
+type csvStream struct {
+	w      *csv.Writer
+	header []string
+}
+
+func (s *csvStream) Item(v any) error {
+	value, err := toValue(v)
+	if err != nil {
+		return err
+	}
+	return s.row(value)
+}
+
+func (s *csvStream) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Function to write v, a value from toValue, as a row, with the header first
+func (s *csvStream) row(v any) error {
+	var record []string
+	if obj, ok := v.(object); ok {
+		if s.header == nil {
+			for _, m := range obj {
+				s.header = append(s.header, m.key)
+			}
+			if err := s.w.Write(s.header); err != nil {
+				return err
+			}
+		}
+		record = make([]string, len(s.header))
+		for i, key := range s.header {
+			for _, m := range obj {
+				if m.key == key {
+					cell, err := csvCell(m.value)
+					if err != nil {
+						return err
+					}
+					record[i] = cell
+					break
+				}
+			}
+		}
+	} else {
+		cell, err := csvCell(v)
+		if err != nil {
+			return err
+		}
+		record = []string{cell}
+	}
+	if err := s.w.Write(record); err != nil {
+		return err
+	}
+	// Send each row as it is written
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Function to format v, a value from toValue, as a CSV cell
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	// Nested objects and lists
+	data, err := json.Marshal(v)
+	return string(data), err
+}
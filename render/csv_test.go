@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	t.Parallel()
+
+	type row struct {
+		ID   int      `json:"id"`
+		Joke string   `json:"joke"`
+		Tags []string `json:"tags,omitempty"`
+		Note *string  `json:"note,omitempty"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"Header then rows", []row{{1, "one", nil, nil}, {2, "two, \"quoted\"", nil, nil}},
+			"id,joke\n1,one\n2,\"two, \"\"quoted\"\"\"\n"},
+		{"Single object", row{ID: 1, Joke: "one"}, "id,joke\n1,one\n"},
+		{"Nested values as JSON", []row{{1, "one", []string{"a", "b"}, nil}}, "id,joke,tags\n1,one,\"[\"\"a\"\",\"\"b\"\"]\"\n"},
+		{"Fields missing from the header are left out", []any{row{ID: 1}, row{ID: 2, Tags: []string{"a"}}},
+			"id,joke\n1,\n2,\n"},
+		{"Scalars", []any{"a", 1, true, nil}, "a\n1\ntrue\n\n"},
+		{"Empty list", []row{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := CSV.Encode(&buf, tt.v); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Expected %q; got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Streams rows as they are written", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s := NewStream(rec, CSV)
+		s.Write(row{ID: 1, Joke: "one"})
+		if got := rec.Body.String(); got != "id,joke\n1,one\n" {
+			t.Errorf("Expected the header and first row before Close; got %q", got)
+		}
+		s.Close()
+	})
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+/*
+	 MsgPack encodes values in MessagePack, with the field names and
+	 order of their JSON encoding
+
+		Times are strings, as in JSON.
+*/
+var MsgPack = valueFormat("msgpack", "application/msgpack", []string{"application/x-msgpack"}, func(w io.Writer, v any) error {
+	b, err := appendMsgPack(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+})
+
+// Function to append v, a value from toValue, as MessagePack
+func appendMsgPack(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int64:
+		return appendMsgPackInt(b, v), nil
+	case uint64:
+		return appendMsgPackUint(b, v), nil
+	case float64:
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v)), nil
+	case string:
+		b = appendMsgPackHeader(b, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		return append(b, v...), nil
+	case []any:
+		b = appendMsgPackHeader(b, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			var err error
+			if b, err = appendMsgPack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case object:
+		b = appendMsgPackHeader(b, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, m := range v {
+			var err error
+			if b, err = appendMsgPack(b, m.key); err != nil {
+				return nil, err
+			}
+			if b, err = appendMsgPack(b, m.value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("render: cannot encode %T as msgpack", v)
+}
+
+/*
+	 Function to append the header of a string, array or map of n items
+
+		fix is the fixed-size type used below fixMax, then the 8, 16
+		and 32 bit length types follow; arrays and maps have no 8 bit
+		form and pass 0.
+*/
+func appendMsgPackHeader(b []byte, n int, fix byte, fixMax int, t8, t16, t32 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case t8 != 0 && n <= math.MaxUint8:
+		return append(b, t8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, t16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, t32), uint32(n))
+	}
+}
+
+// Function to append v in the smallest MessagePack integer type
+func appendMsgPackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgPackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// Function to append v in the smallest MessagePack unsigned type
+func appendMsgPackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMsgPack(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"Nil", nil, "c0"},
+		{"Bools", []bool{true, false}, "92c3c2"},
+		{"Positive fixint", 127, "7f"},
+		{"Negative fixint", -32, "e0"},
+		{"uint8", 200, "ccc8"},
+		{"uint16", 65535, "cdffff"},
+		{"uint32", 1 << 20, "ce00100000"},
+		{"uint64", uint64(1) << 63, "cf8000000000000000"},
+		{"int8", -100, "d09c"},
+		{"int16", -1000, "d1fc18"},
+		{"int32", -100000, "d2fffe7960"},
+		{"float64", 0.5, "cb3fe0000000000000"},
+		{"fixstr", "hi", "a26869"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"Map keeps field order", struct {
+			B int `json:"b"`
+			A int `json:"a"`
+		}{1, 2}, "82a16201a16102"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := MsgPack.Encode(&buf, tt.v); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+				t.Errorf("Expected %s; got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Long headers", func(t *testing.T) {
+		list := make([]int, 16)
+		b, _ := appendMsgPack(nil, mustValue(t, list))
+		if hex.EncodeToString(b[:3]) != "dc0010" {
+			t.Errorf("Expected array16 header; got %x", b[:3])
+		}
+		long := strings.Repeat("a", 256)
+		b, _ = appendMsgPack(nil, long)
+		if hex.EncodeToString(b[:3]) != "da0100" {
+			t.Errorf("Expected str16 header; got %x", b[:3])
+		}
+	})
+}
+
+// Function to convert v with toValue, failing the test on error
+func mustValue(t *testing.T, v any) any {
+	t.Helper()
+	value, err := toValue(v)
+	if err != nil {
+		t.Fatalf("Could not convert %v: %v", v, err)
+	}
+	return value
+}
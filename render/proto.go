@@ -0,0 +1,43 @@
+package render
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+	 ProtoMessage is a value encoded in protobuf as a message
+	 generated from proto/joke/v1/joke.proto
+
+		Proto converts the value to that message, so the wire format
+		always follows the schema.
+*/
+type ProtoMessage interface {
+	Proto() proto.Message
+}
+
+// Protobuf encodes proto.Messages and ProtoMessages in the protobuf binary wire format
+var Protobuf = Format{
+	Name:        "protobuf",
+	ContentType: "application/x-protobuf",
+	Encode: func(w io.Writer, v any) error {
+		m, ok := v.(proto.Message)
+		if p, isProto := v.(ProtoMessage); !ok && isProto {
+			m, ok = p.Proto(), true
+		}
+		if !ok {
+			return errNotProto
+		}
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	},
+}
+
+// Returned when encoding a value that is neither a proto.Message nor a ProtoMessage as protobuf
+var errNotProto = errors.New("render: value is not a protobuf message")
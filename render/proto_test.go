@@ -0,0 +1,40 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// struct converting itself to a message, as response types do
+type testResponse struct {
+	id int64
+}
+
+func (r testResponse) Proto() proto.Message {
+	return wrapperspb.Int64(r.id)
+}
+
+func TestProtobufFormat(t *testing.T) {
+	t.Parallel()
+
+	// The varint example from the protobuf encoding guide
+	want := []byte{0x08, 0x96, 0x01}
+	for _, v := range []any{wrapperspb.Int64(150), testResponse{id: 150}} {
+		rec := httptest.NewRecorder()
+		if err := Write(rec, http.StatusOK, Protobuf, v); err != nil {
+			t.Fatalf("%T: expected no error; got %v", v, err)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), want) || rec.Header().Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("%T: unexpected response %x with headers %v", v, rec.Body.Bytes(), rec.Header())
+		}
+	}
+
+	if err := Protobuf.Encode(&bytes.Buffer{}, "not a message"); err == nil {
+		t.Error("Expected error encoding a non-message")
+	}
+}
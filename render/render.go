@@ -0,0 +1,180 @@
+/*
+	 Package render negotiates response formats and encodes responses
+	 in them
+
+		Handlers list the Formats they offer, Negotiate picks one from
+		the request and Write encodes the response with it.
+*/
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Format is an encoding a response can be served in
+type Format struct {
+	// Name selects the format with ?format=, e.g. "json"
+	Name        string
+	ContentType string
+	// Aliases are other content types accepted for the format
+	Aliases []string
+	Encode  func(w io.Writer, v any) error
+	// NewStream writes lists an item at a time, see Stream; formats
+	// without one are buffered
+	NewStream func(w io.Writer) StreamEncoder
+}
+
+// JSON encodes values with encoding/json, streaming lists as an array
+var JSON = Format{
+	Name:        "json",
+	ContentType: "application/json",
+	Encode: func(w io.Writer, v any) error {
+		return json.NewEncoder(w).Encode(v)
+	},
+	NewStream: func(w io.Writer) StreamEncoder {
+		return &jsonStream{w: w}
+	},
+}
+
+/*
+	 Text writes strings and fmt.Stringers as plain text, streaming
+	 lists one item per line
+
+		Servers sniffed this content type for plain jokes before
+		formats were negotiated, so it is set explicitly to match.
+*/
+var Text = Format{
+	Name:        "text",
+	ContentType: "text/plain; charset=utf-8",
+	Encode: func(w io.Writer, v any) error {
+		switch v := v.(type) {
+		case string:
+			_, err := io.WriteString(w, v)
+			return err
+		case fmt.Stringer:
+			_, err := io.WriteString(w, v.String())
+			return err
+		}
+		return fmt.Errorf("render: cannot write %T as text", v)
+	},
+	NewStream: func(w io.Writer) StreamEncoder {
+		return textStream{w: w}
+	},
+}
+
+/*
+	 Negotiate returns the offer the request prefers
+
+		A ?format= query value naming an offer wins. Otherwise offers
+		are ranked by the Accept header's quality values, ties going to
+		the earlier offer, and a missing Accept header selects the
+		first offer. It returns false when nothing offered is
+		acceptable.
+*/
+func Negotiate(r *http.Request, offers ...Format) (Format, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range offers {
+			if f.Name == name {
+				return f, true
+			}
+		}
+		return Format{}, false
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0], true
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best, bestQ := Format{}, 0.0
+	for _, f := range offers {
+		for _, ct := range append([]string{f.ContentType}, f.Aliases...) {
+			if q := quality(ranges, ct); q > bestQ {
+				best, bestQ = f, q
+			}
+		}
+	}
+	return best, bestQ > 0
+}
+
+/*
+	 Write encodes v in f with status
+
+		Errors are those of the encoder; the status is already sent,
+		so callers can only log them.
+*/
+func Write(w http.ResponseWriter, status int, f Format, v any) error {
+	WriteHeader(w, status, f)
+	return f.Encode(w, v)
+}
+
+// WriteHeader sends the headers of a response in f with status, for
+// HEAD requests answered without building the body
+func WriteHeader(w http.ResponseWriter, status int, f Format) {
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+}
+
+// struct to hold one media range of an Accept header
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// Function to parse the media ranges of an Accept header
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+/*
+	 Function to return the quality ranges give contentType
+
+		The most specific matching range applies, so text/csv beats a
+		wildcard with a lower q.
+*/
+func quality(ranges []mediaRange, contentType string) float64 {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	typ, subtype, _ := strings.Cut(strings.TrimSpace(mediaType), "/")
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
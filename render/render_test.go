@@ -0,0 +1,87 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	offers := []Format{Text, JSON, Protobuf, MsgPack}
+
+	tests := []struct {
+		name   string
+		accept string
+		query  string
+		want   string
+		ok     bool
+	}{
+		{"No Accept picks the default", "", "", "text", true},
+		{"Exact type", "application/x-protobuf", "", "protobuf", true},
+		{"Highest quality wins", "text/plain;q=0.5, application/json", "", "json", true},
+		{"Ties go to the earlier offer", "application/json, text/plain", "", "text", true},
+		{"Specific range beats wildcard", "*/*;q=0.1, application/x-protobuf", "", "protobuf", true},
+		{"Type wildcard", "application/*", "", "json", true},
+		{"Browser Accept", "text/html,application/xhtml+xml,*/*;q=0.8", "", "text", true},
+		{"Refused with q=0", "text/plain;q=0, */*;q=0.1", "", "json", true},
+		{"Alias", "application/x-msgpack", "", "msgpack", true},
+		{"Nothing acceptable", "image/png", "", "", false},
+		{"Format query wins", "application/json", "protobuf", "protobuf", true},
+		{"Unknown format query", "", "xml", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?format="+tt.query, nil)
+			if tt.query == "" {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			got, ok := Negotiate(req, offers...)
+			if ok != tt.ok || got.Name != tt.want {
+				t.Errorf("Expected %q, %v; got %q, %v", tt.want, tt.ok, got.Name, ok)
+			}
+		})
+	}
+}
+
+// struct to test encoding a fmt.Stringer as text
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Sets headers and encodes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		if err := Write(rec, http.StatusCreated, JSON, map[string]string{"joke": "hi"}); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Unexpected response %d %v", rec.Code, rec.Header())
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != `{"joke":"hi"}` {
+			t.Errorf("Unexpected body %q", body)
+		}
+	})
+
+	t.Run("Writes text", func(t *testing.T) {
+		for v, want := range map[any]string{"plain": "plain", stringer{}: "stringer"} {
+			rec := httptest.NewRecorder()
+			Write(rec, http.StatusOK, Text, v)
+			if rec.Body.String() != want {
+				t.Errorf("Expected %q; got %q", want, rec.Body.String())
+			}
+		}
+		if err := Write(httptest.NewRecorder(), http.StatusOK, Text, 42); err == nil {
+			t.Error("Expected error writing an int as text")
+		}
+	})
+}
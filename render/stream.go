@@ -0,0 +1,145 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+/*
+	 StreamEncoder writes a list one item at a time
+
+		Item encodes the next item and Close ends the list, so clients
+		can read items before the last one is ready.
+*/
+type StreamEncoder interface {
+	Item(v any) error
+	Close() error
+}
+
+/*
+	 Stream writes a list response in a Format, flushing each item as
+	 it is written
+
+		Formats without a NewStream encoder are buffered and encoded
+		as one list on Close. Headers are sent with the first item, so
+		callers can still write an error response while Started is
+		false.
+*/
+type Stream struct {
+	w       http.ResponseWriter
+	f       Format
+	enc     StreamEncoder
+	started bool
+}
+
+// NewStream returns a Stream writing a 200 response in f to w
+func NewStream(w http.ResponseWriter, f Format) *Stream {
+	return &Stream{w: w, f: f}
+}
+
+// Started reports whether the response headers have been sent
+func (s *Stream) Started() bool {
+	return s.started
+}
+
+// Write encodes v as the next item and flushes it to the client
+func (s *Stream) Write(v any) error {
+	s.start()
+	if err := s.enc.Item(v); err != nil {
+		return err
+	}
+	// Writers that can't flush still get the item when the response ends
+	http.NewResponseController(s.w).Flush()
+	return nil
+}
+
+// Close ends the list, sending an empty one if nothing was written
+func (s *Stream) Close() error {
+	s.start()
+	return s.enc.Close()
+}
+
+// Function to send the headers and open the encoder on first use
+func (s *Stream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	WriteHeader(s.w, http.StatusOK, s.f)
+	if s.f.NewStream != nil {
+		s.enc = s.f.NewStream(s.w)
+	} else {
+		s.enc = &bufferedStream{w: s.w, f: s.f}
+	}
+}
+
+// struct to hold the items of a format that can't stream until Close
+type bufferedStream struct {
+	w     io.Writer
+	f     Format
+	items []any
+}
+
+func (b *bufferedStream) Item(v any) error {
+	b.items = append(b.items, v)
+	return nil
+}
+
+func (b *bufferedStream) Close() error {
+	return b.f.Encode(b.w, append([]any{}, b.items...))
+}
+
+// Function to return f with streams written by newStream
+func streaming(f Format, newStream func(w io.Writer) StreamEncoder) Format {
+	f.NewStream = newStream
+	return f
+}
+
+// struct to hold a JSON array being written an element at a time
+type jsonStream struct {
+	w io.Writer
+	n int
+}
+
+func (s *jsonStream) Item(v any) error {
+	var buf bytes.Buffer
+	if s.n == 0 {
+		buf.WriteString("[\n")
+	} else {
+		buf.WriteString(",\n")
+	}
+	s.n++
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	_, err = s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *jsonStream) Close() error {
+	end := "\n]\n"
+	if s.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// struct to hold a plain text stream, one item per line
+type textStream struct {
+	w io.Writer
+}
+
+func (s textStream) Item(v any) error {
+	if err := Text.Encode(s.w, v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, "\n")
+	return err
+}
+
+func (s textStream) Close() error { return nil }
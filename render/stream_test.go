@@ -0,0 +1,77 @@
+package render
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	type joke struct {
+		Joke string `json:"joke"`
+	}
+	items := []any{joke{"one"}, joke{"two"}}
+
+	tests := []struct {
+		name  string
+		f     Format
+		items []any
+		want  string
+	}{
+		{"JSON", JSON, items, "[\n{\"joke\":\"one\"},\n{\"joke\":\"two\"}\n]\n"},
+		{"Empty JSON", JSON, nil, "[]\n"},
+		{"YAML", YAML, items, "- joke: one\n- joke: two\n"},
+		{"Empty YAML", YAML, nil, "[]\n"},
+		{"Text", Text, []any{"one", "two"}, "one\ntwo\n"},
+		{"Buffered", MsgPack, items, "\x92\x81\xa4joke\xa3one\x81\xa4joke\xa3two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s := NewStream(rec, tt.f)
+			for _, item := range tt.items {
+				if err := s.Write(item); err != nil {
+					t.Fatalf("Expected no error; got %v", err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("Expected %q; got %q", tt.want, got)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.f.ContentType {
+				t.Errorf("Expected Content-Type %s; got %s", tt.f.ContentType, ct)
+			}
+		})
+	}
+
+	t.Run("CBOR is an indefinite-length array", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s := NewStream(rec, CBOR)
+		s.Write(1)
+		s.Write(2)
+		s.Close()
+		if got := hex.EncodeToString(rec.Body.Bytes()); got != "9f0102ff" {
+			t.Errorf("Expected 9f0102ff; got %s", got)
+		}
+	})
+
+	t.Run("Items are flushed as they are written", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s := NewStream(rec, JSON)
+		if s.Started() {
+			t.Fatal("Expected stream not to start before the first item")
+		}
+		s.Write(joke{"one"})
+		if !rec.Flushed {
+			t.Error("Expected the first item to be flushed")
+		}
+		if !s.Started() {
+			t.Error("Expected stream to have started")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+/*
+	 object is a JSON object with its members in document order
+
+		Binary and text formats built on values keep the field order
+		of the JSON encoding.
+*/
+type object []member
+
+// MarshalJSON encodes o as a JSON object, keeping its member order
+func (o object) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, m := range o {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(append(b, key...), ':'), value...)
+	}
+	return append(b, '}'), nil
+}
+
+// struct to hold one member of an object
+type member struct {
+	key   string
+	value any
+}
+
+/*
+	 Function to convert v into the values its JSON encoding describes
+
+		Values are nil, bool, int64, uint64, float64, string, []any and
+		object, so formats other than JSON reuse the json struct tags
+		and custom marshalers of every response type.
+*/
+func toValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readValue(dec)
+}
+
+// Function to read the next value from dec
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[':
+			list := []any{}
+			for dec.More() {
+				v, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := dec.Token()
+			return list, err
+		case '{':
+			obj := object{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, member{key: key.(string), value: v})
+			}
+			_, err := dec.Token()
+			return obj, err
+		}
+		return nil, fmt.Errorf("render: unexpected %v", tok)
+	case json.Number:
+		return number(tok)
+	default:
+		// nil, bool or string
+		return tok, nil
+	}
+}
+
+// Function to convert n to an int64, uint64 or float64
+func number(n json.Number) (any, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u, nil
+	}
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Function to build a Format encoding values with write
+func valueFormat(name, contentType string, aliases []string, write func(w io.Writer, v any) error) Format {
+	return Format{
+		Name:        name,
+		ContentType: contentType,
+		Aliases:     aliases,
+		Encode: func(w io.Writer, v any) error {
+			value, err := toValue(v)
+			if err != nil {
+				return err
+			}
+			return write(w, value)
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToValue(t *testing.T) {
+	t.Parallel()
+
+	type entry struct {
+		Zebra  string    `json:"zebra"`
+		Apple  int       `json:"apple"`
+		Big    uint64    `json:"big"`
+		Ratio  float64   `json:"ratio"`
+		Tags   []string  `json:"tags"`
+		Hidden string    `json:"-"`
+		When   time.Time `json:"when"`
+		None   *int      `json:"none"`
+	}
+
+	got, err := toValue(entry{
+		Zebra: "z", Apple: -2, Big: 1 << 63, Ratio: 0.5, Tags: []string{"a"}, Hidden: "h",
+		When: time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	// Members keep the struct's field order and JSON names
+	want := object{
+		{"zebra", "z"},
+		{"apple", int64(-2)},
+		{"big", uint64(1 << 63)},
+		{"ratio", 0.5},
+		{"tags", []any{"a"}},
+		{"when", "2024-01-02T03:04:05Z"},
+		{"none", nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v; got %#v", want, got)
+	}
+}
@@ -0,0 +1,178 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+	 YAML encodes values as block-style YAML, with the field names and
+	 order of their JSON encoding
+
+		Streams are written as a top-level sequence, one item at a time.
+*/
+var YAML = streaming(valueFormat("yaml", "application/yaml", []string{"application/x-yaml", "text/yaml"}, func(w io.Writer, v any) error {
+	var b bytes.Buffer
+	if err := writeYAML(&b, v, 0, false); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}), func(w io.Writer) StreamEncoder {
+	return &yamlStream{w: w}
+})
+
+// struct to hold a top-level YAML sequence being written an item at a time
+type yamlStream struct {
+	w io.Writer
+	n int
+}
+
+func (s *yamlStream) Item(v any) error {
+	value, err := toValue(v)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := writeYAMLItem(&b, value, 0); err != nil {
+		return err
+	}
+	s.n++
+	_, err = s.w.Write(b.Bytes())
+	return err
+}
+
+func (s *yamlStream) Close() error {
+	if s.n > 0 {
+		return nil
+	}
+	_, err := io.WriteString(s.w, "[]\n")
+	return err
+}
+
+// Function to write v as a YAML block at indent, the first line
+// already started when inline
+func writeYAML(b *bytes.Buffer, v any, indent int, inline bool) error {
+	switch v := v.(type) {
+	case object:
+		if len(v) == 0 {
+			b.WriteString("{}\n")
+			return nil
+		}
+		for i, m := range v {
+			if i > 0 || !inline {
+				pad(b, indent)
+			}
+			b.WriteString(yamlScalar(m.key))
+			b.WriteByte(':')
+			if err := writeYAMLValue(b, m.value, indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]\n")
+			return nil
+		}
+		for i, item := range v {
+			if i > 0 || !inline {
+				pad(b, indent)
+			}
+			if err := writeYAMLItem(b, item, indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := yamlValue(v)
+	if err != nil {
+		return err
+	}
+	b.WriteString(s + "\n")
+	return nil
+}
+
+// Function to write v after a mapping key at indent
+func writeYAMLValue(b *bytes.Buffer, v any, indent int) error {
+	if isCollection(v) {
+		b.WriteByte('\n')
+		return writeYAML(b, v, indent+2, false)
+	}
+	b.WriteByte(' ')
+	return writeYAML(b, v, indent, true)
+}
+
+// Function to write v as a sequence item at indent, the indent already written
+func writeYAMLItem(b *bytes.Buffer, v any, indent int) error {
+	b.WriteString("- ")
+	return writeYAML(b, v, indent+2, true)
+}
+
+// Function to report whether v is a non-empty object or list
+func isCollection(v any) bool {
+	switch v := v.(type) {
+	case object:
+		return len(v) > 0
+	case []any:
+		return len(v) > 0
+	}
+	return false
+}
+
+// Function to write n spaces
+func pad(b *bytes.Buffer, n int) {
+	b.WriteString(strings.Repeat(" ", n))
+}
+
+// Function to format a scalar value from toValue
+func yamlValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return yamlScalar(v), nil
+	}
+	return "", fmt.Errorf("render: cannot encode %T as YAML", v)
+}
+
+// Strings that are safe to write unquoted
+var plainYAML = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ .,;'/()!?+=-]*$`)
+
+// Plain strings a YAML parser would read as something other than a string
+var reservedYAML = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true,
+}
+
+/*
+	 Function to format s as a YAML string
+
+		Strings are plain when unambiguous and double-quoted otherwise,
+		with JSON escapes, which YAML double-quoted scalars accept.
+*/
+func yamlScalar(s string) string {
+	if plainYAML.MatchString(s) && !strings.HasSuffix(s, " ") && !strings.Contains(s, ": ") &&
+		!reservedYAML[strings.ToLower(s)] {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// Strings always encode
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	t.Parallel()
+
+	type name struct {
+		First string `json:"first"`
+		Last  string `json:"last"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"Plain string", "Chuck Norris counted to infinity", "Chuck Norris counted to infinity\n"},
+		{"Reserved words are quoted", []string{"yes", "Null", "off"}, "- \"yes\"\n- \"Null\"\n- \"off\"\n"},
+		{"Numbers as strings are quoted", "42", "\"42\"\n"},
+		{"Colons are quoted", "joke: punchline", "\"joke: punchline\"\n"},
+		{"Escapes are quoted", "line\nbreak \"x\"", "\"line\\nbreak \\\"x\\\"\"\n"},
+		{"Empty string", "", "\"\"\n"},
+		{"Scalars", []any{nil, true, -3, 0.5}, "- null\n- true\n- -3\n- 0.5\n"},
+		{"Map keeps field order", struct {
+			B int `json:"b"`
+			A int `json:"a"`
+		}{1, 2}, "b: 1\na: 2\n"},
+		{"Nested", struct {
+			Names []name   `json:"names"`
+			Tags  []int    `json:"tags"`
+			Empty []int    `json:"empty"`
+			None  struct{} `json:"none"`
+		}{Names: []name{{"John", "Doe"}, {"Jane", "Roe"}}, Tags: []int{1}, Empty: []int{}},
+			"names:\n  - first: John\n    last: Doe\n  - first: Jane\n    last: Roe\ntags:\n  - 1\nempty: []\nnone: {}\n"},
+		{"Nested lists", [][]int{{1, 2}, {3}}, "- - 1\n  - 2\n- - 3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := YAML.Encode(&buf, tt.v); err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Expected %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+/*
+	 Package s3 uploads objects to Amazon S3 and S3-compatible stores
+	 such as MinIO
+
+		Only PutObject is supported, signed with AWS Signature Version 4
+		and addressed path-style so custom endpoints work without DNS
+		for every bucket.
+*/
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layout of the X-Amz-Date header
+const amzDate = "20060102T150405Z"
+
+// Config describes the bucket objects are written to
+type Config struct {
+	// Endpoint is the store's base URL, defaulting to AWS for Region
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+/*
+	 FromEnv returns a Config for bucket using the standard AWS
+	 environment variables
+
+		AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION
+		(default us-east-1) are read, and S3_ENDPOINT overrides the
+		endpoint for S3-compatible stores.
+*/
+func FromEnv(bucket string) Config {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+}
+
+// Client writes objects to one bucket
+type Client struct {
+	cfg Config
+	now func() time.Time
+}
+
+// New returns a Client for cfg
+func New(cfg Config) *Client {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Client{cfg: cfg, now: time.Now}
+}
+
+// Put uploads body to key, replacing any object already there
+func (c *Client) Put(ctx context.Context, key, contentType string, body []byte) error {
+	u := c.cfg.Endpoint + "/" + escapePath(c.cfg.Bucket+"/"+key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("s3: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	sign(req, hashHex(body), c.cfg, c.now())
+
+	res, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3: could not put %s: %w", key, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("s3: could not put %s: status %d: %s", key, res.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+/*
+	 Function to sign req with AWS Signature Version 4
+
+		Signs the Host header, Content-Type, Range and every x-amz-*
+		header. payloadHash is the hex SHA-256 of the body.
+*/
+func sign(req *http.Request, payloadHash string, cfg Config, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDate))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers, lowercased and sorted
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	day := now.Format("20060102")
+	scope := day + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(amzDate),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretKey), day)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// Function to return query sorted and escaped as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Function to escape each segment of path, keeping the slashes
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Function to percent-encode s, leaving only unreserved characters
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// Function to return the hex SHA-256 of b
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Function to return the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,185 @@
+package safety
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	 Blocklist blocks jokes containing any of the entries of a file,
+	 reloaded when it changes
+
+		The file has one entry per line: a word or phrase, matched whole
+		and case-insensitively, or a regular expression between slashes,
+		such as /(?i)\bdarn(ed)?\b/. Blank lines and lines starting with
+		# are skipped. As a Scorer it gives a blocked joke 1 and any
+		other 0, so it blocks at every threshold. Build one with
+		LoadBlocklist.
+*/
+type Blocklist struct {
+	path    string
+	observe func()
+	logger  *slog.Logger
+
+	mu      sync.RWMutex
+	entries []*regexp.Regexp
+	modTime time.Time
+	hooks   []func()
+}
+
+/*
+	 LoadBlocklist returns the blocklist in the file at path
+
+		observe, when not nil, is called for every joke blocked.
+*/
+func LoadBlocklist(path string, observe func(), logger *slog.Logger) (*Blocklist, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	b := &Blocklist{path: path, observe: observe, logger: logger}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+/*
+	 OnReload calls fn after every reload from now on, e.g. to drop
+	 cached jokes the new entries block
+
+		Register hooks before calling Watch.
+*/
+func (b *Blocklist) OnReload(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hooks = append(b.hooks, fn)
+}
+
+// Len returns the number of entries
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.entries)
+}
+
+// Blocked reports whether text contains an entry
+func (b *Blocklist) Blocked(text string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, re := range b.entries {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Score returns 1 when text contains an entry, counting it as blocked, and 0 otherwise
+func (b *Blocklist) Score(ctx context.Context, text string) (float64, error) {
+	if !b.Blocked(text) {
+		return 0, nil
+	}
+	if b.observe != nil {
+		b.observe()
+	}
+	return 1, nil
+}
+
+/*
+	 Reload reads the file again
+
+		On error the current entries are kept.
+*/
+func (b *Blocklist) Reload() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return fmt.Errorf("safety: could not stat %s: %w", b.path, err)
+	}
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return fmt.Errorf("safety: could not read %s: %w", b.path, err)
+	}
+	entries, err := parseBlocklist(data)
+	if err != nil {
+		return fmt.Errorf("safety: could not parse %s: %w", b.path, err)
+	}
+
+	b.mu.Lock()
+	b.entries = entries
+	b.modTime = info.ModTime()
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// Function to compile each entry of a blocklist file
+func parseBlocklist(data []byte) ([]*regexp.Regexp, error) {
+	var entries []*regexp.Regexp
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr := `(?i)\b` + regexp.QuoteMeta(line) + `\b`
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			expr = line[1 : len(line)-1]
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, re)
+	}
+	return entries, sc.Err()
+}
+
+/*
+	 Watch reloads the file every interval when its modification time
+	 changes, until ctx is cancelled
+
+		Reload errors are logged and the previous entries kept, so a
+		half-written file never unblocks every entry.
+*/
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Skip the reload when the file is unchanged
+		info, err := os.Stat(b.path)
+		if err != nil {
+			b.logger.WarnContext(ctx, "safety: could not stat blocklist", "path", b.path, "error", err)
+			continue
+		}
+		b.mu.RLock()
+		unchanged := info.ModTime().Equal(b.modTime)
+		b.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+
+		if err := b.Reload(); err != nil {
+			b.logger.WarnContext(ctx, "safety: keeping previous blocklist", "error", err)
+			continue
+		}
+		b.logger.InfoContext(ctx, "safety: reloaded blocklist", "path", b.path, "entries", b.Len())
+	}
+}
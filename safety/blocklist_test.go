@@ -0,0 +1,95 @@
+package safety
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Function to write a blocklist file, creating one when path is empty
+func writeBlocklist(t *testing.T, path, contents string) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "blocklist.txt")
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Could not write blocklist: %v", err)
+	}
+	return path
+}
+
+func TestBlocklist(t *testing.T) {
+	t.Parallel()
+
+	var blocked atomic.Int64
+	path := writeBlocklist(t, "", "# words\ndarn\n\nfor crying out loud\n/(?i)heck+/\n")
+	b, err := LoadBlocklist(path, func() { blocked.Add(1) }, nil)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if b.Len() != 3 {
+		t.Errorf("Expected 3 entries; got %d", b.Len())
+	}
+
+	for text, want := range map[string]float64{
+		"Well, DARN it":                     1,
+		"darning socks":                     0,
+		"For crying out loud, Chuck":        1,
+		"what the heckkk":                   1,
+		"Chuck Norris counted to infinity.": 0,
+	} {
+		if got, _ := b.Score(context.Background(), text); got != want {
+			t.Errorf("%q: expected score %v; got %v", text, want, got)
+		}
+	}
+	if blocked.Load() != 3 {
+		t.Errorf("Expected 3 blocked jokes counted; got %d", blocked.Load())
+	}
+
+	if err := os.WriteFile(path, []byte("/(/\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(); err == nil || b.Len() != 3 {
+		t.Errorf("Expected an error keeping the previous entries; got %v with %d entries", err, b.Len())
+	}
+}
+
+func TestBlocklistWatch(t *testing.T) {
+	t.Parallel()
+
+	path := writeBlocklist(t, "", "darn\n")
+	b, err := LoadBlocklist(path, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	var reloads atomic.Int64
+	b.OnReload(func() { reloads.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Watch(ctx, 5*time.Millisecond)
+
+	// Add an entry, moving the modification time so the change is noticed
+	writeBlocklist(t, path, "darn\nshucks\n")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Could not touch blocklist: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !b.Blocked("oh shucks") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected shucks to be blocked after reload")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for reloads.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the reload hook to be called")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
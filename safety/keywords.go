@@ -0,0 +1,90 @@
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Rule scores jokes containing a word or phrase, or matching a regular expression
+type Rule struct {
+	// Match is a word or phrase matched whole and case-insensitively
+	Match string `json:"match,omitempty"`
+	// Regex is a regular expression, case-sensitive unless it starts with (?i)
+	Regex string `json:"regex,omitempty"`
+	// Score is given to a joke the rule matches, from 0 to 1
+	Score float64 `json:"score"`
+
+	re *regexp.Regexp
+}
+
+/*
+	 Keywords scores a joke with the highest score of the rules it
+	 matches, 0 when it matches none
+
+		Build one with LoadKeywords or NewKeywords.
+*/
+type Keywords struct {
+	rules []Rule
+}
+
+// struct to hold the rules file
+type keywordsFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+/*
+	 LoadKeywords reads rules from a JSON file such as:
+
+		{"rules": [{"match": "idiot", "score": 0.6},
+		  {"regex": "(?i)\\bkill(ed|s)?\\b", "score": 0.9}]}
+*/
+func LoadKeywords(path string) (*Keywords, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("safety: could not read %s: %w", path, err)
+	}
+	var f keywordsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("safety: could not parse %s: %w", path, err)
+	}
+	return NewKeywords(f.Rules...)
+}
+
+// NewKeywords returns Keywords scoring with rules, checking each sets one of Match and Regex
+func NewKeywords(rules ...Rule) (*Keywords, error) {
+	k := &Keywords{rules: make([]Rule, len(rules))}
+	for i, r := range rules {
+		if (r.Match == "") == (r.Regex == "") {
+			return nil, errors.New("safety: each rule needs one of match and regex")
+		}
+		if r.Score < 0 || r.Score > 1 {
+			return nil, fmt.Errorf("safety: score of rule %d must be between 0 and 1", i+1)
+		}
+		expr := r.Regex
+		if r.Match != "" {
+			expr = `(?i)\b` + regexp.QuoteMeta(r.Match) + `\b`
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("safety: invalid regex of rule %d: %w", i+1, err)
+		}
+		r.re = re
+		k.rules[i] = r
+	}
+	return k, nil
+}
+
+// Score returns the highest score of the rules text matches
+func (k *Keywords) Score(ctx context.Context, text string) (float64, error) {
+	highest := 0.0
+	for _, r := range k.rules {
+		if r.Score > highest && r.re.MatchString(text) {
+			highest = r.Score
+		}
+	}
+	return highest, nil
+}
@@ -0,0 +1,45 @@
+package safety
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`{"rules": [
+		{"match": "dumb", "score": 0.4},
+		{"match": "no way", "score": 0.2},
+		{"regex": "(?i)\\bkill(ed|s)?\\b", "score": 0.9}]}`), 0o600)
+	k, err := LoadKeywords(path)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	for text, want := range map[string]float64{
+		"Chuck is DUMB":                 0.4,
+		"dumbbells are heavy":           0,
+		"No   way":                      0,
+		"no way, and he KILLED it dumb": 0.9,
+		"a skilled coder":               0,
+	} {
+		if got, _ := k.Score(context.Background(), text); got != want {
+			t.Errorf("%q: expected score %v; got %v", text, want, got)
+		}
+	}
+
+	for _, rules := range [][]Rule{
+		{{Score: 1}},
+		{{Match: "a", Regex: "b", Score: 1}},
+		{{Match: "a", Score: 2}},
+		{{Regex: "(", Score: 1}},
+	} {
+		if _, err := NewKeywords(rules...); err == nil {
+			t.Errorf("%+v: expected an error; got nil", rules)
+		}
+	}
+}
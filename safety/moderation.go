@@ -0,0 +1,101 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Moderation endpoint used when Moderation has none
+const DefaultModerationEndpoint = "https://api.openai.com/v1/moderations"
+
+// Largest moderation response read, so a hostile endpoint can't exhaust memory
+const maxModerationBytes = 64 << 10
+
+/*
+	 Moderation scores jokes with an OpenAI-compatible moderation API
+
+		A joke's score is the highest of its category scores, e.g.
+		harassment or hate.
+*/
+type Moderation struct {
+	// Endpoint of the API, defaults to DefaultModerationEndpoint
+	Endpoint string
+	// APIKey is sent as a bearer token when set
+	APIKey string
+	// Model is sent when set, otherwise the API picks its default
+	Model string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// struct to hold the body of a moderation request
+type moderationRequest struct {
+	Input string `json:"input"`
+	Model string `json:"model,omitempty"`
+}
+
+// struct to hold the body of a moderation response
+type moderationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// Score returns the highest category score the API gives text
+func (m *Moderation) Score(ctx context.Context, text string) (float64, error) {
+	body, err := json.Marshal(moderationRequest{Input: text, Model: m.Model})
+	if err != nil {
+		return 0, fmt.Errorf("safety: could not encode request: %w", err)
+	}
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultModerationEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("safety: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	}
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("safety: could not call moderation API: %w", err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxModerationBytes))
+	if err != nil {
+		return 0, fmt.Errorf("safety: could not read moderation response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("safety: moderation API answered status %d", res.StatusCode)
+	}
+
+	var decoded moderationResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return 0, fmt.Errorf("safety: could not decode moderation response: %w", err)
+	}
+	if len(decoded.Results) == 0 {
+		return 0, fmt.Errorf("safety: moderation response has no results")
+	}
+	highest := 0.0
+	for _, score := range decoded.Results[0].CategoryScores {
+		highest = max(highest, score)
+	}
+	// Trust the API's own verdict when its scores are low
+	if decoded.Results[0].Flagged {
+		highest = 1
+	}
+	return highest, nil
+}
@@ -0,0 +1,45 @@
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModeration(t *testing.T) {
+	t.Parallel()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req moderationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.Input {
+		case "mild":
+			w.Write([]byte(`{"results": [{"flagged": false, "category_scores": {"hate": 0.02, "harassment": 0.3}}]}`))
+		case "flagged":
+			w.Write([]byte(`{"results": [{"flagged": true, "category_scores": {"hate": 0.4}}]}`))
+		default:
+			w.Write([]byte(`{"results": []}`))
+		}
+	}))
+	defer api.Close()
+
+	m := &Moderation{Endpoint: api.URL, APIKey: "key"}
+	if score, err := m.Score(context.Background(), "mild"); err != nil || score != 0.3 {
+		t.Errorf("Expected the highest category score; got %v, %v", score, err)
+	}
+	if score, err := m.Score(context.Background(), "flagged"); err != nil || score != 1 {
+		t.Errorf("Expected flagged text to score 1; got %v, %v", score, err)
+	}
+	if _, err := m.Score(context.Background(), "other"); err == nil {
+		t.Error("Expected an error without results")
+	}
+	if _, err := (&Moderation{Endpoint: api.URL}).Score(context.Background(), "mild"); err == nil {
+		t.Error("Expected an error for a rejected key")
+	}
+}
@@ -0,0 +1,40 @@
+package safety
+
+import (
+	"context"
+	_ "embed"
+	"regexp"
+	"sync"
+)
+
+// Built-in list of profanity, in the blocklist format
+//
+//go:embed profanity.txt
+var profanityList []byte
+
+// Entries of profanityList, compiled on first use
+var profanity = sync.OnceValue(func() []*regexp.Regexp {
+	entries, err := parseBlocklist(profanityList)
+	if err != nil {
+		panic("safety: invalid built-in profanity list: " + err.Error())
+	}
+	return entries
+})
+
+/*
+	 Profanity returns a Scorer giving 1 to text containing common
+	 English profanity, slurs or adult themes, and 0 to other text
+
+		The list is built in, for deployments such as kid-safe mode
+		that need a filter without maintaining a blocklist file.
+*/
+func Profanity() Scorer {
+	return ScorerFunc(func(ctx context.Context, text string) (float64, error) {
+		for _, re := range profanity() {
+			if re.MatchString(text) {
+				return 1, nil
+			}
+		}
+		return 0, nil
+	})
+}
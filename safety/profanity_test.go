@@ -0,0 +1,24 @@
+package safety
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProfanity(t *testing.T) {
+	t.Parallel()
+
+	p := Profanity()
+	for text, want := range map[string]float64{
+		"Chuck Norris can divide by zero.":         0,
+		"Chuck Norris doesn't give a DAMN.":        1,
+		"What the fuck, Chuck":                     1,
+		"Chuck Norris passed his class assignment": 0,
+		"Grace Hopper drank a beer":                1,
+		"Ada wrote a title for the Scunthorpe cup": 0,
+	} {
+		if got, err := p.Score(context.Background(), text); err != nil || got != want {
+			t.Errorf("%q: expected score %v; got %v, %v", text, want, got, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+	 Package safety scores jokes for toxicity before they are served.
+
+		A Scorer rates a joke from 0, harmless, to 1, certainly toxic.
+		Keyword rules score jokes locally; a moderation API scores them
+		with a hosted model. A Filter wraps a joke provider to re-fetch
+		jokes scoring at or above a threshold, failing once it runs out
+		of attempts so the fallback joke is served instead.
+*/
+package safety
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Verdicts a Filter reports for each joke it was asked for
+const (
+	// VerdictPass is a joke that scored below the threshold first time
+	VerdictPass = "pass"
+	// VerdictRefetched is a joke that passed after re-fetching
+	VerdictRefetched = "refetched"
+	// VerdictBlocked is a call whose every joke scored at or above the threshold
+	VerdictBlocked = "blocked"
+	// VerdictError is a call whose joke could not be scored
+	VerdictError = "error"
+)
+
+// ErrUnsafe reports a joke blocked for scoring at or above the threshold
+var ErrUnsafe = errors.New("safety: joke scored above the threshold")
+
+// Scorer rates text from 0, harmless, to 1, certainly toxic
+type Scorer interface {
+	Score(ctx context.Context, text string) (float64, error)
+}
+
+// ScorerFunc adapts a function to a Scorer
+type ScorerFunc func(ctx context.Context, text string) (float64, error)
+
+// Score calls f
+func (f ScorerFunc) Score(ctx context.Context, text string) (float64, error) {
+	return f(ctx, text)
+}
+
+// Max returns a Scorer rating text with the highest score of scorers, failing if any fails
+func Max(scorers ...Scorer) Scorer {
+	return ScorerFunc(func(ctx context.Context, text string) (float64, error) {
+		highest := 0.0
+		for _, s := range scorers {
+			score, err := s.Score(ctx, text)
+			if err != nil {
+				return 0, err
+			}
+			highest = max(highest, score)
+		}
+		return highest, nil
+	})
+}
+
+/*
+	 Filter blocks jokes scoring at or above a threshold
+
+		Build one with NewFilter.
+*/
+type Filter struct {
+	scorer    Scorer
+	threshold float64
+	retries   int
+	observe   func(verdict string, score float64)
+	logger    *slog.Logger
+}
+
+/*
+	 NewFilter returns a Filter scoring jokes with scorer, re-fetching up
+	 to retries times a joke scoring threshold or more
+
+		observe, when not nil, is called with the verdict of each call
+		and the score of its last joke.
+*/
+func NewFilter(scorer Scorer, threshold float64, retries int, observe func(verdict string, score float64), logger *slog.Logger) *Filter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Filter{scorer: scorer, threshold: threshold, retries: max(retries, 0), observe: observe, logger: logger}
+}
+
+/*
+	 Jokes returns p wrapped so only jokes scoring below the threshold
+	 are returned
+
+		A blocked joke is re-fetched with the same names. When every
+		attempt is blocked, or a joke can't be scored, the call fails
+		with joke.ErrJokeUpstream, so it is handled like an upstream
+		outage and the fallback joke is served. Unscored jokes are
+		never served.
+*/
+func (f *Filter) Jokes(p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		for attempt := 0; ; attempt++ {
+			text, err := p.Joke(ctx, firstName, lastName)
+			if err != nil {
+				return "", err
+			}
+			score, err := f.scorer.Score(ctx, text)
+			if err != nil {
+				f.report(VerdictError, 0)
+				return "", fmt.Errorf("%w: safety: could not score joke: %w", joke.ErrJokeUpstream, err)
+			}
+			if score < f.threshold {
+				verdict := VerdictPass
+				if attempt > 0 {
+					verdict = VerdictRefetched
+				}
+				f.report(verdict, score)
+				return text, nil
+			}
+
+			f.logger.InfoContext(ctx, "safety: blocked joke", "score", score, "attempt", attempt+1, "joke", text)
+			if attempt >= f.retries {
+				f.report(VerdictBlocked, score)
+				return "", fmt.Errorf("%w: %w (%.2f after %d attempts)", joke.ErrJokeUpstream, ErrUnsafe, score, attempt+1)
+			}
+		}
+	})
+}
+
+// Function to pass a verdict to the observer, if any
+func (f *Filter) report(verdict string, score float64) {
+	if f.observe != nil {
+		f.observe(verdict, score)
+	}
+}
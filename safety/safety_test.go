@@ -0,0 +1,69 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Function to return a provider returning texts in turn, then the last one
+func sequence(texts ...string) joke.JokeProvider {
+	i := 0
+	return joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		text := texts[min(i, len(texts)-1)]
+		i++
+		return text, nil
+	})
+}
+
+// Scorer giving text containing "rude" a score of 0.9
+var rudeness = ScorerFunc(func(ctx context.Context, text string) (float64, error) {
+	if strings.Contains(text, "rude") {
+		return 0.9, nil
+	}
+	return 0.1, nil
+})
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Passes safe jokes", func(t *testing.T) {
+		var verdicts []string
+		f := NewFilter(rudeness, 0.5, 2, func(v string, score float64) { verdicts = append(verdicts, v) }, nil)
+		text, err := f.Jokes(sequence("kind joke")).Joke(context.Background(), "A", "B")
+		if err != nil || text != "kind joke" || len(verdicts) != 1 || verdicts[0] != VerdictPass {
+			t.Errorf("Expected the joke to pass; got %q, %v, %v", text, err, verdicts)
+		}
+	})
+
+	t.Run("Re-fetches unsafe jokes", func(t *testing.T) {
+		var verdicts []string
+		f := NewFilter(rudeness, 0.5, 2, func(v string, score float64) { verdicts = append(verdicts, v) }, nil)
+		text, err := f.Jokes(sequence("rude joke", "rude joke", "kind joke")).Joke(context.Background(), "A", "B")
+		if err != nil || text != "kind joke" || len(verdicts) != 1 || verdicts[0] != VerdictRefetched {
+			t.Errorf("Expected the third joke after re-fetching; got %q, %v, %v", text, err, verdicts)
+		}
+	})
+
+	t.Run("Blocks once out of attempts", func(t *testing.T) {
+		var verdicts []string
+		f := NewFilter(rudeness, 0.5, 1, func(v string, score float64) { verdicts = append(verdicts, v) }, nil)
+		_, err := f.Jokes(sequence("rude joke", "rude joke", "kind joke")).Joke(context.Background(), "A", "B")
+		if !errors.Is(err, ErrUnsafe) || !errors.Is(err, joke.ErrJokeUpstream) || verdicts[0] != VerdictBlocked {
+			t.Errorf("Expected ErrUnsafe as an upstream error; got %v, %v", err, verdicts)
+		}
+	})
+
+	t.Run("Fails when scoring fails", func(t *testing.T) {
+		failing := ScorerFunc(func(ctx context.Context, text string) (float64, error) {
+			return 0, errors.New("moderation down")
+		})
+		f := NewFilter(Max(rudeness, failing), 0.5, 2, nil, nil)
+		if _, err := f.Jokes(sequence("kind joke")).Joke(context.Background(), "A", "B"); !errors.Is(err, joke.ErrJokeUpstream) {
+			t.Errorf("Expected an upstream error; got %v", err)
+		}
+	})
+}
@@ -0,0 +1,420 @@
+/*
+	 Package search indexes jokes for full-text search with fuzzy
+	 terms, phrase queries and highlighting.
+
+		The index is an in-memory inverted index of word positions;
+		it holds a bounded number of distinct jokes, dropping the
+		oldest first.
+*/
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Longest query accepted, in bytes, and most clauses in one
+const (
+	MaxQueryLen = 200
+	maxClauses  = 10
+)
+
+// Weight of a term matched only fuzzily, relative to an exact match
+const fuzzyWeight = 0.5
+
+// Doc is a joke to index
+type Doc struct {
+	Joke     string
+	Category string
+	// Tenant the joke was served to; searches only see their own
+	// tenant's jokes
+	Tenant string
+}
+
+// Result is a joke matching a query
+type Result struct {
+	Joke     string  `json:"joke"`
+	Category string  `json:"category"`
+	Score    float64 `json:"score"`
+	// Highlight is the joke as HTML, with each matched word in <mark>
+	Highlight string `json:"highlight"`
+}
+
+// struct to hold a word of an indexed joke
+type token struct {
+	term string
+	// Byte offsets of the word in the joke
+	start, end int
+}
+
+// struct to hold an indexed joke
+type doc struct {
+	Doc
+	id     int
+	tokens []token
+}
+
+/*
+	 Index holds jokes for searching
+
+		Safe for concurrent use. Build one with New.
+*/
+type Index struct {
+	limit int
+
+	mu     sync.RWMutex
+	nextID int
+	docs   map[int]*doc
+	// IDs in the order they were added, oldest first
+	order []int
+	// Doc ID by tenant and joke, so a joke served again isn't indexed twice
+	byKey map[string]int
+	// Word positions by term and doc ID
+	postings map[string]map[int][]int
+}
+
+// New returns an empty Index holding at most limit distinct jokes
+func New(limit int) *Index {
+	return &Index{
+		limit:    limit,
+		nextID:   1,
+		docs:     make(map[int]*doc),
+		byKey:    make(map[string]int),
+		postings: make(map[string]map[int][]int),
+	}
+}
+
+// Add indexes d, unless its tenant already has the same joke
+func (x *Index) Add(d Doc) {
+	key := d.Tenant + "\x00" + d.Joke
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.byKey[key]; ok {
+		return
+	}
+
+	id := x.nextID
+	x.nextID++
+	indexed := &doc{Doc: d, id: id, tokens: tokenize(d.Joke)}
+	x.docs[id] = indexed
+	x.byKey[key] = id
+	x.order = append(x.order, id)
+	for pos, t := range indexed.tokens {
+		if x.postings[t.term] == nil {
+			x.postings[t.term] = make(map[int][]int)
+		}
+		x.postings[t.term][id] = append(x.postings[t.term][id], pos)
+	}
+
+	// Drop the oldest jokes once the limit is exceeded
+	for len(x.order) > x.limit {
+		x.removeLocked(x.order[0])
+		x.order = x.order[1:]
+	}
+}
+
+// Len returns the number of jokes indexed
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Function to remove the doc with id from the index
+func (x *Index) removeLocked(id int) {
+	d := x.docs[id]
+	delete(x.docs, id)
+	delete(x.byKey, d.Tenant+"\x00"+d.Joke)
+	for _, t := range d.tokens {
+		delete(x.postings[t.term], id)
+		if len(x.postings[t.term]) == 0 {
+			delete(x.postings, t.term)
+		}
+	}
+}
+
+/*
+	 Search returns the tenant's jokes matching query, best first, at
+	 most limit of them, and the number matching
+
+		Every word of query must match a word of the joke, exactly
+		or, for longer words, within one edit, or two for words of
+		eight letters or more; "quoted phrases" must match exactly
+		and in order. Exact and rarer matches score higher.
+*/
+func (x *Index) Search(tenant, query string, limit int) ([]Result, int) {
+	clauses := parse(query)
+	if len(clauses) == 0 {
+		return []Result{}, 0
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// Match each clause, keeping the docs matching every one so far
+	var scores map[int]float64
+	marks := make(map[int]map[int]bool)
+	for _, c := range clauses {
+		matched := x.matchLocked(c, marks)
+		if scores == nil {
+			scores = matched
+		} else {
+			for id, score := range scores {
+				if m, ok := matched[id]; ok {
+					scores[id] = score + m
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+	}
+
+	results := make([]int, 0, len(scores))
+	for id := range scores {
+		if x.docs[id].Tenant == tenant {
+			results = append(results, id)
+		}
+	}
+	// Best first, then newest
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return a > b
+	})
+
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	page := make([]Result, 0, len(results))
+	for _, id := range results {
+		d := x.docs[id]
+		page = append(page, Result{
+			Joke:      d.Joke,
+			Category:  d.Category,
+			Score:     math.Round(scores[id]*1000) / 1000,
+			Highlight: highlight(d, marks[id]),
+		})
+	}
+	return page, total
+}
+
+/*
+	 Function to return the score of each doc matching the clause c
+
+		The clause is a single word or a phrase. The matched word
+		positions are added to marks.
+*/
+func (x *Index) matchLocked(c []string, marks map[int]map[int]bool) map[int]float64 {
+	matched := make(map[int]float64)
+	mark := func(id, pos int) {
+		if marks[id] == nil {
+			marks[id] = make(map[int]bool)
+		}
+		marks[id][pos] = true
+	}
+
+	// A single word matches fuzzily
+	if len(c) == 1 {
+		for term, weight := range x.expandLocked(c[0]) {
+			postings := x.postings[term]
+			idf := x.idfLocked(len(postings))
+			for id, positions := range postings {
+				matched[id] = max(matched[id], weight*idf)
+				for _, pos := range positions {
+					mark(id, pos)
+				}
+			}
+		}
+		return matched
+	}
+
+	// A phrase matches its words exactly, one after the other
+	for id, starts := range x.postings[c[0]] {
+		d := x.docs[id]
+		for _, start := range starts {
+			if start+len(c) > len(d.tokens) {
+				continue
+			}
+			found := true
+			for i, term := range c[1:] {
+				if d.tokens[start+1+i].term != term {
+					found = false
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+			score := 0.0
+			for _, term := range c {
+				score += x.idfLocked(len(x.postings[term]))
+			}
+			matched[id] = max(matched[id], score)
+			for i := range c {
+				mark(id, start+i)
+			}
+		}
+	}
+	return matched
+}
+
+// Function to return the indexed terms matching word, weighted by how closely
+func (x *Index) expandLocked(word string) map[string]float64 {
+	terms := make(map[string]float64)
+	if _, ok := x.postings[word]; ok {
+		terms[word] = 1
+	}
+	edits := maxEdits(word)
+	if edits == 0 {
+		return terms
+	}
+	for term := range x.postings {
+		if term != word && withinEdits(word, term, edits) {
+			terms[term] = fuzzyWeight
+		}
+	}
+	return terms
+}
+
+// Function to return the inverse document frequency of a term in n docs
+func (x *Index) idfLocked(n int) float64 {
+	return 1 + math.Log(float64(len(x.docs)+1)/float64(n+1))
+}
+
+// Function to return the edits a query word may be from an indexed term
+func maxEdits(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+/*
+	 Function to parse query into clauses: quoted phrases, and single
+	 words, both as terms
+
+		An unclosed quote runs to the end of the query.
+*/
+func parse(query string) [][]string {
+	if len(query) > MaxQueryLen {
+		query = query[:MaxQueryLen]
+	}
+	var clauses [][]string
+	for i, part := range strings.Split(query, `"`) {
+		terms := terms(part)
+		// Odd parts are inside quotes
+		if i%2 == 1 && len(terms) > 1 {
+			clauses = append(clauses, terms)
+			continue
+		}
+		for _, term := range terms {
+			clauses = append(clauses, []string{term})
+		}
+	}
+	if len(clauses) > maxClauses {
+		clauses = clauses[:maxClauses]
+	}
+	return clauses
+}
+
+// Function to return the terms of text
+func terms(text string) []string {
+	var list []string
+	for _, t := range tokenize(text) {
+		list = append(list, t.term)
+	}
+	return list
+}
+
+// Function to split text into lower-case words of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text + " " {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{term: strings.ToLower(norm.NFC.String(text[start:i])), start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// Function to return d's joke as HTML with the words at marked positions in <mark>
+func highlight(d *doc, marked map[int]bool) string {
+	var b strings.Builder
+	last := 0
+	for pos, t := range d.tokens {
+		if !marked[pos] {
+			continue
+		}
+		b.WriteString(html.EscapeString(d.Joke[last:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(d.Joke[t.start:t.end]))
+		b.WriteString("</mark>")
+		last = t.end
+	}
+	b.WriteString(html.EscapeString(d.Joke[last:]))
+	return b.String()
+}
+
+/*
+	 Function to report whether a and b are at most k edits apart:
+	 insertions, deletions or substitutions of a letter
+
+		Fills the edit distance table row by row, giving up as soon
+		as a row needs more than k edits throughout.
+*/
+func withinEdits(a, b string, k int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > k {
+		return false
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		// Every path already needs more than k edits
+		if best > k {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= k
+}
+
+// Function to return the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
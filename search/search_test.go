@@ -0,0 +1,95 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	x := New(10)
+	x.Add(Doc{Joke: "Chuck Norris can divide by zero.", Category: "nerdy"})
+	x.Add(Doc{Joke: "Chuck Norris compiles code by staring at it.", Category: "nerdy"})
+	x.Add(Doc{Joke: "John Doe's code compiles <on the first try>.", Category: "nerdy"})
+	x.Add(Doc{Joke: "Tenant joke about zero.", Tenant: "acme"})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Every word must match", "chuck zero", []string{"Chuck Norris can divide by zero."}},
+		{"Fuzzy words", "compyles", []string{"John Doe's code compiles <on the first try>.", "Chuck Norris compiles code by staring at it."}},
+		{"Phrases match in order", `"compiles code"`, []string{"Chuck Norris compiles code by staring at it."}},
+		{"Short words are exact", "zer", nil},
+		{"No clauses", `""`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total := x.Search("", tt.query, 10)
+			if total != len(tt.want) || len(results) != len(tt.want) {
+				t.Fatalf("Expected %d results; got %d: %+v", len(tt.want), total, results)
+			}
+			for i, r := range results {
+				if r.Joke != tt.want[i] {
+					t.Errorf("Expected result %d to be %q; got %q", i, tt.want[i], r.Joke)
+				}
+			}
+		})
+	}
+
+	t.Run("Highlights matches as HTML", func(t *testing.T) {
+		results, _ := x.Search("", "compiles first", 10)
+		want := "John Doe&#39;s code <mark>compiles</mark> &lt;on the <mark>first</mark> try&gt;."
+		if len(results) != 1 || results[0].Highlight != want {
+			t.Errorf("Expected %q; got %+v", want, results)
+		}
+	})
+
+	t.Run("Only searches the tenant's jokes", func(t *testing.T) {
+		if results, _ := x.Search("acme", "zero", 10); len(results) != 1 || results[0].Joke != "Tenant joke about zero." {
+			t.Errorf("Expected the tenant's joke; got %+v", results)
+		}
+	})
+}
+
+func TestIndexLimit(t *testing.T) {
+	t.Parallel()
+
+	x := New(2)
+	for i := 0; i < 3; i++ {
+		x.Add(Doc{Joke: fmt.Sprintf("joke number %d", i)})
+	}
+	x.Add(Doc{Joke: "joke number 2"})
+
+	if x.Len() != 2 {
+		t.Errorf("Expected 2 jokes; got %d", x.Len())
+	}
+	if _, total := x.Search("", `"number 0"`, 10); total != 0 {
+		t.Errorf("Expected the oldest joke to be dropped; got %d results", total)
+	}
+}
+
+func TestWithinEdits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		k    int
+		want bool
+	}{
+		{"compile", "compile", 0, true},
+		{"compile", "compyle", 1, true},
+		{"compile", "compiles", 1, true},
+		{"compile", "cmpyle", 1, false},
+		{"kitten", "sitting", 2, false},
+		{"kitten", "sitting", 3, true},
+		{"naïve", "naive", 1, true},
+	}
+	for _, tt := range tests {
+		if got := withinEdits(tt.a, tt.b, tt.k); got != tt.want {
+			t.Errorf("%q %q within %d: expected %v; got %v", tt.a, tt.b, tt.k, tt.want, got)
+		}
+	}
+}
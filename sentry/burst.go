@@ -0,0 +1,100 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+/*
+	 Bursts reports bursts of upstream errors rather than each error,
+	 so a flaky upstream raises one alert instead of thousands
+
+		A burst is Threshold errors from one upstream within Window;
+		it is reported once, and the upstream may burst again in the
+		next window. Build one with NewBursts.
+*/
+type Bursts struct {
+	client    *Client
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*burstWindow
+}
+
+// struct to hold the errors counted from an upstream in its current window
+type burstWindow struct {
+	start    time.Time
+	errors   int
+	reported bool
+}
+
+// NewBursts returns Bursts reporting threshold errors from an upstream within window to client
+func NewBursts(client *Client, threshold int, window time.Duration) *Bursts {
+	return &Bursts{
+		client:    client,
+		threshold: max(threshold, 1),
+		window:    window,
+		now:       time.Now,
+		windows:   make(map[string]*burstWindow),
+	}
+}
+
+/*
+	 Observe counts a call to upstream that returned err, reporting a
+	 burst once errors reach the threshold
+
+		Calls canceled by their client are not upstream errors and are
+		ignored.
+*/
+func (b *Bursts) Observe(upstream string, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	now := b.now()
+	b.mu.Lock()
+	w, ok := b.windows[upstream]
+	if !ok || now.Sub(w.start) >= b.window {
+		w = &burstWindow{start: now}
+		b.windows[upstream] = w
+	}
+	w.errors++
+	report := w.errors >= b.threshold && !w.reported
+	if report {
+		w.reported = true
+	}
+	n := w.errors
+	b.mu.Unlock()
+
+	if report {
+		b.client.Capture(Event{
+			Level:   LevelError,
+			Message: fmt.Sprintf("%d errors from the %s upstream within %s, last: %v", n, upstream, b.window, err),
+			Tags:    map[string]string{"upstream": upstream, "kind": "upstream_burst"},
+		})
+	}
+}
+
+// Names returns p wrapped so every failed call is observed under upstream
+func (b *Bursts) Names(upstream string, p joke.NameProvider) joke.NameProvider {
+	return joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		n, err := p.Name(ctx)
+		b.Observe(upstream, err)
+		return n, err
+	})
+}
+
+// Jokes returns p wrapped so every failed call is observed under upstream
+func (b *Bursts) Jokes(upstream string, p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		text, err := p.Joke(ctx, firstName, lastName)
+		b.Observe(upstream, err)
+		return text, err
+	})
+}
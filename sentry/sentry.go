@@ -0,0 +1,237 @@
+/*
+	 Package sentry reports errors to Sentry, or a self-hosted
+	 Sentry-compatible service, through its envelope API.
+
+		Events are sent in the background and dropped when too many
+		are already in flight, so reporting never slows requests down.
+*/
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Events sent at once before more are dropped, and how long each may take
+const (
+	maxInFlight = 10
+	sendTimeout = 10 * time.Second
+)
+
+// Levels of an event
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelFatal   = "fatal"
+)
+
+// Options configures a Client
+type Options struct {
+	// SampleRate is the fraction (0-1) of events sent
+	SampleRate float64
+	// Release tags events with the version deployed, defaulting to the
+	// VCS revision the binary was built from
+	Release string
+	// Environment tags events, e.g. "production"
+	Environment string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+	Logger *slog.Logger
+}
+
+// Event is an error to report
+type Event struct {
+	Level   string
+	Message string
+	// Stack is a stack trace, e.g. from debug.Stack, sent as extra data
+	Stack string
+	Tags  map[string]string
+	// Request is the request being handled when the error happened
+	Request *http.Request
+}
+
+/*
+	 Client sends events to one Sentry project
+
+		Safe for concurrent use. Build one with New.
+*/
+type Client struct {
+	opts     Options
+	dsn      string
+	key      string
+	endpoint string
+	host     string
+	inFlight chan struct{}
+	sample   func() float64
+}
+
+// New returns a Client for dsn, e.g. https://public@o1.ingest.sentry.io/123
+func New(dsn string, opts Options) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sentry: invalid DSN: %w", err)
+	}
+	path := strings.Trim(u.Path, "/")
+	project := path[strings.LastIndexByte(path, '/')+1:]
+	if (u.Scheme != "http" && u.Scheme != "https") || u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, errors.New("sentry: invalid DSN: want https://<key>@<host>/<project>")
+	}
+	prefix := strings.TrimSuffix(path, project)
+	if opts.Release == "" {
+		opts.Release = revision()
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	host, _ := os.Hostname()
+	return &Client{
+		opts:     opts,
+		dsn:      dsn,
+		key:      u.User.Username(),
+		endpoint: fmt.Sprintf("%s://%s/%sapi/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		host:     host,
+		inFlight: make(chan struct{}, maxInFlight),
+		sample:   rand.Float64,
+	}, nil
+}
+
+/*
+	 Capture sends ev in the background, subject to the sample rate
+
+		It returns whether the event was sent; events are dropped when
+		sampled out or when too many are already in flight.
+*/
+func (c *Client) Capture(ev Event) bool {
+	if c.sample() >= c.opts.SampleRate {
+		return false
+	}
+	select {
+	case c.inFlight <- struct{}{}:
+	default:
+		c.opts.Logger.Warn("dropped Sentry event, too many in flight", "message", ev.Message)
+		return false
+	}
+	body := c.envelope(ev)
+	go func() {
+		defer func() { <-c.inFlight }()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		// Handle errors while sending; the event is only logged
+		if err := c.send(ctx, body); err != nil {
+			c.opts.Logger.Error("could not send Sentry event", "message", ev.Message, "error", err)
+		}
+	}()
+	return true
+}
+
+// ReportPanic captures a panic recovered while handling r, for middleware.RecoverReporting
+func (c *Client) ReportPanic(r *http.Request, p any, stack []byte) {
+	c.Capture(Event{
+		Level:   LevelFatal,
+		Message: fmt.Sprintf("panic: %v", p),
+		Stack:   string(stack),
+		Tags:    map[string]string{"kind": "panic"},
+		Request: r,
+	})
+}
+
+// Function to POST an envelope to the project
+func (c *Client) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sentry: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=joke-generator/1.0, sentry_key="+c.key)
+	res, err := c.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry: could not send event: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("sentry: could not send event: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+/*
+	 Function to return ev as an envelope of one event item
+
+		An envelope is a header line, then each item's header and
+		payload on lines of their own.
+*/
+func (c *Client) envelope(ev Event) []byte {
+	eventID := fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+	now := time.Now().UTC()
+
+	payload := map[string]any{
+		"event_id":    eventID,
+		"timestamp":   now.Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       orDefault(ev.Level, LevelError),
+		"message":     map[string]string{"formatted": ev.Message},
+		"server_name": c.host,
+		"release":     c.opts.Release,
+		"environment": c.opts.Environment,
+		"tags":        ev.Tags,
+	}
+	if ev.Stack != "" {
+		payload["extra"] = map[string]string{"stack": ev.Stack}
+	}
+	if r := ev.Request; r != nil {
+		// Headers are left out, as they may carry keys and cookies
+		payload["request"] = map[string]string{
+			"method": r.Method,
+			"url":    r.URL.Path,
+		}
+	}
+	// Events always encode; they hold only strings and maps of strings
+	item, _ := json.Marshal(payload)
+	header, _ := json.Marshal(map[string]string{"event_id": eventID, "sent_at": now.Format(time.RFC3339Nano), "dsn": c.dsn})
+	itemHeader, _ := json.Marshal(map[string]any{"type": "event", "length": len(item)})
+
+	var b bytes.Buffer
+	for _, line := range [][]byte{header, itemHeader, item} {
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Function to return the VCS revision the binary was built from, "" if unknown
+func revision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// Function to return s, or def when s is empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+package sentry
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to return a fake Sentry whose received event payloads are sent on the returned channel
+func newFakeSentry(t *testing.T) (*httptest.Server, chan map[string]any) {
+	events := make(chan map[string]any, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=public") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Header, item header, then the event
+		sc := bufio.NewScanner(r.Body)
+		var lines []string
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		var ev map[string]any
+		if len(lines) != 3 || json.Unmarshal([]byte(lines[2]), &ev) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- ev
+	}))
+	t.Cleanup(ts.Close)
+	return ts, events
+}
+
+// Function to return the next event received, failing after a while
+func nextEvent(t *testing.T, events chan map[string]any) map[string]any {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event; got none")
+		return nil
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	c, err := New("https://public@o1.ingest.sentry.io/self-hosted/123", Options{})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if want := "https://o1.ingest.sentry.io/self-hosted/api/123/envelope/"; c.endpoint != want {
+		t.Errorf("Expected endpoint %s; got %s", want, c.endpoint)
+	}
+	for _, dsn := range []string{"https://o1.ingest.sentry.io/123", "https://public@o1.ingest.sentry.io/", "ftp://public@host/1"} {
+		if _, err := New(dsn, Options{}); err == nil {
+			t.Errorf("%s: expected an error; got nil", dsn)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Sends panics with release and request", func(t *testing.T) {
+		ts, events := newFakeSentry(t)
+		c, err := New(strings.Replace(ts.URL, "http://", "http://public@", 1)+"/42", Options{SampleRate: 1, Release: "v1.2.3", Environment: "production"})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/joke?secret=1", nil)
+		c.ReportPanic(r, "boom", []byte("goroutine 1"))
+
+		ev := nextEvent(t, events)
+		if ev["level"] != LevelFatal || ev["release"] != "v1.2.3" || ev["environment"] != "production" {
+			t.Errorf("Expected a fatal event tagged with release and environment; got %v", ev)
+		}
+		if msg, _ := ev["message"].(map[string]any); msg["formatted"] != "panic: boom" {
+			t.Errorf("Expected the panic message; got %v", ev["message"])
+		}
+		if req, _ := ev["request"].(map[string]any); req["url"] != "/joke" {
+			t.Errorf("Expected the request path without its query; got %v", ev["request"])
+		}
+	})
+
+	t.Run("Drops events sampled out", func(t *testing.T) {
+		c, _ := New("https://public@sentry.example/1", Options{SampleRate: 0.5})
+		c.sample = func() float64 { return 0.7 }
+		if c.Capture(Event{Message: "x"}) {
+			t.Error("Expected the event to be sampled out")
+		}
+	})
+}
+
+func TestBursts(t *testing.T) {
+	t.Parallel()
+
+	ts, events := newFakeSentry(t)
+	c, _ := New(strings.Replace(ts.URL, "http://", "http://public@", 1)+"/42", Options{SampleRate: 1})
+	b := NewBursts(c, 3, time.Minute)
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	b.Observe("joke", nil)
+	b.Observe("joke", errString("timeout"))
+	b.Observe("name", errString("timeout"))
+	b.Observe("joke", errString("timeout"))
+	select {
+	case ev := <-events:
+		t.Fatalf("Expected no event below the threshold; got %v", ev)
+	default:
+	}
+
+	// The third error reports once, later ones in the window don't
+	b.Observe("joke", errString("timeout"))
+	b.Observe("joke", errString("timeout"))
+	ev := nextEvent(t, events)
+	if tags, _ := ev["tags"].(map[string]any); tags["upstream"] != "joke" {
+		t.Errorf("Expected a burst from the joke upstream; got %v", ev)
+	}
+
+	// A new window may burst again
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		b.Observe("joke", errString("timeout"))
+	}
+	nextEvent(t, events)
+	select {
+	case ev := <-events:
+		t.Errorf("Expected one event per burst; got another %v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// errString is an error with a fixed message
+type errString string
+
+func (e errString) Error() string { return string(e) }
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// struct to hold the body of /admin/loglevel requests and responses
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// struct to hold the body of GET /admin/cache
+type cacheListing struct {
+	Stats   cache.Stats   `json:"stats"`
+	Entries []cache.Entry `json:"entries"`
+}
+
+// struct to hold a cached value returned by GET /admin/cache/{key}
+type cacheValue struct {
+	cache.Entry
+	Value string `json:"value"`
+}
+
+// struct to hold the body of POST /admin/keys
+type createKeyRequest struct {
+	Name      string        `json:"name"`
+	Scopes    []string      `json:"scopes"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+	Quota     *apikey.Quota `json:"quota"`
+}
+
+// struct to hold a created key; the secret is only ever returned here
+type createKeyResponse struct {
+	Key    apikey.Key `json:"key"`
+	Secret string     `json:"secret"`
+}
+
+/*
+	 Function to mount the admin routes on mux
+
+		Admin routes are only mounted when WithAdminAuth is given,
+		so they can never be reached without a key.
+*/
+func (s *Server) mountAdmin(mux *http.ServeMux) {
+	if s.adminAuth == nil {
+		return
+	}
+	if s.logLevel != nil {
+		mux.Handle("GET /admin/loglevel", s.admin(s.handleGetLogLevel))
+		mux.Handle("PUT /admin/loglevel", s.admin(s.handlePutLogLevel))
+	}
+	if s.keys != nil {
+		mux.Handle("POST /admin/keys", s.admin(s.handleCreateKey))
+		mux.Handle("GET /admin/keys", s.admin(s.handleListKeys))
+		mux.Handle("DELETE /admin/keys/{id}", s.admin(s.handleRevokeKey))
+		mux.Handle("PUT /admin/keys/{id}/quota", s.admin(s.handlePutQuota))
+		mux.Handle("GET /admin/keys/{id}/stats", s.admin(s.handleKeyStats))
+	}
+	if s.submissions != nil {
+		mux.Handle("GET /admin/submissions", s.admin(s.handleListSubmissions))
+		mux.Handle("GET /admin/submissions/{id}", s.admin(s.handleGetSubmission))
+		mux.Handle("PATCH /admin/submissions/{id}", s.admin(s.handleEditSubmission))
+		mux.Handle("POST /admin/submissions/{id}/approve", s.admin(s.handleApproveSubmission))
+		mux.Handle("POST /admin/submissions/{id}/reject", s.admin(s.handleRejectSubmission))
+	}
+	if s.teams != nil {
+		mux.Handle("POST /admin/teams/post", s.admin(s.handleTeamsPost))
+	}
+	if s.quit != nil {
+		mux.Handle("POST /admin/quitquitquit", s.admin(s.handleQuit))
+	}
+	if _, ok := s.cache.(cache.Inspector); ok {
+		mux.Handle("GET /admin/cache", s.admin(s.handleListCache))
+		mux.Handle("DELETE /admin/cache", s.admin(s.handleClearCache))
+		mux.Handle("GET /admin/cache/{key...}", s.admin(s.handleGetCacheKey))
+		mux.Handle("DELETE /admin/cache/{key...}", s.admin(s.handleDeleteCacheKey))
+	}
+}
+
+// Function to wrap an admin handler with admin key authentication
+func (s *Server) admin(h http.HandlerFunc) http.Handler {
+	return middleware.APIKey(s.adminAuth)(h)
+}
+
+/*
+	 Handler for POST /admin/quitquitquit
+
+		Starts a drain: readiness turns false so the load balancer
+		stops routing here, then in-flight requests finish and the
+		server exits. Responds 202 before the drain completes.
+*/
+func (s *Server) handleQuit(w http.ResponseWriter, r *http.Request) {
+	s.logger.WarnContext(r.Context(), "shutdown requested", "remote", r.RemoteAddr)
+	s.quit()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("draining\n"))
+}
+
+// Handler for GET /admin/loglevel
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	s.writeLogLevel(w)
+}
+
+/*
+	 Handler for PUT /admin/loglevel
+
+		Accepts {"level": "debug"} with any level understood by
+		slog.Level, e.g. debug, info, warn or error, and responds
+		with the level now in effect.
+*/
+func (s *Server) handlePutLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	// Decode the requested level
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid body: want {\"level\": \"debug|info|warn|error\"}", http.StatusBadRequest)
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+		http.Error(w, "invalid level: "+body.Level, http.StatusBadRequest)
+		return
+	}
+
+	old := s.logLevel.Level()
+	s.logLevel.Set(level)
+	s.logger.WarnContext(r.Context(), "log level changed", "from", old, "to", level)
+
+	s.writeLogLevel(w)
+}
+
+// Function to write the current log level as JSON
+func (s *Server) writeLogLevel(w http.ResponseWriter) {
+	s.writeJSON(w, http.StatusOK, logLevelBody{Level: s.logLevel.Level().String()})
+}
+
+/*
+	 Handler for POST /admin/keys
+
+		Accepts {"name": "ci", "scopes": ["jokes"], "expires_at":
+		"2025-01-01T00:00:00Z", "quota": {"daily": 1000}}; scopes,
+		expires_at and quota are optional.
+		Responds 201 with the key and its secret, which is not
+		shown again.
+*/
+func (s *Server) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	// Decode the requested key
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	var expiresAt time.Time
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	var quota apikey.Quota
+	if req.Quota != nil {
+		quota = *req.Quota
+	}
+	key, secret, err := s.keys.Create(req.Name, req.Scopes, expiresAt, quota)
+	if errors.Is(err, apikey.ErrUnknownScope) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not create API key", "error", err)
+		http.Error(w, "could not create key", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "API key created", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	s.writeJSON(w, http.StatusCreated, createKeyResponse{Key: key, Secret: secret})
+}
+
+// Handler for GET /admin/keys, listing every key with its usage
+func (s *Server) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string][]apikey.Key{"keys": s.keys.List()})
+}
+
+// Handler for DELETE /admin/keys/{id}, revoking the key
+func (s *Server) handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	key, err := s.keys.Revoke(r.PathValue("id"))
+	if errors.Is(err, apikey.ErrNotFound) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not revoke API key", "error", err)
+		http.Error(w, "could not revoke key", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "API key revoked", "id", key.ID, "name", key.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for PUT /admin/keys/{id}/quota, accepting {"daily": 1000, "monthly": 20000}
+func (s *Server) handlePutQuota(w http.ResponseWriter, r *http.Request) {
+	var q apikey.Quota
+	// Decode the requested quota
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&q); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := s.keys.SetQuota(r.PathValue("id"), q)
+	if errors.Is(err, apikey.ErrNotFound) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not set API key quota", "error", err)
+		http.Error(w, "could not set quota", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "API key quota set", "id", key.ID, "daily", q.Daily, "monthly", q.Monthly)
+	s.writeJSON(w, http.StatusOK, key)
+}
+
+/*
+	 Handler for GET /admin/keys/{id}/stats
+
+		Responds with the key's usage in the current day and month and
+		its daily and monthly rollups, oldest first. ?period=day or
+		?period=month returns only that period's rollups.
+*/
+func (s *Server) handleKeyStats(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period != "" && period != apikey.PeriodDay && period != apikey.PeriodMonth {
+		http.Error(w, "period must be day or month", http.StatusBadRequest)
+		return
+	}
+	stats, err := s.keys.Stats(r.PathValue("id"), period)
+	if errors.Is(err, apikey.ErrNotFound) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not read API key stats", "error", err)
+		http.Error(w, "could not read stats", http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, stats)
+}
+
+// Handler for GET /admin/cache, listing the cache's counters and keys
+func (s *Server) handleListCache(w http.ResponseWriter, r *http.Request) {
+	c := s.cache.(cache.Inspector)
+	s.writeJSON(w, http.StatusOK, cacheListing{Stats: c.Stats(), Entries: c.Entries()})
+}
+
+// Handler for DELETE /admin/cache, removing every cached value
+func (s *Server) handleClearCache(w http.ResponseWriter, r *http.Request) {
+	c := s.cache.(cache.Inspector)
+	c.Clear()
+	s.logger.WarnContext(r.Context(), "cache cleared")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for GET /admin/cache/{key}, returning the value as a string
+func (s *Server) handleGetCacheKey(w http.ResponseWriter, r *http.Request) {
+	c := s.cache.(cache.Inspector)
+	entry, value, ok := c.Inspect(r.PathValue("key"))
+	if !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, cacheValue{Entry: entry, Value: string(value)})
+}
+
+// Handler for DELETE /admin/cache/{key}, removing the value
+func (s *Server) handleDeleteCacheKey(w http.ResponseWriter, r *http.Request) {
+	c := s.cache.(cache.Inspector)
+	key := r.PathValue("key")
+	if _, _, ok := c.Inspect(key); !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	c.Delete(key)
+	s.logger.InfoContext(r.Context(), "cache key deleted", "key", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function to write v as a JSON response with status
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	// Handle errors while writing response
+	if err != nil {
+		s.logger.Error("error writing response", "error", err)
+	}
+}
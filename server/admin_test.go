@@ -0,0 +1,354 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+func TestLogLevel(t *testing.T) {
+	t.Parallel()
+
+	// Function to send an admin request with key and return the recorder
+	do := func(handler http.Handler, method, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/loglevel", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Changes level", func(t *testing.T) {
+		level := new(slog.LevelVar)
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithLogLevel(level)).Handler()
+
+		rec := do(handler, http.MethodPut, `{"level": "debug"}`, "admin")
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %d %q", rec.Code, rec.Body.String())
+		}
+		if level.Level() != slog.LevelDebug {
+			t.Errorf("Expected level DEBUG; got %s", level.Level())
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != `{"level":"DEBUG"}` {
+			t.Errorf("Unexpected body: %q", body)
+		}
+
+		// GET reports the new level
+		if rec := do(handler, http.MethodGet, "", "admin"); !strings.Contains(rec.Body.String(), "DEBUG") {
+			t.Errorf("Expected GET to report DEBUG; got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Rejects invalid levels", func(t *testing.T) {
+		level := new(slog.LevelVar)
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithLogLevel(level)).Handler()
+
+		for _, body := range []string{`{"level": "loud"}`, `{"level": ""}`, `not json`} {
+			if rec := do(handler, http.MethodPut, body, "admin"); rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status Bad Request for %q; got %d", body, rec.Code)
+			}
+		}
+		if level.Level() != slog.LevelInfo {
+			t.Errorf("Expected level to stay INFO; got %s", level.Level())
+		}
+	})
+
+	t.Run("Requires admin key", func(t *testing.T) {
+		level := new(slog.LevelVar)
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithLogLevel(level)).Handler()
+
+		for _, key := range []string{"", "user"} {
+			if rec := do(handler, http.MethodPut, `{"level": "debug"}`, key); rec.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status Unauthorized for key %q; got %d", key, rec.Code)
+			}
+		}
+		if level.Level() != slog.LevelInfo {
+			t.Errorf("Expected level to stay INFO; got %s", level.Level())
+		}
+	})
+
+	t.Run("Not mounted without admin auth", func(t *testing.T) {
+		level := new(slog.LevelVar)
+		handler := NewServer(
+			WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}),
+			WithLogLevel(level),
+		).Handler()
+
+		// Unmounted routes are unknown paths
+		rec := do(handler, http.MethodPut, `{"level": "debug"}`, "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404; got %d %q", rec.Code, rec.Body.String())
+		}
+		if level.Level() != slog.LevelInfo {
+			t.Errorf("Expected level to stay INFO; got %s", level.Level())
+		}
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	// Function to send an admin request to path and return the recorder
+	do := func(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Function to build a handler backed by a fresh in-memory store
+	newHandler := func(t *testing.T) (http.Handler, *apikey.Store) {
+		t.Helper()
+		store, err := apikey.Open("")
+		if err != nil {
+			t.Fatalf("Could not open store: %v", err)
+		}
+		return NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithKeyStore(store)).Handler(), store
+	}
+
+	t.Run("Creates, lists and revokes keys", func(t *testing.T) {
+		handler, store := newHandler(t)
+
+		rec := do(handler, http.MethodPost, "/admin/keys", `{"name": "ci", "scopes": ["jokes"], "expires_at": "2999-01-01T00:00:00Z"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status Created; got %d %q", rec.Code, rec.Body.String())
+		}
+		var created createKeyResponse
+		if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if !strings.HasPrefix(created.Secret, "jk_") || created.Key.ExpiresAt == nil {
+			t.Errorf("Unexpected created key: %+v", created)
+		}
+		if _, ok := store.Authenticate(created.Secret, apikey.ScopeJokes); !ok {
+			t.Error("Expected created secret to authenticate")
+		}
+
+		// The listing never includes secrets
+		rec = do(handler, http.MethodGet, "/admin/keys", "")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), created.Key.ID) {
+			t.Errorf("Expected key in listing; got %d %q", rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), created.Secret) {
+			t.Error("Expected listing to omit the secret")
+		}
+
+		if rec := do(handler, http.MethodDelete, "/admin/keys/"+created.Key.ID, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("Expected status No Content; got %d %q", rec.Code, rec.Body.String())
+		}
+		if _, ok := store.Authenticate(created.Secret, ""); ok {
+			t.Error("Expected revoked secret to be rejected")
+		}
+	})
+
+	t.Run("Rejects invalid keys", func(t *testing.T) {
+		handler, _ := newHandler(t)
+
+		for _, body := range []string{
+			`not json`,
+			`{"scopes": ["jokes"]}`,
+			`{"name": "ci", "scopes": ["root"]}`,
+			`{"name": "ci", "expires_at": "2000-01-01T00:00:00Z"}`,
+		} {
+			if rec := do(handler, http.MethodPost, "/admin/keys", body); rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status Bad Request for %q; got %d", body, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Sets quotas and reports stats", func(t *testing.T) {
+		handler, store := newHandler(t)
+
+		rec := do(handler, http.MethodPost, "/admin/keys", `{"name": "ci", "quota": {"daily": 5}}`)
+		var created createKeyResponse
+		json.NewDecoder(rec.Body).Decode(&created)
+		if created.Key.Quota.Daily != 5 {
+			t.Fatalf("Expected daily quota 5; got %+v", created.Key)
+		}
+
+		if rec := do(handler, http.MethodPut, "/admin/keys/"+created.Key.ID+"/quota", `{"monthly": 100}`); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %d %q", rec.Code, rec.Body.String())
+		}
+		store.Allow(created.Secret)
+
+		rec = do(handler, http.MethodGet, "/admin/keys/"+created.Key.ID+"/stats?period=day", "")
+		var stats apikey.Stats
+		if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+			t.Fatalf("Could not decode stats: %v", err)
+		}
+		if stats.Key.Quota != (apikey.Quota{Monthly: 100}) || stats.Key.Usage.DayUses != 1 || stats.Rollups == nil {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+
+		if rec := do(handler, http.MethodGet, "/admin/keys/"+created.Key.ID+"/stats?period=year", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request for unknown period; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Leaves no key behind when saving fails", func(t *testing.T) {
+		// The store's directory doesn't exist, so saving the key fails
+		store, err := apikey.Open(filepath.Join(t.TempDir(), "missing", "keys.json"))
+		if err != nil {
+			t.Fatalf("Could not open store: %v", err)
+		}
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithKeyStore(store)).Handler()
+
+		if rec := do(handler, http.MethodPost, "/admin/keys", `{"name": "ci", "quota": {"daily": 5}}`); rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status Internal Server Error; got %d", rec.Code)
+		}
+		if keys := store.List(); len(keys) != 0 {
+			t.Errorf("Expected no key, let alone an unlimited one; got %+v", keys)
+		}
+	})
+
+	t.Run("Unknown keys are not found", func(t *testing.T) {
+		handler, _ := newHandler(t)
+
+		for _, req := range []struct{ method, path, body string }{
+			{http.MethodDelete, "/admin/keys/missing", ""},
+			{http.MethodPut, "/admin/keys/missing/quota", `{"daily": 1}`},
+			{http.MethodGet, "/admin/keys/missing/stats", ""},
+		} {
+			if rec := do(handler, req.method, req.path, req.body); rec.Code != http.StatusNotFound {
+				t.Errorf("%s %s: expected status Not Found; got %d", req.method, req.path, rec.Code)
+			}
+		}
+	})
+}
+
+func TestCacheAdmin(t *testing.T) {
+	t.Parallel()
+
+	c := cache.NewMemory()
+	c.Set(joke.FallbackKey, []byte("offensive joke"), 0)
+	c.Set("other", []byte("value"), time.Hour)
+	c.Get(joke.FallbackKey)
+	c.Get("missing")
+	handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithCache(c)).Handler()
+
+	// Function to send an admin request to path and return the recorder
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", "admin")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Lists keys and counters", func(t *testing.T) {
+		rec := do(http.MethodGet, "/admin/cache")
+		var body cacheListing
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if body.Stats.Hits != 1 || body.Stats.Misses != 1 {
+			t.Errorf("Expected 1 hit and 1 miss; got %+v", body.Stats)
+		}
+		if len(body.Entries) != 2 || body.Entries[0].Key != joke.FallbackKey || body.Entries[1].ExpiresAt == nil {
+			t.Errorf("Unexpected entries: %+v", body.Entries)
+		}
+	})
+
+	t.Run("Shows a key's value", func(t *testing.T) {
+		rec := do(http.MethodGet, "/admin/cache/"+joke.FallbackKey)
+		var body cacheValue
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if body.Value != "offensive joke" || body.Size != len("offensive joke") {
+			t.Errorf("Unexpected value: %+v", body)
+		}
+		if do(http.MethodGet, "/admin/cache/missing").Code != http.StatusNotFound {
+			t.Error("Expected 404 for a missing key")
+		}
+	})
+
+	t.Run("Deletes a key", func(t *testing.T) {
+		if rec := do(http.MethodDelete, "/admin/cache/"+joke.FallbackKey); rec.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204; got %d", rec.Code)
+		}
+		if _, _, ok := c.Inspect(joke.FallbackKey); ok {
+			t.Error("Expected the key to be deleted")
+		}
+		if do(http.MethodDelete, "/admin/cache/"+joke.FallbackKey).Code != http.StatusNotFound {
+			t.Error("Expected 404 deleting a missing key")
+		}
+	})
+
+	t.Run("Clears the cache", func(t *testing.T) {
+		if rec := do(http.MethodDelete, "/admin/cache"); rec.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204; got %d", rec.Code)
+		}
+		if len(c.Entries()) != 0 {
+			t.Errorf("Expected an empty cache; got %v", c.Entries())
+		}
+	})
+
+	t.Run("Requires an admin key", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/cache", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401; got %d", rec.Code)
+		}
+	})
+}
+
+func TestQuit(t *testing.T) {
+	t.Parallel()
+
+	// Function to POST /admin/quitquitquit with key and return the recorder
+	do := func(handler http.Handler, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/quitquitquit", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Starts a drain", func(t *testing.T) {
+		quits := 0
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithQuit(func() { quits++ })).Handler()
+
+		rec := do(handler, "admin")
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202; got %d", rec.Code)
+		}
+		if quits != 1 {
+			t.Errorf("Expected quit to be called once; got %d", quits)
+		}
+	})
+
+	t.Run("Requires an admin key", func(t *testing.T) {
+		quits := 0
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithQuit(func() { quits++ })).Handler()
+
+		if rec := do(handler, "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401; got %d", rec.Code)
+		}
+		if quits != 0 {
+			t.Error("Expected quit not to be called")
+		}
+	})
+
+	t.Run("Not mounted without WithQuit", func(t *testing.T) {
+		handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin"))).Handler()
+		if rec := do(handler, "admin"); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404; got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// BenchmarkGetRoot measures the full / route, including the middleware chain
+// the binary installs, with upstreams replaced by fakes
+func BenchmarkGetRoot(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewServer(
+		WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}),
+		WithCache(cache.NewMemory()),
+		WithLogger(logger),
+		WithMiddleware(
+			middleware.Recover(logger),
+			middleware.Logging(logger),
+			middleware.Timeout(time.Second),
+		),
+	).Handler()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+	})
+}
+
+// BenchmarkGetHistory measures filtering and JSON encoding of a full page
+func BenchmarkGetHistory(b *testing.B) {
+	store := history.New(maxHistoryEntries)
+	for i := 0; i < maxHistoryEntries; i++ {
+		store.Add(history.Entry{Joke: "Grace Hopper can compile HTML.", Category: joke.DefaultCategory, ServedAt: time.Now()})
+	}
+	h := NewServer(WithHistory(store)).Handler()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/history?per_page=100&category=nerdy", nil))
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/calendar"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Days of jokes in /calendar.ics, today included
+const calendarDays = 30
+
+// How often calendar apps are asked to fetch /calendar.ics again
+const calendarRefresh = 12 * time.Hour
+
+/*
+	 Handler for GET /calendar.ics, an iCalendar feed of the joke of
+	 the day
+
+		Each of the last 30 days with a joke is an all-day event, its
+		summary the setup of a two-part joke or the whole joke, and
+		its description the whole joke. Days are in UTC. Like /,
+		requests with a tenant are refused categories it doesn't
+		allow, and get each joke branded with its template.
+*/
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	t := tenant.FromContext(r.Context())
+	if t != nil && !t.Allows(joke.DefaultCategory) {
+		joke.WriteError(w, s.logger, joke.ErrCategoryNotAllowed, "category "+joke.DefaultCategory+" is not allowed for this tenant")
+		return
+	}
+
+	now := time.Now()
+	days, err := s.daily.Recent(r.Context(), now, calendarDays)
+	if err != nil {
+		s.logFailure(r.Context(), "failed to get joke of the day", err)
+		joke.WriteError(w, s.logger, err, "failed to get joke of the day")
+		return
+	}
+
+	feed := calendar.Feed{Name: "Joke of the Day", Refresh: calendarRefresh}
+	// Tenants' events get UIDs of their own, their jokes being branded differently
+	uidSuffix := "@" + s.originHost()
+	if t != nil {
+		uidSuffix = "-" + t.ID + uidSuffix
+	}
+	for _, d := range days {
+		text := s.brand(r.Context(), t, d.Joke, joke.Names{FirstName: d.FirstName, LastName: d.LastName})
+		summary := text
+		if setup, _, ok := joke.SplitJoke(text); ok {
+			summary = setup
+		}
+		feed.Events = append(feed.Events, calendar.Event{
+			UID:         d.Day.Format("2006-01-02") + uidSuffix,
+			Day:         d.Day,
+			Summary:     summary,
+			Description: text,
+		})
+	}
+
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("Cache-Control", "max-age=3600")
+	// Handle errors while writing response
+	if err := feed.Write(w, now); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
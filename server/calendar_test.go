@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/calendar"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+func TestCalendar(t *testing.T) {
+	t.Parallel()
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+	})
+	jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "Why did " + firstName + " cross the road?\nTo debug it.", nil
+	})
+	handler := NewServer(WithProviders(names, jokes), WithPublicURL("https://jokes.example.com")).Handler()
+
+	// Ask twice: the joke of the day stays the same
+	var bodies []string
+	for range 2 {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+		req.Host = "attacker.example"
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != calendar.ContentType {
+			t.Errorf("Expected content type %q; got %q", calendar.ContentType, ct)
+		}
+		bodies = append(bodies, rec.Body.String())
+	}
+
+	today := time.Now().UTC().Format("20060102")
+	for _, want := range []string{
+		"UID:" + time.Now().UTC().Format("2006-01-02") + "@jokes.example.com\r\n",
+		"DTSTART;VALUE=DATE:" + today,
+		"SUMMARY:Why did Ada cross the road?\r\n",
+		`DESCRIPTION:Why did Ada cross the road?\nTo debug it.`,
+	} {
+		if !strings.Contains(bodies[0], want) {
+			t.Errorf("Expected the feed to contain %q; got %s", want, bodies[0])
+		}
+	}
+	if strings.Count(bodies[1], "BEGIN:VEVENT") != 1 {
+		t.Errorf("Expected one event for today; got %s", bodies[1])
+	}
+
+	t.Run("Applies the tenant", func(t *testing.T) {
+		reg, err := tenant.New(
+			&tenant.Tenant{ID: "acme", Name: "Acme", Template: "{{.Joke}} (Acme)"},
+			&tenant.Tenant{ID: "kids", Categories: []string{"animals"}},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		// get requests /calendar.ics as tenant id
+		get := func(id string) *httptest.ResponseRecorder {
+			tn, _ := reg.Get(id)
+			req := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(tenant.NewContext(req.Context(), tn)))
+			return rec
+		}
+
+		body := get("acme").Body.String()
+		for _, want := range []string{
+			"UID:" + time.Now().UTC().Format("2006-01-02") + "-acme@jokes.example.com\r\n",
+			`DESCRIPTION:Why did Ada cross the road?\nTo debug it. (Acme)`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected the feed to contain %q; got %s", want, body)
+			}
+		}
+
+		if rec := get("kids"); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden for a disallowed category; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Reports upstream failures", func(t *testing.T) {
+		failing := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", joke.ErrJokeUpstream
+		})
+		rec := httptest.NewRecorder()
+		NewServer(WithProviders(names, failing)).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendar.ics", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("Expected status Bad Gateway; got %v", rec.Code)
+		}
+	})
+}
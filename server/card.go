@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/meme"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+/*
+	 permalinkPage offers a shortlink as an HTML page of the joke
+
+		Its OpenGraph and Twitter card tags carry the joke and its
+		image, GET /jokes/{id}.png, so links shared in chat and social
+		apps unfurl with the joke visible.
+*/
+var permalinkPage = render.Format{
+	Name:        "html",
+	ContentType: "text/html; charset=utf-8",
+	Encode: func(w io.Writer, v any) error {
+		card, ok := v.(jokeCard)
+		if !ok {
+			return fmt.Errorf("server: cannot write %T as a page", v)
+		}
+		return cardPage.Execute(w, card)
+	},
+}
+
+// Page of a shortlink, see permalinkPage
+var cardPage = template.Must(template.New("card").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.URL}}">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbed}}">
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="Joke Generator">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.Image}}">
+<meta property="og:image:type" content="image/png">
+<meta property="og:image:width" content="{{.Width}}">
+<meta property="og:image:height" content="{{.Height}}">
+<meta property="og:image:alt" content="{{.Title}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.Image}}">
+<style>body{font-family:system-ui,sans-serif;max-width:40rem;margin:4rem auto;padding:0 1rem;line-height:1.5}img{max-width:100%;height:auto}</style>
+</head>
+<body>
+<main>
+<blockquote id="joke">
+{{- range .Lines}}
+<p>{{.}}</p>
+{{- end}}
+</blockquote>
+<p>— {{.Description}}</p>
+<p><img src="{{.Image}}" width="{{.Width}}" height="{{.Height}}" alt=""></p>
+<p><a href="{{.PDF}}">Print this joke</a></p>
+</main>
+</body>
+</html>
+`))
+
+// struct to hold the data of a shortlink's page
+type jokeCard struct {
+	// Title is the joke on one line
+	Title       string
+	Lines       []string
+	Description string
+	URL         string
+	Image       string
+	PDF         string
+	OEmbed      string
+	Width       int
+	Height      int
+}
+
+// Function to return the data of e's page, whose shortlink is link
+func (s *Server) newJokeCard(e history.Entry, link string) jokeCard {
+	origin, id := s.origin(), strconv.Itoa(e.ID)
+	lines := strings.Split(strings.TrimSpace(e.Joke), "\n")
+	return jokeCard{
+		Title:       strings.Join(lines, " "),
+		Lines:       lines,
+		Description: shareTitle(e),
+		URL:         link,
+		Image:       origin + "/jokes/" + id + ".png",
+		PDF:         origin + "/jokes/" + id + ".pdf",
+		OEmbed:      origin + "/oembed?" + url.Values{"url": {link}}.Encode(),
+		Width:       meme.Width,
+		Height:      meme.Height,
+	}
+}
+
+/*
+	 Handler for GET /jokes/{id}.png, a served joke drawn as an image
+	 for link previews
+
+		Jokes are found as for GET /jokes/{id}.pdf. Served jokes don't
+		change, so the image may be cached for a day.
+*/
+func (s *Server) handleJokePNG(w http.ResponseWriter, r *http.Request, id int) {
+	e, found := s.history.Get(id, tenant.ID(r.Context()))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	caption := ""
+	if name := strings.TrimSpace(e.FirstName + " " + e.LastName); name != "" {
+		caption = "Starring " + name
+	}
+	w.Header().Set("Content-Type", meme.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	// Handle errors while writing response
+	if err := meme.Write(w, e.Joke, caption); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/meme"
+)
+
+func TestJokeCard(t *testing.T) {
+	t.Parallel()
+
+	served := history.New(10)
+	served.Add(history.Entry{Joke: "Why did Ada cross the road?\nTo <debug> it.", FirstName: "Ada", LastName: "Lovelace"})
+	served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+	handler := NewServer(WithHistory(served), WithPublicURL("http://jokes.example.com")).Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://jokes.example.com"+path, nil))
+		return rec
+	}
+
+	t.Run("Tags the page for link previews", func(t *testing.T) {
+		rec := get("/j/1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`<meta property="og:title" content="Why did Ada cross the road? To &lt;debug&gt; it.">`,
+			`<meta property="og:description" content="Joke #1 starring Ada Lovelace">`,
+			`<meta property="og:url" content="http://jokes.example.com/j/1">`,
+			`<meta property="og:image" content="http://jokes.example.com/jokes/1.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+			`<meta name="twitter:image" content="http://jokes.example.com/jokes/1.png">`,
+			`type="application/json+oembed" href="http://jokes.example.com/oembed?url=http%3A%2F%2Fjokes.example.com%2Fj%2F1"`,
+			"<p>To &lt;debug&gt; it.</p>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected the page to contain %s; got %s", want, body)
+			}
+		}
+	})
+
+	t.Run("Draws the image", func(t *testing.T) {
+		rec := get("/jokes/1.png")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("Expected a PNG; got %v, %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if size := img.Bounds().Size(); size.X != meme.Width || size.Y != meme.Height {
+			t.Errorf("Expected %dx%d; got %v", meme.Width, meme.Height, size)
+		}
+	})
+
+	t.Run("Doesn't draw other jokes", func(t *testing.T) {
+		for _, path := range []string{"/jokes/2.png", "/jokes/9.png", "/jokes/1.gif", "/jokes/1.pdf.png"} {
+			if rec := get(path); rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status Not Found; got %v", path, rec.Code)
+			}
+		}
+	})
+}
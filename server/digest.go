@@ -0,0 +1,63 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// Page confirming an unsubscribe, posted back to the same link so link scanners don't unsubscribe anyone
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body style="font-family: sans-serif;">
+{{- if .Done}}
+<p>{{.Email}} is unsubscribed from the joke digest.</p>
+{{- else}}
+<form method="post">
+<p>Stop sending the joke digest to {{.Email}}?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{- end}}
+</body></html>
+`))
+
+// struct to hold the data of the unsubscribe page
+type unsubscribeData struct {
+	Email string
+	Done  bool
+}
+
+/*
+	 Handler for GET and POST /digest/unsubscribe, the link in each
+	 digest email
+
+		?email= and ?sig= come from the link. GET asks to confirm;
+		POST, sent by the confirmation or by mail clients offering
+		one-click unsubscribing, unsubscribes. Links whose signature
+		doesn't match the address are refused with a 403.
+*/
+func (s *Server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	email := q.Get("email")
+	if email == "" || !s.digest.Valid(email, q.Get("sig")) {
+		http.Error(w, "invalid unsubscribe link", http.StatusForbidden)
+		return
+	}
+
+	data := unsubscribeData{Email: email}
+	if r.Method == http.MethodPost {
+		if err := s.digest.Unsubscribe(email); err != nil {
+			s.logger.ErrorContext(r.Context(), "failed to unsubscribe", "error", err)
+			http.Error(w, "failed to unsubscribe", http.StatusInternalServerError)
+			return
+		}
+		s.logger.InfoContext(r.Context(), "unsubscribed from digest")
+		data.Done = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// Handle errors while writing response
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
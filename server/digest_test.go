@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/digest"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/trending"
+)
+
+func TestUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscribers.txt")
+	if err := os.WriteFile(path, []byte("ada@example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	subs := digest.NewSubscribers(path, filepath.Join(dir, "unsubscribed.txt"))
+	d := digest.New(digest.Config{
+		Subscribers: subs,
+		Top:         func(n int) []trending.Joke { return nil },
+		Secret:      []byte("secret"),
+	})
+	// Mail clients follow links without a key, but the rest of the
+	// middleware still runs
+	var seen atomic.Int32
+	counted := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	}
+	rejectAll := middleware.APIKey(func(key string) bool { return false })
+	handler := NewServer(WithDigest(d), WithMiddleware(counted, rejectAll)).Handler()
+	link := d.UnsubscribeURL("ada@example.com")
+
+	t.Run("Asks to confirm", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+			t.Errorf("Expected a confirmation form; got %v %s", rec.Code, rec.Body.String())
+		}
+		if got, _ := subs.Recipients(); len(got) != 1 {
+			t.Errorf("Expected ada still subscribed; got %v", got)
+		}
+	})
+
+	t.Run("Unsubscribes on POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, link, strings.NewReader("List-Unsubscribe=One-Click"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "is unsubscribed") {
+			t.Errorf("Expected a confirmation; got %v %s", rec.Code, rec.Body.String())
+		}
+		if got, _ := subs.Recipients(); len(got) != 0 {
+			t.Errorf("Expected ada unsubscribed; got %v", got)
+		}
+	})
+
+	t.Run("Refuses forged links", func(t *testing.T) {
+		forged := strings.Replace(link, "ada%40", "bob%40", 1)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, forged, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", rec.Code)
+		}
+	})
+	t.Run("Runs the middleware", func(t *testing.T) {
+		if n := seen.Load(); n != 3 {
+			t.Errorf("Expected 3 requests through the middleware; got %d", n)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jswanson806/joke-generator/experiment"
+)
+
+// Largest body accepted by POST /experiment/rating
+const maxRatingBody = 256
+
+// struct to hold the body of POST /experiment/rating
+type ratingRequest struct {
+	Score int `json:"score"`
+}
+
+/*
+	 Handler for POST /experiment/rating, counting a client's rating of
+	 the joke it was served under its experiment bucket
+
+		Accepts {"score": 4}, from 1 to 5, and responds 204. Clients
+		keep their bucket, so a rating is counted for the bucket that
+		served the joke.
+*/
+func (s *Server) handleRating(w http.ResponseWriter, r *http.Request) {
+	var req ratingRequest
+	// Decode the rating
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRatingBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.experiment.Rate(r.Context(), req.Score)
+	if errors.Is(err, experiment.ErrScore) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not record rating", "error", err)
+		http.Error(w, "could not record rating", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/experiment"
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestExperiment(t *testing.T) {
+	t.Parallel()
+
+	e, err := experiment.New("test", &experiment.Bucket{Name: "control", Weight: 1}, &experiment.Bucket{Name: "treatment", Weight: 1})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	e.Buckets[1].Jokes = joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		return "treatment joke", nil
+	})
+	ratings := make(map[string]int)
+	e.ObserveRating = func(bucket string, score int) { ratings[bucket] += score }
+	e.ObserveCall = func(bucket string, d time.Duration, err error) {}
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "Chuck", LastName: "Norris"}, nil
+	})
+	control := joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+		return "control joke", nil
+	})
+	handler := NewServer(WithProviders(names, e.Jokes(control)), WithExperiment(e)).Handler()
+
+	// Find a key in each bucket
+	keys := make(map[string]string)
+	for i := 0; len(keys) < 2; i++ {
+		key := strings.Repeat("k", i+1)
+		keys[e.Assign(key).Name] = key
+	}
+
+	for bucket, want := range map[string]string{"control": "control joke", "treatment": "treatment joke"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", keys[bucket])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) || rec.Header().Get(experiment.BucketHeader) != bucket {
+			t.Errorf("%s: expected %q; got %d %s", bucket, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	t.Run("Rates under the client's bucket", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/experiment/rating", strings.NewReader(`{"score": 5}`))
+		r.Header.Set("X-API-Key", keys["treatment"])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusNoContent || ratings["treatment"] != 5 || ratings["control"] != 0 {
+			t.Errorf("Expected a treatment rating; got %d, %v", rec.Code, ratings)
+		}
+	})
+
+	t.Run("Rejects invalid scores", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/experiment/rating", strings.NewReader(`{"score": 0}`)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %v", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Domain of the google.rpc.ErrorInfo details attached to gRPC errors
+const grpcErrorDomain = "joke-generator"
+
+// Interval between jokes on StreamJokes when unset, and the range allowed, as on /stream
+const (
+	defaultStreamInterval = 30 * time.Second
+	minStreamInterval     = 5 * time.Second
+	maxStreamInterval     = time.Hour
+)
+
+// Longest StreamJokes waits for one joke, as on /stream
+const streamFetchTimeout = 10 * time.Second
+
+// struct implementing the JokeService of proto/joke/v1/joke.proto
+type jokeService struct {
+	jokev1.UnimplementedJokeServiceServer
+	s *Server
+}
+
+/*
+	 GRPCServer returns a gRPC server serving JokeService, answered
+	 like the /v1 routes
+
+		opts are applied before the server's own, e.g. interceptors
+		checking GRPCKey. A call whose key belongs to a tenant is
+		served as that tenant, as HTTP requests are; the tenant's rate
+		limit is not applied.
+*/
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.grpcTenant), grpc.ChainStreamInterceptor(s.grpcStreamTenant))
+	g := grpc.NewServer(opts...)
+	jokev1.RegisterJokeServiceServer(g, &jokeService{s: s})
+	return g
+}
+
+// GRPCKey returns the API key of a gRPC call, from x-api-key or bearer authorization metadata as for HTTP requests
+func GRPCKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("x-api-key"); len(keys) > 0 && keys[0] != "" {
+		return keys[0]
+	}
+	for _, v := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// Function to add the tenant owning the call's key to ctx, if any
+func (s *Server) withKeyTenant(ctx context.Context) context.Context {
+	if s.tenants == nil || s.tenantKey == nil {
+		return ctx
+	}
+	secret := GRPCKey(ctx)
+	if secret == "" {
+		return ctx
+	}
+	if t, ok := s.tenants.ByKey(s.tenantKey(secret)); ok {
+		return tenant.NewContext(ctx, t)
+	}
+	return ctx
+}
+
+// Unary interceptor serving each call as the tenant owning its key
+func (s *Server) grpcTenant(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(s.withKeyTenant(ctx), req)
+}
+
+// Stream interceptor serving each call as the tenant owning its key
+func (s *Server) grpcStreamTenant(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &tenantStream{ServerStream: ss, ctx: s.withKeyTenant(ss.Context())})
+}
+
+// struct to hold a grpc.ServerStream whose context carries a tenant
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context with its tenant
+func (t *tenantStream) Context() context.Context {
+	return t.ctx
+}
+
+/*
+	 Function to return the routes of JokeService's google.api.http
+	 annotations, generated by grpc-gateway
+
+		Calls are answered in process by the same jokeService as
+		gRPC, with the proto's field names in JSON, as on the
+		hand-written routes.
+*/
+func (s *Server) gateway() http.Handler {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}))
+	// Registering on a fresh mux fails only for a nil server
+	if err := jokev1.RegisterJokeServiceHandlerServer(context.Background(), mux, &jokeService{s: s}); err != nil {
+		panic(err)
+	}
+	return mux
+}
+
+/*
+	 Function to return err as a gRPC status with msg
+
+		The REST error code, e.g. "upstream_timeout", is attached as
+		the reason of a google.rpc.ErrorInfo detail.
+*/
+func grpcError(err error, msg string) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, joke.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, joke.ErrDecode), errors.Is(err, joke.ErrNameUpstream), errors.Is(err, joke.ErrJokeUpstream):
+		code = codes.Unavailable
+	case errors.Is(err, joke.ErrCategoryNotAllowed):
+		code = codes.PermissionDenied
+	}
+	st, detailErr := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{Reason: joke.ErrorCode(err), Domain: grpcErrorDomain})
+	if detailErr != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// GetJoke returns a joke like joke.get over JSON-RPC
+func (j *jokeService) GetJoke(ctx context.Context, _ *jokev1.GetJokeRequest) (*jokev1.Joke, error) {
+	name, text, err := j.s.tenantJoke(ctx)
+	if err != nil {
+		if errors.Is(err, joke.ErrCategoryNotAllowed) {
+			return nil, grpcError(err, "category "+joke.DefaultCategory+" is not allowed for this tenant")
+		}
+		return nil, grpcError(err, "failed to get joke")
+	}
+	setup, delivery, _ := joke.SplitJoke(text)
+	return &jokev1.Joke{
+		Joke:      text,
+		Category:  joke.DefaultCategory,
+		FirstName: name.FirstName,
+		LastName:  name.LastName,
+		Setup:     setup,
+		Delivery:  delivery,
+	}, nil
+}
+
+// GetName returns a random name
+func (j *jokeService) GetName(ctx context.Context, _ *jokev1.GetNameRequest) (*jokev1.Name, error) {
+	name, err := j.s.names.Name(ctx)
+	if err != nil {
+		j.s.logFailure(ctx, "failed to get name", err)
+		return nil, grpcError(err, "failed to get name")
+	}
+	return &jokev1.Name{FirstName: name.FirstName, LastName: name.LastName}, nil
+}
+
+/*
+	 ListHistory pages through the jokes served to the call's tenant,
+	 newest first
+
+		Zero page and per_page default as on /history.
+*/
+func (j *jokeService) ListHistory(ctx context.Context, req *jokev1.ListHistoryRequest) (*jokev1.ListHistoryResponse, error) {
+	f := history.Filter{
+		Page:     int(req.GetPage()),
+		PerPage:  int(req.GetPerPage()),
+		Category: req.GetCategory(),
+		Tenant:   tenant.ID(ctx),
+	}
+	if f.Page == 0 {
+		f.Page = 1
+	}
+	if f.PerPage == 0 {
+		f.PerPage = defaultHistoryPerPage
+	}
+	if f.Page < 1 || f.PerPage < 1 || f.PerPage > maxHistoryPerPage {
+		return nil, status.Errorf(codes.InvalidArgument, "page must be positive and per_page 1-%d", maxHistoryPerPage)
+	}
+	if req.Since != nil {
+		f.Since = req.GetSince().AsTime()
+	}
+
+	entries, total := j.s.history.List(f)
+	return historyPage{Page: f.Page, PerPage: f.PerPage, Total: total, Entries: entries}.Proto().(*jokev1.ListHistoryResponse), nil
+}
+
+/*
+	 StreamJokes sends a joke every interval until the client cancels
+
+		The first joke is sent at once. The interval is counted from
+		when a joke was sent, and the next one is only fetched then, so
+		a client slow to receive holds the stream back through gRPC flow
+		control instead of jokes queueing up. Jokes that fail to fetch
+		are skipped. Only DefaultCategory can be asked for, as on
+		/stream.
+*/
+func (j *jokeService) StreamJokes(req *jokev1.StreamRequest, stream grpc.ServerStreamingServer[jokev1.Joke]) error {
+	interval := defaultStreamInterval
+	if req.Interval != nil {
+		if err := req.GetInterval().CheckValid(); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		interval = req.GetInterval().AsDuration()
+	}
+	if interval < minStreamInterval || interval > maxStreamInterval {
+		return status.Errorf(codes.InvalidArgument, "interval must be between %s and %s", minStreamInterval, maxStreamInterval)
+	}
+	// Every joke is in DefaultCategory, so a stream of any other would never send one
+	if c := req.GetCategory(); c != "" && !strings.EqualFold(c, joke.DefaultCategory) {
+		return status.Errorf(codes.InvalidArgument, "category %s is not supported, jokes are all %s", c, joke.DefaultCategory)
+	}
+
+	ctx := stream.Context()
+	if t := tenant.FromContext(ctx); t != nil && !t.Allows(joke.DefaultCategory) {
+		return grpcError(joke.ErrCategoryNotAllowed, "category "+joke.DefaultCategory+" is not allowed for this tenant")
+	}
+	for {
+		if res, ok := j.nextStreamJoke(ctx); ok {
+			// Send blocks while the client's flow control window is full
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+
+		next := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			next.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		case <-next.C:
+		}
+	}
+}
+
+// Function to fetch the next joke of a stream, false when it fails and is skipped
+func (j *jokeService) nextStreamJoke(ctx context.Context) (*jokev1.Joke, bool) {
+	ctx, cancel := context.WithTimeout(ctx, streamFetchTimeout)
+	defer cancel()
+	res, err := j.GetJoke(ctx, &jokev1.GetJokeRequest{})
+	return res, err == nil
+}
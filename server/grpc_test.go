@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Function to serve s over an in-memory gRPC connection, returning a client
+func dialGRPC(t *testing.T, s *Server) jokev1.JokeServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	g := s.GRPCServer()
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Could not dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return jokev1.NewJokeServiceClient(conn)
+}
+
+func TestGRPC(t *testing.T) {
+	t.Parallel()
+
+	h := history.New(10)
+	h.Add(history.Entry{Joke: "old joke", Category: "nerdy", ServedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	h.Add(history.Entry{Joke: "new joke", Category: "nerdy", ServedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)})
+	h.Add(history.Entry{Joke: "acme joke", Category: "nerdy", Tenant: "acme"})
+	reg, _ := tenant.New(&tenant.Tenant{ID: "acme", Template: "{{.Joke}} (from {{.Tenant}})", Keys: []string{"acme-key"}})
+	client := dialGRPC(t, NewServer(
+		WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}),
+		WithHistory(h),
+		WithTenants(reg, func(secret string) string { return secret }),
+	))
+	ctx := context.Background()
+
+	t.Run("GetJoke", func(t *testing.T) {
+		j, err := client.GetJoke(ctx, &jokev1.GetJokeRequest{})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if j.Joke != "John Doe can divide by zero." || j.FirstName != "John" || j.Category != joke.DefaultCategory {
+			t.Errorf("Unexpected joke: %v", j)
+		}
+	})
+
+	t.Run("GetName", func(t *testing.T) {
+		n, err := client.GetName(ctx, &jokev1.GetNameRequest{})
+		if err != nil || n.FirstName != "John" || n.LastName != "Doe" {
+			t.Errorf("Expected John Doe; got %v, %v", n, err)
+		}
+	})
+
+	t.Run("ListHistory", func(t *testing.T) {
+		res, err := client.ListHistory(ctx, &jokev1.ListHistoryRequest{PerPage: 1})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if res.Page != 1 || res.Total != 2 || len(res.Entries) != 1 || res.Entries[0].Joke != "new joke" {
+			t.Errorf("Expected the newest of the 2 untenanted jokes; got %v", res)
+		}
+		if _, err := client.ListHistory(ctx, &jokev1.ListHistoryRequest{PerPage: maxHistoryPerPage + 1}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument; got %v", err)
+		}
+	})
+
+	t.Run("Serves the tenant owning the key", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(ctx, "x-api-key", "acme-key")
+		j, err := client.GetJoke(ctx, &jokev1.GetJokeRequest{})
+		if err != nil || j.Joke != "John Doe can divide by zero. (from acme)" {
+			t.Errorf("Expected a branded joke; got %v, %v", j, err)
+		}
+		res, err := client.ListHistory(ctx, &jokev1.ListHistoryRequest{})
+		if err != nil || res.Total != 1 || res.Entries[0].Joke != "acme joke" {
+			t.Errorf("Expected acme's joke; got %v, %v", res, err)
+		}
+	})
+
+	t.Run("StreamJokes sends the first joke at once", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := client.StreamJokes(ctx, &jokev1.StreamRequest{Interval: durationpb.New(time.Minute)})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		j, err := stream.Recv()
+		if err != nil || j.Joke != "John Doe can divide by zero." {
+			t.Errorf("Expected a joke; got %v, %v", j, err)
+		}
+		cancel()
+		if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+			t.Errorf("Expected the stream to end when canceled; got %v", err)
+		}
+	})
+
+	t.Run("StreamJokes rejects invalid requests", func(t *testing.T) {
+		for _, req := range []*jokev1.StreamRequest{
+			{Interval: durationpb.New(time.Second)},
+			{Interval: durationpb.New(2 * time.Hour)},
+			{Category: "dad"},
+		} {
+			stream, err := client.StreamJokes(ctx, req)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("%v: expected InvalidArgument; got %v", req, err)
+			}
+		}
+	})
+
+	t.Run("Reports upstream errors", func(t *testing.T) {
+		failing := (&joketest.FakeJokeProvider{}).Fail(joke.ErrJokeUpstream)
+		client := dialGRPC(t, NewServer(WithProviders(&joketest.FakeNameProvider{}, failing)))
+		_, err := client.GetJoke(ctx, &jokev1.GetJokeRequest{})
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("Expected Unavailable; got %v", err)
+		}
+	})
+}
+
+func TestGRPCKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		md   metadata.MD
+		want string
+	}{
+		{metadata.Pairs("x-api-key", "key-1"), "key-1"},
+		{metadata.Pairs("authorization", "Bearer key-2"), "key-2"},
+		{metadata.Pairs("authorization", "Basic key-3"), ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := GRPCKey(metadata.NewIncomingContext(context.Background(), tt.md)); got != tt.want {
+			t.Errorf("%v: expected key %q; got %q", tt.md, tt.want, got)
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	t.Parallel()
+
+	h := history.New(10)
+	h.Add(history.Entry{Joke: "a joke", Category: "nerdy", FirstName: "Ada", LastName: "Lovelace"})
+	handler := NewServer(WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}), WithHistory(h)).Handler()
+
+	// Function to GET path and decode its JSON body
+	get := func(t *testing.T, path string) (int, map[string]any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: could not decode response: %v", path, err)
+		}
+		return rec.Code, body
+	}
+
+	t.Run("Serves the proto's routes with its field names", func(t *testing.T) {
+		code, j := get(t, "/v1/joke")
+		if code != http.StatusOK || j["joke"] != "John Doe can divide by zero." || j["first_name"] != "John" {
+			t.Errorf("Unexpected /v1/joke response: %d %v", code, j)
+		}
+		code, n := get(t, "/v1/name")
+		if code != http.StatusOK || n["first_name"] != "John" || n["last_name"] != "Doe" {
+			t.Errorf("Unexpected /v1/name response: %d %v", code, n)
+		}
+	})
+
+	t.Run("Pages history with the fields of /history", func(t *testing.T) {
+		_, want := get(t, "/history")
+		_, got := get(t, "/v1/history?per_page=20")
+		for _, field := range []string{"page", "per_page", "total", "entries"} {
+			if _, ok := got[field]; !ok {
+				t.Errorf("Expected field %q in /v1/history; got %v", field, got)
+			}
+		}
+		wantEntry := want["entries"].([]any)[0].(map[string]any)
+		gotEntry := got["entries"].([]any)[0].(map[string]any)
+		for _, field := range []string{"id", "joke", "category", "first_name", "last_name", "served_at"} {
+			if _, ok := wantEntry[field]; !ok {
+				t.Errorf("Expected field %q in /history; got %v", field, wantEntry)
+			}
+			if _, ok := gotEntry[field]; !ok {
+				t.Errorf("Expected field %q in /v1/history; got %v", field, gotEntry)
+			}
+		}
+	})
+
+	t.Run("Maps gRPC errors to HTTP statuses", func(t *testing.T) {
+		if code, _ := get(t, "/v1/history?per_page=101"); code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %d", code)
+		}
+		failing := (&joketest.FakeNameProvider{}).Fail(errors.Join(joke.ErrNameUpstream, errors.New("down")))
+		rec := httptest.NewRecorder()
+		NewServer(WithProviders(failing, &joketest.FakeJokeProvider{})).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/name", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status Service Unavailable; got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jswanson806/joke-generator/auth"
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Default and maximum page sizes for the /history endpoint
+const (
+	defaultHistoryPerPage = 20
+	maxHistoryPerPage     = 100
+)
+
+// struct to hold a page of history returned by /history
+type historyPage struct {
+	Page    int             `json:"page"`
+	PerPage int             `json:"per_page"`
+	Total   int             `json:"total"`
+	Entries []history.Entry `json:"entries"`
+}
+
+// Formats /history is offered in, the default first
+var historyFormats = []render.Format{render.JSON, render.Protobuf, render.MsgPack, render.CBOR, render.YAML, render.CSV}
+
+/*
+	 csvEntry is a history.Entry as a CSV row
+
+		Every entry has every column, so the header taken from the
+		first row names them all; the tenant is left out as it is the
+		request's own.
+*/
+type csvEntry struct {
+	ID        int       `json:"id"`
+	Joke      string    `json:"joke"`
+	Category  string    `json:"category"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	ServedAt  time.Time `json:"served_at"`
+	User      string    `json:"user"`
+}
+
+// Proto returns p as a joke.v1.ListHistoryResponse message
+func (p historyPage) Proto() proto.Message {
+	res := &jokev1.ListHistoryResponse{
+		Page:    int32(p.Page),
+		PerPage: int32(p.PerPage),
+		Total:   int32(p.Total),
+		Entries: make([]*jokev1.HistoryEntry, 0, len(p.Entries)),
+	}
+	for _, e := range p.Entries {
+		res.Entries = append(res.Entries, &jokev1.HistoryEntry{
+			Id:        int64(e.ID),
+			Joke:      e.Joke,
+			Category:  e.Category,
+			FirstName: e.FirstName,
+			LastName:  e.LastName,
+			ServedAt:  timestamppb.New(e.ServedAt),
+		})
+	}
+	return res
+}
+
+/*
+	 Function to parse /history query string values into a history.Filter
+
+		Supported values: page, per_page, since (RFC 3339), category
+		and mine, which is checked by the handler.
+*/
+func parseHistoryFilter(q url.Values) (history.Filter, error) {
+	f := history.Filter{Page: 1, PerPage: defaultHistoryPerPage}
+
+	// Parse page number
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return history.Filter{}, fmt.Errorf("invalid page: %q", v)
+		}
+		f.Page = page
+	}
+
+	// Parse page size
+	if v := q.Get("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage < 1 || perPage > maxHistoryPerPage {
+			return history.Filter{}, fmt.Errorf("invalid per_page: %q (must be 1-%d)", v, maxHistoryPerPage)
+		}
+		f.PerPage = perPage
+	}
+
+	// Reject pages past the last one an int can count up to
+	if f.Page > math.MaxInt/f.PerPage {
+		return history.Filter{}, fmt.Errorf("invalid page: %q", q.Get("page"))
+	}
+
+	// Parse since timestamp
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return history.Filter{}, fmt.Errorf("invalid since: %q (must be RFC 3339)", v)
+		}
+		f.Since = since
+	}
+
+	f.Category = q.Get("category")
+	return f, nil
+}
+
+/*
+	 Function to build the Link header for a page of history
+
+		Adds rel="prev" and rel="next" links that preserve the
+		request's filters.
+*/
+func historyLinkHeader(u *url.URL, f history.Filter, total int) string {
+	link := func(page int, rel string) string {
+		q := u.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(f.PerPage))
+		return fmt.Sprintf("<%s?%s>; rel=%q", u.Path, q.Encode(), rel)
+	}
+
+	var links []string
+	if f.Page > 1 {
+		links = append(links, link(f.Page-1, "prev"))
+	}
+	if f.Page*f.PerPage < total {
+		links = append(links, link(f.Page+1, "next"))
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// Handler for GET /history
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	// Parse and validate filters
+	f, err := parseHistoryFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only ever list the jokes served to the request's tenant
+	f.Tenant = tenant.ID(r.Context())
+
+	// Limit to the signed-in user's jokes when mine=true
+	if mine, _ := strconv.ParseBool(r.URL.Query().Get("mine")); mine {
+		f.User = auth.Subject(r.Context())
+		if f.User == "" {
+			http.Error(w, "sign in to list your jokes", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	entries, total := s.history.List(f)
+	entries = ownEntries(entries, auth.Subject(r.Context()))
+
+	// Set pagination links, keeping any tenant path prefix
+	u := *r.URL
+	u.Path = tenant.Prefix(r.Context()) + u.Path
+	if link := historyLinkHeader(&u, f, total); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	// Write the page in the format the request accepts, JSON by default
+	format, ok := render.Negotiate(r, historyFormats...)
+	if !ok {
+		format = historyFormats[0]
+	}
+
+	// CSV has no room for the page fields, so stream the entries as rows;
+	// the Link header still pages through them
+	if format.Name == render.CSV.Name {
+		s.writeHistoryCSV(w, entries)
+		return
+	}
+
+	err = render.Write(w, http.StatusOK, format, historyPage{
+		Page:    f.Page,
+		PerPage: f.PerPage,
+		Total:   total,
+		Entries: entries,
+	})
+	// Handle errors while writing response
+	if err != nil {
+		s.logger.Error("error writing history response", "error", err)
+	}
+}
+
+/*
+	 Function to return entries with the user left out of those not
+	 served to user
+
+		Who each joke was served to is only shown to that user, so
+		/history never reveals other users' identities.
+*/
+func ownEntries(entries []history.Entry, user string) []history.Entry {
+	own := make([]history.Entry, len(entries))
+	for i, e := range entries {
+		if user == "" || e.User != user {
+			e.User = ""
+		}
+		own[i] = e
+	}
+	return own
+}
+
+// Function to stream entries as CSV rows
+func (s *Server) writeHistoryCSV(w http.ResponseWriter, entries []history.Entry) {
+	stream := render.NewStream(w, render.CSV)
+	for _, e := range entries {
+		// Handle errors while writing a row; the status is already sent
+		if err := stream.Write(csvEntry{
+			ID:        e.ID,
+			Joke:      e.Joke,
+			Category:  e.Category,
+			FirstName: e.FirstName,
+			LastName:  e.LastName,
+			ServedAt:  e.ServedAt,
+			User:      e.User,
+		}); err != nil {
+			s.logger.Error("error writing history response", "error", err)
+			return
+		}
+	}
+	if err := stream.Close(); err != nil {
+		s.logger.Error("error writing history response", "error", err)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jswanson806/joke-generator/cache"
+	jokev1 "github.com/jswanson806/joke-generator/gen/joke/v1"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+func TestGetHistory(t *testing.T) {
+	t.Parallel()
+
+	// Populate the history served by the handler
+	h := history.New(10)
+	for i := 0; i < 3; i++ {
+		h.Add(history.Entry{Joke: "joke", Category: joke.DefaultCategory, ServedAt: time.Now()})
+	}
+	handler := NewServer(WithHistory(h)).Handler()
+
+	t.Run("Returns page with Link header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/history?page=2&per_page=1&category=nerdy", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		// Check the status code for 200
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+
+		// Verify both links are present and keep the filters
+		link := rec.Header().Get("Link")
+		for _, want := range []string{
+			`</history?category=nerdy&page=1&per_page=1>; rel="prev"`,
+			`</history?category=nerdy&page=3&per_page=1>; rel="next"`,
+		} {
+			if !strings.Contains(link, want) {
+				t.Errorf("Expected Link header to contain %q; got %q", want, link)
+			}
+		}
+
+		// Verify the body decodes into the expected page
+		var page historyPage
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if page.Total != 3 || len(page.Entries) != 1 || page.Entries[0].ID != 2 {
+			t.Errorf("Unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("Serves protobuf when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/history?per_page=1", nil)
+		req.Header.Set("Accept", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		var page jokev1.ListHistoryResponse
+		if rec.Header().Get("Content-Type") != "application/x-protobuf" {
+			t.Fatalf("Unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+		}
+		if err := proto.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Could not decode joke.v1.ListHistoryResponse: %v", err)
+		}
+		// The newest of the 3 entries, ID 3, on a page of 1
+		if page.Page != 1 || page.PerPage != 1 || page.Total != 3 || len(page.Entries) != 1 || page.Entries[0].Id != 3 {
+			t.Errorf("Unexpected page %v", &page)
+		}
+	})
+
+	t.Run("Streams CSV rows when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/history?per_page=2", nil)
+		req.Header.Set("Accept", "text/csv")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("Expected Content-Type text/csv; got %s", ct)
+		}
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("Expected a header and 2 rows; got %q", rec.Body.String())
+		}
+		if want := "id,joke,category,first_name,last_name,served_at,user"; lines[0] != want {
+			t.Errorf("Expected header %q; got %q", want, lines[0])
+		}
+		if !strings.HasPrefix(lines[1], "3,joke,"+joke.DefaultCategory+",,,") {
+			t.Errorf("Expected the newest entry first; got %q", lines[1])
+		}
+		if rec.Header().Get("Link") == "" {
+			t.Error("Expected a Link header")
+		}
+	})
+
+	t.Run("Requires sign in for mine", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?mine=true", nil))
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Shows only the caller's own user", func(t *testing.T) {
+		sessions := session.NewManager(cache.NewMemory())
+		signIn := httptest.NewRecorder()
+		sessions.Start(signIn, 0, map[string]string{"sub": "alice"})
+		uh := history.New(10)
+		uh.Add(history.Entry{Joke: "alice joke", User: "alice"})
+		uh.Add(history.Entry{Joke: "bob joke", User: "bob"})
+		userHandler := NewServer(WithHistory(uh), WithSessions(sessions)).Handler()
+
+		// Function to list history, signed in as alice unless anonymous
+		list := func(anonymous bool) map[string]string {
+			req := httptest.NewRequest(http.MethodGet, "/history", nil)
+			if !anonymous {
+				req.AddCookie(signIn.Result().Cookies()[0])
+			}
+			rec := httptest.NewRecorder()
+			userHandler.ServeHTTP(rec, req)
+			var page historyPage
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			users := make(map[string]string)
+			for _, e := range page.Entries {
+				users[e.Joke] = e.User
+			}
+			return users
+		}
+
+		if users := list(true); users["alice joke"] != "" || users["bob joke"] != "" {
+			t.Errorf("Expected no users shown anonymously; got %v", users)
+		}
+		if users := list(false); users["alice joke"] != "alice" || users["bob joke"] != "" {
+			t.Errorf("Expected only alice's own user shown; got %v", users)
+		}
+	})
+
+	t.Run("Lists only the tenant's jokes", func(t *testing.T) {
+		th := history.New(10)
+		th.Add(history.Entry{Joke: "acme joke", Tenant: "acme"})
+		th.Add(history.Entry{Joke: "acme joke", Tenant: "acme"})
+		th.Add(history.Entry{Joke: "shared joke"})
+		reg, _ := tenant.New(&tenant.Tenant{ID: "acme", Keys: []string{"acme-key"}})
+		tenantHandler := NewServer(WithHistory(th), WithTenants(reg, func(secret string) string { return secret })).Handler()
+
+		req := httptest.NewRequest(http.MethodGet, "/t/acme/history?per_page=1", nil)
+		req.Header.Set("X-API-Key", "acme-key")
+		rec := httptest.NewRecorder()
+		tenantHandler.ServeHTTP(rec, req)
+
+		var page historyPage
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if page.Total != 2 || page.Entries[0].Joke != "acme joke" {
+			t.Errorf("Expected acme's two jokes; got %+v", page)
+		}
+		if link := rec.Header().Get("Link"); !strings.HasPrefix(link, "</t/acme/history?") {
+			t.Errorf("Expected Link header to keep the tenant prefix; got %q", link)
+		}
+	})
+
+	t.Run("Rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"page=0", "per_page=1000", "since=yesterday", "page=abc", "page=2305843009213693953&per_page=5"} {
+			req := httptest.NewRequest(http.MethodGet, "/history?"+query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			// Verify status code is 400
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status Bad Request; got %v", query, rec.Code)
+			}
+		}
+	})
+}
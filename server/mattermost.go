@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/mattermost"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Time allowed to fetch a joke for Mattermost, well within its response timeout
+const mattermostTimeout = 10 * time.Second
+
+// Shown to the user when no joke could be fetched
+const mattermostSorry = "Sorry, the jokes are taking a break. Try again later."
+
+// Shown to the user who asks for help or gives only one name
+const mattermostUsage = "Tell a joke with `/joke`, or star someone with `/joke Ada Lovelace`."
+
+/*
+	 Handler for POST /integrations/mattermost, answering a Mattermost
+	 slash command or outgoing webhook with a joke
+
+		A first and last name after the command, or the trigger word,
+		star that person, optionally after "about"; no words star a
+		random name. The joke is posted for the channel to see;
+		usage and failures are only shown to the user.
+*/
+func (s *Server) handleMattermost(w http.ResponseWriter, r *http.Request) {
+	req, err := mattermost.Parse(w, r, s.mattermost)
+	if errors.Is(err, mattermost.ErrToken) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	args := req.Args()
+	if len(args) > 0 && strings.EqualFold(args[0], "about") {
+		args = args[1:]
+	}
+	names, personal := s.names, false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1:
+		s.writeJSON(w, http.StatusOK, mattermost.Response{ResponseType: mattermost.Ephemeral, Text: mattermostUsage})
+		return
+	default:
+		first, last := args[0], strings.Join(args[1:], " ")
+		personal = true
+		names = joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+			return joke.Names{FirstName: first, LastName: last}, nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), mattermostTimeout)
+	defer cancel()
+	name, text, err := joke.Fetch(ctx, names, s.jokes)
+	if err != nil {
+		s.logFailure(ctx, "failed to build joke for Mattermost", err)
+		s.writeJSON(w, http.StatusOK, mattermost.Response{ResponseType: mattermost.Ephemeral, Text: mattermostSorry})
+		return
+	}
+	// Jokes about a name the user typed stay in the channel
+	if !personal {
+		s.history.Add(history.Entry{
+			Joke:      text,
+			Category:  joke.DefaultCategory,
+			FirstName: name.FirstName,
+			LastName:  name.LastName,
+			Tenant:    tenant.ID(r.Context()),
+			User:      auth.Subject(r.Context()),
+			ServedAt:  time.Now(),
+		})
+	}
+	s.writeJSON(w, http.StatusOK, mattermost.Response{ResponseType: mattermost.InChannel, Text: text})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/mattermost"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+func TestMattermost(t *testing.T) {
+	t.Parallel()
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "John", LastName: "Doe"}, nil
+	})
+	jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		if firstName == "Fail" {
+			return "", joke.ErrJokeUpstream
+		}
+		return firstName + " " + lastName + " can divide by zero.", nil
+	})
+	handler := NewServer(WithProviders(names, jokes), WithMattermost("command-token", "webhook-token")).Handler()
+
+	// post sends form to the integration, returning the status and response
+	post := func(t *testing.T, form url.Values) (int, mattermost.Response) {
+		req := httptest.NewRequest(http.MethodPost, "/integrations/mattermost", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var res mattermost.Response
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+		}
+		return rec.Code, res
+	}
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		wantType string
+		wantText string
+	}{
+		{"Tells a joke about a random name", url.Values{"token": {"command-token"}, "command": {"/joke"}}, mattermost.InChannel, "John Doe can divide by zero."},
+		{"Stars the person named", url.Values{"token": {"command-token"}, "command": {"/joke"}, "text": {"Ada Lovelace"}}, mattermost.InChannel, "Ada Lovelace can divide by zero."},
+		{"Answers outgoing webhooks", url.Values{"token": {"webhook-token"}, "trigger_word": {"joke"}, "text": {"joke about Ada Lovelace"}}, mattermost.InChannel, "Ada Lovelace can divide by zero."},
+		{"Shows usage for one name", url.Values{"token": {"command-token"}, "text": {"help"}}, mattermost.Ephemeral, mattermostUsage},
+		{"Apologizes to the user on failure", url.Values{"token": {"command-token"}, "text": {"Fail Caller"}}, mattermost.Ephemeral, mattermostSorry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, res := post(t, tt.form)
+			if code != http.StatusOK || res.ResponseType != tt.wantType || res.Text != tt.wantText {
+				t.Errorf("Expected %s %q; got %v %+v", tt.wantType, tt.wantText, code, res)
+			}
+		})
+	}
+
+	t.Run("Rejects unknown tokens", func(t *testing.T) {
+		if code, _ := post(t, url.Values{"token": {"other"}}); code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", code)
+		}
+	})
+	t.Run("Runs the middleware and records the tenant", func(t *testing.T) {
+		served := history.New(10)
+		acme := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), &tenant.Tenant{ID: "acme"})))
+			})
+		}
+		rejectAll := middleware.APIKey(func(key string) bool { return false })
+		handler := NewServer(WithProviders(names, jokes), WithHistory(served), WithMattermost("command-token"), WithMiddleware(acme, rejectAll)).Handler()
+
+		form := url.Values{"token": {"command-token"}, "command": {"/joke"}}
+		req := httptest.NewRequest(http.MethodPost, "/integrations/mattermost", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if entries, total := served.List(history.Filter{Page: 1, PerPage: 10, Tenant: "acme"}); total != 1 || entries[0].Tenant != "acme" {
+			t.Errorf("Expected one joke recorded for acme; got %+v", entries)
+		}
+	})
+	t.Run("Doesn't record jokes about names typed", func(t *testing.T) {
+		served := history.New(10)
+		handler := NewServer(WithProviders(names, jokes), WithHistory(served), WithMattermost("command-token")).Handler()
+
+		form := url.Values{"token": {"command-token"}, "command": {"/joke"}, "text": {"Ada Lovelace"}}
+		req := httptest.NewRequest(http.MethodPost, "/integrations/mattermost", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if _, total := served.List(history.Filter{Page: 1, PerPage: 10}); total != 0 {
+			t.Errorf("Expected no joke recorded; got %d", total)
+		}
+	})
+}
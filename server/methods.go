@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Methods routes may be registered for, in the order the Allow header lists them
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+/*
+	 Function to answer OPTIONS and unsupported methods for the routes
+	 of mux
+
+		OPTIONS gets a 204 listing the path's methods in Allow, and a
+		method the path has no route for gets a 405 with the same
+		list. Paths with no routes are left to mux's 404. GET routes
+		serve HEAD too, as in http.ServeMux, with the body discarded
+		by the server.
+*/
+func allowMethods(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			allow := allowedMethods(mux, r)
+			if len(allow) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Replace mux's 405, whose Allow header leaves out OPTIONS
+		if _, pattern := mux.Handler(r); pattern == "" {
+			if allow := allowedMethods(mux, r); len(allow) > 0 {
+				w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Function to return the methods mux has a route for at the request's path
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allow []string
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allow = append(allow, method)
+		}
+	}
+	return allow
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joketest"
+)
+
+func TestMethods(t *testing.T) {
+	t.Parallel()
+
+	names := &joketest.FakeNameProvider{}
+	handler := NewServer(WithProviders(names, &joketest.FakeJokeProvider{})).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{"GET serves a joke", http.MethodGet, "/", http.StatusOK, ""},
+		{"POST to / is not allowed", http.MethodPost, "/", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"OPTIONS lists /", http.MethodOptions, "/", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"OPTIONS lists /rpc", http.MethodOptions, "/rpc", http.StatusNoContent, "POST, OPTIONS"},
+		{"GET /rpc is not allowed", http.MethodGet, "/rpc", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+		{"DELETE /history is not allowed", http.MethodDelete, "/history", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"Unknown paths are not found", http.MethodGet, "/nope", http.StatusNotFound, ""},
+		{"OPTIONS on unknown paths is not found", http.MethodOptions, "/nope", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d; got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Expected Allow %q; got %q", tt.allow, got)
+			}
+		})
+	}
+
+	t.Run("HEAD sends headers without fetching a joke", func(t *testing.T) {
+		for _, path := range []string{"/", "/jokes?count=5"} {
+			before := names.Calls()
+			req := httptest.NewRequest(http.MethodHead, path, nil)
+			req.Header.Set("Accept", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status OK for %s; got %d", path, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type application/json for %s; got %q", path, ct)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("Expected no body for %s; got %q", path, rec.Body.String())
+			}
+			if names.Calls() != before {
+				t.Errorf("Expected no name to be fetched for %s", path)
+			}
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/submission"
+)
+
+// struct to hold the body of POST /admin/submissions/{id}/approve and /reject
+type reviewRequest struct {
+	Note string `json:"note"`
+}
+
+/*
+	 Handler for GET /admin/submissions, listing submissions oldest
+	 first
+
+		?status= is pending by default; approved, rejected or all
+		list the others.
+*/
+func (s *Server) handleListSubmissions(w http.ResponseWriter, r *http.Request) {
+	status := submission.Status(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = submission.Pending
+	case "all":
+		status = ""
+	case submission.Pending, submission.Approved, submission.Rejected:
+	default:
+		http.Error(w, "status must be pending, approved, rejected or all", http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string][]submission.Submission{"submissions": s.submissions.List(status)})
+}
+
+// Handler for GET /admin/submissions/{id}, returning the submission with its audit trail
+func (s *Server) handleGetSubmission(w http.ResponseWriter, r *http.Request) {
+	id, ok := submissionID(w, r)
+	if !ok {
+		return
+	}
+	sub, err := s.submissions.Get(id)
+	if err != nil {
+		s.writeReviewError(w, r, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, sub)
+}
+
+/*
+	 Handler for PATCH /admin/submissions/{id}, editing a pending
+	 submission before it is approved
+
+		Accepts {"joke": "...", "category": "..."}; either may be
+		left out to keep it. Both are checked as on submission.
+*/
+func (s *Server) handleEditSubmission(w http.ResponseWriter, r *http.Request) {
+	id, ok := submissionID(w, r)
+	if !ok {
+		return
+	}
+	var req submitRequest
+	// Decode the edit
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := s.submissions.Get(id)
+	if err != nil {
+		s.writeReviewError(w, r, err)
+		return
+	}
+	if req.Joke == "" {
+		req.Joke = sub.Joke
+	}
+	if req.Category == "" {
+		req.Category = sub.Category
+	}
+	text, category, err := cleanSubmission(req.Joke, req.Category)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err = s.submissions.Edit(id, moderator(r), text, category)
+	if err != nil {
+		s.writeReviewError(w, r, err)
+		return
+	}
+	s.logger.InfoContext(r.Context(), "submission edited", "id", id, "moderator", moderator(r))
+	s.writeJSON(w, http.StatusOK, sub)
+}
+
+// Handler for POST /admin/submissions/{id}/approve, adding the submission to the jokes served
+func (s *Server) handleApproveSubmission(w http.ResponseWriter, r *http.Request) {
+	s.review(w, r, s.submissions.Approve)
+}
+
+// Handler for POST /admin/submissions/{id}/reject, keeping the submission from being served
+func (s *Server) handleRejectSubmission(w http.ResponseWriter, r *http.Request) {
+	s.review(w, r, s.submissions.Reject)
+}
+
+/*
+	 Function to approve or reject the request's submission with decide
+
+		Accepts an optional {"note": "..."} recorded in the audit
+		trail, e.g. why a joke was rejected.
+*/
+func (s *Server) review(w http.ResponseWriter, r *http.Request, decide func(id int64, by, note string) (submission.Submission, error)) {
+	id, ok := submissionID(w, r)
+	if !ok {
+		return
+	}
+	var req reviewRequest
+	// Decode the optional note; an empty body has none
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := decide(id, moderator(r), req.Note)
+	if err != nil {
+		s.writeReviewError(w, r, err)
+		return
+	}
+	s.logger.InfoContext(r.Context(), "submission reviewed", "id", id, "status", sub.Status, "moderator", moderator(r))
+	s.writeJSON(w, http.StatusOK, sub)
+}
+
+// Function to write the response for a failed review
+func (s *Server) writeReviewError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, submission.ErrNotFound):
+		http.Error(w, "submission not found", http.StatusNotFound)
+	case errors.Is(err, submission.ErrNotPending):
+		http.Error(w, "submission was already reviewed", http.StatusConflict)
+	default:
+		s.logger.ErrorContext(r.Context(), "could not review submission", "error", err)
+		http.Error(w, "could not review submission", http.StatusInternalServerError)
+	}
+}
+
+// Function to read the {id} path value, writing a 404 when it isn't one
+func submissionID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "submission not found", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// Function to return the moderator recorded in the audit trail: the
+// fingerprint of the admin's API key, which is safe to keep
+func moderator(r *http.Request) string {
+	return apikey.Fingerprint(middleware.RequestKey(r))
+}
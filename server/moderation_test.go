@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/submission"
+)
+
+func TestModeration(t *testing.T) {
+	t.Parallel()
+
+	store, _ := submission.Open("")
+	handler := NewServer(WithAdminAuth(middleware.StaticKeys("admin")), WithSubmissions(store)).Handler()
+
+	// Function to send an admin request to path and return the recorder
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Edits then approves a submission", func(t *testing.T) {
+		sub, _ := store.Submit(submission.Submission{Joke: "{{.FirstName}} is funy.", Category: "nerdy", Author: "alice"})
+		path := "/admin/submissions/" + strconv.FormatInt(sub.ID, 10)
+
+		if rec := do(http.MethodPatch, path, `{"joke": "{{.FirstName}} is funny."}`); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v: %s", rec.Code, rec.Body.String())
+		}
+		rec := do(http.MethodPost, path+"/approve", `{"note": "fixed a typo"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v: %s", rec.Code, rec.Body.String())
+		}
+		var got submission.Submission
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if got.Status != submission.Approved || got.Joke != "{{.FirstName}} is funny." {
+			t.Errorf("Unexpected submission: %+v", got)
+		}
+
+		// The audit trail records every action and who took it
+		if len(got.Audit) != 3 || got.Audit[1].Action != submission.ActionEdited || got.Audit[2].Note != "fixed a typo" {
+			t.Errorf("Unexpected audit trail: %+v", got.Audit)
+		}
+		if by := got.Audit[2].By; !strings.HasPrefix(by, "sha256:") {
+			t.Errorf("Expected the admin key's fingerprint; got %q", by)
+		}
+
+		// Reviewed submissions can't be reviewed again
+		if rec := do(http.MethodPost, path+"/reject", ""); rec.Code != http.StatusConflict {
+			t.Errorf("Expected status Conflict; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Lists pending submissions", func(t *testing.T) {
+		store.Submit(submission.Submission{Joke: "{{.Name}} waits.", Category: "nerdy"})
+		rec := do(http.MethodGet, "/admin/submissions", "")
+		var body map[string][]submission.Submission
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		for _, sub := range body["submissions"] {
+			if sub.Status != submission.Pending {
+				t.Errorf("Expected only pending submissions; got %+v", sub)
+			}
+		}
+		if rec := do(http.MethodGet, "/admin/submissions?status=maybe", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Rejects invalid edits and unknown submissions", func(t *testing.T) {
+		sub, _ := store.Submit(submission.Submission{Joke: "{{.Name}} refactors the coffee machine.", Category: "nerdy"})
+		if rec := do(http.MethodPatch, "/admin/submissions/"+strconv.FormatInt(sub.ID, 10), `{"joke": "no placeholder"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %v", rec.Code)
+		}
+		for _, path := range []string{"/admin/submissions/99/approve", "/admin/submissions/abc/approve"} {
+			if rec := do(http.MethodPost, path, ""); rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status Not Found; got %v", path, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jswanson806/joke-generator/render"
+)
+
+// Default and longest wait of a /joke/next request, in seconds
+const (
+	defaultNextWait = 25
+	maxNextWait     = 120
+)
+
+// Time left to answer a /joke/next request before its deadline
+const nextDeadlineMargin = 500 * time.Millisecond
+
+/*
+	 Handler for GET /joke/next, a long-poll for the next scheduled joke
+
+		?after= is the ID of the last joke the client got, 0 by
+		default; a later joke is returned at once, otherwise the
+		request is held until one is published or ?wait= seconds pass,
+		25 by default and at most 120, then answered with a 204. The
+		route is exempt from the server's -timeout, so the wait is
+		capped here; it still ends early enough to answer before any
+		other deadline on the request.
+*/
+func (s *Server) handleNext(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var after int64
+	if v := q.Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		// Handle IDs that aren't a number
+		if err != nil || n < 0 {
+			http.Error(w, "after must be a joke ID", http.StatusBadRequest)
+			return
+		}
+		after = n
+	}
+	wait := defaultNextWait
+	if v := q.Get("wait"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Handle waits that aren't a number in range
+		if err != nil || n < 0 || n > maxNextWait {
+			http.Error(w, fmt.Sprintf("wait must be between 0 and %d seconds", maxNextWait), http.StatusBadRequest)
+			return
+		}
+		wait = n
+	}
+
+	// Stop waiting in time to answer before the request's own deadline
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	if d, ok := r.Context().Deadline(); ok && d.Add(-nextDeadlineMargin).Before(deadline) {
+		deadline = d.Add(-nextDeadlineMargin)
+	}
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	j, err := s.publisher.Next(ctx, after)
+	if err != nil {
+		// The client went away; nobody is left to answer
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, j); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
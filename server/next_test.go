@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestGetNext(t *testing.T) {
+	t.Parallel()
+
+	p := joke.NewPublisher(nil, nil, time.Hour, nil)
+	p.Publish(joke.Published{Joke: "first"})
+	handler := NewServer(WithPublisher(p)).Handler()
+
+	t.Run("Returns a joke past after at once", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/next", nil))
+
+		// Check the status code for 200
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var j joke.Published
+		if err := json.NewDecoder(rec.Body).Decode(&j); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if j.ID != 1 || j.Joke != "first" {
+			t.Errorf("Unexpected joke: %+v", j)
+		}
+	})
+
+	t.Run("Treats an ID past the latest as stale", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/next?after=41&wait=0", nil))
+
+		var j joke.Published
+		if err := json.NewDecoder(rec.Body).Decode(&j); err != nil || rec.Code != http.StatusOK || j.ID != 1 {
+			t.Errorf("Expected joke 1; got %v, %+v, %v", rec.Code, j, err)
+		}
+	})
+
+	t.Run("Answers 204 when no joke is published in time", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/next?after=1&wait=0", nil))
+
+		// Check the status code for 204
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("Expected status No Content; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Stops waiting before the request's deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), nextDeadlineMargin+50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/joke/next?after=1", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent || ctx.Err() != nil {
+			t.Errorf("Expected status No Content before the deadline; got %v, %v", rec.Code, ctx.Err())
+		}
+	})
+
+	t.Run("Rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"after=x", "after=-1", "wait=x", "wait=121"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/next?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status Bad Request; got %v", query, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Not served without a publisher", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		NewServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/next", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status Not Found; got %v", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/shortid"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Width of embedded jokes unless the consumer asks for less, in pixels
+const oembedWidth = 500
+
+// How long consumers may cache an embed, in seconds
+const oembedCacheAge = 86400
+
+// struct to hold an oEmbed response for a joke, a rich embed of its text
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+/*
+	 Handler for GET /oembed, the oEmbed (https://oembed.com) endpoint
+	 for shortlinks
+
+		?url= must be a shortlink of this server, /j/{short}; other
+		URLs, and jokes that aren't found, are Not Found as the spec
+		asks. Only JSON is offered, so ?format=xml is Not Implemented.
+		The embed is a blockquote of the joke whose height is
+		estimated from its length.
+*/
+func (s *Server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if f := query.Get("format"); f != "" && f != "json" {
+		http.Error(w, "only json is supported", http.StatusNotImplemented)
+		return
+	}
+	e, found := s.linkedEntry(r, query.Get("url"))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	width := oembedWidth
+	if n, err := strconv.Atoi(query.Get("maxwidth")); err == nil && n > 0 && n < width {
+		width = n
+	}
+	height := oembedHeight(e.Joke, width)
+	if n, err := strconv.Atoi(query.Get("maxheight")); err == nil && n > 0 && n < height {
+		height = n
+	}
+	link := s.permalink(e)
+	s.writeJSON(w, http.StatusOK, oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Joke Generator",
+		ProviderURL:  s.origin() + "/",
+		Title:        shareTitle(e),
+		AuthorName:   strings.TrimSpace(e.FirstName + " " + e.LastName),
+		CacheAge:     oembedCacheAge,
+		HTML:         oembedHTML(e, link, width),
+		Width:        width,
+		Height:       height,
+	})
+}
+
+// Function to find the joke a shortlink of this server points to
+func (s *Server) linkedEntry(r *http.Request, link string) (history.Entry, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Host != s.originHost() {
+		return history.Entry{}, false
+	}
+	short, ok := strings.CutPrefix(u.Path, "/j/")
+	if !ok {
+		return history.Entry{}, false
+	}
+	id, err := shortid.Decode(short)
+	if err != nil {
+		return history.Entry{}, false
+	}
+	return s.history.Get(id, tenant.ID(r.Context()))
+}
+
+// Function to lay out e as a blockquote, a paragraph a line, signed with a link to it
+func oembedHTML(e history.Entry, link string, width int) string {
+	var b strings.Builder
+	b.WriteString(`<blockquote class="joke" style="max-width:` + strconv.Itoa(width) + `px;margin:0">`)
+	for _, line := range strings.Split(strings.TrimSpace(e.Joke), "\n") {
+		b.WriteString("<p>" + html.EscapeString(line) + "</p>")
+	}
+	b.WriteString(`<footer>— <a href="` + html.EscapeString(link) + `">` + html.EscapeString(shareTitle(e)) + "</a></footer>")
+	b.WriteString("</blockquote>")
+	return b.String()
+}
+
+// Function to estimate the height of joke embedded width pixels wide,
+// at about 8 pixels a character and 24 a line, plus the footer
+func oembedHeight(joke string, width int) int {
+	perLine := max(width/8, 1)
+	lines := 1
+	for _, line := range strings.Split(strings.TrimSpace(joke), "\n") {
+		lines += 1 + len([]rune(line))/perLine
+	}
+	return lines*24 + 32
+}
+
+// Function to link the oEmbed endpoint from a shortlink response, for
+// consumers discovering it
+func (s *Server) oembedLink(w http.ResponseWriter, link string) {
+	endpoint := s.origin() + "/oembed?" + url.Values{"url": {link}}.Encode()
+	w.Header().Add("Link", "<"+endpoint+`>; rel="alternate"; type="application/json+oembed"`)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestOEmbed(t *testing.T) {
+	t.Parallel()
+
+	served := history.New(10)
+	served.Add(history.Entry{Joke: "Why did Ada cross the road?\nTo <debug> it.", FirstName: "Ada", LastName: "Lovelace"})
+	served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+	handler := NewServer(WithHistory(served), WithPublicURL("http://jokes.example.com")).Handler()
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://jokes.example.com/oembed?"+query.Encode(), nil))
+		return rec
+	}
+
+	t.Run("Embeds a shortlink", func(t *testing.T) {
+		rec := get(url.Values{"url": {"http://jokes.example.com/j/1"}, "maxwidth": {"300"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var got oembedResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.Version != "1.0" || got.Type != "rich" || got.Title != "Joke #1 starring Ada Lovelace" || got.AuthorName != "Ada Lovelace" {
+			t.Errorf("Expected a rich embed of joke 1; got %+v", got)
+		}
+		if got.Width != 300 || got.Height <= 0 {
+			t.Errorf("Expected a width of 300 and a height; got %d by %d", got.Width, got.Height)
+		}
+		for _, want := range []string{"<p>To &lt;debug&gt; it.</p>", `<a href="http://jokes.example.com/j/1">`} {
+			if !strings.Contains(got.HTML, want) {
+				t.Errorf("Expected the HTML to contain %q; got %s", want, got.HTML)
+			}
+		}
+	})
+
+	t.Run("Is discoverable from shortlinks", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://jokes.example.com/j/1", nil))
+		want := `<http://jokes.example.com/oembed?url=http%3A%2F%2Fjokes.example.com%2Fj%2F1>; rel="alternate"; type="application/json+oembed"`
+		if got := rec.Header().Get("Link"); got != want {
+			t.Errorf("Expected %s; got %s", want, got)
+		}
+	})
+
+	t.Run("Only supports JSON", func(t *testing.T) {
+		rec := get(url.Values{"url": {"http://jokes.example.com/j/1"}, "format": {"xml"}})
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("Expected status Not Implemented; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Doesn't embed other URLs", func(t *testing.T) {
+		for _, link := range []string{"", "http://other.example.com/j/1", "http://jokes.example.com/jokes/1.pdf", "http://jokes.example.com/j/2", "http://jokes.example.com/j/0"} {
+			if rec := get(url.Values{"url": {link}}); rec.Code != http.StatusNotFound {
+				t.Errorf("%q: expected status Not Found; got %v", link, rec.Code)
+			}
+		}
+	})
+}
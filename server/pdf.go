@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Margin around the printed joke, in points
+const pdfMargin = 72
+
+// Size of a US Letter page, in points
+const (
+	pdfWidth  = 612
+	pdfHeight = 792
+)
+
+// Font sizes tried for the printed joke, largest first, until it fits the page
+var pdfSizes = []float64{28, 22, 18, 14, 11}
+
+// struct to hold an RGB color of the printed joke
+type pdfColor struct {
+	r, g, b int
+}
+
+// Colors of the printed joke
+var (
+	pdfBlack  = pdfColor{0, 0, 0}
+	pdfBrand  = pdfColor{41, 97, 189}
+	pdfYellow = pdfColor{255, 204, 51}
+	pdfGray   = pdfColor{102, 102, 102}
+	pdfWhite  = pdfColor{255, 255, 255}
+)
+
+/*
+	 Handler for GET /jokes/{id}.{ext}, a served joke as a file
+
+		{id} is the joke's ID in /history. Jokes dropped from history,
+		or served to another tenant, are not found.
+*/
+func (s *Server) handleJokeFile(w http.ResponseWriter, r *http.Request) {
+	name, ext, _ := strings.Cut(r.PathValue("file"), ".")
+	id, err := strconv.Atoi(name)
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+	switch ext {
+	case "pdf":
+		s.handleJokePDF(w, r, id)
+	case "png":
+		s.handleJokePNG(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Handler for GET /jokes/{id}.pdf, a served joke laid out on a printable page
+func (s *Server) handleJokePDF(w http.ResponseWriter, r *http.Request, id int) {
+	e, found := s.history.Get(id, tenant.ID(r.Context()))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Lay out the whole page first, so layout errors still get a 500
+	var b bytes.Buffer
+	if err := printableJoke(e, s.permalink(e)).Output(&b); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to lay out joke", "error", err)
+		http.Error(w, "failed to print joke", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="joke-`+strconv.Itoa(e.ID)+`.pdf"`)
+	// Handle errors while writing response
+	if _, err := b.WriteTo(w); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+/*
+	 Function to lay out e on a US Letter page
+
+		A banner with the logo tops the page; the joke follows in the
+		largest size that fits, its punchline in bold; who it stars,
+		when it was served and its permalink, link, close the page.
+		Text uses the fonts built into every PDF reader, so characters
+		outside Windows-1252 print as ".".
+*/
+func printableJoke(e history.Entry, link string) *gofpdf.Fpdf {
+	doc := gofpdf.New("P", "pt", "Letter", "")
+	doc.SetTitle("Joke #"+strconv.Itoa(e.ID), true)
+	doc.SetProducer("joke-generator", false)
+	doc.SetAutoPageBreak(false, 0)
+	doc.SetCellMargin(0)
+	doc.AddPage()
+	tr := doc.UnicodeTranslatorFromDescriptor("")
+	width := float64(pdfWidth - 2*pdfMargin)
+	// text draws s with its baseline starting at x, y
+	text := func(x, y float64, style string, size float64, c pdfColor, s string) {
+		doc.SetFont("Helvetica", style, size)
+		doc.SetTextColor(c.r, c.g, c.b)
+		doc.Text(x, y, tr(s))
+	}
+	// fill sets the color shapes are filled with
+	fill := func(c pdfColor) {
+		doc.SetFillColor(c.r, c.g, c.b)
+	}
+
+	// Banner with a smiling face for a logo
+	fill(pdfBrand)
+	doc.Rect(0, 0, pdfWidth, 100, "F")
+	fill(pdfYellow)
+	doc.Circle(pdfMargin+26, 50, 26, "F")
+	fill(pdfBlack)
+	doc.Circle(pdfMargin+17, 42, 3.5, "F")
+	doc.Circle(pdfMargin+35, 42, 3.5, "F")
+	doc.Ellipse(pdfMargin+26, 60, 14, 9, 0, "F")
+	fill(pdfYellow)
+	doc.Ellipse(pdfMargin+26, 56, 15, 8, 0, "F")
+	text(pdfMargin+68, 60, "B", 28, pdfWhite, "Joke Generator")
+
+	// Joke, in the largest size whose lines fit between banner and footer
+	setup, delivery, twoPart := joke.SplitJoke(e.Joke)
+	if !twoPart {
+		setup = e.Joke
+	}
+	top, bottom := float64(100+pdfMargin), float64(pdfHeight-pdfMargin-90)
+	var size float64
+	var setupLines, deliveryLines [][]byte
+	for _, size = range pdfSizes {
+		doc.SetFont("Helvetica", "", size)
+		setupLines = doc.SplitLines([]byte(tr(setup)), width)
+		deliveryLines = nil
+		if twoPart {
+			doc.SetFont("Helvetica", "B", size)
+			deliveryLines = doc.SplitLines([]byte(tr(delivery)), width)
+		}
+		if height := float64(len(setupLines)+len(deliveryLines)+1) * size * 1.35; top+height <= bottom {
+			break
+		}
+	}
+	y := top + size
+	for _, line := range setupLines {
+		doc.SetFont("Helvetica", "", size)
+		doc.SetTextColor(0, 0, 0)
+		doc.Text(pdfMargin, y, string(line))
+		y += size * 1.35
+	}
+	y += size * 0.65
+	for _, line := range deliveryLines {
+		doc.SetFont("Helvetica", "B", size)
+		doc.SetTextColor(0, 0, 0)
+		doc.Text(pdfMargin, y, string(line))
+		y += size * 1.35
+	}
+
+	// Attribution
+	fill(pdfGray)
+	doc.Rect(pdfMargin, pdfHeight-pdfMargin-71, width, 1, "F")
+	if e.FirstName != "" {
+		text(pdfMargin, pdfHeight-pdfMargin-46, "", 14, pdfBlack, "Starring "+strings.TrimSpace(e.FirstName+" "+e.LastName))
+	}
+	text(pdfMargin, pdfHeight-pdfMargin-26, "", 10, pdfGray, "Joke #"+strconv.Itoa(e.ID)+", served "+e.ServedAt.UTC().Format("2 January 2006"))
+	doc.SetFont("Helvetica", "", 10)
+	text(pdfMargin+width-doc.GetStringWidth(tr(link)), pdfHeight-pdfMargin-26, "", 10, pdfGray, link)
+	return doc
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestJokePDF(t *testing.T) {
+	t.Parallel()
+
+	served := history.New(10)
+	served.Add(history.Entry{Joke: "Why did Ada cross the road?\nTo debug it.", FirstName: "Ada", LastName: "Lovelace", ServedAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)})
+	served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+	served.Add(history.Entry{Joke: strings.Repeat("Ada can divide by zero. ", 200)})
+	handler := NewServer(WithHistory(served), WithPublicURL("http://example.com")).Handler()
+
+	t.Run("Renders a served joke", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/1.pdf", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("Expected a PDF; got %q", ct)
+		}
+		if !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-1.")) {
+			t.Errorf("Expected a PDF header; got %q", rec.Body.Bytes()[:min(rec.Body.Len(), 10)])
+		}
+		content := pdfContent(t, rec.Body.Bytes())
+		for _, want := range []string{"(Why did Ada cross the road?) Tj", "(To debug it.) Tj", "(Starring Ada Lovelace) Tj", "(Joke #1, served 16 October 2026) Tj", "(http://example.com/j/1) Tj"} {
+			if !strings.Contains(content, want) {
+				t.Errorf("Expected the PDF to contain %q; got %s", want, content)
+			}
+		}
+	})
+
+	t.Run("Shrinks long jokes to fit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/3.pdf", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if content := pdfContent(t, rec.Body.Bytes()); !strings.Contains(content, " 11.00 Tf") {
+			t.Errorf("Expected the smallest size; got %s", content)
+		}
+	})
+
+	t.Run("Doesn't find other jokes", func(t *testing.T) {
+		for _, path := range []string{"/jokes/2.pdf", "/jokes/9.pdf", "/jokes/1", "/jokes/x.pdf", "/jokes/0.pdf"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status Not Found; got %v", path, rec.Code)
+			}
+		}
+	})
+}
+
+// Function to return the inflated content streams of the PDF in body
+func pdfContent(t *testing.T, body []byte) string {
+	t.Helper()
+	var content strings.Builder
+	for {
+		_, rest, ok := bytes.Cut(body, []byte("/FlateDecode"))
+		if !ok {
+			return content.String()
+		}
+		_, rest, _ = bytes.Cut(rest, []byte("stream\n"))
+		stream, after, _ := bytes.Cut(rest, []byte("\nendstream"))
+		r, err := zlib.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("Could not inflate stream: %v", err)
+		}
+		if _, err := io.Copy(&content, r); err != nil {
+			t.Fatalf("Could not inflate stream: %v", err)
+		}
+		body = after
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/render"
+)
+
+// How long a /joke/setup token can be exchanged for its punchline
+const revealTTL = 10 * time.Minute
+
+// Longest token accepted by /joke/punchline, well above any joke's
+const maxRevealToken = 8 << 10
+
+// Errors reported for tokens that can't be exchanged
+var (
+	errRevealToken   = errors.New("invalid token")
+	errRevealExpired = errors.New("token expired")
+)
+
+// struct to hold the response of /joke/setup
+type revealSetup struct {
+	Setup     string    `json:"setup"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// struct to hold the response of /joke/punchline
+type revealPunchline struct {
+	Delivery string `json:"delivery"`
+	Joke     string `json:"joke"`
+}
+
+// struct to hold what a token seals: the joke, its delivery and its expiry
+type revealClaims struct {
+	Joke     string `json:"joke"`
+	Delivery string `json:"delivery"`
+	Expires  int64  `json:"exp"`
+}
+
+// Function to seal c into a URL-safe token
+func (s *Server) sealReveal(c revealClaims) (string, error) {
+	return s.reveal.Seal(c)
+}
+
+// Function to open a token sealed by sealReveal, checking it hasn't expired
+func (s *Server) openReveal(tok string, now time.Time) (revealClaims, error) {
+	var c revealClaims
+	if err := s.reveal.Open(tok, &c); err != nil {
+		return revealClaims{}, errRevealToken
+	}
+	if !now.Before(time.Unix(c.Expires, 0)) {
+		return revealClaims{}, errRevealExpired
+	}
+	return c, nil
+}
+
+/*
+	 Handler for GET /joke/setup, the first half of a joke told in
+	 two steps
+
+		Returns the setup of a random joke with a token for
+		/joke/punchline, valid for 10 minutes. A joke told in one part
+		is set up by naming who it's about, its whole text being the
+		punchline. Like /, requests with a tenant are refused
+		categories it doesn't allow, and get the joke branded with its
+		template.
+*/
+func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
+	name, text, err := s.tenantJoke(r.Context())
+	if errors.Is(err, joke.ErrCategoryNotAllowed) {
+		joke.WriteError(w, s.logger, err, "category "+joke.DefaultCategory+" is not allowed for this tenant")
+		return
+	}
+	if err != nil {
+		if joke.ClientGone(r.Context()) {
+			w.WriteHeader(joke.StatusClientClosedRequest)
+			return
+		}
+		joke.WriteError(w, s.logger, err, "failed to get joke")
+		return
+	}
+
+	setup, delivery, ok := joke.SplitJoke(text)
+	// Tease a one-part joke with its subject, saving it all for the punchline
+	if !ok {
+		setup, delivery = fmt.Sprintf("Here's one about %s %s...", name.FirstName, name.LastName), text
+	}
+	expires := time.Now().Add(revealTTL).Truncate(time.Second)
+	token, err := s.sealReveal(revealClaims{Joke: text, Delivery: delivery, Expires: expires.Unix()})
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to seal token", "error", err)
+		http.Error(w, "failed to set up joke", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, revealSetup{Setup: setup, Token: token, ExpiresAt: expires.UTC()}); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+/*
+	 Handler for GET /joke/punchline, the delivery of the joke set up
+	 by /joke/setup
+
+		?token= is the token /joke/setup returned. A token can be
+		exchanged any number of times until it expires, after which
+		the request is answered with a 410.
+*/
+func (s *Server) handlePunchline(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" || len(token) > maxRevealToken {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	c, err := s.openReveal(token, time.Now())
+	// Handle tokens this server didn't issue, or issued too long ago
+	if errors.Is(err, errRevealExpired) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, revealPunchline{Delivery: c.Delivery, Joke: c.Joke}); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+func TestReveal(t *testing.T) {
+	t.Parallel()
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+	})
+	// reveal is run with each joke below
+	reveal := func(t *testing.T, text string) (revealSetup, revealPunchline) {
+		jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return text, nil
+		})
+		handler := NewServer(WithProviders(names, jokes)).Handler()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/setup", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var setup revealSetup
+		if err := json.NewDecoder(rec.Body).Decode(&setup); err != nil {
+			t.Fatalf("Could not decode setup: %v", err)
+		}
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/punchline?token="+url.QueryEscape(setup.Token), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var punchline revealPunchline
+		if err := json.NewDecoder(rec.Body).Decode(&punchline); err != nil {
+			t.Fatalf("Could not decode punchline: %v", err)
+		}
+		return setup, punchline
+	}
+
+	t.Run("Reveals a two-part joke in two steps", func(t *testing.T) {
+		t.Parallel()
+		setup, punchline := reveal(t, "Why did Ada cross the road?\nTo debug the other side.")
+		if setup.Setup != "Why did Ada cross the road?" || punchline.Delivery != "To debug the other side." {
+			t.Errorf("Unexpected reveal: %+v, %+v", setup, punchline)
+		}
+		if setup.ExpiresAt.Before(time.Now()) {
+			t.Errorf("Expected the token to expire later; got %v", setup.ExpiresAt)
+		}
+	})
+
+	t.Run("Saves a one-part joke for the punchline", func(t *testing.T) {
+		t.Parallel()
+		setup, punchline := reveal(t, "Ada can divide by zero.")
+		if setup.Setup != "Here's one about Ada Lovelace..." || punchline.Delivery != "Ada can divide by zero." {
+			t.Errorf("Unexpected reveal: %+v, %+v", setup, punchline)
+		}
+	})
+
+	t.Run("Rejects missing, forged and expired tokens", func(t *testing.T) {
+		t.Parallel()
+		s := NewServer()
+		expired, err := s.sealReveal(revealClaims{Joke: "old", Delivery: "old", Expires: time.Now().Add(-time.Second).Unix()})
+		if err != nil {
+			t.Fatalf("Could not seal token: %v", err)
+		}
+		if _, err := s.openReveal(expired, time.Now()); !errors.Is(err, errRevealExpired) {
+			t.Errorf("Expected the token to have expired; got %v", err)
+		}
+		issued, _ := NewServer().sealReveal(revealClaims{Joke: "x", Delivery: "x", Expires: time.Now().Add(time.Minute).Unix()})
+
+		handler := s.Handler()
+		for token, want := range map[string]int{
+			"":                       http.StatusBadRequest,
+			"not-a-token":            http.StatusBadRequest,
+			url.QueryEscape(issued):  http.StatusBadRequest,
+			url.QueryEscape(expired): http.StatusGone,
+		} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/punchline?token="+token, nil))
+			if rec.Code != want {
+				t.Errorf("%q: expected status %v; got %v", token, want, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Servers sharing a secret exchange each other's tokens", func(t *testing.T) {
+		t.Parallel()
+		secret := []byte("shared secret")
+		issued, err := NewServer(WithRevealSecret(secret)).sealReveal(revealClaims{Joke: "x", Delivery: "y", Expires: time.Now().Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatalf("Could not seal token: %v", err)
+		}
+		if c, err := NewServer(WithRevealSecret(secret)).openReveal(issued, time.Now()); err != nil || c.Delivery != "y" {
+			t.Errorf("Expected another server to open the token; got %+v, %v", c, err)
+		}
+		if _, err := NewServer(WithRevealSecret([]byte("other secret"))).openReveal(issued, time.Now()); !errors.Is(err, errRevealToken) {
+			t.Errorf("Expected a server with another secret to refuse the token; got %v", err)
+		}
+	})
+
+	t.Run("Reports upstream failures", func(t *testing.T) {
+		t.Parallel()
+		jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "", joke.ErrJokeUpstream
+		})
+		rec := httptest.NewRecorder()
+		NewServer(WithProviders(names, jokes)).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/setup", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("Expected status Bad Gateway; got %v", rec.Code)
+		}
+	})
+	t.Run("Applies the tenant", func(t *testing.T) {
+		t.Parallel()
+		reg, err := tenant.New(
+			&tenant.Tenant{ID: "acme", Name: "Acme", Template: "{{.Joke}} (Acme)"},
+			&tenant.Tenant{ID: "kids", Categories: []string{"animals"}},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+			return "Ada can divide by zero.", nil
+		})
+		handler := NewServer(WithProviders(names, jokes)).Handler()
+		// get requests /joke/setup as tenant id
+		get := func(id string) *httptest.ResponseRecorder {
+			tn, _ := reg.Get(id)
+			req := httptest.NewRequest(http.MethodGet, "/joke/setup", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(tenant.NewContext(req.Context(), tn)))
+			return rec
+		}
+
+		var setup revealSetup
+		if err := json.NewDecoder(get("acme").Body).Decode(&setup); err != nil {
+			t.Fatalf("Could not decode setup: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/joke/punchline?token="+url.QueryEscape(setup.Token), nil))
+		var punchline revealPunchline
+		if err := json.NewDecoder(rec.Body).Decode(&punchline); err != nil {
+			t.Fatalf("Could not decode punchline: %v", err)
+		}
+		if punchline.Joke != "Ada can divide by zero. (Acme)" {
+			t.Errorf("Expected the branded joke; got %q", punchline.Joke)
+		}
+
+		if rec := get("kids"); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden for a disallowed category; got %v", rec.Code)
+		}
+	})
+}
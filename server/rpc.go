@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	// rpcUpstreamError and rpcNotAllowed are in the range reserved for server errors
+	rpcUpstreamError = -32000
+	rpcNotAllowed    = -32001
+)
+
+// Limits on /rpc request bodies and batches
+const (
+	maxRPCBody  = 64 << 10
+	maxRPCBatch = 20
+)
+
+// struct to hold a JSON-RPC 2.0 request or notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID is absent for notifications, which get no response
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+// struct to hold a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// struct to hold a JSON-RPC 2.0 error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// struct to hold the data of errors from the upstream providers
+type rpcErrorData struct {
+	Code string `json:"code"`
+}
+
+// struct to hold the result of joke.get
+type rpcJoke struct {
+	Joke      string `json:"joke"`
+	Setup     string `json:"setup,omitempty"`
+	Delivery  string `json:"delivery,omitempty"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Function to return an error response to the call with id
+func rpcFailure(id json.RawMessage, code int, message string) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: message}, ID: nullID(id)}
+}
+
+// Function to return id, or null when the request had none
+func nullID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+/*
+	 Handler for POST /rpc implementing JSON-RPC 2.0
+
+		Methods are joke.get and name.get, neither taking params. Like
+		/, joke.get refuses tenants the joke's category isn't allowed
+		for, and brands the joke with the tenant's template.
+		Batches of up to maxRPCBatch calls run concurrently and are
+		answered in order. Notifications, calls without an id, are run
+		but not answered; a request of only notifications gets a 204.
+*/
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBody))
+	if err != nil {
+		s.writeJSON(w, http.StatusOK, rpcFailure(nil, rpcParseError, "could not read request: "+err.Error()))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	// A single call
+	if len(body) == 0 || body[0] != '[' {
+		res := s.rpcCall(r.Context(), body)
+		if res == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	// A batch of calls
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		s.writeJSON(w, http.StatusOK, rpcFailure(nil, rpcParseError, "invalid JSON: "+err.Error()))
+		return
+	}
+	if len(batch) == 0 {
+		s.writeJSON(w, http.StatusOK, rpcFailure(nil, rpcInvalidRequest, "empty batch"))
+		return
+	}
+	if len(batch) > maxRPCBatch {
+		s.writeJSON(w, http.StatusOK, rpcFailure(nil, rpcInvalidRequest, "batch too large"))
+		return
+	}
+
+	results := make([]*rpcResponse, len(batch))
+	var wg sync.WaitGroup
+	for i, call := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.rpcCall(r.Context(), call)
+		}()
+	}
+	wg.Wait()
+
+	// Drop the notifications, which get no response
+	responses := make([]*rpcResponse, 0, len(results))
+	for _, res := range results {
+		if res != nil {
+			responses = append(responses, res)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, responses)
+}
+
+// Function to run one call, returning nil for notifications
+func (s *Server) rpcCall(ctx context.Context, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		// Calls that aren't objects are invalid; broken JSON can't be parsed
+		if json.Valid(raw) {
+			return rpcFailure(nil, rpcInvalidRequest, "request must be an object")
+		}
+		return rpcFailure(nil, rpcParseError, "invalid JSON: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, `jsonrpc must be "2.0" and method is required`)
+	}
+
+	result, rpcErr := s.rpcDispatch(ctx, req)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if rpcErr != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+// Function to run the method of req
+func (s *Server) rpcDispatch(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	// Neither method takes params; allow them empty
+	switch p := string(bytes.TrimSpace(req.Params)); p {
+	case "", "null", "{}", "[]":
+	default:
+		return nil, &rpcError{Code: rpcInvalidParams, Message: req.Method + " takes no params"}
+	}
+
+	switch req.Method {
+	case "joke.get":
+		name, text, err := s.tenantJoke(ctx)
+		if errors.Is(err, joke.ErrCategoryNotAllowed) {
+			return nil, &rpcError{Code: rpcNotAllowed, Message: "category " + joke.DefaultCategory + " is not allowed for this tenant", Data: rpcErrorData{Code: joke.ErrorCode(err)}}
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcUpstreamError, Message: "failed to get joke", Data: rpcErrorData{Code: joke.ErrorCode(err)}}
+		}
+		setup, delivery, _ := joke.SplitJoke(text)
+		return rpcJoke{Joke: text, Setup: setup, Delivery: delivery, FirstName: name.FirstName, LastName: name.LastName}, nil
+	case "name.get":
+		name, err := s.names.Name(ctx)
+		if err != nil {
+			s.logFailure(ctx, "failed to get name", err)
+			return nil, &rpcError{Code: rpcUpstreamError, Message: "failed to get name", Data: rpcErrorData{Code: joke.ErrorCode(err)}}
+		}
+		return name, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+/*
+	 Function to fetch a joke for the tenant in ctx, if any, branded
+	 with its template
+
+		Tenants not allowed DefaultCategory get ErrCategoryNotAllowed
+		without a joke being fetched. Failures to fetch are logged.
+*/
+func (s *Server) tenantJoke(ctx context.Context) (joke.Names, string, error) {
+	t := tenant.FromContext(ctx)
+	if t != nil && !t.Allows(joke.DefaultCategory) {
+		return joke.Names{}, "", joke.ErrCategoryNotAllowed
+	}
+	name, text, err := joke.Fetch(ctx, s.names, s.jokes)
+	if err != nil {
+		s.logFailure(ctx, "failed to build joke", err)
+		return joke.Names{}, "", err
+	}
+	return name, s.brand(ctx, t, text, name), nil
+}
+
+// Function to brand text about name with t's template, if t isn't nil
+func (s *Server) brand(ctx context.Context, t *tenant.Tenant, text string, name joke.Names) string {
+	if t == nil {
+		return text
+	}
+	branded, err := t.Brand(tenant.Branding{Joke: text, FirstName: name.FirstName, LastName: name.LastName})
+	// Handle errors while branding; serve the joke unbranded
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to brand joke", "error", err)
+		return text
+	}
+	return branded
+}
+
+// Function to log a failed call, quietly when the client went away and canceled it
+func (s *Server) logFailure(ctx context.Context, msg string, err error) {
+	if joke.ClientGone(ctx) {
+		s.logger.InfoContext(ctx, "client went away", "error", err)
+		return
+	}
+	s.logger.ErrorContext(ctx, msg, "error", err)
+}
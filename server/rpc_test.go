@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+func TestRPC(t *testing.T) {
+	t.Parallel()
+
+	handler := NewServer(WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{})).Handler()
+
+	// Function to POST body to /rpc and return the status and trimmed body
+	call := func(handler http.Handler, body string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			"joke.get",
+			`{"jsonrpc": "2.0", "method": "joke.get", "id": 1}`,
+			`{"jsonrpc":"2.0","result":{"joke":"John Doe can divide by zero.","first_name":"John","last_name":"Doe"},"id":1}`,
+		},
+		{
+			"name.get",
+			`{"jsonrpc": "2.0", "method": "name.get", "params": {}, "id": "a"}`,
+			`{"jsonrpc":"2.0","result":{"first_name":"John","last_name":"Doe"},"id":"a"}`,
+		},
+		{
+			"Unknown method",
+			`{"jsonrpc": "2.0", "method": "joke.rate", "id": 2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found: joke.rate"},"id":2}`,
+		},
+		{
+			"Unexpected params",
+			`{"jsonrpc": "2.0", "method": "joke.get", "params": [1], "id": 3}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"joke.get takes no params"},"id":3}`,
+		},
+		{
+			"Wrong version",
+			`{"jsonrpc": "1.0", "method": "joke.get", "id": 4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"jsonrpc must be \"2.0\" and method is required"},"id":4}`,
+		},
+		{
+			"Parse error",
+			`{"jsonrpc": "2.0", "method"`,
+			`"code":-32700`,
+		},
+		{
+			"Empty batch",
+			`[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`,
+		},
+		{
+			"Batch in order, skipping notifications",
+			`[{"jsonrpc": "2.0", "method": "name.get", "id": 1}, {"jsonrpc": "2.0", "method": "joke.get"}, 5, {"jsonrpc": "2.0", "method": "joke.get", "id": 2}]`,
+			`[{"jsonrpc":"2.0","result":{"first_name":"John","last_name":"Doe"},"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"request must be an object"},"id":null},` +
+				`{"jsonrpc":"2.0","result":{"joke":"John Doe can divide by zero.","first_name":"John","last_name":"Doe"},"id":2}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(handler, tt.body)
+			if status != http.StatusOK {
+				t.Errorf("Expected status OK; got %d", status)
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("Expected body\n%s\ngot\n%s", tt.want, body)
+			}
+		})
+	}
+
+	t.Run("Notifications get no response", func(t *testing.T) {
+		for _, body := range []string{
+			`{"jsonrpc": "2.0", "method": "joke.get"}`,
+			`[{"jsonrpc": "2.0", "method": "joke.get"}, {"jsonrpc": "2.0", "method": "name.get"}]`,
+		} {
+			if status, got := call(handler, body); status != http.StatusNoContent || got != "" {
+				t.Errorf("Expected status No Content for %s; got %d %q", body, status, got)
+			}
+		}
+	})
+
+	t.Run("Rejects large batches", func(t *testing.T) {
+		calls := strings.Repeat(`{"jsonrpc": "2.0", "method": "name.get", "id": 1},`, maxRPCBatch+1)
+		if _, body := call(handler, "["+strings.TrimSuffix(calls, ",")+"]"); !strings.Contains(body, "batch too large") {
+			t.Errorf("Expected batch too large error; got %s", body)
+		}
+	})
+
+	t.Run("Reports upstream errors", func(t *testing.T) {
+		failing := (&joketest.FakeJokeProvider{}).Fail(fmt.Errorf("%w: %w", joke.ErrJokeUpstream, errors.New("down")))
+		failingHandler := NewServer(WithProviders(&joketest.FakeNameProvider{}, failing)).Handler()
+
+		_, body := call(failingHandler, `{"jsonrpc": "2.0", "method": "joke.get", "id": 1}`)
+		want := `{"jsonrpc":"2.0","error":{"code":-32000,"message":"failed to get joke","data":{"code":"joke_upstream_error"}},"id":1}`
+		if body != want {
+			t.Errorf("Expected body\n%s\ngot\n%s", want, body)
+		}
+	})
+	t.Run("Applies the tenant's categories and branding", func(t *testing.T) {
+		reg, err := tenant.New(
+			&tenant.Tenant{ID: "acme", Template: "{{.Joke}} (from {{.Tenant}})", Keys: []string{"acme-key"}},
+			&tenant.Tenant{ID: "puns", Categories: []string{"puns"}, Keys: []string{"puns-key"}},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		tenantHandler := NewServer(WithProviders(&joketest.FakeNameProvider{}, &joketest.FakeJokeProvider{}), WithTenants(reg, func(secret string) string { return secret })).Handler()
+		body := `{"jsonrpc": "2.0", "method": "joke.get", "id": 1}`
+
+		// Function to POST body to the tenant's /rpc
+		tenantCall := func(id string) string {
+			req := httptest.NewRequest(http.MethodPost, tenant.PathPrefix+id+"/rpc", strings.NewReader(body))
+			req.Header.Set("X-API-Key", id+"-key")
+			rec := httptest.NewRecorder()
+			tenantHandler.ServeHTTP(rec, req)
+			return strings.TrimSpace(rec.Body.String())
+		}
+		if got := tenantCall("acme"); !strings.Contains(got, `"joke":"John Doe can divide by zero. (from acme)"`) {
+			t.Errorf("Expected a branded joke; got %s", got)
+		}
+		want := `{"jsonrpc":"2.0","error":{"code":-32001,"message":"category nerdy is not allowed for this tenant","data":{"code":"category_not_allowed"}},"id":1}`
+		if got := tenantCall("puns"); got != want {
+			t.Errorf("Expected body\n%s\ngot\n%s", want, got)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/search"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Default and maximum results of a /jokes/search request
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// struct to hold the results returned by /jokes/search
+type searchResults struct {
+	Query   string          `json:"query"`
+	Total   int             `json:"total"`
+	Results []search.Result `json:"results"`
+}
+
+/*
+	 Handler for GET /jokes/search, searching the jokes served to the
+	 request's tenant
+
+		?q= is required: words match fuzzily, "quoted phrases"
+		exactly, and every one must match. ?limit= caps the results,
+		20 by default and at most 100.
+*/
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := q.Get("q")
+	switch {
+	case query == "":
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	case len(query) > search.MaxQueryLen:
+		http.Error(w, fmt.Sprintf("q must be at most %d bytes", search.MaxQueryLen), http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: %q (must be 1-%d)", v, maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, total := s.search.Search(tenant.ID(r.Context()), query, limit)
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, searchResults{Query: query, Total: total, Results: results}); err != nil {
+		s.logger.Error("error writing search response", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/search"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	h := history.New(10)
+	handler := NewServer(WithHistory(h), WithSearch(search.New(10))).Handler()
+
+	// Jokes added to history after the server is built are indexed
+	h.Add(history.Entry{Joke: "Chuck Norris can divide by zero.", Category: "nerdy"})
+	h.Add(history.Entry{Joke: "John Doe's code compiles the first time.", Category: "nerdy"})
+
+	t.Run("Returns matching jokes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/search?q=divde", nil))
+
+		// Check the status code for 200
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var body searchResults
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if body.Total != 1 || body.Results[0].Highlight != "Chuck Norris can <mark>divide</mark> by zero." {
+			t.Errorf("Unexpected results: %+v", body)
+		}
+	})
+
+	t.Run("Rejects invalid queries", func(t *testing.T) {
+		for _, query := range []string{"", "q=zero&limit=0", "q=zero&limit=101"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/search?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%q: expected status Bad Request; got %v", query, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,566 @@
+// Package server assembles the joke generator HTTP server.
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jswanson806/joke-generator/apikey"
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/digest"
+	"github.com/jswanson806/joke-generator/experiment"
+	"github.com/jswanson806/joke-generator/feature"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/metrics"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/search"
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/sla"
+	"github.com/jswanson806/joke-generator/submission"
+	"github.com/jswanson806/joke-generator/teams"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/token"
+	"github.com/jswanson806/joke-generator/translate"
+	"github.com/jswanson806/joke-generator/trending"
+	"github.com/jswanson806/joke-generator/ui"
+)
+
+// Address the server listens on when WithAddr is not given
+const DefaultAddr = "127.0.0.1:3000"
+
+// Maximum number of served jokes kept in history
+const maxHistoryEntries = 1000
+
+/*
+	 Server holds the dependencies shared by every route
+
+		Build one with NewServer; tests inject fakes through the
+		same Options the binary uses.
+*/
+type Server struct {
+	addr        string
+	publicURL   string
+	names       joke.NameProvider
+	jokes       joke.JokeProvider
+	cache       cache.Cache
+	history     *history.Store
+	features    *feature.Flags
+	metrics     *metrics.Registry
+	adminAuth   func(key string) bool
+	auth        *auth.Handler
+	sessions    *session.Manager
+	logLevel    *slog.LevelVar
+	keys        *apikey.Store
+	tenants     *tenant.Registry
+	tenantKey   func(secret string) string
+	ui          *ui.UI
+	publisher   *joke.Publisher
+	submissions *submission.Store
+	search      *search.Index
+	trending    *trending.Tracker
+	sla         *sla.Tracker
+	experiment  *experiment.Experiment
+	reveal      *token.Sealer
+	revealKey   []byte
+	twilioToken string
+	twilioURL   string
+	teams       *teams.Webhook
+	mattermost  []string
+	digest      *digest.Digest
+	daily       *joke.DailyJokes
+	translator  translate.Translator
+	langCache   cache.Cache
+	ready       func() bool
+	quit        func()
+	logger      *slog.Logger
+	middleware  []middleware.Middleware
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAddr sets the address the server listens on
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+/*
+	 WithPublicURL sets the URL clients reach the server at, e.g.
+	 https://jokes.example.com
+
+		Permalinks, shortlink pages, oEmbed and sitemap URLs start
+		with it, never with the request's Host, which clients
+		control. Without it they start with http:// and the address
+		the server listens on.
+*/
+func WithPublicURL(base string) Option {
+	return func(s *Server) {
+		s.publicURL = strings.TrimSuffix(base, "/")
+	}
+}
+
+// WithProviders sets the name and joke providers used to build jokes
+func WithProviders(names joke.NameProvider, jokes joke.JokeProvider) Option {
+	return func(s *Server) {
+		s.names = names
+		s.jokes = jokes
+	}
+}
+
+// WithCache sets the cache holding the fallback joke served when a provider
+// fails. Without a cache, provider failures are returned as errors.
+func WithCache(c cache.Cache) Option {
+	return func(s *Server) {
+		s.cache = c
+	}
+}
+
+// WithHistory sets the store recording served jokes
+func WithHistory(store *history.Store) Option {
+	return func(s *Server) {
+		s.history = store
+	}
+}
+
+// WithFeatures sets the feature flags gating optional behaviors.
+// Without flags every feature is off.
+func WithFeatures(f *feature.Flags) Option {
+	return func(s *Server) {
+		s.features = f
+	}
+}
+
+// WithMetrics serves reg at GET /metrics. Providers are not instrumented
+// by the server; wrap them with reg.Names and reg.Jokes before WithProviders.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(s *Server) {
+		s.metrics = reg
+	}
+}
+
+// WithAdminAuth mounts the /admin routes, accepting requests whose API key
+// passes valid. Without it the admin routes are not served.
+func WithAdminAuth(valid func(key string) bool) Option {
+	return func(s *Server) {
+		s.adminAuth = valid
+	}
+}
+
+// WithKeyStore lets admins create, list and revoke the API keys in store
+// through /admin/keys. Checking keys on requests is left to middleware.
+func WithKeyStore(store *apikey.Store) Option {
+	return func(s *Server) {
+		s.keys = store
+	}
+}
+
+/*
+	 WithTenants serves the tenants in reg, selected by API key or by a
+	 /t/{id}/ path prefix the key's tenant matches
+
+		keyID maps a request's API key to the key IDs listed in the
+		tenants file.
+*/
+func WithTenants(reg *tenant.Registry, keyID func(secret string) string) Option {
+	return func(s *Server) {
+		s.tenants = reg
+		s.tenantKey = keyID
+	}
+}
+
+// WithPublisher lets clients long-poll p's scheduled jokes at GET /joke/next
+func WithPublisher(p *joke.Publisher) Option {
+	return func(s *Server) {
+		s.publisher = p
+	}
+}
+
+// WithSubmissions lets signed-in users submit jokes to store at
+// POST /jokes/submit; see WithLogin.
+func WithSubmissions(store *submission.Store) Option {
+	return func(s *Server) {
+		s.submissions = store
+	}
+}
+
+// WithSearch indexes every joke added to history in idx and serves
+// searches of it at GET /jokes/search
+func WithSearch(idx *search.Index) Option {
+	return func(s *Server) {
+		s.search = idx
+	}
+}
+
+// WithTrending counts every joke added to history in t and serves the
+// top jokes at GET /jokes/trending
+func WithTrending(t *trending.Tracker) Option {
+	return func(s *Server) {
+		s.trending = t
+	}
+}
+
+// WithSLA serves the availability and latency t records at GET /providers/sla.
+// Providers are not wrapped by the server; wrap them with t.Names and t.Jokes.
+func WithSLA(t *sla.Tracker) Option {
+	return func(s *Server) {
+		s.sla = t
+	}
+}
+
+// WithExperiment assigns every request a bucket of e and lets clients rate
+// jokes at POST /experiment/rating. Wrap the joke provider with e.Jokes before
+// WithProviders so each bucket is served from its own source.
+func WithExperiment(e *experiment.Experiment) Option {
+	return func(s *Server) {
+		s.experiment = e
+	}
+}
+
+// WithUI serves u's page at GET /ui and its assets under /static/
+func WithUI(u *ui.UI) Option {
+	return func(s *Server) {
+		s.ui = u
+	}
+}
+
+// WithReadiness reports ready at GET /readyz, which is 503 until ready
+// returns true. Without it the server is ready as soon as it serves.
+func WithReadiness(ready func() bool) Option {
+	return func(s *Server) {
+		s.ready = ready
+	}
+}
+
+// WithQuit lets admins start a graceful shutdown with POST /admin/quitquitquit,
+// which calls quit. quit should return at once and may be called more than once.
+func WithQuit(quit func()) Option {
+	return func(s *Server) {
+		s.quit = quit
+	}
+}
+
+// WithSessions loads each request's session from m, serves its CSRF token
+// at GET /session/csrf and requires the token on unsafe requests.
+func WithSessions(m *session.Manager) Option {
+	return func(s *Server) {
+		s.sessions = m
+	}
+}
+
+// WithLogin mounts the /auth login routes of h and tracks signed-in users,
+// so the jokes they are served are recorded against them in history. It
+// implies WithSessions with h's session Manager.
+func WithLogin(h *auth.Handler) Option {
+	return func(s *Server) {
+		s.auth = h
+		s.sessions = h.Sessions()
+	}
+}
+
+/*
+	 WithTwilio answers Twilio voice calls at POST
+	 /integrations/twilio/voice, checking requests are signed with
+	 authToken
+
+		webhookURL is the URL the number's voice webhook is set to,
+		which the signatures cover; empty is the public URL, see
+		WithPublicURL, and the request's path.
+*/
+func WithTwilio(authToken, webhookURL string) Option {
+	return func(s *Server) {
+		s.twilioToken = authToken
+		s.twilioURL = webhookURL
+	}
+}
+
+// WithMattermost answers Mattermost slash commands and outgoing webhooks
+// at POST /integrations/mattermost, accepting requests carrying one of tokens.
+func WithMattermost(tokens ...string) Option {
+	return func(s *Server) {
+		s.mattermost = append(s.mattermost, tokens...)
+	}
+}
+
+// WithDigest serves the unsubscribe links in the emails d sends.
+// Sending the digest is left to the caller, e.g. with d.Run.
+func WithDigest(d *digest.Digest) Option {
+	return func(s *Server) {
+		s.digest = d
+	}
+}
+
+// WithTranslator serves jokes translated by t at GET /{lang}/joke, for each
+// of translate.Languages, e.g. /es/joke.
+func WithTranslator(t translate.Translator) Option {
+	return func(s *Server) {
+		s.translator = t
+	}
+}
+
+// WithRevealSecret seals /joke/setup tokens with a key derived from secret,
+// so every server given the same secret can exchange them, across restarts.
+// Without it each process makes its own key.
+func WithRevealSecret(secret []byte) Option {
+	return func(s *Server) {
+		s.revealKey = secret
+	}
+}
+
+// WithTeams lets admins post a joke to the Teams channel of w on demand
+// through POST /admin/teams/post. Scheduled posts are left to the caller.
+func WithTeams(w *teams.Webhook) Option {
+	return func(s *Server) {
+		s.teams = w
+	}
+}
+
+// WithLogLevel lets admins change level at runtime through /admin/loglevel.
+// level should be the one given to the logger's handler.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.logLevel = level
+	}
+}
+
+// WithLogger sets the logger used by the server
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithMiddleware wraps every route with mw, composed with middleware.Chain.
+// Middleware given first runs first; WithMiddleware may be given more than once.
+func WithMiddleware(mw ...middleware.Middleware) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
+
+/*
+	 NewServer returns a Server configured by opts
+
+		Without options the server listens on DefaultAddr, calls the
+		default upstream name and joke services and keeps history
+		in memory.
+*/
+func NewServer(opts ...Option) *Server {
+	// Defaults, overridden by opts
+	s := &Server{
+		addr:    DefaultAddr,
+		names:   &joke.HTTPNameProvider{},
+		jokes:   &joke.HTTPJokeProvider{},
+		history: history.New(maxHistoryEntries),
+		logger:  slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.reveal = token.NewSealer(s.revealKey)
+	// Keep jokes of the day with the fallback joke, so a persistent cache
+	// keeps them both
+	dailyCache := s.cache
+	if dailyCache == nil {
+		dailyCache = cache.NewMemory()
+	}
+	s.daily = joke.NewDailyJokes(s.names, s.jokes, dailyCache)
+	// Random jokes rarely repeat, so translations get a small cache of their own
+	s.langCache = cache.NewLRU(translationCacheSize)
+	if s.search != nil {
+		s.history.OnAdd(func(e history.Entry) {
+			s.search.Add(search.Doc{Joke: e.Joke, Category: e.Category, Tenant: e.Tenant})
+		})
+	}
+	if s.trending != nil {
+		s.history.OnAdd(func(e history.Entry) {
+			s.trending.Record(e.Tenant, e.Joke, e.Category, e.ServedAt)
+		})
+	}
+	return s
+}
+
+// New returns an *http.Server serving a Server configured by opts
+func New(opts ...Option) *http.Server {
+	return NewServer(opts...).HTTPServer()
+}
+
+// HTTPServer returns an *http.Server serving s's routes at its address
+func (s *Server) HTTPServer() *http.Server {
+	return &http.Server{
+		Addr:     s.addr,
+		Handler:  s.Handler(),
+		ErrorLog: slog.NewLogLogger(s.logger.Handler(), slog.LevelError),
+	}
+}
+
+// Handler returns the server's routes wrapped with the configured middleware
+func (s *Server) Handler() http.Handler {
+	// Use http.ServeMux struct instead of default multiplexer
+	mux := http.NewServeMux()
+
+	// Handlers for routes are defined below
+	mux.Handle("GET /{$}", joke.NewHandler(joke.Deps{
+		Names:    s.names,
+		Jokes:    s.jokes,
+		Cache:    s.cache,
+		History:  s.history,
+		Features: s.features,
+		User:     auth.Subject,
+		Logger:   s.logger,
+	}))
+	mux.Handle("GET /jokes", joke.NewBatchHandler(joke.Deps{
+		Names:    s.names,
+		Jokes:    s.jokes,
+		History:  s.history,
+		Features: s.features,
+		User:     auth.Subject,
+		Logger:   s.logger,
+	}))
+	mux.Handle("GET /stream", joke.NewStreamHandler(joke.Deps{
+		Names:    s.names,
+		Jokes:    s.jokes,
+		Cache:    s.cache,
+		History:  s.history,
+		Features: s.features,
+		User:     auth.Subject,
+		Logger:   s.logger,
+	}))
+	mux.HandleFunc("GET /history", s.handleHistory)
+	mux.HandleFunc("GET /joke/setup", s.handleSetup)
+	mux.HandleFunc("GET /joke/punchline", s.handlePunchline)
+	mux.HandleFunc("GET /calendar.ics", s.handleCalendar)
+	mux.HandleFunc("GET /jokes/{file}", s.handleJokeFile)
+	mux.HandleFunc("GET /jokes/{id}/share", s.handleShare)
+	mux.HandleFunc("GET /j/{short}", s.handleShortlink)
+	mux.HandleFunc("GET /oembed", s.handleOEmbed)
+	mux.HandleFunc("GET /sitemap.xml", s.handleSitemap)
+	if s.translator != nil {
+		// Languages are listed rather than matched with a wildcard,
+		// which would overlap /j/{short} and /jokes/{file}
+		for _, lang := range translate.Languages {
+			mux.HandleFunc("GET /"+lang+"/joke", s.handleTranslatedJoke(lang))
+		}
+	}
+	if s.publisher != nil {
+		mux.HandleFunc("GET /joke/next", s.handleNext)
+	}
+	if s.submissions != nil {
+		mux.HandleFunc("POST /jokes/submit", s.handleSubmit)
+	}
+	if s.search != nil {
+		mux.HandleFunc("GET /jokes/search", s.handleSearch)
+	}
+	if s.trending != nil {
+		mux.HandleFunc("GET /jokes/trending", s.handleTrending)
+	}
+	if s.sla != nil {
+		mux.HandleFunc("GET /providers/sla", s.handleSLA)
+	}
+	if s.experiment != nil {
+		mux.HandleFunc("POST /experiment/rating", s.handleRating)
+	}
+	mux.HandleFunc("POST /rpc", s.handleRPC)
+	mux.Handle("GET /v1/", s.gateway())
+	if s.metrics != nil {
+		mux.Handle("GET /metrics", s.metrics.Handler())
+	}
+	if s.ui != nil {
+		mux.HandleFunc("GET /ui", s.ui.ServePage)
+		mux.HandleFunc("GET "+ui.StaticPrefix, s.ui.ServeAsset)
+	}
+	s.mountAdmin(mux)
+
+	if s.sessions != nil {
+		mux.Handle("GET /session/csrf", s.sessions.CSRFHandler())
+	}
+
+	var selfAuthenticating []string
+	// Browsers reach login through redirects, which can't carry an API key;
+	// the callback checks the state Login sealed in its cookie instead
+	if s.auth != nil {
+		mux.HandleFunc("GET /auth/login", s.auth.Login)
+		mux.HandleFunc("GET /auth/callback", s.auth.Callback)
+		mux.HandleFunc("POST /auth/logout", s.auth.Logout)
+		selfAuthenticating = append(selfAuthenticating, "GET /auth/login", "GET /auth/callback")
+	}
+	if s.twilioToken != "" {
+		mux.HandleFunc("POST /integrations/twilio/voice", s.handleTwilioVoice)
+		selfAuthenticating = append(selfAuthenticating, "POST /integrations/twilio/voice")
+	}
+	if len(s.mattermost) > 0 {
+		mux.HandleFunc("POST /integrations/mattermost", s.handleMattermost)
+		selfAuthenticating = append(selfAuthenticating, "POST /integrations/mattermost")
+	}
+	// Unsubscribe links are signed, and followed from mail clients without a key
+	if s.digest != nil {
+		mux.HandleFunc("GET "+digest.UnsubscribePath, s.handleUnsubscribe)
+		mux.HandleFunc("POST "+digest.UnsubscribePath, s.handleUnsubscribe)
+		selfAuthenticating = append(selfAuthenticating, "GET "+digest.UnsubscribePath, "POST "+digest.UnsubscribePath)
+	}
+
+	// Answer OPTIONS and unsupported methods with the routes' Allow list
+	handler := allowMethods(mux)
+
+	// Load the session, and with it the signed-in user, on every route
+	if s.sessions != nil {
+		handler = middleware.Chain(s.sessions.Middleware(), session.CSRF())(handler)
+	}
+
+	// Assign the request's experiment bucket, before routing so joke and
+	// rating routes see the same one
+	if s.experiment != nil {
+		handler = s.experiment.Middleware()(handler)
+	}
+
+	// Resolve the tenant, stripping any /t/{id} prefix before routing
+	if s.tenants != nil {
+		handler = s.tenants.Middleware(s.tenantKey)(handler)
+	}
+
+	// Serve probes outside the middleware, so they need no API key and
+	// aren't rate limited or logged
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", s.handleHealth)
+	root.HandleFunc("GET /readyz", s.handleReady)
+	chained := middleware.Chain(s.middleware...)(handler)
+	// Webhooks, unsubscribe and login links can't send an API key or CSRF token;
+	// they check their own signature or token, and get every other middleware
+	for _, pattern := range selfAuthenticating {
+		root.Handle(pattern, middleware.SelfAuthenticating(chained))
+	}
+	// Event streams stay open by design, so they get no request deadline;
+	// long polls cap their own wait at maxNextWait instead
+	streaming := []string{"/stream"}
+	if s.publisher != nil {
+		streaming = append(streaming, "/joke/next")
+	}
+	for _, path := range streaming {
+		root.Handle("GET "+path, middleware.Streaming(chained))
+		if s.tenants != nil {
+			root.Handle("GET "+tenant.PathPrefix+"{id}"+path, middleware.Streaming(chained))
+		}
+	}
+	root.Handle("/", chained)
+	return root
+}
+
+// Handler for GET /healthz, answering while the process serves requests
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Handler for GET /readyz, answering 503 until the server is ready for traffic
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.ready != nil && !s.ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ready\n"))
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/joketest"
+	"github.com/jswanson806/joke-generator/metrics"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/ui"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	names := &joketest.FakeNameProvider{}
+	jokes := &joketest.FakeJokeProvider{}
+
+	t.Run("Uses default address", func(t *testing.T) {
+		if srv := New(); srv.Addr != DefaultAddr {
+			t.Errorf("Expected address %q; got %q", DefaultAddr, srv.Addr)
+		}
+	})
+
+	t.Run("WithAddr sets address", func(t *testing.T) {
+		if srv := New(WithAddr(":8080")); srv.Addr != ":8080" {
+			t.Errorf("Expected address %q; got %q", ":8080", srv.Addr)
+		}
+	})
+
+	t.Run("WithMiddleware runs in order", func(t *testing.T) {
+		var order []string
+		mw := func(name string) middleware.Middleware {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		srv := New(WithProviders(names, jokes), WithMiddleware(mw("first"), mw("second")), WithMiddleware(mw("third")))
+
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if len(order) != 3 || order[0] != "first" || order[1] != "second" || order[2] != "third" {
+			t.Errorf("Expected middleware to run first, second, third; got %v", order)
+		}
+	})
+
+	t.Run("Probes skip middleware and report readiness", func(t *testing.T) {
+		var ready bool
+		deny := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "denied", http.StatusUnauthorized)
+			})
+		}
+		srv := New(WithProviders(names, jokes), WithMiddleware(deny), WithReadiness(func() bool { return ready }))
+
+		// Function to return the status of GET path
+		status := func(path string) int {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec.Code
+		}
+		if got := status("/healthz"); got != http.StatusOK {
+			t.Errorf("Expected /healthz status 200; got %d", got)
+		}
+		if got := status("/readyz"); got != http.StatusServiceUnavailable {
+			t.Errorf("Expected /readyz status 503 before ready; got %d", got)
+		}
+		ready = true
+		if got := status("/readyz"); got != http.StatusOK {
+			t.Errorf("Expected /readyz status 200 once ready; got %d", got)
+		}
+		if got := status("/"); got != http.StatusUnauthorized {
+			t.Errorf("Expected other routes to pass through middleware; got %d", got)
+		}
+	})
+
+	t.Run("WithUI serves the page and assets", func(t *testing.T) {
+		page, err := ui.New()
+		if err != nil {
+			t.Fatalf("Could not build UI: %v", err)
+		}
+		srv := New(WithProviders(names, jokes), WithUI(page))
+		css, _ := page.Path("app.css")
+
+		for _, path := range []string{"/ui", css} {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status OK for %s; got %d", path, rec.Code)
+			}
+		}
+	})
+
+	t.Run("WithCache serves fallback joke", func(t *testing.T) {
+		failing := (&joketest.FakeJokeProvider{}).Fail(errors.New("joke upstream down"))
+		c := cache.NewMemory()
+		c.Set(joke.FallbackKey, []byte("cached joke"), 0)
+		srv := New(WithProviders(names, failing), WithCache(c))
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "cached joke" {
+			t.Errorf("Expected cached joke with status OK; got %d %q", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-Joke-Fallback") != "true" {
+			t.Error("Expected X-Joke-Fallback header")
+		}
+	})
+
+	t.Run("WithCache stores served jokes", func(t *testing.T) {
+		c := cache.NewMemory()
+		srv := New(WithProviders(names, jokes), WithCache(c))
+
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if got, ok := c.Get(joke.FallbackKey); !ok || string(got) != "John Doe can divide by zero." {
+			t.Errorf("Expected served joke to be cached; got %q", got)
+		}
+	})
+	t.Run("WithMetrics serves /metrics", func(t *testing.T) {
+		reg := metrics.NewRegistry()
+		srv := New(WithProviders(reg.Names("name", names), reg.Jokes("joke", jokes)), WithMetrics(reg))
+
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %d", rec.Code)
+		}
+		if want := `joke_upstream_requests_total{upstream="joke",result="ok"} 1`; !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected metrics to contain %q; got:\n%s", want, rec.Body.String())
+		}
+	})
+	t.Run("WithSessions serves CSRF token", func(t *testing.T) {
+		srv := New(WithProviders(names, jokes), WithSessions(session.NewManager(cache.NewMemory())))
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/session/csrf", nil))
+
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "csrf_token") {
+			t.Errorf("Expected CSRF token; got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+	t.Run("WithLogin serves login without an API key", func(t *testing.T) {
+		var issuer *httptest.Server
+		issuer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "authorization_endpoint": "https://issuer.test/authorize"})
+		}))
+		defer issuer.Close()
+		o := auth.NewOIDC(auth.Config{Issuer: issuer.URL, ClientID: "client", RedirectURL: "http://app.test/auth/callback"})
+		login := auth.NewHandler(o, session.NewManager(cache.NewMemory()), nil)
+		rejectAll := middleware.APIKey(func(key string) bool { return false })
+		srv := New(WithProviders(names, jokes), WithLogin(login), WithMiddleware(rejectAll))
+
+		// The issuer redirects back to the callback, which checks its state
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+		if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "https://issuer.test/authorize") {
+			t.Errorf("Expected a redirect to the issuer; got %d %q", rec.Code, rec.Header().Get("Location"))
+		}
+		rec = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state=forged", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected the callback to reject the state; got %d", rec.Code)
+		}
+		// Every other route still needs a key
+		rec = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized without a key; got %d", rec.Code)
+		}
+	})
+}
+
+func TestStreamTimeout(t *testing.T) {
+	t.Parallel()
+
+	reg, err := tenant.New(&tenant.Tenant{ID: "acme"})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	// probe answers in place of the routes, saying whether the request has a deadline
+	probe := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				w.Header().Set("X-Deadline", "true")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	p := joke.NewPublisher(nil, nil, time.Hour, nil)
+	handler := NewServer(WithTenants(reg, nil), WithPublisher(p), WithMiddleware(middleware.Timeout(time.Minute), probe)).Handler()
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/jokes", true},
+		{"/stream", false},
+		{"/t/acme/stream", false},
+		{"/joke/next", false},
+		{"/t/acme/joke/next", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("X-Deadline") == "true"; got != tt.want {
+			t.Errorf("%s: expected deadline %v; got %v", tt.path, tt.want, got)
+		}
+	}
+}
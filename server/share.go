@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/shortid"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// uriList is the text/uri-list format (RFC 2483) of a shared joke: its
+// permalink, for pasting where a link is expected
+var uriList = render.Format{
+	Name:        "uri-list",
+	ContentType: "text/uri-list; charset=utf-8",
+	Encode:      render.Text.Encode,
+}
+
+// Formats a shared joke can be copied in, plain text by default
+var shareFormats = []render.Format{render.Text, uriList}
+
+/*
+	 Handler for GET /jokes/{id}/share, a served joke ready to paste
+	 into a message
+
+		Plain text has the joke, who it stars and its permalink; the
+		uri-list variant has just the permalink, commented with the
+		joke's title. Jokes are found as for GET /jokes/{id}.pdf.
+*/
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+	e, found := s.history.Get(id, tenant.ID(r.Context()))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	format, ok := render.Negotiate(r, shareFormats...)
+	if !ok {
+		format = shareFormats[0]
+	}
+	link := s.permalink(e)
+	var body string
+	if format.Name == uriList.Name {
+		// uri-list lines end in CRLF; "#" lines are comments
+		body = "# " + shareTitle(e) + "\r\n" + link + "\r\n"
+	} else {
+		body = shareText(e, link)
+	}
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, format, body); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// Function to title e for sharing, e.g. "Joke #3 starring Ada Lovelace"
+func shareTitle(e history.Entry) string {
+	title := "Joke #" + strconv.Itoa(e.ID)
+	if name := strings.TrimSpace(e.FirstName + " " + e.LastName); name != "" {
+		title += " starring " + name
+	}
+	return title
+}
+
+// Function to return the absolute URL of e's shortlink, which stays the
+// same for as long as e is in history
+func (s *Server) permalink(e history.Entry) string {
+	return s.origin() + "/j/" + shortid.Encode(e.ID)
+}
+
+// Function to return the scheme and host absolute URLs start with, see WithPublicURL
+func (s *Server) origin() string {
+	if s.publicURL != "" {
+		return s.publicURL
+	}
+	return "http://" + s.addr
+}
+
+// Function to return the host of the origin, which shortlinks of this server are on
+func (s *Server) originHost() string {
+	u, err := url.Parse(s.origin())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
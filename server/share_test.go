@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestShare(t *testing.T) {
+	t.Parallel()
+
+	served := history.New(10)
+	served.Add(history.Entry{Joke: "Why did Ada cross the road?\nTo debug it.", FirstName: "Ada", LastName: "Lovelace"})
+	served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+	handler := NewServer(WithHistory(served), WithPublicURL("https://jokes.example.com/")).Handler()
+
+	t.Run("Shares plain text", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://jokes.example.com/jokes/1/share", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Errorf("Expected plain text; got %q", ct)
+		}
+		want := "Why did Ada cross the road?\nTo debug it.\n\n— Joke #1 starring Ada Lovelace\nhttps://jokes.example.com/j/1\n"
+		if rec.Body.String() != want {
+			t.Errorf("Expected %q; got %q", want, rec.Body.String())
+		}
+	})
+
+	t.Run("Shares a uri-list on the public URL", func(t *testing.T) {
+		// Links never follow the Host the client sent
+		req := httptest.NewRequest(http.MethodGet, "http://evil.example/jokes/1/share", nil)
+		req.Header.Set("Accept", "text/uri-list")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if ct := rec.Header().Get("Content-Type"); ct != "text/uri-list; charset=utf-8" {
+			t.Errorf("Expected a uri-list; got %q", ct)
+		}
+		want := "# Joke #1 starring Ada Lovelace\r\nhttps://jokes.example.com/j/1\r\n"
+		if rec.Body.String() != want {
+			t.Errorf("Expected %q; got %q", want, rec.Body.String())
+		}
+	})
+
+	t.Run("Doesn't find other jokes", func(t *testing.T) {
+		for _, path := range []string{"/jokes/2/share", "/jokes/9/share", "/jokes/x/share", "/jokes/0/share"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status Not Found; got %v", path, rec.Code)
+			}
+		}
+	})
+}
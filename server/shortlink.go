@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/shortid"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// printablePage stands for the printable page in shortlink negotiation:
+// requests for it are redirected there. It is never encoded, so it has
+// no Encode.
+var printablePage = render.Format{
+	Name:        "pdf",
+	ContentType: "application/pdf",
+}
+
+// Formats a shortlink resolves to, the joke's page by default
+var shortlinkFormats = []render.Format{permalinkPage, render.JSON, render.Text, printablePage}
+
+// struct to hold a joke behind a shortlink, without who it was served to
+type linkedJoke struct {
+	ID        int       `json:"id"`
+	Joke      string    `json:"joke"`
+	Category  string    `json:"category"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	ServedAt  time.Time `json:"served_at"`
+	URL       string    `json:"url"`
+}
+
+/*
+	 Handler for GET /j/{short}, the permalink of a served joke
+
+		{short} is the joke's ID in /history in base62, see shortid.
+		Browsers, and link preview crawlers, get a page of the joke
+		with card tags; clients asking for JSON or plain text get the
+		joke itself, as text in the form GET /jokes/{id}/share copies,
+		and ones asking for a PDF are redirected to the printable page.
+*/
+func (s *Server) handleShortlink(w http.ResponseWriter, r *http.Request) {
+	id, err := shortid.Decode(r.PathValue("short"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	e, found := s.history.Get(id, tenant.ID(r.Context()))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	s.oembedLink(w, s.permalink(e))
+
+	format, ok := render.Negotiate(r, shortlinkFormats...)
+	if !ok {
+		format = shortlinkFormats[0]
+	}
+	var v any
+	switch format.Name {
+	case printablePage.Name:
+		w.Header().Add("Vary", "Accept")
+		http.Redirect(w, r, "/jokes/"+strconv.Itoa(e.ID)+".pdf", http.StatusFound)
+		return
+	case permalinkPage.Name:
+		v = s.newJokeCard(e, s.permalink(e))
+	case render.Text.Name:
+		v = shareText(e, s.permalink(e))
+	default:
+		v = linkedJoke{
+			ID:        e.ID,
+			Joke:      e.Joke,
+			Category:  e.Category,
+			FirstName: e.FirstName,
+			LastName:  e.LastName,
+			ServedAt:  e.ServedAt,
+			URL:       s.permalink(e),
+		}
+	}
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, format, v); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// Function to format e and its permalink as text to paste into a message
+func shareText(e history.Entry, link string) string {
+	return strings.TrimSpace(e.Joke) + "\n\n— " + shareTitle(e) + "\n" + link + "\n"
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestShortlink(t *testing.T) {
+	t.Parallel()
+
+	served := history.New(100)
+	for i := 0; i < 61; i++ {
+		served.Add(history.Entry{Joke: "Filler."})
+	}
+	served.Add(history.Entry{Joke: "Why did Ada cross the road?\nTo debug it.", FirstName: "Ada", LastName: "Lovelace", User: "ada@example.com"})
+	served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+	handler := NewServer(WithHistory(served), WithPublicURL("http://jokes.example.com")).Handler()
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://jokes.example.com"+path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Renders a page for browsers", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "text/html,application/xhtml+xml,*/*;q=0.8"} {
+			rec := get("/j/Z", accept)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+				t.Errorf("%q: expected a page; got %v, %q", accept, rec.Code, rec.Header().Get("Content-Type"))
+			}
+		}
+	})
+
+	t.Run("Redirects to the printable page", func(t *testing.T) {
+		rec := get("/j/Z", "application/pdf")
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/jokes/61.pdf" {
+			t.Errorf("Expected a redirect to /jokes/61.pdf; got %v to %q", rec.Code, rec.Header().Get("Location"))
+		}
+	})
+
+	t.Run("Renders JSON", func(t *testing.T) {
+		rec := get("/j/10", "application/json")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var got linkedJoke
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.ID != 62 || got.FirstName != "Ada" || got.URL != "http://jokes.example.com/j/10" {
+			t.Errorf("Expected joke 62 and its link; got %+v", got)
+		}
+		if strings.Contains(rec.Body.String(), "ada@example.com") {
+			t.Errorf("Expected the user to be left out; got %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Renders text", func(t *testing.T) {
+		rec := get("/j/10?format=text", "")
+		want := "Why did Ada cross the road?\nTo debug it.\n\n— Joke #62 starring Ada Lovelace\nhttp://jokes.example.com/j/10\n"
+		if rec.Body.String() != want {
+			t.Errorf("Expected %q; got %q", want, rec.Body.String())
+		}
+	})
+
+	t.Run("Doesn't find other jokes", func(t *testing.T) {
+		for _, path := range []string{"/j/11", "/j/zz", "/j/0", "/j/010", "/j/a-b"} {
+			if rec := get(path, ""); rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status Not Found; got %v", path, rec.Code)
+			}
+		}
+	})
+}
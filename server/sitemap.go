@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/tenant"
+)
+
+// Most URLs a sitemap may list, per the sitemap protocol
+const sitemapPageSize = 50000
+
+// Namespace of sitemaps and sitemap indexes
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// struct to hold a sitemap, the shortlinks of one page of history
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+// struct to hold a sitemap index, linking the pages of a sitemap too large for one
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// struct to hold a URL in a sitemap or a sitemap in an index
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+/*
+	 Handler for GET /sitemap.xml, the shortlinks of the jokes in
+	 history, for search engines
+
+		Page N of the sitemap, ?page=N, lists jokes N-1 to N times
+		sitemapPageSize by ID, so pages don't shift as jokes are
+		served. When history spans more than one page the sitemap is
+		an index of its pages; otherwise it is the one page.
+*/
+func (s *Server) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenant.ID(r.Context())
+	var v any
+	if p := r.URL.Query().Get("page"); p != "" {
+		page, err := strconv.Atoi(p)
+		if err != nil || page < 1 {
+			http.Error(w, "page must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		v = s.sitemapPage(page, tenantID)
+	} else {
+		first, last := s.sitemapPages(tenantID)
+		if first == last {
+			v = s.sitemapPage(first, tenantID)
+		} else {
+			index := sitemapIndex{NS: sitemapNS}
+			for page := first; page <= last; page++ {
+				index.Sitemaps = append(index.Sitemaps, sitemapLoc{Loc: s.origin() + "/sitemap.xml?page=" + strconv.Itoa(page)})
+			}
+			v = index
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	// Handle errors while writing response
+	if err := writeXML(w, v); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// Function to return the first and last sitemap pages with jokes served
+// to tenant, both 1 when there are none
+func (s *Server) sitemapPages(tenantID string) (first, last int) {
+	oldest := s.history.After(0, 1, tenantID)
+	newest, _ := s.history.List(history.Filter{Page: 1, PerPage: 1, Tenant: tenantID})
+	if len(oldest) == 0 || len(newest) == 0 {
+		return 1, 1
+	}
+	return (oldest[0].ID-1)/sitemapPageSize + 1, (newest[0].ID-1)/sitemapPageSize + 1
+}
+
+// Function to return page of the sitemap of jokes served to tenant
+func (s *Server) sitemapPage(page int, tenantID string) sitemapURLSet {
+	set := sitemapURLSet{NS: sitemapNS}
+	for _, e := range s.history.After((page-1)*sitemapPageSize, sitemapPageSize, tenantID) {
+		if e.ID > page*sitemapPageSize {
+			break
+		}
+		set.URLs = append(set.URLs, sitemapLoc{
+			Loc:     s.permalink(e),
+			LastMod: e.ServedAt.UTC().Format("2006-01-02"),
+		})
+	}
+	return set
+}
+
+// Function to write v as an XML document
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+)
+
+func TestSitemap(t *testing.T) {
+	t.Parallel()
+
+	get := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://jokes.example.com"+path, nil))
+		return rec
+	}
+
+	t.Run("Lists shortlinks", func(t *testing.T) {
+		served := history.New(10)
+		served.Add(history.Entry{Joke: "Mine.", ServedAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)})
+		served.Add(history.Entry{Joke: "Not yours.", Tenant: "acme"})
+		served.Add(history.Entry{Joke: "Also mine.", ServedAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)})
+		rec := get(NewServer(WithHistory(served), WithPublicURL("http://jokes.example.com")).Handler(), "/sitemap.xml")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+			t.Fatalf("Expected XML; got %v, %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var got sitemapURLSet
+		if err := xml.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []sitemapLoc{
+			{Loc: "http://jokes.example.com/j/1", LastMod: "2026-10-16"},
+			{Loc: "http://jokes.example.com/j/3", LastMod: "2026-10-17"},
+		}
+		if len(got.URLs) != len(want) || got.URLs[0] != want[0] || got.URLs[1] != want[1] {
+			t.Errorf("Expected %+v; got %+v", want, got.URLs)
+		}
+	})
+
+	t.Run("Indexes pages of large histories", func(t *testing.T) {
+		served := history.New(sitemapPageSize + 1)
+		for i := 0; i < sitemapPageSize+1; i++ {
+			served.Add(history.Entry{Joke: "Filler."})
+		}
+		handler := NewServer(WithHistory(served), WithPublicURL("http://jokes.example.com")).Handler()
+
+		var index sitemapIndex
+		if err := xml.NewDecoder(get(handler, "/sitemap.xml").Body).Decode(&index); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(index.Sitemaps) != 2 || index.Sitemaps[1].Loc != "http://jokes.example.com/sitemap.xml?page=2" {
+			t.Fatalf("Expected an index of 2 pages; got %+v", index.Sitemaps)
+		}
+
+		for page, want := range map[string]int{"1": sitemapPageSize, "2": 1, "3": 0} {
+			var set sitemapURLSet
+			if err := xml.NewDecoder(get(handler, "/sitemap.xml?page="+page).Body).Decode(&set); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(set.URLs) != want {
+				t.Errorf("Page %s: expected %d URLs; got %d", page, want, len(set.URLs))
+			}
+		}
+	})
+
+	t.Run("Rejects invalid pages", func(t *testing.T) {
+		handler := NewServer(WithHistory(history.New(10))).Handler()
+		for _, page := range []string{"0", "-1", "x"} {
+			if rec := get(handler, "/sitemap.xml?page="+page); rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status Bad Request; got %v", page, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/sla"
+)
+
+// struct to hold the report returned by /providers/sla
+type slaReport struct {
+	Providers []sla.Provider `json:"providers"`
+}
+
+/*
+	 Handler for GET /providers/sla, reporting each upstream's uptime
+	 and latency over the last hour, day and week
+
+		Only upstreams called since the server started are listed.
+*/
+func (s *Server) handleSLA(w http.ResponseWriter, r *http.Request) {
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, slaReport{Providers: s.sla.Report()}); err != nil {
+		s.logger.Error("error writing sla response", "error", err)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/sla"
+)
+
+func TestSLA(t *testing.T) {
+	t.Parallel()
+
+	tracker := sla.New()
+	tracker.Observe("joke", 100*time.Millisecond, nil)
+	tracker.Observe("joke", 100*time.Millisecond, errors.New("status 502"))
+	handler := NewServer(WithSLA(tracker)).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/providers/sla", nil))
+
+	// Check the status code for 200
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rec.Code)
+	}
+	var body slaReport
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(body.Providers) != 1 || body.Providers[0].Provider != "joke" {
+		t.Fatalf("Unexpected providers: %+v", body.Providers)
+	}
+	for _, window := range []string{"1h", "24h", "7d"} {
+		stats := body.Providers[0].Windows[window]
+		if stats.Requests != 2 || stats.UptimePercent == nil || *stats.UptimePercent != 50 {
+			t.Errorf("Expected 50%% uptime over %s; got %+v", window, stats)
+		}
+	}
+}
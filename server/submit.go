@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/submission"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Longest joke and category a user may submit, in characters
+const (
+	maxSubmissionLen  = 500
+	maxCategoryLen    = 32
+	maxSubmissionBody = 4096
+)
+
+// struct to hold the body of POST /jokes/submit
+type submitRequest struct {
+	Joke     string `json:"joke"`
+	Category string `json:"category"`
+}
+
+/*
+	 Handler for POST /jokes/submit, storing a signed-in user's joke
+	 as pending until a moderator reviews it
+
+		Accepts {"joke": "{{.FirstName}} ...", "category": "nerdy"};
+		the joke is a template with placeholders for the person, see
+		joke.ValidateTemplate, and the category defaults to
+		joke.DefaultCategory. Responds 201 with the submission, 401
+		without a signed-in user, or 409 when it is a near-duplicate of
+		an earlier submission.
+*/
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	author := auth.Subject(r.Context())
+	if author == "" {
+		http.Error(w, "sign in to submit jokes", http.StatusUnauthorized)
+		return
+	}
+
+	var req submitRequest
+	// Decode the submitted joke
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	text, category, err := cleanSubmission(req.Joke, req.Category)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.submissions.Submit(submission.Submission{Joke: text, Category: category, Author: author})
+	if errors.Is(err, submission.ErrDuplicate) {
+		http.Error(w, "joke was already submitted", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "could not store submission", "error", err)
+		http.Error(w, "could not store submission", http.StatusInternalServerError)
+		return
+	}
+	s.logger.InfoContext(r.Context(), "joke submitted", "id", sub.ID, "author", author, "category", category)
+	s.writeJSON(w, http.StatusCreated, sub)
+}
+
+/*
+	 Function to return a submitted joke and category, normalized, or
+	 an error describing why they are invalid
+
+		An empty category is joke.DefaultCategory.
+*/
+func cleanSubmission(text, category string) (string, string, error) {
+	text = norm.NFC.String(strings.TrimSpace(text))
+	switch {
+	case text == "":
+		return "", "", errors.New("joke is required")
+	case utf8.RuneCountInString(text) > maxSubmissionLen:
+		return "", "", fmt.Errorf("joke must be at most %d characters", maxSubmissionLen)
+	}
+	if err := joke.ValidateTemplate(text); err != nil {
+		return "", "", err
+	}
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		category = joke.DefaultCategory
+	}
+	if !validCategory(category) {
+		return "", "", fmt.Errorf("category must be at most %d letters, digits or hyphens", maxCategoryLen)
+	}
+	return text, category, nil
+}
+
+// Function to report whether category is a short slug, e.g. nerdy or dad-jokes
+func validCategory(category string) bool {
+	if len(category) > maxCategoryLen {
+		return false
+	}
+	for _, c := range category {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/session"
+	"github.com/jswanson806/joke-generator/submission"
+)
+
+func TestSubmit(t *testing.T) {
+	t.Parallel()
+
+	// Sign a user in through a session, as the login callback does
+	sessions := session.NewManager(cache.NewMemory())
+	signIn := httptest.NewRecorder()
+	sess, err := sessions.Start(signIn, 0, map[string]string{"sub": "alice"})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	cookie := signIn.Result().Cookies()[0]
+
+	store, _ := submission.Open("")
+	handler := NewServer(WithSessions(sessions), WithSubmissions(store)).Handler()
+
+	// Function to submit body, signed in unless anonymous
+	submit := func(body string, anonymous bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/jokes/submit", strings.NewReader(body))
+		if !anonymous {
+			req.AddCookie(cookie)
+			req.Header.Set(session.CSRFHeader, sess.CSRFToken)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Stores the joke as pending", func(t *testing.T) {
+		rec := submit(`{"joke": "{{.FirstName}} types with one finger.", "category": "Nerdy"}`, false)
+
+		// Check the status code for 201
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status Created; got %v: %s", rec.Code, rec.Body.String())
+		}
+		var sub submission.Submission
+		if err := json.NewDecoder(rec.Body).Decode(&sub); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if sub.Status != submission.Pending || sub.Author != "alice" || sub.Category != "nerdy" {
+			t.Errorf("Unexpected submission: %+v", sub)
+		}
+		if pending := store.List(submission.Pending); len(pending) != 1 {
+			t.Errorf("Expected 1 pending submission; got %d", len(pending))
+		}
+	})
+
+	t.Run("Refuses duplicates", func(t *testing.T) {
+		if rec := submit(`{"joke": "{{.FirstName}} types with ONE finger!"}`, false); rec.Code != http.StatusConflict {
+			t.Errorf("Expected status Conflict; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Requires sign in", func(t *testing.T) {
+		if rec := submit(`{"joke": "{{.FirstName}} is here."}`, true); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Rejects invalid jokes", func(t *testing.T) {
+		for _, body := range []string{
+			`{"joke": ""}`,
+			`{"joke": "No one is in this joke."}`,
+			`{"joke": "{{.Shoe}} is not a placeholder."}`,
+			`{"joke": "{{.FirstName}}", "category": "dad jokes!"}`,
+			`{"joke": "{{.FirstName}} ` + strings.Repeat("a", maxSubmissionLen) + `"}`,
+			`not json`,
+		} {
+			if rec := submit(body, false); rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status Bad Request; got %v", body, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+/*
+	 Handler for POST /admin/teams/post, posting a joke to the Teams
+	 channel on demand
+
+		Answers with the joke posted, or a 502 when no joke could be
+		fetched or Teams rejected the card.
+*/
+func (s *Server) handleTeamsPost(w http.ResponseWriter, r *http.Request) {
+	name, text, err := joke.Fetch(r.Context(), s.names, s.jokes)
+	if err != nil {
+		s.logFailure(r.Context(), "failed to build joke for Teams", err)
+		joke.WriteError(w, s.logger, err, "failed to get joke")
+		return
+	}
+	j := joke.Published{Joke: text, Category: joke.DefaultCategory, FirstName: name.FirstName, LastName: name.LastName, PublishedAt: time.Now()}
+	if err := s.teams.Post(r.Context(), j); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to post joke to Teams", "error", err)
+		http.Error(w, "failed to post to Teams", http.StatusBadGateway)
+		return
+	}
+	s.logger.InfoContext(r.Context(), "joke posted to Teams")
+	s.writeJSON(w, http.StatusOK, j)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/teams"
+)
+
+func TestTeamsPost(t *testing.T) {
+	t.Parallel()
+
+	var posted []string
+	status := http.StatusAccepted
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = append(posted, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer hook.Close()
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+	})
+	jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return firstName + " can divide by zero.", nil
+	})
+	handler := NewServer(
+		WithProviders(names, jokes),
+		WithAdminAuth(middleware.StaticKeys("admin")),
+		WithTeams(&teams.Webhook{URL: hook.URL + "/webhook"}),
+	).Handler()
+	post := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/teams/post", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("admin")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rec.Code)
+	}
+	var j joke.Published
+	if err := json.NewDecoder(rec.Body).Decode(&j); err != nil || j.Joke != "Ada can divide by zero." {
+		t.Errorf("Expected the posted joke; got %+v, %v", j, err)
+	}
+	if len(posted) != 1 || posted[0] != "/webhook" {
+		t.Errorf("Expected one post to the webhook; got %v", posted)
+	}
+
+	// Check non-admins can't post
+	if rec := post("user"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized; got %v", rec.Code)
+	}
+
+	// Check a rejected card is reported
+	status = http.StatusBadRequest
+	if rec := post("admin"); rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected status Bad Gateway; got %v", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/translate"
+)
+
+// Translations cached, and for how long; random jokes rarely repeat, so
+// they're kept only while the same joke might be asked for again
+const (
+	translationCacheSize = 1000
+	translationTTL       = time.Hour
+)
+
+// struct to hold the response of /{lang}/joke
+type translatedJoke struct {
+	Joke string `json:"joke"`
+	// Language of Joke, translate.Source when translating failed
+	Language string `json:"language"`
+}
+
+// String returns the joke, for the plain text format
+func (j translatedJoke) String() string {
+	return j.Joke
+}
+
+/*
+	 Function to return the handler of GET /{lang}/joke, a random joke
+	 translated into lang
+
+		Translations are cached per language; when translating fails
+		the joke is served in English instead, with Content-Language
+		and the language field saying so. Like /, requests with a
+		tenant are refused categories it doesn't allow, and get the
+		translated joke branded with its template.
+*/
+func (s *Server) handleTranslatedJoke(lang string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := tenant.FromContext(r.Context())
+		if t != nil && !t.Allows(joke.DefaultCategory) {
+			joke.WriteError(w, s.logger, joke.ErrCategoryNotAllowed, "category "+joke.DefaultCategory+" is not allowed for this tenant")
+			return
+		}
+
+		name, text, err := joke.Fetch(r.Context(), s.names, s.jokes)
+		if err != nil {
+			s.logFailure(r.Context(), "failed to build joke", err)
+			if joke.ClientGone(r.Context()) {
+				w.WriteHeader(joke.StatusClientClosedRequest)
+				return
+			}
+			joke.WriteError(w, s.logger, err, "failed to get joke")
+			return
+		}
+		s.history.Add(history.Entry{
+			Joke:      text,
+			Category:  joke.DefaultCategory,
+			FirstName: name.FirstName,
+			LastName:  name.LastName,
+			Tenant:    tenant.ID(r.Context()),
+			User:      auth.Subject(r.Context()),
+			ServedAt:  time.Now(),
+		})
+
+		res := translatedJoke{Joke: text, Language: translate.Source}
+		if translated, err := s.translateJoke(r.Context(), text, lang); err != nil {
+			s.logFailure(r.Context(), "failed to translate joke", err)
+		} else {
+			res = translatedJoke{Joke: translated, Language: lang}
+		}
+		// Brand after translating, so the tenant's template is served as written
+		res.Joke = s.brand(r.Context(), t, res.Joke, name)
+
+		format, ok := render.Negotiate(r, render.JSON, render.Text)
+		if !ok {
+			format = render.JSON
+		}
+		w.Header().Set("Content-Language", res.Language)
+		// Handle errors while writing response
+		if err := render.Write(w, http.StatusOK, format, res); err != nil {
+			s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+		}
+	}
+}
+
+// Function to translate text into lang, through the translation cache
+func (s *Server) translateJoke(ctx context.Context, text, lang string) (string, error) {
+	sum := sha256.Sum256([]byte(text))
+	key := "joke:translation:" + lang + ":" + hex.EncodeToString(sum[:])
+	if cached, ok := s.langCache.Get(key); ok {
+		return string(cached), nil
+	}
+	translated, err := s.translator.Translate(ctx, text, lang)
+	if err != nil {
+		return "", err
+	}
+	s.langCache.Set(key, []byte(translated), translationTTL)
+	return translated, nil
+}
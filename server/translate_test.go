@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/translate"
+)
+
+func TestTranslatedJoke(t *testing.T) {
+	t.Parallel()
+
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "Ada", LastName: "Lovelace"}, nil
+	})
+	jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "Ada can divide by zero.", nil
+	})
+	// LibreTranslate knows Spanish and fails everything else
+	var calls atomic.Int32
+	libre := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct{ Target string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Target != "es" {
+			http.Error(w, `{"error":"unsupported"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"translatedText":"Ada puede dividir por cero."}`))
+	}))
+	defer libre.Close()
+	handler := NewServer(
+		WithProviders(names, jokes),
+		WithTranslator(&translate.LibreTranslate{URL: libre.URL, Client: libre.Client()}),
+	).Handler()
+	get := func(path string) (*httptest.ResponseRecorder, translatedJoke) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var res translatedJoke
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatalf("Could not decode joke: %v", err)
+		}
+		return rec, res
+	}
+
+	t.Run("Translates and caches", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rec, res := get("/es/joke")
+			if res.Joke != "Ada puede dividir por cero." || res.Language != "es" || rec.Header().Get("Content-Language") != "es" {
+				t.Errorf("Expected the Spanish joke; got %+v, %q", res, rec.Header().Get("Content-Language"))
+			}
+		}
+		if calls.Load() != 1 {
+			t.Errorf("Expected one translation; got %d", calls.Load())
+		}
+	})
+
+	t.Run("Falls back to English", func(t *testing.T) {
+		rec, res := get("/fr/joke")
+		if rec.Code != http.StatusOK || res.Joke != "Ada can divide by zero." || res.Language != "en" || rec.Header().Get("Content-Language") != "en" {
+			t.Errorf("Expected the English joke; got %v, %+v", rec.Code, res)
+		}
+	})
+
+	t.Run("Only serves known languages", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/xx/joke", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status Not Found; got %v", rec.Code)
+		}
+	})
+	t.Run("Applies the tenant", func(t *testing.T) {
+		reg, err := tenant.New(
+			&tenant.Tenant{ID: "acme", Name: "Acme", Template: "{{.Joke}}, dice {{.Tenant}}"},
+			&tenant.Tenant{ID: "kids", Categories: []string{"animals"}},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		served := history.New(10)
+		handler := NewServer(
+			WithProviders(names, jokes),
+			WithHistory(served),
+			WithTranslator(&translate.LibreTranslate{URL: libre.URL, Client: libre.Client()}),
+		).Handler()
+		// get requests path as tenant id
+		get := func(id, path string) *httptest.ResponseRecorder {
+			tn, _ := reg.Get(id)
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(tenant.NewContext(req.Context(), tn)))
+			return rec
+		}
+
+		rec := get("acme", "/es/joke")
+		var res translatedJoke
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatalf("Could not decode joke: %v", err)
+		}
+		if res.Joke != "Ada puede dividir por cero., dice Acme" {
+			t.Errorf("Expected the branded Spanish joke; got %q", res.Joke)
+		}
+		if entries, total := served.List(history.Filter{Page: 1, PerPage: 10, Tenant: "acme"}); total != 1 || entries[0].Tenant != "acme" {
+			t.Errorf("Expected one joke recorded for acme; got %+v", entries)
+		}
+
+		if rec := get("kids", "/es/joke"); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden for a disallowed category; got %v", rec.Code)
+		}
+	})
+}
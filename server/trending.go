@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jswanson806/joke-generator/render"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/trending"
+)
+
+// Default and maximum jokes returned by /jokes/trending
+const (
+	defaultTrendingLimit = 10
+	maxTrendingLimit     = 50
+)
+
+// struct to hold the jokes returned by /jokes/trending
+type trendingJokes struct {
+	Jokes []trending.Joke `json:"jokes"`
+}
+
+/*
+	 Handler for GET /jokes/trending, listing the jokes served most
+	 often lately to the request's tenant, highest score first
+
+		?category= limits the jokes to one category and ?limit= caps
+		them, 10 by default and at most 50.
+*/
+func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultTrendingLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrendingLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: %q (must be 1-%d)", v, maxTrendingLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	jokes := s.trending.Top(tenant.ID(r.Context()), q.Get("category"), limit)
+	// Handle errors while writing response
+	if err := render.Write(w, http.StatusOK, render.JSON, trendingJokes{Jokes: jokes}); err != nil {
+		s.logger.Error("error writing trending response", "error", err)
+	}
+}
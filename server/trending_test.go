@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/trending"
+)
+
+func TestTrending(t *testing.T) {
+	t.Parallel()
+
+	h := history.New(10)
+	handler := NewServer(WithHistory(h), WithTrending(trending.New(time.Hour, 100))).Handler()
+
+	// Jokes added to history after the server is built are counted
+	for _, j := range []string{"often", "once", "often"} {
+		h.Add(history.Entry{Joke: j, Category: "nerdy", ServedAt: time.Now()})
+	}
+
+	t.Run("Returns the top jokes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/trending?limit=1", nil))
+
+		// Check the status code for 200
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		var body trendingJokes
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(body.Jokes) != 1 || body.Jokes[0].Joke != "often" || body.Jokes[0].Serves != 2 {
+			t.Errorf("Unexpected jokes: %+v", body.Jokes)
+		}
+	})
+
+	t.Run("Rejects invalid limits", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes/trending?limit=51", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %v", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jswanson806/joke-generator/auth"
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/tenant"
+	"github.com/jswanson806/joke-generator/twilio"
+)
+
+// Time allowed to fetch a joke for a call, within Twilio's 15 second webhook timeout
+const twilioTimeout = 10 * time.Second
+
+// Largest webhook body read, well above the parameters Twilio sends
+const maxTwilioBody = 64 << 10
+
+// Spoken when no joke could be fetched, so callers don't hear Twilio's error message
+const twilioSorry = "Sorry, the jokes are taking a break. Please call back later."
+
+/*
+	 Handler for POST /integrations/twilio/voice, answering calls to a
+	 Twilio number with a joke spoken in TwiML
+
+		Requests must carry a valid X-Twilio-Signature. The joke
+		stars the caller when Twilio looked up their name, sent as
+		CallerName, and a random name otherwise; only jokes about a
+		random name are recorded in history. A two-part joke is spoken
+		with a pause before the punchline.
+*/
+func (s *Server) handleTwilioVoice(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTwilioBody)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	if !twilio.Valid(s.twilioToken, s.twilioWebhookURL(r), r.PostForm, r.Header.Get(twilio.SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), twilioTimeout)
+	defer cancel()
+	names := s.names
+	first, last, personal := callerName(r.PostForm.Get("CallerName"))
+	if personal {
+		names = joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+			return joke.Names{FirstName: first, LastName: last}, nil
+		})
+	}
+
+	lines := []string{twilioSorry}
+	name, text, err := joke.Fetch(ctx, names, s.jokes)
+	if err != nil {
+		s.logFailure(ctx, "failed to build joke for call", err)
+	} else {
+		lines = []string{text}
+		if setup, delivery, ok := joke.SplitJoke(text); ok {
+			lines = []string{setup, delivery}
+		}
+		// Jokes about the caller's real name are only told to them
+		if !personal {
+			s.history.Add(history.Entry{
+				Joke:      text,
+				Category:  joke.DefaultCategory,
+				FirstName: name.FirstName,
+				LastName:  name.LastName,
+				Tenant:    tenant.ID(r.Context()),
+				User:      auth.Subject(r.Context()),
+				ServedAt:  time.Now(),
+			})
+		}
+	}
+
+	body, err := twilio.Say("", lines...)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to build TwiML", "error", err)
+		http.Error(w, "failed to answer call", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", twilio.ContentType)
+	// Handle errors while writing response
+	if _, err := w.Write(body); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+/*
+	 Function to return the URL Twilio called, which its signature covers
+
+		The URL set with WithTwilio is used when given, and otherwise
+		the public URL, since proxies in front of the server change
+		the scheme and host it sees.
+*/
+func (s *Server) twilioWebhookURL(r *http.Request) string {
+	if s.twilioURL != "" {
+		return s.twilioURL
+	}
+	return s.origin() + r.URL.RequestURI()
+}
+
+/*
+	 Function to split the caller name Twilio looked up into a first
+	 and last name
+
+		Names are looked up in capitals, e.g. "ADA LOVELACE", so they
+		are put in title case. A single name is not enough to
+		personalize a joke.
+*/
+func callerName(s string) (first, last string, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return "", "", false
+	}
+	for i, f := range fields {
+		r, size := utf8.DecodeRuneInString(f)
+		fields[i] = string(unicode.ToTitle(r)) + strings.ToLower(f[size:])
+	}
+	return fields[0], strings.Join(fields[1:], " "), true
+}
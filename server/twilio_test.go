@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/history"
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/twilio"
+)
+
+func TestTwilioVoice(t *testing.T) {
+	t.Parallel()
+
+	const token, webhookURL = "secret", "https://jokes.example.com/integrations/twilio/voice"
+	names := joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		return joke.Names{FirstName: "John", LastName: "Doe"}, nil
+	})
+	jokes := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		if firstName == "Fail" {
+			return "", joke.ErrJokeUpstream
+		}
+		return "Why did " + firstName + " " + lastName + " call?\nTo hear this joke.", nil
+	})
+	handler := NewServer(WithProviders(names, jokes), WithTwilio(token, webhookURL)).Handler()
+
+	// call posts params to the webhook, signed with sign
+	call := func(params url.Values, sign string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/integrations/twilio/voice", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(twilio.SignatureHeader, twilio.Signature(sign, webhookURL, params))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Speaks a joke starring the caller", func(t *testing.T) {
+		t.Parallel()
+		rec := call(url.Values{"CallSid": {"CA1"}, "CallerName": {"ADA LOVELACE"}}, token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != twilio.ContentType {
+			t.Errorf("Expected content type %q; got %q", twilio.ContentType, ct)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "<Say>Why did Ada Lovelace call?</Say><Pause") || !strings.Contains(body, "<Say>To hear this joke.</Say>") {
+			t.Errorf("Unexpected TwiML: %s", body)
+		}
+	})
+
+	t.Run("Uses a random name without a caller name", func(t *testing.T) {
+		t.Parallel()
+		rec := call(url.Values{"CallSid": {"CA2"}}, token)
+		if !strings.Contains(rec.Body.String(), "John Doe") {
+			t.Errorf("Expected a joke about John Doe; got %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Records only jokes about random names", func(t *testing.T) {
+		t.Parallel()
+		served := history.New(10)
+		handler := NewServer(WithProviders(names, jokes), WithHistory(served), WithTwilio(token, webhookURL)).Handler()
+		for _, params := range []url.Values{{"CallerName": {"ADA LOVELACE"}}, {}} {
+			req := httptest.NewRequest(http.MethodPost, "/integrations/twilio/voice", strings.NewReader(params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set(twilio.SignatureHeader, twilio.Signature(token, webhookURL, params))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		if entries, total := served.List(history.Filter{Page: 1, PerPage: 10}); total != 1 || entries[0].FirstName != "John" {
+			t.Errorf("Expected only the joke about John recorded; got %+v", entries)
+		}
+	})
+
+	t.Run("Apologizes when no joke can be fetched", func(t *testing.T) {
+		t.Parallel()
+		rec := call(url.Values{"CallerName": {"FAIL CALLER"}}, token)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), twilioSorry) {
+			t.Errorf("Expected an apology; got %v %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Rejects unsigned requests", func(t *testing.T) {
+		t.Parallel()
+		rec := call(url.Values{"CallSid": {"CA3"}}, "wrong")
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Runs the middleware except the API key check", func(t *testing.T) {
+		t.Parallel()
+		var seen atomic.Bool
+		logged := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen.Store(true)
+				next.ServeHTTP(w, r)
+			})
+		}
+		rejectAll := middleware.APIKey(func(key string) bool { return false })
+		handler := NewServer(WithProviders(names, jokes), WithTwilio(token, webhookURL), WithMiddleware(logged, rejectAll)).Handler()
+
+		params := url.Values{"CallSid": {"CA4"}}
+		req := httptest.NewRequest(http.MethodPost, "/integrations/twilio/voice", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(twilio.SignatureHeader, twilio.Signature(token, webhookURL, params))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %v", rec.Code)
+		}
+		if !seen.Load() {
+			t.Error("Expected the webhook to run through the middleware")
+		}
+
+		// Other routes still need a key
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jokes", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status Unauthorized for /jokes; got %v", rec.Code)
+		}
+	})
+
+	t.Run("Not served without a token", func(t *testing.T) {
+		t.Parallel()
+		rec := httptest.NewRecorder()
+		NewServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/integrations/twilio/voice", nil))
+		if rec.Code != http.StatusNotFound && rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected the route not to be served; got %v", rec.Code)
+		}
+	})
+}
+
+func TestCallerName(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string][2]string{
+		"ADA LOVELACE":       {"Ada", "Lovelace"},
+		"émile van der BERG": {"Émile", "Van Der Berg"},
+	} {
+		first, last, ok := callerName(in)
+		if !ok || first != want[0] || last != want[1] {
+			t.Errorf("%q: expected %v; got %q %q %v", in, want, first, last, ok)
+		}
+	}
+	for _, in := range []string{"", "WIRELESS", "  "} {
+		if _, _, ok := callerName(in); ok {
+			t.Errorf("%q: expected no name", in)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+	 Package session keeps per-browser sessions in a server-side
+	 store, identified by an opaque cookie.
+
+		The cookie holds only a random session ID; values live in
+		the store and expire with the session. Every session carries
+		a CSRF token for forms and scripts to echo back.
+*/
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/middleware"
+	"github.com/jswanson806/joke-generator/token"
+)
+
+// Defaults used by NewManager
+const (
+	DefaultCookieName = "joke_session"
+	DefaultTTL        = 12 * time.Hour
+)
+
+// Prefix of session keys in the cache
+const keyPrefix = "session:"
+
+// Session is the server-side state of one browser
+type Session struct {
+	ID        string            `json:"id"`
+	CSRFToken string            `json:"csrf_token"`
+	Values    map[string]string `json:"values,omitempty"`
+	Expires   time.Time         `json:"expires"`
+}
+
+// Context key for the request's *Session
+type contextKey struct{}
+
+/*
+	 Manager starts, loads and ends sessions
+
+		Sessions are stored in Store as JSON under "session:<id>"
+		and expire from it with the session.
+*/
+type Manager struct {
+	// Store holds session state
+	Store cache.Cache
+	// TTL is how long a session lasts, defaults to DefaultTTL
+	TTL time.Duration
+	// CookieName defaults to DefaultCookieName
+	CookieName string
+	// Secure marks the cookie HTTPS-only; set it whenever the site is served over TLS
+	Secure bool
+}
+
+// NewManager returns a Manager storing sessions in store with the defaults
+func NewManager(store cache.Cache) *Manager {
+	return &Manager{Store: store, TTL: DefaultTTL, CookieName: DefaultCookieName}
+}
+
+/*
+	 Start begins a new session holding values and sets its cookie
+
+		The session lasts ttl, or the Manager's TTL when ttl is zero.
+		Always start a new session when privileges change, e.g. on
+		login, so an ID set by an attacker is never promoted.
+*/
+func (m *Manager) Start(w http.ResponseWriter, ttl time.Duration, values map[string]string) (*Session, error) {
+	if ttl <= 0 {
+		ttl = m.ttl()
+	}
+	s := &Session{
+		ID:        token.String(32),
+		CSRFToken: token.String(32),
+		Values:    values,
+		Expires:   time.Now().Add(ttl),
+	}
+	if err := m.Save(s); err != nil {
+		return nil, err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookieName(),
+		Value:    s.ID,
+		Path:     "/",
+		Expires:  s.Expires,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   m.Secure,
+		HttpOnly: true,
+		// Lax so the cookie is sent on top-level navigations from other sites, e.g. a login redirect
+		SameSite: http.SameSiteLaxMode,
+	})
+	return s, nil
+}
+
+// Save writes s to the store until it expires
+func (m *Manager) Save(s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	m.Store.Set(keyPrefix+s.ID, data, time.Until(s.Expires))
+	return nil
+}
+
+// Delete removes s from the store, ending it without touching its cookie
+func (m *Manager) Delete(s *Session) {
+	m.Store.Delete(keyPrefix + s.ID)
+}
+
+// Destroy ends the request's session, if any, and clears its cookie
+func (m *Manager) Destroy(w http.ResponseWriter, r *http.Request) {
+	if s, ok := FromContext(r.Context()); ok {
+		m.Delete(s)
+	}
+	http.SetCookie(w, &http.Cookie{Name: m.cookieName(), Path: "/", MaxAge: -1, Secure: m.Secure, HttpOnly: true})
+}
+
+/*
+	 Middleware adds the request's session, if any, to its context
+
+		Requests without a cookie, or whose session is unknown or
+		expired, carry no session.
+*/
+func (m *Manager) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s, ok := m.load(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), contextKey{}, s))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+	 CSRFHandler serves the CSRF token of the request's session as
+	 {"csrf_token": "..."}
+
+		A session is started for requests without one, so pages can
+		fetch a token before the user signs in. Any client can start
+		them, so give the Manager a bounded store, e.g. cache.LRU.
+*/
+func (m *Manager) CSRFHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := FromContext(r.Context())
+		if !ok {
+			var err error
+			s, err = m.Start(w, 0, nil)
+			if err != nil {
+				http.Error(w, "could not start session", http.StatusInternalServerError)
+				return
+			}
+		}
+		// Tokens must never be cached by a shared proxy
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"csrf_token": s.CSRFToken})
+	})
+}
+
+// Header and form field carrying the CSRF token on unsafe requests
+const (
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+)
+
+/*
+	 CSRF rejects unsafe requests made with a session cookie unless
+	 they carry the session's CSRF token
+
+		POST, PUT, PATCH and DELETE requests must send the token in
+		the X-CSRF-Token header or the csrf_token form field.
+		Requests without a session, e.g. API clients authenticating
+		with a key, carry no ambient credentials and are let through,
+		as are requests marked by middleware.SelfAuthenticating. Runs
+		after Manager.Middleware.
+*/
+func CSRF() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if middleware.IsSelfAuthenticating(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			s, ok := FromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := r.Header.Get(CSRFHeader)
+			if token == "" {
+				token = r.PostFormValue(CSRFField)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) != 1 {
+				http.Error(w, "missing or invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Function to load the session named by the request's cookie
+func (m *Manager) load(r *http.Request) (*Session, bool) {
+	c, err := r.Cookie(m.cookieName())
+	if err != nil || c.Value == "" {
+		return nil, false
+	}
+	data, ok := m.Store.Get(keyPrefix + c.Value)
+	if !ok {
+		return nil, false
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil || s.ID != c.Value || !time.Now().Before(s.Expires) {
+		return nil, false
+	}
+	return &s, true
+}
+
+// FromContext returns the session added by Manager.Middleware
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(contextKey{}).(*Session)
+	return s, ok
+}
+
+// CSRFToken returns the CSRF token of the request's session, or "" without one
+func CSRFToken(ctx context.Context) string {
+	if s, ok := FromContext(ctx); ok {
+		return s.CSRFToken
+	}
+	return ""
+}
+
+// Function to return the configured TTL
+func (m *Manager) ttl() time.Duration {
+	if m.TTL <= 0 {
+		return DefaultTTL
+	}
+	return m.TTL
+}
+
+// Function to return the configured cookie name
+func (m *Manager) cookieName() string {
+	if m.CookieName == "" {
+		return DefaultCookieName
+	}
+	return m.CookieName
+}
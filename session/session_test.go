@@ -0,0 +1,220 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/cache"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// Function to return the session seen by a handler for a request carrying cookies
+func current(m *Manager, cookies ...*http.Cookie) (*Session, bool) {
+	var s *Session
+	var ok bool
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok = FromContext(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	return s, ok
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Starts and loads sessions", func(t *testing.T) {
+		m := NewManager(cache.NewMemory())
+		rec := httptest.NewRecorder()
+
+		started, err := m.Start(rec, 0, map[string]string{"sub": "user-1"})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		c := rec.Result().Cookies()[0]
+		if c.Name != DefaultCookieName || c.Value != started.ID || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("Unexpected cookie: %+v", c)
+		}
+		s, ok := current(m, c)
+		if !ok || s.Values["sub"] != "user-1" || s.CSRFToken == "" || s.CSRFToken != started.CSRFToken {
+			t.Errorf("Expected started session; got %+v", s)
+		}
+	})
+
+	t.Run("Sets Secure cookies", func(t *testing.T) {
+		m := NewManager(cache.NewMemory())
+		m.Secure = true
+		rec := httptest.NewRecorder()
+
+		m.Start(rec, 0, nil)
+
+		if c := rec.Result().Cookies()[0]; !c.Secure {
+			t.Error("Expected Secure cookie")
+		}
+	})
+
+	t.Run("Saves changes", func(t *testing.T) {
+		m := NewManager(cache.NewMemory())
+		rec := httptest.NewRecorder()
+		s, _ := m.Start(rec, 0, map[string]string{})
+
+		s.Values["theme"] = "dark"
+		if err := m.Save(s); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+
+		if loaded, _ := current(m, rec.Result().Cookies()[0]); loaded.Values["theme"] != "dark" {
+			t.Errorf("Expected saved value; got %+v", loaded)
+		}
+	})
+
+	t.Run("Ignores unknown and expired sessions", func(t *testing.T) {
+		m := NewManager(cache.NewMemory())
+		if _, ok := current(m, &http.Cookie{Name: DefaultCookieName, Value: "made-up"}); ok {
+			t.Error("Expected no session for unknown ID")
+		}
+
+		// Store a session whose expiry has passed but whose entry has not been evicted
+		s := &Session{ID: "old", Expires: time.Now().Add(-time.Minute)}
+		data, _ := json.Marshal(s)
+		m.Store.Set(keyPrefix+"old", data, 0)
+		if _, ok := current(m, &http.Cookie{Name: DefaultCookieName, Value: "old"}); ok {
+			t.Error("Expected no session once expired")
+		}
+	})
+
+	t.Run("Destroys sessions", func(t *testing.T) {
+		m := NewManager(cache.NewMemory())
+		rec := httptest.NewRecorder()
+		m.Start(rec, 0, nil)
+		c := rec.Result().Cookies()[0]
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.AddCookie(c)
+		out := httptest.NewRecorder()
+		m.Middleware()(http.HandlerFunc(m.Destroy)).ServeHTTP(out, req)
+
+		if cleared := out.Result().Cookies()[0]; cleared.MaxAge >= 0 {
+			t.Errorf("Expected cookie to be cleared; got %+v", cleared)
+		}
+		if _, ok := current(m, c); ok {
+			t.Error("Expected session to be gone")
+		}
+	})
+}
+
+func TestCSRFHandler(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(cache.NewMemory())
+	handler := m.Middleware()(m.CSRFHandler())
+
+	// Function to fetch a token, returning it and the response
+	fetch := func(cookies ...*http.Cookie) (string, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/session/csrf", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body struct {
+			Token string `json:"csrf_token"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body.Token, rec
+	}
+
+	t.Run("Starts a session without one", func(t *testing.T) {
+		token, rec := fetch()
+
+		if token == "" || len(rec.Result().Cookies()) != 1 {
+			t.Fatalf("Expected token and session cookie; got %q and %v", token, rec.Result().Cookies())
+		}
+		if rec.Header().Get("Cache-Control") != "no-store" {
+			t.Error("Expected Cache-Control no-store")
+		}
+	})
+
+	t.Run("Returns the session's token", func(t *testing.T) {
+		first, rec := fetch()
+		second, again := fetch(rec.Result().Cookies()[0])
+
+		if second != first {
+			t.Errorf("Expected the same token %q; got %q", first, second)
+		}
+		if len(again.Result().Cookies()) != 0 {
+			t.Error("Expected no new session")
+		}
+	})
+
+	t.Run("Stays within a bounded store", func(t *testing.T) {
+		store := cache.NewLRU(2)
+		bounded := NewManager(store)
+		for range 5 {
+			bounded.Middleware()(bounded.CSRFHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/session/csrf", nil))
+		}
+		if store.Len() != 2 {
+			t.Errorf("Expected 2 sessions kept; got %d", store.Len())
+		}
+	})
+}
+
+func TestCSRF(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(cache.NewMemory())
+	rec := httptest.NewRecorder()
+	s, _ := m.Start(rec, 0, nil)
+	cookie := rec.Result().Cookies()[0]
+	handler := m.Middleware()(CSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// Function to send a request and return its status
+	send := func(method, body string, header http.Header, withCookie bool) int {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		if withCookie {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	form := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		header     http.Header
+		withCookie bool
+		want       int
+	}{
+		{"Safe method", http.MethodGet, "", nil, true, http.StatusOK},
+		{"No session", http.MethodPost, "", nil, false, http.StatusOK},
+		{"Missing token", http.MethodPost, "", nil, true, http.StatusForbidden},
+		{"Wrong token", http.MethodDelete, "", http.Header{CSRFHeader: {"forged"}}, true, http.StatusForbidden},
+		{"Header token", http.MethodPut, "", http.Header{CSRFHeader: {s.CSRFToken}}, true, http.StatusOK},
+		{"Form token", http.MethodPost, CSRFField + "=" + s.CSRFToken, form, true, http.StatusOK},
+		{"Wrong form token", http.MethodPost, CSRFField + "=forged", form, true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := send(tt.method, tt.body, tt.header, tt.withCookie); got != tt.want {
+			t.Errorf("%s: expected status %d; got %d", tt.name, tt.want, got)
+		}
+	}
+
+	// Routes checking their own signature need no token, even with a session
+	handler = middleware.SelfAuthenticating(handler)
+	if got := send(http.MethodPost, "", nil, true); got != http.StatusOK {
+		t.Errorf("Self-authenticating: expected status %d; got %d", http.StatusOK, got)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+	 Package shortid encodes joke IDs in base62 for short links
+
+		IDs are written with the digits 0-9, a-z and A-Z, most
+		significant first, so ID 1,000,000 is "4c92": short enough for
+		a chat message and safe in any URL without escaping.
+*/
+package shortid
+
+import (
+	"errors"
+	"strings"
+)
+
+// Digits of base62, in order of value
+const alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// ErrInvalid is returned decoding a string that no positive ID encodes to
+var ErrInvalid = errors.New("shortid: invalid short ID")
+
+// Encode returns the short ID of id, which must be positive
+func Encode(id int) string {
+	if id <= 0 {
+		panic("shortid: ID must be positive")
+	}
+	var b [11]byte
+	i := len(b)
+	for ; id > 0; id /= 62 {
+		i--
+		b[i] = alphabet[id%62]
+	}
+	return string(b[i:])
+}
+
+/*
+	 Decode returns the ID s encodes
+
+		Each ID has one short ID, so strings with leading zeros, and
+		ones too large for an int, are ErrInvalid.
+*/
+func Decode(s string) (int, error) {
+	if s == "" || s[0] == '0' {
+		return 0, ErrInvalid
+	}
+	const maxInt = int(^uint(0) >> 1)
+	id := 0
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 || id > (maxInt-d)/62 {
+			return 0, ErrInvalid
+		}
+		id = id*62 + d
+	}
+	return id, nil
+}
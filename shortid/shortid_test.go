@@ -0,0 +1,36 @@
+package shortid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	for id, want := range map[int]string{1: "1", 10: "a", 61: "Z", 62: "10", 1000000: "4c92"} {
+		if got := Encode(id); got != want {
+			t.Errorf("%d: expected %q; got %q", id, want, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Decodes what Encode returns", func(t *testing.T) {
+		for _, id := range []int{1, 61, 62, 3843, 1000000, int(^uint(0) >> 1)} {
+			if got, err := Decode(Encode(id)); got != id || err != nil {
+				t.Errorf("%d: expected it back; got %d, %v", id, got, err)
+			}
+		}
+	})
+
+	t.Run("Rejects invalid short IDs", func(t *testing.T) {
+		for _, s := range []string{"", "0", "01", "a-b", "é", "ZZZZZZZZZZZZ"} {
+			if _, err := Decode(s); !errors.Is(err, ErrInvalid) {
+				t.Errorf("%q: expected ErrInvalid; got %v", s, err)
+			}
+		}
+	})
+}
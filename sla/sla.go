@@ -0,0 +1,204 @@
+/*
+	 Package sla tracks the availability and latency of each upstream
+	 over rolling windows.
+
+		Calls are counted in one-minute buckets kept for the longest
+		window, so a report costs a scan of at most a week of minutes
+		per upstream and memory stays fixed however busy the server is.
+*/
+package sla
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Width of a bucket, the resolution of every window
+const bucketWidth = time.Minute
+
+// Window is a rolling window reported by the Tracker
+type Window struct {
+	// Name is the window's key in reports, e.g. "24h"
+	Name     string
+	Duration time.Duration
+}
+
+// Windows reported when New is given none
+var DefaultWindows = []Window{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// Stats are an upstream's calls over one window
+type Stats struct {
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// UptimePercent is the share of calls that succeeded, null without calls
+	UptimePercent *float64 `json:"uptime_percent"`
+	// AvgLatencyMS and MaxLatencyMS cover every call, failed ones too
+	AvgLatencyMS float64 `json:"avg_latency_ms"`
+	MaxLatencyMS float64 `json:"max_latency_ms"`
+}
+
+// Provider is the report of one upstream, keyed by window name
+type Provider struct {
+	Provider string           `json:"provider"`
+	Windows  map[string]Stats `json:"windows"`
+}
+
+// struct to hold the calls of one minute
+type bucket struct {
+	// minute is the bucket's start in minutes since the epoch, 0 when unused
+	minute   int64
+	requests int
+	failures int
+	latency  time.Duration
+	max      time.Duration
+}
+
+/*
+	 Tracker records upstream calls and reports them per window
+
+		Safe for concurrent use. Build one with New.
+*/
+type Tracker struct {
+	windows []Window
+	size    int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string][]bucket
+}
+
+// New returns a Tracker reporting windows, or DefaultWindows when none are given
+func New(windows ...Window) *Tracker {
+	if len(windows) == 0 {
+		windows = DefaultWindows
+	}
+	longest := time.Duration(0)
+	for _, w := range windows {
+		longest = max(longest, w.Duration)
+	}
+	return &Tracker{
+		windows: windows,
+		size:    int(longest / bucketWidth),
+		now:     time.Now,
+		buckets: make(map[string][]bucket),
+	}
+}
+
+/*
+	 Observe records a call to upstream that took d and returned err
+
+		Calls canceled by the caller, such as a client hanging up, say
+		nothing about the upstream and are not counted.
+*/
+func (t *Tracker) Observe(upstream string, d time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	minute := t.now().Unix() / int64(bucketWidth/time.Second)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buckets, ok := t.buckets[upstream]
+	if !ok {
+		buckets = make([]bucket, t.size)
+		t.buckets[upstream] = buckets
+	}
+	// Reuse the slot of a minute that fell out of the longest window
+	b := &buckets[minute%int64(t.size)]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.requests++
+	if err != nil {
+		b.failures++
+	}
+	b.latency += d
+	b.max = max(b.max, d)
+}
+
+// Names returns p wrapped so every call is recorded under upstream
+func (t *Tracker) Names(upstream string, p joke.NameProvider) joke.NameProvider {
+	return joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		start := time.Now()
+		n, err := p.Name(ctx)
+		t.Observe(upstream, time.Since(start), err)
+		return n, err
+	})
+}
+
+// Jokes returns p wrapped so every call is recorded under upstream
+func (t *Tracker) Jokes(upstream string, p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		start := time.Now()
+		text, err := p.Joke(ctx, firstName, lastName)
+		t.Observe(upstream, time.Since(start), err)
+		return text, err
+	})
+}
+
+/*
+	 Report returns the stats of every upstream called so far, sorted
+	 by name
+
+		A window includes the current, partial minute, so 1h covers
+		between 59 and 60 whole minutes plus the calls of this one.
+*/
+func (t *Tracker) Report() []Provider {
+	now := t.now().Unix() / int64(bucketWidth/time.Second)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := make([]Provider, 0, len(t.buckets))
+	for upstream, buckets := range t.buckets {
+		p := Provider{Provider: upstream, Windows: make(map[string]Stats, len(t.windows))}
+		for _, w := range t.windows {
+			p.Windows[w.Name] = stats(buckets, now, int64(w.Duration/bucketWidth))
+		}
+		report = append(report, p)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Provider < report[j].Provider })
+	return report
+}
+
+// Function to sum the buckets of the minutes minutes up to now
+func stats(buckets []bucket, now, minutes int64) Stats {
+	var (
+		s       Stats
+		latency time.Duration
+		longest time.Duration
+	)
+	for _, b := range buckets {
+		if b.minute == 0 || b.minute > now || b.minute <= now-minutes {
+			continue
+		}
+		s.Requests += b.requests
+		s.Failures += b.failures
+		latency += b.latency
+		longest = max(longest, b.max)
+	}
+	if s.Requests == 0 {
+		return s
+	}
+	uptime := math.Round(float64(s.Requests-s.Failures)/float64(s.Requests)*100*1000) / 1000
+	s.UptimePercent = &uptime
+	s.AvgLatencyMS = milliseconds(latency / time.Duration(s.Requests))
+	s.MaxLatencyMS = milliseconds(longest)
+	return s
+}
+
+// Function to return d in milliseconds, rounded to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
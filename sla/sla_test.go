@@ -0,0 +1,72 @@
+package sla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	tr := New()
+	now := time.Date(2024, time.January, 8, 12, 0, 30, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	// Two days ago: one failure out of two
+	now = now.Add(-48 * time.Hour)
+	tr.Observe("joke", 100*time.Millisecond, nil)
+	tr.Observe("joke", 300*time.Millisecond, errors.New("status 502"))
+	// Ten minutes ago: three successes, and a hang up that isn't counted
+	now = now.Add(48*time.Hour - 10*time.Minute)
+	for i := 0; i < 3; i++ {
+		tr.Observe("joke", 100*time.Millisecond, nil)
+	}
+	tr.Observe("joke", time.Second, context.Canceled)
+	tr.Observe("name", 20*time.Millisecond, nil)
+	now = now.Add(10 * time.Minute)
+
+	report := tr.Report()
+	if len(report) != 2 || report[0].Provider != "joke" || report[1].Provider != "name" {
+		t.Fatalf("Expected the joke and name upstreams; got %+v", report)
+	}
+
+	hour := report[0].Windows["1h"]
+	if hour.Requests != 3 || hour.Failures != 0 || hour.UptimePercent == nil || *hour.UptimePercent != 100 {
+		t.Errorf("Expected 3 successful calls in the last hour; got %+v", hour)
+	}
+	if hour.AvgLatencyMS != 100 || hour.MaxLatencyMS != 100 {
+		t.Errorf("Expected 100ms latency; got %v avg, %v max", hour.AvgLatencyMS, hour.MaxLatencyMS)
+	}
+	if day := report[0].Windows["24h"]; day.Requests != 3 {
+		t.Errorf("Expected the older calls outside 24h; got %+v", day)
+	}
+	week := report[0].Windows["7d"]
+	if week.Requests != 5 || week.Failures != 1 || *week.UptimePercent != 80 || week.AvgLatencyMS != 140 || week.MaxLatencyMS != 300 {
+		t.Errorf("Expected 5 calls with one failure over 7d; got %+v", week)
+	}
+
+	// Calls age out of every window
+	now = now.Add(7 * 24 * time.Hour)
+	if week := tr.Report()[0].Windows["7d"]; week.Requests != 0 || week.UptimePercent != nil {
+		t.Errorf("Expected no calls after a week; got %+v", week)
+	}
+}
+
+func TestObserveReusesBuckets(t *testing.T) {
+	t.Parallel()
+
+	tr := New(Window{"5m", 5 * time.Minute})
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	tr.Observe("joke", time.Millisecond, errors.New("timeout"))
+	// The same slot, five minutes on, starts afresh
+	now = now.Add(5 * time.Minute)
+	tr.Observe("joke", time.Millisecond, nil)
+
+	if s := tr.Report()[0].Windows["5m"]; s.Requests != 1 || s.Failures != 0 {
+		t.Errorf("Expected only the latest call; got %+v", s)
+	}
+}
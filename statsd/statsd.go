@@ -0,0 +1,103 @@
+/*
+	 Package statsd pushes metrics to a StatsD or DogStatsD agent over
+	 UDP, for ops stacks built on Datadog rather than Prometheus.
+
+		DogStatsD lines carry tags as |#key:value; plain StatsD has no
+		tags, so their values are folded into the metric name instead.
+*/
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends metrics to one agent
+type Client struct {
+	// Prefix is prepended to every metric name, e.g. "joke."
+	Prefix string
+	// Tags are added to every metric, as key:value
+	Tags []string
+	// Plain sends StatsD without tags: Tags are left out and the
+	// values of each metric's own tags are folded into its name
+	Plain bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Dial returns a Client sending to the agent at addr, e.g. "127.0.0.1:8125"
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: could not dial %s: %w", addr, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Count adds n to the counter name
+func (c *Client) Count(name string, n int64, tags ...string) {
+	c.send(name, strconv.FormatInt(n, 10), "c", tags)
+}
+
+// Timing records a duration of d under name, in milliseconds
+func (c *Client) Timing(name string, d time.Duration, tags ...string) {
+	c.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close closes the connection to the agent
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+/*
+	 Function to send one metric line
+
+		UDP sends don't wait for the agent, and errors, e.g. no agent
+		listening, are ignored so metrics never fail a request.
+*/
+func (c *Client) send(name, value, kind string, tags []string) {
+	line := c.line(name, value, kind, tags)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write([]byte(line))
+}
+
+// Function to return the line for a metric, with the client's prefix and tags
+func (c *Client) line(name, value, kind string, tags []string) string {
+	all := append(append([]string(nil), c.Tags...), tags...)
+	var b strings.Builder
+	b.WriteString(c.Prefix)
+	b.WriteString(name)
+	if c.Plain {
+		// Fold tag values into the name, e.g. upstream:joke becomes .joke
+		for _, tag := range tags {
+			_, v, _ := strings.Cut(tag, ":")
+			b.WriteString(".")
+			b.WriteString(strings.ReplaceAll(sanitize(v), ":", "_"))
+		}
+	}
+	b.WriteString(":")
+	b.WriteString(value)
+	b.WriteString("|")
+	b.WriteString(kind)
+	if !c.Plain && len(all) > 0 {
+		b.WriteString("|#")
+		for i, tag := range all {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(sanitize(tag))
+		}
+	}
+	return b.String()
+}
+
+// Function to replace the characters that delimit a StatsD line
+func sanitize(s string) string {
+	return strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_").Replace(s)
+}
@@ -0,0 +1,69 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLine(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Adds DogStatsD tags", func(t *testing.T) {
+		c := &Client{Prefix: "joke.", Tags: []string{"env:prod"}}
+		got := c.line("upstream.requests", "1", "c", []string{"upstream:joke", "result:ok"})
+		if want := "joke.upstream.requests:1|c|#env:prod,upstream:joke,result:ok"; got != want {
+			t.Errorf("Expected %q; got %q", want, got)
+		}
+	})
+
+	t.Run("Leaves out the tag section without tags", func(t *testing.T) {
+		c := &Client{}
+		if got := c.line("purged", "3", "c", nil); got != "purged:3|c" {
+			t.Errorf("Expected %q; got %q", "purged:3|c", got)
+		}
+	})
+
+	t.Run("Folds tag values into plain names", func(t *testing.T) {
+		c := &Client{Prefix: "joke.", Tags: []string{"env:prod"}, Plain: true}
+		got := c.line("upstream.latency", "12.5", "ms", []string{"upstream:joke"})
+		if want := "joke.upstream.latency.joke:12.5|ms"; got != want {
+			t.Errorf("Expected %q; got %q", want, got)
+		}
+	})
+
+	t.Run("Sanitizes delimiters", func(t *testing.T) {
+		c := &Client{}
+		got := c.line("x", "1", "c", []string{"error:a|b,c#d"})
+		if want := "x:1|c|#error:a_b_c_d"; got != want {
+			t.Errorf("Expected %q; got %q", want, got)
+		}
+	})
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	defer agent.Close()
+
+	c, err := Dial(agent.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	defer c.Close()
+	c.Timing("latency", 1500*time.Microsecond, "upstream:name")
+
+	buf := make([]byte, 512)
+	agent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := agent.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected a packet; got %v", err)
+	}
+	if got, want := string(buf[:n]), "latency:1.5|ms|#upstream:name"; got != want {
+		t.Errorf("Expected %q; got %q", want, got)
+	}
+}
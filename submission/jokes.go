@@ -0,0 +1,32 @@
+package submission
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+/*
+	 Jokes returns a JokeProvider serving a random approved submission,
+	 filled with the name, for share (0 to 1) of jokes, and next's
+	 jokes otherwise
+
+		Submissions in an exclude category are never served. next also
+		serves every joke while nothing else is approved.
+*/
+func (s *Store) Jokes(next joke.JokeProvider, share float64, exclude ...string) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		if share <= 0 || rand.Float64() >= share {
+			return next.Joke(ctx, firstName, lastName)
+		}
+		approved := slices.DeleteFunc(s.List(Approved), func(sub Submission) bool {
+			return slices.Contains(exclude, sub.Category)
+		})
+		if len(approved) == 0 {
+			return next.Joke(ctx, firstName, lastName)
+		}
+		return joke.FillTemplate(ctx, approved[rand.IntN(len(approved))].Joke, firstName, lastName)
+	})
+}
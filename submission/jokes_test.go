@@ -0,0 +1,35 @@
+package submission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestJokes(t *testing.T) {
+	t.Parallel()
+
+	next := joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		return "upstream joke", nil
+	})
+	s, _ := Open("")
+	jokes := s.Jokes(next, 1)
+
+	// Nothing is approved yet, so next serves every joke
+	if j, _ := jokes.Joke(context.Background(), "Ada", "Lovelace"); j != "upstream joke" {
+		t.Errorf("Expected the upstream joke; got %q", j)
+	}
+
+	sub, _ := s.Submit(Submission{Joke: "{{.FirstName}} approved this.", Category: "explicit"})
+	s.Approve(sub.ID, "mod", "")
+	if j, _ := jokes.Joke(context.Background(), "Ada", "Lovelace"); j != "Ada approved this." {
+		t.Errorf("Expected the approved joke; got %q", j)
+	}
+	if j, _ := s.Jokes(next, 0).Joke(context.Background(), "Ada", "Lovelace"); j != "upstream joke" {
+		t.Errorf("Expected the upstream joke with no share; got %q", j)
+	}
+	if j, _ := s.Jokes(next, 1, "explicit").Joke(context.Background(), "Ada", "Lovelace"); j != "upstream joke" {
+		t.Errorf("Expected the upstream joke with its category excluded; got %q", j)
+	}
+}
@@ -0,0 +1,62 @@
+package submission
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Bits two jokes' simhashes may differ by and still be duplicates, or
+// similar enough to flag for moderators
+const (
+	duplicateDistance = 3
+	similarDistance   = 10
+)
+
+/*
+	 Simhash returns a 64-bit fingerprint of text that changes little
+	 when text changes little
+
+		Words are compared case-insensitively, ignoring punctuation,
+		along with each pair of neighbouring words so word order
+		counts. The number of differing bits, see distance, estimates
+		how different two texts are.
+*/
+func Simhash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	for i, w := range words {
+		add(w)
+		if i > 0 {
+			add(words[i-1] + " " + w)
+		}
+	}
+
+	var hash uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// Function to return the number of bits a and b differ by
+func distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
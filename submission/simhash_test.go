@@ -0,0 +1,26 @@
+package submission
+
+import "testing"
+
+func TestSimhash(t *testing.T) {
+	t.Parallel()
+
+	joke := "{{.FirstName}} can divide by zero, and the result is always a segfault."
+	tests := []struct {
+		name  string
+		other string
+		max   int
+		min   int
+	}{
+		{"Identical", joke, 0, 0},
+		{"Case and punctuation", "{{.FirstName}} can divide by zero and the result is ALWAYS a segfault!", duplicateDistance, 0},
+		{"Different joke", "When {{.Name}} runs git blame, the commits apologize to " + "{{.Them}}.", 64, similarDistance + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := distance(Simhash(joke), Simhash(tt.other)); d > tt.max || d < tt.min {
+				t.Errorf("Expected a distance between %d and %d; got %d", tt.min, tt.max, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+/*
+	 Package submission keeps jokes contributed by users until they are
+	 moderated.
+
+		Submissions are held in memory and, when the Store has a path,
+		saved to a JSON file on every change.
+*/
+package submission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/atomicfile"
+)
+
+// Status of a submission in moderation
+type Status string
+
+// Statuses a submission moves through
+const (
+	// Pending submissions wait for a moderator
+	Pending Status = "pending"
+	// Approved submissions may be served
+	Approved Status = "approved"
+	// Rejected submissions are never served
+	Rejected Status = "rejected"
+)
+
+// Errors returned by Store
+var (
+	ErrNotFound   = errors.New("submission: not found")
+	ErrNotPending = errors.New("submission: already reviewed")
+	ErrDuplicate  = errors.New("submission: duplicate")
+)
+
+// Action recorded in a submission's audit trail
+type Action string
+
+// Actions taken on a submission
+const (
+	ActionSubmitted Action = "submitted"
+	ActionEdited    Action = "edited"
+	ActionApproved  Action = "approved"
+	ActionRejected  Action = "rejected"
+)
+
+// struct to hold one action taken on a submission
+type AuditEntry struct {
+	Action Action `json:"action"`
+	// By is the author for ActionSubmitted, otherwise the moderator
+	By   string    `json:"by"`
+	At   time.Time `json:"at"`
+	Note string    `json:"note,omitempty"`
+}
+
+// struct to hold a contributed joke
+type Submission struct {
+	ID int64 `json:"id"`
+	// Joke is a template with placeholders for the person, see
+	// joke.ValidateTemplate
+	Joke     string `json:"joke"`
+	Category string `json:"category"`
+	// Author is the signed-in user who submitted the joke
+	Author      string    `json:"author"`
+	Status      Status    `json:"status"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	// Audit lists every action taken on the submission, oldest first
+	Audit []AuditEntry `json:"audit"`
+	// Similar flags the IDs of earlier submissions close to this one,
+	// for moderators to compare
+	Similar []int64 `json:"similar,omitempty"`
+
+	// Simhash of Joke, computed rather than saved
+	simhash uint64
+}
+
+/*
+	 Store holds submissions
+
+		Safe for concurrent use. Build one with Open.
+*/
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu     sync.Mutex
+	subs   []Submission
+	nextID int64
+	hooks  []func(Submission, AuditEntry)
+}
+
+/*
+	 Open returns a Store saved to the JSON file at path, loading the
+	 submissions already in it
+
+		A missing file is an empty store. An empty path keeps
+		submissions in memory only.
+*/
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now, nextID: 1}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("submission: could not read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.subs); err != nil {
+		return nil, fmt.Errorf("submission: could not parse %s: %w", path, err)
+	}
+	for i, sub := range s.subs {
+		s.nextID = max(s.nextID, sub.ID+1)
+		s.subs[i].simhash = Simhash(sub.Joke)
+	}
+	return s, nil
+}
+
+/*
+	 OnChange calls fn after every action taken on a submission, e.g.
+	 to notify moderators of new submissions or authors of reviews
+
+		fn is called synchronously with the submission as it was
+		saved and should return quickly. Register hooks before the
+		Store is used.
+*/
+func (s *Store) OnChange(fn func(Submission, AuditEntry)) {
+	s.hooks = append(s.hooks, fn)
+}
+
+/*
+	 Submit stores sub as a new pending submission and returns it with
+	 its ID
+
+		Jokes that are near-duplicates of an earlier submission,
+		whatever its status, are refused with ErrDuplicate; ones merely
+		similar are stored with the earlier IDs in Similar.
+*/
+func (s *Store) Submit(sub Submission) (Submission, error) {
+	sub.simhash = Simhash(sub.Joke)
+
+	s.mu.Lock()
+	dup, similar := s.similarLocked(sub.simhash, 0)
+	if dup != 0 {
+		s.mu.Unlock()
+		return Submission{}, fmt.Errorf("%w of submission %d", ErrDuplicate, dup)
+	}
+	sub.Similar = similar
+	sub.ID = s.nextID
+	sub.Status = Pending
+	sub.SubmittedAt = s.now()
+	entry := AuditEntry{Action: ActionSubmitted, By: sub.Author, At: sub.SubmittedAt}
+	sub.Audit = []AuditEntry{entry}
+	s.subs = append(s.subs, sub)
+	if err := s.saveLocked(); err != nil {
+		// Keep memory and the file in step
+		s.subs = s.subs[:len(s.subs)-1]
+		s.mu.Unlock()
+		return Submission{}, err
+	}
+	s.nextID++
+	s.mu.Unlock()
+
+	s.notify(sub, entry)
+	return sub, nil
+}
+
+// Approve lets the pending submission with id be served, recording by and note
+func (s *Store) Approve(id int64, by, note string) (Submission, error) {
+	return s.review(id, AuditEntry{Action: ActionApproved, By: by, Note: note}, func(sub *Submission) {
+		sub.Status = Approved
+	})
+}
+
+// Reject keeps the pending submission with id from being served, recording by and note
+func (s *Store) Reject(id int64, by, note string) (Submission, error) {
+	return s.review(id, AuditEntry{Action: ActionRejected, By: by, Note: note}, func(sub *Submission) {
+		sub.Status = Rejected
+	})
+}
+
+/*
+	 Edit replaces the joke and category of the pending submission with
+	 id, recording by
+
+		An empty joke or category is left as it was. The submission
+		stays pending until approved or rejected.
+*/
+func (s *Store) Edit(id int64, by, joke, category string) (Submission, error) {
+	return s.review(id, AuditEntry{Action: ActionEdited, By: by}, func(sub *Submission) {
+		if joke != "" {
+			sub.Joke = joke
+			sub.simhash = Simhash(joke)
+		}
+		if category != "" {
+			sub.Category = category
+		}
+	})
+}
+
+// Function to apply change to the pending submission with id, recording entry
+func (s *Store) review(id int64, entry AuditEntry, change func(*Submission)) (Submission, error) {
+	s.mu.Lock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		s.mu.Unlock()
+		return Submission{}, ErrNotFound
+	}
+	if s.subs[i].Status != Pending {
+		s.mu.Unlock()
+		return Submission{}, ErrNotPending
+	}
+
+	old := s.subs[i]
+	sub := old
+	sub.Audit = append(append([]AuditEntry(nil), old.Audit...), entry)
+	sub.Audit[len(sub.Audit)-1].At = s.now()
+	change(&sub)
+	// An edit may bring the joke closer to others, or further away
+	if sub.simhash != old.simhash {
+		_, sub.Similar = s.similarLocked(sub.simhash, id)
+	}
+	s.subs[i] = sub
+	if err := s.saveLocked(); err != nil {
+		// Keep memory and the file in step
+		s.subs[i] = old
+		s.mu.Unlock()
+		return Submission{}, err
+	}
+	s.mu.Unlock()
+
+	s.notify(sub, sub.Audit[len(sub.Audit)-1])
+	return sub, nil
+}
+
+// Function to call the OnChange hooks
+func (s *Store) notify(sub Submission, entry AuditEntry) {
+	for _, fn := range s.hooks {
+		fn(sub, entry)
+	}
+}
+
+/*
+	 Function to return the first submission that hash is a
+	 near-duplicate of, or 0, and the IDs of those it is similar to
+
+		An except other than 0 is the submission being edited: it is
+		skipped, and near-duplicates are only flagged as similar.
+*/
+func (s *Store) similarLocked(hash uint64, except int64) (int64, []int64) {
+	var similar []int64
+	for _, sub := range s.subs {
+		if sub.ID == except {
+			continue
+		}
+		switch d := distance(hash, sub.simhash); {
+		case d <= duplicateDistance && except == 0:
+			return sub.ID, nil
+		case d <= similarDistance:
+			similar = append(similar, sub.ID)
+		}
+	}
+	return 0, similar
+}
+
+// Function to return the index of the submission with id, -1 if there is none
+func (s *Store) indexLocked(id int64) int {
+	for i, sub := range s.subs {
+		if sub.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the submission with id, or ErrNotFound
+func (s *Store) Get(id int64) (Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.indexLocked(id); i >= 0 {
+		return s.subs[i], nil
+	}
+	return Submission{}, ErrNotFound
+}
+
+// List returns the submissions with status, oldest first; an empty status lists all
+func (s *Store) List(status Status) []Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Submission{}
+	for _, sub := range s.subs {
+		if status == "" || sub.Status == status {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
+
+// Function to write every submission to the file, when the store has one
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("submission: could not save submissions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package submission
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Submits pending jokes and saves them", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "submissions.json")
+		s, err := Open(path)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		sub, err := s.Submit(Submission{Joke: "{{.FirstName}} joke", Category: "nerdy", Author: "alice", Status: Approved})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if sub.ID != 1 || sub.Status != Pending || sub.SubmittedAt.IsZero() {
+			t.Errorf("Unexpected submission: %+v", sub)
+		}
+
+		// Reopen the file and continue numbering
+		s, err = Open(path)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if got, err := s.Get(1); err != nil || got.Author != "alice" {
+			t.Errorf("Expected the saved submission; got %+v, %v", got, err)
+		}
+		if sub, _ := s.Submit(Submission{Joke: "another"}); sub.ID != 2 {
+			t.Errorf("Expected ID 2; got %d", sub.ID)
+		}
+	})
+
+	t.Run("Lists by status", func(t *testing.T) {
+		s, _ := Open("")
+		s.Submit(Submission{Joke: "one"})
+		if got := s.List(Pending); len(got) != 1 {
+			t.Errorf("Expected 1 pending submission; got %d", len(got))
+		}
+		if got := s.List(Approved); len(got) != 0 {
+			t.Errorf("Expected no approved submissions; got %d", len(got))
+		}
+		if _, err := s.Get(9); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound; got %v", err)
+		}
+	})
+}
+
+func TestReview(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Records actions and calls hooks", func(t *testing.T) {
+		s, _ := Open("")
+		var actions []Action
+		s.OnChange(func(sub Submission, e AuditEntry) { actions = append(actions, e.Action) })
+
+		sub, _ := s.Submit(Submission{Joke: "{{.FirstName}} joke", Category: "nerdy", Author: "alice"})
+		if _, err := s.Edit(sub.ID, "mod", "", "puns"); err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		got, err := s.Reject(sub.ID, "mod", "not funny")
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		if got.Status != Rejected || got.Category != "puns" || got.Joke != "{{.FirstName}} joke" {
+			t.Errorf("Unexpected submission: %+v", got)
+		}
+		if want := []Action{ActionSubmitted, ActionEdited, ActionRejected}; !slices.Equal(actions, want) {
+			t.Errorf("Expected hooks for %v; got %v", want, actions)
+		}
+		if _, err := s.Approve(sub.ID, "mod", ""); !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected ErrNotPending; got %v", err)
+		}
+		if _, err := s.Approve(9, "mod", ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound; got %v", err)
+		}
+	})
+}
+
+func TestDuplicates(t *testing.T) {
+	t.Parallel()
+
+	s, _ := Open("")
+	first, _ := s.Submit(Submission{Joke: "{{.FirstName}} can divide by zero, and the result is always a segfault."})
+
+	// Near-duplicates are refused
+	if _, err := s.Submit(Submission{Joke: "{{.FirstName}} can divide by zero and the result is always a segfault!"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate; got %v", err)
+	}
+
+	// Different jokes are stored, flagged only when close to an earlier one
+	other, err := s.Submit(Submission{Joke: "When {{.Name}} runs git blame, the commits apologize to {{.Them}}."})
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if len(other.Similar) != 0 {
+		t.Errorf("Expected no similar submissions; got %v", other.Similar)
+	}
+
+	// An edit into a copy of another submission is flagged, not refused
+	edited, err := s.Edit(other.ID, "mod", first.Joke, "")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if !slices.Equal(edited.Similar, []int64{first.ID}) {
+		t.Errorf("Expected similar to %d; got %v", first.ID, edited.Similar)
+	}
+}
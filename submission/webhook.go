@@ -0,0 +1,72 @@
+package submission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Longest a webhook may take to accept a notification
+const webhookTimeout = 10 * time.Second
+
+// struct to hold the body posted to a webhook
+type notification struct {
+	Event      AuditEntry `json:"event"`
+	Submission Submission `json:"submission"`
+}
+
+/*
+	 Webhook returns an OnChange hook posting each action, as
+	 {"event": ..., "submission": ...}, to url
+
+		Posts are sent in the background so moderation never waits on
+		the webhook; failures are logged. A nil client uses
+		http.DefaultClient.
+*/
+func Webhook(url string, client *http.Client, logger *slog.Logger) func(Submission, AuditEntry) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(sub Submission, entry AuditEntry) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			// Handle errors while notifying; the action already happened
+			if err := post(ctx, client, url, notification{Event: entry, Submission: sub}); err != nil {
+				logger.Error("submission: could not notify webhook", "id", sub.ID, "action", entry.Action, "error", err)
+			}
+		}()
+	}
+}
+
+// Function to post n as JSON to url
+func post(ctx context.Context, client *http.Client, url string, n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("submission: could not encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("submission: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("submission: could not post notification: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("submission: could not post notification: status %d", res.StatusCode)
+	}
+	return nil
+}
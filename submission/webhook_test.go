@@ -0,0 +1,34 @@
+package submission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	got := make(chan notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		json.NewDecoder(r.Body).Decode(&n)
+		got <- n
+	}))
+	defer srv.Close()
+
+	s, _ := Open("")
+	s.OnChange(Webhook(srv.URL, srv.Client(), nil))
+	s.Submit(Submission{Joke: "{{.FirstName}} joke", Author: "alice"})
+
+	select {
+	case n := <-got:
+		if n.Event.Action != ActionSubmitted || n.Submission.Author != "alice" {
+			t.Errorf("Unexpected notification: %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the webhook to be notified")
+	}
+}
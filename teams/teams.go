@@ -0,0 +1,125 @@
+/*
+	 Package teams posts jokes to Microsoft Teams channels
+
+		Jokes are sent to an incoming webhook, made with a Teams
+		Workflow or the older Incoming Webhook connector, as an
+		Adaptive Card.
+*/
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// Content type of an Adaptive Card attachment
+const cardContentType = "application/vnd.microsoft.card.adaptive"
+
+// Adaptive Card schema version sent, the newest Teams renders everywhere
+const cardVersion = "1.4"
+
+// Largest error response body quoted in errors
+const maxErrorBody = 512
+
+// Webhook posts jokes to a Teams incoming webhook
+type Webhook struct {
+	// URL of the webhook, as Teams shows when it is created
+	URL string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// struct to hold the message a webhook is sent
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+// struct to hold a message's card attachment
+type attachment struct {
+	ContentType string `json:"contentType"`
+	Content     card   `json:"content"`
+}
+
+// struct to hold an Adaptive Card
+type card struct {
+	Schema  string      `json:"$schema"`
+	Type    string      `json:"type"`
+	Version string      `json:"version"`
+	Body    []textBlock `json:"body"`
+}
+
+// struct to hold a TextBlock element of a card
+type textBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Wrap     bool   `json:"wrap"`
+	Size     string `json:"size,omitempty"`
+	Weight   string `json:"weight,omitempty"`
+	IsSubtle bool   `json:"isSubtle,omitempty"`
+	Spacing  string `json:"spacing,omitempty"`
+}
+
+/*
+	 Function to return the Adaptive Card showing j
+
+		A two-part joke shows its punchline in bold below the setup.
+		The footer names who the joke is about.
+*/
+func newCard(j joke.Published) card {
+	c := card{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: cardVersion,
+		Body:    []textBlock{{Type: "TextBlock", Text: "Joke of the moment", Size: "Medium", Weight: "Bolder", Wrap: true}},
+	}
+	if setup, delivery, ok := joke.SplitJoke(j.Joke); ok {
+		c.Body = append(c.Body,
+			textBlock{Type: "TextBlock", Text: setup, Wrap: true},
+			textBlock{Type: "TextBlock", Text: delivery, Weight: "Bolder", Spacing: "Medium", Wrap: true})
+	} else {
+		c.Body = append(c.Body, textBlock{Type: "TextBlock", Text: j.Joke, Wrap: true})
+	}
+	if j.FirstName != "" {
+		c.Body = append(c.Body, textBlock{Type: "TextBlock", Text: fmt.Sprintf("Starring %s %s", j.FirstName, j.LastName), IsSubtle: true, Size: "Small", Wrap: true})
+	}
+	return c
+}
+
+// Post sends j to the webhook as an Adaptive Card
+func (w *Webhook) Post(ctx context.Context, j joke.Published) error {
+	body, err := json.Marshal(message{
+		Type:        "message",
+		Attachments: []attachment{{ContentType: cardContentType, Content: newCard(j)}},
+	})
+	if err != nil {
+		return fmt.Errorf("teams: could not encode card: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("teams: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("teams: could not post card: %w", err)
+	}
+	defer res.Body.Close()
+	// Workflows answer 202 Accepted, the connector 200 OK
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		quoted, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return fmt.Errorf("teams: webhook answered status %d: %q", res.StatusCode, quoted)
+	}
+	return nil
+}
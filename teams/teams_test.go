@@ -0,0 +1,67 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+func TestPost(t *testing.T) {
+	t.Parallel()
+
+	var got message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected a JSON body; got %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Could not decode message: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	j := joke.Published{Joke: "Why did Ada cross the road?\nTo debug it.", FirstName: "Ada", LastName: "Lovelace"}
+	if err := w.Post(context.Background(), j); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(got.Attachments) != 1 || got.Attachments[0].ContentType != cardContentType {
+		t.Fatalf("Expected one Adaptive Card; got %+v", got)
+	}
+	body := got.Attachments[0].Content.Body
+	if len(body) != 4 || body[1].Text != "Why did Ada cross the road?" || body[2].Text != "To debug it." || body[3].Text != "Starring Ada Lovelace" {
+		t.Errorf("Unexpected card body: %+v", body)
+	}
+}
+
+func TestNewCard(t *testing.T) {
+	t.Parallel()
+
+	c := newCard(joke.Published{Joke: "Ada can divide by zero."})
+	if len(c.Body) != 2 || c.Body[1].Text != "Ada can divide by zero." {
+		t.Errorf("Expected a title and the joke; got %+v", c.Body)
+	}
+	if c.Type != "AdaptiveCard" || c.Version != cardVersion {
+		t.Errorf("Unexpected card: %+v", c)
+	}
+}
+
+func TestPostFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Webhook message delivery failed", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	if err := w.Post(context.Background(), joke.Published{Joke: "x"}); err == nil {
+		t.Error("Expected an error for a rejected card")
+	}
+}
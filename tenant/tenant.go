@@ -0,0 +1,263 @@
+/*
+	 Package tenant serves several customers from one server, each with
+	 its own configuration and isolated history
+
+		A request's tenant is chosen by a /t/{id}/ path prefix or by the
+		API key it carries, and is available to handlers through
+		FromContext.
+*/
+package tenant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// PathPrefix starts the paths of requests addressed to a tenant
+const PathPrefix = "/t/"
+
+/*
+	 Tenant is one customer's configuration
+
+		Empty Categories allow every category and a zero Rate leaves
+		the tenant limited only by the server-wide limits.
+*/
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Categories are the joke categories the tenant may be served
+	Categories []string `json:"categories,omitempty"`
+	// Rate and Burst limit the tenant's requests per second
+	Rate  float64 `json:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// Template brands each joke, e.g. "{{.Joke}} (from {{.Tenant}})"
+	Template string `json:"template,omitempty"`
+	// Keys are the IDs of the API keys, or fingerprints of static
+	// keys, whose requests belong to the tenant
+	Keys []string `json:"keys,omitempty"`
+
+	tmpl *template.Template
+}
+
+// Allows reports whether t may be served jokes in category
+func (t *Tenant) Allows(category string) bool {
+	return len(t.Categories) == 0 || slices.Contains(t.Categories, category)
+}
+
+// Branding holds the fields available to a Tenant's Template
+type Branding struct {
+	Joke      string
+	Tenant    string
+	FirstName string
+	LastName  string
+}
+
+// Brand returns the joke in b rendered with t's template, unchanged without one
+func (t *Tenant) Brand(b Branding) (string, error) {
+	if t.tmpl == nil {
+		return b.Joke, nil
+	}
+	b.Tenant = t.Name
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, b); err != nil {
+		return "", fmt.Errorf("tenant: could not brand joke for %s: %w", t.ID, err)
+	}
+	return buf.String(), nil
+}
+
+// Registry holds the configured tenants
+type Registry struct {
+	byID  map[string]*Tenant
+	byKey map[string]*Tenant
+}
+
+// struct to hold the tenants file
+type file struct {
+	Tenants []*Tenant `json:"tenants"`
+}
+
+/*
+	 Load reads tenants from a JSON file such as:
+
+		{"tenants": [{"id": "acme", "name": "Acme", "categories": ["nerdy"],
+		  "rate": 5, "burst": 10, "template": "{{.Joke}} (from {{.Tenant}})",
+		  "keys": ["3f2a9c1e8b7d6a50"]}]}
+*/
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tenant: could not read %s: %w", path, err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("tenant: could not parse %s: %w", path, err)
+	}
+	return New(f.Tenants...)
+}
+
+// New returns a Registry of tenants, checking that IDs and keys are unique
+func New(tenants ...*Tenant) (*Registry, error) {
+	r := &Registry{byID: make(map[string]*Tenant), byKey: make(map[string]*Tenant)}
+	for _, t := range tenants {
+		if t.ID == "" || strings.Contains(t.ID, "/") {
+			return nil, fmt.Errorf("tenant: invalid id %q", t.ID)
+		}
+		if _, ok := r.byID[t.ID]; ok {
+			return nil, fmt.Errorf("tenant: duplicate id %q", t.ID)
+		}
+		if t.Name == "" {
+			t.Name = t.ID
+		}
+		if t.Template != "" {
+			tmpl, err := template.New(t.ID).Option("missingkey=error").Parse(t.Template)
+			if err != nil {
+				return nil, fmt.Errorf("tenant: invalid template for %s: %w", t.ID, err)
+			}
+			t.tmpl = tmpl
+		}
+		for _, k := range t.Keys {
+			if other, ok := r.byKey[k]; ok {
+				return nil, fmt.Errorf("tenant: key %q belongs to both %s and %s", k, other.ID, t.ID)
+			}
+			r.byKey[k] = t
+		}
+		r.byID[t.ID] = t
+	}
+	return r, nil
+}
+
+// Get returns the tenant with id
+func (r *Registry) Get(id string) (*Tenant, bool) {
+	t, ok := r.byID[id]
+	return t, ok
+}
+
+// ByKey returns the tenant owning the key with id, one of the IDs in Tenant.Keys
+func (r *Registry) ByKey(id string) (*Tenant, bool) {
+	t, ok := r.byKey[id]
+	return t, ok
+}
+
+/*
+	 Middleware resolves the tenant of each request and adds it to the
+	 request context
+
+		The tenant is the one owning the request's API key, keyID
+		mapping secrets to the IDs in Tenant.Keys. A /t/{id}/ prefix
+		names the tenant explicitly and is stripped, so /t/acme/history
+		is served as /history; it is only honored for keys the tenant
+		owns. Other keys, including those of no tenant, and requests
+		without one are refused with a 403, an unknown tenant with a
+		404. Requests matching no tenant are served as before.
+*/
+func (r *Registry) Middleware(keyID func(secret string) string) middleware.Middleware {
+	limited := make(map[string]middleware.Middleware)
+	for id, t := range r.byID {
+		if t.Rate > 0 {
+			limited[id] = middleware.RateLimit(t.Rate, max(t.Burst, 1))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		// Each tenant's rate limit wraps next once, keeping one limiter per tenant
+		handlers := make(map[string]http.Handler, len(limited))
+		for id, mw := range limited {
+			handlers[id] = mw(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var fromKey *Tenant
+			if secret := middleware.RequestKey(req); secret != "" && keyID != nil {
+				fromKey = r.byKey[keyID(secret)]
+			}
+
+			t, prefix := fromKey, ""
+			if rest, ok := strings.CutPrefix(req.URL.Path, PathPrefix); ok {
+				id, path, _ := strings.Cut(rest, "/")
+				fromPath, ok := r.byID[id]
+				if !ok {
+					http.Error(w, "unknown tenant", http.StatusNotFound)
+					return
+				}
+				if fromKey != fromPath {
+					http.Error(w, "API key does not belong to this tenant", http.StatusForbidden)
+					return
+				}
+				t, prefix = fromPath, PathPrefix+id
+				req = stripPrefix(req, "/"+path)
+			}
+			if t == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			ctx := NewContext(req.Context(), t)
+			if prefix != "" {
+				ctx = context.WithValue(ctx, prefixKey{}, prefix)
+			}
+			req = req.WithContext(ctx)
+			if h, ok := handlers[t.ID]; ok {
+				h.ServeHTTP(w, req)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// Function to return a copy of req with its path replaced by path
+func stripPrefix(req *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *req.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// Key types for the tenant and stripped path prefix stored in a context
+type (
+	contextKey struct{}
+	prefixKey  struct{}
+)
+
+// NewContext returns a copy of ctx carrying t
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant of a request, nil when it has none
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(contextKey{}).(*Tenant)
+	return t
+}
+
+// ID returns the ID of the request's tenant, "" when it has none
+func ID(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return ""
+}
+
+/*
+	 Prefix returns the path prefix Middleware stripped from the
+	 request, e.g. "/t/acme", or "" when the tenant came from its key
+
+		Handlers building links to themselves put it back in front.
+*/
+func Prefix(ctx context.Context) string {
+	p, _ := ctx.Value(prefixKey{}).(string)
+	return p
+}
@@ -0,0 +1,132 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Reads tenants", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tenants.json")
+		os.WriteFile(path, []byte(`{"tenants": [{"id": "acme", "categories": ["nerdy"], "template": "{{.Joke}} ({{.Tenant}})", "keys": ["key-1"]}]}`), 0o644)
+
+		reg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		acme, ok := reg.Get("acme")
+		if !ok || acme.Name != "acme" || !acme.Allows("nerdy") || acme.Allows("dad") {
+			t.Errorf("Unexpected tenant %+v", acme)
+		}
+		if got, _ := acme.Brand(Branding{Joke: "joke"}); got != "joke (acme)" {
+			t.Errorf("Expected branded joke; got %q", got)
+		}
+		if owner, ok := reg.ByKey("key-1"); !ok || owner != acme {
+			t.Errorf("Expected key-1 to belong to acme; got %+v", owner)
+		}
+	})
+
+	t.Run("Rejects invalid tenants", func(t *testing.T) {
+		for name, tenants := range map[string][]*Tenant{
+			"empty id":      {{}},
+			"slash in id":   {{ID: "a/b"}},
+			"duplicate id":  {{ID: "a"}, {ID: "a"}},
+			"shared key":    {{ID: "a", Keys: []string{"k"}}, {ID: "b", Keys: []string{"k"}}},
+			"bad template":  {{ID: "a", Template: "{{.Joke"}},
+			"unknown field": {{ID: "a", Template: "{{.Nope}}"}},
+		} {
+			reg, err := New(tenants...)
+			if name == "unknown field" {
+				// Unknown fields are only caught when the template runs
+				if _, err := reg.byID["a"].Brand(Branding{}); err == nil {
+					t.Errorf("%s: expected error branding", name)
+				}
+				continue
+			}
+			if err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	reg, _ := New(
+		&Tenant{ID: "acme", Keys: []string{"acme-key"}},
+		&Tenant{ID: "globex", Keys: []string{"globex-key"}, Rate: 1.0 / 60, Burst: 1},
+	)
+	// Record the tenant and path each request reaches the handler with
+	var gotTenant, gotPath, gotPrefix string
+	handler := reg.Middleware(func(secret string) string { return secret })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotTenant, gotPath, gotPrefix = ID(r.Context()), r.URL.Path, Prefix(r.Context())
+		}))
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+		tenant string
+		url    string
+		prefix string
+	}{
+		{"No tenant", "/history", "", http.StatusOK, "", "/history", ""},
+		{"Tenant by path", "/t/acme/history", "acme-key", http.StatusOK, "acme", "/history", "/t/acme"},
+		{"Tenant root", "/t/acme/", "acme-key", http.StatusOK, "acme", "/", "/t/acme"},
+		{"Tenant by key", "/", "acme-key", http.StatusOK, "acme", "/", ""},
+		{"Key of other tenant", "/t/acme/", "globex-key", http.StatusForbidden, "", "", ""},
+		{"Key of no tenant", "/t/acme/history", "static", http.StatusForbidden, "", "", ""},
+		{"Path without key", "/t/acme/history", "", http.StatusForbidden, "", "", ""},
+		{"Unknown tenant", "/t/initech/", "", http.StatusNotFound, "", "", ""},
+		{"Key outside tenants", "/", "static", http.StatusOK, "", "/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTenant, gotPath, gotPrefix = "", "", ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d; got %d", tt.status, rec.Code)
+			}
+			if gotTenant != tt.tenant || gotPath != tt.url || gotPrefix != tt.prefix {
+				t.Errorf("Expected tenant %q at %q with prefix %q; got %q at %q with prefix %q",
+					tt.tenant, tt.url, tt.prefix, gotTenant, gotPath, gotPrefix)
+			}
+		})
+	}
+
+	t.Run("Rate limits each tenant", func(t *testing.T) {
+		for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+			req := httptest.NewRequest(http.MethodGet, "/t/globex/", nil)
+			req.Header.Set("X-API-Key", "globex-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Errorf("Request %d: expected status %d; got %d", i+1, want, rec.Code)
+			}
+		}
+
+		// Other tenants are unaffected
+		req := httptest.NewRequest(http.MethodGet, "/t/acme/", nil)
+		req.Header.Set("X-API-Key", "acme-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected acme to be served; got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid is returned opening a token that is malformed or was sealed with another key
+var ErrInvalid = errors.New("token: invalid sealed token")
+
+/*
+	 Sealer seals values into URL-safe tokens that only a Sealer with
+	 the same key can open
+
+		Tokens are encrypted and authenticated with AES-GCM, so they
+		carry their own state without clients reading or changing
+		it, and nothing is stored per token. Build one with
+		NewSealer.
+*/
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+/*
+	 NewSealer returns a Sealer with a key derived from secret
+
+		Sealers sharing a secret open each other's tokens, across
+		restarts too; without one a key is made for this process, and
+		its tokens can only be opened by it.
+*/
+func NewSealer(secret []byte) *Sealer {
+	key := make([]byte, 32)
+	if len(secret) > 0 {
+		sum := sha256.Sum256(secret)
+		key = sum[:]
+	} else if _, err := rand.Read(key); err != nil {
+		// Reading from crypto/rand never fails on supported platforms
+		panic(fmt.Sprintf("token: could not make key: %v", err))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Sprintf("token: could not make cipher: %v", err))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("token: could not make cipher: %v", err))
+	}
+	return &Sealer{aead: aead}
+}
+
+// Seal encodes v as JSON and seals it into a token
+func (s *Sealer) Seal(v any) (string, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("token: could not encode: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plain)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("token: could not make nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Open decodes a token made by Seal into v, returning ErrInvalid for any other token
+func (s *Sealer) Open(tok string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return ErrInvalid
+	}
+	nonce, sealed := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(plain, v); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
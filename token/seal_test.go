@@ -0,0 +1,43 @@
+package token
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	t.Parallel()
+
+	type claims struct {
+		Joke string `json:"joke"`
+	}
+
+	t.Run("Opens its own tokens", func(t *testing.T) {
+		s := NewSealer(nil)
+		tok, err := s.Seal(claims{Joke: "a joke"})
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		var c claims
+		if err := s.Open(tok, &c); err != nil || c.Joke != "a joke" {
+			t.Errorf("Expected the sealed claims; got %+v, %v", c, err)
+		}
+	})
+
+	t.Run("Shares tokens between sealers with a secret", func(t *testing.T) {
+		tok, _ := NewSealer([]byte("secret")).Seal(claims{Joke: "shared"})
+		var c claims
+		if err := NewSealer([]byte("secret")).Open(tok, &c); err != nil || c.Joke != "shared" {
+			t.Errorf("Expected the sealed claims; got %+v, %v", c, err)
+		}
+	})
+
+	t.Run("Rejects tokens it didn't seal", func(t *testing.T) {
+		tok, _ := NewSealer(nil).Seal(claims{Joke: "elsewhere"})
+		for _, forged := range []string{tok, tok[:len(tok)-2] + "AA", "", "not base64!"} {
+			if err := NewSealer(nil).Open(forged, &claims{}); !errors.Is(err, ErrInvalid) {
+				t.Errorf("%q: expected ErrInvalid; got %v", forged, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,21 @@
+/*
+	 Package token makes the random strings used as secrets and IDs,
+	 e.g. session IDs, CSRF tokens and API keys
+
+		Tokens are URL-safe, so they fit in cookies, headers and
+		query strings without escaping.
+*/
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// String returns n random bytes as a URL-safe string
+func String(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read only fails if the system random source is broken
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
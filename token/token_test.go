@@ -0,0 +1,19 @@
+package token
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	a, b := String(32), String(32)
+	if a == b {
+		t.Errorf("Expected different tokens; got %q twice", a)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(a)
+	if err != nil || len(raw) != 32 {
+		t.Errorf("Expected 32 URL-safe bytes; got %q (%v)", a, err)
+	}
+}
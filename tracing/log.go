@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+)
+
+/*
+	 LogHandler returns h adding the trace_id and span_id of the span
+	 in each record's context, so log lines link to their traces
+
+		Only records logged with a context, e.g. through InfoContext,
+		can be correlated; others pass through unchanged.
+*/
+func LogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{h}
+}
+
+// struct to hold the handler records are passed on to
+type logHandler struct {
+	slog.Handler
+}
+
+// Handle adds the trace and span IDs of ctx to r
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if s := FromContext(ctx); s != nil {
+		r = r.Clone()
+		r.AddAttrs(slog.String("trace_id", s.TraceID.String()), slog.String("span_id", s.SpanID.String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with attrs, still adding trace IDs
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler with the group name, still adding trace IDs
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+	ctx, span := New(nil, nil, nil).Start(context.Background(), "GET /", KindServer)
+
+	logger.InfoContext(ctx, "in a span")
+	logger.Info("outside a span")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines; got %q", buf.String())
+	}
+	for _, want := range []string{"component=test", "trace_id=" + span.TraceID.String(), "span_id=" + span.SpanID.String()} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("Expected %q in %q", want, lines[0])
+		}
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("Expected no trace ID outside a span; got %q", lines[1])
+	}
+}
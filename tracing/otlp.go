@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instrumentation scope spans are reported under
+const scopeName = "github.com/jswanson806/joke-generator"
+
+// OTLP status codes
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// Client used without OTLP.Client, timing out so a hung collector can't stall exports
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+/*
+	 OTLP exports spans to an OpenTelemetry collector with OTLP/HTTP,
+	 encoded as JSON
+
+		Spans are POSTed to Endpoint with /v1/traces appended, e.g.
+		http://localhost:4318/v1/traces, along with Headers, such as
+		the API key of a hosted backend.
+*/
+type OTLP struct {
+	Endpoint string
+	Headers  map[string]string
+	// Client sends the requests, defaulting to a client with a 30s timeout
+	Client *http.Client
+}
+
+// struct to hold an OTLP key-value attribute
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// struct to hold an OTLP span
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// Export POSTs spans of resource, failing unless the collector answers 2xx
+func (o *OTLP) Export(ctx context.Context, resource Resource, spans []*Span) error {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		encoded[i] = encodeSpan(s)
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": attributes(resource)},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": scopeName},
+				"spans": encoded,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("tracing: could not encode spans: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(o.Endpoint, "/") + "/v1/traces"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("tracing: could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	client := o.Client
+	if client == nil {
+		client = defaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("tracing: could not export spans: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("tracing: could not export spans: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Function to return s in its OTLP JSON form
+func encodeSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID:           s.TraceID.String(),
+		SpanID:            s.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        attributes(s.attrs),
+	}
+	if s.parent != (SpanID{}) {
+		o.ParentSpanID = s.parent.String()
+	}
+	o.Status.Code = statusUnset
+	if s.err != "" {
+		o.Status.Code, o.Status.Message = statusError, s.err
+	}
+	return o
+}
+
+// Function to return m as OTLP attributes, sorted by key
+func attributes(m map[string]string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(m))
+	for k, v := range m {
+		a := otlpAttribute{Key: k}
+		a.Value.StringValue = v
+		attrs = append(attrs, a)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+/*
+	 ParsePairs parses comma-separated key=value pairs, the format of
+	 OTEL_EXPORTER_OTLP_HEADERS and OTEL_RESOURCE_ATTRIBUTES
+
+		Values may be percent-encoded. Empty input returns an empty map.
+*/
+func ParsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("tracing: invalid pair %q, want key=value", part)
+		}
+		v = strings.TrimSpace(v)
+		if unescaped, err := url.PathUnescape(v); err == nil {
+			v = unescaped
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOTLP(t *testing.T) {
+	t.Parallel()
+
+	var (
+		body struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []otlpAttribute `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		auth string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer collector.Close()
+
+	tr := New(nil, nil, nil)
+	ctx, parent := tr.Start(context.Background(), "GET /", KindServer)
+	_, child := tr.Start(ctx, "joke upstream", KindClient)
+	child.SetAttribute("peer", "api")
+	child.SetError(errors.New("timeout"))
+	child.end, parent.end = child.start, parent.start
+
+	o := &OTLP{Endpoint: collector.URL + "/", Headers: map[string]string{"Authorization": "Bearer key"}}
+	resource := Resource{"service.name": "jokes", "deployment.environment.name": "staging"}
+	if err := o.Export(context.Background(), resource, []*Span{child, parent}); err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	if auth != "Bearer key" {
+		t.Errorf("Expected the configured headers; got Authorization %q", auth)
+	}
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected one resource and scope; got %+v", body)
+	}
+	attrs := body.ResourceSpans[0].Resource.Attributes
+	if len(attrs) != 2 || attrs[1].Key != "service.name" || attrs[1].Value.StringValue != "jokes" {
+		t.Errorf("Expected the resource attributes, sorted; got %+v", attrs)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans; got %+v", spans)
+	}
+	got := spans[0]
+	if got.TraceID != parent.TraceID.String() || got.ParentSpanID != parent.SpanID.String() || got.Kind != KindClient {
+		t.Errorf("Expected the child span under the parent; got %+v", got)
+	}
+	if got.Status.Code != statusError || got.Status.Message != "timeout" || len(got.Attributes) != 1 {
+		t.Errorf("Expected the error status and attribute; got %+v", got)
+	}
+	if spans[1].ParentSpanID != "" || spans[1].Status.Code != statusUnset {
+		t.Errorf("Expected a root span with no status; got %+v", spans[1])
+	}
+}
+
+func TestParsePairs(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParsePairs("api-key=abc%3D, team = jokes ,")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	if len(got) != 2 || got["api-key"] != "abc=" || got["team"] != "jokes" {
+		t.Errorf("Expected both pairs, unescaped; got %v", got)
+	}
+	if _, err := ParsePairs("novalue"); err == nil {
+		t.Error("Expected an error for a pair without =")
+	}
+}
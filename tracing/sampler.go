@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+	 Sampler decides whether a trace begun here is sampled
+
+		parentSampled is the decision of the caller that sent a
+		traceparent header, or nil without one.
+*/
+type Sampler interface {
+	Sample(id TraceID, parentSampled *bool) bool
+}
+
+// SamplerFunc adapts a function to the Sampler interface
+type SamplerFunc func(id TraceID, parentSampled *bool) bool
+
+// Sample calls f
+func (f SamplerFunc) Sample(id TraceID, parentSampled *bool) bool {
+	return f(id, parentSampled)
+}
+
+// AlwaysOn samples every trace
+var AlwaysOn Sampler = SamplerFunc(func(TraceID, *bool) bool { return true })
+
+/*
+	 Ratio samples the fraction ratio (0-1) of traces
+
+		The decision is taken from the trace ID, so every service
+		sampling at the same ratio keeps the same traces.
+*/
+func Ratio(ratio float64) Sampler {
+	ratio = min(max(ratio, 0), 1)
+	bound := uint64(ratio * math.MaxUint64)
+	return SamplerFunc(func(id TraceID, _ *bool) bool {
+		if ratio == 1 {
+			return true
+		}
+		return binary.BigEndian.Uint64(id[8:]) < bound
+	})
+}
+
+// ParentBased follows the caller's decision when there is one, and root's otherwise
+func ParentBased(root Sampler) Sampler {
+	return SamplerFunc(func(id TraceID, parentSampled *bool) bool {
+		if parentSampled != nil {
+			return *parentSampled
+		}
+		return root.Sample(id, nil)
+	})
+}
+
+/*
+	 ParseSampler returns the sampler named name, as in
+	 OTEL_TRACES_SAMPLER: always_on, traceidratio,
+	 parentbased_always_on or parentbased_traceidratio
+
+		The ratio samplers keep ratio (0-1) of traces.
+*/
+func ParseSampler(name string, ratio float64) (Sampler, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("tracing: sample ratio %v is not between 0 and 1", ratio)
+	}
+	switch name {
+	case "always_on":
+		return AlwaysOn, nil
+	case "traceidratio":
+		return Ratio(ratio), nil
+	case "parentbased_always_on":
+		return ParentBased(AlwaysOn), nil
+	case "parentbased_traceidratio":
+		return ParentBased(Ratio(ratio)), nil
+	}
+	return nil, fmt.Errorf("tracing: unknown sampler %q, want always_on, traceidratio, parentbased_always_on or parentbased_traceidratio", name)
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRatio(t *testing.T) {
+	t.Parallel()
+
+	sampled := 0
+	sampler := Ratio(0.25)
+	for i := 0; i < 10000; i++ {
+		if sampler.Sample(newTraceID(), nil) {
+			sampled++
+		}
+	}
+	if sampled < 2000 || sampled > 3000 {
+		t.Errorf("Expected about 2500 of 10000 traces sampled; got %d", sampled)
+	}
+
+	id := newTraceID()
+	if Ratio(0.5).Sample(id, nil) != Ratio(0.5).Sample(id, nil) {
+		t.Error("Expected the same decision for the same trace ID")
+	}
+	if Ratio(0).Sample(id, nil) || !Ratio(1).Sample(id, nil) {
+		t.Error("Expected ratio 0 to sample nothing and 1 everything")
+	}
+}
+
+func TestParentBased(t *testing.T) {
+	t.Parallel()
+
+	sampler := ParentBased(Ratio(0))
+	yes, no := true, false
+	if !sampler.Sample(newTraceID(), &yes) || sampler.Sample(newTraceID(), &no) {
+		t.Error("Expected the parent's decision to be followed")
+	}
+	if sampler.Sample(newTraceID(), nil) {
+		t.Error("Expected the root sampler without a parent")
+	}
+}
+
+func TestParseSampler(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"always_on", "traceidratio", "parentbased_always_on", "parentbased_traceidratio"} {
+		if _, err := ParseSampler(name, 0.1); err != nil {
+			t.Errorf("%s: expected no error; got %v", name, err)
+		}
+	}
+	if _, err := ParseSampler("sometimes", 0.1); err == nil {
+		t.Error("Expected an error for an unknown sampler")
+	}
+	if _, err := ParseSampler("traceidratio", 2); err == nil {
+		t.Error("Expected an error for a ratio above 1")
+	}
+}
+
+func TestKeepErrors(t *testing.T) {
+	t.Parallel()
+
+	// Function to record one trace with a child span failing with err, returning the spans exported
+	trace := func(keepErrors bool, err error) []*Span {
+		e := &recordingExporter{}
+		tr := New(e, nil, nil)
+		tr.Sampler, tr.KeepErrors = Ratio(0), keepErrors
+		ctx, root := tr.Start(context.Background(), "GET /", KindServer)
+		_, child := tr.Start(ctx, "joke upstream", KindClient)
+		child.SetError(err)
+		child.End()
+		root.End()
+		return exported(tr, e)
+	}
+
+	if spans := trace(true, errors.New("timeout")); len(spans) != 2 {
+		t.Errorf("Expected the failed trace to be kept whole; got %d spans", len(spans))
+	}
+	if spans := trace(true, nil); len(spans) != 0 {
+		t.Errorf("Expected the successful trace to be dropped; got %d spans", len(spans))
+	}
+	if spans := trace(false, errors.New("timeout")); len(spans) != 0 {
+		t.Errorf("Expected the failed trace to be dropped without KeepErrors; got %d spans", len(spans))
+	}
+}
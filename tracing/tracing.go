@@ -0,0 +1,462 @@
+/*
+	 Package tracing records spans of requests and upstream calls and
+	 exports them to an OpenTelemetry collector over OTLP.
+
+		Trace context is read from and passed on in W3C traceparent
+		headers, so traces continue across services. The spans of a
+		trace are held until its first span here ends, when the whole
+		trace is kept or dropped, so failed traces can be kept even
+		when not sampled. Kept spans are exported in batches in the
+		background; when the exporter falls behind they are dropped
+		rather than slowing requests.
+*/
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+	"github.com/jswanson806/joke-generator/middleware"
+)
+
+// Spans buffered before more are dropped, and most exported at once
+const (
+	spanBuffer = 2048
+	maxBatch   = 512
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns id as 32 lower-case hex digits
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns id as 16 lower-case hex digits
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// Kind of a span, as numbered by OTLP
+type Kind int
+
+// Kinds of span recorded
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Resource describes the service spans come from, e.g. service.name
+type Resource map[string]string
+
+/*
+	 Span is one timed operation of a trace
+
+		Safe for concurrent use. Start one with Tracer.Start and
+		finish it with End.
+*/
+type Span struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled spans are exported
+	Sampled bool
+
+	tracer *Tracer
+	// Zero for the first span of a trace in this service
+	parent SpanID
+	kind   Kind
+	start  time.Time
+	// The spans of the trace in this service, and whether this span is
+	// its first
+	local *localTrace
+	root  bool
+
+	mu    sync.Mutex
+	name  string
+	end   time.Time
+	attrs map[string]string
+	err   string
+	ended bool
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute sets the attribute key of the span to value
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed with err, unless err is nil
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+/*
+	 End finishes the span
+
+		The spans of a trace wait for its first span here to end, then
+		are queued for export if the trace is sampled or, with
+		KeepErrors, any of them failed. Spans ending after that follow
+		the same decision.
+*/
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	failed := s.err != ""
+	s.mu.Unlock()
+
+	t := s.tracer
+	if !s.Sampled && !t.KeepErrors {
+		return
+	}
+	lt := s.local
+	lt.mu.Lock()
+	if lt.done {
+		keep := lt.keep
+		lt.mu.Unlock()
+		if keep {
+			t.queue(s)
+		}
+		return
+	}
+	lt.spans = append(lt.spans, s)
+	lt.failed = lt.failed || failed
+	if !s.root {
+		lt.mu.Unlock()
+		return
+	}
+	lt.done = true
+	lt.keep = s.Sampled || (t.KeepErrors && lt.failed)
+	spans := lt.spans
+	lt.spans = nil
+	keep := lt.keep
+	lt.mu.Unlock()
+	if keep {
+		for _, span := range spans {
+			t.queue(span)
+		}
+	}
+}
+
+// struct to hold the ended spans of a trace in this service until its first span ends
+type localTrace struct {
+	mu     sync.Mutex
+	spans  []*Span
+	failed bool
+	// Set once the first span ended, with whether the trace was kept
+	done bool
+	keep bool
+}
+
+// struct to hold the key the span of a context is stored under
+type spanKey struct{}
+
+// FromContext returns the span of ctx, nil outside of one
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// struct to hold a trace context read from a traceparent header
+type remoteParent struct {
+	traceID TraceID
+	spanID  SpanID
+	sampled bool
+}
+
+// struct to hold the key a remote parent is stored under
+type remoteKey struct{}
+
+/*
+	 Tracer starts spans and exports them
+
+		Safe for concurrent use. Build one with New and start its
+		export loop with Run.
+*/
+type Tracer struct {
+	// Sampler decides which traces are kept, defaulting to
+	// ParentBased(AlwaysOn). Set it before the Tracer is used.
+	Sampler Sampler
+	// KeepErrors keeps traces with a failed span whatever Sampler decided
+	KeepErrors bool
+
+	exporter Exporter
+	resource Resource
+	logger   *slog.Logger
+	ch       chan *Span
+}
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, resource Resource, spans []*Span) error
+}
+
+// New returns a Tracer exporting spans of resource to exporter
+func New(exporter Exporter, resource Resource, logger *slog.Logger) *Tracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Tracer{exporter: exporter, resource: resource, logger: logger, ch: make(chan *Span, spanBuffer)}
+}
+
+/*
+	 Start begins a span named name as a child of the span of ctx,
+	 or of a remote parent from a traceparent header, returning a
+	 context holding it
+
+		Without a parent the span begins a new trace.
+*/
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attrs: make(map[string]string)}
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID, s.parent, s.Sampled, s.local = parent.TraceID, parent.SpanID, parent.Sampled, parent.local
+	} else {
+		var parentSampled *bool
+		if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+			s.TraceID, s.parent, parentSampled = remote.traceID, remote.spanID, &remote.sampled
+		} else {
+			s.TraceID = newTraceID()
+		}
+		sampler := t.Sampler
+		if sampler == nil {
+			sampler = ParentBased(AlwaysOn)
+		}
+		s.Sampled = sampler.Sample(s.TraceID, parentSampled)
+		s.local, s.root = &localTrace{}, true
+	}
+	s.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Function to queue s for export, dropping it when the buffer is full
+func (t *Tracer) queue(s *Span) {
+	select {
+	case t.ch <- s:
+	default:
+		t.logger.Warn("dropped span, the exporter is falling behind", "span", s.name)
+	}
+}
+
+/*
+	 Run exports finished spans every interval, or sooner once a batch
+	 is full, until ctx is done, then exports what is left
+
+		Spans the exporter fails to take are logged and dropped.
+*/
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, maxBatch)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(ctx, t.resource, batch); err != nil {
+			t.logger.Error("could not export spans", "spans", len(batch), "error", err)
+		}
+		batch = make([]*Span, 0, maxBatch)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// Export what is left; ctx is already canceled
+			for len(t.ch) > 0 {
+				batch = append(batch, <-t.ch)
+				if len(batch) == maxBatch {
+					flush(context.WithoutCancel(ctx))
+				}
+			}
+			flush(context.WithoutCancel(ctx))
+			return
+		case s := <-t.ch:
+			batch = append(batch, s)
+			if len(batch) == maxBatch {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
+
+/*
+	 Middleware records a server span for every request, continuing
+	 the trace of a valid traceparent header
+
+		Spans are named after the method and first path segment, e.g.
+		"GET /history", so IDs in paths don't multiply span names, or
+		the route's pattern when the mux is reached with the same
+		request. They fail on 5xx responses.
+*/
+func (t *Tracer) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if remote, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+				ctx = context.WithValue(ctx, remoteKey{}, remote)
+			}
+			ctx, span := t.Start(ctx, spanName(r), KindServer)
+			defer span.End()
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			r = r.WithContext(ctx)
+			next.ServeHTTP(rec, r)
+
+			// The mux sets the pattern on the request it was given
+			if r.Pattern != "" {
+				span.SetName(r.Pattern)
+			}
+			span.SetAttribute("http.response.status_code", fmt.Sprint(rec.status))
+			if rec.status >= 500 {
+				span.SetError(fmt.Errorf("status %d", rec.status))
+			}
+		})
+	}
+}
+
+// Function to return the method and first path segment of r, e.g. "GET /history"
+func spanName(r *http.Request) string {
+	path := r.URL.Path
+	if i := strings.IndexByte(strings.TrimPrefix(path, "/"), '/'); i >= 0 {
+		path = path[:i+1]
+	}
+	return r.Method + " " + path
+}
+
+// Names returns p wrapped so every call is recorded as a client span under upstream
+func (t *Tracer) Names(upstream string, p joke.NameProvider) joke.NameProvider {
+	return joke.NameProviderFunc(func(ctx context.Context) (joke.Names, error) {
+		ctx, span := t.Start(ctx, upstream+" upstream", KindClient)
+		defer span.End()
+		n, err := p.Name(ctx)
+		span.SetError(err)
+		return n, err
+	})
+}
+
+// Jokes returns p wrapped so every call is recorded as a client span under upstream
+func (t *Tracer) Jokes(upstream string, p joke.JokeProvider) joke.JokeProvider {
+	return joke.JokeProviderFunc(func(ctx context.Context, firstName, lastName string) (string, error) {
+		ctx, span := t.Start(ctx, upstream+" upstream", KindClient)
+		defer span.End()
+		text, err := p.Joke(ctx, firstName, lastName)
+		span.SetError(err)
+		return text, err
+	})
+}
+
+// Transport returns rt, or http.DefaultTransport when nil, passing the span of each request on in a traceparent header
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if s := FromContext(r.Context()); s != nil {
+			r = r.Clone(r.Context())
+			r.Header.Set("traceparent", Traceparent(s))
+		}
+		return rt.RoundTrip(r)
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// Traceparent returns the W3C traceparent header value for s
+func Traceparent(s *Span) string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID.String() + "-" + s.SpanID.String() + "-" + flags
+}
+
+// Function to parse a W3C traceparent header, reporting whether it was valid
+func parseTraceparent(h string) (remoteParent, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return remoteParent{}, false
+	}
+	// Version 00 has exactly four fields; later versions may add more
+	if parts[0] == "00" && len(parts) != 4 {
+		return remoteParent{}, false
+	}
+	var p remoteParent
+	var flags [1]byte
+	_, errTrace := hex.Decode(p.traceID[:], []byte(parts[1]))
+	_, errSpan := hex.Decode(p.spanID[:], []byte(parts[2]))
+	_, errFlags := hex.Decode(flags[:], []byte(parts[3]))
+	if errTrace != nil || errSpan != nil || errFlags != nil || p.traceID == (TraceID{}) || p.spanID == (SpanID{}) {
+		return remoteParent{}, false
+	}
+	p.sampled = flags[0]&1 == 1
+	return p, true
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Function to return a random, non-zero trace ID
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+// Function to return a random, non-zero span ID
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
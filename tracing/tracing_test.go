@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jswanson806/joke-generator/joke"
+)
+
+// struct to hold the spans exported to it
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(ctx context.Context, resource Resource, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Function to stop t's export loop, so every ended span is exported, and return the spans
+func exported(t *Tracer, e *recordingExporter) []*Span {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t.Run(ctx, time.Hour)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.spans
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	p, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || p.traceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || p.spanID.String() != "00f067aa0ba902b7" || !p.sampled {
+		t.Errorf("Expected the sampled parent; got %+v (%v)", p, ok)
+	}
+	for _, h := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, ok := parseTraceparent(h); ok {
+			t.Errorf("Expected %q to be invalid", h)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Continues the caller's trace with child spans", func(t *testing.T) {
+		e := &recordingExporter{}
+		tr := New(e, nil, nil)
+		jokes := tr.Jokes("joke", joke.JokeProviderFunc(func(ctx context.Context, first, last string) (string, error) {
+			return "", errors.New("upstream down")
+		}))
+		handler := tr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			jokes.Joke(r.Context(), "Chuck", "Norris")
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/history/12", nil)
+		r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		spans := exported(tr, e)
+		if len(spans) != 2 {
+			t.Fatalf("Expected 2 spans; got %d", len(spans))
+		}
+		client, server := spans[0], spans[1]
+		if server.name != "GET /history" || server.kind != KindServer || server.err != "status 502" {
+			t.Errorf("Expected a failed server span named after the route; got %q %v %q", server.name, server.kind, server.err)
+		}
+		if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.parent.String() != "00f067aa0ba902b7" {
+			t.Errorf("Expected the server span to continue the caller's trace; got %s under %s", server.TraceID, server.parent)
+		}
+		if client.TraceID != server.TraceID || client.parent != server.SpanID || client.err != "upstream down" {
+			t.Errorf("Expected a failed child span of the server span; got %+v", client)
+		}
+	})
+
+	t.Run("Starts a new trace without a valid traceparent", func(t *testing.T) {
+		e := &recordingExporter{}
+		tr := New(e, nil, nil)
+		handler := tr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("traceparent", "garbage")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		spans := exported(tr, e)
+		if len(spans) != 1 || spans[0].TraceID == (TraceID{}) || spans[0].parent != (SpanID{}) || spans[0].err != "" {
+			t.Errorf("Expected one successful root span; got %+v", spans)
+		}
+	})
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	tr := New(&recordingExporter{}, nil, nil)
+	ctx, span := tr.Start(context.Background(), "call", KindClient)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	res, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	res.Body.Close()
+
+	if want := Traceparent(span); got != want || !strings.HasSuffix(got, "-01") {
+		t.Errorf("Expected traceparent %q; got %q", want, got)
+	}
+}
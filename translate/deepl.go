@@ -0,0 +1,67 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Endpoints of the DeepL API, for Pro keys and for Free keys, which end in ":fx"
+const (
+	deepLURL     = "https://api.deepl.com/v2/translate"
+	deepLFreeURL = "https://api-free.deepl.com/v2/translate"
+)
+
+// DeepL's codes for Languages it names differently: plain Portuguese is deprecated
+var deepLTargets = map[string]string{"pt": "PT-BR"}
+
+// DeepL translates with the DeepL API (https://www.deepl.com/pro-api)
+type DeepL struct {
+	// APIKey authenticates with the API, choosing the Free or Pro endpoint
+	APIKey string
+	// URL of the API, when not the endpoint APIKey is for
+	URL string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// struct to hold a DeepL /v2/translate request
+type deepLRequest struct {
+	Text       []string `json:"text"`
+	SourceLang string   `json:"source_lang"`
+	TargetLang string   `json:"target_lang"`
+}
+
+// struct to hold a DeepL /v2/translate response
+type deepLResponse struct {
+	Translations []struct {
+		Text string `json:"text"`
+	} `json:"translations"`
+}
+
+// Translate implements Translator
+func (d *DeepL) Translate(ctx context.Context, text, target string) (string, error) {
+	url := d.URL
+	if url == "" {
+		url = deepLURL
+		if strings.HasSuffix(d.APIKey, ":fx") {
+			url = deepLFreeURL
+		}
+	}
+	lang, ok := deepLTargets[target]
+	if !ok {
+		lang = strings.ToUpper(target)
+	}
+
+	var out deepLResponse
+	in := deepLRequest{Text: []string{text}, SourceLang: strings.ToUpper(Source), TargetLang: lang}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + d.APIKey}}
+	if err := postJSON(ctx, d.Client, url, header, in, &out); err != nil {
+		return "", fmt.Errorf("translate: DeepL: %w", err)
+	}
+	if len(out.Translations) == 0 {
+		return "", fmt.Errorf("translate: DeepL returned no translation")
+	}
+	return out.Translations[0].Text, nil
+}
@@ -0,0 +1,38 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDeepL(t *testing.T) {
+	t.Parallel()
+
+	var got deepLRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		w.Write([]byte(`{"translations":[{"detected_source_language":"EN","text":"Ada pode dividir por zero."}]}`))
+	}))
+	defer srv.Close()
+
+	d := &DeepL{APIKey: "secret:fx", URL: srv.URL, Client: srv.Client()}
+	out, err := d.Translate(context.Background(), "Ada can divide by zero.", "pt")
+	if err != nil || out != "Ada pode dividir por zero." {
+		t.Errorf("Expected the translation; got %q, %v", out, err)
+	}
+	want := deepLRequest{Text: []string{"Ada can divide by zero."}, SourceLang: "EN", TargetLang: "PT-BR"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected request %+v; got %+v", want, got)
+	}
+	if auth != "DeepL-Auth-Key secret:fx" {
+		t.Errorf("Expected the key in Authorization; got %q", auth)
+	}
+}
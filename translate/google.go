@@ -0,0 +1,58 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Endpoint of Cloud Translation Basic (v2), keyed with an API key
+const googleURL = "https://translation.googleapis.com/language/translate/v2"
+
+// Google translates with Google Cloud Translation (https://cloud.google.com/translate)
+type Google struct {
+	// APIKey is an API key of a project with the Cloud Translation API enabled
+	APIKey string
+	// URL of the API, defaulting to Cloud Translation Basic
+	URL string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// struct to hold a Cloud Translation v2 request
+type googleRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+}
+
+// struct to hold a Cloud Translation v2 response
+type googleResponse struct {
+	Data struct {
+		Translations []struct {
+			TranslatedText string `json:"translatedText"`
+		} `json:"translations"`
+	} `json:"data"`
+}
+
+// Translate implements Translator
+func (g *Google) Translate(ctx context.Context, text, target string) (string, error) {
+	url := g.URL
+	if url == "" {
+		url = googleURL
+	}
+
+	var out googleResponse
+	in := googleRequest{Q: text, Source: Source, Target: target, Format: "text"}
+	// The key goes in a header rather than the query, keeping it out of
+	// the URLs errors quote
+	header := http.Header{"X-Goog-Api-Key": {g.APIKey}}
+	if err := postJSON(ctx, g.Client, url, header, in, &out); err != nil {
+		return "", fmt.Errorf("translate: Google: %w", err)
+	}
+	if len(out.Data.Translations) == 0 {
+		return "", fmt.Errorf("translate: Google returned no translation")
+	}
+	return out.Data.Translations[0].TranslatedText, nil
+}
@@ -0,0 +1,52 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGoogle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Translates", func(t *testing.T) {
+		var got googleRequest
+		var key string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get("X-Goog-Api-Key")
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			w.Write([]byte(`{"data":{"translations":[{"translatedText":"Ada kann durch null teilen."}]}}`))
+		}))
+		defer srv.Close()
+
+		g := &Google{APIKey: "secret", URL: srv.URL, Client: srv.Client()}
+		out, err := g.Translate(context.Background(), "Ada can divide by zero.", "de")
+		if err != nil || out != "Ada kann durch null teilen." {
+			t.Errorf("Expected the translation; got %q, %v", out, err)
+		}
+		if want := (googleRequest{Q: "Ada can divide by zero.", Source: "en", Target: "de", Format: "text"}); got != want {
+			t.Errorf("Expected request %+v; got %+v", want, got)
+		}
+		if key != "secret" {
+			t.Errorf("Expected the key in X-Goog-Api-Key; got %q", key)
+		}
+	})
+
+	t.Run("Reports errors without the key", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":{"code":400,"message":"API key not valid"}}`, http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		g := &Google{APIKey: "secret", URL: srv.URL, Client: srv.Client()}
+		_, err := g.Translate(context.Background(), "Hi", "fr")
+		if err == nil || !strings.Contains(err.Error(), "API key not valid") || strings.Contains(err.Error(), "secret") {
+			t.Errorf("Expected the API's error without the key; got %v", err)
+		}
+	})
+}
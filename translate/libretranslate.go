@@ -0,0 +1,51 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+	 LibreTranslate translates with a LibreTranslate server
+
+		LibreTranslate (https://libretranslate.com) is open source and
+		can be self-hosted, so jokes needn't leave the network.
+*/
+type LibreTranslate struct {
+	// URL of the server, e.g. https://libretranslate.com
+	URL string
+	// APIKey is sent when set; self-hosted servers may not need one
+	APIKey string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// struct to hold a LibreTranslate /translate request
+type libreRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// struct to hold a LibreTranslate /translate response, or its error
+type libreResponse struct {
+	TranslatedText string `json:"translatedText"`
+	Error          string `json:"error"`
+}
+
+// Translate implements Translator
+func (l *LibreTranslate) Translate(ctx context.Context, text, target string) (string, error) {
+	var out libreResponse
+	in := libreRequest{Q: text, Source: Source, Target: target, Format: "text", APIKey: l.APIKey}
+	if err := postJSON(ctx, l.Client, strings.TrimSuffix(l.URL, "/")+"/translate", nil, in, &out); err != nil {
+		return "", fmt.Errorf("translate: LibreTranslate: %w", err)
+	}
+	if out.TranslatedText == "" {
+		return "", fmt.Errorf("translate: LibreTranslate returned no translation: %q", out.Error)
+	}
+	return out.TranslatedText, nil
+}
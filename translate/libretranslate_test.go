@@ -0,0 +1,51 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLibreTranslate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Translates", func(t *testing.T) {
+		var got libreRequest
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/translate" {
+				t.Errorf("Expected /translate; got %s", r.URL.Path)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			w.Write([]byte(`{"translatedText":"¿Por qué Ada cruzó la calle?"}`))
+		}))
+		defer srv.Close()
+
+		l := &LibreTranslate{URL: srv.URL + "/", APIKey: "secret", Client: srv.Client()}
+		out, err := l.Translate(context.Background(), "Why did Ada cross the road?", "es")
+		if err != nil || out != "¿Por qué Ada cruzó la calle?" {
+			t.Errorf("Expected the translation; got %q, %v", out, err)
+		}
+		want := libreRequest{Q: "Why did Ada cross the road?", Source: "en", Target: "es", Format: "text", APIKey: "secret"}
+		if got != want {
+			t.Errorf("Expected request %+v; got %+v", want, got)
+		}
+	})
+
+	t.Run("Reports errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"Invalid API key"}`))
+		}))
+		defer srv.Close()
+
+		l := &LibreTranslate{URL: srv.URL, Client: srv.Client()}
+		if _, err := l.Translate(context.Background(), "Hi", "fr"); err == nil || !strings.Contains(err.Error(), "Invalid API key") {
+			t.Errorf("Expected the server's error; got %v", err)
+		}
+	})
+}
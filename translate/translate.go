@@ -0,0 +1,125 @@
+/*
+	 Package translate translates jokes with machine translation
+	 services
+
+		Translator is implemented for DeepL, Google Cloud Translation
+		and LibreTranslate, and by Noop; New picks one from
+		configuration. Jokes are always English, the language of the
+		upstream providers.
+*/
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Source is the language jokes are translated from
+const Source = "en"
+
+// Languages jokes can be translated into, as ISO 639-1 codes; every
+// provider supports them all
+var Languages = []string{"de", "es", "fr", "it", "ja", "nl", "pl", "pt", "ru"}
+
+// Providers New accepts
+const (
+	ProviderDeepL          = "deepl"
+	ProviderGoogle         = "google"
+	ProviderLibreTranslate = "libretranslate"
+	ProviderNoop           = "noop"
+)
+
+// Largest error response body quoted in errors
+const maxErrorBody = 512
+
+// Translator translates text from Source into target, one of Languages
+type Translator interface {
+	Translate(ctx context.Context, text, target string) (string, error)
+}
+
+/*
+	 Noop is a Translator returning text unchanged
+
+		It serves the translated routes without a provider account,
+		e.g. in development; jokes stay English.
+*/
+type Noop struct{}
+
+// Translate implements Translator
+func (Noop) Translate(ctx context.Context, text, target string) (string, error) {
+	return text, nil
+}
+
+// Config configures a Translator, see New
+type Config struct {
+	// Provider is one of the Provider constants
+	Provider string
+	// APIKey authenticates with the provider; DeepL and Google need one
+	APIKey string
+	// URL of a LibreTranslate server, which it needs, or of the DeepL or
+	// Google API in place of their own
+	URL string
+	// Client sends the requests, defaulting to http.DefaultClient
+	Client *http.Client
+}
+
+// New returns the Translator of c.Provider, keyed and configured by c
+func New(c Config) (Translator, error) {
+	switch c.Provider {
+	case ProviderDeepL:
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("translate: %s needs an API key", c.Provider)
+		}
+		return &DeepL{APIKey: c.APIKey, URL: c.URL, Client: c.Client}, nil
+	case ProviderGoogle:
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("translate: %s needs an API key", c.Provider)
+		}
+		return &Google{APIKey: c.APIKey, URL: c.URL, Client: c.Client}, nil
+	case ProviderLibreTranslate:
+		if c.URL == "" {
+			return nil, fmt.Errorf("translate: %s needs a server URL", c.Provider)
+		}
+		return &LibreTranslate{URL: c.URL, APIKey: c.APIKey, Client: c.Client}, nil
+	case ProviderNoop:
+		return Noop{}, nil
+	}
+	return nil, fmt.Errorf("translate: unknown provider %q", c.Provider)
+}
+
+// Function to POST in as JSON to url with header, decoding the response into out
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("could not encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not build request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach the API: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		quoted, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return fmt.Errorf("the API answered status %d: %q", res.StatusCode, quoted)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package translate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		c    Config
+		want Translator
+	}{
+		{Config{Provider: ProviderDeepL, APIKey: "k"}, &DeepL{APIKey: "k"}},
+		{Config{Provider: ProviderGoogle, APIKey: "k"}, &Google{APIKey: "k"}},
+		{Config{Provider: ProviderLibreTranslate, URL: "http://localhost:5000"}, &LibreTranslate{URL: "http://localhost:5000"}},
+		{Config{Provider: ProviderNoop}, Noop{}},
+	} {
+		got, err := New(tc.c)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %#v; got %#v, %v", tc.c.Provider, tc.want, got, err)
+		}
+	}
+
+	for _, c := range []Config{
+		{Provider: ProviderDeepL},
+		{Provider: ProviderGoogle},
+		{Provider: ProviderLibreTranslate},
+		{Provider: "babelfish"},
+	} {
+		if _, err := New(c); err == nil {
+			t.Errorf("%s: expected an error", c.Provider)
+		}
+	}
+}
+
+func TestNoop(t *testing.T) {
+	t.Parallel()
+
+	if out, err := (Noop{}).Translate(context.Background(), "Hi", "es"); out != "Hi" || err != nil {
+		t.Errorf("Expected the text unchanged; got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+	 Package trending ranks jokes by how often they were served
+	 lately.
+
+		Each serve adds one to a joke's score, which then halves every
+		half-life, so a burst of serves today outranks a steady trickle
+		last week.
+*/
+package trending
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Half-life of a serve when New is given none
+const DefaultHalfLife = 6 * time.Hour
+
+// Joke is a trending joke and its score
+type Joke struct {
+	Joke     string `json:"joke"`
+	Category string `json:"category"`
+	// Score is the serves decayed to now, each worth 1 when it happened
+	Score float64 `json:"score"`
+	// Serves counts every serve since the joke was first tracked
+	Serves     int       `json:"serves"`
+	LastServed time.Time `json:"last_served"`
+}
+
+// struct to hold a tracked joke's score as of its last serve
+type entry struct {
+	Joke
+	tenant string
+}
+
+/*
+	 Tracker keeps the trending score of served jokes
+
+		Safe for concurrent use. Build one with New. It tracks at most
+		limit jokes, forgetting those with the lowest scores.
+*/
+type Tracker struct {
+	halfLife time.Duration
+	limit    int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// New returns a Tracker of at most limit jokes whose serves halve in value every halfLife
+func New(halfLife time.Duration, limit int) *Tracker {
+	if halfLife <= 0 {
+		halfLife = DefaultHalfLife
+	}
+	return &Tracker{halfLife: halfLife, limit: limit, now: time.Now, entries: make(map[string]*entry)}
+}
+
+// Record counts a serve of joke to tenant at at
+func (t *Tracker) Record(tenant, joke, category string, at time.Time) {
+	key := tenant + "\x00" + joke
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{Joke: Joke{Joke: joke, Category: category, LastServed: at}, tenant: tenant}
+		t.entries[key] = e
+	}
+	// Bring the score forward to at, then add this serve
+	e.Score = t.decay(e.Score, at.Sub(e.LastServed)) + 1
+	e.Serves++
+	if at.After(e.LastServed) {
+		e.LastServed = at
+	}
+
+	if len(t.entries) > t.limit {
+		t.pruneLocked()
+	}
+}
+
+/*
+	 Top returns the tenant's n highest scoring jokes, highest first
+
+		An empty category includes every category.
+*/
+func (t *Tracker) Top(tenant, category string, n int) []Joke {
+	now := t.now()
+	t.mu.Lock()
+	top := make([]Joke, 0, len(t.entries))
+	for _, e := range t.entries {
+		if e.tenant != tenant || (category != "" && e.Category != category) {
+			continue
+		}
+		j := e.Joke
+		j.Score = math.Round(t.decay(e.Score, now.Sub(e.LastServed))*1000) / 1000
+		top = append(top, j)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		return top[i].LastServed.After(top[j].LastServed)
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// Function to return score decayed over elapsed
+func (t *Tracker) decay(score float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return score
+	}
+	return score * math.Exp2(-elapsed.Hours()/t.halfLife.Hours())
+}
+
+// Function to forget the lowest scoring tenth of the jokes, so pruning is rare
+func (t *Tracker) pruneLocked() {
+	now := t.now()
+	type scored struct {
+		key   string
+		score float64
+	}
+	all := make([]scored, 0, len(t.entries))
+	for key, e := range t.entries {
+		all = append(all, scored{key, t.decay(e.Score, now.Sub(e.LastServed))})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].score < all[j].score })
+	for _, s := range all[:len(all)-t.limit+t.limit/10] {
+		delete(t.entries, s.key)
+	}
+}
@@ -0,0 +1,64 @@
+package trending
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Recent serves outrank older ones", func(t *testing.T) {
+		tr := New(time.Hour, 100)
+		tr.now = func() time.Time { return now }
+
+		// Three serves two half-lives ago are worth less than one now and one an hour ago
+		for i := 0; i < 3; i++ {
+			tr.Record("", "old favourite", "nerdy", now.Add(-2*time.Hour))
+		}
+		tr.Record("", "new hit", "nerdy", now.Add(-time.Hour))
+		tr.Record("", "new hit", "nerdy", now)
+
+		top := tr.Top("", "", 10)
+		if len(top) != 2 || top[0].Joke != "new hit" {
+			t.Fatalf("Expected the new hit first; got %+v", top)
+		}
+		if top[0].Score != 1.5 || top[0].Serves != 2 {
+			t.Errorf("Expected score 1.5 from 2 serves; got %+v", top[0])
+		}
+		if math.Abs(top[1].Score-0.75) > 0.001 {
+			t.Errorf("Expected the old favourite to score 0.75; got %v", top[1].Score)
+		}
+	})
+
+	t.Run("Filters by tenant and category", func(t *testing.T) {
+		tr := New(time.Hour, 100)
+		tr.Record("", "nerdy joke", "nerdy", now)
+		tr.Record("", "pun", "puns", now)
+		tr.Record("acme", "acme joke", "nerdy", now)
+
+		if top := tr.Top("", "puns", 10); len(top) != 1 || top[0].Joke != "pun" {
+			t.Errorf("Expected only the pun; got %+v", top)
+		}
+		if top := tr.Top("acme", "", 10); len(top) != 1 || top[0].Joke != "acme joke" {
+			t.Errorf("Expected only the tenant's joke; got %+v", top)
+		}
+	})
+
+	t.Run("Forgets the lowest scores over the limit", func(t *testing.T) {
+		tr := New(time.Hour, 2)
+		tr.now = func() time.Time { return now }
+		tr.Record("", "popular", "nerdy", now)
+		tr.Record("", "popular", "nerdy", now)
+		tr.Record("", "faded", "nerdy", now.Add(-5*time.Hour))
+		tr.Record("", "fresh", "nerdy", now)
+
+		top := tr.Top("", "", 10)
+		if len(top) != 2 || top[0].Joke != "popular" || top[1].Joke != "fresh" {
+			t.Errorf("Expected popular and fresh; got %+v", top)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+/*
+	 Package twilio answers Twilio voice webhooks
+
+		Twilio signs each webhook request with the account's auth
+		token; Valid checks the signature before a call is answered
+		with the TwiML Voice built by Say.
+*/
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Header Twilio sends a webhook request's signature in
+const SignatureHeader = "X-Twilio-Signature"
+
+// ContentType of TwiML responses
+const ContentType = "text/xml; charset=utf-8"
+
+/*
+	 Signature returns the signature Twilio sends with a POST to
+	 webhookURL carrying params
+
+		It is the base64 HMAC-SHA1, keyed with authToken, of the URL
+		followed by each parameter's name and value, sorted by name.
+*/
+func Signature(authToken, webhookURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(webhookURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether signature is the one Twilio sends with a POST to webhookURL carrying params
+func Valid(authToken, webhookURL string, params url.Values, signature string) bool {
+	want := Signature(authToken, webhookURL, params)
+	return hmac.Equal([]byte(want), []byte(signature))
+}
+
+// struct to hold a TwiML Voice response
+type response struct {
+	XMLName xml.Name `xml:"Response"`
+	Verbs   []any
+}
+
+// struct to hold a <Say> verb
+type say struct {
+	XMLName xml.Name `xml:"Say"`
+	Voice   string   `xml:"voice,attr,omitempty"`
+	Text    string   `xml:",chardata"`
+}
+
+// struct to hold a <Pause> verb
+type pause struct {
+	XMLName xml.Name `xml:"Pause"`
+	Length  int      `xml:"length,attr"`
+}
+
+/*
+	 Say returns a TwiML Voice response speaking each line in voice,
+	 pausing for a second between lines
+
+		An empty voice leaves the choice to Twilio.
+*/
+func Say(voice string, lines ...string) ([]byte, error) {
+	var res response
+	for i, line := range lines {
+		if i > 0 {
+			res.Verbs = append(res.Verbs, pause{Length: 1})
+		}
+		res.Verbs = append(res.Verbs, say{Voice: voice, Text: line})
+	}
+	body, err := xml.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("twilio: could not encode TwiML: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,56 @@
+package twilio
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+
+	// Example from Twilio's webhook security documentation
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	webhookURL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+	want := "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+
+	if got := Signature("12345", webhookURL, params); got != want {
+		t.Errorf("Expected signature %q; got %q", want, got)
+	}
+	if !Valid("12345", webhookURL, params, want) {
+		t.Error("Expected the signature to be valid")
+	}
+	if Valid("other", webhookURL, params, want) {
+		t.Error("Expected the signature to be invalid with another token")
+	}
+	params.Set("Digits", "9999")
+	if Valid("12345", webhookURL, params, want) {
+		t.Error("Expected the signature to be invalid with other params")
+	}
+}
+
+func TestSay(t *testing.T) {
+	t.Parallel()
+
+	body, err := Say("alice", "Why did Ada cross the road?", "To <debug> it & more.")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<Response><Say voice="alice">Why did Ada cross the road?</Say><Pause length="1"></Pause>` +
+		`<Say voice="alice">To &lt;debug&gt; it &amp; more.</Say></Response>`
+	if string(body) != want {
+		t.Errorf("Expected %s; got %s", want, body)
+	}
+
+	body, _ = Say("", "Hello")
+	if strings.Contains(string(body), "voice=") {
+		t.Errorf("Expected no voice attribute; got %s", body)
+	}
+}
@@ -0,0 +1,33 @@
+// Fetch a joke from the page's own server and show it
+(function () {
+  var joke = document.getElementById("joke");
+  var button = document.getElementById("next");
+
+  function load() {
+    button.disabled = true;
+    // Relative to the page, so tenant prefixes like /t/acme/ are kept
+    fetch("./", { headers: { Accept: "application/json" } })
+      .then(function (res) {
+        return res.json().then(function (body) {
+          if (!res.ok) {
+            throw new Error(body.message || res.statusText);
+          }
+          return body.joke;
+        });
+      })
+      .then(function (text) {
+        joke.className = "";
+        joke.textContent = text;
+      })
+      .catch(function (err) {
+        joke.className = "error";
+        joke.textContent = "Could not get a joke: " + err.message;
+      })
+      .finally(function () {
+        button.disabled = false;
+      });
+  }
+
+  button.addEventListener("click", load);
+  load();
+})();
@@ -0,0 +1,160 @@
+/*
+	 Package ui serves the browser page and its embedded static assets
+
+		Assets are served under names carrying a hash of their content,
+		e.g. /static/app.3f2a9c1b04.css, so they can be cached forever
+		and a changed file is fetched under its new name.
+*/
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Path the assets are served under
+const StaticPrefix = "/static/"
+
+// Cache-Control of hashed and unhashed asset names
+const (
+	immutableCache  = "public, max-age=31536000, immutable"
+	revalidateCache = "no-cache"
+)
+
+// Length of the content hash in asset names, in hex digits
+const hashLen = 10
+
+//go:embed static
+var staticFS embed.FS
+
+//go:embed index.html
+var indexHTML string
+
+// struct to hold an embedded asset and its hashed name
+type asset struct {
+	name    string
+	content []byte
+	hash    string
+}
+
+/*
+	 UI serves the page and the assets in an fs.FS
+
+		Build one with New for the embedded assets, or NewFS for
+		others, e.g. in tests.
+*/
+type UI struct {
+	// Assets by name, e.g. app.css, and by hashed name
+	byName   map[string]*asset
+	byHashed map[string]*asset
+	page     []byte
+	modTime  time.Time
+}
+
+// New returns a UI serving the embedded assets
+func New() (*UI, error) {
+	static, err := fs.Sub(staticFS, "static")
+	// Handle errors while opening the embedded directory
+	if err != nil {
+		return nil, fmt.Errorf("ui: could not open assets: %w", err)
+	}
+	return NewFS(static, indexHTML)
+}
+
+/*
+	 NewFS returns a UI serving the files at the root of assets and a
+	 page rendered from the html/template page
+
+		The page links assets with {{asset "app.css"}}, which renders
+		the asset's hashed path.
+*/
+func NewFS(assets fs.FS, page string) (*UI, error) {
+	u := &UI{byName: map[string]*asset{}, byHashed: map[string]*asset{}, modTime: time.Now()}
+
+	entries, err := fs.ReadDir(assets, ".")
+	// Handle errors while listing assets
+	if err != nil {
+		return nil, fmt.Errorf("ui: could not list assets: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		content, err := fs.ReadFile(assets, e.Name())
+		// Handle errors while reading an asset
+		if err != nil {
+			return nil, fmt.Errorf("ui: could not read %s: %w", e.Name(), err)
+		}
+		sum := sha256.Sum256(content)
+		a := &asset{name: e.Name(), content: content, hash: hex.EncodeToString(sum[:])[:hashLen]}
+		u.byName[a.name] = a
+		u.byHashed[hashedName(a.name, a.hash)] = a
+	}
+
+	tmpl, err := template.New("page").Funcs(template.FuncMap{"asset": u.Path}).Parse(page)
+	// Handle errors while parsing the page
+	if err != nil {
+		return nil, fmt.Errorf("ui: could not parse page: %w", err)
+	}
+	var buf bytes.Buffer
+	// Handle errors while rendering the page, e.g. a missing asset
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("ui: could not render page: %w", err)
+	}
+	u.page = buf.Bytes()
+	return u, nil
+}
+
+// Path returns the hashed path of asset name, e.g. /static/app.3f2a9c1b04.css
+func (u *UI) Path(name string) (string, error) {
+	a, ok := u.byName[name]
+	if !ok {
+		return "", fmt.Errorf("ui: no asset %q", name)
+	}
+	return StaticPrefix + hashedName(a.name, a.hash), nil
+}
+
+// ServePage serves the page, which links the current asset names so is never cached
+func (u *UI) ServePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", revalidateCache)
+	http.ServeContent(w, r, "", u.modTime, bytes.NewReader(u.page))
+}
+
+/*
+	 ServeAsset serves the asset named by the path after StaticPrefix
+
+		Hashed names are cached as immutable. Plain names, e.g. for
+		links outside the page, are served too but revalidated with
+		their ETag.
+*/
+func (u *UI) ServeAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, StaticPrefix)
+	a, ok := u.byHashed[name]
+	if ok {
+		w.Header().Set("Cache-Control", immutableCache)
+	} else if a, ok = u.byName[name]; ok {
+		w.Header().Set("Cache-Control", revalidateCache)
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", `"`+a.hash+`"`)
+	// ServeContent sets the Content-Type from the name's extension
+	http.ServeContent(w, r, a.name, u.modTime, bytes.NewReader(a.content))
+}
+
+// Function to insert hash before the extension of name, e.g. app.<hash>.css
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
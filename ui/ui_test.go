@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUI(t *testing.T) {
+	t.Parallel()
+
+	assets := fstest.MapFS{
+		"app.css": {Data: []byte("body { color: red; }")},
+	}
+	u, err := NewFS(assets, `<link rel="stylesheet" href="{{asset "app.css"}}">`)
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+	hashed, err := u.Path("app.css")
+	if err != nil {
+		t.Fatalf("Expected no error; got %v", err)
+	}
+
+	// Function to request path from handler
+	get := func(handler http.HandlerFunc, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("Hashes asset names", func(t *testing.T) {
+		if !strings.HasPrefix(hashed, "/static/app.") || !strings.HasSuffix(hashed, ".css") || len(hashed) != len("/static/app..css")+hashLen {
+			t.Errorf("Expected a hashed path; got %q", hashed)
+		}
+	})
+
+	t.Run("Page links hashed assets", func(t *testing.T) {
+		rec := get(u.ServePage, "/ui", nil)
+		if !strings.Contains(rec.Body.String(), `href="`+hashed+`"`) {
+			t.Errorf("Expected page to link %s; got %q", hashed, rec.Body.String())
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != revalidateCache {
+			t.Errorf("Expected Cache-Control %q; got %q", revalidateCache, cc)
+		}
+	})
+
+	t.Run("Hashed assets are immutable", func(t *testing.T) {
+		rec := get(u.ServeAsset, hashed, nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != "body { color: red; }" {
+			t.Errorf("Expected the asset; got %d %q", rec.Code, rec.Body.String())
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != immutableCache {
+			t.Errorf("Expected Cache-Control %q; got %q", immutableCache, cc)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+			t.Errorf("Expected Content-Type text/css; got %q", ct)
+		}
+	})
+
+	t.Run("Plain names are revalidated", func(t *testing.T) {
+		rec := get(u.ServeAsset, "/static/app.css", nil)
+		if cc := rec.Header().Get("Cache-Control"); cc != revalidateCache {
+			t.Errorf("Expected Cache-Control %q; got %q", revalidateCache, cc)
+		}
+		etag := rec.Header().Get("ETag")
+		rec = get(u.ServeAsset, "/static/app.css", http.Header{"If-None-Match": {etag}})
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("Expected status 304 for a matching ETag; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Unknown assets are not found", func(t *testing.T) {
+		if rec := get(u.ServeAsset, "/static/app.0000000000.css", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404; got %d", rec.Code)
+		}
+	})
+
+	t.Run("Pages linking missing assets fail", func(t *testing.T) {
+		if _, err := NewFS(assets, `{{asset "missing.js"}}`); err == nil {
+			t.Error("Expected an error for a missing asset")
+		}
+	})
+
+	t.Run("Embedded assets", func(t *testing.T) {
+		u, err := New()
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		for _, name := range []string{"app.css", "app.js", "favicon.svg"} {
+			if _, err := u.Path(name); err != nil {
+				t.Errorf("Expected embedded asset %s; got %v", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,212 @@
+// Package vcr records upstream HTTP interactions to fixture files and
+// replays them, so tests and offline runs don't depend on live upstreams.
+package vcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Mode selects what a Transport does with each request
+type Mode int
+
+const (
+	// Off passes requests straight through to the wrapped transport
+	Off Mode = iota
+	// Record passes requests through and saves each response to a fixture
+	Record
+	// Replay serves responses from fixtures without touching the network
+	Replay
+)
+
+// ErrNoFixture is returned in Replay mode when no fixture matches a request
+var ErrNoFixture = errors.New("vcr: no fixture for request")
+
+// ParseMode parses "off", "record" or "replay"
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "off":
+		return Off, nil
+	case "record":
+		return Record, nil
+	case "replay":
+		return Replay, nil
+	default:
+		return Off, fmt.Errorf("vcr: unknown mode %q (want off, record or replay)", s)
+	}
+}
+
+// struct to hold a recorded response
+type Interaction struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// struct to hold every interaction recorded for one request
+type Fixture struct {
+	Method       string        `json:"method"`
+	URL          string        `json:"url"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+/*
+	 Transport is an http.RoundTripper that records or replays responses
+
+		Fixtures are stored in Dir, one JSON file per method and URL.
+		A fixture may hold several interactions; Replay cycles through
+		them in order so random upstreams can return varied responses.
+*/
+type Transport struct {
+	// Mode selects pass-through, recording or replaying
+	Mode Mode
+	// Dir holds the fixture files
+	Dir string
+	// Next performs real requests, defaults to http.DefaultTransport
+	Next http.RoundTripper
+
+	mu     sync.Mutex
+	replay map[string]int
+}
+
+// New returns a Transport in mode storing fixtures in dir
+func New(mode Mode, dir string, next http.RoundTripper) *Transport {
+	return &Transport{Mode: mode, Dir: dir, Next: next}
+}
+
+// RoundTrip records, replays or passes through req depending on Mode
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.Mode {
+	case Replay:
+		return t.replayResponse(req)
+	case Record:
+		return t.recordResponse(req)
+	default:
+		return t.next().RoundTrip(req)
+	}
+}
+
+// Function to serve the next recorded interaction for req
+func (t *Transport) replayResponse(req *http.Request) (*http.Response, error) {
+	key := fixtureKey(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := t.load(key)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(f.Interactions) == 0) {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, req.Method, req.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Cycle through the recorded interactions
+	if t.replay == nil {
+		t.replay = make(map[string]int)
+	}
+	in := f.Interactions[t.replay[key]%len(f.Interactions)]
+	t.replay[key]++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewBufferString(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Function to make req for real and append the response to its fixture
+func (t *Transport) recordResponse(req *http.Request) (*http.Response, error) {
+	res, err := t.next().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the body so it can be both saved and returned
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("vcr: could not read response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	key := fixtureKey(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := t.load(key)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = Fixture{Method: req.Method, URL: req.URL.String()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Interactions = append(f.Interactions, Interaction{
+		Status: res.StatusCode,
+		Header: res.Header.Clone(),
+		Body:   string(body),
+	})
+	if err := t.save(key, f); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Function to read the fixture stored under key
+func (t *Transport) load(key string) (Fixture, error) {
+	var f Fixture
+	data, err := os.ReadFile(filepath.Join(t.Dir, key+".json"))
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("vcr: could not decode fixture %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// Function to write the fixture stored under key
+func (t *Transport) save(key string, f Fixture) error {
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return fmt.Errorf("vcr: could not create fixture directory: %w", err)
+	}
+	// Keep URLs and bodies readable in the fixture files
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("vcr: could not encode fixture: %w", err)
+	}
+	return os.WriteFile(filepath.Join(t.Dir, key+".json"), buf.Bytes(), 0o644)
+}
+
+// Function to return the wrapped transport
+func (t *Transport) next() http.RoundTripper {
+	if t.Next == nil {
+		return http.DefaultTransport
+	}
+	return t.Next
+}
+
+// Function to name the fixture file for req after its method and URL
+func fixtureKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,82 @@
+package vcr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Function to GET url with client and return the status and body
+func get(t *testing.T, client *http.Client, url string) (int, string, error) {
+	t.Helper()
+	res, err := client.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Could not read body: %v", err)
+	}
+	return res.StatusCode, string(body), nil
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// Upstream returning a different body on every call
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "response %d", calls.Add(1))
+	}))
+	url := upstream.URL + "/joke?firstName=John"
+
+	t.Run("Record saves responses", func(t *testing.T) {
+		client := &http.Client{Transport: New(Record, dir, nil)}
+		for i := 1; i <= 2; i++ {
+			_, body, err := get(t, client, url)
+			if err != nil || body != fmt.Sprintf("response %d", i) {
+				t.Errorf("Expected live response %d; got %q, %v", i, body, err)
+			}
+		}
+	})
+
+	// Replay must not reach the network
+	upstream.Close()
+
+	t.Run("Replay cycles through recorded responses", func(t *testing.T) {
+		client := &http.Client{Transport: New(Replay, dir, nil)}
+		for _, want := range []string{"response 1", "response 2", "response 1"} {
+			status, body, err := get(t, client, url)
+			if err != nil || status != http.StatusOK || body != want {
+				t.Errorf("Expected %q; got %d %q, %v", want, status, body, err)
+			}
+		}
+	})
+
+	t.Run("Replay fails for unknown requests", func(t *testing.T) {
+		client := &http.Client{Transport: New(Replay, dir, nil)}
+		if _, _, err := get(t, client, upstream.URL+"/other"); !errors.Is(err, ErrNoFixture) {
+			t.Errorf("Expected ErrNoFixture; got %v", err)
+		}
+	})
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]Mode{"": Off, "off": Off, "record": Record, "replay": Replay} {
+		if got, err := ParseMode(s); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseMode("rewind"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}